@@ -16,11 +16,12 @@ package cmd
 
 import (
 	"context"
-	"errors"
 	"fmt"
-	"time"
+	"net/http"
 
+	"github.com/coinbase/rosetta-cli/pkg/fixture"
 	"github.com/coinbase/rosetta-cli/pkg/results"
+	"github.com/coinbase/rosetta-cli/pkg/retry"
 	"github.com/coinbase/rosetta-cli/pkg/tester"
 
 	"github.com/coinbase/rosetta-sdk-go/fetcher"
@@ -46,33 +47,77 @@ Check out the https://github.com/coinbase/rosetta-cli/tree/master/examples
 directory for examples of how to configure this test for Bitcoin and
 Ethereum.
 
+When re-running this command with the same data directory, it will
+resume where it left off (all created accounts, in-progress jobs, and
+pending broadcasts are loaded from storage). If no data directory is
+specified, a new temporary directory is created on each run.
+
 Right now, this tool only supports transfer testing (for both account-based
 and UTXO-based blockchains). However, we plan to add support for testing
 arbitrary scenarios (i.e. staking, governance).`,
 		RunE: runCheckConstructionCmd,
 	}
+
+	// CheckConstructionSeed is the seed used to derive all
+	// key pairs generated by check:construction (see --seed).
+	CheckConstructionSeed string
 )
 
-func runCheckConstructionCmd(cmd *cobra.Command, args []string) error {
+func runCheckConstructionCmd(cmd *cobra.Command, args []string) (err error) {
 	if Config.Construction == nil {
 		return results.ExitConstruction(
 			Config,
 			nil,
 			nil,
-			errors.New("construction configuration is missing"),
+			nil,
+			fmt.Errorf("%w: construction configuration is missing", ErrInvalidConfiguration),
+		)
+	}
+
+	if len(CheckConstructionSeed) > 0 {
+		Config.Construction.Seed = CheckConstructionSeed
+	}
+
+	if err := ensureDataDirectoryExists(Config); err != nil {
+		return results.ExitConstruction(
+			Config,
+			nil,
+			nil,
+			nil,
+			err,
 		)
 	}
 
-	ensureDataDirectoryExists()
 	ctx, cancel := context.WithCancel(Context)
 
-	fetcher := fetcher.New(
+	// When a fixture output file is provided, all Construction API
+	// requests made to the online and offline implementations
+	// are recorded.
+	var recorder *fixture.Recorder
+	var wrap func(http.RoundTripper) http.RoundTripper
+	if len(Config.Construction.FixtureOutputFile) > 0 {
+		recorder = fixture.NewRecorder(Config.Network)
+		wrap = recorder.Wrap
+	}
+
+	injector, inject := newChaosInjector(Config)
+	fetcher, err := retry.NewInjectedFetcher(
+		Config,
 		Config.OnlineURL,
+		inject,
+		wrap,
 		fetcher.WithMaxConnections(Config.MaxOnlineConnections),
-		fetcher.WithRetryElapsedTime(time.Duration(Config.RetryElapsedTime)*time.Second),
-		fetcher.WithTimeout(time.Duration(Config.HTTPTimeout)*time.Second),
-		fetcher.WithMaxRetries(Config.MaxRetries),
 	)
+	if err != nil {
+		cancel()
+		return results.ExitConstruction(
+			Config,
+			nil,
+			nil,
+			nil,
+			fmt.Errorf("%w: unable to create fetcher", err),
+		)
+	}
 
 	_, _, fetchErr := fetcher.InitializeAsserter(ctx, Config.Network)
 	if fetchErr != nil {
@@ -81,17 +126,19 @@ func runCheckConstructionCmd(cmd *cobra.Command, args []string) error {
 			Config,
 			nil,
 			nil,
+			nil,
 			fmt.Errorf("%w: unable to initialize asserter", fetchErr.Err),
 		)
 	}
 
-	_, err := utils.CheckNetworkSupported(ctx, Config.Network, fetcher)
+	_, err = utils.CheckNetworkSupported(ctx, Config.Network, fetcher)
 	if err != nil {
 		cancel()
 		return results.ExitConstruction(
 			Config,
 			nil,
 			nil,
+			nil,
 			fmt.Errorf("%w: unable to confirm network is supported", err),
 		)
 	}
@@ -101,6 +148,7 @@ func runCheckConstructionCmd(cmd *cobra.Command, args []string) error {
 		Config,
 		Config.Network,
 		fetcher,
+		recorder,
 		cancel,
 		&SignalReceived,
 	)
@@ -109,17 +157,19 @@ func runCheckConstructionCmd(cmd *cobra.Command, args []string) error {
 			Config,
 			nil,
 			nil,
+			nil,
 			fmt.Errorf("%w: unable to initialize construction tester", err),
 		)
 	}
 
-	defer constructionTester.CloseDatabase(ctx)
+	defer closeOnExit(&err, func() error { return constructionTester.CloseDatabase(ctx) })
 
 	if err := constructionTester.PerformBroadcasts(ctx); err != nil {
 		return results.ExitConstruction(
 			Config,
 			nil,
 			nil,
+			nil,
 			fmt.Errorf("%w: unable to perform broadcasts", err),
 		)
 	}
@@ -150,12 +200,15 @@ func runCheckConstructionCmd(cmd *cobra.Command, args []string) error {
 			ctx,
 			"check:construction status",
 			constructionTester,
-			Config.Construction.StatusPort,
+			statusAddr(Config.Construction.StatusPort),
 		)
 	})
 
-	sigListeners := []context.CancelFunc{cancel}
+	sigListeners := []context.CancelFunc{constructionTester.Halt, cancel}
 	go handleSignals(&sigListeners)
 
-	return constructionTester.HandleErr(g.Wait(), &sigListeners)
+	err = g.Wait()
+	reportChaos(injector, Config)
+
+	return constructionTester.HandleErr(err, &sigListeners)
 }
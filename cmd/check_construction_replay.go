@@ -0,0 +1,90 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/coinbase/rosetta-cli/pkg/fixture"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	checkConstructionReplayCmd = &cobra.Command{
+		Use:   "check:construction-replay",
+		Short: "Replay a recorded check:construction run against an implementation",
+		Long: `This command re-executes the offline Construction API requests
+(/construction/derive, /construction/preprocess, /construction/payloads,
+/construction/combine, /construction/parse, and /construction/hash) recorded
+during a successful check:construction run against the offline_url in the
+construction configuration and ensures each response is identical to the
+recorded response. Nothing is broadcast, so this can be used to detect
+construction regressions in a new version of an implementation.
+
+To record a fixture, populate fixture_output_file in the construction
+configuration before running check:construction.
+
+The argument for this command is the path of the recorded fixture.`,
+		RunE: runCheckConstructionReplayCmd,
+		Args: cobra.ExactArgs(1),
+	}
+
+	// errReplayMismatch is returned when any replayed
+	// response differs from the recorded response.
+	errReplayMismatch = errors.New("replayed responses do not match fixture")
+)
+
+func runCheckConstructionReplayCmd(cmd *cobra.Command, args []string) error {
+	if Config.Construction == nil {
+		return fmt.Errorf("%w: construction configuration is missing", ErrInvalidConfiguration)
+	}
+
+	f, err := fixture.Load(args[0])
+	if err != nil {
+		return err
+	}
+
+	if types.Hash(f.Network) != types.Hash(Config.Network) {
+		return fmt.Errorf(
+			"fixture network %s does not match configured network %s",
+			types.PrintStruct(f.Network),
+			types.PrintStruct(Config.Network),
+		)
+	}
+
+	mismatches, err := fixture.Replay(
+		Context,
+		&http.Client{Timeout: time.Duration(Config.HTTPTimeout) * time.Second},
+		Config.Construction.OfflineURL,
+		f,
+	)
+	if err != nil {
+		return fmt.Errorf("%w: unable to replay fixture", err)
+	}
+
+	if len(mismatches) > 0 {
+		fixture.PrintMismatches(mismatches)
+		return fmt.Errorf("%w: %d responses differ", errReplayMismatch, len(mismatches))
+	}
+
+	color.Green("All replayed responses match the fixture!")
+	return nil
+}
@@ -17,20 +17,29 @@ package cmd
 import (
 	"context"
 	"fmt"
-	"time"
+	"os"
 
+	"github.com/coinbase/rosetta-cli/configuration"
 	"github.com/coinbase/rosetta-cli/pkg/results"
+	"github.com/coinbase/rosetta-cli/pkg/retry"
 	"github.com/coinbase/rosetta-cli/pkg/tester"
 
 	"github.com/coinbase/rosetta-sdk-go/fetcher"
 	"github.com/coinbase/rosetta-sdk-go/utils"
+	"github.com/fatih/color"
+	"github.com/olekukonko/tablewriter"
 	"github.com/spf13/cobra"
 	"golang.org/x/sync/errgroup"
 )
 
+const (
+	// checkDataCmdName is the name of the check:data command.
+	checkDataCmdName = "check:data"
+)
+
 var (
 	checkDataCmd = &cobra.Command{
-		Use:   "check:data",
+		Use:   checkDataCmdName,
 		Short: "Check the correctness of a Rosetta Data API Implementation",
 		Long: `Check all server responses are properly constructed, that
 there are no duplicate blocks and transactions, that blocks can be processed
@@ -65,67 +74,248 @@ If your blockchain has a genesis allocation of funds and you set
 historical balance disabled to true, you must provide an
 absolute path to a JSON file containing initial balances with the
 bootstrap balance config. You can look at the examples folder for an example
-of what one of these files looks like.`,
+of what one of these files looks like.
+
+To quickly check that all blocks can be synced and are properly constructed
+(for example, as a smoke test in CI before running a full check), run with
+--quick. This disables balance tracking, coin tracking, and reconciliation.
+
+If syncing starts far from tip, populate bulk_load_index in the configuration
+file to bulk load all blocks up to that index (without reconciling them) before
+the check starts. Bulk loading writes blocks in large batches instead of
+committing a database transaction for each block.
+
+To inspect the stored data when a check fails (before the automatic search
+for missing operations runs or any data is changed), run with --halt-on-error.
+Instead of exiting, the check prints the failure and reads the queries of the
+inspect command from stdin while the status server keeps running. Run continue
+to resume the usual failure handling or abort to exit immediately.
+
+If the configuration file defines profiles, run with --profile all to run
+this command for each profile sequentially (in alphabetical order). Each
+profile should populate end conditions so that its check completes. Once
+all profiles have been checked (or a signal is received), the result of
+each profile is printed and the exit code of the first failed profile is
+returned.`,
 		RunE: runCheckDataCmd,
 	}
+
+	// CheckDataQuick is a boolean indicating if check:data
+	// should run in quick mode (see --quick).
+	CheckDataQuick bool
+
+	// CheckDataHaltOnError is a boolean indicating if check:data
+	// should halt for inspection when it fails (see --halt-on-error).
+	CheckDataHaltOnError bool
 )
 
 func runCheckDataCmd(cmd *cobra.Command, args []string) error {
-	ensureDataDirectoryExists()
+	if Profile == configuration.AllProfiles {
+		return checkDataProfiles()
+	}
+
+	return checkData(Config)
+}
+
+// checkDataProfiles runs check:data for each profile in the
+// configuration file sequentially and returns the error of
+// the first profile that failed (if any).
+func checkDataProfiles() error {
+	names := configuration.ProfileNames(Config)
+	if len(names) == 0 {
+		return fmt.Errorf("%w: no profiles defined", ErrInvalidConfiguration)
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetRowLine(true)
+	table.SetRowSeparator("-")
+	table.SetHeader([]string{"Profile", "Result"})
+
+	var firstErr error
+	for _, name := range names {
+		if SignalReceived {
+			table.Append([]string{name, "skipped (signal received)"})
+			continue
+		}
+
+		color.Cyan("running check:data for profile %s", name)
+		err := checkDataProfile(name)
+		if err == nil {
+			table.Append([]string{name, "success"})
+			continue
+		}
+
+		table.Append([]string{name, err.Error()})
+		if firstErr == nil {
+			firstErr = fmt.Errorf("%w: check:data failed for profile %s", err, name)
+		}
+	}
+
+	table.Render()
+	return firstErr
+}
+
+// checkDataProfile loads the profile named name and
+// runs check:data with it (Config is not modified).
+func checkDataProfile(name string) error {
+	config, err := configuration.LoadProfile(Context, configurationFile, name)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidConfiguration, err.Error())
+	}
+
+	if err := configureLogging(config); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidConfiguration, err.Error())
+	}
+
+	return checkData(config)
+}
+
+// checkData runs check:data with config.
+func checkData(config *configuration.Configuration) (err error) {
+	if CheckDataQuick {
+		if err := configuration.EnableQuickMode(config); err != nil {
+			return fmt.Errorf("%w: %s", ErrInvalidConfiguration, err.Error())
+		}
+	}
+
+	if config.Data.QuickMode {
+		color.Cyan("quick mode: balance tracking, coin tracking, and reconciliation are disabled")
+	}
+
+	if err := ensureDataDirectoryExists(config); err != nil {
+		return results.ExitData(
+			config,
+			nil,
+			nil,
+			err,
+			"",
+			"",
+			nil,
+		)
+	}
+
 	ctx, cancel := context.WithCancel(Context)
 
-	fetcher := fetcher.New(
-		Config.OnlineURL,
-		fetcher.WithMaxConnections(Config.MaxOnlineConnections),
-		fetcher.WithRetryElapsedTime(time.Duration(Config.RetryElapsedTime)*time.Second),
-		fetcher.WithTimeout(time.Duration(Config.HTTPTimeout)*time.Second),
-		fetcher.WithMaxRetries(Config.MaxRetries),
+	injector, inject := newChaosInjector(config)
+	fetcher, err := retry.NewInjectedFetcher(
+		config,
+		config.OnlineURL,
+		inject,
+		nil,
+		fetcher.WithMaxConnections(config.MaxOnlineConnections),
 	)
+	if err != nil {
+		cancel()
+		return results.ExitData(
+			config,
+			nil,
+			nil,
+			fmt.Errorf("%w: unable to create fetcher", err),
+			"",
+			"",
+			nil,
+		)
+	}
 
-	_, _, fetchErr := fetcher.InitializeAsserter(ctx, Config.Network)
+	_, _, fetchErr := fetcher.InitializeAsserter(ctx, config.Network)
 	if fetchErr != nil {
 		cancel()
 		return results.ExitData(
-			Config,
+			config,
 			nil,
 			nil,
 			fmt.Errorf("%w: unable to initialize asserter", fetchErr.Err),
 			"",
 			"",
+			nil,
 		)
 	}
 
-	networkStatus, err := utils.CheckNetworkSupported(ctx, Config.Network, fetcher)
+	networkStatus, err := utils.CheckNetworkSupported(ctx, config.Network, fetcher)
 	if err != nil {
 		cancel()
 		return results.ExitData(
-			Config,
+			config,
 			nil,
 			nil,
 			fmt.Errorf("%w: unable to confirm network", err),
 			"",
 			"",
+			nil,
 		)
 	}
 
-	dataTester := tester.InitializeData(
+	loaded, err := tester.BulkLoad(
 		ctx,
-		Config,
-		Config.Network,
+		config,
+		config.Network,
+		fetcher,
+		cancel,
+		networkStatus.GenesisBlockIdentifier,
+		&SignalReceived,
+	)
+	if err != nil {
+		cancel()
+		return results.ExitData(
+			config,
+			nil,
+			nil,
+			err,
+			"",
+			"",
+			nil,
+		)
+	}
+
+	// Once blocks are bulk loaded, the check resumes
+	// from the last bulk loaded block.
+	if loaded && config.Data.StartIndex != nil {
+		data := *config.Data
+		data.StartIndex = nil
+		resumed := *config
+		resumed.Data = &data
+		config = &resumed
+	}
+
+	dataTester, err := tester.InitializeData(
+		ctx,
+		config,
+		config.Network,
 		fetcher,
 		cancel,
 		networkStatus.GenesisBlockIdentifier,
 		nil, // only populated when doing recursive search
 		&SignalReceived,
 	)
+	if err != nil {
+		cancel()
+		return results.ExitData(
+			config,
+			nil,
+			nil,
+			fmt.Errorf("%w: unable to initialize data tester", err),
+			"",
+			"",
+			nil,
+		)
+	}
+
+	defer closeOnExit(&err, func() error { return dataTester.CloseDatabase(ctx) })
 
-	defer dataTester.CloseDatabase(ctx)
+	// When halting on errors, the status server must keep
+	// running after the check fails (which cancels ctx).
+	haltCtx, haltCancel := context.WithCancel(Context)
+	defer haltCancel()
 
 	g, ctx := errgroup.WithContext(ctx)
 	g.Go(func() error {
 		return dataTester.StartPeriodicLogger(ctx)
 	})
 
+	g.Go(func() error {
+		return dataTester.StartCounterSampler(ctx)
+	})
+
 	g.Go(func() error {
 		return dataTester.StartReconciler(ctx)
 	})
@@ -138,6 +328,18 @@ func runCheckDataCmd(cmd *cobra.Command, args []string) error {
 		return dataTester.StartPruning(ctx)
 	})
 
+	g.Go(func() error {
+		return dataTester.StartDiskSpaceWatchdog(ctx)
+	})
+
+	g.Go(func() error {
+		return dataTester.StartAccountFileReloader(ctx)
+	})
+
+	g.Go(func() error {
+		return dataTester.StartArtifactUploader(ctx)
+	})
+
 	g.Go(func() error {
 		return dataTester.WatchEndConditions(ctx)
 	})
@@ -147,18 +349,30 @@ func runCheckDataCmd(cmd *cobra.Command, args []string) error {
 	})
 
 	g.Go(func() error {
+		serverCtx := ctx
+		if CheckDataHaltOnError {
+			serverCtx = haltCtx
+		}
+
 		return tester.StartServer(
-			ctx,
+			serverCtx,
 			"check:data status",
 			dataTester,
-			Config.Data.StatusPort,
+			statusAddr(config.Data.StatusPort),
 		)
 	})
 
-	sigListeners := []context.CancelFunc{cancel}
+	sigListeners := []context.CancelFunc{dataTester.Halt, cancel, haltCancel}
 	go handleSignals(&sigListeners)
 
+	err = g.Wait()
+	reportChaos(injector, config)
+
+	if CheckDataHaltOnError {
+		dataTester.HaltOnError(haltCtx, err, os.Stdin, os.Stdout)
+	}
+
 	// HandleErr will exit if we should not attempt
 	// to find missing operations.
-	return dataTester.HandleErr(g.Wait(), &sigListeners)
+	return dataTester.HandleErr(err, &sigListeners)
 }
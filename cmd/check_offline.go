@@ -0,0 +1,240 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/coinbase/rosetta-cli/pkg/fixture"
+	"github.com/coinbase/rosetta-cli/pkg/offline"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// offlineReadyInterval is the interval between attempts
+	// to connect to a launched offline implementation.
+	offlineReadyInterval = 500 * time.Millisecond
+)
+
+var (
+	checkOfflineCmd = &cobra.Command{
+		Use:   "check:offline <fixture> [-- command [args...]]",
+		Short: "Ensure the offline Construction API endpoints make no outbound connections",
+		Long: `This command replays the offline Construction API requests
+(/construction/derive, /construction/preprocess, /construction/payloads,
+/construction/combine, /construction/parse, and /construction/hash) recorded
+in a check:construction fixture against the offline_url in the construction
+configuration while blocking all outbound connections of the implementation.
+
+All outbound connections are blocked by an HTTP proxy started on --proxy-addr.
+If a command is provided after "--", it is launched with HTTP_PROXY and
+HTTPS_PROXY set to the proxy (and stopped when the check completes).
+Otherwise, the implementation must already be running with these variables
+set. Every connection attempted through the proxy is attributed to the
+request being replayed, and any request that does not return the recorded
+status code is reported (because it likely required a node).
+
+Implementations that ignore proxy environment variables can only be
+checked by running them without network access (like with
+"docker run --network none"), in which case requests that require
+online access fail and are reported.
+
+To record a fixture, populate fixture_output_file in the construction
+configuration before running check:construction.`,
+		RunE: runCheckOfflineCmd,
+		Args: cobra.MinimumNArgs(1),
+	}
+
+	// OfflineProxyAddr is the address the
+	// egress-blocking proxy listens on.
+	OfflineProxyAddr string
+
+	// OfflineStartupTimeout is the number of seconds to wait
+	// for a launched offline implementation to accept
+	// connections.
+	OfflineStartupTimeout uint64
+
+	// errOnlineAccess is returned when any offline endpoint
+	// attempts an outbound connection or fails.
+	errOnlineAccess = errors.New("offline endpoints required online access")
+)
+
+// offlineEnv returns the environment of the process with all
+// proxy variables replaced by proxyURL.
+func offlineEnv(proxyURL string) []string {
+	env := []string{}
+	for _, variable := range os.Environ() {
+		name := strings.ToUpper(strings.SplitN(variable, "=", 2)[0])
+		switch name {
+		case "HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY", "ALL_PROXY":
+			continue
+		}
+
+		env = append(env, variable)
+	}
+
+	return append(
+		env,
+		fmt.Sprintf("HTTP_PROXY=%s", proxyURL),
+		fmt.Sprintf("HTTPS_PROXY=%s", proxyURL),
+		fmt.Sprintf("http_proxy=%s", proxyURL),
+		fmt.Sprintf("https_proxy=%s", proxyURL),
+	)
+}
+
+// waitForOffline waits until the host of offlineURL
+// accepts connections or timeout elapses.
+func waitForOffline(ctx context.Context, offlineURL string, timeout time.Duration) error {
+	parsed, err := url.Parse(offlineURL)
+	if err != nil {
+		return fmt.Errorf("%w: unable to parse offline url", err)
+	}
+
+	host := parsed.Host
+	if len(parsed.Port()) == 0 {
+		host = net.JoinHostPort(parsed.Hostname(), "80")
+		if parsed.Scheme == "https" {
+			host = net.JoinHostPort(parsed.Hostname(), "443")
+		}
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		conn, err := net.DialTimeout("tcp", host, offlineReadyInterval)
+		if err == nil {
+			_ = conn.Close()
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%w: %s did not accept connections", err, host)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(offlineReadyInterval):
+		}
+	}
+}
+
+func runCheckOfflineCmd(cmd *cobra.Command, args []string) error {
+	if Config.Construction == nil {
+		return fmt.Errorf("%w: construction configuration is missing", ErrInvalidConfiguration)
+	}
+
+	command := []string{}
+	if dash := cmd.ArgsLenAtDash(); dash >= 0 {
+		if dash != 1 {
+			return errors.New("a single fixture must be provided before --")
+		}
+
+		command = args[dash:]
+	} else if len(args) != 1 {
+		return errors.New("a single fixture must be provided")
+	}
+
+	f, err := fixture.Load(args[0])
+	if err != nil {
+		return err
+	}
+
+	if types.Hash(f.Network) != types.Hash(Config.Network) {
+		return fmt.Errorf(
+			"fixture network %s does not match configured network %s",
+			types.PrintStruct(f.Network),
+			types.PrintStruct(Config.Network),
+		)
+	}
+
+	ctx, cancel := context.WithCancel(Context)
+	defer cancel()
+
+	listener, err := net.Listen("tcp", OfflineProxyAddr)
+	if err != nil {
+		return fmt.Errorf("%w: unable to listen on %s", err, OfflineProxyAddr)
+	}
+
+	proxy := offline.NewProxy()
+	proxyServer := &http.Server{Handler: proxy}
+	go func() {
+		_ = proxyServer.Serve(listener)
+	}()
+	defer proxyServer.Close()
+
+	proxyURL := fmt.Sprintf("http://%s", listener.Addr().String())
+	log.Printf("blocking outbound connections with proxy %s\n", proxyURL)
+
+	if len(command) > 0 {
+		implementation := exec.CommandContext(ctx, command[0], command[1:]...)
+		implementation.Env = offlineEnv(proxyURL)
+		implementation.Stdout = os.Stderr
+		implementation.Stderr = os.Stderr
+		if err := implementation.Start(); err != nil {
+			return fmt.Errorf("%w: unable to start %s", err, command[0])
+		}
+
+		defer func() {
+			cancel()
+			_ = implementation.Wait()
+		}()
+
+		if err := waitForOffline(
+			ctx,
+			Config.Construction.OfflineURL,
+			time.Duration(OfflineStartupTimeout)*time.Second,
+		); err != nil {
+			return fmt.Errorf("%w: offline implementation is not ready", err)
+		}
+	}
+
+	// Requests to the implementation must never
+	// be sent through a proxy.
+	client := &http.Client{
+		Timeout:   time.Duration(Config.HTTPTimeout) * time.Second,
+		Transport: &http.Transport{Proxy: nil},
+	}
+
+	report, err := offline.Check(ctx, client, Config.Construction.OfflineURL, f, proxy)
+	if err != nil {
+		return fmt.Errorf("%w: unable to check offline endpoints", err)
+	}
+
+	report.Print()
+	if !report.Passed() {
+		return fmt.Errorf(
+			"%w: %d requests and %d startup attempts",
+			errOnlineAccess,
+			len(report.Violations),
+			len(report.Unattributed),
+		)
+	}
+
+	color.Green("No offline endpoint attempted an outbound connection!")
+	return nil
+}
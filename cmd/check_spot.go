@@ -0,0 +1,121 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/coinbase/rosetta-cli/pkg/retry"
+	"github.com/coinbase/rosetta-cli/pkg/spotcheck"
+
+	"github.com/coinbase/rosetta-sdk-go/parser"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/coinbase/rosetta-sdk-go/utils"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	checkSpotCmd = &cobra.Command{
+		Use:   "check:spot",
+		Short: "Spot-check randomly sampled historical blocks",
+		Long: `Running check:data from genesis can take days on large
+blockchains. This command provides a quick sanity check of a Data API
+implementation by randomly sampling blocks across the entire chain
+(between genesis and the current block).
+
+Each sampled block is fetched and its format is asserted. Then, a sample of
+the accounts whose balances change in the block is selected and their
+historical balances are fetched at the block and at its parent. If the
+balance at the parent plus the balance change computed from the block's
+operations does not equal the balance at the block, the mismatch is printed
+and this command exits with an error.
+
+To quickly check that a node is sane right now (instead of sampling its
+entire history), run with --from-tip N. Starting at the current block, this
+command walks back through the parent of each block (fetched by its hash)
+for N blocks and checks each of them in the same way. If the block returned
+for a parent is not the requested parent, this command exits with an error.
+
+This command requires historical balance lookup to be supported by the
+implementation. No data is stored.`,
+		RunE: runCheckSpotCmd,
+	}
+
+	// SpotCheckSamples is the number of blocks sampled by check:spot.
+	SpotCheckSamples int
+
+	// SpotCheckAccounts is the maximum number of accounts checked
+	// in each block sampled by check:spot.
+	SpotCheckAccounts int
+
+	// SpotCheckFromTip is the number of blocks checked by check:spot
+	// walking back from the current block (if not 0).
+	SpotCheckFromTip int
+)
+
+func runCheckSpotCmd(cmd *cobra.Command, args []string) error {
+	if SpotCheckSamples <= 0 || SpotCheckAccounts <= 0 {
+		return fmt.Errorf("samples and accounts must be positive")
+	}
+
+	if SpotCheckFromTip < 0 {
+		return fmt.Errorf("from tip must not be negative")
+	}
+
+	newFetcher, err := retry.NewFetcher(Config, Config.OnlineURL)
+	if err != nil {
+		return fmt.Errorf("%w: unable to create fetcher", err)
+	}
+	_, _, fetchErr := newFetcher.InitializeAsserter(Context, Config.Network)
+	if fetchErr != nil {
+		return fmt.Errorf("%w: unable to initialize asserter", fetchErr.Err)
+	}
+
+	_, err = utils.CheckNetworkSupported(Context, Config.Network, newFetcher)
+	if err != nil {
+		return fmt.Errorf("%w: unable to confirm network is supported", err)
+	}
+
+	// Like view:block, no operations are exempt from parsing.
+	p := parser.New(newFetcher.Asserter, func(*types.Operation) bool { return false }, nil)
+	checker := spotcheck.New(Config.Network, newFetcher, p, SpotCheckAccounts)
+
+	var results *spotcheck.Results
+	if SpotCheckFromTip > 0 {
+		results, err = checker.RunFromTip(Context, SpotCheckFromTip)
+	} else {
+		results, err = checker.Run(Context, SpotCheckSamples)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: unable to spot-check blocks", err)
+	}
+
+	if len(results.Mismatches) > 0 {
+		results.Print()
+		return fmt.Errorf(
+			"found %d balance mismatches in %d blocks",
+			len(results.Mismatches),
+			results.BlocksChecked,
+		)
+	}
+
+	color.Green(
+		"Success: checked %d accounts in %d blocks",
+		results.AccountsChecked,
+		results.BlocksChecked,
+	)
+	return nil
+}
@@ -0,0 +1,128 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/coinbase/rosetta-cli/pkg/compare"
+	"github.com/coinbase/rosetta-cli/pkg/retry"
+
+	"github.com/coinbase/rosetta-sdk-go/fetcher"
+	"github.com/coinbase/rosetta-sdk-go/parser"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/coinbase/rosetta-sdk-go/utils"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// compareArgs are the URLs of the
+	// implementations to compare.
+	compareArgs = 2
+)
+
+var (
+	compareNetworksCmd = &cobra.Command{
+		Use:   "compare:networks",
+		Short: "Compare two implementations block-by-block",
+		Long: `When upgrading a node (or migrating to a new implementation), it is
+useful to confirm that the new version returns the same data as the old
+version. This command fetches each block from both implementations in
+lock-step (starting at genesis by default) and exits with an error at the
+first divergence in block identifiers, transactions, or operations.
+
+If --balances is set, the balances of all accounts whose balances change
+in each block are also fetched from both implementations at that block
+and compared. This requires historical balance lookup to be supported by
+both implementations.
+
+The network in the configuration file is used for both implementations.
+The URLs of both implementations must be provided as arguments
+(i.e. compare:networks http://old-node:8080 http://new-node:8080). No
+data is stored.`,
+		RunE: runCompareNetworksCmd,
+		Args: cobra.ExactArgs(compareArgs),
+	}
+
+	// CompareStartIndex is the index of the first
+	// block compared by compare:networks.
+	CompareStartIndex int64
+
+	// CompareEndIndex is the index of the last
+	// block compared by compare:networks.
+	CompareEndIndex int64
+
+	// CompareBalances determines if compare:networks
+	// compares the balances of accounts.
+	CompareBalances bool
+)
+
+// initializeFetcher returns a *fetcher.Fetcher for the
+// implementation at url with an initialized asserter.
+func initializeFetcher(url string) (*fetcher.Fetcher, error) {
+	newFetcher, err := retry.NewFetcher(Config, url)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to create fetcher for %s", err, url)
+	}
+	_, _, fetchErr := newFetcher.InitializeAsserter(Context, Config.Network)
+	if fetchErr != nil {
+		return nil, fmt.Errorf("%w: unable to initialize asserter for %s", fetchErr.Err, url)
+	}
+
+	_, err = utils.CheckNetworkSupported(Context, Config.Network, newFetcher)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to confirm network is supported by %s", err, url)
+	}
+
+	return newFetcher, nil
+}
+
+func runCompareNetworksCmd(cmd *cobra.Command, args []string) error {
+	left, err := initializeFetcher(args[0])
+	if err != nil {
+		return err
+	}
+
+	right, err := initializeFetcher(args[1])
+	if err != nil {
+		return err
+	}
+
+	// Like view:block, no operations are exempt from parsing.
+	p := parser.New(left.Asserter, func(*types.Operation) bool { return false }, nil)
+	comparer := compare.New(Config.Network, left, right, p, CompareBalances)
+	results, err := comparer.Run(Context, CompareStartIndex, CompareEndIndex)
+	if err != nil {
+		return fmt.Errorf("%w: unable to compare implementations", err)
+	}
+
+	if results.Divergence != nil {
+		results.Divergence.Print()
+		return fmt.Errorf(
+			"%s divergence found at block %d after comparing %d blocks",
+			results.Divergence.Type,
+			results.Divergence.Index,
+			results.Compared,
+		)
+	}
+
+	color.Green(
+		"Success: blocks %d to %d are identical",
+		results.StartIndex,
+		results.EndIndex,
+	)
+	return nil
+}
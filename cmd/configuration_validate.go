@@ -19,6 +19,7 @@ import (
 
 	"github.com/coinbase/rosetta-cli/configuration"
 
+	"github.com/coinbase/rosetta-sdk-go/types"
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 )
@@ -27,17 +28,52 @@ var (
 	configurationValidateCmd = &cobra.Command{
 		Use:   "configuration:validate",
 		Short: "Ensure a configuration file at the provided path is formatted correctly",
-		RunE:  runConfigurationValidateCmd,
-		Args:  cobra.ExactArgs(1),
+		Long: `Validate the correctness of a configuration file at the provided path
+without connecting to any Rosetta API implementation.
+
+The configuration file is loaded exactly as it is by check:data and
+check:construction: unknown fields are rejected (with the line they
+are on in JSON files), environment overrides and defaults are applied,
+all URLs and mutually exclusive options are checked, and all workflows
+(including the DSL file and workflows directory) are compiled.
+
+If the configuration file defines profiles, the profile selected with
+--profile is applied before the configuration is validated. If no profile
+is selected, each profile is also validated.
+
+If the configuration file is valid, the normalized effective configuration
+(with all defaults populated) is printed.`,
+		RunE: runConfigurationValidateCmd,
+		Args: cobra.ExactArgs(1),
 	}
 )
 
 func runConfigurationValidateCmd(cmd *cobra.Command, args []string) error {
-	_, err := configuration.LoadConfiguration(Context, args[0])
+	config, err := configuration.LoadProfile(Context, args[0], Profile)
 	if err != nil {
-		return fmt.Errorf("%w: configuration validation failed %s", err, args[0])
+		return fmt.Errorf(
+			"%w: %s: configuration validation failed %s",
+			ErrInvalidConfiguration,
+			err.Error(),
+			args[0],
+		)
+	}
+
+	if len(Profile) == 0 {
+		for _, name := range configuration.ProfileNames(config) {
+			if _, err := configuration.LoadProfile(Context, args[0], name); err != nil {
+				return fmt.Errorf(
+					"%w: %s: configuration validation failed %s (profile %s)",
+					ErrInvalidConfiguration,
+					err.Error(),
+					args[0],
+					name,
+				)
+			}
+		}
 	}
 
 	color.Green("Configuration file validated!")
+	fmt.Println(types.PrettyPrintStruct(config))
 	return nil
 }
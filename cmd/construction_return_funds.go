@@ -0,0 +1,117 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/coinbase/rosetta-cli/pkg/retry"
+	"github.com/coinbase/rosetta-cli/pkg/tester"
+
+	"github.com/coinbase/rosetta-sdk-go/constructor/job"
+	"github.com/coinbase/rosetta-sdk-go/fetcher"
+	"github.com/coinbase/rosetta-sdk-go/utils"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	constructionReturnFundsCmd = &cobra.Command{
+		Use:   "construction:return-funds",
+		Short: "Return funds from all accounts created by check:construction",
+		Long: `check:construction executes the return_funds workflow once all
+end conditions are reached. If a run is halted before then (or the workflow
+fails), funds are left in the accounts generated during the run.
+
+This command loads all accounts (and keys) stored by check:construction
+in the data_directory and executes the return_funds workflow until it can
+no longer be executed, syncing blocks to confirm each broadcast. The
+address funds are returned to is specified in the return_funds workflow.
+No other workflows are executed.`,
+		RunE: runConstructionReturnFundsCmd,
+	}
+
+	// errNoReturnFundsWorkflow is returned when there
+	// is no return_funds workflow to execute.
+	errNoReturnFundsWorkflow = fmt.Errorf("no %s workflow is defined", job.ReturnFunds)
+)
+
+func runConstructionReturnFundsCmd(cmd *cobra.Command, args []string) (err error) {
+	if Config.Construction == nil {
+		return fmt.Errorf("%w: construction configuration is missing", ErrInvalidConfiguration)
+	}
+
+	defined := false
+	for _, workflow := range Config.Construction.Workflows {
+		if workflow.Name == string(job.ReturnFunds) {
+			defined = true
+			break
+		}
+	}
+	if !defined {
+		return errNoReturnFundsWorkflow
+	}
+
+	if err := ensureDataDirectoryExists(Config); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(Context)
+	defer cancel()
+
+	fetcher, err := retry.NewFetcher(
+		Config,
+		Config.OnlineURL,
+		fetcher.WithMaxConnections(Config.MaxOnlineConnections),
+	)
+	if err != nil {
+		return fmt.Errorf("%w: unable to create fetcher", err)
+	}
+
+	_, _, fetchErr := fetcher.InitializeAsserter(ctx, Config.Network)
+	if fetchErr != nil {
+		return fmt.Errorf("%w: unable to initialize asserter", fetchErr.Err)
+	}
+
+	if _, err := utils.CheckNetworkSupported(ctx, Config.Network, fetcher); err != nil {
+		return fmt.Errorf("%w: unable to confirm network is supported", err)
+	}
+
+	constructionTester, err := tester.InitializeConstruction(
+		ctx,
+		Config,
+		Config.Network,
+		fetcher,
+		nil,
+		cancel,
+		&SignalReceived,
+	)
+	if err != nil {
+		return fmt.Errorf("%w: unable to initialize construction tester", err)
+	}
+
+	defer closeOnExit(&err, func() error { return constructionTester.CloseDatabase(ctx) })
+
+	sigListeners := []context.CancelFunc{constructionTester.Halt, cancel}
+	go handleSignals(&sigListeners)
+
+	if err := constructionTester.ReturnFunds(ctx, &sigListeners); err != nil {
+		return err
+	}
+
+	color.Green("Returned funds from all accounts!")
+	return nil
+}
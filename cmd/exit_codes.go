@@ -0,0 +1,156 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"log"
+
+	"github.com/coinbase/rosetta-cli/pkg/processor"
+	"github.com/coinbase/rosetta-cli/pkg/results"
+	"github.com/coinbase/rosetta-cli/pkg/retry"
+
+	"github.com/coinbase/rosetta-sdk-go/asserter"
+	"github.com/coinbase/rosetta-sdk-go/fetcher"
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/coinbase/rosetta-sdk-go/syncer"
+)
+
+const (
+	// FailureExitCode is the exit code used when a command
+	// fails for any reason without a more specific exit code.
+	FailureExitCode = 1
+
+	// ConfigurationExitCode is the exit code used when the
+	// configuration file (or a flag) is invalid.
+	ConfigurationExitCode = 2
+
+	// HaltedExitCode is the exit code used when a check
+	// is halted by SIGINT or SIGTERM. Restarting the check
+	// resumes syncing at the block after the last
+	// synced block.
+	HaltedExitCode = 3
+
+	// NodeUnreachableExitCode is the exit code used when
+	// requests to the node fail (after all retries).
+	NodeUnreachableExitCode = 4
+
+	// AssertionExitCode is the exit code used when a response
+	// of the node is not correctly formatted or a synced block
+	// fails a check (like duplicate hashes or an invariant).
+	AssertionExitCode = 5
+
+	// ReconciliationExitCode is the exit code used when a
+	// computed balance does not match the balance returned by
+	// the node (or a computed balance is negative).
+	ReconciliationExitCode = 6
+
+	// StorageExitCode is the exit code used when data cannot
+	// be read from or written to storage (or stored data
+	// is inconsistent).
+	StorageExitCode = 7
+)
+
+// ErrInvalidConfiguration is returned when the
+// configuration file (or a flag) is invalid.
+var ErrInvalidConfiguration = errors.New("invalid configuration")
+
+// assertionErrors are returned when a synced
+// block fails a check of the rosetta-cli.
+var assertionErrors = []error{
+	results.ErrAccountNotCreated,
+	results.ErrInvariantViolation,
+	results.ErrFeeMismatch,
+	results.ErrDuplicateHash,
+	results.ErrInvalidTimestamp,
+	results.ErrSupplyMismatch,
+}
+
+// nodeErrors are returned when
+// requests to the node fail.
+var nodeErrors = []error{
+	processor.ErrNodeUnavailable,
+	processor.ErrNodeGenesisChanged,
+	syncer.ErrGetNetworkStatusFailed,
+	syncer.ErrFetchBlockFailed,
+}
+
+func isAny(err error, targets []error) bool {
+	for _, target := range targets {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// closeOnExit calls closeFunc and, if it fails and the
+// command has not already failed, sets the error of the
+// command (pointed to by err) so its exit code reflects
+// the failure. It is intended to be deferred with a
+// named error result.
+func closeOnExit(err *error, closeFunc func() error) {
+	closeErr := closeFunc()
+	if closeErr == nil {
+		return
+	}
+
+	if *err != nil {
+		log.Printf("%s: unable to close\n", closeErr.Error())
+		return
+	}
+
+	*err = closeErr
+}
+
+// ExitCode returns the exit code of a command that
+// returned err. Errors are classified in order of
+// severity, so a reconciliation failure caused by an
+// unreachable node is still a reconciliation failure.
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+
+	if errors.Is(err, results.ErrCheckHalted) {
+		return HaltedExitCode
+	}
+
+	if errors.Is(err, ErrInvalidConfiguration) ||
+		errors.Is(err, retry.ErrInvalidHTTPConfiguration) {
+		return ConfigurationExitCode
+	}
+
+	if errors.Is(err, results.ErrReconciliationFailure) ||
+		errors.Is(err, storage.ErrNegativeBalance) {
+		return ReconciliationExitCode
+	}
+
+	if assertionFailed, _ := asserter.Err(err); assertionFailed || isAny(err, assertionErrors) {
+		return AssertionExitCode
+	}
+
+	if storageFailed, _ := storage.Err(err); storageFailed ||
+		errors.Is(err, results.ErrStorageCorruption) {
+		return StorageExitCode
+	}
+
+	if fetcher.Err(err) || isAny(err, nodeErrors) || processor.NodeUnavailable(err) {
+		return NodeUnreachableExitCode
+	}
+
+	return FailureExitCode
+}
@@ -0,0 +1,189 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/coinbase/rosetta-cli/pkg/processor"
+	"github.com/coinbase/rosetta-cli/pkg/results"
+	"github.com/coinbase/rosetta-cli/pkg/retry"
+
+	"github.com/coinbase/rosetta-sdk-go/asserter"
+	"github.com/coinbase/rosetta-sdk-go/fetcher"
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/coinbase/rosetta-sdk-go/syncer"
+	"github.com/stretchr/testify/assert"
+)
+
+// multiError matches each of its errors (like an
+// error returned by one check that wraps the error
+// of another).
+type multiError []error
+
+func (m multiError) Error() string {
+	messages := make([]string, len(m))
+	for i, err := range m {
+		messages[i] = err.Error()
+	}
+
+	return strings.Join(messages, ": ")
+}
+
+func (m multiError) Is(target error) bool {
+	for _, err := range m {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func TestExitCode(t *testing.T) {
+	var tests = map[string]struct {
+		err error
+
+		expected int
+	}{
+		"nil": {
+			expected: 0,
+		},
+		"unclassified": {
+			err:      errors.New("unexpected error"),
+			expected: FailureExitCode,
+		},
+		"invalid configuration": {
+			err:      fmt.Errorf("%w: no profiles defined", ErrInvalidConfiguration),
+			expected: ConfigurationExitCode,
+		},
+		"invalid http configuration": {
+			err:      fmt.Errorf("%w: unable to create fetcher", retry.ErrInvalidHTTPConfiguration),
+			expected: ConfigurationExitCode,
+		},
+		"halted": {
+			err:      results.ErrCheckHalted,
+			expected: HaltedExitCode,
+		},
+		"fetcher error": {
+			err:      fmt.Errorf("%w: unable to initialize asserter", fetcher.ErrRequestFailed),
+			expected: NodeUnreachableExitCode,
+		},
+		"node unavailable": {
+			err:      processor.ErrNodeUnavailable,
+			expected: NodeUnreachableExitCode,
+		},
+		"syncer fetch failed": {
+			err:      syncer.ErrFetchBlockFailed,
+			expected: NodeUnreachableExitCode,
+		},
+		"exhausted retries": {
+			err:      errors.New(fetcher.ErrExhaustedRetries.Error()),
+			expected: NodeUnreachableExitCode,
+		},
+		"asserter error": {
+			err:      fmt.Errorf("%w: invalid block", asserter.ErrBlockIdentifierIsNil),
+			expected: AssertionExitCode,
+		},
+		"check failure": {
+			err:      fmt.Errorf("%w: block 10", results.ErrDuplicateHash),
+			expected: AssertionExitCode,
+		},
+		"reconciliation failure": {
+			err:      results.ErrReconciliationFailure,
+			expected: ReconciliationExitCode,
+		},
+		"negative balance": {
+			err:      storage.ErrNegativeBalance,
+			expected: ReconciliationExitCode,
+		},
+		"storage error": {
+			err:      fmt.Errorf("%w: error closing database", storage.ErrDBCloseFailed),
+			expected: StorageExitCode,
+		},
+		"storage corruption": {
+			err:      results.ErrStorageCorruption,
+			expected: StorageExitCode,
+		},
+		"halted before invalid configuration": {
+			err:      multiError{ErrInvalidConfiguration, results.ErrCheckHalted},
+			expected: HaltedExitCode,
+		},
+		"invalid configuration before reconciliation failure": {
+			err:      multiError{results.ErrReconciliationFailure, ErrInvalidConfiguration},
+			expected: ConfigurationExitCode,
+		},
+		"reconciliation failure before assertion failure": {
+			err:      multiError{results.ErrInvariantViolation, results.ErrReconciliationFailure},
+			expected: ReconciliationExitCode,
+		},
+		"assertion failure before storage error": {
+			err:      multiError{storage.ErrDBCloseFailed, results.ErrFeeMismatch},
+			expected: AssertionExitCode,
+		},
+		"storage error before node error": {
+			err:      multiError{fetcher.ErrRequestFailed, storage.ErrDBCloseFailed},
+			expected: StorageExitCode,
+		},
+		"node error before unclassified": {
+			err:      multiError{errors.New("unexpected error"), syncer.ErrGetNetworkStatusFailed},
+			expected: NodeUnreachableExitCode,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.expected, ExitCode(test.err))
+		})
+	}
+}
+
+func TestCloseOnExit(t *testing.T) {
+	errCommand := errors.New("command failed")
+	errClose := errors.New("close failed")
+
+	var tests = map[string]struct {
+		err      error
+		closeErr error
+
+		expected error
+	}{
+		"both succeed": {},
+		"close fails": {
+			closeErr: errClose,
+			expected: errClose,
+		},
+		"command fails": {
+			err:      errCommand,
+			expected: errCommand,
+		},
+		"both fail": {
+			err:      errCommand,
+			closeErr: errClose,
+			expected: errCommand,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := test.err
+			closeOnExit(&err, func() error { return test.closeErr })
+			assert.Equal(t, test.expected, err)
+		})
+	}
+}
@@ -0,0 +1,187 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"time"
+
+	"github.com/coinbase/rosetta-cli/configuration"
+	"github.com/coinbase/rosetta-cli/pkg/archive"
+	"github.com/coinbase/rosetta-cli/pkg/encryption"
+	"github.com/coinbase/rosetta-cli/pkg/export"
+	"github.com/coinbase/rosetta-cli/pkg/provenance"
+	"github.com/coinbase/rosetta-cli/pkg/tester"
+
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	exportBlocksCmd = &cobra.Command{
+		Use:   "export:blocks",
+		Short: "Export blocks synced by check:data for analytics",
+		Long: `This command converts the blocks stored by check:data in the
+data_directory into tables that can be loaded into analytical tools
+(like Spark or BigQuery) without re-indexing the chain.
+
+To use this command, provide the directory where the files should be
+written as the argument. Three files are written to this directory:
+blocks.csv (one row per block), transactions.csv (one row per transaction),
+and operations.csv (one row per operation). Metadata is encoded as JSON.
+The run that produced the export (CLI version, config hash, and time range)
+is written to run.json in the same directory.
+
+To write Parquet files (blocks.parquet, transactions.parquet, and
+operations.parquet) instead, run with --format parquet. Indices, counts,
+timestamps, and currency decimals are INT64 columns and all other columns
+are UTF8 columns. Empty values (like missing metadata) are null. Files are
+not compressed.
+
+Only blocks that are still in storage (i.e. have not been pruned) or
+that were archived before being pruned (see block_archive) are exported,
+so you may wish to run check:data with pruning disabled or with the
+block archive enabled.
+This command should not be run while check:data is running.`,
+		RunE: runExportBlocksCmd,
+		Args: cobra.ExactArgs(1),
+	}
+
+	// ExportFormat is the format of the files written by export:blocks.
+	ExportFormat string
+
+	// ExportStartIndex is the first block index exported
+	// by export:blocks.
+	ExportStartIndex int64
+
+	// ExportEndIndex is the last block index exported
+	// by export:blocks.
+	ExportEndIndex int64
+)
+
+// openDataDatabase opens the database populated by
+// check:data in the configured data_directory. The caller
+// must close the database when it is no longer needed.
+func openDataDatabase() (storage.Database, error) {
+	if len(Config.DataDirectory) == 0 {
+		return nil, errors.New("data_directory must be populated")
+	}
+
+	return openDatabase(tester.DataPath(Config.DataDirectory, Config.Network), "check:data")
+}
+
+// openDatabase opens the existing database at dbPath
+// (populated by the command name).
+func openDatabase(dbPath string, name string) (storage.Database, error) {
+	if _, err := os.Stat(dbPath); err != nil {
+		return nil, fmt.Errorf("%w: unable to find %s database", err, name)
+	}
+
+	key, err := encryption.LoadKey(Config.Encryption)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to load encryption key", err)
+	}
+
+	opts := []storage.BadgerOption{
+		storage.WithCustomSettings(encryption.BadgerOptions(dbPath, false, key)),
+	}
+	if Config.CompressionDisabled {
+		opts = append(opts, storage.WithoutCompression())
+	}
+
+	localStore, err := storage.NewBadgerStorage(Context, dbPath, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to open database", err)
+	}
+
+	return localStore, nil
+}
+
+// openBlockArchive opens the block archive populated by
+// check:data in the configured data_directory. If no blocks
+// have been archived, nil is returned.
+func openBlockArchive() (*archive.Archive, error) {
+	archivePath := tester.BlockArchivePath(Config.DataDirectory, Config.Network)
+	if _, err := os.Stat(archivePath); os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	segmentSize := int64(configuration.DefaultBlockArchiveSegmentSize)
+	if Config.Data.BlockArchive != nil {
+		segmentSize = Config.Data.BlockArchive.SegmentSize
+	}
+
+	blockArchive, err := archive.Open(archivePath, segmentSize)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to open block archive", err)
+	}
+
+	return blockArchive, nil
+}
+
+// closeDatabase closes db and logs any error.
+func closeDatabase(db storage.Database) {
+	if err := db.Close(Context); err != nil {
+		log.Printf("%s: unable to close database\n", err.Error())
+	}
+}
+
+func runExportBlocksCmd(cmd *cobra.Command, args []string) error {
+	run := provenance.New("export:blocks", Config, nil, time.Now())
+
+	localStore, err := openDataDatabase()
+	if err != nil {
+		return fmt.Errorf("%w: unable to export blocks", err)
+	}
+	defer closeDatabase(localStore)
+
+	blockArchive, err := openBlockArchive()
+	if err != nil {
+		return fmt.Errorf("%w: unable to export blocks", err)
+	}
+	if blockArchive != nil {
+		defer blockArchive.Close()
+	}
+
+	exported, err := export.ExportBlocks(
+		Context,
+		storage.NewBlockStorage(localStore),
+		blockArchive,
+		args[0],
+		ExportFormat,
+		ExportStartIndex,
+		ExportEndIndex,
+	)
+	if err != nil {
+		return fmt.Errorf("%w: unable to export blocks", err)
+	}
+
+	run.Finish(time.Now(), nil)
+	if err := run.Write(path.Join(args[0], provenance.RunFile)); err != nil {
+		return fmt.Errorf("%w: unable to export blocks", err)
+	}
+
+	if err := run.Save(Config.DataDirectory); err != nil {
+		return fmt.Errorf("%w: unable to export blocks", err)
+	}
+
+	color.Green("Exported %d blocks to %s", exported, args[0])
+	return nil
+}
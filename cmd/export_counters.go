@@ -0,0 +1,81 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path"
+
+	"github.com/coinbase/rosetta-cli/pkg/timeseries"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	exportCountersCmd = &cobra.Command{
+		Use:   "export:counters",
+		Short: "Export counter samples recorded by check:data for plotting",
+		Long: `When counter_sample_interval is populated in the data configuration,
+check:data periodically stores a sample of its counters (blocks, orphans,
+transactions, operations, and reconciliations) in the data_directory.
+This command writes all samples (oldest first) to the file provided as the
+argument so that throughput can be plotted over the course of a run.
+
+Each sample includes the value of every counter and its rate (per second)
+since the previous sample. With --format csv (the default), each sample is
+a row with a column for each value and rate. With --format json, samples
+are written as a JSON array.
+
+This command should not be run while check:data is running.`,
+		RunE: runExportCountersCmd,
+		Args: cobra.ExactArgs(1),
+	}
+
+	// ExportCountersFormat is the format of the
+	// file written by export:counters.
+	ExportCountersFormat string
+)
+
+func runExportCountersCmd(cmd *cobra.Command, args []string) error {
+	localStore, err := openDataDatabase()
+	if err != nil {
+		return fmt.Errorf("%w: unable to export counters", err)
+	}
+	defer closeDatabase(localStore)
+
+	samples, err := timeseries.NewStorage(localStore).GetAll(Context)
+	if err != nil {
+		return fmt.Errorf("%w: unable to get counter samples", err)
+	}
+
+	file, err := os.Create(path.Clean(args[0]))
+	if err != nil {
+		return fmt.Errorf("%w: unable to create %s", err, args[0])
+	}
+
+	if err := timeseries.Write(file, timeseries.Points(samples), ExportCountersFormat); err != nil {
+		_ = file.Close()
+		return fmt.Errorf("%w: unable to export counters", err)
+	}
+
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("%w: unable to close %s", err, args[0])
+	}
+
+	color.Green("Exported %d counter samples to %s", len(samples), args[0])
+	return nil
+}
@@ -0,0 +1,63 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/coinbase/rosetta-cli/pkg/inspect"
+	"github.com/coinbase/rosetta-cli/pkg/tester"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	inspectCmd = &cobra.Command{
+		Use:   "inspect",
+		Short: "Interactively query data stored by check:data",
+		Long: `After a check:data run fails, it is often useful to look at
+the data it stored. This command opens the data stored by check:data in the
+data_directory and reads queries from stdin (one per line). Run help
+to see all supported queries:
+
+  block <index or hash>             print a stored block
+  balance <account> [block index]   print the computed balances of an account
+  orphans [limit]                   print the most recently orphaned blocks
+  counters                          print all counters
+
+The account can be provided as an address or as a JSON representation of
+a types.AccountIdentifier. No data is written to storage and no requests
+are made to the node. This command should not be run while check:data
+is running.`,
+		RunE: runInspectCmd,
+	}
+)
+
+func runInspectCmd(cmd *cobra.Command, args []string) error {
+	localStore, err := openDataDatabase()
+	if err != nil {
+		return fmt.Errorf("%w: unable to inspect", err)
+	}
+	defer closeDatabase(localStore)
+
+	explorer := inspect.New(
+		localStore,
+		tester.DataPath(Config.DataDirectory, Config.Network),
+	)
+
+	fmt.Println("Run help to see all queries.")
+	return explorer.Run(Context, os.Stdin, os.Stdout)
+}
@@ -0,0 +1,148 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/coinbase/rosetta-cli/pkg/mock"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	mockCmd = &cobra.Command{
+		Use:   "mock",
+		Short: "Serve a synthetic blockchain over the Rosetta Data and Construction APIs",
+		Long: `This command serves a small in-memory blockchain for the network in
+the configuration file over the Rosetta Data and Construction APIs. It
+is intended for demos and for testing the rosetta-cli (or other clients)
+without running a node.
+
+A block is added every --block-time seconds. Each block credits a reward
+to one account, transfers a small amount between two accounts, and includes
+all submitted transactions that are still affordable. Every --reorg-frequency
+blocks, the last --reorg-depth blocks are orphaned and replaced.
+
+All accounts are funded in the genesis block and their private keys (derived
+from --seed) are printed on startup so they can be used as prefunded_accounts
+in a construction configuration. Signatures submitted to /construction/combine
+are not cryptographically verified.`,
+		RunE: runMockCmd,
+	}
+
+	// MockAddr is the address the mock
+	// command listens on.
+	MockAddr string
+
+	// MockBlockTime is the number of seconds
+	// between blocks.
+	MockBlockTime uint64
+
+	// MockAccounts is the number of accounts
+	// funded in the genesis block.
+	MockAccounts int
+
+	// MockSeed is the seed the private keys
+	// of accounts are derived from.
+	MockSeed string
+
+	// MockReorgFrequency is the number of blocks
+	// between reorgs (0 disables reorgs).
+	MockReorgFrequency int64
+
+	// MockReorgDepth is the number of blocks
+	// orphaned by each reorg.
+	MockReorgDepth int64
+)
+
+func runMockCmd(cmd *cobra.Command, args []string) error {
+	if MockBlockTime == 0 {
+		return errors.New("--block-time must be positive")
+	}
+
+	if MockAccounts <= 0 {
+		return errors.New("--accounts must be positive")
+	}
+
+	chain, err := mock.NewChain(&mock.Config{
+		Network:        Config.Network,
+		Accounts:       MockAccounts,
+		Seed:           MockSeed,
+		ReorgFrequency: MockReorgFrequency,
+		ReorgDepth:     MockReorgDepth,
+	}, time.Now())
+	if err != nil {
+		return fmt.Errorf("%w: unable to create mock chain", err)
+	}
+
+	for _, account := range chain.Accounts() {
+		log.Printf(
+			"account %s (private key %s)\n",
+			account.Identifier.Address,
+			hex.EncodeToString(account.KeyPair.PrivateKey),
+		)
+	}
+
+	server := &http.Server{
+		Addr:    MockAddr,
+		Handler: mock.NewServer(Config.Network, chain).Handler(),
+	}
+
+	ctx, cancel := context.WithCancel(Context)
+	defer cancel()
+
+	sigListeners := []context.CancelFunc{cancel}
+	go handleSignals(&sigListeners)
+
+	go func() {
+		<-ctx.Done()
+		_ = server.Shutdown(context.Background())
+	}()
+
+	go func() {
+		ticker := time.NewTicker(time.Duration(MockBlockTime) * time.Second)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				orphaned, added := chain.Advance(now)
+				if len(orphaned) > 0 {
+					color.Yellow("reorg orphaned %d blocks\n", len(orphaned))
+				}
+
+				head := added[len(added)-1].BlockIdentifier
+				log.Printf("added block %d (%s)\n", head.Index, head.Hash)
+			}
+		}
+	}()
+
+	log.Printf("serving mock %s on %s\n", Config.Network.Network, MockAddr)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("%w: unable to serve", err)
+	}
+
+	return nil
+}
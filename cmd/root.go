@@ -18,19 +18,37 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"net"
+	"net/http"
+	httppprof "net/http/pprof"
 	"os"
 	"os/signal"
 	"runtime"
 	"runtime/pprof"
+	"strings"
 	"syscall"
 
 	"github.com/coinbase/rosetta-cli/configuration"
-
+	"github.com/coinbase/rosetta-cli/pkg/chaos"
+	"github.com/coinbase/rosetta-cli/pkg/compact"
+	"github.com/coinbase/rosetta-cli/pkg/export"
+	"github.com/coinbase/rosetta-cli/pkg/keyfile"
+	"github.com/coinbase/rosetta-cli/pkg/logger"
+	"github.com/coinbase/rosetta-cli/pkg/provenance"
+	"github.com/coinbase/rosetta-cli/pkg/timeseries"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
 	"github.com/coinbase/rosetta-sdk-go/utils"
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 )
 
+const (
+	// logModuleParts is the number of parts of a
+	// --log-module override (module=level).
+	logModuleParts = 2
+)
+
 var (
 	rootCmd = &cobra.Command{
 		Use:               "rosetta-cli",
@@ -42,6 +60,14 @@ var (
 	cpuProfile        string
 	memProfile        string
 	blockProfile      string
+	pprofAddr         string
+	logLevel          string
+	logModules        []string
+
+	// Profile is the name of the profile in the configurationFile
+	// to apply (or configuration.AllProfiles to run check:data
+	// for each profile).
+	Profile string
 
 	// Config is the populated *configuration.Configuration from
 	// the configurationFile. If none is provided, this is set
@@ -63,6 +89,11 @@ var (
 	// cleanup a running block profile.
 	blockProfileCleanup func()
 
+	// StatusAddr is the address used to serve the status
+	// server of check:data and check:construction. If not
+	// populated, the status_port in the configuration is used.
+	StatusAddr string
+
 	// OnlyChanges is a boolean indicating if only the balance changes should be
 	// logged to the console.
 	OnlyChanges bool
@@ -72,7 +103,15 @@ var (
 // profiling.
 //
 // Bassed on https://golang.org/pkg/runtime/pprof/#hdr-Profiling_a_Go_program
-func rootPreRun(*cobra.Command, []string) error {
+func rootPreRun(cmd *cobra.Command, args []string) error {
+	if Profile == configuration.AllProfiles && cmd.Name() != checkDataCmdName {
+		return fmt.Errorf(
+			"%w: --profile %s is only supported by check:data",
+			ErrInvalidConfiguration,
+			configuration.AllProfiles,
+		)
+	}
+
 	if cpuProfile != "" {
 		f, err := os.Create(cpuProfile)
 		if err != nil {
@@ -111,6 +150,37 @@ func rootPreRun(*cobra.Command, []string) error {
 		}
 	}
 
+	if pprofAddr != "" {
+		if err := startPprofServer(pprofAddr); err != nil {
+			return fmt.Errorf("%w: unable to start pprof server", err)
+		}
+	}
+
+	return nil
+}
+
+// startPprofServer serves the net/http/pprof profiles at addr
+// for the lifetime of the process. Profiles are served on a
+// dedicated mux so they are never exposed by other servers.
+func startPprofServer(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", httppprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", httppprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", httppprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", httppprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", httppprof.Trace)
+
+	server := &http.Server{Handler: mux}
+	go func() {
+		log.Printf("pprof server running on %s\n", listener.Addr().String())
+		_ = server.Serve(listener)
+	}()
+
 	return nil
 }
 
@@ -166,6 +236,13 @@ with the defaults), run rosetta-cli configuration:create.
 
 Any fields not populated in the configuration file will be populated with
 default values.`,
+	)
+	rootFlags.StringVar(
+		&Profile,
+		"profile",
+		"",
+		`Name of the profile in the configuration file to apply (or all to run
+check:data for each profile sequentially)`,
 	)
 	rootFlags.StringVar(
 		&cpuProfile,
@@ -185,6 +262,27 @@ default values.`,
 		"",
 		`Save the pprof block profile in the specified file`,
 	)
+	rootFlags.StringVar(
+		&pprofAddr,
+		"pprof-addr",
+		"",
+		`Serve net/http/pprof profiles (heap, CPU, goroutine, etc.) at the
+specified address (like localhost:6060) while the command runs`,
+	)
+	rootFlags.StringVar(
+		&logLevel,
+		"log-level",
+		"",
+		`Minimum level of logged messages (debug, info, warn, or error)
+(overrides the level in the logging configuration)`,
+	)
+	rootFlags.StringSliceVar(
+		&logModules,
+		"log-module",
+		[]string{},
+		`Minimum level of messages logged by a module (like reconciler=debug)
+(overrides the level of the module in the logging configuration)`,
+	)
 	rootCmd.AddCommand(versionCmd)
 
 	// Configuration Commands
@@ -192,8 +290,99 @@ default values.`,
 	rootCmd.AddCommand(configurationValidateCmd)
 
 	// Check commands
+	for _, checkCmd := range []*cobra.Command{checkDataCmd, checkConstructionCmd} {
+		checkCmd.Flags().StringVar(
+			&StatusAddr,
+			"status-addr",
+			"",
+			`Address (i.e. host:port) to serve /healthz, /readyz, /status, and
+the /dashboard web UI on. If not populated, the status_port in the
+configuration file is used.`,
+		)
+	}
+	checkDataCmd.Flags().BoolVar(
+		&CheckDataQuick,
+		"quick",
+		false,
+		`Only sync blocks and check their structure (balance tracking, coin
+tracking, and reconciliation are disabled). This is equivalent to setting
+quick_mode to true in the configuration file.`,
+	)
+	checkDataCmd.Flags().BoolVar(
+		&CheckDataHaltOnError,
+		"halt-on-error",
+		false,
+		`Instead of exiting when the check fails, print the failure and read
+queries about the stored data (like the inspect command) from stdin while the
+status server keeps running. Run continue to resume the usual failure
+handling or abort to exit immediately.`,
+	)
 	rootCmd.AddCommand(checkDataCmd)
+	checkConstructionCmd.Flags().StringVar(
+		&CheckConstructionSeed,
+		"seed",
+		"",
+		`Derive all key pairs generated during the run from this seed
+(overrides the seed in the construction configuration)`,
+	)
 	rootCmd.AddCommand(checkConstructionCmd)
+	rootCmd.AddCommand(checkConstructionReplayCmd)
+
+	checkOfflineCmd.Flags().StringVar(
+		&OfflineProxyAddr,
+		"proxy-addr",
+		"127.0.0.1:3128",
+		`Address (i.e. host:port) of the proxy blocking outbound connections`,
+	)
+	checkOfflineCmd.Flags().Uint64Var(
+		&OfflineStartupTimeout,
+		"startup-timeout",
+		30,
+		`Number of seconds to wait for a launched implementation to accept connections`,
+	)
+	rootCmd.AddCommand(checkOfflineCmd)
+
+	checkSpotCmd.Flags().IntVar(
+		&SpotCheckSamples,
+		"samples",
+		10,
+		`Number of blocks to sample`,
+	)
+	checkSpotCmd.Flags().IntVar(
+		&SpotCheckAccounts,
+		"accounts",
+		5,
+		`Maximum number of accounts to check in each sampled block`,
+	)
+	checkSpotCmd.Flags().IntVar(
+		&SpotCheckFromTip,
+		"from-tip",
+		0,
+		`Number of blocks to check walking back from the current block (instead
+of sampling blocks)`,
+	)
+	rootCmd.AddCommand(checkSpotCmd)
+
+	compareNetworksCmd.Flags().Int64Var(
+		&CompareStartIndex,
+		"start-index",
+		-1,
+		`Index of the first block to compare (defaults to the genesis block)`,
+	)
+	compareNetworksCmd.Flags().Int64Var(
+		&CompareEndIndex,
+		"end-index",
+		-1,
+		`Index of the last block to compare (defaults to the lowest current block of both implementations)`,
+	)
+	compareNetworksCmd.Flags().BoolVar(
+		&CompareBalances,
+		"balances",
+		false,
+		`Compare the balances of accounts whose balances change in each block`,
+	)
+	rootCmd.AddCommand(compareNetworksCmd)
+	rootCmd.AddCommand(constructionReturnFundsCmd)
 
 	// View Commands
 	viewBlockCmd.Flags().BoolVar(
@@ -203,43 +392,360 @@ default values.`,
 		`Only print balance changes for accounts in the block`,
 	)
 	rootCmd.AddCommand(viewBlockCmd)
+	viewAccountCmd.Flags().StringVar(
+		&ViewBalanceBlock,
+		"block",
+		"",
+		`Index or hash of the block to perform the query at (defaults to the current block)`,
+	)
 	rootCmd.AddCommand(viewAccountCmd)
+	viewNetworksCmd.Flags().StringVar(
+		&SaveOptionsFile,
+		"save-options",
+		"",
+		`Store the /network/options response of each network in the specified file`,
+	)
+	viewNetworksCmd.Flags().StringVar(
+		&CompareOptionsFile,
+		"compare-options",
+		"",
+		`Diff /network/options against a file previously stored with --save-options`,
+	)
 	rootCmd.AddCommand(viewNetworksCmd)
 
+	viewFailuresCmd.Flags().StringVar(
+		&ViewFailuresFormat,
+		"format",
+		tableFormat,
+		`Format of the printed failures (table or json)`,
+	)
+	rootCmd.AddCommand(viewFailuresCmd)
+
+	viewCheckpointBalancesCmd.Flags().StringVar(
+		&ViewCheckpointBalancesFormat,
+		"format",
+		tableFormat,
+		`Format of the printed balances (table or json)`,
+	)
+	rootCmd.AddCommand(viewCheckpointBalancesCmd)
+
+	viewStatsCmd.Flags().StringVar(
+		&ViewStatsFormat,
+		"format",
+		tableFormat,
+		`Format of the printed statistics (table or json)`,
+	)
+	rootCmd.AddCommand(viewStatsCmd)
+
+	viewRunsCmd.Flags().StringVar(
+		&ViewRunsFormat,
+		"format",
+		tableFormat,
+		`Format of the printed runs (table or json)`,
+	)
+	rootCmd.AddCommand(viewRunsCmd)
+
+	viewAccountHistoryCmd.Flags().StringVar(
+		&AccountHistoryFormat,
+		"format",
+		tableFormat,
+		`Format of the printed operations (table or json)`,
+	)
+	viewAccountHistoryCmd.Flags().Int64Var(
+		&AccountHistoryStartIndex,
+		"start-index",
+		-1,
+		`Index of the first block to scan (defaults to the oldest block in storage)`,
+	)
+	viewAccountHistoryCmd.Flags().Int64Var(
+		&AccountHistoryEndIndex,
+		"end-index",
+		-1,
+		`Index of the last block to scan (defaults to the head block in storage)`,
+	)
+	rootCmd.AddCommand(viewAccountHistoryCmd)
+
+	// Export Commands
+	exportBlocksCmd.Flags().StringVar(
+		&ExportFormat,
+		"format",
+		export.CSVFormat,
+		`Format of the exported files (csv or parquet)`,
+	)
+	exportBlocksCmd.Flags().Int64Var(
+		&ExportStartIndex,
+		"start-index",
+		-1,
+		`Index of the first block to export (defaults to the oldest block in storage)`,
+	)
+	exportBlocksCmd.Flags().Int64Var(
+		&ExportEndIndex,
+		"end-index",
+		-1,
+		`Index of the last block to export (defaults to the head block in storage)`,
+	)
+	rootCmd.AddCommand(exportBlocksCmd)
+
+	exportCountersCmd.Flags().StringVar(
+		&ExportCountersFormat,
+		"format",
+		timeseries.CSVFormat,
+		`Format of the exported file (csv or json)`,
+	)
+	rootCmd.AddCommand(exportCountersCmd)
+
+	// Serve Commands
+	serveCmd.Flags().StringVar(
+		&ServeAddr,
+		"addr",
+		":8081",
+		`Address (i.e. host:port) to serve the Rosetta Data API on`,
+	)
+	rootCmd.AddCommand(serveCmd)
+
+	// Mock Commands
+	mockCmd.Flags().StringVar(
+		&MockAddr,
+		"addr",
+		":8080",
+		`Address (i.e. host:port) to serve the mock Rosetta APIs on`,
+	)
+	mockCmd.Flags().Uint64Var(
+		&MockBlockTime,
+		"block-time",
+		1,
+		`Number of seconds between blocks`,
+	)
+	mockCmd.Flags().IntVar(
+		&MockAccounts,
+		"accounts",
+		5,
+		`Number of accounts funded in the genesis block`,
+	)
+	mockCmd.Flags().StringVar(
+		&MockSeed,
+		"seed",
+		"mock",
+		`Seed the private keys of funded accounts are derived from`,
+	)
+	mockCmd.Flags().Int64Var(
+		&MockReorgFrequency,
+		"reorg-frequency",
+		0,
+		`Number of blocks between reorgs (0 disables reorgs)`,
+	)
+	mockCmd.Flags().Int64Var(
+		&MockReorgDepth,
+		"reorg-depth",
+		1,
+		`Number of blocks orphaned by each reorg`,
+	)
+	rootCmd.AddCommand(mockCmd)
+
+	// Inspect Commands
+	rootCmd.AddCommand(inspectCmd)
+
 	// Utils
 	rootCmd.AddCommand(utilsAsserterConfigurationCmd)
+	rootCmd.AddCommand(utilsSelfTestCmd)
 	rootCmd.AddCommand(utilsTrainZstdCmd)
+
+	utilsDBVerifyCmd.Flags().Int64Var(
+		&DBVerifyBalanceSamples,
+		"balance-samples",
+		100,
+		`Number of randomly selected blocks where balance changes are re-derived`,
+	)
+	rootCmd.AddCommand(utilsDBVerifyCmd)
+
+	utilsDBCompactCmd.Flags().Float64Var(
+		&DBCompactDiscardRatio,
+		"discard-ratio",
+		compact.DefaultDiscardRatio,
+		`Fraction of a value log file that must be discarded for it to be rewritten (0-1)`,
+	)
+	rootCmd.AddCommand(utilsDBCompactCmd)
+
+	utilsDBRotateKeyCmd.Flags().StringVar(
+		&RotateKeyFile,
+		"new-key-file",
+		"",
+		`Path to a file containing the new hex-encoded key`,
+	)
+	utilsDBRotateKeyCmd.Flags().StringVar(
+		&RotateKeyEnv,
+		"new-key-env",
+		"",
+		`Name of the environment variable containing the new hex-encoded key`,
+	)
+	rootCmd.AddCommand(utilsDBRotateKeyCmd)
+
+	for _, keysCmd := range []*cobra.Command{utilsKeysExportCmd, utilsKeysImportCmd} {
+		keysCmd.Flags().StringVar(
+			&KeysFormat,
+			"format",
+			keyfile.HexFormat,
+			`Format of the keys (hex, wif, or keystore)`,
+		)
+		keysCmd.Flags().StringVar(
+			&KeysPassphrase,
+			"passphrase",
+			"",
+			`Passphrase used to encrypt or decrypt keystores`,
+		)
+	}
+	utilsKeysExportCmd.Flags().BoolVar(
+		&KeysWIFMainnet,
+		"wif-mainnet",
+		false,
+		`Encode WIF keys with the Bitcoin mainnet version byte (instead of testnet)`,
+	)
+	rootCmd.AddCommand(utilsKeysExportCmd)
+
+	utilsKeysImportCmd.Flags().StringVar(
+		&KeysAddress,
+		"address",
+		"",
+		`Address of the account of an imported keystore`,
+	)
+	utilsKeysImportCmd.Flags().StringVar(
+		&KeysCurveType,
+		"curve-type",
+		string(types.Secp256k1),
+		`Curve type of the key in an imported keystore`,
+	)
+	rootCmd.AddCommand(utilsKeysImportCmd)
 }
 
 func initConfig() {
 	Context = context.Background()
 	var err error
-	if len(configurationFile) == 0 {
+	switch {
+	case len(configurationFile) == 0 && len(Profile) > 0:
+		err = fmt.Errorf("--profile %s requires a configuration file", Profile)
+	case len(configurationFile) == 0:
 		Config = configuration.DefaultConfiguration()
-	} else {
-		Config, err = configuration.LoadConfiguration(Context, configurationFile)
+		err = configuration.ApplyEnvironmentOverrides(Config)
+	default:
+		Config, err = configuration.LoadProfile(Context, configurationFile, Profile)
 	}
 	if err != nil {
-		log.Fatalf("%s: unable to load configuration", err.Error())
+		log.Printf("%s: unable to load configuration\n", err.Error())
+		os.Exit(ConfigurationExitCode)
+	}
+
+	if err := configureLogging(Config); err != nil {
+		log.Printf("%s: unable to configure logging\n", err.Error())
+		os.Exit(ConfigurationExitCode)
 	}
 }
 
-func ensureDataDirectoryExists() {
+// configureLogging applies the --log-level and --log-module
+// flags to the logging configuration of config and sets the
+// minimum level of logged messages.
+func configureLogging(config *configuration.Configuration) error {
+	if len(logLevel) == 0 && len(logModules) == 0 {
+		logger.ConfigureLevels(config.Logging)
+		return nil
+	}
+
+	if config.Logging == nil {
+		config.Logging = &configuration.LoggingConfiguration{}
+	}
+
+	if len(logLevel) > 0 {
+		config.Logging.Level = configuration.LogLevel(logLevel)
+	}
+
+	if config.Logging.Modules == nil {
+		config.Logging.Modules = map[configuration.LogModule]configuration.LogLevel{}
+	}
+
+	for _, override := range logModules {
+		parts := strings.SplitN(override, "=", logModuleParts)
+		if len(parts) != logModuleParts {
+			return fmt.Errorf("log module %s must be formatted as module=level", override)
+		}
+
+		config.Logging.Modules[configuration.LogModule(parts[0])] = configuration.LogLevel(parts[1])
+	}
+
+	if err := configuration.AssertLogging(config.Logging); err != nil {
+		return err
+	}
+
+	logger.ConfigureLevels(config.Logging)
+	return nil
+}
+
+// statusAddr returns the address to serve a status
+// server on, preferring StatusAddr over port.
+func statusAddr(port uint) string {
+	if len(StatusAddr) > 0 {
+		return StatusAddr
+	}
+
+	return fmt.Sprintf(":%d", port)
+}
+
+// newChaosInjector returns a *chaos.Injector and the function to
+// wrap the transport of the online fetcher with if faults should be
+// injected into requests to the online_url of config (otherwise
+// both are nil).
+func newChaosInjector(
+	config *configuration.Configuration,
+) (*chaos.Injector, func(http.RoundTripper) http.RoundTripper) {
+	if config.Chaos == nil {
+		return nil, nil
+	}
+
+	color.Yellow("chaos mode: injecting faults into requests to %s", config.OnlineURL)
+	injector := chaos.New(config.Chaos)
+	return injector, injector.Wrap
+}
+
+// reportChaos prints (and saves, if configured in config) a
+// report of all faults injected by injector (if it is not nil).
+func reportChaos(injector *chaos.Injector, config *configuration.Configuration) {
+	if injector == nil {
+		return
+	}
+
+	report := injector.Report()
+	report.Print()
+
+	if len(config.Chaos.ReportFile) == 0 {
+		return
+	}
+
+	if err := report.Save(config.Chaos.ReportFile); err != nil {
+		log.Printf("%s\n", err.Error())
+		return
+	}
+
+	color.Green("Chaos report saved to %s", config.Chaos.ReportFile)
+}
+
+func ensureDataDirectoryExists(config *configuration.Configuration) error {
 	// If data directory is not specified, we use a temporary directory
 	// and delete its contents when execution is complete.
-	if len(Config.DataDirectory) == 0 {
+	if len(config.DataDirectory) == 0 {
 		tmpDir, err := utils.CreateTempDir()
 		if err != nil {
-			log.Fatalf("%s: unable to create temporary directory", err.Error())
+			return fmt.Errorf("%w: unable to create temporary directory", err)
 		}
 
-		Config.DataDirectory = tmpDir
+		config.DataDirectory = tmpDir
 	}
+
+	return nil
 }
 
 // handleSignals handles OS signals so we can ensure we close database
 // correctly. We call multiple sigListeners because we
-// may need to cancel more than 1 context.
+// may need to cancel more than 1 context. sigListeners are called
+// in order, so any listener that halts syncing should be provided
+// before any context is canceled.
 func handleSignals(listeners *[]context.CancelFunc) {
 	sigs := make(chan os.Signal, 1)
 	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
@@ -257,6 +763,6 @@ var versionCmd = &cobra.Command{
 	Use:   "version",
 	Short: "Print rosetta-cli version",
 	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Println("v0.6.0")
+		fmt.Println(provenance.CLIVersion)
 	},
 }
@@ -0,0 +1,86 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/coinbase/rosetta-cli/pkg/serve"
+
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/spf13/cobra"
+)
+
+var (
+	serveCmd = &cobra.Command{
+		Use:   "serve",
+		Short: "Serve blocks and balances synced by check:data as a Rosetta Data API",
+		Long: `This command serves /network/list, /network/status, /block, and
+/account/balance directly out of the blocks and balances stored by check:data
+in the data_directory (no requests are made to the node). This allows the
+rosetta-cli to be used as an offline fixture server when testing clients of
+a Rosetta implementation.
+
+The head block in storage is served as the current block. Only blocks and
+historical balances that have not been pruned can be served, so you may wish
+to run check:data with pruning disabled. This command should not be run while
+check:data is running.`,
+		RunE: runServeCmd,
+	}
+
+	// ServeAddr is the address the serve
+	// command listens on.
+	ServeAddr string
+)
+
+func runServeCmd(cmd *cobra.Command, args []string) error {
+	localStore, err := openDataDatabase()
+	if err != nil {
+		return fmt.Errorf("%w: unable to serve", err)
+	}
+	defer closeDatabase(localStore)
+
+	server := &http.Server{
+		Addr: ServeAddr,
+		Handler: serve.New(
+			Config.Network,
+			localStore,
+			storage.NewBlockStorage(localStore),
+			storage.NewBalanceStorage(localStore),
+		).Handler(),
+	}
+
+	ctx, cancel := context.WithCancel(Context)
+	defer cancel()
+
+	sigListeners := []context.CancelFunc{cancel}
+	go handleSignals(&sigListeners)
+
+	go func() {
+		<-ctx.Done()
+		_ = server.Shutdown(context.Background())
+	}()
+
+	log.Printf("serving %s on %s\n", Config.Network.Network, ServeAddr)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("%w: unable to serve", err)
+	}
+
+	return nil
+}
@@ -16,9 +16,9 @@ package cmd
 
 import (
 	"fmt"
-	"time"
 
-	"github.com/coinbase/rosetta-sdk-go/fetcher"
+	"github.com/coinbase/rosetta-cli/pkg/retry"
+
 	"github.com/coinbase/rosetta-sdk-go/utils"
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
@@ -43,12 +43,10 @@ the configuration file should be saved (in JSON).`,
 
 func runCreateConfigurationCmd(cmd *cobra.Command, args []string) error {
 	// Create a new fetcher
-	newFetcher := fetcher.New(
-		Config.OnlineURL,
-		fetcher.WithRetryElapsedTime(time.Duration(Config.RetryElapsedTime)*time.Second),
-		fetcher.WithTimeout(time.Duration(Config.HTTPTimeout)*time.Second),
-		fetcher.WithMaxRetries(Config.MaxRetries),
-	)
+	newFetcher, err := retry.NewFetcher(Config, Config.OnlineURL)
+	if err != nil {
+		return fmt.Errorf("%w: unable to create fetcher", err)
+	}
 
 	// Initialize the fetcher's asserter
 	_, _, fetchErr := newFetcher.InitializeAsserter(Context, Config.Network)
@@ -0,0 +1,95 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/coinbase/rosetta-cli/pkg/compact"
+	"github.com/coinbase/rosetta-cli/pkg/encryption"
+	"github.com/coinbase/rosetta-cli/pkg/tester"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	utilsDBCompactCmd = &cobra.Command{
+		Use:   "utils:db:compact",
+		Short: "Reclaim disk space used by data stored by check:data",
+		Long: `During long runs, the data_directory of check:data can grow to
+several times the size of the data it stores because the database only
+reclaims the space of deleted and overwritten values in the background
+(and rarely). This command compacts the database of check:data and
+garbage collects its value log:
+
+  1. All levels of the LSM tree are compacted into one (which drops
+     deleted and overwritten keys).
+  2. Value log files are rewritten until no file has more than
+     --discard-ratio of its values discarded.
+
+Progress is logged as each step completes. Once done, the size of the
+LSM tree, the value log, and all files in the database before and after
+compaction are printed.
+
+The database is opened with the same options as check:data (including
+memory_limit_disabled and encryption). This command should not be run
+while check:data is running.`,
+		RunE: runUtilsDBCompactCmd,
+	}
+
+	// DBCompactDiscardRatio is the fraction of a value log
+	// file that must be discarded for utils:db:compact to
+	// rewrite it.
+	DBCompactDiscardRatio float64
+)
+
+func runUtilsDBCompactCmd(cmd *cobra.Command, args []string) error {
+	if len(Config.DataDirectory) == 0 {
+		return errors.New("data_directory must be populated")
+	}
+
+	dbPath := tester.DataPath(Config.DataDirectory, Config.Network)
+	if _, err := os.Stat(dbPath); err != nil {
+		return fmt.Errorf("%w: unable to find check:data database", err)
+	}
+
+	key, err := encryption.LoadKey(Config.Encryption)
+	if err != nil {
+		return fmt.Errorf("%w: unable to load encryption key", err)
+	}
+
+	results, err := compact.Run(
+		Context,
+		dbPath,
+		DBCompactDiscardRatio,
+		Config.MemoryLimitDisabled,
+		key,
+	)
+	if err != nil {
+		return fmt.Errorf("%w: unable to compact database", err)
+	}
+
+	results.Print()
+	color.Green(
+		"Success: reclaimed %s (rewrote %d value log files)",
+		compact.FormatMB(results.Before.Total-results.After.Total),
+		results.RewrittenValueLogs,
+	)
+
+	return nil
+}
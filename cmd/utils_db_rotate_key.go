@@ -0,0 +1,114 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/coinbase/rosetta-cli/pkg/encryption"
+	"github.com/coinbase/rosetta-cli/pkg/tester"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	utilsDBRotateKeyCmd = &cobra.Command{
+		Use:   "utils:db:rotate-key",
+		Short: "Re-encrypt the databases in the data_directory with a new key",
+		Long: `When encryption is configured, the databases of check:data and
+check:construction in the data_directory (including the private keys
+stored by check:construction) are encrypted at rest. This command
+re-encrypts both databases (if they exist) with the key read from
+--new-key-file or --new-key-env (the current key is read from the
+encryption configuration).
+
+Data is encrypted with data keys that are stored in a key registry
+encrypted with the configured key, so only the key registry is rewritten
+and this command completes quickly regardless of the size of the
+databases. Once it succeeds, update the encryption configuration to
+provide the new key.
+
+This command should not be run while check:data or check:construction
+is running.`,
+		RunE: runUtilsDBRotateKeyCmd,
+	}
+
+	// RotateKeyFile is the path to a file containing the
+	// new key used by utils:db:rotate-key.
+	RotateKeyFile string
+
+	// RotateKeyEnv is the name of the environment variable
+	// containing the new key used by utils:db:rotate-key.
+	RotateKeyEnv string
+)
+
+func runUtilsDBRotateKeyCmd(cmd *cobra.Command, args []string) error {
+	if len(Config.DataDirectory) == 0 {
+		return errors.New("data_directory must be populated")
+	}
+
+	if Config.Encryption == nil {
+		return fmt.Errorf("%w: encryption must be configured", ErrInvalidConfiguration)
+	}
+
+	if len(RotateKeyFile) > 0 && len(RotateKeyEnv) > 0 {
+		return errors.New("only one of --new-key-file and --new-key-env can be provided")
+	}
+
+	oldKey, err := encryption.LoadKey(Config.Encryption)
+	if err != nil {
+		return fmt.Errorf("%w: unable to load current key", err)
+	}
+
+	newKey, err := encryption.ReadKey(RotateKeyFile, RotateKeyEnv)
+	if err != nil {
+		return fmt.Errorf("%w: unable to load new key", err)
+	}
+
+	rotated := 0
+	for _, db := range []struct {
+		name string
+		path string
+	}{
+		{"check:data", tester.DataPath(Config.DataDirectory, Config.Network)},
+		{"check:construction", tester.ConstructionPath(Config.DataDirectory, Config.Network)},
+	} {
+		if _, err := os.Stat(db.path); os.IsNotExist(err) {
+			color.Yellow("skipping %s database (not found at %s)", db.name, db.path)
+			continue
+		}
+
+		if err := encryption.Rotate(db.path, oldKey, newKey); err != nil {
+			return fmt.Errorf("%w: unable to rotate key of %s database", err, db.name)
+		}
+
+		color.Cyan("re-encrypted %s database", db.name)
+		rotated++
+	}
+
+	if rotated == 0 {
+		return errors.New("no databases found in data_directory")
+	}
+
+	color.Green(
+		"Success: re-encrypted %d databases (update the encryption configuration to the new key)",
+		rotated,
+	)
+
+	return nil
+}
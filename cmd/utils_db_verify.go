@@ -0,0 +1,114 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/coinbase/rosetta-cli/pkg/results"
+	"github.com/coinbase/rosetta-cli/pkg/retry"
+	"github.com/coinbase/rosetta-cli/pkg/verify"
+
+	"github.com/coinbase/rosetta-sdk-go/parser"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	utilsDBVerifyCmd = &cobra.Command{
+		Use:   "utils:db:verify",
+		Short: "Verify the integrity of data stored by check:data",
+		Long: `If check:data crashes (or the machine it runs on does), it is
+not always clear if the data it stored in the data_directory can still be
+trusted. This command walks all blocks stored by check:data (from the oldest
+block to the head block) and reports any corruption it finds:
+
+  linkage   a block is missing, is stored at the wrong index, or its
+            parent is not the previous block
+  balance   the stored balances of an account before and after a sampled
+            block do not differ by the balance change in the block
+  counter   the block, transaction, or operation counters are lower
+            than the number stored
+
+The balance changes in --balance-samples randomly selected blocks are
+re-derived (using the asserter and balance exemptions of the
+implementation at the online_url). Balance changes of accounts that are
+not tracked (or whose historical balances were pruned) are skipped.
+
+No data is written to storage. This command should not be run while
+check:data is running.`,
+		RunE: runUtilsDBVerifyCmd,
+	}
+
+	// DBVerifyBalanceSamples is the number of blocks where
+	// utils:db:verify re-derives balance changes.
+	DBVerifyBalanceSamples int64
+)
+
+func runUtilsDBVerifyCmd(cmd *cobra.Command, args []string) error {
+	if DBVerifyBalanceSamples < 0 {
+		return fmt.Errorf("balance samples %d must be >= 0", DBVerifyBalanceSamples)
+	}
+
+	// The fetcher's asserter is used to determine
+	// which operations are successful.
+	newFetcher, err := retry.NewFetcher(Config, Config.OnlineURL)
+	if err != nil {
+		return fmt.Errorf("%w: unable to create fetcher", err)
+	}
+	_, _, fetchErr := newFetcher.InitializeAsserter(Context, Config.Network)
+	if fetchErr != nil {
+		return fmt.Errorf("%w: unable to initialize asserter", fetchErr.Err)
+	}
+
+	networkOptions, fetchErr := newFetcher.NetworkOptionsRetry(Context, Config.Network, nil)
+	if fetchErr != nil {
+		return fmt.Errorf("%w: unable to get network options", fetchErr.Err)
+	}
+
+	localStore, err := openDataDatabase()
+	if err != nil {
+		return fmt.Errorf("%w: unable to verify database", err)
+	}
+	defer closeDatabase(localStore)
+
+	p := parser.New(newFetcher.Asserter, nil, networkOptions.Allow.BalanceExemptions)
+	verifyResults, err := verify.New(localStore, p, DBVerifyBalanceSamples).Run(Context)
+	if err != nil {
+		return fmt.Errorf("%w: unable to verify database", err)
+	}
+
+	summary := fmt.Sprintf(
+		"verified %d blocks (%d to %d) and %d balance changes in %d blocks (%d skipped)",
+		verifyResults.Blocks,
+		verifyResults.StartIndex,
+		verifyResults.EndIndex,
+		verifyResults.BalanceChanges,
+		verifyResults.SampledBlocks,
+		verifyResults.SkippedBalanceChanges,
+	)
+	if len(verifyResults.Corruptions) > 0 {
+		verify.Print(verifyResults.Corruptions)
+		return fmt.Errorf(
+			"%w: found %d inconsistencies after %s",
+			results.ErrStorageCorruption,
+			len(verifyResults.Corruptions),
+			summary,
+		)
+	}
+
+	color.Green("Success: %s", summary)
+	return nil
+}
@@ -0,0 +1,323 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strings"
+
+	"github.com/coinbase/rosetta-cli/pkg/keyfile"
+	"github.com/coinbase/rosetta-cli/pkg/tester"
+
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/coinbase/rosetta-sdk-go/utils"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// keyFilePermissions are the permissions of
+	// files containing exported private keys.
+	keyFilePermissions = 0600
+)
+
+var (
+	utilsKeysExportCmd = &cobra.Command{
+		Use:   "utils:keys:export",
+		Short: "Export the keys stored by check:construction",
+		Long: `check:construction generates and stores a key for every account it
+creates. If a run is halted before funds are returned (or the return_funds
+workflow is not defined), these keys are the only way to recover funds.
+
+This command exports all keys stored by check:construction in the
+data_directory so they can be imported into a wallet. The argument for this
+command is the path to write the keys to. Supported formats are:
+
+  hex       a JSON array of accounts, curve types, and hex-encoded private keys
+  wif       one address and WIF-encoded private key per line (secp256k1 only)
+  keystore  one encrypted Web3 Secret Storage file per account, written to
+            the directory provided as the argument (requires --passphrase)
+
+Exported keys are not encrypted (unless exported as a keystore), so they
+should be handled with care. Only the first key of multi-signature accounts
+is exported.`,
+		RunE: runUtilsKeysExportCmd,
+		Args: cobra.ExactArgs(1),
+	}
+
+	utilsKeysImportCmd = &cobra.Command{
+		Use:   "utils:keys:import",
+		Short: "Import keys to be used by check:construction",
+		Long: `This command stores externally generated keys in the
+check:construction database in the data_directory so they can be used by
+check:construction (for example, to fund a run from an existing wallet).
+The argument for this command is the path of the keys to import, in
+any of the formats supported by utils:keys:export:
+
+  hex       a JSON array of accounts, curve types, and hex-encoded private keys
+  wif       one address and WIF-encoded private key per line (secp256k1 only)
+  keystore  a single encrypted Web3 Secret Storage file (requires --passphrase
+            and --address)
+
+Keys for accounts that already have a stored key are skipped. This command
+should not be run while check:construction is running.`,
+		RunE: runUtilsKeysImportCmd,
+		Args: cobra.ExactArgs(1),
+	}
+
+	// KeysFormat is the format of the keys exported
+	// by utils:keys:export or imported by utils:keys:import.
+	KeysFormat string
+
+	// KeysPassphrase is the passphrase used to encrypt
+	// and decrypt keystores.
+	KeysPassphrase string
+
+	// KeysWIFMainnet is a boolean indicating if keys should
+	// be WIF-encoded with the Bitcoin mainnet version byte.
+	KeysWIFMainnet bool
+
+	// KeysAddress is the address of the account of a
+	// keystore imported by utils:keys:import.
+	KeysAddress string
+
+	// KeysCurveType is the curve type of the key in a
+	// keystore imported by utils:keys:import.
+	KeysCurveType string
+)
+
+// keystoreFileName returns the name of the
+// keystore file exported for account.
+func keystoreFileName(account *types.AccountIdentifier) string {
+	name := account.Address
+	if account.SubAccount != nil {
+		name = fmt.Sprintf("%s_%s", name, account.SubAccount.Address)
+	}
+
+	return fmt.Sprintf("%s.json", name)
+}
+
+// loadKeys returns all keys stored in
+// the check:construction database.
+func loadKeys() ([]*keyfile.Key, error) {
+	if len(Config.DataDirectory) == 0 {
+		return nil, errors.New("data_directory must be populated")
+	}
+
+	localStore, err := openDatabase(
+		tester.ConstructionPath(Config.DataDirectory, Config.Network),
+		"check:construction",
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer closeDatabase(localStore)
+
+	keyStorage := storage.NewKeyStorage(localStore)
+	dbTx := localStore.NewDatabaseTransaction(Context, false)
+	defer dbTx.Discard(Context)
+
+	accounts, err := keyStorage.GetAllAccountsTransactional(Context, dbTx)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to get accounts", err)
+	}
+
+	keys := make([]*keyfile.Key, len(accounts))
+	for i, account := range accounts {
+		keyPair, err := keyStorage.GetTransactional(Context, dbTx, account)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"%w: unable to get key of %s",
+				err,
+				types.AccountString(account),
+			)
+		}
+
+		keys[i] = &keyfile.Key{Account: account, KeyPair: keyPair}
+	}
+
+	return keys, nil
+}
+
+func runUtilsKeysExportCmd(cmd *cobra.Command, args []string) error {
+	keys, err := loadKeys()
+	if err != nil {
+		return fmt.Errorf("%w: unable to export keys", err)
+	}
+
+	output := path.Clean(args[0])
+	var encoded []byte
+	switch KeysFormat {
+	case keyfile.HexFormat:
+		encoded, err = keyfile.EncodeHex(keys)
+	case keyfile.WIFFormat:
+		encoded, err = keyfile.EncodeWIFList(keys, KeysWIFMainnet)
+	case keyfile.KeystoreFormat:
+		return exportKeystores(keys, output)
+	default:
+		return fmt.Errorf("%s is not a supported format", KeysFormat)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: unable to encode keys", err)
+	}
+
+	if err := ioutil.WriteFile(output, encoded, keyFilePermissions); err != nil {
+		return fmt.Errorf("%w: unable to write keys", err)
+	}
+
+	color.Green("Exported %d keys to %s", len(keys), output)
+	return nil
+}
+
+// exportKeystores writes an encrypted
+// keystore for each key to directory.
+func exportKeystores(keys []*keyfile.Key, directory string) error {
+	if len(KeysPassphrase) == 0 {
+		return errors.New("passphrase must be provided to export keystores")
+	}
+
+	if err := utils.EnsurePathExists(directory); err != nil {
+		return fmt.Errorf("%w: unable to create %s", err, directory)
+	}
+
+	for _, key := range keys {
+		encoded, err := keyfile.EncryptKeystore(
+			key.KeyPair.PrivateKey,
+			key.Account.Address,
+			KeysPassphrase,
+			keyfile.StandardScryptN,
+			keyfile.StandardScryptP,
+		)
+		if err != nil {
+			return fmt.Errorf(
+				"%w: unable to encrypt key of %s",
+				err,
+				types.AccountString(key.Account),
+			)
+		}
+
+		file := path.Join(directory, keystoreFileName(key.Account))
+		if err := ioutil.WriteFile(file, encoded, keyFilePermissions); err != nil {
+			return fmt.Errorf("%w: unable to write %s", err, file)
+		}
+	}
+
+	color.Green("Exported %d keystores to %s", len(keys), directory)
+	return nil
+}
+
+// decodeKeys decodes the keys in data
+// encoded in KeysFormat.
+func decodeKeys(data []byte) ([]*keyfile.Key, error) {
+	switch KeysFormat {
+	case keyfile.HexFormat:
+		return keyfile.DecodeHex(data)
+	case keyfile.WIFFormat:
+		return keyfile.DecodeWIFList(data)
+	case keyfile.KeystoreFormat:
+		if len(KeysAddress) == 0 {
+			return nil, errors.New("address must be provided to import a keystore")
+		}
+
+		privateKey, _, err := keyfile.DecryptKeystore(data, KeysPassphrase)
+		if err != nil {
+			return nil, err
+		}
+
+		key, err := keyfile.NewKey(
+			&types.AccountIdentifier{Address: KeysAddress},
+			privateKey,
+			types.CurveType(KeysCurveType),
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		return []*keyfile.Key{key}, nil
+	default:
+		return nil, fmt.Errorf("%s is not a supported format", KeysFormat)
+	}
+}
+
+func runUtilsKeysImportCmd(cmd *cobra.Command, args []string) error {
+	if len(Config.DataDirectory) == 0 {
+		return errors.New("data_directory must be populated")
+	}
+
+	data, err := ioutil.ReadFile(path.Clean(args[0]))
+	if err != nil {
+		return fmt.Errorf("%w: unable to read keys", err)
+	}
+
+	keys, err := decodeKeys(data)
+	if err != nil {
+		return fmt.Errorf("%w: unable to decode keys", err)
+	}
+
+	// Unlike other commands that read the check:construction
+	// database, keys can be imported before check:construction
+	// has ever been run.
+	dbPath := tester.ConstructionPath(Config.DataDirectory, Config.Network)
+	if err := utils.EnsurePathExists(dbPath); err != nil {
+		return fmt.Errorf("%w: unable to create %s", err, dbPath)
+	}
+
+	localStore, err := openDatabase(dbPath, "check:construction")
+	if err != nil {
+		return fmt.Errorf("%w: unable to import keys", err)
+	}
+	defer closeDatabase(localStore)
+
+	keyStorage := storage.NewKeyStorage(localStore)
+	dbTx := localStore.NewDatabaseTransaction(Context, true)
+	defer dbTx.Discard(Context)
+
+	imported := []string{}
+	for _, key := range keys {
+		_, err := keyStorage.GetTransactional(Context, dbTx, key.Account)
+		if err == nil {
+			color.Yellow("Skipping %s (key already stored)", types.AccountString(key.Account))
+			continue
+		}
+		if !errors.Is(err, storage.ErrAddrNotFound) {
+			return fmt.Errorf("%w: unable to check for existing key", err)
+		}
+
+		if err := keyStorage.StoreTransactional(Context, key.Account, key.KeyPair, dbTx); err != nil {
+			return fmt.Errorf(
+				"%w: unable to store key of %s",
+				err,
+				types.AccountString(key.Account),
+			)
+		}
+
+		imported = append(imported, types.AccountString(key.Account))
+	}
+
+	if err := dbTx.Commit(Context); err != nil {
+		return fmt.Errorf("%w: unable to commit keys", err)
+	}
+
+	if len(imported) > 0 {
+		fmt.Printf("Imported: %s\n", strings.Join(imported, ", "))
+	}
+
+	color.Green("Imported %d of %d keys", len(imported), len(keys))
+	return nil
+}
@@ -0,0 +1,80 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/coinbase/rosetta-cli/pkg/selftest"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	utilsSelfTestCmd = &cobra.Command{
+		Use:   "utils:selftest",
+		Short: "Check that this machine is ready to run the rosetta-cli",
+		Long: `Before starting a multi-day run of check:data or check:construction
+on a new machine, it is useful to make sure all local dependencies of the
+rosetta-cli work. This command exercises storage reads/writes, compression,
+key generation and signing for each supported curve, and logger streams
+with synthetic data (no requests are made to the node) and prints a
+readiness report.
+
+If data_directory is populated in the configuration file, all data is
+written to (and then removed from) a temporary directory inside of it.
+Otherwise, the system temporary directory is used.
+
+If any check fails, this command exits with a non-zero exit code.`,
+		RunE: runSelfTestCmd,
+	}
+
+	// errSelfTestFailed is returned when
+	// any self-test check fails.
+	errSelfTestFailed = errors.New("self-test failed")
+)
+
+func runSelfTestCmd(cmd *cobra.Command, args []string) error {
+	dir := Config.DataDirectory
+	if len(dir) == 0 {
+		dir = os.TempDir()
+	} else if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return fmt.Errorf("%w: unable to create data directory", err)
+	}
+
+	results, err := selftest.Run(Context, dir)
+	if err != nil {
+		return fmt.Errorf("%w: unable to run self-test", err)
+	}
+
+	selftest.Print(results)
+
+	failed := 0
+	for _, result := range results {
+		if !result.Passed() {
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%w: %d of %d checks failed", errSelfTestFailed, failed, len(results))
+	}
+
+	color.Green("All checks passed!")
+	return nil
+}
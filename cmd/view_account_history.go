@@ -0,0 +1,126 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/coinbase/rosetta-cli/pkg/history"
+	"github.com/coinbase/rosetta-cli/pkg/retry"
+
+	"github.com/coinbase/rosetta-sdk-go/asserter"
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// tableFormat prints records as a table.
+	tableFormat = "table"
+
+	// jsonFormat prints records as a JSON array.
+	jsonFormat = "json"
+)
+
+var (
+	viewAccountHistoryCmd = &cobra.Command{
+		Use:   "view:account-history",
+		Short: "View all operations affecting an account",
+		Long: `When a reconciliation fails, it is often useful to inspect every
+operation that modified the account. This command walks all blocks stored
+by check:data in the data_directory and prints every operation affecting
+the account with a running computed balance (for each currency).
+
+The account can be provided as an address or as a JSON representation of
+a types.AccountIdentifier (to view the history of a SubAccountIdentifier).
+For example, you could run view:account-history '{"address":"interesting address"}'.
+
+The running balance starts at 0 at the first block scanned, so it only
+matches the computed balance if no blocks where the account was modified
+have been pruned (and the account did not have a bootstrap balance). The
+node is only queried to determine which operation statuses are successful.`,
+		RunE: runViewAccountHistoryCmd,
+		Args: cobra.ExactArgs(1),
+	}
+
+	// AccountHistoryFormat is the format of the
+	// operations printed by view:account-history.
+	AccountHistoryFormat string
+
+	// AccountHistoryStartIndex is the first block
+	// index scanned by view:account-history.
+	AccountHistoryStartIndex int64
+
+	// AccountHistoryEndIndex is the last block
+	// index scanned by view:account-history.
+	AccountHistoryEndIndex int64
+)
+
+func runViewAccountHistoryCmd(cmd *cobra.Command, args []string) error {
+	if AccountHistoryFormat != tableFormat && AccountHistoryFormat != jsonFormat {
+		return fmt.Errorf("%s is not a supported format", AccountHistoryFormat)
+	}
+
+	account := &types.AccountIdentifier{Address: args[0]}
+	if strings.HasPrefix(strings.TrimSpace(args[0]), "{") {
+		account = &types.AccountIdentifier{}
+		if err := json.Unmarshal([]byte(args[0]), account); err != nil {
+			return fmt.Errorf("%w: unable to unmarshal account %s", err, args[0])
+		}
+	}
+
+	if err := asserter.AccountIdentifier(account); err != nil {
+		return fmt.Errorf("%w: invalid account identifier %s", err, types.PrintStruct(account))
+	}
+
+	// The fetcher's asserter is used to determine
+	// which operations are successful.
+	newFetcher, err := retry.NewFetcher(Config, Config.OnlineURL)
+	if err != nil {
+		return fmt.Errorf("%w: unable to create fetcher", err)
+	}
+	_, _, fetchErr := newFetcher.InitializeAsserter(Context, Config.Network)
+	if fetchErr != nil {
+		return fmt.Errorf("%w: unable to initialize asserter", fetchErr.Err)
+	}
+
+	localStore, err := openDataDatabase()
+	if err != nil {
+		return fmt.Errorf("%w: unable to view account history", err)
+	}
+	defer closeDatabase(localStore)
+
+	entries, err := history.AccountHistory(
+		Context,
+		storage.NewBlockStorage(localStore),
+		newFetcher.Asserter.OperationSuccessful,
+		account,
+		AccountHistoryStartIndex,
+		AccountHistoryEndIndex,
+	)
+	if err != nil {
+		return fmt.Errorf("%w: unable to get account history", err)
+	}
+
+	if AccountHistoryFormat == jsonFormat {
+		fmt.Println(types.PrettyPrintStruct(entries))
+		return nil
+	}
+
+	history.Print(entries)
+	return nil
+}
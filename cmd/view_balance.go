@@ -16,15 +16,19 @@ package cmd
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"strconv"
-	"time"
+	"strings"
+
+	"github.com/coinbase/rosetta-cli/pkg/retry"
 
 	"github.com/coinbase/rosetta-sdk-go/asserter"
-	"github.com/coinbase/rosetta-sdk-go/fetcher"
+	"github.com/coinbase/rosetta-sdk-go/storage"
 	"github.com/coinbase/rosetta-sdk-go/types"
 	"github.com/coinbase/rosetta-sdk-go/utils"
+	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 )
 
@@ -34,21 +38,44 @@ var (
 		Short: "View an account balance",
 		Long: `While debugging, it is often useful to inspect the state
 of an account at a certain block. This command allows you to look up
-any account by providing a JSON representation of a types.AccountIdentifier
-(and optionally a height to perform the query).
+any account by providing an address or a JSON representation of a
+types.AccountIdentifier (and optionally a block to perform the query).
+
+For example, you could run view:balance '{"address":"interesting address"}' --block 1000
+to lookup the balance of an interesting address at block 1000. The block can
+be provided as an index or a hash. Allowing the address to specified as JSON
+allows for querying by SubAccountIdentifier.
 
-For example, you could run view:balance '{"address":"interesting address"}' 1000
-to lookup the balance of an interesting address at block 1000. Allowing the
-address to specified as JSON allows for querying by SubAccountIdentifier.`,
+If the data_directory contains the block returned by the node (because
+check:data has synced it), the balance computed by check:data at that block
+is also printed for each currency along with the difference between the
+node balance and the computed balance.`,
 		RunE: runViewBalanceCmd,
-		Args: cobra.MinimumNArgs(1),
+		Args: cobra.RangeArgs(1, 2),
 	}
+
+	// ViewBalanceBlock is the index or hash of the
+	// block to perform the view:balance query at.
+	ViewBalanceBlock string
 )
 
+// parseLookupBlock returns the *types.PartialBlockIdentifier
+// described by block (an index or a hash).
+func parseLookupBlock(block string) *types.PartialBlockIdentifier {
+	if index, err := strconv.ParseInt(block, 10, 64); err == nil {
+		return &types.PartialBlockIdentifier{Index: &index}
+	}
+
+	return &types.PartialBlockIdentifier{Hash: &block}
+}
+
 func runViewBalanceCmd(cmd *cobra.Command, args []string) error {
-	account := &types.AccountIdentifier{}
-	if err := json.Unmarshal([]byte(args[0]), account); err != nil {
-		return fmt.Errorf("%w: unable to unmarshal account %s", err, args[0])
+	account := &types.AccountIdentifier{Address: args[0]}
+	if strings.HasPrefix(strings.TrimSpace(args[0]), "{") {
+		account = &types.AccountIdentifier{}
+		if err := json.Unmarshal([]byte(args[0]), account); err != nil {
+			return fmt.Errorf("%w: unable to unmarshal account %s", err, args[0])
+		}
 	}
 
 	if err := asserter.AccountIdentifier(account); err != nil {
@@ -56,12 +83,10 @@ func runViewBalanceCmd(cmd *cobra.Command, args []string) error {
 	}
 
 	// Create a new fetcher
-	newFetcher := fetcher.New(
-		Config.OnlineURL,
-		fetcher.WithRetryElapsedTime(time.Duration(Config.RetryElapsedTime)*time.Second),
-		fetcher.WithTimeout(time.Duration(Config.HTTPTimeout)*time.Second),
-		fetcher.WithMaxRetries(Config.MaxRetries),
-	)
+	newFetcher, err := retry.NewFetcher(Config, Config.OnlineURL)
+	if err != nil {
+		return fmt.Errorf("%w: unable to create fetcher", err)
+	}
 
 	// Initialize the fetcher's asserter
 	_, _, fetchErr := newFetcher.InitializeAsserter(Context, Config.Network)
@@ -69,19 +94,26 @@ func runViewBalanceCmd(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("%w: unable to initialize asserter", fetchErr.Err)
 	}
 
-	_, err := utils.CheckNetworkSupported(Context, Config.Network, newFetcher)
+	_, err = utils.CheckNetworkSupported(Context, Config.Network, newFetcher)
 	if err != nil {
 		return fmt.Errorf("%w: unable to confirm network is supported", err)
 	}
 
+	// The block index can also be provided as
+	// the second argument.
 	var lookupBlock *types.PartialBlockIdentifier
-	if len(args) > 1 {
+	switch {
+	case len(args) > 1 && len(ViewBalanceBlock) > 0:
+		return errors.New("block cannot be provided as an argument and with --block")
+	case len(args) > 1:
 		index, err := strconv.ParseInt(args[1], 10, 64)
 		if err != nil {
-			return fmt.Errorf("%w: unable to parse index %s", err, args[0])
+			return fmt.Errorf("%w: unable to parse index %s", err, args[1])
 		}
 
 		lookupBlock = &types.PartialBlockIdentifier{Index: &index}
+	case len(ViewBalanceBlock) > 0:
+		lookupBlock = parseLookupBlock(ViewBalanceBlock)
 	}
 
 	block, amounts, metadata, fetchErr := newFetcher.AccountBalanceRetry(
@@ -99,5 +131,83 @@ func runViewBalanceCmd(cmd *cobra.Command, args []string) error {
 	log.Printf("Metadata: %s\n", types.PrettyPrintStruct(metadata))
 	log.Printf("Balance Fetched At: %s\n", types.PrettyPrintStruct(block))
 
+	if len(Config.DataDirectory) == 0 {
+		return nil
+	}
+
+	localStore, err := openDataDatabase()
+	if err != nil {
+		color.Yellow("%s: skipping comparison with computed balance", err.Error())
+		return nil
+	}
+	defer closeDatabase(localStore)
+
+	return compareComputedBalance(localStore, account, block, amounts)
+}
+
+// compareComputedBalance prints the balance of account computed by
+// check:data at block for the currency of each amount (fetched from
+// the node) and the difference between them.
+func compareComputedBalance(
+	localStore storage.Database,
+	account *types.AccountIdentifier,
+	block *types.BlockIdentifier,
+	amounts []*types.Amount,
+) error {
+	// The block must match the block returned by the node
+	// (not just its index) for the balances to be comparable.
+	localBlock, err := storage.NewBlockStorage(localStore).GetBlock(
+		Context,
+		&types.PartialBlockIdentifier{Hash: &block.Hash},
+	)
+	if err != nil {
+		color.Yellow(
+			"%s: block %s has not been synced by check:data",
+			err.Error(),
+			types.PrintStruct(block),
+		)
+		return nil
+	}
+
+	balanceStorage := storage.NewBalanceStorage(localStore)
+	dbTx := localStore.NewDatabaseTransaction(Context, false)
+	defer dbTx.Discard(Context)
+
+	for _, amount := range amounts {
+		computed, err := balanceStorage.GetBalanceTransactional(
+			Context,
+			dbTx,
+			account,
+			amount.Currency,
+			localBlock.BlockIdentifier.Index,
+		)
+		if err != nil {
+			color.Yellow(
+				"%s: unable to get computed %s balance",
+				err.Error(),
+				types.PrintStruct(amount.Currency),
+			)
+			continue
+		}
+
+		difference, err := types.SubtractValues(amount.Value, computed.Value)
+		if err != nil {
+			return fmt.Errorf("%w: unable to compare balances", err)
+		}
+
+		printer := color.Green
+		if difference != "0" {
+			printer = color.Red
+		}
+
+		printer(
+			"%s Live: %s Computed: %s Difference: %s",
+			types.PrintStruct(amount.Currency),
+			amount.Value,
+			computed.Value,
+			difference,
+		)
+	}
+
 	return nil
 }
@@ -0,0 +1,68 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/coinbase/rosetta-cli/pkg/processor"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/spf13/cobra"
+)
+
+var (
+	viewCheckpointBalancesCmd = &cobra.Command{
+		Use:   "view:checkpoint-balances",
+		Short: "View balances trusted on first sight by check:data",
+		Long: `When checkpoint_balances is enabled, check:data trusts the balance
+returned by /account/balance (at the parent block) for every account first
+seen after syncing started instead of computing it from genesis. Each
+trusted balance is stored in the data_directory. This command prints all
+trusted balances so that it is clear which balances were bootstrapped.
+
+When --format json is provided, balances are printed as a JSON array.`,
+		RunE: runViewCheckpointBalancesCmd,
+	}
+
+	// ViewCheckpointBalancesFormat is the format of the
+	// balances printed by view:checkpoint-balances.
+	ViewCheckpointBalancesFormat string
+)
+
+func runViewCheckpointBalancesCmd(cmd *cobra.Command, args []string) error {
+	if ViewCheckpointBalancesFormat != tableFormat && ViewCheckpointBalancesFormat != jsonFormat {
+		return fmt.Errorf("%s is not a supported format", ViewCheckpointBalancesFormat)
+	}
+
+	localStore, err := openDataDatabase()
+	if err != nil {
+		return fmt.Errorf("%w: unable to view checkpoint balances", err)
+	}
+	defer closeDatabase(localStore)
+
+	balances, err := processor.GetCheckpointBalances(Context, localStore)
+	if err != nil {
+		return fmt.Errorf("%w: unable to get checkpoint balances", err)
+	}
+
+	if ViewCheckpointBalancesFormat == jsonFormat {
+		fmt.Println(types.PrettyPrintStruct(balances))
+		return nil
+	}
+
+	processor.PrintCheckpointBalances(balances)
+	return nil
+}
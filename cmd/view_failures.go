@@ -0,0 +1,70 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/coinbase/rosetta-cli/pkg/failures"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/spf13/cobra"
+)
+
+var (
+	viewFailuresCmd = &cobra.Command{
+		Use:   "view:failures",
+		Short: "View failures recorded by check:data",
+		Long: `Every failure found by check:data (reconciliation failures,
+accounts referenced before creation, invariant violations, block fetch
+mismatches, and the error check:data exited with) is stored as a typed
+record in the data_directory. This command prints all recorded failures
+(oldest first).
+
+When --format json is provided, failures are printed as a JSON array
+with a stable schema (indicated by the version of each failure) so that
+they can be inspected programmatically.`,
+		RunE: runViewFailuresCmd,
+	}
+
+	// ViewFailuresFormat is the format of the
+	// failures printed by view:failures.
+	ViewFailuresFormat string
+)
+
+func runViewFailuresCmd(cmd *cobra.Command, args []string) error {
+	if ViewFailuresFormat != tableFormat && ViewFailuresFormat != jsonFormat {
+		return fmt.Errorf("%s is not a supported format", ViewFailuresFormat)
+	}
+
+	localStore, err := openDataDatabase()
+	if err != nil {
+		return fmt.Errorf("%w: unable to view failures", err)
+	}
+	defer closeDatabase(localStore)
+
+	recorded, err := failures.NewStorage(localStore).GetAll(Context)
+	if err != nil {
+		return fmt.Errorf("%w: unable to get failures", err)
+	}
+
+	if ViewFailuresFormat == jsonFormat {
+		fmt.Println(types.PrettyPrintStruct(recorded))
+		return nil
+	}
+
+	failures.Print(recorded)
+	return nil
+}
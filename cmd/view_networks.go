@@ -18,9 +18,10 @@ import (
 	"errors"
 	"fmt"
 	"log"
-	"time"
 
-	"github.com/coinbase/rosetta-sdk-go/fetcher"
+	"github.com/coinbase/rosetta-cli/pkg/compare"
+	"github.com/coinbase/rosetta-cli/pkg/retry"
+
 	"github.com/coinbase/rosetta-sdk-go/types"
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
@@ -35,18 +36,38 @@ useful to view network(s) status. This command fetches the network
 status from all available networks and prints it to the terminal.
 
 If this command errors, it is likely because the /network/* endpoints are
-not formatted correctly.`,
+not formatted correctly.
+
+When --save-options is provided, the /network/options response of
+each network is stored in the specified file. When --compare-options
+is provided, the /network/options responses are diffed against a file
+previously stored with --save-options and all drift (removed or added
+operation types, changed operation statuses or errors, version bumps,
+etc.) is printed. This command exits with an error if any drift could
+break existing integrations (like a removed operation type or a status
+that changed whether it is successful). Version bumps are printed but
+are not considered breaking.`,
 		RunE: runViewNetworksCmd,
 	}
+
+	// SaveOptionsFile is the file the /network/options
+	// responses are stored in by view:networks.
+	SaveOptionsFile string
+
+	// CompareOptionsFile is a file previously stored
+	// with --save-options to diff /network/options against.
+	CompareOptionsFile string
+
+	// errBreakingOptionsDrift is returned when /network/options
+	// drifted in a way that could break existing integrations.
+	errBreakingOptionsDrift = errors.New("network options have breaking drift")
 )
 
 func runViewNetworksCmd(cmd *cobra.Command, args []string) error {
-	f := fetcher.New(
-		Config.OnlineURL,
-		fetcher.WithRetryElapsedTime(time.Duration(Config.RetryElapsedTime)*time.Second),
-		fetcher.WithTimeout(time.Duration(Config.HTTPTimeout)*time.Second),
-		fetcher.WithMaxRetries(Config.MaxRetries),
-	)
+	f, err := retry.NewFetcher(Config, Config.OnlineURL)
+	if err != nil {
+		return fmt.Errorf("%w: unable to create fetcher", err)
+	}
 
 	// Attempt to fetch network list
 	networkList, fetchErr := f.NetworkListRetry(Context, nil)
@@ -58,6 +79,7 @@ func runViewNetworksCmd(cmd *cobra.Command, args []string) error {
 		return errors.New("no networks available")
 	}
 
+	capture := &compare.OptionsCapture{}
 	for _, network := range networkList.NetworkIdentifiers {
 		color.Cyan(types.PrettyPrintStruct(network))
 		networkOptions, fetchErr := f.NetworkOptions(
@@ -70,6 +92,10 @@ func runViewNetworksCmd(cmd *cobra.Command, args []string) error {
 		}
 
 		log.Printf("Network options: %s\n", types.PrettyPrintStruct(networkOptions))
+		capture.Networks = append(capture.Networks, &compare.NetworkOptions{
+			Network: network,
+			Options: networkOptions,
+		})
 
 		networkStatus, fetchErr := f.NetworkStatusRetry(
 			Context,
@@ -83,5 +109,35 @@ func runViewNetworksCmd(cmd *cobra.Command, args []string) error {
 		log.Printf("Network status: %s\n", types.PrettyPrintStruct(networkStatus))
 	}
 
+	if len(SaveOptionsFile) > 0 {
+		if err := capture.Save(SaveOptionsFile); err != nil {
+			return err
+		}
+
+		log.Printf("Network options saved to %s\n", SaveOptionsFile)
+	}
+
+	if len(CompareOptionsFile) == 0 {
+		return nil
+	}
+
+	previous, err := compare.LoadOptionsCapture(CompareOptionsFile)
+	if err != nil {
+		return err
+	}
+
+	drifts := compare.DiffCaptures(previous, capture)
+	if len(drifts) == 0 {
+		color.Green("No network options drift from %s", CompareOptionsFile)
+		return nil
+	}
+
+	compare.PrintDrifts(drifts)
+	for _, drift := range drifts {
+		if drift.Breaking {
+			return fmt.Errorf("%w: compared to %s", errBreakingOptionsDrift, CompareOptionsFile)
+		}
+	}
+
 	return nil
 }
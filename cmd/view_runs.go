@@ -0,0 +1,69 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/coinbase/rosetta-cli/pkg/provenance"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/spf13/cobra"
+)
+
+var (
+	viewRunsCmd = &cobra.Command{
+		Use:   "view:runs",
+		Short: "View runs recorded in the data_directory",
+		Long: `Each run of check:data and export:blocks is recorded in the
+data_directory with its run ID, rosetta-cli version, configuration hash,
+node version (from /network/options), and time range. The same run is
+stamped on the results file, the log streams, and exported files, so any
+artifact can be traced back to the run that produced it.
+
+Runs without an end time were interrupted before they could exit.
+
+When --format json is provided, runs are printed as a JSON array.`,
+		RunE: runViewRunsCmd,
+	}
+
+	// ViewRunsFormat is the format of the
+	// runs printed by view:runs.
+	ViewRunsFormat string
+)
+
+func runViewRunsCmd(cmd *cobra.Command, args []string) error {
+	if ViewRunsFormat != tableFormat && ViewRunsFormat != jsonFormat {
+		return fmt.Errorf("%s is not a supported format", ViewRunsFormat)
+	}
+
+	if len(Config.DataDirectory) == 0 {
+		return errors.New("data_directory must be populated")
+	}
+
+	runs, err := provenance.List(Config.DataDirectory)
+	if err != nil {
+		return fmt.Errorf("%w: unable to view runs", err)
+	}
+
+	if ViewRunsFormat == jsonFormat {
+		fmt.Println(types.PrettyPrintStruct(runs))
+		return nil
+	}
+
+	provenance.Print(runs)
+	return nil
+}
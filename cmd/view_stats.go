@@ -0,0 +1,101 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/coinbase/rosetta-cli/pkg/opstats"
+	"github.com/coinbase/rosetta-cli/pkg/results"
+
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/spf13/cobra"
+)
+
+var (
+	viewStatsCmd = &cobra.Command{
+		Use:   "view:stats",
+		Short: "View statistics recorded by check:data",
+		Long: `While syncing, check:data records the number of blocks, transactions,
+operations, and reconciliations it has processed. When operation_stats_enabled
+is true, it also records the count, total absolute value (per currency), and
+number of unique accounts of each operation type. This command prints all
+statistics recorded in the data_directory (check:data does not need to be
+running).
+
+When --format json is provided, statistics are printed as a JSON object.`,
+		RunE: runViewStatsCmd,
+	}
+
+	// ViewStatsFormat is the format of the
+	// statistics printed by view:stats.
+	ViewStatsFormat string
+)
+
+// storedStats are the statistics printed by view:stats.
+type storedStats struct {
+	Stats          *results.CheckDataStats   `json:"stats"`
+	OperationStats []*opstats.OperationStats `json:"operation_stats"`
+}
+
+func runViewStatsCmd(cmd *cobra.Command, args []string) error {
+	if ViewStatsFormat != tableFormat && ViewStatsFormat != jsonFormat {
+		return fmt.Errorf("%s is not a supported format", ViewStatsFormat)
+	}
+
+	localStore, err := openDataDatabase()
+	if err != nil {
+		return fmt.Errorf("%w: unable to view stats", err)
+	}
+	defer closeDatabase(localStore)
+
+	counterStorage := storage.NewCounterStorage(localStore)
+	stats := results.ComputeCheckDataStats(
+		Context,
+		counterStorage,
+		storage.NewBalanceStorage(localStore),
+	)
+	if stats == nil {
+		return errors.New("unable to compute stats")
+	}
+	stats.ReconciliationDrift = results.ComputeReconciliationDrift(
+		Context,
+		counterStorage,
+		Config.Data.ReconciliationTolerances,
+	)
+
+	operationStats, err := opstats.NewStorage(localStore, nil).GetAll(Context)
+	if err != nil {
+		return fmt.Errorf("%w: unable to get operation stats", err)
+	}
+
+	if ViewStatsFormat == jsonFormat {
+		fmt.Println(types.PrettyPrintStruct(&storedStats{
+			Stats:          stats,
+			OperationStats: operationStats,
+		}))
+		return nil
+	}
+
+	stats.Print()
+	if len(operationStats) > 0 {
+		fmt.Printf("\n")
+		opstats.Print(operationStats)
+	}
+
+	return nil
+}
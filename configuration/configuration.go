@@ -16,17 +16,24 @@ package configuration
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"log"
+	"math/big"
+	"net/http"
+	"net/url"
 	"path"
 
+	"github.com/coinbase/rosetta-cli/pkg/invariant"
+
 	"github.com/coinbase/rosetta-sdk-go/asserter"
 	"github.com/coinbase/rosetta-sdk-go/constructor/dsl"
 	"github.com/coinbase/rosetta-sdk-go/constructor/job"
 	"github.com/coinbase/rosetta-sdk-go/types"
-	"github.com/coinbase/rosetta-sdk-go/utils"
 	"github.com/fatih/color"
 )
 
@@ -52,6 +59,7 @@ func DefaultConfiguration() *Configuration {
 		HTTPTimeout:          DefaultTimeout,
 		MaxRetries:           DefaultMaxRetries,
 		MaxSyncConcurrency:   DefaultMaxSyncConcurrency,
+		SyncCacheSize:        DefaultSyncCacheSize,
 		TipDelay:             DefaultTipDelay,
 		MaxReorgDepth:        DefaultMaxReorgDepth,
 		Data:                 DefaultDataConfiguration(),
@@ -85,10 +93,46 @@ func populateConstructionMissingFields(
 		constructionConfig.BlockBroadcastLimit = DefaultBlockBroadcastLimit
 	}
 
+	if backoff := constructionConfig.RebroadcastBackoff; backoff != nil && backoff.Multiplier == 0 {
+		backoff.Multiplier = DefaultRebroadcastMultiplier
+	}
+
 	if constructionConfig.StatusPort == 0 {
 		constructionConfig.StatusPort = DefaultStatusPort
 	}
 
+	if sends := constructionConfig.ConcurrentSends; sends != nil && sends.MaxPending == 0 {
+		sends.MaxPending = DefaultConcurrentSendsMaxPending
+	}
+
+	if staking := constructionConfig.StakingWorkflows; staking != nil {
+		if staking.Concurrency == 0 {
+			staking.Concurrency = DefaultStakingConcurrency
+		}
+
+		if len(staking.ValidatorMetadataKey) == 0 {
+			staking.ValidatorMetadataKey = DefaultValidatorMetadataKey
+		}
+	}
+
+	if tokens := constructionConfig.TokenWorkflows; tokens != nil {
+		if tokens.Concurrency == 0 {
+			tokens.Concurrency = DefaultTokenConcurrency
+		}
+
+		if len(tokens.ContractAddressKey) == 0 {
+			tokens.ContractAddressKey = DefaultContractAddressKey
+		}
+
+		if len(tokens.TransferOperationType) == 0 {
+			tokens.TransferOperationType = DefaultTokenTransferOperationType
+		}
+
+		if len(tokens.SpenderMetadataKey) == 0 {
+			tokens.SpenderMetadataKey = DefaultSpenderMetadataKey
+		}
+	}
+
 	return constructionConfig
 }
 
@@ -113,6 +157,82 @@ func populateDataMissingFields(dataConfig *DataConfiguration) *DataConfiguration
 		dataConfig.StatusPort = DefaultStatusPort
 	}
 
+	applyQuickMode(dataConfig)
+
+	if dataConfig.ReorgAlerts != nil && dataConfig.ReorgAlerts.OrphanRateWindow == 0 {
+		dataConfig.ReorgAlerts.OrphanRateWindow = DefaultOrphanRateWindow
+	}
+
+	if dataConfig.Statsd != nil {
+		if dataConfig.Statsd.Port == 0 {
+			dataConfig.Statsd.Port = DefaultStatsdPort
+		}
+
+		if len(dataConfig.Statsd.Prefix) == 0 {
+			dataConfig.Statsd.Prefix = DefaultStatsdPrefix
+		}
+	}
+
+	if dataConfig.TimestampValidation != nil {
+		if dataConfig.TimestampValidation.MaxFutureSkew == 0 {
+			dataConfig.TimestampValidation.MaxFutureSkew = DefaultTimestampMaxFutureSkew
+		}
+
+		if dataConfig.TimestampValidation.TipWindow == 0 {
+			dataConfig.TimestampValidation.TipWindow = DefaultTimestampTipWindow
+		}
+	}
+
+	if dataConfig.BlockStream != nil && dataConfig.BlockStream.Timeout == 0 {
+		dataConfig.BlockStream.Timeout = DefaultBlockStreamTimeout
+	}
+
+	if dataConfig.SyncRestarts != nil {
+		if dataConfig.SyncRestarts.MaxRestarts == 0 {
+			dataConfig.SyncRestarts.MaxRestarts = DefaultSyncMaxRestarts
+		}
+
+		if dataConfig.SyncRestarts.InitialBackoff == 0 {
+			dataConfig.SyncRestarts.InitialBackoff = DefaultSyncInitialBackoff
+		}
+
+		if dataConfig.SyncRestarts.MaxBackoff == 0 {
+			dataConfig.SyncRestarts.MaxBackoff = DefaultSyncMaxBackoff
+		}
+	}
+
+	if dataConfig.DiskSpaceWatchdog != nil && dataConfig.DiskSpaceWatchdog.CheckInterval == 0 {
+		dataConfig.DiskSpaceWatchdog.CheckInterval = DefaultDiskSpaceCheckInterval
+	}
+
+	if dataConfig.ArtifactUpload != nil && dataConfig.ArtifactUpload.Timeout == 0 {
+		dataConfig.ArtifactUpload.Timeout = DefaultArtifactUploadTimeout
+	}
+
+	if dataConfig.SupplyTracking != nil {
+		if dataConfig.SupplyTracking.CheckpointInterval == 0 {
+			dataConfig.SupplyTracking.CheckpointInterval = DefaultSupplyCheckpointInterval
+		}
+
+		for _, currency := range dataConfig.SupplyTracking.Currencies {
+			if currency != nil && len(currency.CallMethod) > 0 && len(currency.ResultPath) == 0 {
+				currency.ResultPath = DefaultSupplyResultPath
+			}
+		}
+	}
+
+	if dataConfig.BlockArchive != nil && dataConfig.BlockArchive.SegmentSize == 0 {
+		dataConfig.BlockArchive.SegmentSize = DefaultBlockArchiveSegmentSize
+	}
+
+	if dataConfig.Quorum != nil && dataConfig.Quorum.Size == 0 {
+		dataConfig.Quorum.Size = len(dataConfig.Quorum.URLs) + 1
+	}
+
+	if dataConfig.OptionalWorkers != nil && dataConfig.OptionalWorkers.MaxConsecutiveErrors == 0 {
+		dataConfig.OptionalWorkers.MaxConsecutiveErrors = DefaultOptionalWorkerMaxErrors
+	}
+
 	return dataConfig
 }
 
@@ -145,31 +265,131 @@ func populateMissingFields(config *Configuration) *Configuration {
 		config.MaxSyncConcurrency = DefaultMaxSyncConcurrency
 	}
 
+	if config.SyncCacheSize == 0 {
+		config.SyncCacheSize = DefaultSyncCacheSize
+	}
+
 	if config.TipDelay == 0 {
 		config.TipDelay = DefaultTipDelay
 	}
 
+	if config.Finality != nil {
+		if config.Finality.Model == EpochFinality && config.Finality.Epochs == 0 {
+			config.Finality.Epochs = DefaultFinalityEpochs
+		}
+
+		// The syncer must always keep at least 1
+		// block to detect reorgs.
+		config.MaxReorgDepth = int(config.Finality.MaxDepth())
+		if config.MaxReorgDepth == 0 {
+			config.MaxReorgDepth = 1
+		}
+	}
+
 	if config.MaxReorgDepth == 0 {
 		config.MaxReorgDepth = DefaultMaxReorgDepth
 	}
 
+	if config.RetryBackoff != nil && config.RetryBackoff.MaxBackoff == 0 {
+		config.RetryBackoff.MaxBackoff = DefaultRetryMaxBackoff
+	}
+
+	if config.Chaos != nil && config.Chaos.TimeoutDelay == 0 {
+		config.Chaos.TimeoutDelay = DefaultChaosTimeoutDelay
+	}
+
 	config.Construction = populateConstructionMissingFields(config.Construction)
 	config.Data = populateDataMissingFields(config.Data)
 
 	return config
 }
 
-func assertConstructionConfiguration(ctx context.Context, config *ConstructionConfiguration) error {
+func assertConfirmationDepths(config *ConstructionConfiguration) error {
+	if config.ConfirmationDepth < 0 {
+		return fmt.Errorf("confirmation depth %d must be non-negative", config.ConfirmationDepth)
+	}
+
+	names := map[string]struct{}{}
+	for _, workflow := range config.Workflows {
+		names[workflow.Name] = struct{}{}
+	}
+
+	for name, depth := range config.WorkflowConfirmationDepths {
+		if _, ok := names[name]; !ok {
+			return fmt.Errorf("workflow %s is not defined", name)
+		}
+
+		if depth < 0 {
+			return fmt.Errorf("confirmation depth %d of workflow %s must be non-negative", depth, name)
+		}
+	}
+
+	return nil
+}
+
+func assertConcurrentSends(config *ConstructionConfiguration) error {
+	sends := config.ConcurrentSends
+	if sends == nil {
+		return nil
+	}
+
+	if len(sends.Workflows) == 0 {
+		return errors.New("at least one workflow must be populated")
+	}
+
+	if sends.MaxPending < 2 {
+		return fmt.Errorf("max pending %d must be >= 2", sends.MaxPending)
+	}
+
+	concurrency := map[string]int{}
+	for _, workflow := range config.Workflows {
+		concurrency[workflow.Name] = workflow.Concurrency
+	}
+
+	for _, name := range sends.Workflows {
+		workflowConcurrency, ok := concurrency[name]
+		if !ok {
+			return fmt.Errorf("workflow %s is not defined", name)
+		}
+
+		if workflowConcurrency < 2 {
+			return fmt.Errorf(
+				"workflow %s must have concurrency > 1 (has %d)",
+				name,
+				workflowConcurrency,
+			)
+		}
+	}
+
+	return nil
+}
+
+func assertConstructionConfiguration(
+	ctx context.Context,
+	config *ConstructionConfiguration,
+	network *types.NetworkIdentifier,
+) error {
 	if config == nil {
 		return nil
 	}
 
+	if err := assertURL(config.OfflineURL); err != nil {
+		return fmt.Errorf("%w: invalid offline url", err)
+	}
+
 	if len(config.Workflows) > 0 && len(config.ConstructorDSLFile) > 0 {
 		return errors.New("cannot populate both workflows and DSL file path")
 	}
 
-	if len(config.Workflows) == 0 && len(config.ConstructorDSLFile) == 0 {
-		return errors.New("both workflows and DSL file path are empty")
+	if len(config.Workflows) == 0 &&
+		len(config.ConstructorDSLFile) == 0 &&
+		len(config.WorkflowsDirectory) == 0 {
+		return errors.New("workflows, DSL file path, and workflows directory are empty")
+	}
+
+	if len(config.WorkflowsDirectory) == 0 &&
+		(len(config.WorkflowsInclude) > 0 || len(config.WorkflowsExclude) > 0) {
+		return errors.New("workflow globs cannot be populated without a workflows directory")
 	}
 
 	// Compile ConstructorDSLFile and save to Workflows
@@ -183,6 +403,74 @@ func assertConstructionConfiguration(ctx context.Context, config *ConstructionCo
 		config.Workflows = compiledWorkflows
 	}
 
+	if len(config.WorkflowsDirectory) > 0 {
+		workflows, err := LoadWorkflowsDirectory(
+			ctx,
+			config.WorkflowsDirectory,
+			config.WorkflowsInclude,
+			config.WorkflowsExclude,
+		)
+		if err != nil {
+			return fmt.Errorf("%w: unable to load workflows directory", err)
+		}
+
+		names := map[string]struct{}{}
+		for _, workflow := range config.Workflows {
+			names[workflow.Name] = struct{}{}
+		}
+
+		for _, workflow := range workflows {
+			if _, ok := names[workflow.Name]; ok {
+				return fmt.Errorf("workflow %s is defined more than once", workflow.Name)
+			}
+
+			names[workflow.Name] = struct{}{}
+			config.Workflows = append(config.Workflows, workflow)
+		}
+	}
+
+	if err := assertStakingWorkflows(config.StakingWorkflows); err != nil {
+		return fmt.Errorf("%w: invalid staking workflows", err)
+	}
+
+	if config.StakingWorkflows != nil {
+		names := map[string]struct{}{}
+		for _, workflow := range config.Workflows {
+			names[workflow.Name] = struct{}{}
+		}
+
+		for _, workflow := range GenerateStakingWorkflows(config.StakingWorkflows, network) {
+			if _, ok := names[workflow.Name]; ok {
+				return fmt.Errorf("staking workflow %s is already defined", workflow.Name)
+			}
+
+			config.Workflows = append(config.Workflows, workflow)
+		}
+	}
+
+	if err := assertTokenWorkflows(config.TokenWorkflows); err != nil {
+		return fmt.Errorf("%w: invalid token workflows", err)
+	}
+
+	if config.TokenWorkflows != nil {
+		names := map[string]struct{}{}
+		for _, workflow := range config.Workflows {
+			names[workflow.Name] = struct{}{}
+		}
+
+		for _, workflow := range GenerateTokenWorkflows(config.TokenWorkflows, network) {
+			if _, ok := names[workflow.Name]; ok {
+				return fmt.Errorf("token workflow %s is already defined", workflow.Name)
+			}
+
+			config.Workflows = append(config.Workflows, workflow)
+		}
+	}
+
+	if err := assertConfirmationDepths(config); err != nil {
+		return fmt.Errorf("%w: invalid confirmation depths", err)
+	}
+
 	// Parse provided Workflows
 	for _, workflow := range config.Workflows {
 		if workflow.Name == string(job.CreateAccount) || workflow.Name == string(job.RequestFunds) {
@@ -196,6 +484,39 @@ func assertConstructionConfiguration(ctx context.Context, config *ConstructionCo
 		}
 	}
 
+	if err := assertConcurrentSends(config); err != nil {
+		return fmt.Errorf("%w: invalid concurrent sends", err)
+	}
+
+	if err := assertRemoteSigner(config); err != nil {
+		return fmt.Errorf("%w: invalid remote signer", err)
+	}
+
+	pluginCurves, err := assertCurvePlugins(config)
+	if err != nil {
+		return fmt.Errorf("%w: invalid curve plugins", err)
+	}
+
+	if err := assertRebroadcastBackoff(config.RebroadcastBackoff); err != nil {
+		return fmt.Errorf("%w: invalid rebroadcast backoff", err)
+	}
+
+	if config.FeeEstimation != nil && config.FeeEstimation.MaxDeviation < 0 {
+		return fmt.Errorf(
+			"fee estimation max deviation %f must be >= 0",
+			config.FeeEstimation.MaxDeviation,
+		)
+	}
+
+	switch config.UnconfirmedBroadcasts {
+	case "", WarnUnconfirmedBroadcasts, FailUnconfirmedBroadcasts:
+	default:
+		return fmt.Errorf(
+			"unconfirmed broadcasts behavior %s is not supported",
+			config.UnconfirmedBroadcasts,
+		)
+	}
+
 	for _, account := range config.PrefundedAccounts {
 		// Checks that privkey is hex encoded
 		_, err := hex.DecodeString(account.PrivateKeyHex)
@@ -207,9 +528,11 @@ func assertConstructionConfiguration(ctx context.Context, config *ConstructionCo
 			)
 		}
 
-		// Checks if valid CurveType
-		if err := asserter.CurveType(account.CurveType); err != nil {
-			return fmt.Errorf("%w: invalid CurveType for prefunded account", err)
+		// Checks if valid CurveType (or handled by a curve plugin)
+		if _, ok := pluginCurves[account.CurveType]; !ok {
+			if err := asserter.CurveType(account.CurveType); err != nil {
+				return fmt.Errorf("%w: invalid CurveType for prefunded account", err)
+			}
 		}
 
 		// Checks if valid AccountIdentifier
@@ -227,126 +550,1242 @@ func assertConstructionConfiguration(ctx context.Context, config *ConstructionCo
 	return nil
 }
 
-func assertDataConfiguration(config *DataConfiguration) error {
-	if config.StartIndex != nil && *config.StartIndex < 0 {
-		return fmt.Errorf("start index %d cannot be negative", *config.StartIndex)
+func assertRebroadcastBackoff(config *RebroadcastBackoff) error {
+	if config == nil {
+		return nil
 	}
 
-	if config.EndConditions == nil {
-		return nil
+	if config.InitialInterval <= 0 {
+		return errors.New("initial interval must be > 0")
 	}
 
-	if config.EndConditions.Index != nil {
-		if *config.EndConditions.Index < 0 {
-			return fmt.Errorf("end index %d cannot be negative", *config.EndConditions.Index)
-		}
+	if config.Multiplier < 1 {
+		return fmt.Errorf("multiplier %f must be >= 1", config.Multiplier)
 	}
 
-	if config.EndConditions.ReconciliationCoverage != nil {
-		coverage := config.EndConditions.ReconciliationCoverage.Coverage
-		if coverage < 0 || coverage > 1 {
-			return fmt.Errorf("reconciliation coverage %f must be [0.0,1.0]", coverage)
-		}
+	if config.MaxInterval < 0 {
+		return fmt.Errorf("max interval %d must be >= 0", config.MaxInterval)
+	}
 
-		index := config.EndConditions.ReconciliationCoverage.Index
-		if index != nil && *index < 0 {
-			return fmt.Errorf("reconciliation coverage height %d must be >= 0", *index)
+	if config.MaxRebroadcasts < 0 {
+		return fmt.Errorf("max rebroadcasts %d must be >= 0", config.MaxRebroadcasts)
+	}
+
+	lastRebroadcast := int64(0)
+	if schedule := config.Schedule(); len(schedule) > 0 {
+		lastRebroadcast = schedule[len(schedule)-1]
+	}
+
+	if config.LostDepth <= lastRebroadcast {
+		return fmt.Errorf(
+			"lost depth %d must be > depth of last rebroadcast %d",
+			config.LostDepth,
+			lastRebroadcast,
+		)
+	}
+
+	return nil
+}
+
+// assertCurvePlugins ensures each curve plugin handles a
+// different curve that is not natively supported and returns
+// the curves handled by plugins.
+func assertCurvePlugins(config *ConstructionConfiguration) (map[types.CurveType]struct{}, error) {
+	curves := map[types.CurveType]struct{}{}
+	if len(config.CurvePlugins) > 0 && len(config.Seed) > 0 {
+		return nil, errors.New("seeded keys cannot be derived on plugin curves")
+	}
+
+	for _, plugin := range config.CurvePlugins {
+		if plugin == nil {
+			return nil, errors.New("curve plugin cannot be nil")
 		}
 
-		accountCount := config.EndConditions.ReconciliationCoverage.AccountCount
-		if accountCount != nil && *accountCount < 0 {
-			return fmt.Errorf(
-				"reconciliation coverage account count %d must be >= 0",
-				*accountCount,
-			)
+		if len(plugin.CurveType) == 0 {
+			return nil, errors.New("curve plugin curve type must be populated")
 		}
 
-		if config.BalanceTrackingDisabled {
-			return errors.New(
-				"balance tracking must be enabled for reconciliation coverage end condition",
-			)
+		if len(plugin.Path) == 0 {
+			return nil, fmt.Errorf("curve plugin path for %s must be populated", plugin.CurveType)
 		}
 
-		if config.IgnoreReconciliationError {
-			return errors.New(
-				"reconciliation errors cannot be ignored for reconciliation coverage end condition",
-			)
+		if asserter.CurveType(plugin.CurveType) == nil {
+			return nil, fmt.Errorf("curve %s is natively supported", plugin.CurveType)
 		}
 
-		if config.ReconciliationDisabled {
-			return errors.New(
-				"reconciliation cannot be disabled for reconciliation coverage end condition",
-			)
+		if _, ok := curves[plugin.CurveType]; ok {
+			return nil, fmt.Errorf("curve %s has more than one plugin", plugin.CurveType)
 		}
+		curves[plugin.CurveType] = struct{}{}
 	}
 
-	return nil
+	return curves, nil
 }
 
-func assertConfiguration(ctx context.Context, config *Configuration) error {
-	if err := asserter.NetworkIdentifier(config.Network); err != nil {
-		return fmt.Errorf("%w: invalid network identifier", err)
+// assertRemoteSigner ensures every account managed by the
+// remote signer is valid and is not also a prefunded account
+// (with a local private key).
+func assertRemoteSigner(config *ConstructionConfiguration) error {
+	if config.RemoteSigner == nil {
+		return nil
 	}
 
-	if err := assertDataConfiguration(config.Data); err != nil {
-		return fmt.Errorf("%w: invalid data configuration", err)
+	if len(config.RemoteSigner.Path) == 0 {
+		return errors.New("remote signer path must be populated")
 	}
 
-	if err := assertConstructionConfiguration(ctx, config.Construction); err != nil {
-		return fmt.Errorf("%w: invalid construction configuration", err)
+	accounts := map[string]struct{}{}
+	for _, account := range config.PrefundedAccounts {
+		accounts[types.Hash(account.AccountIdentifier)] = struct{}{}
 	}
 
-	return nil
-}
+	for _, account := range config.RemoteSigner.Accounts {
+		if account == nil {
+			return errors.New("remote account cannot be nil")
+		}
 
-// modifyFilePaths modifies a collection of filepaths in a *Configuration
-// file to make them relative to the configuration file (this makes it a lot easier
-// to store all config-related files in the same directory and to run the rosetta-cli
-// from a different directory).
-func modifyFilePaths(config *Configuration, fileDir string) {
-	if config.Data != nil {
-		if len(config.Data.BootstrapBalances) > 0 {
-			config.Data.BootstrapBalances = path.Join(fileDir, config.Data.BootstrapBalances)
+		if len(account.KeyID) == 0 {
+			return errors.New("remote account key id must be populated")
 		}
 
-		if len(config.Data.InterestingAccounts) > 0 {
-			config.Data.InterestingAccounts = path.Join(fileDir, config.Data.InterestingAccounts)
+		if err := asserter.AccountIdentifier(account.AccountIdentifier); err != nil {
+			return fmt.Errorf("%w: invalid account for key %s", err, account.KeyID)
 		}
-	}
 
-	if config.Construction != nil {
-		if len(config.Construction.ConstructorDSLFile) > 0 {
-			config.Construction.ConstructorDSLFile = path.Join(
-				fileDir,
-				config.Construction.ConstructorDSLFile,
+		if err := asserter.Currency(account.Currency); err != nil {
+			return fmt.Errorf("%w: invalid currency for key %s", err, account.KeyID)
+		}
+
+		key := types.Hash(account.AccountIdentifier)
+		if _, ok := accounts[key]; ok {
+			return fmt.Errorf(
+				"account %s is defined more than once",
+				types.AccountString(account.AccountIdentifier),
 			)
 		}
+		accounts[key] = struct{}{}
 	}
+
+	return nil
 }
 
-// LoadConfiguration returns a parsed and asserted Configuration for running
-// tests.
-func LoadConfiguration(ctx context.Context, filePath string) (*Configuration, error) {
-	var configRaw Configuration
-	if err := utils.LoadAndParse(filePath, &configRaw); err != nil {
-		return nil, fmt.Errorf("%w: unable to open configuration file", err)
+// applyQuickMode disables balance tracking, coin tracking,
+// and reconciliation if quick mode is enabled.
+func applyQuickMode(config *DataConfiguration) {
+	if !config.QuickMode {
+		return
 	}
 
-	config := populateMissingFields(&configRaw)
+	config.BalanceTrackingDisabled = true
+	config.CoinTrackingDisabled = true
+	config.ReconciliationDisabled = true
+}
 
-	// Get the configuration file directory so we can load all files
-	// relative to the location of the configuration file.
-	fileDir := path.Dir(filePath)
-	modifyFilePaths(config, fileDir)
+// EnableQuickMode enables quick mode in an already loaded
+// configuration and returns an error if any other data
+// configuration requires balance tracking or reconciliation.
+func EnableQuickMode(config *Configuration) error {
+	config.Data.QuickMode = true
+	applyQuickMode(config.Data)
+	if err := assertDataConfiguration(config.Data); err != nil {
+		return fmt.Errorf("%w: unable to enable quick mode", err)
+	}
 
-	if err := assertConfiguration(ctx, config); err != nil {
-		return nil, fmt.Errorf("%w: invalid configuration", err)
+	return nil
+}
+
+func assertDataConfiguration(config *DataConfiguration) error {
+	if config.StartIndex != nil && *config.StartIndex < 0 {
+		return fmt.Errorf("start index %d cannot be negative", *config.StartIndex)
 	}
 
-	color.Cyan(
-		"loaded configuration file: %s\n",
-		filePath,
-	)
+	if err := assertBulkLoadIndex(config); err != nil {
+		return fmt.Errorf("%w: invalid bulk load index", err)
+	}
+
+	if config.AsserterRefresh != nil {
+		for _, index := range config.AsserterRefresh.Indices {
+			if index < 0 {
+				return fmt.Errorf("asserter refresh index %d cannot be negative", index)
+			}
+		}
+	}
+
+	if config.AccountCreation != nil {
+		if len(config.AccountCreation.OperationTypes) == 0 {
+			return errors.New("account creation operation types must be populated")
+		}
+	}
+
+	if config.FeeValidation != nil {
+		if len(config.FeeValidation.OperationTypes) == 0 {
+			return errors.New("fee validation operation types must be populated")
+		}
+	}
+
+	if config.BalanceInterpolationSamples > 0 {
+		if config.HistoricalBalanceEnabled != nil && !*config.HistoricalBalanceEnabled {
+			return errors.New("historical balance lookup must be enabled for balance interpolation")
+		}
+
+		if config.BalanceTrackingDisabled || config.ReconciliationDisabled {
+			return errors.New("balance interpolation requires balance tracking and reconciliation")
+		}
+	}
+
+	if config.CheckpointBalances {
+		if config.HistoricalBalanceEnabled != nil && !*config.HistoricalBalanceEnabled {
+			return errors.New("historical balance lookup must be enabled for checkpoint balances")
+		}
+
+		if config.BalanceTrackingDisabled || config.InitialBalanceFetchDisabled {
+			return errors.New("checkpoint balances require balance tracking and initial balance fetch")
+		}
+	}
+
+	if config.ReconciliationFailureBudget > 0 && config.IgnoreReconciliationError {
+		return errors.New("reconciliation failure budget cannot be used when ignoring reconciliation errors")
+	}
+
+	if config.ReconciliationDumpBlocks < 0 {
+		return fmt.Errorf("reconciliation dump blocks %d must be non-negative", config.ReconciliationDumpBlocks)
+	}
+
+	if err := assertReconciliationTolerances(config.ReconciliationTolerances); err != nil {
+		return fmt.Errorf("%w: invalid reconciliation tolerances", err)
+	}
+
+	if len(config.TrackedCurrencies) > 0 && len(config.IgnoredCurrencies) > 0 {
+		return errors.New("tracked currencies and ignored currencies cannot both be populated")
+	}
+
+	for _, currencies := range [][]*types.Currency{config.TrackedCurrencies, config.IgnoredCurrencies} {
+		for _, currency := range currencies {
+			if err := asserter.Currency(currency); err != nil {
+				return fmt.Errorf("%w: invalid currency filter", err)
+			}
+		}
+	}
+
+	switch config.SubAccountMode {
+	case "", SeparateSubAccounts, AggregateSubAccounts:
+	default:
+		return fmt.Errorf("%s is not a valid sub-account mode", config.SubAccountMode)
+	}
+
+	if config.ReconcilerActiveBacklog != nil && *config.ReconcilerActiveBacklog < 0 {
+		return fmt.Errorf(
+			"reconciler active backlog %d must be >= 0",
+			*config.ReconcilerActiveBacklog,
+		)
+	}
+
+	switch config.ReconcilerBacklogMode {
+	case "", DropBacklogMode, BlockBacklogMode:
+	default:
+		return fmt.Errorf("%s is not a valid reconciler backlog mode", config.ReconcilerBacklogMode)
+	}
+
+	switch config.InactiveReconciliationOrder {
+	case "", SeenInactiveReconciliationOrder, BalanceInactiveReconciliationOrder:
+	default:
+		return fmt.Errorf(
+			"%s is not a valid inactive reconciliation order",
+			config.InactiveReconciliationOrder,
+		)
+	}
+
+	if config.BalanceWriteShards < 0 {
+		return fmt.Errorf("balance write shards %d must be >= 0", config.BalanceWriteShards)
+	}
+
+	if config.BalanceCacheSize < 0 {
+		return fmt.Errorf("balance cache size %d must be >= 0", config.BalanceCacheSize)
+	}
+
+	if len(config.SubscribedAccounts) > 0 && config.BalanceTrackingDisabled {
+		return errors.New("subscribed accounts cannot be populated when balance tracking is disabled")
+	}
+
+	if config.AccountFileReloadInterval > 0 && config.BalanceTrackingDisabled {
+		return errors.New("account files cannot be reloaded when balance tracking is disabled")
+	}
+
+	if err := assertAsserterMode(config); err != nil {
+		return fmt.Errorf("%w: invalid asserter mode", err)
+	}
+
+	switch config.SyncMode {
+	case "", PollingSyncMode, EventsSyncMode:
+	default:
+		return fmt.Errorf("%s is not a valid sync mode", config.SyncMode)
+	}
+
+	if config.OptionalWorkers != nil {
+		for _, worker := range config.OptionalWorkers.Workers {
+			switch worker {
+			case AccountCreationWorker, BlockHashVerificationWorker, FeeWorker,
+				TransactionFetchVerificationWorker, DuplicateHashWorker, BlockStreamWorker,
+				TimestampWorker, SupplyWorker:
+			default:
+				return fmt.Errorf("%s is not a valid optional worker", worker)
+			}
+		}
+	}
+
+	names := map[string]struct{}{}
+	for _, i := range config.Invariants {
+		if len(i.Name) == 0 {
+			return errors.New("invariant name must be populated")
+		}
+
+		if _, ok := names[i.Name]; ok {
+			return fmt.Errorf("invariant %s is defined more than once", i.Name)
+		}
+		names[i.Name] = struct{}{}
+
+		if _, err := invariant.New(i.Name, i.Scope, i.Condition, i.Assertion); err != nil {
+			return fmt.Errorf("%w: invalid invariant", err)
+		}
+	}
+
+	pluginNames := map[string]struct{}{}
+	for _, p := range config.Plugins {
+		if len(p.Name) == 0 {
+			return errors.New("plugin name must be populated")
+		}
+
+		if _, ok := pluginNames[p.Name]; ok {
+			return fmt.Errorf("plugin %s is defined more than once", p.Name)
+		}
+		pluginNames[p.Name] = struct{}{}
+
+		if len(p.Path) == 0 {
+			return fmt.Errorf("path of plugin %s must be populated", p.Name)
+		}
+	}
+
+	if config.EndConditions == nil {
+		return nil
+	}
+
+	if config.EndConditions.Index != nil {
+		if *config.EndConditions.Index < 0 {
+			return fmt.Errorf("end index %d cannot be negative", *config.EndConditions.Index)
+		}
+	}
+
+	if len(config.EndConditions.Expression) > 0 {
+		if _, err := invariant.Compile(config.EndConditions.Expression); err != nil {
+			return fmt.Errorf("%w: invalid end condition expression", err)
+		}
+	}
+
+	if config.EndConditions.ReconciliationCoverage != nil {
+		coverage := config.EndConditions.ReconciliationCoverage.Coverage
+		if coverage < 0 || coverage > 1 {
+			return fmt.Errorf("reconciliation coverage %f must be [0.0,1.0]", coverage)
+		}
+
+		index := config.EndConditions.ReconciliationCoverage.Index
+		if index != nil && *index < 0 {
+			return fmt.Errorf("reconciliation coverage height %d must be >= 0", *index)
+		}
+
+		accountCount := config.EndConditions.ReconciliationCoverage.AccountCount
+		if accountCount != nil && *accountCount < 0 {
+			return fmt.Errorf(
+				"reconciliation coverage account count %d must be >= 0",
+				*accountCount,
+			)
+		}
+
+		if config.BalanceTrackingDisabled {
+			return errors.New(
+				"balance tracking must be enabled for reconciliation coverage end condition",
+			)
+		}
+
+		if config.IgnoreReconciliationError {
+			return errors.New(
+				"reconciliation errors cannot be ignored for reconciliation coverage end condition",
+			)
+		}
+
+		if config.ReconciliationDisabled {
+			return errors.New(
+				"reconciliation cannot be disabled for reconciliation coverage end condition",
+			)
+		}
+	}
+
+	return nil
+}
+
+func assertBulkLoadIndex(config *DataConfiguration) error {
+	if config.BulkLoadIndex == nil {
+		return nil
+	}
+
+	index := *config.BulkLoadIndex
+	if index < 0 {
+		return fmt.Errorf("bulk load index %d cannot be negative", index)
+	}
+
+	if config.StartIndex != nil && *config.StartIndex > index {
+		return fmt.Errorf(
+			"start index %d cannot be greater than bulk load index %d",
+			*config.StartIndex,
+			index,
+		)
+	}
+
+	if config.EndConditions != nil && config.EndConditions.Index != nil &&
+		*config.EndConditions.Index < index {
+		return fmt.Errorf(
+			"end index %d cannot be less than bulk load index %d",
+			*config.EndConditions.Index,
+			index,
+		)
+	}
+
+	return nil
+}
+
+func assertFinality(config *Finality) error {
+	if config == nil {
+		return nil
+	}
+
+	switch config.Model {
+	case InstantFinality:
+	case ProbabilisticFinality:
+		if config.Depth <= 0 {
+			return fmt.Errorf("depth %d must be positive for probabilistic finality", config.Depth)
+		}
+	case EpochFinality:
+		if config.EpochLength <= 0 {
+			return fmt.Errorf(
+				"epoch length %d must be positive for epoch finality",
+				config.EpochLength,
+			)
+		}
+
+		if config.Epochs < 0 {
+			return fmt.Errorf("epochs %d cannot be negative", config.Epochs)
+		}
+	default:
+		return fmt.Errorf("%s is not a valid finality model", config.Model)
+	}
+
+	return nil
+}
+
+func assertReorgAlerts(config *ReorgAlerts, maxReorgDepth int) error {
+	if config == nil {
+		return nil
+	}
+
+	if config.MaxDepth < 0 {
+		return fmt.Errorf("max depth %d cannot be negative", config.MaxDepth)
+	}
+
+	if config.MaxDepth >= int64(maxReorgDepth) {
+		return fmt.Errorf(
+			"max depth %d must be less than max reorg depth %d",
+			config.MaxDepth,
+			maxReorgDepth,
+		)
+	}
+
+	if config.MaxOrphanRate < 0 || config.MaxOrphanRate > 1 {
+		return fmt.Errorf("max orphan rate %f must be [0.0,1.0]", config.MaxOrphanRate)
+	}
+
+	return nil
+}
+
+func assertQuorum(config *Quorum) error {
+	if config == nil {
+		return nil
+	}
+
+	if len(config.URLs) == 0 {
+		return errors.New("quorum urls must be populated")
+	}
+
+	for _, quorumURL := range config.URLs {
+		if err := assertURL(quorumURL); err != nil {
+			return fmt.Errorf("%w: invalid quorum url", err)
+		}
+	}
+
+	// online_url is always an endpoint.
+	endpoints := len(config.URLs) + 1
+	if config.Size < 2 || config.Size > endpoints {
+		return fmt.Errorf("quorum size %d must be [2,%d]", config.Size, endpoints)
+	}
+
+	return nil
+}
+
+func assertAsserterMode(config *DataConfiguration) error {
+	switch config.AsserterMode {
+	case "", StrictAsserterMode:
+		if len(config.AsserterWarnings) > 0 {
+			return errors.New("asserter warnings can only be populated in permissive mode")
+		}
+
+		return nil
+	case PermissiveAsserterMode:
+	default:
+		return fmt.Errorf("%s is not a valid asserter mode", config.AsserterMode)
+	}
+
+	// Blocks fetched from a quorum of endpoints are
+	// always fully asserted.
+	if config.Quorum != nil {
+		return errors.New("permissive mode cannot be used with quorum")
+	}
+
+	for _, warning := range config.AsserterWarnings {
+		switch warning {
+		case UnknownOperationStatusViolation, MissingTimestampViolation, ZeroAmountViolation:
+		default:
+			return fmt.Errorf("%s is not a valid asserter violation", warning)
+		}
+	}
+
+	return nil
+}
+
+func assertReconciliationTolerances(tolerances []*ReconciliationTolerance) error {
+	currencies := map[string]struct{}{}
+	for _, tolerance := range tolerances {
+		if tolerance == nil {
+			return errors.New("reconciliation tolerance cannot be nil")
+		}
+
+		if err := asserter.Currency(tolerance.Currency); err != nil {
+			return err
+		}
+
+		key := types.Hash(tolerance.Currency)
+		if _, ok := currencies[key]; ok {
+			return fmt.Errorf("duplicate tolerance for %s", types.PrintStruct(tolerance.Currency))
+		}
+		currencies[key] = struct{}{}
+
+		epsilon, ok := new(big.Int).SetString(tolerance.Epsilon, 10)
+		if !ok || epsilon.Sign() < 0 {
+			return fmt.Errorf(
+				"epsilon %s of %s must be a non-negative integer",
+				tolerance.Epsilon,
+				tolerance.Currency.Symbol,
+			)
+		}
+	}
+
+	return nil
+}
+
+func assertStatsd(config *Statsd) error {
+	if config == nil {
+		return nil
+	}
+
+	if len(config.Host) == 0 {
+		return errors.New("statsd host must be populated")
+	}
+
+	return nil
+}
+
+func assertTimestampValidation(config *TimestampValidation) error {
+	if config == nil {
+		return nil
+	}
+
+	if config.Tolerance < 0 {
+		return errors.New("tolerance must be non-negative")
+	}
+
+	if config.MaxFutureSkew < 0 {
+		return errors.New("max future skew must be non-negative")
+	}
+
+	if config.MaxTipLag < 0 {
+		return errors.New("max tip lag must be non-negative")
+	}
+
+	if config.TipWindow < 0 {
+		return errors.New("tip window must be non-negative")
+	}
+
+	return nil
+}
+
+// assertSupplyAmount ensures amount is empty
+// or a non-negative integer.
+func assertSupplyAmount(amount string, name string, symbol string) error {
+	if len(amount) == 0 {
+		return nil
+	}
+
+	value, ok := new(big.Int).SetString(amount, 10)
+	if !ok || value.Sign() < 0 {
+		return fmt.Errorf("%s %s of %s must be a non-negative integer", name, amount, symbol)
+	}
+
+	return nil
+}
+
+func assertSupplyCurrency(config *SupplyCurrency) error {
+	if err := asserter.Currency(config.Currency); err != nil {
+		return err
+	}
+
+	symbol := config.Currency.Symbol
+	if err := assertSupplyAmount(config.InitialSupply, "initial supply", symbol); err != nil {
+		return err
+	}
+
+	if err := assertSupplyAmount(config.Tolerance, "tolerance", symbol); err != nil {
+		return err
+	}
+
+	if (len(config.Schedule) > 0) == (len(config.CallMethod) > 0) {
+		return fmt.Errorf("exactly one of schedule or call method must be populated for %s", symbol)
+	}
+
+	lastStart := int64(-1)
+	for _, period := range config.Schedule {
+		if period == nil {
+			return errors.New("issuance period cannot be nil")
+		}
+
+		if period.StartIndex <= lastStart {
+			return fmt.Errorf(
+				"issuance periods of %s must have increasing start indexes",
+				symbol,
+			)
+		}
+		lastStart = period.StartIndex
+
+		if len(period.Reward) == 0 {
+			return fmt.Errorf("reward of issuance period of %s must be populated", symbol)
+		}
+
+		if err := assertSupplyAmount(period.Reward, "reward", symbol); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func assertSupplyTracking(config *SupplyTracking) error {
+	if config == nil {
+		return nil
+	}
+
+	if config.CheckpointInterval < 0 {
+		return fmt.Errorf("checkpoint interval %d must be >= 0", config.CheckpointInterval)
+	}
+
+	if len(config.Currencies) == 0 {
+		return errors.New("at least one currency must be populated")
+	}
+
+	currencies := map[string]struct{}{}
+	for _, currency := range config.Currencies {
+		if currency == nil {
+			return errors.New("supply currency cannot be nil")
+		}
+
+		if err := assertSupplyCurrency(currency); err != nil {
+			return err
+		}
+
+		key := types.Hash(currency.Currency)
+		if _, ok := currencies[key]; ok {
+			return fmt.Errorf("duplicate supply currency %s", types.PrintStruct(currency.Currency))
+		}
+		currencies[key] = struct{}{}
+	}
+
+	return nil
+}
+
+func assertBlockStream(config *BlockStream) error {
+	if config == nil {
+		return nil
+	}
+
+	switch config.Kind {
+	case KafkaBlockStream, NSQBlockStream:
+	default:
+		return fmt.Errorf("%s is not a valid block stream kind", config.Kind)
+	}
+
+	if len(config.URL) == 0 {
+		return errors.New("block stream url must be populated")
+	}
+
+	if len(config.Topic) == 0 {
+		return errors.New("block stream topic must be populated")
+	}
+
+	return nil
+}
+
+func assertArtifactUpload(config *ArtifactUpload) error {
+	if config == nil {
+		return nil
+	}
+
+	u, err := url.Parse(config.Destination)
+	if err != nil {
+		return fmt.Errorf("%w: unable to parse destination %s", err, config.Destination)
+	}
+
+	if u.Scheme != "s3" && u.Scheme != "gs" {
+		return fmt.Errorf("destination %s must start with s3:// or gs://", config.Destination)
+	}
+
+	if len(u.Host) == 0 {
+		return fmt.Errorf("destination %s must include a bucket", config.Destination)
+	}
+
+	return nil
+}
+
+func assertBlockArchive(config *BlockArchive, pruningDisabled bool) error {
+	if config == nil {
+		return nil
+	}
+
+	if config.SegmentSize < 0 {
+		return fmt.Errorf("segment size %d must be >= 0", config.SegmentSize)
+	}
+
+	if pruningDisabled {
+		return errors.New("pruning must be enabled to archive blocks")
+	}
+
+	return nil
+}
+
+func assertSyncRestarts(config *SyncRestarts) error {
+	if config == nil {
+		return nil
+	}
+
+	if config.MaxRestarts < 0 {
+		return fmt.Errorf("max restarts %d must be >= 0", config.MaxRestarts)
+	}
+
+	if config.MaxBackoff < config.InitialBackoff {
+		return fmt.Errorf(
+			"max backoff %d must be >= initial backoff %d",
+			config.MaxBackoff,
+			config.InitialBackoff,
+		)
+	}
+
+	for _, retryable := range config.RetryableErrors {
+		if len(retryable) == 0 {
+			return errors.New("retryable errors cannot be empty")
+		}
+	}
+
+	return nil
+}
+
+func assertDiskSpaceWatchdog(config *DiskSpaceWatchdog) error {
+	if config == nil {
+		return nil
+	}
+
+	if config.HaltThreshold == 0 {
+		return errors.New("halt threshold must be > 0")
+	}
+
+	if config.LowThreshold <= config.HaltThreshold {
+		return fmt.Errorf(
+			"low threshold %d must be > halt threshold %d",
+			config.LowThreshold,
+			config.HaltThreshold,
+		)
+	}
+
+	return nil
+}
+
+func assertRetryBackoff(config *RetryBackoff) error {
+	if config == nil {
+		return nil
+	}
+
+	if config.BaseBackoff == 0 {
+		return errors.New("base backoff must be > 0")
+	}
+
+	if config.MaxBackoff < config.BaseBackoff {
+		return fmt.Errorf(
+			"max backoff %d must be >= base backoff %d",
+			config.MaxBackoff,
+			config.BaseBackoff,
+		)
+	}
+
+	if config.Jitter < 0 || config.Jitter > 1 {
+		return fmt.Errorf("jitter %f must be [0.0,1.0]", config.Jitter)
+	}
+
+	return nil
+}
+
+func assertRateLimits(config *RateLimits) error {
+	if config == nil {
+		return nil
+	}
+
+	if config.Block < 0 {
+		return fmt.Errorf("block rate limit %f must be >= 0", config.Block)
+	}
+
+	if config.AccountBalance < 0 {
+		return fmt.Errorf("account balance rate limit %f must be >= 0", config.AccountBalance)
+	}
+
+	return nil
+}
+
+func assertEncryption(config *EncryptionConfiguration) error {
+	if config == nil {
+		return nil
+	}
+
+	if len(config.KeyFile) > 0 && len(config.KeyEnv) > 0 {
+		return errors.New("only one of key_file and key_env can be populated")
+	}
+
+	if len(config.KeyFile) == 0 && len(config.KeyEnv) == 0 {
+		return errors.New("key_file or key_env must be populated")
+	}
+
+	return nil
+}
+
+func assertChaos(config *ChaosConfiguration) error {
+	if config == nil {
+		return nil
+	}
+
+	rates := map[string]float64{
+		"timeout rate":            config.TimeoutRate,
+		"server error rate":       config.ServerErrorRate,
+		"truncated response rate": config.TruncatedResponseRate,
+		"truncated request rate":  config.TruncatedRequestRate,
+		"reorg rate":              config.ReorgRate,
+	}
+	for name, rate := range rates {
+		if rate < 0 || rate > 1 {
+			return fmt.Errorf("%s %f must be [0.0,1.0]", name, rate)
+		}
+	}
+
+	// At most one of these faults is injected into each request.
+	if sum := config.TimeoutRate +
+		config.ServerErrorRate +
+		config.TruncatedResponseRate; sum > 1 {
+		return fmt.Errorf(
+			"sum of timeout, server error, and truncated response rates %f must be <= 1.0",
+			sum,
+		)
+	}
+
+	return nil
+}
+
+// LoadTLSConfig returns the *tls.Config described by config
+// (or nil if config is nil).
+func LoadTLSConfig(config *TLSConfiguration) (*tls.Config, error) {
+	if config == nil {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: config.ServerName,
+	}
+
+	if len(config.CertFile) > 0 || len(config.KeyFile) > 0 {
+		if len(config.CertFile) == 0 || len(config.KeyFile) == 0 {
+			return nil, errors.New("cert file and key file must both be populated")
+		}
+
+		certificate, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to load client certificate", err)
+		}
+
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+
+	if len(config.CAFile) > 0 {
+		bundle, err := ioutil.ReadFile(config.CAFile) // #nosec G304
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to read ca file", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(bundle) {
+			return nil, fmt.Errorf("no certificates found in ca file %s", config.CAFile)
+		}
+
+		tlsConfig.RootCAs = pool
+	}
+
+	return tlsConfig, nil
+}
+
+// AssertLogging ensures all log levels and modules in
+// config are supported.
+func AssertLogging(config *LoggingConfiguration) error {
+	if config == nil {
+		return nil
+	}
+
+	if len(config.Level) > 0 {
+		if err := assertLogLevel(config.Level); err != nil {
+			return err
+		}
+	}
+
+	for module, level := range config.Modules {
+		switch module {
+		case SyncerLogModule, StorageLogModule, ReconcilerLogModule,
+			FetcherLogModule, BroadcastLogModule:
+		default:
+			return fmt.Errorf("%s is not a valid log module", module)
+		}
+
+		if err := assertLogLevel(level); err != nil {
+			return fmt.Errorf("%w: invalid level for %s", err, module)
+		}
+	}
+
+	return nil
+}
+
+func assertLogLevel(level LogLevel) error {
+	switch level {
+	case DebugLogLevel, InfoLogLevel, WarnLogLevel, ErrorLogLevel:
+		return nil
+	default:
+		return fmt.Errorf("%s is not a valid log level", level)
+	}
+}
+
+// assertURL ensures rawURL is the absolute
+// http(s) URL of a Rosetta API implementation.
+func assertURL(rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("%w: unable to parse url %s", err, rawURL)
+	}
+
+	switch parsed.Scheme {
+	case "http", "https":
+	default:
+		return fmt.Errorf("url %s scheme %s is not supported", rawURL, parsed.Scheme)
+	}
+
+	if len(parsed.Host) == 0 {
+		return fmt.Errorf("url %s has no host", rawURL)
+	}
+
+	return nil
+}
+
+func assertHTTP(config *HTTPConfiguration) error {
+	if config == nil {
+		return nil
+	}
+
+	if len(config.ProxyURL) > 0 {
+		proxyURL, err := url.Parse(config.ProxyURL)
+		if err != nil {
+			return fmt.Errorf("%w: unable to parse proxy url", err)
+		}
+
+		switch proxyURL.Scheme {
+		case "http", "https", "socks5":
+		default:
+			return fmt.Errorf("proxy url scheme %s is not supported", proxyURL.Scheme)
+		}
+
+		if len(proxyURL.Host) == 0 {
+			return fmt.Errorf("proxy url %s has no host", config.ProxyURL)
+		}
+	}
+
+	headers := map[string]struct{}{}
+	for header := range config.Headers {
+		if len(header) == 0 {
+			return errors.New("header name must be populated")
+		}
+
+		headers[http.CanonicalHeaderKey(header)] = struct{}{}
+	}
+
+	if len(config.BearerToken) > 0 {
+		if _, ok := headers["Authorization"]; ok {
+			return errors.New("bearer token cannot be used with an Authorization header")
+		}
+	}
+
+	if config.APIKey != nil {
+		if len(config.APIKey.Header) == 0 || len(config.APIKey.Value) == 0 {
+			return errors.New("api key header and value must be populated")
+		}
+
+		if _, ok := headers[http.CanonicalHeaderKey(config.APIKey.Header)]; ok {
+			return fmt.Errorf("api key header %s is also in headers", config.APIKey.Header)
+		}
+
+		if len(config.BearerToken) > 0 &&
+			http.CanonicalHeaderKey(config.APIKey.Header) == "Authorization" {
+			return errors.New("bearer token cannot be used with an Authorization api key")
+		}
+	}
+
+	if _, err := LoadTLSConfig(config.TLS); err != nil {
+		return fmt.Errorf("%w: invalid tls configuration", err)
+	}
+
+	return nil
+}
+
+func assertConfiguration(ctx context.Context, config *Configuration) error {
+	if err := asserter.NetworkIdentifier(config.Network); err != nil {
+		return fmt.Errorf("%w: invalid network identifier", err)
+	}
+
+	if config.SyncCacheSize < 0 {
+		return fmt.Errorf("sync cache size %d must be non-negative", config.SyncCacheSize)
+	}
+
+	if err := assertURL(config.OnlineURL); err != nil {
+		return fmt.Errorf("%w: invalid online url", err)
+	}
+
+	if err := assertRetryBackoff(config.RetryBackoff); err != nil {
+		return fmt.Errorf("%w: invalid retry backoff", err)
+	}
+
+	if err := assertRateLimits(config.RateLimits); err != nil {
+		return fmt.Errorf("%w: invalid rate limits", err)
+	}
+
+	if err := assertEncryption(config.Encryption); err != nil {
+		return fmt.Errorf("%w: invalid encryption configuration", err)
+	}
+
+	if err := assertHTTP(config.HTTP); err != nil {
+		return fmt.Errorf("%w: invalid http configuration", err)
+	}
+
+	if err := AssertLogging(config.Logging); err != nil {
+		return fmt.Errorf("%w: invalid logging configuration", err)
+	}
+
+	if err := assertChaos(config.Chaos); err != nil {
+		return fmt.Errorf("%w: invalid chaos configuration", err)
+	}
+
+	if err := assertFinality(config.Finality); err != nil {
+		return fmt.Errorf("%w: invalid finality", err)
+	}
+
+	if err := assertReorgAlerts(config.Data.ReorgAlerts, config.MaxReorgDepth); err != nil {
+		return fmt.Errorf("%w: invalid reorg alerts", err)
+	}
+
+	if err := assertQuorum(config.Data.Quorum); err != nil {
+		return fmt.Errorf("%w: invalid quorum", err)
+	}
+
+	if err := assertStatsd(config.Data.Statsd); err != nil {
+		return fmt.Errorf("%w: invalid statsd", err)
+	}
+
+	if err := assertTimestampValidation(config.Data.TimestampValidation); err != nil {
+		return fmt.Errorf("%w: invalid timestamp validation", err)
+	}
+
+	if err := assertSupplyTracking(config.Data.SupplyTracking); err != nil {
+		return fmt.Errorf("%w: invalid supply tracking", err)
+	}
+
+	if err := assertBlockStream(config.Data.BlockStream); err != nil {
+		return fmt.Errorf("%w: invalid block stream", err)
+	}
+
+	if err := assertArtifactUpload(config.Data.ArtifactUpload); err != nil {
+		return fmt.Errorf("%w: invalid artifact upload", err)
+	}
+
+	if err := assertBlockArchive(
+		config.Data.BlockArchive,
+		config.Data.PruningDisabled,
+	); err != nil {
+		return fmt.Errorf("%w: invalid block archive", err)
+	}
+
+	if err := assertSyncRestarts(config.Data.SyncRestarts); err != nil {
+		return fmt.Errorf("%w: invalid sync restarts", err)
+	}
+
+	if err := assertDiskSpaceWatchdog(config.Data.DiskSpaceWatchdog); err != nil {
+		return fmt.Errorf("%w: invalid disk space watchdog", err)
+	}
+
+	if err := assertDataConfiguration(config.Data); err != nil {
+		return fmt.Errorf("%w: invalid data configuration", err)
+	}
+
+	if err := assertConstructionConfiguration(ctx, config.Construction, config.Network); err != nil {
+		return fmt.Errorf("%w: invalid construction configuration", err)
+	}
+
+	return nil
+}
+
+// modifyFilePaths modifies a collection of filepaths in a *Configuration
+// file to make them relative to the configuration file (this makes it a lot easier
+// to store all config-related files in the same directory and to run the rosetta-cli
+// from a different directory).
+func modifyFilePaths(config *Configuration, fileDir string) {
+	if config.Data != nil {
+		for _, plugin := range config.Data.Plugins {
+			plugin.Path = executablePath(fileDir, plugin.Path)
+		}
+
+		if len(config.Data.BootstrapBalances) > 0 {
+			config.Data.BootstrapBalances = path.Join(fileDir, config.Data.BootstrapBalances)
+		}
+
+		if len(config.Data.InterestingAccounts) > 0 {
+			config.Data.InterestingAccounts = path.Join(fileDir, config.Data.InterestingAccounts)
+		}
+
+		if len(config.Data.SubscribedAccounts) > 0 {
+			config.Data.SubscribedAccounts = path.Join(fileDir, config.Data.SubscribedAccounts)
+		}
+	}
+
+	if config.HTTP != nil && config.HTTP.TLS != nil {
+		tlsConfig := config.HTTP.TLS
+		for _, filePath := range []*string{
+			&tlsConfig.CertFile,
+			&tlsConfig.KeyFile,
+			&tlsConfig.CAFile,
+		} {
+			if len(*filePath) > 0 {
+				*filePath = path.Join(fileDir, *filePath)
+			}
+		}
+	}
+
+	if config.Encryption != nil && len(config.Encryption.KeyFile) > 0 {
+		config.Encryption.KeyFile = path.Join(fileDir, config.Encryption.KeyFile)
+	}
+
+	if config.Construction != nil {
+		if len(config.Construction.ConstructorDSLFile) > 0 {
+			config.Construction.ConstructorDSLFile = path.Join(
+				fileDir,
+				config.Construction.ConstructorDSLFile,
+			)
+		}
+
+		if len(config.Construction.WorkflowsDirectory) > 0 {
+			config.Construction.WorkflowsDirectory = path.Join(
+				fileDir,
+				config.Construction.WorkflowsDirectory,
+			)
+		}
+
+		if config.Construction.RemoteSigner != nil {
+			remoteSigner := config.Construction.RemoteSigner
+			remoteSigner.Path = executablePath(fileDir, remoteSigner.Path)
+		}
+
+		for _, plugin := range config.Construction.CurvePlugins {
+			plugin.Path = executablePath(fileDir, plugin.Path)
+		}
+	}
+}
+
+// executablePath returns the path of an executable relative to
+// the configuration file. Executables are usually installed
+// elsewhere, so absolute paths are returned as-is.
+func executablePath(fileDir string, filePath string) string {
+	if len(filePath) == 0 || path.IsAbs(filePath) {
+		return filePath
+	}
+
+	return path.Join(fileDir, filePath)
+}
+
+// LoadConfiguration returns a parsed and asserted Configuration for running
+// tests.
+func LoadConfiguration(ctx context.Context, filePath string) (*Configuration, error) {
+	return LoadProfile(ctx, filePath, "")
+}
+
+// LoadProfile returns a parsed and asserted Configuration for running
+// tests with the profile named profile applied. If profile is empty
+// (or AllProfiles), no profile is applied.
+func LoadProfile(ctx context.Context, filePath string, profile string) (*Configuration, error) {
+	var configRaw Configuration
+	if err := loadFile(filePath, &configRaw); err != nil {
+		return nil, fmt.Errorf("%w: unable to open configuration file", err)
+	}
+
+	if err := assertProfiles(&configRaw); err != nil {
+		return nil, fmt.Errorf("%w: invalid profiles", err)
+	}
+
+	if len(profile) > 0 && profile != AllProfiles {
+		if err := applyProfile(&configRaw, profile); err != nil {
+			return nil, fmt.Errorf("%w: unable to apply profile", err)
+		}
+	}
+
+	if err := ApplyEnvironmentOverrides(&configRaw); err != nil {
+		return nil, fmt.Errorf("%w: unable to apply environment overrides", err)
+	}
+
+	config := populateMissingFields(&configRaw)
+
+	// Get the configuration file directory so we can load all files
+	// relative to the location of the configuration file.
+	fileDir := path.Dir(filePath)
+	modifyFilePaths(config, fileDir)
+
+	if err := assertConfiguration(ctx, config); err != nil {
+		return nil, fmt.Errorf("%w: invalid configuration", err)
+	}
+
+	if len(config.Profile) > 0 {
+		color.Cyan("loaded configuration file: %s (profile: %s)\n", filePath, config.Profile)
+	} else {
+		color.Cyan("loaded configuration file: %s\n", filePath)
+	}
 
 	if config.LogConfiguration {
 		log.Println(types.PrettyPrintStruct(config))
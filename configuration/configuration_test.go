@@ -20,6 +20,8 @@ import (
 	"path"
 	"testing"
 
+	"github.com/coinbase/rosetta-cli/pkg/invariant"
+
 	"github.com/coinbase/rosetta-sdk-go/constructor/job"
 	"github.com/coinbase/rosetta-sdk-go/storage"
 	"github.com/coinbase/rosetta-sdk-go/types"
@@ -28,13 +30,14 @@ import (
 )
 
 var (
-	startIndex        = int64(89)
-	badStartIndex     = int64(-10)
-	goodCoverage      = float64(0.33)
-	badCoverage       = float64(-2)
-	endTip            = false
-	historicalEnabled = true
-	fakeWorkflows     = []*job.Workflow{
+	startIndex         = int64(89)
+	badStartIndex      = int64(-10)
+	goodCoverage       = float64(0.33)
+	badCoverage        = float64(-2)
+	endTip             = false
+	historicalEnabled  = true
+	historicalDisabled = false
+	fakeWorkflows      = []*job.Workflow{
 		{
 			Name:        string(job.CreateAccount),
 			Concurrency: job.ReservedWorkflowConcurrency,
@@ -66,6 +69,7 @@ var (
 		HTTPTimeout:          21,
 		MaxRetries:           1000,
 		MaxSyncConcurrency:   12,
+		SyncCacheSize:        1 << 20,
 		TipDelay:             1231,
 		MaxReorgDepth:        12,
 		Construction: &ConstructionConfiguration{
@@ -143,10 +147,65 @@ var (
 	}
 )
 
+func TestFinality(t *testing.T) {
+	var tests = map[string]struct {
+		finality *Finality
+		head     int64
+
+		expectedFinalized int64
+		expectedMaxDepth  int64
+	}{
+		"instant": {
+			finality:          &Finality{Model: InstantFinality},
+			head:              10,
+			expectedFinalized: 10,
+			expectedMaxDepth:  0,
+		},
+		"probabilistic": {
+			finality:          &Finality{Model: ProbabilisticFinality, Depth: 6},
+			head:              10,
+			expectedFinalized: 4,
+			expectedMaxDepth:  6,
+		},
+		"probabilistic (none final)": {
+			finality:          &Finality{Model: ProbabilisticFinality, Depth: 6},
+			head:              5,
+			expectedFinalized: -1,
+			expectedMaxDepth:  6,
+		},
+		"epoch": {
+			finality:          &Finality{Model: EpochFinality, EpochLength: 32, Epochs: 2},
+			head:              100,
+			expectedFinalized: 31,
+			expectedMaxDepth:  96,
+		},
+		"epoch (start of epoch)": {
+			finality:          &Finality{Model: EpochFinality, EpochLength: 32, Epochs: 2},
+			head:              96,
+			expectedFinalized: 31,
+			expectedMaxDepth:  96,
+		},
+		"epoch (none final)": {
+			finality:          &Finality{Model: EpochFinality, EpochLength: 32, Epochs: 2},
+			head:              95,
+			expectedFinalized: -1,
+			expectedMaxDepth:  96,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.expectedFinalized, test.finality.FinalizedIndex(test.head))
+			assert.Equal(t, test.expectedMaxDepth, test.finality.MaxDepth())
+		})
+	}
+}
+
 func TestLoadConfiguration(t *testing.T) {
 	var (
 		goodAccountCount = int64(10)
 		badAccountCount  = int64(-10)
+		negativeBacklog  = -1
 	)
 	var tests = map[string]struct {
 		provided *Configuration
@@ -239,6 +298,225 @@ func TestLoadConfiguration(t *testing.T) {
 				return cfg
 			}(),
 		},
+		"quick mode": {
+			provided: &Configuration{
+				Data: &DataConfiguration{
+					QuickMode: true,
+				},
+			},
+			expected: func() *Configuration {
+				cfg := DefaultConfiguration()
+				cfg.Data.QuickMode = true
+				cfg.Data.BalanceTrackingDisabled = true
+				cfg.Data.CoinTrackingDisabled = true
+				cfg.Data.ReconciliationDisabled = true
+
+				return cfg
+			}(),
+		},
+		"quick mode with reconciliation coverage": {
+			provided: &Configuration{
+				Data: &DataConfiguration{
+					QuickMode: true,
+					EndConditions: &DataEndConditions{
+						ReconciliationCoverage: &ReconciliationCoverage{Coverage: 0.5},
+					},
+				},
+			},
+			err: true,
+		},
+		"invalid sync cache size": {
+			provided: &Configuration{
+				SyncCacheSize: -1,
+			},
+			err: true,
+		},
+		"invalid retry backoff (missing base backoff)": {
+			provided: &Configuration{
+				RetryBackoff: &RetryBackoff{Jitter: 0.5},
+			},
+			err: true,
+		},
+		"invalid retry backoff (jitter)": {
+			provided: &Configuration{
+				RetryBackoff: &RetryBackoff{BaseBackoff: 100, Jitter: 1.5},
+			},
+			err: true,
+		},
+		"invalid rate limits": {
+			provided: &Configuration{
+				RateLimits: &RateLimits{Block: 10, AccountBalance: -1},
+			},
+			err: true,
+		},
+		"invalid encryption configuration (no key)": {
+			provided: &Configuration{
+				Encryption: &EncryptionConfiguration{},
+			},
+			err: true,
+		},
+		"invalid encryption configuration (multiple keys)": {
+			provided: &Configuration{
+				Encryption: &EncryptionConfiguration{
+					KeyFile: "key.txt",
+					KeyEnv:  "ROSETTA_ENCRYPTION_KEY",
+				},
+			},
+			err: true,
+		},
+		"valid chaos configuration": {
+			provided: &Configuration{
+				Chaos: &ChaosConfiguration{
+					TimeoutRate:     0.01,
+					ServerErrorRate: 0.05,
+					ReorgRate:       0.1,
+				},
+			},
+			expected: func() *Configuration {
+				cfg := DefaultConfiguration()
+				cfg.Chaos = &ChaosConfiguration{
+					TimeoutRate:     0.01,
+					ServerErrorRate: 0.05,
+					ReorgRate:       0.1,
+					TimeoutDelay:    DefaultChaosTimeoutDelay,
+				}
+
+				return cfg
+			}(),
+		},
+		"invalid chaos configuration (rate)": {
+			provided: &Configuration{
+				Chaos: &ChaosConfiguration{ReorgRate: 1.5},
+			},
+			err: true,
+		},
+		"invalid chaos configuration (sum of rates)": {
+			provided: &Configuration{
+				Chaos: &ChaosConfiguration{
+					TimeoutRate:           0.5,
+					ServerErrorRate:       0.5,
+					TruncatedResponseRate: 0.5,
+				},
+			},
+			err: true,
+		},
+		"valid http configuration": {
+			provided: &Configuration{
+				HTTP: &HTTPConfiguration{
+					ProxyURL:    "http://proxy:3128",
+					Headers:     map[string]string{"X-Client": "rosetta-cli"},
+					BearerToken: "token",
+					APIKey:      &APIKey{Header: "X-API-Key", Value: "key"},
+				},
+			},
+			expected: func() *Configuration {
+				cfg := DefaultConfiguration()
+				cfg.HTTP = &HTTPConfiguration{
+					ProxyURL:    "http://proxy:3128",
+					Headers:     map[string]string{"X-Client": "rosetta-cli"},
+					BearerToken: "token",
+					APIKey:      &APIKey{Header: "X-API-Key", Value: "key"},
+				}
+
+				return cfg
+			}(),
+		},
+		"invalid http configuration (proxy scheme)": {
+			provided: &Configuration{
+				HTTP: &HTTPConfiguration{ProxyURL: "ftp://proxy:21"},
+			},
+			err: true,
+		},
+		"invalid http configuration (duplicate authorization)": {
+			provided: &Configuration{
+				HTTP: &HTTPConfiguration{
+					Headers:     map[string]string{"authorization": "Basic abc"},
+					BearerToken: "token",
+				},
+			},
+			err: true,
+		},
+		"invalid http configuration (missing api key value)": {
+			provided: &Configuration{
+				HTTP: &HTTPConfiguration{APIKey: &APIKey{Header: "X-API-Key"}},
+			},
+			err: true,
+		},
+		"invalid tls configuration (missing key file)": {
+			provided: &Configuration{
+				HTTP: &HTTPConfiguration{
+					TLS: &TLSConfiguration{CertFile: "client.pem"},
+				},
+			},
+			err: true,
+		},
+		"invalid tls configuration (missing ca file)": {
+			provided: &Configuration{
+				HTTP: &HTTPConfiguration{
+					TLS: &TLSConfiguration{CAFile: "missing.pem"},
+				},
+			},
+			err: true,
+		},
+		"probabilistic finality": {
+			provided: &Configuration{
+				MaxReorgDepth: 50,
+				Finality:      &Finality{Model: ProbabilisticFinality, Depth: 12},
+			},
+			expected: func() *Configuration {
+				cfg := DefaultConfiguration()
+				cfg.MaxReorgDepth = 12
+				cfg.Finality = &Finality{Model: ProbabilisticFinality, Depth: 12}
+
+				return cfg
+			}(),
+		},
+		"instant finality": {
+			provided: &Configuration{
+				Finality: &Finality{Model: InstantFinality},
+			},
+			expected: func() *Configuration {
+				cfg := DefaultConfiguration()
+				cfg.MaxReorgDepth = 1
+				cfg.Finality = &Finality{Model: InstantFinality}
+
+				return cfg
+			}(),
+		},
+		"epoch finality": {
+			provided: &Configuration{
+				Finality: &Finality{Model: EpochFinality, EpochLength: 32},
+			},
+			expected: func() *Configuration {
+				cfg := DefaultConfiguration()
+				cfg.MaxReorgDepth = 96
+				cfg.Finality = &Finality{
+					Model:       EpochFinality,
+					EpochLength: 32,
+					Epochs:      DefaultFinalityEpochs,
+				}
+
+				return cfg
+			}(),
+		},
+		"invalid finality (model)": {
+			provided: &Configuration{
+				Finality: &Finality{Model: "eventual"},
+			},
+			err: true,
+		},
+		"invalid finality (missing depth)": {
+			provided: &Configuration{
+				Finality: &Finality{Model: ProbabilisticFinality},
+			},
+			err: true,
+		},
+		"invalid finality (missing epoch length)": {
+			provided: &Configuration{
+				Finality: &Finality{Model: EpochFinality, Epochs: 1},
+			},
+			err: true,
+		},
 		"invalid network": {
 			provided: invalidNetwork,
 			err:      true,
@@ -255,151 +533,813 @@ func TestLoadConfiguration(t *testing.T) {
 			provided: invalidEndIndex,
 			err:      true,
 		},
-		"invalid reconciliation coverage": {
-			provided: invalidReconciliationCoverage,
-			err:      true,
+		"invalid bulk load index (negative)": {
+			provided: &Configuration{
+				Data: &DataConfiguration{
+					BulkLoadIndex: func() *int64 {
+						i := int64(-1)
+						return &i
+					}(),
+				},
+			},
+			err: true,
 		},
-		"invalid reconciliation coverage (reconciliation disabled)": {
+		"invalid bulk load index (before start index)": {
 			provided: &Configuration{
 				Data: &DataConfiguration{
-					ReconciliationDisabled: true,
-					EndConditions: &DataEndConditions{
-						ReconciliationCoverage: &ReconciliationCoverage{
-							Coverage: goodCoverage,
-						},
-					},
+					StartIndex: func() *int64 {
+						i := int64(20)
+						return &i
+					}(),
+					BulkLoadIndex: func() *int64 {
+						i := int64(10)
+						return &i
+					}(),
 				},
 			},
 			err: true,
 		},
-		"invalid reconciliation coverage (balance tracking disabled)": {
+		"invalid bulk load index (after end index)": {
 			provided: &Configuration{
 				Data: &DataConfiguration{
-					BalanceTrackingDisabled: true,
+					BulkLoadIndex: func() *int64 {
+						i := int64(10)
+						return &i
+					}(),
 					EndConditions: &DataEndConditions{
-						ReconciliationCoverage: &ReconciliationCoverage{
-							Coverage: goodCoverage,
-						},
+						Index: func() *int64 {
+							i := int64(5)
+							return &i
+						}(),
 					},
 				},
 			},
 			err: true,
 		},
-		"invalid reconciliation coverage (ignore reconciliation error)": {
+		"invalid end condition expression": {
 			provided: &Configuration{
 				Data: &DataConfiguration{
-					IgnoreReconciliationError: true,
 					EndConditions: &DataEndConditions{
-						ReconciliationCoverage: &ReconciliationCoverage{
-							Coverage: goodCoverage,
-						},
+						Expression: "counters.blocks > 100000 &&",
 					},
 				},
 			},
 			err: true,
 		},
-		"valid reconciliation coverage (with account count)": {
+		"invalid account creation (no operation types)": {
 			provided: &Configuration{
 				Data: &DataConfiguration{
-					EndConditions: &DataEndConditions{
-						ReconciliationCoverage: &ReconciliationCoverage{
-							Coverage:     goodCoverage,
-							AccountCount: &goodAccountCount,
-							Index:        &goodAccountCount,
-						},
-					},
+					AccountCreation: &AccountCreation{},
 				},
 			},
-			expected: func() *Configuration {
-				cfg := DefaultConfiguration()
-				cfg.Data.EndConditions = &DataEndConditions{
-					ReconciliationCoverage: &ReconciliationCoverage{
-						Coverage:     goodCoverage,
-						AccountCount: &goodAccountCount,
-						Index:        &goodAccountCount,
-					},
-				}
-
-				return cfg
-			}(),
+			err: true,
 		},
-		"invalid reconciliation coverage (with account count)": {
+		"invalid fee validation (no operation types)": {
 			provided: &Configuration{
 				Data: &DataConfiguration{
-					EndConditions: &DataEndConditions{
-						ReconciliationCoverage: &ReconciliationCoverage{
-							Coverage:     goodCoverage,
-							AccountCount: &badAccountCount,
-						},
-					},
+					FeeValidation: &FeeValidation{MetadataPath: "fee"},
 				},
 			},
 			err: true,
 		},
-		"invalid reconciliation coverage (with index)": {
+		"invalid asserter refresh index": {
 			provided: &Configuration{
 				Data: &DataConfiguration{
-					EndConditions: &DataEndConditions{
-						ReconciliationCoverage: &ReconciliationCoverage{
-							Coverage: goodCoverage,
-							Index:    &badAccountCount,
-						},
+					AsserterRefresh: &AsserterRefresh{
+						Indices: []int64{10, badStartIndex},
 					},
 				},
 			},
 			err: true,
 		},
-		"empty workflows": {
+		"invalid balance interpolation (historical balance disabled)": {
 			provided: &Configuration{
-				Construction: &ConstructionConfiguration{
-					Workflows: []*job.Workflow{},
+				Data: &DataConfiguration{
+					BalanceInterpolationSamples: 5,
+					HistoricalBalanceEnabled:    &historicalDisabled,
 				},
 			},
 			err: true,
 		},
-		"non-existent dsl file": {
+		"invalid checkpoint balances (initial balance fetch disabled)": {
 			provided: &Configuration{
-				Construction: &ConstructionConfiguration{
-					ConstructorDSLFile: "blah.ros",
+				Data: &DataConfiguration{
+					CheckpointBalances:          true,
+					InitialBalanceFetchDisabled: true,
 				},
 			},
 			err: true,
 		},
-		"multiple end conditions": {
-			provided: multipleEndConditions,
-			expected: func() *Configuration {
-				def := DefaultConfiguration()
-				def.Data.EndConditions = multipleEndConditions.Data.EndConditions
-
-				return def
-			}(),
-			err: false,
+		"invalid reconciliation failure budget (errors ignored)": {
+			provided: &Configuration{
+				Data: &DataConfiguration{
+					ReconciliationFailureBudget: 10,
+					IgnoreReconciliationError:   true,
+				},
+			},
+			err: true,
 		},
-	}
-
-	for name, test := range tests {
-		t.Run(name, func(t *testing.T) {
-			dir, err := utils.CreateTempDir()
-			assert.NoError(t, err)
-			defer utils.RemoveTempDir(dir)
-
-			filePath := path.Join(dir, "test.json")
-			err = utils.SerializeAndWrite(filePath, test.provided)
-			assert.NoError(t, err)
-
-			// Copy test.ros to temp dir
-			cmd := exec.Command("cp", "testdata/test.ros", path.Join(dir, "test.ros"))
-			assert.NoError(t, cmd.Run())
-
-			// Check if expected fields populated
-			config, err := LoadConfiguration(context.Background(), filePath)
-			if test.err {
-				assert.Error(t, err)
-				assert.Nil(t, config)
-			} else {
-				assert.NoError(t, err)
-
-				// Ensure test.ros expected file path is right
+		"invalid reorg alerts (max depth)": {
+			provided: &Configuration{
+				MaxReorgDepth: 10,
+				Data: &DataConfiguration{
+					ReorgAlerts: &ReorgAlerts{MaxDepth: 10},
+				},
+			},
+			err: true,
+		},
+		"invalid reorg alerts (max orphan rate)": {
+			provided: &Configuration{
+				Data: &DataConfiguration{
+					ReorgAlerts: &ReorgAlerts{MaxOrphanRate: 2},
+				},
+			},
+			err: true,
+		},
+		"invalid currency filter (tracked and ignored)": {
+			provided: &Configuration{
+				Data: &DataConfiguration{
+					TrackedCurrencies: []*types.Currency{{Symbol: "BTC", Decimals: 8}},
+					IgnoredCurrencies: []*types.Currency{{Symbol: "SPAM", Decimals: 0}},
+				},
+			},
+			err: true,
+		},
+		"invalid currency filter (missing symbol)": {
+			provided: &Configuration{
+				Data: &DataConfiguration{
+					IgnoredCurrencies: []*types.Currency{{Decimals: 8}},
+				},
+			},
+			err: true,
+		},
+		"invalid quorum (missing urls)": {
+			provided: &Configuration{
+				Data: &DataConfiguration{
+					Quorum: &Quorum{Size: 2},
+				},
+			},
+			err: true,
+		},
+		"invalid quorum (size)": {
+			provided: &Configuration{
+				Data: &DataConfiguration{
+					Quorum: &Quorum{URLs: []string{"http://node-2"}, Size: 3},
+				},
+			},
+			err: true,
+		},
+		"invalid statsd (missing host)": {
+			provided: &Configuration{
+				Data: &DataConfiguration{
+					Statsd: &Statsd{Port: 8125},
+				},
+			},
+			err: true,
+		},
+		"invalid timestamp validation (tolerance)": {
+			provided: &Configuration{
+				Data: &DataConfiguration{
+					TimestampValidation: &TimestampValidation{
+						Tolerance: -1,
+					},
+				},
+			},
+			err: true,
+		},
+		"invalid block stream (kind)": {
+			provided: &Configuration{
+				Data: &DataConfiguration{
+					BlockStream: &BlockStream{
+						Kind:  "rabbitmq",
+						URL:   "http://localhost:4151",
+						Topic: "blocks",
+					},
+				},
+			},
+			err: true,
+		},
+		"invalid artifact upload (scheme)": {
+			provided: &Configuration{
+				Data: &DataConfiguration{
+					ArtifactUpload: &ArtifactUpload{Destination: "https://bucket/prefix"},
+				},
+			},
+			err: true,
+		},
+		"invalid artifact upload (bucket)": {
+			provided: &Configuration{
+				Data: &DataConfiguration{
+					ArtifactUpload: &ArtifactUpload{Destination: "s3:///prefix"},
+				},
+			},
+			err: true,
+		},
+		"invalid supply tracking (no source)": {
+			provided: &Configuration{
+				Data: &DataConfiguration{
+					SupplyTracking: &SupplyTracking{
+						Currencies: []*SupplyCurrency{
+							{Currency: &types.Currency{Symbol: "BTC", Decimals: 8}},
+						},
+					},
+				},
+			},
+			err: true,
+		},
+		"invalid supply tracking (schedule order)": {
+			provided: &Configuration{
+				Data: &DataConfiguration{
+					SupplyTracking: &SupplyTracking{
+						Currencies: []*SupplyCurrency{
+							{
+								Currency: &types.Currency{Symbol: "BTC", Decimals: 8},
+								Schedule: []*IssuancePeriod{
+									{StartIndex: 210000, Reward: "2500000000"},
+									{StartIndex: 0, Reward: "5000000000"},
+								},
+							},
+						},
+					},
+				},
+			},
+			err: true,
+		},
+		"invalid supply tracking (tolerance)": {
+			provided: &Configuration{
+				Data: &DataConfiguration{
+					SupplyTracking: &SupplyTracking{
+						Currencies: []*SupplyCurrency{
+							{
+								Currency:   &types.Currency{Symbol: "ETH", Decimals: 18},
+								CallMethod: "supply",
+								Tolerance:  "-1",
+							},
+						},
+					},
+				},
+			},
+			err: true,
+		},
+		"invalid block archive (segment size)": {
+			provided: &Configuration{
+				Data: &DataConfiguration{
+					BlockArchive: &BlockArchive{SegmentSize: -1},
+				},
+			},
+			err: true,
+		},
+		"invalid block archive (pruning disabled)": {
+			provided: &Configuration{
+				Data: &DataConfiguration{
+					PruningDisabled: true,
+					BlockArchive:    &BlockArchive{},
+				},
+			},
+			err: true,
+		},
+		"invalid sync restarts (max backoff)": {
+			provided: &Configuration{
+				Data: &DataConfiguration{
+					SyncRestarts: &SyncRestarts{InitialBackoff: 600},
+				},
+			},
+			err: true,
+		},
+		"invalid disk space watchdog (thresholds)": {
+			provided: &Configuration{
+				Data: &DataConfiguration{
+					DiskSpaceWatchdog: &DiskSpaceWatchdog{LowThreshold: 1024, HaltThreshold: 2048},
+				},
+			},
+			err: true,
+		},
+		"invalid optional worker": {
+			provided: &Configuration{
+				Data: &DataConfiguration{
+					OptionalWorkers: &OptionalWorkers{
+						Workers: []OptionalWorker{AccountCreationWorker, "balance"},
+					},
+				},
+			},
+			err: true,
+		},
+		"invalid invariant": {
+			provided: &Configuration{
+				Data: &DataConfiguration{
+					Invariants: []*Invariant{
+						{
+							Name:      "max operations",
+							Scope:     invariant.TransactionScope,
+							Assertion: "operations.# <=",
+						},
+					},
+				},
+			},
+			err: true,
+		},
+		"duplicate invariant": {
+			provided: &Configuration{
+				Data: &DataConfiguration{
+					Invariants: []*Invariant{
+						{
+							Name:      "max operations",
+							Scope:     invariant.TransactionScope,
+							Assertion: "operations.# <= 10000",
+						},
+						{
+							Name:      "max operations",
+							Scope:     invariant.BlockScope,
+							Assertion: "transactions.# <= 10000",
+						},
+					},
+				},
+			},
+			err: true,
+		},
+		"negative reconciliation dump blocks": {
+			provided: &Configuration{
+				Data: &DataConfiguration{
+					ReconciliationDumpBlocks: -1,
+				},
+			},
+			err: true,
+		},
+		"invalid reconciliation tolerance (negative epsilon)": {
+			provided: &Configuration{
+				Data: &DataConfiguration{
+					ReconciliationTolerances: []*ReconciliationTolerance{
+						{Currency: &types.Currency{Symbol: "BTC", Decimals: 8}, Epsilon: "-1"},
+					},
+				},
+			},
+			err: true,
+		},
+		"invalid reconciliation tolerance (duplicate currency)": {
+			provided: &Configuration{
+				Data: &DataConfiguration{
+					ReconciliationTolerances: []*ReconciliationTolerance{
+						{Currency: &types.Currency{Symbol: "BTC", Decimals: 8}, Epsilon: "10"},
+						{Currency: &types.Currency{Symbol: "BTC", Decimals: 8}, Epsilon: "20"},
+					},
+				},
+			},
+			err: true,
+		},
+		"invalid sub-account mode": {
+			provided: &Configuration{
+				Data: &DataConfiguration{
+					SubAccountMode: "merged",
+				},
+			},
+			err: true,
+		},
+		"invalid reconciler backlog mode": {
+			provided: &Configuration{
+				Data: &DataConfiguration{
+					ReconcilerBacklogMode: "wait",
+				},
+			},
+			err: true,
+		},
+		"negative reconciler active backlog": {
+			provided: &Configuration{
+				Data: &DataConfiguration{
+					ReconcilerActiveBacklog: &negativeBacklog,
+				},
+			},
+			err: true,
+		},
+		"invalid inactive reconciliation order": {
+			provided: &Configuration{
+				Data: &DataConfiguration{
+					InactiveReconciliationOrder: "random",
+				},
+			},
+			err: true,
+		},
+		"negative balance write shards": {
+			provided: &Configuration{
+				Data: &DataConfiguration{
+					BalanceWriteShards: -1,
+				},
+			},
+			err: true,
+		},
+		"negative balance cache size": {
+			provided: &Configuration{
+				Data: &DataConfiguration{
+					BalanceCacheSize: -1,
+				},
+			},
+			err: true,
+		},
+		"subscribed accounts without balance tracking": {
+			provided: &Configuration{
+				Data: &DataConfiguration{
+					SubscribedAccounts:      "accounts.json",
+					BalanceTrackingDisabled: true,
+				},
+			},
+			err: true,
+		},
+		"account file reload without balance tracking": {
+			provided: &Configuration{
+				Data: &DataConfiguration{
+					InterestingAccounts:       "accounts.json",
+					AccountFileReloadInterval: 10,
+					BalanceTrackingDisabled:   true,
+				},
+			},
+			err: true,
+		},
+		"invalid asserter mode": {
+			provided: &Configuration{
+				Data: &DataConfiguration{
+					AsserterMode: "lenient",
+				},
+			},
+			err: true,
+		},
+		"invalid asserter warning": {
+			provided: &Configuration{
+				Data: &DataConfiguration{
+					AsserterMode:     PermissiveAsserterMode,
+					AsserterWarnings: []AsserterViolation{ZeroAmountViolation, "negative_balance"},
+				},
+			},
+			err: true,
+		},
+		"asserter warnings in strict mode": {
+			provided: &Configuration{
+				Data: &DataConfiguration{
+					AsserterMode:     StrictAsserterMode,
+					AsserterWarnings: []AsserterViolation{ZeroAmountViolation},
+				},
+			},
+			err: true,
+		},
+		"permissive asserter mode with quorum": {
+			provided: &Configuration{
+				Data: &DataConfiguration{
+					AsserterMode: PermissiveAsserterMode,
+					Quorum:       &Quorum{URLs: []string{"http://node-2"}, Size: 2},
+				},
+			},
+			err: true,
+		},
+		"invalid sync mode": {
+			provided: &Configuration{
+				Data: &DataConfiguration{
+					SyncMode: "websocket",
+				},
+			},
+			err: true,
+		},
+		"duplicate plugin": {
+			provided: &Configuration{
+				Data: &DataConfiguration{
+					Plugins: []*BlockWorkerPlugin{
+						{Name: "indexer", Path: "/usr/local/bin/indexer"},
+						{Name: "indexer", Path: "/usr/local/bin/other-indexer"},
+					},
+				},
+			},
+			err: true,
+		},
+		"plugin missing path": {
+			provided: &Configuration{
+				Data: &DataConfiguration{
+					Plugins: []*BlockWorkerPlugin{{Name: "indexer"}},
+				},
+			},
+			err: true,
+		},
+		"invalid reconciliation coverage": {
+			provided: invalidReconciliationCoverage,
+			err:      true,
+		},
+		"invalid reconciliation coverage (reconciliation disabled)": {
+			provided: &Configuration{
+				Data: &DataConfiguration{
+					ReconciliationDisabled: true,
+					EndConditions: &DataEndConditions{
+						ReconciliationCoverage: &ReconciliationCoverage{
+							Coverage: goodCoverage,
+						},
+					},
+				},
+			},
+			err: true,
+		},
+		"invalid reconciliation coverage (balance tracking disabled)": {
+			provided: &Configuration{
+				Data: &DataConfiguration{
+					BalanceTrackingDisabled: true,
+					EndConditions: &DataEndConditions{
+						ReconciliationCoverage: &ReconciliationCoverage{
+							Coverage: goodCoverage,
+						},
+					},
+				},
+			},
+			err: true,
+		},
+		"invalid reconciliation coverage (ignore reconciliation error)": {
+			provided: &Configuration{
+				Data: &DataConfiguration{
+					IgnoreReconciliationError: true,
+					EndConditions: &DataEndConditions{
+						ReconciliationCoverage: &ReconciliationCoverage{
+							Coverage: goodCoverage,
+						},
+					},
+				},
+			},
+			err: true,
+		},
+		"valid reconciliation coverage (with account count)": {
+			provided: &Configuration{
+				Data: &DataConfiguration{
+					EndConditions: &DataEndConditions{
+						ReconciliationCoverage: &ReconciliationCoverage{
+							Coverage:     goodCoverage,
+							AccountCount: &goodAccountCount,
+							Index:        &goodAccountCount,
+						},
+					},
+				},
+			},
+			expected: func() *Configuration {
+				cfg := DefaultConfiguration()
+				cfg.Data.EndConditions = &DataEndConditions{
+					ReconciliationCoverage: &ReconciliationCoverage{
+						Coverage:     goodCoverage,
+						AccountCount: &goodAccountCount,
+						Index:        &goodAccountCount,
+					},
+				}
+
+				return cfg
+			}(),
+		},
+		"invalid reconciliation coverage (with account count)": {
+			provided: &Configuration{
+				Data: &DataConfiguration{
+					EndConditions: &DataEndConditions{
+						ReconciliationCoverage: &ReconciliationCoverage{
+							Coverage:     goodCoverage,
+							AccountCount: &badAccountCount,
+						},
+					},
+				},
+			},
+			err: true,
+		},
+		"invalid reconciliation coverage (with index)": {
+			provided: &Configuration{
+				Data: &DataConfiguration{
+					EndConditions: &DataEndConditions{
+						ReconciliationCoverage: &ReconciliationCoverage{
+							Coverage: goodCoverage,
+							Index:    &badAccountCount,
+						},
+					},
+				},
+			},
+			err: true,
+		},
+		"empty workflows": {
+			provided: &Configuration{
+				Construction: &ConstructionConfiguration{
+					Workflows: []*job.Workflow{},
+				},
+			},
+			err: true,
+		},
+		"confirmation depth of undefined workflow": {
+			provided: &Configuration{
+				Construction: &ConstructionConfiguration{
+					Workflows:                  fakeWorkflows,
+					WorkflowConfirmationDepths: map[string]int64{"stake": 12},
+				},
+			},
+			err: true,
+		},
+		"negative confirmation depth": {
+			provided: &Configuration{
+				Construction: &ConstructionConfiguration{
+					Workflows:         fakeWorkflows,
+					ConfirmationDepth: -1,
+				},
+			},
+			err: true,
+		},
+		"concurrent sends of undefined workflow": {
+			provided: &Configuration{
+				Construction: &ConstructionConfiguration{
+					Workflows:       fakeWorkflows,
+					ConcurrentSends: &ConcurrentSends{Workflows: []string{"transfer"}},
+				},
+			},
+			err: true,
+		},
+		"concurrent sends of workflow without concurrency": {
+			provided: &Configuration{
+				Construction: &ConstructionConfiguration{
+					Workflows: fakeWorkflows,
+					ConcurrentSends: &ConcurrentSends{
+						Workflows: []string{string(job.RequestFunds)},
+					},
+				},
+			},
+			err: true,
+		},
+		"remote signer account without key id": {
+			provided: &Configuration{
+				Construction: &ConstructionConfiguration{
+					Workflows: fakeWorkflows,
+					RemoteSigner: &RemoteSigner{
+						Path: "signer",
+						Accounts: []*RemoteAccount{
+							{
+								AccountIdentifier: &types.AccountIdentifier{Address: "addr1"},
+								Currency:          &types.Currency{Symbol: "BTC", Decimals: 8},
+							},
+						},
+					},
+				},
+			},
+			err: true,
+		},
+		"remote signer account is prefunded": {
+			provided: &Configuration{
+				Construction: &ConstructionConfiguration{
+					Workflows: fakeWorkflows,
+					PrefundedAccounts: []*storage.PrefundedAccount{
+						{
+							PrivateKeyHex:     "0102",
+							CurveType:         types.Secp256k1,
+							AccountIdentifier: &types.AccountIdentifier{Address: "addr1"},
+							Currency:          &types.Currency{Symbol: "BTC", Decimals: 8},
+						},
+					},
+					RemoteSigner: &RemoteSigner{
+						Path: "signer",
+						Accounts: []*RemoteAccount{
+							{
+								KeyID:             "key-1",
+								AccountIdentifier: &types.AccountIdentifier{Address: "addr1"},
+								Currency:          &types.Currency{Symbol: "BTC", Decimals: 8},
+							},
+						},
+					},
+				},
+			},
+			err: true,
+		},
+		"rebroadcast backoff lost before last rebroadcast": {
+			provided: &Configuration{
+				Construction: &ConstructionConfiguration{
+					Workflows: fakeWorkflows,
+					RebroadcastBackoff: &RebroadcastBackoff{
+						InitialInterval: 5,
+						MaxRebroadcasts: 3,
+						LostDepth:       35,
+					},
+				},
+			},
+			err: true,
+		},
+		"negative fee estimation max deviation": {
+			provided: &Configuration{
+				Construction: &ConstructionConfiguration{
+					Workflows:     fakeWorkflows,
+					FeeEstimation: &FeeEstimation{MaxDeviation: -1},
+				},
+			},
+			err: true,
+		},
+		"invalid log level": {
+			provided: &Configuration{
+				Logging: &LoggingConfiguration{Level: "trace"},
+			},
+			err: true,
+		},
+		"invalid log module": {
+			provided: &Configuration{
+				Logging: &LoggingConfiguration{
+					Modules: map[LogModule]LogLevel{"parser": DebugLogLevel},
+				},
+			},
+			err: true,
+		},
+		"invalid online url": {
+			provided: &Configuration{
+				OnlineURL: "localhost:8080",
+			},
+			err: true,
+		},
+		"invalid offline url": {
+			provided: &Configuration{
+				Construction: &ConstructionConfiguration{
+					OfflineURL: "ftp://localhost:8080",
+					Workflows:  fakeWorkflows,
+				},
+			},
+			err: true,
+		},
+		"curve plugin for native curve": {
+			provided: &Configuration{
+				Construction: &ConstructionConfiguration{
+					Workflows: fakeWorkflows,
+					CurvePlugins: []*CurvePlugin{
+						{CurveType: types.Secp256k1, Path: "/usr/local/bin/signer"},
+					},
+				},
+			},
+			err: true,
+		},
+		"duplicate curve plugins": {
+			provided: &Configuration{
+				Construction: &ConstructionConfiguration{
+					Workflows: fakeWorkflows,
+					CurvePlugins: []*CurvePlugin{
+						{CurveType: "sr25519", Path: "/usr/local/bin/signer"},
+						{CurveType: "sr25519", Path: "/usr/local/bin/other"},
+					},
+				},
+			},
+			err: true,
+		},
+		"prefunded account on unsupported curve": {
+			provided: &Configuration{
+				Construction: &ConstructionConfiguration{
+					Workflows: fakeWorkflows,
+					PrefundedAccounts: []*storage.PrefundedAccount{
+						{
+							PrivateKeyHex:     "01",
+							AccountIdentifier: &types.AccountIdentifier{Address: "addr"},
+							CurveType:         "sr25519",
+							Currency:          &types.Currency{Symbol: "DOT", Decimals: 10},
+						},
+					},
+				},
+			},
+			err: true,
+		},
+		"invalid unconfirmed broadcasts behavior": {
+			provided: &Configuration{
+				Construction: &ConstructionConfiguration{
+					Workflows:             fakeWorkflows,
+					UnconfirmedBroadcasts: "retry",
+				},
+			},
+			err: true,
+		},
+		"non-existent dsl file": {
+			provided: &Configuration{
+				Construction: &ConstructionConfiguration{
+					ConstructorDSLFile: "blah.ros",
+				},
+			},
+			err: true,
+		},
+		"multiple end conditions": {
+			provided: multipleEndConditions,
+			expected: func() *Configuration {
+				def := DefaultConfiguration()
+				def.Data.EndConditions = multipleEndConditions.Data.EndConditions
+
+				return def
+			}(),
+			err: false,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			dir, err := utils.CreateTempDir()
+			assert.NoError(t, err)
+			defer utils.RemoveTempDir(dir)
+
+			filePath := path.Join(dir, "test.json")
+			err = utils.SerializeAndWrite(filePath, test.provided)
+			assert.NoError(t, err)
+
+			// Copy test.ros to temp dir
+			cmd := exec.Command("cp", "testdata/test.ros", path.Join(dir, "test.ros"))
+			assert.NoError(t, cmd.Run())
+
+			// Check if expected fields populated
+			config, err := LoadConfiguration(context.Background(), filePath)
+			if test.err {
+				assert.Error(t, err)
+				assert.Nil(t, config)
+			} else {
+				assert.NoError(t, err)
+
+				// Ensure test.ros expected file path is right
 				if test.expected.Construction != nil && len(test.expected.Construction.ConstructorDSLFile) > 0 {
 					test.expected.Construction.ConstructorDSLFile = path.Join(dir, test.expected.Construction.ConstructorDSLFile)
 				}
@@ -408,3 +1348,142 @@ func TestLoadConfiguration(t *testing.T) {
 		})
 	}
 }
+
+func TestRebroadcastBackoffSchedule(t *testing.T) {
+	var tests = map[string]struct {
+		backoff *RebroadcastBackoff
+
+		expected []int64
+	}{
+		"fixed": {
+			backoff: &RebroadcastBackoff{
+				InitialInterval: 10,
+				Multiplier:      1,
+				MaxRebroadcasts: 3,
+			},
+			expected: []int64{10, 20, 30},
+		},
+		"exponential": {
+			backoff: &RebroadcastBackoff{
+				InitialInterval: 5,
+				Multiplier:      2,
+				MaxRebroadcasts: 3,
+			},
+			expected: []int64{5, 15, 35},
+		},
+		"max interval": {
+			backoff: &RebroadcastBackoff{
+				InitialInterval: 5,
+				Multiplier:      3,
+				MaxInterval:     20,
+				MaxRebroadcasts: 4,
+			},
+			expected: []int64{5, 20, 40, 60},
+		},
+		"no rebroadcasts": {
+			backoff: &RebroadcastBackoff{
+				InitialInterval: 5,
+				Multiplier:      2,
+			},
+			expected: []int64{},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.expected, test.backoff.Schedule())
+		})
+	}
+}
+
+func TestEnableQuickMode(t *testing.T) {
+	cfg := DefaultConfiguration()
+	assert.NoError(t, EnableQuickMode(cfg))
+	assert.True(t, cfg.Data.BalanceTrackingDisabled)
+	assert.True(t, cfg.Data.CoinTrackingDisabled)
+	assert.True(t, cfg.Data.ReconciliationDisabled)
+
+	cfg = DefaultConfiguration()
+	cfg.Data.BalanceInterpolationSamples = 10
+	assert.Error(t, EnableQuickMode(cfg))
+}
+
+func TestModifyFilePaths(t *testing.T) {
+	var tests = map[string]struct {
+		config *Configuration
+
+		expected *Configuration
+	}{
+		"plugins": {
+			config: &Configuration{
+				Data: &DataConfiguration{
+					Plugins: []*BlockWorkerPlugin{
+						{Name: "indexer", Path: "bin/indexer"},
+						{Name: "exporter", Path: "/usr/local/bin/exporter"},
+					},
+				},
+			},
+			expected: &Configuration{
+				Data: &DataConfiguration{
+					Plugins: []*BlockWorkerPlugin{
+						{Name: "indexer", Path: "/config/bin/indexer"},
+						{Name: "exporter", Path: "/usr/local/bin/exporter"},
+					},
+				},
+			},
+		},
+		"remote signer": {
+			config: &Configuration{
+				Construction: &ConstructionConfiguration{
+					RemoteSigner: &RemoteSigner{Path: "bin/kms-signer"},
+				},
+			},
+			expected: &Configuration{
+				Construction: &ConstructionConfiguration{
+					RemoteSigner: &RemoteSigner{Path: "/config/bin/kms-signer"},
+				},
+			},
+		},
+		"curve plugins": {
+			config: &Configuration{
+				Construction: &ConstructionConfiguration{
+					CurvePlugins: []*CurvePlugin{
+						{CurveType: "sr25519", Path: "bin/sr25519-plugin"},
+						{CurveType: "bls12381", Path: "/usr/local/bin/bls-plugin"},
+					},
+				},
+			},
+			expected: &Configuration{
+				Construction: &ConstructionConfiguration{
+					CurvePlugins: []*CurvePlugin{
+						{CurveType: "sr25519", Path: "/config/bin/sr25519-plugin"},
+						{CurveType: "bls12381", Path: "/usr/local/bin/bls-plugin"},
+					},
+				},
+			},
+		},
+		"encryption key file": {
+			config: &Configuration{
+				Encryption: &EncryptionConfiguration{KeyFile: "encryption.key"},
+			},
+			expected: &Configuration{
+				Encryption: &EncryptionConfiguration{KeyFile: "/config/encryption.key"},
+			},
+		},
+		"encryption key env": {
+			config: &Configuration{
+				Encryption: &EncryptionConfiguration{KeyEnv: "ROSETTA_ENCRYPTION_KEY"},
+			},
+			expected: &Configuration{
+				Encryption: &EncryptionConfiguration{KeyEnv: "ROSETTA_ENCRYPTION_KEY"},
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			modifyFilePaths(test.config, "/config")
+			assert.Equal(t, test.expected, test.config)
+		})
+	}
+}
@@ -0,0 +1,152 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configuration
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+)
+
+const (
+	// EnvironmentPrefix is the prefix of all environment
+	// variables that override configuration fields.
+	EnvironmentPrefix = "ROSETTA_CLI"
+)
+
+// lookupFunc returns the value of an environment
+// variable and a boolean indicating if it is set.
+type lookupFunc func(key string) (string, bool)
+
+// ApplyEnvironmentOverrides overrides the fields of config with
+// any environment variables set for them. The name of the environment
+// variable for a field is EnvironmentPrefix followed by the uppercased
+// JSON keys of the field and its parents, separated by underscores
+// (for example, ROSETTA_CLI_ONLINE_URL or
+// ROSETTA_CLI_DATA_END_CONDITIONS_INDEX).
+//
+// String fields are set to the value of the environment variable.
+// All other fields (like numbers, booleans, and lists) are parsed
+// from the value as JSON.
+func ApplyEnvironmentOverrides(config *Configuration) error {
+	_, err := applyOverrides(reflect.ValueOf(config).Elem(), EnvironmentPrefix, os.LookupEnv)
+	return err
+}
+
+// envKey returns the environment variable name
+// of a struct field (or "" if the field is not
+// serialized).
+func envKey(prefix string, field reflect.StructField) string {
+	if len(field.PkgPath) > 0 {
+		return ""
+	}
+
+	name := strings.Split(field.Tag.Get("json"), ",")[0]
+	if name == "-" {
+		return ""
+	}
+
+	if len(name) == 0 {
+		name = field.Name
+	}
+
+	return fmt.Sprintf("%s_%s", prefix, strings.ToUpper(name))
+}
+
+// applyOverrides overrides the fields of the struct v with
+// any environment variables set for them and returns a
+// boolean indicating if any field was overridden.
+func applyOverrides(v reflect.Value, prefix string, lookup lookupFunc) (bool, error) {
+	applied := false
+	for i := 0; i < v.NumField(); i++ {
+		key := envKey(prefix, v.Type().Field(i))
+		if len(key) == 0 {
+			continue
+		}
+
+		field := v.Field(i)
+		if value, ok := lookup(key); ok {
+			if err := setField(field, value); err != nil {
+				return false, fmt.Errorf("%w: unable to parse %s", err, key)
+			}
+
+			applied = true
+			continue
+		}
+
+		fieldApplied, err := applyNestedOverrides(field, key, lookup)
+		if err != nil {
+			return false, err
+		}
+
+		applied = applied || fieldApplied
+	}
+
+	return applied, nil
+}
+
+// applyNestedOverrides applies overrides to the fields of
+// a struct (or pointer to a struct) field. Overrides are
+// applied to a copy of a pointed-to struct (which may be
+// shared, like EthereumNetwork) and the field is only
+// updated if one of its fields is overridden.
+func applyNestedOverrides(field reflect.Value, key string, lookup lookupFunc) (bool, error) {
+	switch {
+	case field.Kind() == reflect.Struct:
+		return applyOverrides(field, key, lookup)
+	case field.Kind() == reflect.Ptr && field.Type().Elem().Kind() == reflect.Struct:
+		nested := reflect.New(field.Type().Elem())
+		if !field.IsNil() {
+			nested.Elem().Set(field.Elem())
+		}
+
+		applied, err := applyOverrides(nested.Elem(), key, lookup)
+		if err != nil {
+			return false, err
+		}
+
+		if applied {
+			field.Set(nested)
+		}
+
+		return applied, nil
+	default:
+		return false, nil
+	}
+}
+
+// setField sets field to value. String fields (and pointers
+// to strings) are set directly and all others are parsed
+// from JSON.
+func setField(field reflect.Value, value string) error {
+	target := field
+	if field.Kind() == reflect.Ptr {
+		target = reflect.New(field.Type().Elem()).Elem()
+	}
+
+	if target.Kind() == reflect.String {
+		target.SetString(value)
+	} else if err := json.Unmarshal([]byte(value), target.Addr().Interface()); err != nil {
+		return err
+	}
+
+	if field.Kind() == reflect.Ptr {
+		field.Set(target.Addr())
+	}
+
+	return nil
+}
@@ -0,0 +1,101 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configuration
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyOverrides(t *testing.T) {
+	var tests = map[string]struct {
+		env map[string]string
+
+		expected *Configuration
+		err      bool
+	}{
+		"no overrides": {
+			env:      map[string]string{},
+			expected: DefaultConfiguration(),
+		},
+		"overrides": {
+			env: map[string]string{
+				"ROSETTA_CLI_ONLINE_URL":                   "http://node:8080",
+				"ROSETTA_CLI_NETWORK_NETWORK":              "Testnet3",
+				"ROSETTA_CLI_MAX_SYNC_CONCURRENCY":         "32",
+				"ROSETTA_CLI_DATA_LOG_BLOCKS":              "true",
+				"ROSETTA_CLI_DATA_END_CONDITIONS_INDEX":    "100",
+				"ROSETTA_CLI_DATA_TRACKED_CURRENCIES":      `[{"symbol":"BTC","decimals":8}]`,
+				"ROSETTA_CLI_CONSTRUCTION_OFFLINE_URL":     "http://offline:8080",
+				"ROSETTA_CLI_CONSTRUCTION_STALE_DEPTH":     "10",
+				"ROSETTA_CLI_UNKNOWN_FIELD":                "ignored",
+				"ROSETTA_CLI_DATA_END_CONDITIONS_DURATION": "60",
+			},
+			expected: func() *Configuration {
+				config := DefaultConfiguration()
+				config.OnlineURL = "http://node:8080"
+				config.Network = &types.NetworkIdentifier{
+					Blockchain: EthereumNetwork.Blockchain,
+					Network:    "Testnet3",
+				}
+				config.MaxSyncConcurrency = 32
+				config.Data.LogBlocks = true
+				index := int64(100)
+				duration := uint64(60)
+				config.Data.EndConditions = &DataEndConditions{
+					Index:    &index,
+					Duration: &duration,
+				}
+				config.Data.TrackedCurrencies = []*types.Currency{{Symbol: "BTC", Decimals: 8}}
+				config.Construction = &ConstructionConfiguration{
+					OfflineURL: "http://offline:8080",
+					StaleDepth: 10,
+				}
+				return config
+			}(),
+		},
+		"invalid value": {
+			env: map[string]string{
+				"ROSETTA_CLI_MAX_SYNC_CONCURRENCY": "many",
+			},
+			err: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			config := DefaultConfiguration()
+			lookup := func(key string) (string, bool) {
+				value, ok := test.env[key]
+				return value, ok
+			}
+
+			_, err := applyOverrides(reflect.ValueOf(config).Elem(), EnvironmentPrefix, lookup)
+			if test.err {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, config)
+
+			// Shared defaults are never modified.
+			assert.Equal(t, "Ropsten", EthereumNetwork.Network)
+		})
+	}
+}
@@ -0,0 +1,242 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configuration
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// yamlExtension and ymlExtension are the file
+	// extensions of YAML configuration files.
+	yamlExtension = ".yaml"
+	ymlExtension  = ".yml"
+
+	// tomlExtension is the file extension of
+	// TOML configuration files.
+	tomlExtension = ".toml"
+
+	// unknownFieldPrefix is the prefix of the error returned
+	// by the decoder when a field is not in the schema.
+	unknownFieldPrefix = "json: unknown field "
+)
+
+// loadFile parses the configuration file at filePath into config.
+// YAML (.yaml or .yml) and TOML (.toml) files use the same keys as
+// JSON files. All other files are parsed as JSON.
+//
+// YAML and TOML files are converted to JSON before they are parsed
+// so that all formats are held to the same schema (for example,
+// unknown fields are rejected in all formats).
+func loadFile(filePath string, config *Configuration) error {
+	contents, err := ioutil.ReadFile(path.Clean(filePath))
+	if err != nil {
+		return fmt.Errorf("%w: unable to read %s", err, filePath)
+	}
+
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case yamlExtension, ymlExtension:
+		converted, lines, err := yamlToJSON(contents)
+		if err != nil {
+			return fmt.Errorf("%w: unable to parse YAML", err)
+		}
+
+		return decodeConverted(converted, lines, config)
+	case tomlExtension:
+		converted, err := tomlToJSON(contents)
+		if err != nil {
+			return fmt.Errorf("%w: unable to parse TOML", err)
+		}
+
+		return decodeConverted(converted, tomlLines(contents), config)
+	default:
+		return decodeJSON(contents, config)
+	}
+}
+
+// newDecoder returns a *json.Decoder of contents
+// that rejects unknown fields.
+func newDecoder(contents []byte) *json.Decoder {
+	decoder := json.NewDecoder(bytes.NewReader(contents))
+	decoder.DisallowUnknownFields()
+
+	return decoder
+}
+
+// decodeJSON decodes a JSON configuration file into config.
+// Syntax and type errors include the line they occurred on.
+func decodeJSON(contents []byte, config *Configuration) error {
+	err := newDecoder(contents).Decode(config)
+	if err == nil {
+		return nil
+	}
+
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return fmt.Errorf("%w: line %d", err, lineNumber(contents, syntaxErr.Offset))
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return fmt.Errorf("%w: line %d", err, lineNumber(contents, typeErr.Offset))
+	}
+
+	// Unknown field errors do not include an offset, so the
+	// line is the first line that contains the (quoted) field.
+	if strings.HasPrefix(err.Error(), unknownFieldPrefix) {
+		field := strings.TrimPrefix(err.Error(), unknownFieldPrefix)
+		if offset := bytes.Index(contents, []byte(field)); offset >= 0 {
+			return fmt.Errorf("%w: line %d", err, lineNumber(contents, int64(offset)))
+		}
+	}
+
+	return fmt.Errorf("%w: unable to parse JSON", err)
+}
+
+// decodeConverted decodes a YAML or TOML configuration file that
+// was converted to JSON into config. Offsets in the converted file
+// do not correspond to lines in the original file, so type and
+// unknown field errors are mapped to the path of the field they
+// occurred on and then to the line of that field in the original
+// file (lines).
+func decodeConverted(contents []byte, lines sourceLines, config *Configuration) error {
+	err := newDecoder(contents).Decode(config)
+	if err == nil {
+		return nil
+	}
+
+	if path, ok := errorPath(contents, err); ok {
+		if line, ok := lines.line(path); ok {
+			return fmt.Errorf("%w: line %d", err, line)
+		}
+	}
+
+	return fmt.Errorf("%w: invalid configuration fields", err)
+}
+
+// errorPath returns the path of the field that the error
+// returned when decoding the JSON document contents into
+// a *Configuration occurred on.
+func errorPath(contents []byte, err error) ([]string, bool) {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		path := valuePath(contents, typeErr.Offset)
+		return path, len(path) > 0
+	}
+
+	if !strings.HasPrefix(err.Error(), unknownFieldPrefix) {
+		return nil, false
+	}
+
+	var document interface{}
+	if err := json.Unmarshal(contents, &document); err != nil {
+		return nil, false
+	}
+
+	path, ok := unknownFieldPath(document, reflect.TypeOf(Configuration{}))
+	if !ok || strconv.Quote(path[len(path)-1]) != strings.TrimPrefix(err.Error(), unknownFieldPrefix) {
+		return nil, false
+	}
+
+	return path, true
+}
+
+// lineNumber returns the line (starting at 1) of
+// the byte at offset in contents.
+func lineNumber(contents []byte, offset int64) int {
+	if offset > int64(len(contents)) {
+		offset = int64(len(contents))
+	}
+
+	return bytes.Count(contents[:offset], []byte("\n")) + 1
+}
+
+// yamlToJSON converts a YAML document to JSON and returns
+// the line of each of its fields. Errors returned by the
+// YAML parser include the line they occurred on.
+func yamlToJSON(contents []byte) ([]byte, sourceLines, error) {
+	var node yaml.Node
+	if err := yaml.Unmarshal(contents, &node); err != nil {
+		return nil, nil, err
+	}
+
+	var document interface{}
+	if err := node.Decode(&document); err != nil {
+		return nil, nil, err
+	}
+
+	converted, err := json.Marshal(stringKeys(document))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	lines := sourceLines{}
+	yamlLines(&node, nil, lines)
+
+	return converted, lines, nil
+}
+
+// stringKeys converts the maps returned by the YAML parser
+// (which may have keys of any type) into maps with string
+// keys so that they can be converted to JSON.
+func stringKeys(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, val := range v {
+			v[key] = stringKeys(val)
+		}
+
+		return v
+	case map[interface{}]interface{}:
+		converted := make(map[string]interface{}, len(v))
+		for key, val := range v {
+			converted[fmt.Sprint(key)] = stringKeys(val)
+		}
+
+		return converted
+	case []interface{}:
+		for i, val := range v {
+			v[i] = stringKeys(val)
+		}
+
+		return v
+	default:
+		return v
+	}
+}
+
+// tomlToJSON converts a TOML document to JSON. Errors
+// returned by the TOML parser include the line they
+// occurred on.
+func tomlToJSON(contents []byte) ([]byte, error) {
+	var document map[string]interface{}
+	if _, err := toml.Decode(string(contents), &document); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(document)
+}
@@ -0,0 +1,174 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configuration
+
+import (
+	"context"
+	"io/ioutil"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/coinbase/rosetta-sdk-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadConfigurationFormats(t *testing.T) {
+	tip := true
+	expected := DefaultConfiguration()
+	expected.Network = &types.NetworkIdentifier{Blockchain: "Bitcoin", Network: "Testnet3"}
+	expected.OnlineURL = "http://localhost:8081"
+	expected.Data.InactiveReconciliationConcurrency = 8
+	expected.Data.EndConditions = &DataEndConditions{Tip: &tip}
+
+	var tests = map[string]struct {
+		file     string
+		contents string
+
+		err string
+	}{
+		"json": {
+			file: "config.json",
+			contents: `{
+  "network": {"blockchain": "Bitcoin", "network": "Testnet3"},
+  "online_url": "http://localhost:8081",
+  "data": {
+    "inactive_reconciliation_concurrency": 8,
+    "end_conditions": {"tip": true}
+  }
+}`,
+		},
+		"yaml": {
+			file: "config.yaml",
+			contents: `# Syncing a testnet node
+network:
+  blockchain: Bitcoin
+  network: Testnet3
+online_url: http://localhost:8081
+data:
+  inactive_reconciliation_concurrency: 8
+  end_conditions:
+    tip: true
+`,
+		},
+		"toml": {
+			file: "config.toml",
+			contents: `# Syncing a testnet node
+online_url = "http://localhost:8081"
+
+[network]
+blockchain = "Bitcoin"
+network = "Testnet3"
+
+[data]
+inactive_reconciliation_concurrency = 8
+
+[data.end_conditions]
+tip = true
+`,
+		},
+		"json syntax error": {
+			file:     "config.json",
+			contents: "{\n  \"online_url\": \"http://localhost:8081\"\n  \"data\": {}\n}",
+			err:      "line 3",
+		},
+		"json type error": {
+			file:     "config.json",
+			contents: "{\n  \"data\": {\n    \"start_index\": \"10\"\n  }\n}",
+			err:      "line 3",
+		},
+		"json unknown field": {
+			file:     "config.json",
+			contents: "{\n  \"data\": {\n    \"unknown_field\": true\n  }\n}",
+			err:      "line 3",
+		},
+		"yaml syntax error": {
+			file:     "config.yml",
+			contents: "online_url: http://localhost:8081\ndata:\n\tstart_index: 10\n",
+			err:      "line 3",
+		},
+		"yaml unknown field": {
+			file:     "config.yaml",
+			contents: "data:\n  unknown_field: true\n",
+			err:      "line 2",
+		},
+		"yaml type error": {
+			file:     "config.yaml",
+			contents: "online_url: http://localhost:8081\ndata:\n  start_index: \"10\"\n",
+			err:      "line 3",
+		},
+		"yaml list item error": {
+			file: "config.yaml",
+			contents: `data:
+  plugins:
+    - name: indexer
+      path: /usr/local/bin/indexer
+    - name: exporter
+      paht: /usr/local/bin/exporter
+`,
+			err: "line 6",
+		},
+		"toml syntax error": {
+			file:     "config.toml",
+			contents: "online_url = \"http://localhost:8081\"\n\n[data\n",
+			err:      "line 3",
+		},
+		"toml type error": {
+			file:     "config.toml",
+			contents: "[data]\nstart_index = \"10\"\n",
+			err:      "line 2",
+		},
+		"toml unknown field": {
+			file:     "config.toml",
+			contents: "online_url = \"http://localhost:8081\"\n\n[data]\nunknown_field = true\n",
+			err:      "line 4",
+		},
+		"toml array of tables error": {
+			file: "config.toml",
+			contents: `[[data.plugins]]
+name = "indexer"
+path = "/usr/local/bin/indexer"
+
+[[data.plugins]]
+name = "exporter"
+timeout = "30"
+`,
+			err: "line 7",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			dir, err := utils.CreateTempDir()
+			assert.NoError(t, err)
+			defer utils.RemoveTempDir(dir)
+
+			filePath := path.Join(dir, test.file)
+			assert.NoError(t, ioutil.WriteFile(filePath, []byte(test.contents), 0600))
+
+			config, err := LoadConfiguration(context.Background(), filePath)
+			if len(test.err) > 0 {
+				assert.Error(t, err)
+				assert.True(t, strings.Contains(err.Error(), test.err), err.Error())
+				assert.Nil(t, config)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, expected, config)
+		})
+	}
+}
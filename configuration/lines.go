@@ -0,0 +1,366 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configuration
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// sourceLines maps the path of each field in a YAML or TOML
+// configuration file (like data.end_conditions.tip or
+// data.plugins.0.path) to the line it is defined on.
+type sourceLines map[string]int
+
+// add records that the field at path is defined on line
+// (unless it was already defined on an earlier line).
+func (l sourceLines) add(path []string, line int) {
+	key := strings.Join(path, ".")
+	if _, ok := l[key]; !ok {
+		l[key] = line
+	}
+}
+
+// line returns the line of the field at path. Fields
+// that are not defined on their own line (like the
+// items of an inline list) return the line of the
+// closest field that contains them.
+func (l sourceLines) line(path []string) (int, bool) {
+	for i := len(path); i > 0; i-- {
+		if line, ok := l[strings.Join(path[:i], ".")]; ok {
+			return line, true
+		}
+	}
+
+	return 0, false
+}
+
+// yamlLines records the line of each field in node.
+func yamlLines(node *yaml.Node, path []string, lines sourceLines) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, child := range node.Content {
+			yamlLines(child, path, lines)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			fieldPath := appendPath(path, node.Content[i].Value)
+			lines.add(fieldPath, node.Content[i].Line)
+			yamlLines(node.Content[i+1], fieldPath, lines)
+		}
+	case yaml.SequenceNode:
+		for i, child := range node.Content {
+			itemPath := appendPath(path, strconv.Itoa(i))
+			lines.add(itemPath, child.Line)
+			yamlLines(child, itemPath, lines)
+		}
+	}
+}
+
+// tomlLines returns the line of each field in a TOML
+// document. The TOML parser does not record where keys
+// are defined, so they are found by scanning the document
+// line by line: table headers set the table of the keys
+// that follow them and each array of tables header starts
+// a new item of the array.
+func tomlLines(contents []byte) sourceLines {
+	lines := sourceLines{}
+	arrays := map[string]int{}
+	var table []string
+	var multiline string
+	for i, text := range strings.Split(string(contents), "\n") {
+		trimmed := strings.TrimSpace(text)
+		if len(multiline) > 0 {
+			if strings.Count(trimmed, multiline)%2 == 1 {
+				multiline = ""
+			}
+
+			continue
+		}
+
+		switch {
+		case len(trimmed) == 0 || strings.HasPrefix(trimmed, "#"):
+			continue
+		case strings.HasPrefix(trimmed, "[["):
+			end := strings.Index(trimmed, "]]")
+			if end < 0 {
+				continue
+			}
+
+			array := resolveTable(splitKey(trimmed[2:end]), arrays)
+			lines.add(array, i+1)
+
+			key := strings.Join(array, ".")
+			table = appendPath(array, strconv.Itoa(arrays[key]))
+			arrays[key]++
+			lines.add(table, i+1)
+		case strings.HasPrefix(trimmed, "["):
+			end := strings.Index(trimmed, "]")
+			if end < 0 {
+				continue
+			}
+
+			table = resolveTable(splitKey(trimmed[1:end]), arrays)
+			lines.add(table, i+1)
+		default:
+			equals := unquotedIndex(trimmed, '=')
+			if equals < 0 {
+				continue
+			}
+
+			lines.add(appendPath(table, splitKey(trimmed[:equals])...), i+1)
+
+			value := trimmed[equals+1:]
+			for _, delimiter := range []string{`"""`, `'''`} {
+				if strings.Count(value, delimiter)%2 == 1 {
+					multiline = delimiter
+					break
+				}
+			}
+		}
+	}
+
+	return lines
+}
+
+// resolveTable returns the path of the table named by
+// the keys of a TOML table header. Keys that name an
+// array of tables refer to its last item.
+func resolveTable(keys []string, arrays map[string]int) []string {
+	var path []string
+	for i, key := range keys {
+		path = append(path, key)
+		if count, ok := arrays[strings.Join(path, ".")]; ok && i < len(keys)-1 {
+			path = append(path, strconv.Itoa(count-1))
+		}
+	}
+
+	return path
+}
+
+// splitKey splits a (possibly dotted and quoted)
+// TOML key into its parts.
+func splitKey(key string) []string {
+	var parts []string
+	for {
+		dot := unquotedIndex(key, '.')
+		if dot < 0 {
+			return append(parts, strings.Trim(strings.TrimSpace(key), `"'`))
+		}
+
+		parts = append(parts, strings.Trim(strings.TrimSpace(key[:dot]), `"'`))
+		key = key[dot+1:]
+	}
+}
+
+// unquotedIndex returns the index of the first
+// target in s that is not quoted (or -1).
+func unquotedIndex(s string, target rune) int {
+	var quote rune
+	for i, c := range s {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == target:
+			return i
+		}
+	}
+
+	return -1
+}
+
+// appendPath returns a copy of path with
+// keys appended.
+func appendPath(path []string, keys ...string) []string {
+	return append(append([]string{}, path...), keys...)
+}
+
+// jsonContainer is an object or array that
+// is being read by valuePath.
+type jsonContainer struct {
+	object bool
+
+	// key is the key of the current value
+	// in an object and index is its index
+	// in an array.
+	key   string
+	index int
+
+	// needKey is true when the next
+	// token in an object is a key.
+	needKey bool
+}
+
+// valuePath returns the path of the innermost value of
+// the JSON document contents that starts before offset.
+func valuePath(contents []byte, offset int64) []string {
+	decoder := json.NewDecoder(bytes.NewReader(contents))
+	var stack []*jsonContainer
+	var path []string
+	for decoder.InputOffset() < offset {
+		token, err := decoder.Token()
+		if err != nil {
+			break
+		}
+
+		var top *jsonContainer
+		if len(stack) > 0 {
+			top = stack[len(stack)-1]
+		}
+
+		if key, ok := token.(string); ok && top != nil && top.needKey {
+			top.key = key
+			top.needKey = false
+			continue
+		}
+
+		delim, isDelim := token.(json.Delim)
+		if isDelim && (delim == '}' || delim == ']') {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+
+		if top != nil {
+			if top.object {
+				top.needKey = true
+			} else {
+				top.index++
+			}
+
+			path = containerPath(stack)
+		}
+
+		if isDelim {
+			stack = append(stack, &jsonContainer{
+				object:  delim == '{',
+				index:   -1,
+				needKey: delim == '{',
+			})
+		}
+	}
+
+	return path
+}
+
+// containerPath returns the path of the
+// current value of the innermost container
+// in stack.
+func containerPath(stack []*jsonContainer) []string {
+	path := make([]string, len(stack))
+	for i, container := range stack {
+		if container.object {
+			path[i] = container.key
+		} else {
+			path[i] = strconv.Itoa(container.index)
+		}
+	}
+
+	return path
+}
+
+// unmarshalerType is the type of json.Unmarshaler.
+var unmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// unknownFieldPath returns the path of the first field
+// of the decoded JSON document value that is not a
+// field of t, in the order the fields are decoded.
+func unknownFieldPath(value interface{}, t reflect.Type) ([]string, bool) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if reflect.PtrTo(t).Implements(unmarshalerType) {
+		return nil, false
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if t.Kind() != reflect.Struct && t.Kind() != reflect.Map {
+			return nil, false
+		}
+
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			var fieldType reflect.Type
+			if t.Kind() == reflect.Map {
+				fieldType = t.Elem()
+			} else {
+				field, ok := jsonField(t, key)
+				if !ok {
+					return []string{key}, true
+				}
+
+				fieldType = field.Type
+			}
+
+			if path, ok := unknownFieldPath(v[key], fieldType); ok {
+				return append([]string{key}, path...), true
+			}
+		}
+	case []interface{}:
+		if t.Kind() != reflect.Slice && t.Kind() != reflect.Array {
+			return nil, false
+		}
+
+		for i, item := range v {
+			if path, ok := unknownFieldPath(item, t.Elem()); ok {
+				return append([]string{strconv.Itoa(i)}, path...), true
+			}
+		}
+	}
+
+	return nil, false
+}
+
+// jsonField returns the field of the struct t that
+// the JSON key is decoded into.
+func jsonField(t reflect.Type, key string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if len(field.PkgPath) > 0 {
+			continue
+		}
+
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+
+		if len(name) == 0 {
+			name = field.Name
+		}
+
+		if strings.EqualFold(name, key) {
+			return field, true
+		}
+	}
+
+	return reflect.StructField{}, false
+}
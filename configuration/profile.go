@@ -0,0 +1,95 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configuration
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// AllProfiles is the reserved profile name used to
+// run check:data for each profile sequentially.
+const AllProfiles = "all"
+
+var (
+	// ErrProfileNotFound is returned when a profile is
+	// not defined in a configuration file.
+	ErrProfileNotFound = errors.New("profile not found")
+
+	// ErrNoProfiles is returned when a profile is selected
+	// but no profiles are defined in a configuration file.
+	ErrNoProfiles = errors.New("no profiles defined")
+)
+
+// ProfileNames returns the names of the profiles
+// defined in config in alphabetical order.
+func ProfileNames(config *Configuration) []string {
+	names := make([]string, 0, len(config.Profiles))
+	for name := range config.Profiles {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// applyProfile applies the fields populated in the profile
+// named profile to config. Objects are merged with the
+// corresponding objects in config (so only the fields that
+// differ from the rest of the file must be populated) and
+// all other values (including arrays) are replaced.
+func applyProfile(config *Configuration, profile string) error {
+	if len(config.Profiles) == 0 {
+		return fmt.Errorf("%w: unable to select profile %s", ErrNoProfiles, profile)
+	}
+
+	raw, ok := config.Profiles[profile]
+	if !ok {
+		return fmt.Errorf(
+			"%w: %s (available profiles: %s)",
+			ErrProfileNotFound,
+			profile,
+			strings.Join(ProfileNames(config), ", "),
+		)
+	}
+
+	// Profiles are not inherited, so they are removed before
+	// applying the profile to detect any nested profiles.
+	profiles := config.Profiles
+	config.Profiles = nil
+	if err := newDecoder(raw).Decode(config); err != nil {
+		return fmt.Errorf("%w: invalid fields in profile %s", err, profile)
+	}
+
+	if config.Profiles != nil {
+		return fmt.Errorf("profile %s cannot define profiles", profile)
+	}
+
+	config.Profiles = profiles
+	config.Profile = profile
+	return nil
+}
+
+// assertProfiles ensures the reserved profile
+// name is not used by any profile.
+func assertProfiles(config *Configuration) error {
+	if _, ok := config.Profiles[AllProfiles]; ok {
+		return fmt.Errorf("profile name %s is reserved", AllProfiles)
+	}
+
+	return nil
+}
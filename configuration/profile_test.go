@@ -0,0 +1,161 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configuration
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"path"
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/coinbase/rosetta-sdk-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+const profilesConfiguration = `{
+  "online_url": "http://localhost:8081",
+  "data": {
+    "inactive_reconciliation_concurrency": 8,
+    "end_conditions": {"tip": true}
+  },
+  "profiles": {
+    "bitcoin": {
+      "network": {"blockchain": "Bitcoin", "network": "Testnet3"},
+      "data_directory": "bitcoin-data"
+    },
+    "ethereum": {
+      "network": {"blockchain": "Ethereum", "network": "Ropsten"},
+      "online_url": "http://ethereum:8080",
+      "data": {"inactive_reconciliation_concurrency": 4}
+    },
+    "unknown field": {
+      "data": {"unknown_field": true}
+    },
+    "nested": {
+      "profiles": {"inner": {}}
+    }
+  }
+}`
+
+func TestLoadProfile(t *testing.T) {
+	dir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(dir)
+
+	filePath := path.Join(dir, "config.json")
+	assert.NoError(t, ioutil.WriteFile(filePath, []byte(profilesConfiguration), 0600))
+
+	tip := true
+	bitcoin := &types.NetworkIdentifier{Blockchain: "Bitcoin", Network: "Testnet3"}
+	ethereum := &types.NetworkIdentifier{Blockchain: "Ethereum", Network: "Ropsten"}
+	var tests = map[string]struct {
+		profile string
+
+		network                           *types.NetworkIdentifier
+		onlineURL                         string
+		dataDirectory                     string
+		inactiveReconciliationConcurrency uint64
+		err                               error
+	}{
+		"no profile": {
+			network:                           EthereumNetwork,
+			onlineURL:                         "http://localhost:8081",
+			inactiveReconciliationConcurrency: 8,
+		},
+		"all profiles": {
+			profile:                           AllProfiles,
+			network:                           EthereumNetwork,
+			onlineURL:                         "http://localhost:8081",
+			inactiveReconciliationConcurrency: 8,
+		},
+		"bitcoin": {
+			profile:                           "bitcoin",
+			network:                           bitcoin,
+			onlineURL:                         "http://localhost:8081",
+			dataDirectory:                     "bitcoin-data",
+			inactiveReconciliationConcurrency: 8,
+		},
+		"ethereum": {
+			profile:                           "ethereum",
+			network:                           ethereum,
+			onlineURL:                         "http://ethereum:8080",
+			inactiveReconciliationConcurrency: 4,
+		},
+		"missing profile": {
+			profile: "solana",
+			err:     ErrProfileNotFound,
+		},
+		"unknown field": {
+			profile: "unknown field",
+		},
+		"nested profiles": {
+			profile: "nested",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			config, err := LoadProfile(context.Background(), filePath, test.profile)
+			if test.err != nil {
+				assert.True(t, errors.Is(err, test.err))
+				return
+			}
+
+			if test.network == nil {
+				assert.Error(t, err)
+				assert.Nil(t, config)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, test.network, config.Network)
+			assert.Equal(t, test.onlineURL, config.OnlineURL)
+			assert.Equal(t, test.dataDirectory, config.DataDirectory)
+			assert.Equal(
+				t,
+				test.inactiveReconciliationConcurrency,
+				config.Data.InactiveReconciliationConcurrency,
+			)
+
+			// Fields not populated in the profile are kept.
+			assert.Equal(t, &DataEndConditions{Tip: &tip}, config.Data.EndConditions)
+			assert.Equal(
+				t,
+				[]string{"bitcoin", "ethereum", "nested", "unknown field"},
+				ProfileNames(config),
+			)
+		})
+	}
+}
+
+func TestLoadProfileReserved(t *testing.T) {
+	dir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(dir)
+
+	filePath := path.Join(dir, "config.json")
+	contents := `{"profiles": {"all": {"online_url": "http://localhost:8081"}}}`
+	assert.NoError(t, ioutil.WriteFile(filePath, []byte(contents), 0600))
+
+	config, err := LoadConfiguration(context.Background(), filePath)
+	assert.Error(t, err)
+	assert.Nil(t, config)
+
+	config, err = LoadProfile(context.Background(), filePath, "bitcoin")
+	assert.Error(t, err)
+	assert.Nil(t, config)
+}
@@ -0,0 +1,360 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configuration
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/coinbase/rosetta-sdk-go/asserter"
+	"github.com/coinbase/rosetta-sdk-go/constructor/job"
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+const (
+	// stakingVerifyScenario is the name of the scenario
+	// that checks balance changes once the transaction
+	// of a staking workflow is confirmed.
+	stakingVerifyScenario = "verify"
+
+	// minimumStakedBalance is the minimum staked balance of
+	// an account selected by the claim_rewards workflow.
+	minimumStakedBalance = "1"
+)
+
+// stakingWorkflows are all built-in staking
+// workflows in the order they are generated.
+var stakingWorkflows = []StakingWorkflow{
+	DelegateWorkflow,
+	UndelegateWorkflow,
+	ClaimRewardsWorkflow,
+	RedelegateWorkflow,
+}
+
+// stakingGenerator generates the actions of
+// the built-in staking workflows.
+type stakingGenerator struct {
+	config  *StakingWorkflows
+	network string
+}
+
+func action(actionType job.ActionType, outputPath string, input string) *job.Action {
+	return &job.Action{
+		Type:       actionType,
+		Input:      input,
+		OutputPath: outputPath,
+	}
+}
+
+// setup returns the actions that set the network of
+// scenario and the staked currency.
+func (g *stakingGenerator) setup(scenario string) []*job.Action {
+	return []*job.Action{
+		action(job.SetVariable, scenario+".network", g.network),
+		action(job.SetVariable, "currency", types.PrintStruct(g.config.Currency)),
+	}
+}
+
+// findBalance returns an action that stores the balance of the
+// subAccount of the account stored at account in outputPath.
+func findBalance(
+	outputPath string,
+	account string,
+	subAccount *types.SubAccountIdentifier,
+) *job.Action {
+	return action(job.FindBalance, outputPath, fmt.Sprintf(
+		`{"account_identifier":{"address":{{%s.account_identifier.address}}},`+
+			`"sub_account_identifier":%s,`+
+			`"minimum_balance":{"value":"0","currency":{{currency}}}}`,
+		account,
+		types.PrintStruct(subAccount),
+	))
+}
+
+// assertDifference returns the actions that ensure
+// left - right - minimum >= 0.
+func assertDifference(name string, left string, right string, minimum string) []*job.Action {
+	return []*job.Action{
+		action(job.Math, name+"_change", fmt.Sprintf(
+			`{"operation":"%s","left_value":%s,"right_value":%s}`,
+			job.Subtraction,
+			left,
+			right,
+		)),
+		action(job.Math, name+"_surplus", fmt.Sprintf(
+			`{"operation":"%s","left_value":{{%s_change}},"right_value":"%s"}`,
+			job.Subtraction,
+			name,
+			minimum,
+		)),
+		action(job.Assert, "", fmt.Sprintf("{{%s_surplus}}", name)),
+	}
+}
+
+// operations returns the action that sets the operations
+// of scenario to a single operation of opType by the
+// account stored at account.
+func (g *stakingGenerator) operations(
+	scenario string,
+	opType string,
+	account string,
+	amount string,
+	metadata map[string]interface{},
+) *job.Action {
+	amountJSON := ""
+	if len(amount) > 0 {
+		amountJSON = fmt.Sprintf(`"amount":{"value":"%s","currency":{{currency}}},`, amount)
+	}
+
+	return action(job.SetVariable, scenario+".operations", fmt.Sprintf(
+		`[{"operation_identifier":{"index":0},"type":"%s",`+
+			`"account":{"address":{{%s.account_identifier.address}}},%s"metadata":%s}]`,
+		opType,
+		account,
+		amountJSON,
+		types.PrintStruct(metadata),
+	))
+}
+
+// findDelegator returns the action that finds an account
+// with a staked balance of at least minimum.
+func (g *stakingGenerator) findDelegator(minimum string) *job.Action {
+	return action(job.FindBalance, "delegator", fmt.Sprintf(
+		`{"sub_account_identifier":%s,"minimum_balance":{"value":"%s","currency":{{currency}}}}`,
+		types.PrintStruct(g.config.StakedSubAccount),
+		minimum,
+	))
+}
+
+func (g *stakingGenerator) delegate(opType string) []*job.Scenario {
+	name := string(DelegateWorkflow)
+	actions := append(
+		g.setup(name),
+		action(job.FindBalance, "delegator", fmt.Sprintf(
+			`{"minimum_balance":{"value":"%s","currency":{{currency}}},"create_limit":1}`,
+			g.config.Amount,
+		)),
+		findBalance("staked_before", "delegator", g.config.StakedSubAccount),
+		g.operations(
+			name,
+			opType,
+			"delegator",
+			"-"+g.config.Amount,
+			map[string]interface{}{g.config.ValidatorMetadataKey: g.config.Validators[0]},
+		),
+	)
+
+	verify := append(
+		[]*job.Action{findBalance("staked_after", "delegator", g.config.StakedSubAccount)},
+		assertDifference(
+			"staked",
+			"{{staked_after.balance.value}}",
+			"{{staked_before.balance.value}}",
+			g.config.Amount,
+		)...,
+	)
+
+	return []*job.Scenario{
+		{Name: name, Actions: actions},
+		{Name: stakingVerifyScenario, Actions: verify},
+	}
+}
+
+func (g *stakingGenerator) undelegate(opType string) []*job.Scenario {
+	name := string(UndelegateWorkflow)
+	actions := append(
+		g.setup(name),
+		g.findDelegator(g.config.Amount),
+		g.operations(
+			name,
+			opType,
+			"delegator",
+			"",
+			map[string]interface{}{
+				g.config.ValidatorMetadataKey: g.config.Validators[0],
+				"amount":                      g.config.Amount,
+			},
+		),
+	)
+
+	verify := append(
+		[]*job.Action{findBalance("staked_after", "delegator", g.config.StakedSubAccount)},
+		assertDifference(
+			"staked",
+			"{{delegator.balance.value}}",
+			"{{staked_after.balance.value}}",
+			g.config.Amount,
+		)...,
+	)
+
+	return []*job.Scenario{
+		{Name: name, Actions: actions},
+		{Name: stakingVerifyScenario, Actions: verify},
+	}
+}
+
+func (g *stakingGenerator) claimRewards(opType string) []*job.Scenario {
+	name := string(ClaimRewardsWorkflow)
+	actions := append(g.setup(name), g.findDelegator(minimumStakedBalance))
+	if g.config.RewardsSubAccount != nil {
+		actions = append(
+			actions,
+			findBalance("rewards_before", "delegator", g.config.RewardsSubAccount),
+		)
+	}
+
+	actions = append(actions, g.operations(
+		name,
+		opType,
+		"delegator",
+		"",
+		map[string]interface{}{g.config.ValidatorMetadataKey: g.config.Validators[0]},
+	))
+
+	scenarios := []*job.Scenario{{Name: name, Actions: actions}}
+	if g.config.RewardsSubAccount == nil {
+		return scenarios
+	}
+
+	verify := append(
+		[]*job.Action{findBalance("rewards_after", "delegator", g.config.RewardsSubAccount)},
+		assertDifference(
+			"rewards",
+			"{{rewards_before.balance.value}}",
+			"{{rewards_after.balance.value}}",
+			"0",
+		)...,
+	)
+
+	return append(scenarios, &job.Scenario{Name: stakingVerifyScenario, Actions: verify})
+}
+
+func (g *stakingGenerator) redelegate(opType string) []*job.Scenario {
+	name := string(RedelegateWorkflow)
+	actions := append(
+		g.setup(name),
+		g.findDelegator(g.config.Amount),
+		g.operations(
+			name,
+			opType,
+			"delegator",
+			"",
+			map[string]interface{}{
+				"source_" + g.config.ValidatorMetadataKey: g.config.Validators[0],
+				g.config.ValidatorMetadataKey:             g.config.Validators[1],
+				"amount":                                  g.config.Amount,
+			},
+		),
+	)
+
+	verify := append(
+		[]*job.Action{findBalance("staked_after", "delegator", g.config.StakedSubAccount)},
+		assertDifference(
+			"staked",
+			"{{staked_after.balance.value}}",
+			"{{delegator.balance.value}}",
+			"0",
+		)...,
+	)
+
+	return []*job.Scenario{
+		{Name: name, Actions: actions},
+		{Name: stakingVerifyScenario, Actions: verify},
+	}
+}
+
+// GenerateStakingWorkflows returns the built-in staking
+// workflows configured by config on network.
+func GenerateStakingWorkflows(
+	config *StakingWorkflows,
+	network *types.NetworkIdentifier,
+) []*job.Workflow {
+	g := &stakingGenerator{
+		config:  config,
+		network: types.PrintStruct(network),
+	}
+
+	generators := map[StakingWorkflow]func(string) []*job.Scenario{
+		DelegateWorkflow:     g.delegate,
+		UndelegateWorkflow:   g.undelegate,
+		ClaimRewardsWorkflow: g.claimRewards,
+		RedelegateWorkflow:   g.redelegate,
+	}
+
+	workflows := []*job.Workflow{}
+	for _, name := range stakingWorkflows {
+		opType, ok := config.OperationTypes[name]
+		if !ok {
+			continue
+		}
+
+		workflows = append(workflows, &job.Workflow{
+			Name:        string(name),
+			Concurrency: config.Concurrency,
+			Scenarios:   generators[name](opType),
+		})
+	}
+
+	return workflows
+}
+
+func assertStakingWorkflows(config *StakingWorkflows) error {
+	if config == nil {
+		return nil
+	}
+
+	if len(config.OperationTypes) == 0 {
+		return errors.New("staking operation types must be populated")
+	}
+
+	for name, opType := range config.OperationTypes {
+		switch name {
+		case DelegateWorkflow, UndelegateWorkflow, ClaimRewardsWorkflow, RedelegateWorkflow:
+		default:
+			return fmt.Errorf("%s is not a valid staking workflow", name)
+		}
+
+		if len(opType) == 0 {
+			return fmt.Errorf("operation type for %s is empty", name)
+		}
+	}
+
+	if err := asserter.Currency(config.Currency); err != nil {
+		return fmt.Errorf("%w: invalid staking currency", err)
+	}
+
+	amount, err := types.BigInt(config.Amount)
+	if err != nil || amount.Sign() <= 0 {
+		return fmt.Errorf("staking amount %s must be a positive integer", config.Amount)
+	}
+
+	if config.StakedSubAccount == nil || len(config.StakedSubAccount.Address) == 0 {
+		return errors.New("staked sub-account must be populated")
+	}
+
+	if config.RewardsSubAccount != nil && len(config.RewardsSubAccount.Address) == 0 {
+		return errors.New("rewards sub-account address must be populated")
+	}
+
+	if len(config.Validators) == 0 {
+		return errors.New("at least 1 validator must be populated")
+	}
+
+	if _, ok := config.OperationTypes[RedelegateWorkflow]; ok && len(config.Validators) < 2 {
+		return fmt.Errorf("%s requires at least 2 validators", RedelegateWorkflow)
+	}
+
+	return nil
+}
@@ -0,0 +1,138 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configuration
+
+import (
+	"encoding/json"
+	"regexp"
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/constructor/job"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// placeholder matches the variables
+// populated when an action is run.
+var placeholder = regexp.MustCompile(`{{[a-z_.]+}}`)
+
+func testStakingWorkflows() *StakingWorkflows {
+	return &StakingWorkflows{
+		OperationTypes: map[StakingWorkflow]string{
+			DelegateWorkflow:     "DELEGATE",
+			UndelegateWorkflow:   "UNDELEGATE",
+			ClaimRewardsWorkflow: "CLAIM_REWARDS",
+			RedelegateWorkflow:   "REDELEGATE",
+		},
+		Currency:          &types.Currency{Symbol: "ATOM", Decimals: 6},
+		Amount:            "1000",
+		StakedSubAccount:  &types.SubAccountIdentifier{Address: "staked"},
+		RewardsSubAccount: &types.SubAccountIdentifier{Address: "rewards"},
+		Validators:        []string{"validator-1", "validator-2"},
+	}
+}
+
+func TestGenerateStakingWorkflows(t *testing.T) {
+	network := &types.NetworkIdentifier{Blockchain: "cosmos", Network: "testnet"}
+	config := populateConstructionMissingFields(&ConstructionConfiguration{
+		StakingWorkflows: testStakingWorkflows(),
+	}).StakingWorkflows
+
+	workflows := GenerateStakingWorkflows(config, network)
+	assert.Len(t, workflows, len(stakingWorkflows))
+	for i, workflow := range workflows {
+		assert.Equal(t, string(stakingWorkflows[i]), workflow.Name)
+		assert.Equal(t, DefaultStakingConcurrency, workflow.Concurrency)
+		assert.Len(t, workflow.Scenarios, 2)
+		assert.Equal(t, stakingVerifyScenario, workflow.Scenarios[1].Name)
+
+		for _, scenario := range workflow.Scenarios {
+			for _, action := range scenario.Actions {
+				// All inputs must be valid JSON once
+				// variables are populated.
+				input := placeholder.ReplaceAllString(action.Input, `"1"`)
+				assert.True(t, json.Valid([]byte(input)), action.Input)
+			}
+
+			last := scenario.Actions[len(scenario.Actions)-1]
+			if scenario.Name == stakingVerifyScenario {
+				assert.Equal(t, job.Assert, last.Type)
+			} else {
+				assert.Equal(t, scenario.Name+".operations", last.OutputPath)
+			}
+		}
+	}
+
+	t.Run("subset of workflows", func(t *testing.T) {
+		config := testStakingWorkflows()
+		config.OperationTypes = map[StakingWorkflow]string{ClaimRewardsWorkflow: "CLAIM"}
+		config.RewardsSubAccount = nil
+
+		workflows := GenerateStakingWorkflows(config, network)
+		assert.Len(t, workflows, 1)
+		assert.Equal(t, string(ClaimRewardsWorkflow), workflows[0].Name)
+		assert.Len(t, workflows[0].Scenarios, 1)
+	})
+}
+
+func TestAssertStakingWorkflows(t *testing.T) {
+	var tests = map[string]struct {
+		modify func(*StakingWorkflows)
+
+		err bool
+	}{
+		"valid": {
+			modify: func(*StakingWorkflows) {},
+		},
+		"unknown workflow": {
+			modify: func(config *StakingWorkflows) {
+				config.OperationTypes["slash"] = "SLASH"
+			},
+			err: true,
+		},
+		"invalid amount": {
+			modify: func(config *StakingWorkflows) {
+				config.Amount = "-10"
+			},
+			err: true,
+		},
+		"missing staked sub-account": {
+			modify: func(config *StakingWorkflows) {
+				config.StakedSubAccount = nil
+			},
+			err: true,
+		},
+		"redelegate with 1 validator": {
+			modify: func(config *StakingWorkflows) {
+				config.Validators = []string{"validator-1"}
+			},
+			err: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			config := testStakingWorkflows()
+			test.modify(config)
+
+			err := assertStakingWorkflows(config)
+			if test.err {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,302 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configuration
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/coinbase/rosetta-sdk-go/asserter"
+	"github.com/coinbase/rosetta-sdk-go/constructor/job"
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+const (
+	// TokenTransferWorkflow is the prefix of the name of
+	// the workflow that transfers each token.
+	TokenTransferWorkflow = "token_transfer"
+
+	// TokenTransferFromWorkflow is the prefix of the name of
+	// the workflow that approves a spender to transfer each
+	// token and then transfers it with the spender.
+	TokenTransferFromWorkflow = "token_transfer_from"
+
+	// approveScenario is the name of the scenario that
+	// approves a spender in a transfer from workflow.
+	approveScenario = "approve"
+
+	// transferScenario is the name of the scenario that
+	// transfers tokens in all token workflows.
+	transferScenario = "transfer"
+
+	// tokenVerifyScenario is the name of the scenario
+	// that checks the token balance of the recipient
+	// once a transfer is confirmed.
+	tokenVerifyScenario = "verify"
+
+	// recipientCreateLimit and recipientCreateProbability
+	// control how often a new account is created to
+	// receive a token transfer.
+	recipientCreateLimit       = 100
+	recipientCreateProbability = 50
+)
+
+// tokenGenerator generates the actions of the
+// built-in token workflows for a single token.
+type tokenGenerator struct {
+	config   *TokenWorkflows
+	network  string
+	currency string
+}
+
+// setup returns the actions that set the network of
+// scenario, the token, and the fee currency.
+func (g *tokenGenerator) setup(scenario string) []*job.Action {
+	return []*job.Action{
+		action(job.SetVariable, scenario+".network", g.network),
+		action(job.SetVariable, "currency", g.currency),
+		action(job.SetVariable, "fee_currency", types.PrintStruct(g.config.FeeCurrency)),
+	}
+}
+
+// findFeeBalance returns the action that ensures the account
+// stored at account can pay for gas.
+func (g *tokenGenerator) findFeeBalance(account string) *job.Action {
+	return action(job.FindBalance, account+"_fees", fmt.Sprintf(
+		`{"account_identifier":{{%s.account_identifier}},`+
+			`"minimum_balance":{"value":"%s","currency":{{fee_currency}}}}`,
+		account,
+		g.config.MinimumFeeBalance,
+	))
+}
+
+// findRecipient returns the action that finds (or creates) an
+// account to receive a transfer that is not any of excluded.
+func findRecipient(excluded ...string) *job.Action {
+	accounts := make([]string, len(excluded))
+	for i, account := range excluded {
+		accounts[i] = fmt.Sprintf("{{%s.account_identifier}}", account)
+	}
+
+	return action(job.FindBalance, "recipient", fmt.Sprintf(
+		`{"not_account_identifier":[%s],`+
+			`"minimum_balance":{"value":"0","currency":{{currency}}},`+
+			`"create_limit":%d,"create_probability":%d}`,
+		strings.Join(accounts, ","),
+		recipientCreateLimit,
+		recipientCreateProbability,
+	))
+}
+
+// transfer returns the action that sets the operations of the
+// transfer scenario to a debit of the account stored at sender
+// and a credit of the recipient. If spender is populated, its
+// address is added to the metadata of the debit.
+func (g *tokenGenerator) transfer(sender string, spender string) *job.Action {
+	metadata := ""
+	if len(spender) > 0 {
+		metadata = fmt.Sprintf(
+			`,"metadata":{"%s":{{%s.account_identifier.address}}}`,
+			g.config.SpenderMetadataKey,
+			spender,
+		)
+	}
+
+	return action(job.SetVariable, transferScenario+".operations", fmt.Sprintf(
+		`[{"operation_identifier":{"index":0},"type":"%s",`+
+			`"account":{{%s.account_identifier}},`+
+			`"amount":{"value":"-%s","currency":{{currency}}}%s},`+
+			`{"operation_identifier":{"index":1},"related_operations":[{"index":0}],`+
+			`"type":"%s","account":{{recipient.account_identifier}},`+
+			`"amount":{"value":"%s","currency":{{currency}}}}]`,
+		g.config.TransferOperationType,
+		sender,
+		g.config.Amount,
+		metadata,
+		g.config.TransferOperationType,
+		g.config.Amount,
+	))
+}
+
+// verify returns the scenario that asserts the token balance
+// of the recipient increased by at least Amount.
+func (g *tokenGenerator) verify() *job.Scenario {
+	actions := append(
+		[]*job.Action{
+			action(job.FindBalance, "recipient_after", `{"account_identifier":`+
+				`{{recipient.account_identifier}},`+
+				`"minimum_balance":{"value":"0","currency":{{currency}}}}`),
+		},
+		assertDifference(
+			"recipient",
+			"{{recipient_after.balance.value}}",
+			"{{recipient.balance.value}}",
+			g.config.Amount,
+		)...,
+	)
+
+	return &job.Scenario{Name: tokenVerifyScenario, Actions: actions}
+}
+
+// findOwner returns the action that finds an
+// account holding at least Amount of the token.
+func (g *tokenGenerator) findOwner(outputPath string) *job.Action {
+	return action(job.FindBalance, outputPath, fmt.Sprintf(
+		`{"minimum_balance":{"value":"%s","currency":{{currency}}}}`,
+		g.config.Amount,
+	))
+}
+
+func (g *tokenGenerator) tokenTransfer() []*job.Scenario {
+	actions := append(
+		g.setup(transferScenario),
+		g.findOwner("sender"),
+		g.findFeeBalance("sender"),
+		findRecipient("sender"),
+		g.transfer("sender", ""),
+	)
+
+	return []*job.Scenario{
+		{Name: transferScenario, Actions: actions},
+		g.verify(),
+	}
+}
+
+func (g *tokenGenerator) tokenTransferFrom() []*job.Scenario {
+	approve := append(
+		g.setup(approveScenario),
+		g.findOwner("owner"),
+		g.findFeeBalance("owner"),
+		action(job.FindBalance, "spender", fmt.Sprintf(
+			`{"not_account_identifier":[{{owner.account_identifier}}],`+
+				`"minimum_balance":{"value":"%s","currency":{{fee_currency}}}}`,
+			g.config.MinimumFeeBalance,
+		)),
+		findRecipient("owner", "spender"),
+		action(job.SetVariable, approveScenario+".operations", fmt.Sprintf(
+			`[{"operation_identifier":{"index":0},"type":"%s",`+
+				`"account":{{owner.account_identifier}},`+
+				`"amount":{"value":"0","currency":{{currency}}},`+
+				`"metadata":{"%s":{{spender.account_identifier.address}},"amount":"%s"}}]`,
+			g.config.ApproveOperationType,
+			g.config.SpenderMetadataKey,
+			g.config.Amount,
+		)),
+	)
+
+	transfer := []*job.Action{
+		action(job.SetVariable, transferScenario+".network", g.network),
+		g.transfer("owner", "spender"),
+	}
+
+	return []*job.Scenario{
+		{Name: approveScenario, Actions: approve},
+		{Name: transferScenario, Actions: transfer},
+		g.verify(),
+	}
+}
+
+// tokenWorkflowName returns the name of the
+// workflow with prefix for token.
+func tokenWorkflowName(prefix string, token *types.Currency) string {
+	return fmt.Sprintf("%s_%s", prefix, strings.ToLower(token.Symbol))
+}
+
+// GenerateTokenWorkflows returns the built-in token
+// workflows configured by config on network.
+func GenerateTokenWorkflows(
+	config *TokenWorkflows,
+	network *types.NetworkIdentifier,
+) []*job.Workflow {
+	workflows := []*job.Workflow{}
+	for _, token := range config.Tokens {
+		g := &tokenGenerator{
+			config:   config,
+			network:  types.PrintStruct(network),
+			currency: types.PrintStruct(token),
+		}
+
+		workflows = append(workflows, &job.Workflow{
+			Name:        tokenWorkflowName(TokenTransferWorkflow, token),
+			Concurrency: config.Concurrency,
+			Scenarios:   g.tokenTransfer(),
+		})
+
+		if len(config.ApproveOperationType) == 0 {
+			continue
+		}
+
+		workflows = append(workflows, &job.Workflow{
+			Name:        tokenWorkflowName(TokenTransferFromWorkflow, token),
+			Concurrency: config.Concurrency,
+			Scenarios:   g.tokenTransferFrom(),
+		})
+	}
+
+	return workflows
+}
+
+func assertTokenWorkflows(config *TokenWorkflows) error {
+	if config == nil {
+		return nil
+	}
+
+	if len(config.Tokens) == 0 {
+		return errors.New("at least 1 token must be populated")
+	}
+
+	names := map[string]struct{}{}
+	for _, token := range config.Tokens {
+		if err := asserter.Currency(token); err != nil {
+			return fmt.Errorf("%w: invalid token", err)
+		}
+
+		address, ok := token.Metadata[config.ContractAddressKey].(string)
+		if !ok || len(address) == 0 {
+			return fmt.Errorf(
+				"token %s is missing %s in its metadata",
+				token.Symbol,
+				config.ContractAddressKey,
+			)
+		}
+
+		name := tokenWorkflowName(TokenTransferWorkflow, token)
+		if _, ok := names[name]; ok {
+			return fmt.Errorf("token %s is populated more than once", token.Symbol)
+		}
+
+		names[name] = struct{}{}
+	}
+
+	amount, err := types.BigInt(config.Amount)
+	if err != nil || amount.Sign() <= 0 {
+		return fmt.Errorf("token amount %s must be a positive integer", config.Amount)
+	}
+
+	if err := asserter.Currency(config.FeeCurrency); err != nil {
+		return fmt.Errorf("%w: invalid fee currency", err)
+	}
+
+	minimumFeeBalance, err := types.BigInt(config.MinimumFeeBalance)
+	if err != nil || minimumFeeBalance.Sign() <= 0 {
+		return fmt.Errorf(
+			"minimum fee balance %s must be a positive integer",
+			config.MinimumFeeBalance,
+		)
+	}
+
+	return nil
+}
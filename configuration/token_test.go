@@ -0,0 +1,154 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configuration
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/constructor/job"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func testTokenWorkflows() *TokenWorkflows {
+	return populateConstructionMissingFields(&ConstructionConfiguration{
+		TokenWorkflows: &TokenWorkflows{
+			Tokens: []*types.Currency{
+				{
+					Symbol:   "USDC",
+					Decimals: 6,
+					Metadata: map[string]interface{}{"contract_address": "0xa0b8"},
+				},
+				{
+					Symbol:   "DAI",
+					Decimals: 18,
+					Metadata: map[string]interface{}{"contract_address": "0x6b17"},
+				},
+			},
+			Amount:               "100",
+			FeeCurrency:          &types.Currency{Symbol: "ETH", Decimals: 18},
+			MinimumFeeBalance:    "1000000000000000",
+			ApproveOperationType: "ERC20_APPROVE",
+		},
+	}).TokenWorkflows
+}
+
+func TestGenerateTokenWorkflows(t *testing.T) {
+	network := &types.NetworkIdentifier{Blockchain: "Ethereum", Network: "Ropsten"}
+	workflows := GenerateTokenWorkflows(testTokenWorkflows(), network)
+
+	names := []string{
+		"token_transfer_usdc",
+		"token_transfer_from_usdc",
+		"token_transfer_dai",
+		"token_transfer_from_dai",
+	}
+	assert.Len(t, workflows, len(names))
+	for i, workflow := range workflows {
+		assert.Equal(t, names[i], workflow.Name)
+		assert.Equal(t, DefaultTokenConcurrency, workflow.Concurrency)
+
+		last := workflow.Scenarios[len(workflow.Scenarios)-1]
+		assert.Equal(t, tokenVerifyScenario, last.Name)
+		assert.Equal(t, job.Assert, last.Actions[len(last.Actions)-1].Type)
+
+		for _, scenario := range workflow.Scenarios {
+			for _, action := range scenario.Actions {
+				// All inputs must be valid JSON once
+				// variables are populated.
+				input := placeholder.ReplaceAllString(action.Input, `"1"`)
+				assert.True(t, json.Valid([]byte(input)), action.Input)
+			}
+
+			if scenario.Name != tokenVerifyScenario {
+				last := scenario.Actions[len(scenario.Actions)-1]
+				assert.Equal(t, scenario.Name+".operations", last.OutputPath)
+			}
+		}
+	}
+
+	t.Run("without approvals", func(t *testing.T) {
+		config := testTokenWorkflows()
+		config.ApproveOperationType = ""
+
+		workflows := GenerateTokenWorkflows(config, network)
+		assert.Len(t, workflows, 2)
+		assert.Equal(t, "token_transfer_usdc", workflows[0].Name)
+		assert.Equal(t, "token_transfer_dai", workflows[1].Name)
+	})
+}
+
+func TestAssertTokenWorkflows(t *testing.T) {
+	var tests = map[string]struct {
+		modify func(*TokenWorkflows)
+
+		err bool
+	}{
+		"valid": {
+			modify: func(*TokenWorkflows) {},
+		},
+		"no tokens": {
+			modify: func(config *TokenWorkflows) {
+				config.Tokens = nil
+			},
+			err: true,
+		},
+		"missing contract address": {
+			modify: func(config *TokenWorkflows) {
+				config.Tokens[0].Metadata = nil
+			},
+			err: true,
+		},
+		"duplicate token": {
+			modify: func(config *TokenWorkflows) {
+				config.Tokens[1].Symbol = "usdc"
+			},
+			err: true,
+		},
+		"invalid amount": {
+			modify: func(config *TokenWorkflows) {
+				config.Amount = "0"
+			},
+			err: true,
+		},
+		"missing fee currency": {
+			modify: func(config *TokenWorkflows) {
+				config.FeeCurrency = nil
+			},
+			err: true,
+		},
+		"invalid minimum fee balance": {
+			modify: func(config *TokenWorkflows) {
+				config.MinimumFeeBalance = "gas"
+			},
+			err: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			config := testTokenWorkflows()
+			test.modify(config)
+
+			err := assertTokenWorkflows(config)
+			if test.err {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
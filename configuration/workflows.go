@@ -0,0 +1,171 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configuration
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/coinbase/rosetta-sdk-go/constructor/dsl"
+	"github.com/coinbase/rosetta-sdk-go/constructor/job"
+	"github.com/coinbase/rosetta-sdk-go/utils"
+)
+
+const (
+	// dslExtension is the extension of Rosetta
+	// Constructor DSL workflow files.
+	dslExtension = ".ros"
+
+	// jsonExtension is the extension of workflow files
+	// containing a JSON workflow (or array of workflows).
+	jsonExtension = ".json"
+)
+
+// ErrNoWorkflowFiles is returned when no workflow files
+// in a workflows directory match the provided globs.
+var ErrNoWorkflowFiles = errors.New("no workflow files found")
+
+// matchesAny returns a boolean indicating if name matches
+// any of globs (using filepath.Match syntax).
+func matchesAny(globs []string, name string) (bool, error) {
+	for _, glob := range globs {
+		matched, err := filepath.Match(glob, name)
+		if err != nil {
+			return false, fmt.Errorf("%w: invalid glob %s", err, glob)
+		}
+
+		if matched {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// workflowFiles returns the paths of all workflow files in
+// directory (and its subdirectories) in lexical order. A file
+// is included if its path relative to directory matches any
+// of include (or include is empty) and none of exclude.
+func workflowFiles(directory string, include []string, exclude []string) ([]string, error) {
+	files := []string{}
+	err := filepath.Walk(directory, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		extension := filepath.Ext(filePath)
+		if info.IsDir() || (extension != dslExtension && extension != jsonExtension) {
+			return nil
+		}
+
+		relativePath, err := filepath.Rel(directory, filePath)
+		if err != nil {
+			return err
+		}
+		relativePath = filepath.ToSlash(relativePath)
+
+		if len(include) > 0 {
+			included, err := matchesAny(include, relativePath)
+			if err != nil || !included {
+				return err
+			}
+		}
+
+		excluded, err := matchesAny(exclude, relativePath)
+		if err != nil || excluded {
+			return err
+		}
+
+		files = append(files, filePath)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to walk %s", err, directory)
+	}
+
+	return files, nil
+}
+
+// loadWorkflowFile returns the workflows in a DSL file or
+// in a JSON file (containing a workflow or an array of
+// workflows).
+func loadWorkflowFile(ctx context.Context, filePath string) ([]*job.Workflow, error) {
+	if filepath.Ext(filePath) == dslExtension {
+		workflows, err := dsl.Parse(ctx, filePath)
+		if err != nil {
+			err.Log()
+			return nil, fmt.Errorf("%w: compilation of %s failed", err.Err, filePath)
+		}
+
+		return workflows, nil
+	}
+
+	contents, err := ioutil.ReadFile(filePath) // #nosec G304
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to read %s", err, filePath)
+	}
+
+	if bytes.HasPrefix(bytes.TrimSpace(contents), []byte("[")) {
+		workflows := []*job.Workflow{}
+		if err := utils.LoadAndParse(filePath, &workflows); err != nil {
+			return nil, fmt.Errorf("%w: unable to parse %s", err, filePath)
+		}
+
+		return workflows, nil
+	}
+
+	var workflow job.Workflow
+	if err := utils.LoadAndParse(filePath, &workflow); err != nil {
+		return nil, fmt.Errorf("%w: unable to parse %s", err, filePath)
+	}
+
+	return []*job.Workflow{&workflow}, nil
+}
+
+// LoadWorkflowsDirectory returns all workflows in the workflow
+// files (*.ros and *.json) in directory that match include
+// and exclude.
+func LoadWorkflowsDirectory(
+	ctx context.Context,
+	directory string,
+	include []string,
+	exclude []string,
+) ([]*job.Workflow, error) {
+	files, err := workflowFiles(directory, include, exclude)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("%w: in %s", ErrNoWorkflowFiles, directory)
+	}
+
+	workflows := []*job.Workflow{}
+	for _, filePath := range files {
+		fileWorkflows, err := loadWorkflowFile(ctx, filePath)
+		if err != nil {
+			return nil, err
+		}
+
+		workflows = append(workflows, fileWorkflows...)
+	}
+
+	return workflows, nil
+}
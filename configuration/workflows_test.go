@@ -0,0 +1,81 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configuration
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadWorkflowsDirectory(t *testing.T) {
+	var tests = map[string]struct {
+		include []string
+		exclude []string
+
+		workflows []string
+		err       error
+	}{
+		"all files": {
+			workflows: []string{"create_account", "request_funds", "stake", "unstake", "transfer"},
+		},
+		"include": {
+			include:   []string{"staking/*"},
+			workflows: []string{"stake", "unstake"},
+		},
+		"exclude": {
+			exclude:   []string{"staking/*", "*.ros"},
+			workflows: []string{"transfer"},
+		},
+		"no matching files": {
+			include: []string{"*.yaml"},
+			err:     ErrNoWorkflowFiles,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			workflows, err := LoadWorkflowsDirectory(
+				context.Background(),
+				"testdata/workflows",
+				test.include,
+				test.exclude,
+			)
+			if test.err != nil {
+				assert.True(t, errors.Is(err, test.err))
+				return
+			}
+
+			assert.NoError(t, err)
+			names := []string{}
+			for _, workflow := range workflows {
+				names = append(names, workflow.Name)
+			}
+			assert.Equal(t, test.workflows, names)
+		})
+	}
+
+	t.Run("invalid glob", func(t *testing.T) {
+		_, err := LoadWorkflowsDirectory(
+			context.Background(),
+			"testdata/workflows",
+			[]string{"["},
+			nil,
+		)
+		assert.Error(t, err)
+	})
+}
@@ -3,10 +3,15 @@ module github.com/coinbase/rosetta-cli
 go 1.13
 
 require (
+	github.com/BurntSushi/toml v0.3.1
 	github.com/coinbase/rosetta-sdk-go v0.6.0
+	github.com/dgraph-io/badger/v2 v2.2007.2
 	github.com/fatih/color v1.10.0
 	github.com/olekukonko/tablewriter v0.0.2-0.20190409134802-7e037d187b0c
 	github.com/spf13/cobra v1.1.1
 	github.com/stretchr/testify v1.6.1
+	github.com/tidwall/gjson v1.6.1
+	golang.org/x/crypto v0.0.0-20200820211705-5c72a883971a
 	golang.org/x/sync v0.0.0-20200625203802-6e8e738ad208
+	gopkg.in/yaml.v3 v3.0.0-20200313102051-9f266ea9e77c
 )
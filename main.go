@@ -26,6 +26,6 @@ func main() {
 	err := cmd.Execute()
 	if err != nil {
 		color.Red("Command Failed: %s", err.Error())
-		os.Exit(1)
+		os.Exit(cmd.ExitCode(err))
 	}
 }
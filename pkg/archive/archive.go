@@ -0,0 +1,382 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package archive
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"sync"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+const (
+	// indexFileName is the name of the file that
+	// records where each block is stored.
+	indexFileName = "index.dat"
+
+	// segmentFilePattern is the pattern used to
+	// name segment files.
+	segmentFilePattern = "segment-%06d.dat"
+
+	// entrySize is the size of each index entry
+	// (index, segment, offset, and length).
+	entrySize = 24
+
+	filePermissions = 0600
+	dirPermissions  = 0700
+)
+
+var (
+	// ErrBlockNotFound is returned when a block
+	// is not stored in the archive.
+	ErrBlockNotFound = errors.New("block not found in archive")
+
+	// ErrOutOfOrder is returned when a block is appended
+	// with an index that is not greater than the index
+	// of the last archived block.
+	ErrOutOfOrder = errors.New("block appended out of order")
+)
+
+// file is the subset of *os.File used to write
+// the index and segment files.
+type file interface {
+	io.WriterAt
+	Sync() error
+	Close() error
+}
+
+// entry records where a block is stored.
+type entry struct {
+	index   int64
+	segment uint32
+	offset  int64
+	length  uint32
+}
+
+func (e *entry) encode() []byte {
+	b := make([]byte, entrySize)
+	binary.BigEndian.PutUint64(b[0:8], uint64(e.index))
+	binary.BigEndian.PutUint32(b[8:12], e.segment)
+	binary.BigEndian.PutUint64(b[12:20], uint64(e.offset))
+	binary.BigEndian.PutUint32(b[20:24], e.length)
+	return b
+}
+
+func decodeEntry(b []byte) entry {
+	return entry{
+		index:   int64(binary.BigEndian.Uint64(b[0:8])),
+		segment: binary.BigEndian.Uint32(b[8:12]),
+		offset:  int64(binary.BigEndian.Uint64(b[12:20])),
+		length:  binary.BigEndian.Uint32(b[20:24]),
+	}
+}
+
+// Archive is an append-only store of blocks. Blocks are
+// written (as JSON) to segment files of a bounded size and
+// the location of each block is recorded in an index file.
+// Files are never modified once written (except to remove
+// a partial write after a crash), so an archive can be
+// copied incrementally with tools like rsync.
+type Archive struct {
+	directory   string
+	segmentSize int64
+
+	mutex   sync.RWMutex
+	entries []entry
+
+	indexFile     file
+	segmentFile   file
+	segment       uint32
+	segmentOffset int64
+}
+
+// Open opens (or creates) the archive in directory. New
+// segment files are started once a segment exceeds
+// segmentSize bytes. Any partially written blocks (from
+// an unclean shutdown) are removed.
+func Open(directory string, segmentSize int64) (*Archive, error) {
+	if err := os.MkdirAll(directory, dirPermissions); err != nil {
+		return nil, fmt.Errorf("%w: unable to create archive directory", err)
+	}
+
+	a := &Archive{
+		directory:   directory,
+		segmentSize: segmentSize,
+	}
+
+	if err := a.loadIndex(); err != nil {
+		return nil, err
+	}
+
+	offset := int64(0)
+	if len(a.entries) > 0 {
+		last := a.entries[len(a.entries)-1]
+		a.segment = last.segment
+		offset = last.offset + int64(last.length)
+	}
+
+	if err := a.openSegment(offset); err != nil {
+		_ = a.indexFile.Close()
+		return nil, err
+	}
+
+	return a, nil
+}
+
+func (a *Archive) segmentPath(segment uint32) string {
+	return path.Join(a.directory, fmt.Sprintf(segmentFilePattern, segment))
+}
+
+// loadIndex reads all entries from the index file and
+// discards any entries whose block was not completely
+// written to its segment file.
+func (a *Archive) loadIndex() error {
+	indexPath := path.Join(a.directory, indexFileName)
+	f, err := os.OpenFile(indexPath, os.O_RDWR|os.O_CREATE, filePermissions) // #nosec G304
+	if err != nil {
+		return fmt.Errorf("%w: unable to open archive index", err)
+	}
+
+	contents, err := ioutil.ReadAll(f)
+	if err != nil {
+		_ = f.Close()
+		return fmt.Errorf("%w: unable to read archive index", err)
+	}
+
+	segmentSizes := map[uint32]int64{}
+	entries := make([]entry, 0, len(contents)/entrySize)
+	for i := 0; i+entrySize <= len(contents); i += entrySize {
+		e := decodeEntry(contents[i : i+entrySize])
+
+		size, ok := segmentSizes[e.segment]
+		if !ok {
+			info, err := os.Stat(a.segmentPath(e.segment))
+			if err == nil {
+				size = info.Size()
+			}
+
+			segmentSizes[e.segment] = size
+		}
+
+		if e.offset+int64(e.length) > size {
+			break
+		}
+
+		entries = append(entries, e)
+	}
+
+	validSize := int64(len(entries) * entrySize)
+	if err := f.Truncate(validSize); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("%w: unable to truncate archive index", err)
+	}
+
+	a.indexFile = f
+	a.entries = entries
+	return nil
+}
+
+// openSegment opens the current segment file for appending
+// and removes any data written after offset (the end of
+// the last indexed block).
+func (a *Archive) openSegment(offset int64) error {
+	f, err := os.OpenFile( // #nosec G304
+		a.segmentPath(a.segment),
+		os.O_RDWR|os.O_CREATE,
+		filePermissions,
+	)
+	if err != nil {
+		return fmt.Errorf("%w: unable to open segment %d", err, a.segment)
+	}
+
+	if err := f.Truncate(offset); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("%w: unable to truncate segment %d", err, a.segment)
+	}
+
+	a.segmentFile = f
+	a.segmentOffset = offset
+	return nil
+}
+
+// rotateSegment closes the current segment
+// file and starts a new segment file.
+func (a *Archive) rotateSegment() error {
+	if err := a.segmentFile.Sync(); err != nil {
+		return fmt.Errorf("%w: unable to sync segment %d", err, a.segment)
+	}
+
+	if err := a.segmentFile.Close(); err != nil {
+		return fmt.Errorf("%w: unable to close segment %d", err, a.segment)
+	}
+
+	a.segment++
+	return a.openSegment(0)
+}
+
+// Append adds block to the archive. Blocks must be
+// appended in increasing order of index (gaps are
+// allowed).
+func (a *Archive) Append(block *types.Block) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	index := block.BlockIdentifier.Index
+	if len(a.entries) > 0 && index <= a.entries[len(a.entries)-1].index {
+		return fmt.Errorf(
+			"%w: block %d is not after block %d",
+			ErrOutOfOrder,
+			index,
+			a.entries[len(a.entries)-1].index,
+		)
+	}
+
+	data, err := json.Marshal(block)
+	if err != nil {
+		return fmt.Errorf("%w: unable to encode block %d", err, index)
+	}
+
+	if a.segmentOffset > 0 && a.segmentOffset+int64(len(data)) > a.segmentSize {
+		if err := a.rotateSegment(); err != nil {
+			return err
+		}
+	}
+
+	// The block is written before its index entry so that
+	// the index never references data that was not written.
+	// Writes are made at the end of the valid data (instead
+	// of the file position), so a failed or partial write is
+	// overwritten by the next append.
+	if _, err := a.segmentFile.WriteAt(data, a.segmentOffset); err != nil {
+		return fmt.Errorf("%w: unable to write block %d", err, index)
+	}
+
+	e := entry{
+		index:   index,
+		segment: a.segment,
+		offset:  a.segmentOffset,
+		length:  uint32(len(data)),
+	}
+	indexOffset := int64(len(a.entries) * entrySize)
+	if _, err := a.indexFile.WriteAt(e.encode(), indexOffset); err != nil {
+		return fmt.Errorf("%w: unable to index block %d", err, index)
+	}
+
+	a.segmentOffset += int64(len(data))
+	a.entries = append(a.entries, e)
+	return nil
+}
+
+// Get returns the archived block with index.
+func (a *Archive) Get(index int64) (*types.Block, error) {
+	a.mutex.RLock()
+	i := sort.Search(len(a.entries), func(i int) bool {
+		return a.entries[i].index >= index
+	})
+	if i == len(a.entries) || a.entries[i].index != index {
+		a.mutex.RUnlock()
+		return nil, fmt.Errorf("%w: %d", ErrBlockNotFound, index)
+	}
+	e := a.entries[i]
+	a.mutex.RUnlock()
+
+	f, err := os.Open(a.segmentPath(e.segment)) // #nosec G304
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to open segment %d", err, e.segment)
+	}
+	defer f.Close()
+
+	data := make([]byte, e.length)
+	if _, err := f.ReadAt(data, e.offset); err != nil {
+		return nil, fmt.Errorf("%w: unable to read block %d", err, index)
+	}
+
+	var block types.Block
+	if err := json.Unmarshal(data, &block); err != nil {
+		return nil, fmt.Errorf("%w: unable to decode block %d", err, index)
+	}
+
+	return &block, nil
+}
+
+// FirstIndex returns the index of the first archived
+// block (or -1 if no blocks are archived).
+func (a *Archive) FirstIndex() int64 {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	if len(a.entries) == 0 {
+		return -1
+	}
+
+	return a.entries[0].index
+}
+
+// LastIndex returns the index of the last archived
+// block (or -1 if no blocks are archived).
+func (a *Archive) LastIndex() int64 {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	if len(a.entries) == 0 {
+		return -1
+	}
+
+	return a.entries[len(a.entries)-1].index
+}
+
+// Sync flushes all appended blocks to disk.
+func (a *Archive) Sync() error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if err := a.segmentFile.Sync(); err != nil {
+		return fmt.Errorf("%w: unable to sync segment %d", err, a.segment)
+	}
+
+	if err := a.indexFile.Sync(); err != nil {
+		return fmt.Errorf("%w: unable to sync archive index", err)
+	}
+
+	return nil
+}
+
+// Close syncs and closes the archive.
+func (a *Archive) Close() error {
+	if err := a.Sync(); err != nil {
+		return err
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if err := a.segmentFile.Close(); err != nil {
+		return fmt.Errorf("%w: unable to close segment %d", err, a.segment)
+	}
+
+	if err := a.indexFile.Close(); err != nil {
+		return fmt.Errorf("%w: unable to close archive index", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,238 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package archive
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/coinbase/rosetta-sdk-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func testBlock(index int64) *types.Block {
+	block := &types.Block{
+		BlockIdentifier: &types.BlockIdentifier{
+			Hash:  fmt.Sprintf("block %d", index),
+			Index: index,
+		},
+		ParentBlockIdentifier: &types.BlockIdentifier{
+			Hash:  fmt.Sprintf("block %d", index-1),
+			Index: index - 1,
+		},
+		Timestamp: 1000 + index,
+	}
+
+	if index == 0 {
+		block.ParentBlockIdentifier = block.BlockIdentifier
+	}
+
+	return block
+}
+
+func TestArchive(t *testing.T) {
+	dir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(dir)
+
+	// A small segment size ensures blocks are
+	// written to multiple segment files.
+	a, err := Open(dir, 400)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(-1), a.FirstIndex())
+	assert.Equal(t, int64(-1), a.LastIndex())
+
+	for _, index := range []int64{2, 3, 4, 6, 7, 8, 9} {
+		assert.NoError(t, a.Append(testBlock(index)))
+	}
+
+	err = a.Append(testBlock(5))
+	assert.True(t, errors.Is(err, ErrOutOfOrder))
+	assert.NoError(t, a.Close())
+
+	_, err = os.Stat(path.Join(dir, fmt.Sprintf(segmentFilePattern, 1)))
+	assert.NoError(t, err)
+
+	a, err = Open(dir, 400)
+	assert.NoError(t, err)
+	defer a.Close()
+
+	assert.Equal(t, int64(2), a.FirstIndex())
+	assert.Equal(t, int64(9), a.LastIndex())
+
+	var tests = map[string]struct {
+		index int64
+		err   error
+	}{
+		"first block": {
+			index: 2,
+		},
+		"last block": {
+			index: 9,
+		},
+		"gap": {
+			index: 5,
+			err:   ErrBlockNotFound,
+		},
+		"before first block": {
+			index: 1,
+			err:   ErrBlockNotFound,
+		},
+		"after last block": {
+			index: 10,
+			err:   ErrBlockNotFound,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			block, err := a.Get(test.index)
+			if test.err != nil {
+				assert.True(t, errors.Is(err, test.err))
+				assert.Nil(t, block)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, testBlock(test.index), block)
+		})
+	}
+}
+
+func TestArchivePartialWrite(t *testing.T) {
+	dir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(dir)
+
+	a, err := Open(dir, 1<<20)
+	assert.NoError(t, err)
+	for index := int64(0); index < 3; index++ {
+		assert.NoError(t, a.Append(testBlock(index)))
+	}
+	assert.NoError(t, a.Close())
+
+	// Simulate a crash while appending block 3 (the index
+	// entry is written but the block is truncated).
+	indexPath := path.Join(dir, indexFileName)
+	contents, err := ioutil.ReadFile(indexPath)
+	assert.NoError(t, err)
+	last := decodeEntry(contents[len(contents)-entrySize:])
+	partial := entry{
+		index:   3,
+		segment: last.segment,
+		offset:  last.offset + int64(last.length),
+		length:  100,
+	}
+	contents = append(contents, partial.encode()...)
+	contents = append(contents, []byte{1, 2}...)
+	assert.NoError(t, ioutil.WriteFile(indexPath, contents, filePermissions))
+
+	segmentPath := path.Join(dir, fmt.Sprintf(segmentFilePattern, last.segment))
+	f, err := os.OpenFile(segmentPath, os.O_APPEND|os.O_WRONLY, filePermissions)
+	assert.NoError(t, err)
+	_, err = f.Write([]byte(`{"block_identifier"`))
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+
+	a, err = Open(dir, 1<<20)
+	assert.NoError(t, err)
+	defer a.Close()
+	assert.Equal(t, int64(2), a.LastIndex())
+
+	assert.NoError(t, a.Append(testBlock(3)))
+	block, err := a.Get(3)
+	assert.NoError(t, err)
+	assert.Equal(t, testBlock(3), block)
+
+	block, err = a.Get(2)
+	assert.NoError(t, err)
+	assert.Equal(t, testBlock(2), block)
+}
+
+var _ file = (*failingFile)(nil)
+
+// failingFile writes half of the next write
+// to file and then returns an error.
+type failingFile struct {
+	file
+}
+
+func (f *failingFile) WriteAt(b []byte, off int64) (int, error) {
+	n, err := f.file.WriteAt(b[:len(b)/2], off)
+	if err != nil {
+		return n, err
+	}
+
+	return n, errors.New("write failed")
+}
+
+func TestArchiveFailedWrite(t *testing.T) {
+	var tests = map[string]struct {
+		fail func(a *Archive) func()
+	}{
+		"segment write fails": {
+			fail: func(a *Archive) func() {
+				segmentFile := a.segmentFile
+				a.segmentFile = &failingFile{file: segmentFile}
+				return func() { a.segmentFile = segmentFile }
+			},
+		},
+		"index write fails": {
+			fail: func(a *Archive) func() {
+				indexFile := a.indexFile
+				a.indexFile = &failingFile{file: indexFile}
+				return func() { a.indexFile = indexFile }
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			dir, err := utils.CreateTempDir()
+			assert.NoError(t, err)
+			defer utils.RemoveTempDir(dir)
+
+			a, err := Open(dir, 1<<20)
+			assert.NoError(t, err)
+			assert.NoError(t, a.Append(testBlock(0)))
+
+			restore := test.fail(a)
+			assert.Error(t, a.Append(testBlock(1)))
+			restore()
+			assert.Equal(t, int64(0), a.LastIndex())
+
+			for index := int64(1); index < 3; index++ {
+				assert.NoError(t, a.Append(testBlock(index)))
+			}
+			assert.NoError(t, a.Close())
+
+			a, err = Open(dir, 1<<20)
+			assert.NoError(t, err)
+			defer a.Close()
+			assert.Equal(t, int64(2), a.LastIndex())
+
+			for index := int64(0); index < 3; index++ {
+				block, err := a.Get(index)
+				assert.NoError(t, err)
+				assert.Equal(t, testBlock(index), block)
+			}
+		})
+	}
+}
@@ -0,0 +1,392 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bootstrap
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/coinbase/rosetta-sdk-go/asserter"
+	"github.com/coinbase/rosetta-sdk-go/fetcher"
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/coinbase/rosetta-sdk-go/utils"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	// csvExtension is the file extension used to determine
+	// if a bootstrap balances file should be parsed as CSV.
+	csvExtension = ".csv"
+
+	// importBatchSize is the number of balances to write
+	// in a single database transaction. Writing all balances
+	// in a single transaction can exceed the transaction size
+	// limit of the database for large files.
+	importBatchSize = 5000
+
+	// validationConcurrency is the number of concurrent
+	// /account/balance requests to make when validating
+	// imported balances.
+	validationConcurrency = 16
+
+	// logFrequency is the number of balances to process
+	// between progress logs.
+	logFrequency = 100000
+)
+
+// CSV columns (in order) for a bootstrap balances file.
+const (
+	csvAddress = iota
+	csvSubAccountAddress
+	csvSymbol
+	csvDecimals
+	csvValue
+
+	csvColumns
+)
+
+var (
+	// ErrInvalidBalance is returned when a parsed bootstrap
+	// balance is not valid.
+	ErrInvalidBalance = errors.New("invalid bootstrap balance")
+
+	// ErrBalanceMismatch is returned when an imported balance
+	// does not match the balance returned by /account/balance
+	// at the genesis block.
+	ErrBalanceMismatch = errors.New("bootstrap balance mismatch")
+)
+
+// Handler is invoked on each balance parsed from a
+// bootstrap balances file.
+type Handler func(*storage.BootstrapBalance) error
+
+// StreamBalances parses the bootstrap balances file at filePath
+// and invokes handler with each balance. Files ending in .csv
+// are parsed as CSV, all other files are parsed as a JSON array
+// (the same format accepted by storage.BootstrapBalances).
+//
+// Balances are parsed one at a time so that very large files
+// never need to be loaded into memory.
+func StreamBalances(filePath string, handler Handler) error {
+	f, err := os.Open(path.Clean(filePath))
+	if err != nil {
+		return fmt.Errorf("%w: unable to open bootstrap balances file", err)
+	}
+	defer f.Close()
+
+	if strings.EqualFold(path.Ext(filePath), csvExtension) {
+		return streamCSV(f, handler)
+	}
+
+	return streamJSON(f, handler)
+}
+
+// streamJSON parses a JSON array of *storage.BootstrapBalance
+// one element at a time.
+func streamJSON(r io.Reader, handler Handler) error {
+	decoder := json.NewDecoder(r)
+	token, err := decoder.Token()
+	if err != nil {
+		return fmt.Errorf("%w: unable to read opening token", err)
+	}
+
+	if delim, ok := token.(json.Delim); !ok || delim != '[' {
+		return fmt.Errorf("%w: expected JSON array", ErrInvalidBalance)
+	}
+
+	for i := 0; decoder.More(); i++ {
+		var balance storage.BootstrapBalance
+		if err := decoder.Decode(&balance); err != nil {
+			return fmt.Errorf("%w: unable to decode balance %d", err, i)
+		}
+
+		if err := validateBalance(&balance); err != nil {
+			return fmt.Errorf("%w: balance %d", err, i)
+		}
+
+		if err := handler(&balance); err != nil {
+			return err
+		}
+	}
+
+	if _, err := decoder.Token(); err != nil {
+		return fmt.Errorf("%w: unable to read closing token", err)
+	}
+
+	return nil
+}
+
+// streamCSV parses a CSV file with the header
+// address,sub_account_address,symbol,decimals,value
+// one row at a time.
+func streamCSV(r io.Reader, handler Handler) error {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = csvColumns
+	reader.ReuseRecord = true
+
+	// Skip header
+	if _, err := reader.Read(); err != nil {
+		return fmt.Errorf("%w: unable to read CSV header", err)
+	}
+
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%w: unable to read CSV line %d", err, line)
+		}
+
+		decimals, err := strconv.ParseInt(record[csvDecimals], 10, 32)
+		if err != nil {
+			return fmt.Errorf("%w: unable to parse decimals on line %d", err, line)
+		}
+
+		balance := &storage.BootstrapBalance{
+			Account: &types.AccountIdentifier{
+				Address: record[csvAddress],
+			},
+			Currency: &types.Currency{
+				Symbol:   record[csvSymbol],
+				Decimals: int32(decimals),
+			},
+			Value: record[csvValue],
+		}
+
+		if len(record[csvSubAccountAddress]) > 0 {
+			balance.Account.SubAccount = &types.SubAccountIdentifier{
+				Address: record[csvSubAccountAddress],
+			}
+		}
+
+		if err := validateBalance(balance); err != nil {
+			return fmt.Errorf("%w: line %d", err, line)
+		}
+
+		if err := handler(balance); err != nil {
+			return err
+		}
+	}
+}
+
+// validateBalance ensures a *storage.BootstrapBalance
+// is well-formatted and positive.
+func validateBalance(balance *storage.BootstrapBalance) error {
+	if err := asserter.AccountIdentifier(balance.Account); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidBalance, err)
+	}
+
+	if err := asserter.Currency(balance.Currency); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidBalance, err)
+	}
+
+	value, ok := new(big.Int).SetString(balance.Value, 10)
+	if !ok {
+		return fmt.Errorf("%w: %s is not an integer", ErrInvalidBalance, balance.Value)
+	}
+
+	if value.Sign() < 1 {
+		return fmt.Errorf(
+			"%w: cannot bootstrap zero or negative balance %s",
+			ErrInvalidBalance,
+			balance.Value,
+		)
+	}
+
+	return nil
+}
+
+// BalanceSetter sets the balance of an account (implemented
+// by *storage.BalanceStorage).
+type BalanceSetter interface {
+	SetBalance(
+		ctx context.Context,
+		dbTransaction storage.DatabaseTransaction,
+		account *types.AccountIdentifier,
+		amount *types.Amount,
+		block *types.BlockIdentifier,
+	) error
+}
+
+// ImportBalances streams the balances in filePath into
+// balanceStorage at the genesis block. Balances are committed
+// in batches of importBatchSize.
+func ImportBalances(
+	ctx context.Context,
+	database storage.Database,
+	balanceStorage BalanceSetter,
+	filePath string,
+	genesisBlock *types.BlockIdentifier,
+) (int, error) {
+	imported := 0
+	dbTx := database.NewDatabaseTransaction(ctx, true)
+	defer func() {
+		// dbTx is replaced after each commit, so we must
+		// discard whichever transaction is open on exit.
+		dbTx.Discard(ctx)
+	}()
+
+	err := StreamBalances(filePath, func(balance *storage.BootstrapBalance) error {
+		if err := balanceStorage.SetBalance(
+			ctx,
+			dbTx,
+			balance.Account,
+			&types.Amount{
+				Value:    balance.Value,
+				Currency: balance.Currency,
+			},
+			genesisBlock,
+		); err != nil {
+			return fmt.Errorf("%w: unable to set balance", err)
+		}
+
+		imported++
+		if imported%importBatchSize != 0 {
+			return nil
+		}
+
+		if err := dbTx.Commit(ctx); err != nil {
+			return fmt.Errorf("%w: unable to commit balances", err)
+		}
+		dbTx = database.NewDatabaseTransaction(ctx, true)
+
+		if imported%logFrequency == 0 {
+			log.Printf("%d balances bootstrapped\n", imported)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return -1, err
+	}
+
+	if err := dbTx.Commit(ctx); err != nil {
+		return -1, fmt.Errorf("%w: unable to commit balances", err)
+	}
+
+	log.Printf("%d Balances Bootstrapped\n", imported)
+	return imported, nil
+}
+
+// ValidateBalances streams the balances in filePath and
+// ensures each one matches the balance returned by
+// /account/balance at the genesis block.
+func ValidateBalances(
+	ctx context.Context,
+	network *types.NetworkIdentifier,
+	fetcher *fetcher.Fetcher,
+	filePath string,
+	genesisBlock *types.BlockIdentifier,
+) error {
+	balances := make(chan *storage.BootstrapBalance)
+	g, ctx := errgroup.WithContext(ctx)
+	g.Go(func() error {
+		defer close(balances)
+
+		return StreamBalances(filePath, func(balance *storage.BootstrapBalance) error {
+			select {
+			case balances <- balance:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+	})
+
+	for i := 0; i < validationConcurrency; i++ {
+		g.Go(func() error {
+			for balance := range balances {
+				if err := validateLiveBalance(
+					ctx,
+					network,
+					fetcher,
+					balance,
+					genesisBlock,
+				); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+	}
+
+	if err := g.Wait(); err != nil {
+		return err
+	}
+
+	log.Println("all bootstrap balances match live balances at genesis")
+	return nil
+}
+
+func validateLiveBalance(
+	ctx context.Context,
+	network *types.NetworkIdentifier,
+	fetcher *fetcher.Fetcher,
+	balance *storage.BootstrapBalance,
+	genesisBlock *types.BlockIdentifier,
+) error {
+	amount, block, err := utils.CurrencyBalance(
+		ctx,
+		network,
+		fetcher,
+		balance.Account,
+		balance.Currency,
+		genesisBlock.Index,
+	)
+	if err != nil {
+		return fmt.Errorf(
+			"%w: unable to fetch balance for %s",
+			err,
+			types.AccountString(balance.Account),
+		)
+	}
+
+	if types.Hash(block) != types.Hash(genesisBlock) {
+		return fmt.Errorf(
+			"%w: balance for %s fetched at %s instead of genesis %s",
+			ErrBalanceMismatch,
+			types.AccountString(balance.Account),
+			types.PrintStruct(block),
+			types.PrintStruct(genesisBlock),
+		)
+	}
+
+	if amount.Value != balance.Value {
+		return fmt.Errorf(
+			"%w: %s bootstrapped %s%s but live balance is %s%s",
+			ErrBalanceMismatch,
+			types.AccountString(balance.Account),
+			balance.Value,
+			balance.Currency.Symbol,
+			amount.Value,
+			balance.Currency.Symbol,
+		)
+	}
+
+	return nil
+}
@@ -0,0 +1,133 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bootstrap
+
+import (
+	"errors"
+	"io/ioutil"
+	"path"
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/coinbase/rosetta-sdk-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	btc = &types.Currency{
+		Symbol:   "BTC",
+		Decimals: 8,
+	}
+)
+
+func TestStreamBalances(t *testing.T) {
+	var tests = map[string]struct {
+		fileName string
+		contents string
+
+		expected []*storage.BootstrapBalance
+		err      error
+	}{
+		"json": {
+			fileName: "balances.json",
+			contents: `[
+				{"account_identifier":{"address":"addr1"},"currency":{"symbol":"BTC","decimals":8},"value":"100"},
+				{
+					"account_identifier":{"address":"addr2","sub_account":{"address":"sub"}},
+					"currency":{"symbol":"BTC","decimals":8},"value":"5"
+				}
+			]`,
+			expected: []*storage.BootstrapBalance{
+				{
+					Account:  &types.AccountIdentifier{Address: "addr1"},
+					Currency: btc,
+					Value:    "100",
+				},
+				{
+					Account: &types.AccountIdentifier{
+						Address:    "addr2",
+						SubAccount: &types.SubAccountIdentifier{Address: "sub"},
+					},
+					Currency: btc,
+					Value:    "5",
+				},
+			},
+		},
+		"json not array": {
+			fileName: "balances.json",
+			contents: `{"account_identifier":{"address":"addr1"}}`,
+			err:      ErrInvalidBalance,
+		},
+		"json negative balance": {
+			fileName: "balances.json",
+			contents: `[{"account_identifier":{"address":"addr1"},"currency":{"symbol":"BTC","decimals":8},"value":"-1"}]`,
+			err:      ErrInvalidBalance,
+		},
+		"csv": {
+			fileName: "balances.csv",
+			contents: "address,sub_account_address,symbol,decimals,value\naddr1,,BTC,8,100\naddr2,sub,BTC,8,5\n",
+			expected: []*storage.BootstrapBalance{
+				{
+					Account:  &types.AccountIdentifier{Address: "addr1"},
+					Currency: btc,
+					Value:    "100",
+				},
+				{
+					Account: &types.AccountIdentifier{
+						Address:    "addr2",
+						SubAccount: &types.SubAccountIdentifier{Address: "sub"},
+					},
+					Currency: btc,
+					Value:    "5",
+				},
+			},
+		},
+		"csv missing address": {
+			fileName: "balances.csv",
+			contents: "address,sub_account_address,symbol,decimals,value\n,,BTC,8,100\n",
+			err:      ErrInvalidBalance,
+		},
+		"csv invalid value": {
+			fileName: "balances.csv",
+			contents: "address,sub_account_address,symbol,decimals,value\naddr1,,BTC,8,hello\n",
+			err:      ErrInvalidBalance,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			dir, err := utils.CreateTempDir()
+			assert.NoError(t, err)
+			defer utils.RemoveTempDir(dir)
+
+			filePath := path.Join(dir, test.fileName)
+			assert.NoError(t, ioutil.WriteFile(filePath, []byte(test.contents), 0600))
+
+			parsed := []*storage.BootstrapBalance{}
+			err = StreamBalances(filePath, func(balance *storage.BootstrapBalance) error {
+				parsed = append(parsed, balance)
+				return nil
+			})
+			if test.err != nil {
+				assert.True(t, errors.Is(err, test.err))
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, parsed)
+		})
+	}
+}
@@ -0,0 +1,536 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package bulkload loads the initial sync of check:data into a
+// badger database with write batches instead of committing a
+// badger transaction for each block. The transactions committed
+// by the storage.BlockStorage, its workers, and the counters are
+// collapsed in memory (so keys written in every block, like
+// counters and the balances of active accounts, are only written
+// once per flush) and written with a badger.WriteBatch whenever
+// they exceed a flush size.
+//
+// The written keys and values are the same as those written by
+// storage.BadgerStorage, so the database can be opened with
+// storage.NewBadgerStorage once the bulk load is closed.
+package bulkload
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/dgraph-io/badger/v2"
+)
+
+const (
+	// DefaultFlushSize is the default size (in bytes)
+	// of the keys and values written by committed
+	// transactions that are kept in memory before
+	// they are written to badger.
+	DefaultFlushSize = 64 * 1024 * 1024
+
+	// logModulo determines how often we should print
+	// logs while scanning data.
+	logModulo = 5000
+)
+
+var (
+	// ErrInterrupted is returned when the database was
+	// being bulk loaded and was not closed (so it is
+	// missing the writes of some committed transactions).
+	ErrInterrupted = errors.New(
+		"bulk load was interrupted (remove the data directory and bulk load again)",
+	)
+
+	// ErrReadOnlyTransaction is returned when a read-only
+	// transaction is modified.
+	ErrReadOnlyTransaction = errors.New("transaction is read-only")
+
+	// loadingKey is stored while the database is being
+	// bulk loaded. Writes are flushed in many badger
+	// transactions, so a database that was not closed
+	// can contain some of the writes of a flush.
+	loadingKey = []byte("bulkload/loading")
+)
+
+var _ storage.Database = (*Database)(nil)
+
+// Database is a storage.Database that collapses the writes of
+// committed transactions in memory and flushes them to badger
+// with write batches.
+//
+// Like storage.BadgerStorage, only one write transaction can be
+// open at a time. Unlike storage.BadgerStorage, transactions are
+// not isolated from transactions committed after they are opened
+// (each read sees the latest committed value), which does not
+// matter during a sync because blocks are processed serially.
+type Database struct {
+	db        *badger.DB
+	encoder   *storage.Encoder
+	pool      *storage.BufferPool
+	flushSize int
+
+	// writer is held by the open write transaction
+	// (if any) and while writes are flushed.
+	writer sync.Mutex
+
+	// pendingLock guards pending, which holds the
+	// writes of committed transactions that have
+	// not been flushed.
+	pendingLock sync.RWMutex
+	pending     *index
+}
+
+// Open opens the badger database with opts for a bulk load. If
+// compress is true, values are compressed like storage.BadgerStorage
+// does by default. Committed writes are flushed once they exceed
+// flushSize bytes (DefaultFlushSize if flushSize is 0).
+//
+// ErrInterrupted is returned if a previous bulk load of the database
+// was not closed.
+func Open(
+	ctx context.Context,
+	opts badger.Options,
+	compress bool,
+	flushSize int,
+) (*Database, error) {
+	if flushSize <= 0 {
+		flushSize = DefaultFlushSize
+	}
+
+	db, err := badger.Open(opts)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", storage.ErrDatabaseOpenFailed, err)
+	}
+
+	pool := storage.NewBufferPool()
+	encoder, err := storage.NewEncoder(nil, pool, compress)
+	if err != nil {
+		closeBadger(db)
+		return nil, fmt.Errorf("%w: %v", storage.ErrCompressorLoadFailed, err)
+	}
+
+	d := &Database{
+		db:        db,
+		encoder:   encoder,
+		pool:      pool,
+		flushSize: flushSize,
+		pending:   newIndex(),
+	}
+
+	if err := d.markLoading(); err != nil {
+		closeBadger(db)
+		return nil, err
+	}
+
+	return d, nil
+}
+
+// closeBadger closes db after a failure.
+func closeBadger(db *badger.DB) {
+	if err := db.Close(); err != nil {
+		log.Printf("%s: unable to close database\n", err.Error())
+	}
+}
+
+// markLoading stores (and syncs) loadingKey before
+// anything is written. ErrInterrupted is returned if
+// it is already stored.
+func (d *Database) markLoading() error {
+	err := d.db.Update(func(txn *badger.Txn) error {
+		_, err := txn.Get(loadingKey)
+		switch {
+		case err == nil:
+			return ErrInterrupted
+		case !errors.Is(err, badger.ErrKeyNotFound):
+			return fmt.Errorf("%w: unable to get bulk load marker", err)
+		}
+
+		if err := txn.Set(loadingKey, []byte{}); err != nil {
+			return fmt.Errorf("%w: unable to set bulk load marker", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := d.db.Sync(); err != nil {
+		return fmt.Errorf("%w: unable to sync bulk load marker", err)
+	}
+
+	return nil
+}
+
+// Interrupted returns a boolean indicating if database
+// was being bulk loaded and was not closed.
+func Interrupted(ctx context.Context, database storage.Database) (bool, error) {
+	txn := database.NewDatabaseTransaction(ctx, false)
+	defer txn.Discard(ctx)
+
+	exists, _, err := txn.Get(ctx, loadingKey)
+	if err != nil {
+		return false, fmt.Errorf("%w: unable to get bulk load marker", err)
+	}
+
+	return exists, nil
+}
+
+// Close flushes all committed writes and closes the database.
+// If the writes cannot be flushed, the database is closed
+// without removing the marker of the bulk load (so it cannot
+// be opened by check:data).
+func (d *Database) Close(ctx context.Context) error {
+	d.writer.Lock()
+	defer d.writer.Unlock()
+
+	if err := d.flush(); err != nil {
+		closeBadger(d.db)
+		return err
+	}
+
+	err := d.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete(loadingKey)
+	})
+	if err != nil {
+		return fmt.Errorf("%w: unable to delete bulk load marker", err)
+	}
+
+	if err := d.db.Close(); err != nil {
+		return fmt.Errorf("%w: %v", storage.ErrDBCloseFailed, err)
+	}
+
+	return nil
+}
+
+// Encoder returns the encoder of the database.
+func (d *Database) Encoder() *storage.Encoder {
+	return d.encoder
+}
+
+// flush writes all pending writes to badger with a
+// write batch. The caller must hold d.writer.
+func (d *Database) flush() error {
+	if d.pending.first() == nil {
+		return nil
+	}
+
+	// d.pending is only modified while d.writer
+	// is held, so it can be read without a lock.
+	batch := d.db.NewWriteBatch()
+	for n := d.pending.first(); n != nil; n = n.next[0] {
+		var err error
+		if n.deleted {
+			err = batch.Delete(n.key)
+		} else {
+			err = batch.Set(n.key, n.value)
+		}
+
+		if err != nil {
+			batch.Cancel()
+			return fmt.Errorf("%w: unable to write %s", err, string(n.key))
+		}
+	}
+
+	if err := batch.Flush(); err != nil {
+		return fmt.Errorf("%w: unable to flush write batch", err)
+	}
+
+	// Readers that loaded the old index before it
+	// is replaced can still use it (it is no longer
+	// modified and its writes are now in badger).
+	d.pendingLock.Lock()
+	d.pending = newIndex()
+	d.pendingLock.Unlock()
+
+	return nil
+}
+
+// loadPending returns the index of pending writes.
+func (d *Database) loadPending() *index {
+	d.pendingLock.RLock()
+	defer d.pendingLock.RUnlock()
+
+	return d.pending
+}
+
+// NewDatabaseTransaction creates a new transaction. If write
+// is true, it blocks until no other write transaction is open.
+func (d *Database) NewDatabaseTransaction(
+	ctx context.Context,
+	write bool,
+) storage.DatabaseTransaction {
+	if write {
+		d.writer.Lock()
+	}
+
+	return &Transaction{
+		database:  d,
+		write:     write,
+		holdsLock: write,
+		writes:    newIndex(),
+	}
+}
+
+// Transaction is a storage.DatabaseTransaction of
+// a *Database. Writes are kept in memory until
+// the transaction is committed.
+type Transaction struct {
+	database *Database
+	write    bool
+	rwLock   sync.RWMutex
+
+	holdsLock bool
+	writes    *index
+
+	// buffersToReclaim are the values set with
+	// reclaimValue (which are only returned to the
+	// pool once the transaction is done).
+	buffersToReclaim []*bytes.Buffer
+}
+
+// Set changes the value of the key within the transaction.
+func (t *Transaction) Set(
+	ctx context.Context,
+	key []byte,
+	value []byte,
+	reclaimValue bool,
+) error {
+	if !t.write {
+		return ErrReadOnlyTransaction
+	}
+
+	t.rwLock.Lock()
+	defer t.rwLock.Unlock()
+
+	if reclaimValue {
+		t.buffersToReclaim = append(t.buffersToReclaim, bytes.NewBuffer(value))
+	}
+
+	t.writes.put(copyBytes(key), copyBytes(value), false)
+	return nil
+}
+
+// Delete removes the key within the transaction.
+func (t *Transaction) Delete(ctx context.Context, key []byte) error {
+	if !t.write {
+		return ErrReadOnlyTransaction
+	}
+
+	t.rwLock.Lock()
+	defer t.rwLock.Unlock()
+
+	t.writes.put(copyBytes(key), nil, true)
+	return nil
+}
+
+// Get returns the value of the key (written by the
+// transaction, committed, or flushed to badger).
+func (t *Transaction) Get(
+	ctx context.Context,
+	key []byte,
+) (bool, []byte, error) {
+	t.rwLock.RLock()
+	defer t.rwLock.RUnlock()
+
+	if n := t.writes.get(key); n != nil {
+		return !n.deleted, copyBytes(n.value), nil
+	}
+
+	if exists, value, ok := t.database.getPending(key); ok {
+		return exists, value, nil
+	}
+
+	var value []byte
+	err := t.database.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(key)
+		if err != nil {
+			return err
+		}
+
+		value, err = item.ValueCopy(nil)
+		return err
+	})
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return false, nil, nil
+	}
+	if err != nil {
+		return false, nil, err
+	}
+
+	return true, value, nil
+}
+
+// getPending returns the pending value of key (if any).
+func (d *Database) getPending(key []byte) (bool, []byte, bool) {
+	d.pendingLock.RLock()
+	defer d.pendingLock.RUnlock()
+
+	n := d.pending.get(key)
+	if n == nil {
+		return false, nil, false
+	}
+
+	return !n.deleted, copyBytes(n.value), true
+}
+
+// Scan calls worker for each key with prefix (starting at
+// seekStart) like storage.BadgerTransaction.Scan does. The
+// writes of the transaction, pending writes, and badger are
+// merged (in that order of precedence).
+func (t *Transaction) Scan(
+	ctx context.Context,
+	prefix []byte,
+	seekStart []byte,
+	worker func([]byte, []byte) error,
+	logEntries bool,
+	reverse bool, // reverse == true means greatest to least
+) (int, error) {
+	t.rwLock.RLock()
+	defer t.rwLock.RUnlock()
+
+	// The pending writes are loaded before badger is
+	// read so that writes that are flushed in between
+	// are still found in the loaded index.
+	sources := []source{
+		newIndexSource(t.writes, nil, seekStart, reverse),
+		newIndexSource(
+			t.database.loadPending(),
+			t.database.pendingLock.RLocker(),
+			seekStart,
+			reverse,
+		),
+	}
+
+	txn := t.database.db.NewTransaction(false)
+	defer txn.Discard()
+
+	opts := badger.DefaultIteratorOptions
+	opts.Reverse = reverse
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	it.Seek(seekStart)
+	sources = append(sources, &badgerSource{it: it})
+
+	entries := 0
+	for {
+		current := nextSource(sources, reverse)
+		if current == nil {
+			break
+		}
+
+		k := current.key()
+		v, deleted, err := current.value()
+		if err != nil {
+			return -1, fmt.Errorf("%w: unable to get value for key %s", err, string(k))
+		}
+
+		for _, s := range sources {
+			if s.valid() && bytes.Equal(s.key(), k) {
+				s.next()
+			}
+		}
+
+		if deleted {
+			continue
+		}
+
+		if !bytes.HasPrefix(k, prefix) {
+			break
+		}
+
+		if err := worker(k, v); err != nil {
+			return -1, fmt.Errorf("%w: worker failed for key %s", err, string(k))
+		}
+
+		entries++
+		if logEntries && entries%logModulo == 0 {
+			log.Printf("scanned %d entries for %s\n", entries, string(prefix))
+		}
+	}
+
+	return entries, nil
+}
+
+// Commit adds the writes of the transaction to the pending
+// writes and flushes them if they exceed the flush size.
+func (t *Transaction) Commit(ctx context.Context) error {
+	defer t.done()
+
+	// It is possible that we may accidentally call commit
+	// twice. In this case, nothing is written again.
+	if !t.holdsLock {
+		return nil
+	}
+
+	d := t.database
+	d.pendingLock.Lock()
+	for n := t.writes.first(); n != nil; n = n.next[0] {
+		d.pending.put(n.key, n.value, n.deleted)
+	}
+	d.pendingLock.Unlock()
+
+	if d.pending.size < d.flushSize {
+		return nil
+	}
+
+	if err := d.flush(); err != nil {
+		return fmt.Errorf("%w: %v", storage.ErrCommitFailed, err)
+	}
+
+	return nil
+}
+
+// Discard discards the writes of the transaction.
+func (t *Transaction) Discard(ctx context.Context) {
+	t.done()
+}
+
+// done reclaims all buffers of the transaction
+// and releases the writer lock (if held).
+func (t *Transaction) done() {
+	t.rwLock.Lock()
+	defer t.rwLock.Unlock()
+
+	for _, buf := range t.buffersToReclaim {
+		t.database.pool.Put(buf)
+	}
+
+	// Ensure we don't attempt to reclaim twice.
+	t.buffersToReclaim = nil
+	t.writes = newIndex()
+
+	if t.holdsLock {
+		t.holdsLock = false
+		t.database.writer.Unlock()
+	}
+}
+
+// copyBytes returns a copy of b.
+func copyBytes(b []byte) []byte {
+	if b == nil {
+		return nil
+	}
+
+	c := make([]byte, len(b))
+	copy(c, b)
+	return c
+}
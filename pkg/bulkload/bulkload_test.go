@@ -0,0 +1,238 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bulkload
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/coinbase/rosetta-cli/pkg/encryption"
+
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/coinbase/rosetta-sdk-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+var errStop = errors.New("stop")
+
+// scanKeys returns the keys scanned by dbTx.
+func scanKeys(
+	ctx context.Context,
+	t *testing.T,
+	dbTx storage.DatabaseTransaction,
+	prefix string,
+	seekStart string,
+	reverse bool,
+) []string {
+	keys := []string{}
+	_, err := dbTx.Scan(
+		ctx,
+		[]byte(prefix),
+		[]byte(seekStart),
+		func(k []byte, v []byte) error {
+			assert.Equal(t, "value "+string(k), string(v))
+			keys = append(keys, string(k))
+			return nil
+		},
+		false,
+		reverse,
+	)
+	assert.NoError(t, err)
+
+	return keys
+}
+
+// setKeys sets the value of each key to
+// "value <key>" in a committed transaction.
+func setKeys(ctx context.Context, t *testing.T, database storage.Database, keys ...string) {
+	dbTx := database.NewDatabaseTransaction(ctx, true)
+	for _, k := range keys {
+		assert.NoError(t, dbTx.Set(ctx, []byte(k), []byte("value "+k), true))
+	}
+	assert.NoError(t, dbTx.Commit(ctx))
+}
+
+func TestDatabase(t *testing.T) {
+	var tests = map[string]struct {
+		flushSize int
+	}{
+		"flushed on every commit": {
+			flushSize: 1,
+		},
+		"flushed on close": {
+			flushSize: DefaultFlushSize,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			dir, err := utils.CreateTempDir()
+			assert.NoError(t, err)
+			defer utils.RemoveTempDir(dir)
+
+			opts := encryption.BadgerOptions(dir, false, nil)
+			database, err := Open(ctx, opts, true, test.flushSize)
+			assert.NoError(t, err)
+
+			interrupted, err := Interrupted(ctx, database)
+			assert.NoError(t, err)
+			assert.True(t, interrupted)
+
+			// Keys outside the prefix (before and after it)
+			// must not be scanned.
+			setKeys(ctx, t, database, "a/1", "p/1", "p/2", "p/3", "z/1")
+
+			dbTx := database.NewDatabaseTransaction(ctx, true)
+			assert.NoError(t, dbTx.Delete(ctx, []byte("p/2")))
+			assert.NoError(t, dbTx.Set(ctx, []byte("p/4"), []byte("value p/4"), false))
+
+			// Writes of an open transaction are only
+			// seen by the transaction.
+			exists, value, err := dbTx.Get(ctx, []byte("p/4"))
+			assert.NoError(t, err)
+			assert.True(t, exists)
+			assert.Equal(t, []byte("value p/4"), value)
+
+			exists, _, err = dbTx.Get(ctx, []byte("p/2"))
+			assert.NoError(t, err)
+			assert.False(t, exists)
+
+			assert.Equal(t, []string{"p/1", "p/3", "p/4"}, scanKeys(ctx, t, dbTx, "p/", "p/", false))
+			assert.Equal(t, []string{"p/4", "p/3", "p/1"}, scanKeys(ctx, t, dbTx, "p/", "p/~", true))
+			assert.Equal(t, []string{"p/3", "p/1"}, scanKeys(ctx, t, dbTx, "p/", "p/3", true))
+			assert.Equal(t, []string{"p/3", "p/4"}, scanKeys(ctx, t, dbTx, "p/", "p/3", false))
+
+			readTx := database.NewDatabaseTransaction(ctx, false)
+			assert.Equal(t, []string{"p/1", "p/2", "p/3"}, scanKeys(ctx, t, readTx, "p/", "p/", false))
+			assert.True(t, errors.Is(readTx.Set(ctx, []byte("p/5"), []byte{}, false), ErrReadOnlyTransaction))
+			readTx.Discard(ctx)
+
+			// Worker errors stop the scan.
+			calls := 0
+			_, err = dbTx.Scan(
+				ctx,
+				[]byte("p/"),
+				[]byte("p/~"),
+				func(k []byte, v []byte) error {
+					calls++
+					return errStop
+				},
+				false,
+				true,
+			)
+			assert.True(t, errors.Is(err, errStop))
+			assert.Equal(t, 1, calls)
+			assert.NoError(t, dbTx.Commit(ctx))
+
+			// Discarded writes are dropped.
+			dbTx = database.NewDatabaseTransaction(ctx, true)
+			assert.NoError(t, dbTx.Set(ctx, []byte("p/5"), []byte("value p/5"), false))
+			assert.NoError(t, dbTx.Delete(ctx, []byte("p/1")))
+			dbTx.Discard(ctx)
+
+			readTx = database.NewDatabaseTransaction(ctx, false)
+			assert.Equal(t, []string{"p/1", "p/3", "p/4"}, scanKeys(ctx, t, readTx, "p/", "p/", false))
+			readTx.Discard(ctx)
+
+			assert.NoError(t, database.Close(ctx))
+
+			// Once closed, the database can be
+			// opened by storage.BadgerStorage.
+			localStore, err := storage.NewBadgerStorage(
+				ctx,
+				dir,
+				storage.WithCustomSettings(opts),
+			)
+			assert.NoError(t, err)
+			defer localStore.Close(ctx)
+
+			interrupted, err = Interrupted(ctx, localStore)
+			assert.NoError(t, err)
+			assert.False(t, interrupted)
+
+			readTx = localStore.NewDatabaseTransaction(ctx, false)
+			defer readTx.Discard(ctx)
+			assert.Equal(t, []string{"p/1", "p/3", "p/4"}, scanKeys(ctx, t, readTx, "p/", "p/", false))
+			assert.Equal(t, []string{"a/1"}, scanKeys(ctx, t, readTx, "a/", "a/", false))
+		})
+	}
+}
+
+func TestDatabaseEncoding(t *testing.T) {
+	ctx := context.Background()
+	dir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(dir)
+
+	opts := encryption.BadgerOptions(dir, false, nil)
+	database, err := Open(ctx, opts, true, 128)
+	assert.NoError(t, err)
+
+	// Counters are written (with the encoder of the
+	// database) in every transaction.
+	counters := storage.NewCounterStorage(database)
+	for i := 0; i < 100; i++ {
+		_, err := counters.Update(ctx, storage.BlockCounter, big.NewInt(1))
+		assert.NoError(t, err)
+
+		_, err = counters.Update(ctx, fmt.Sprintf("counter %d", i%10), big.NewInt(int64(i)))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, database.Close(ctx))
+
+	localStore, err := storage.NewBadgerStorage(ctx, dir, storage.WithCustomSettings(opts))
+	assert.NoError(t, err)
+	defer localStore.Close(ctx)
+
+	counters = storage.NewCounterStorage(localStore)
+	value, err := counters.Get(ctx, storage.BlockCounter)
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewInt(100), value)
+
+	value, err = counters.Get(ctx, "counter 9")
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewInt(9+19+29+39+49+59+69+79+89+99), value)
+}
+
+func TestInterrupted(t *testing.T) {
+	ctx := context.Background()
+	dir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(dir)
+
+	opts := encryption.BadgerOptions(dir, false, nil)
+	database, err := Open(ctx, opts, true, 1)
+	assert.NoError(t, err)
+	setKeys(ctx, t, database, "p/1")
+
+	// The bulk load is not closed (like
+	// when the process is killed).
+	assert.NoError(t, database.db.Close())
+
+	_, err = Open(ctx, opts, true, 1)
+	assert.True(t, errors.Is(err, ErrInterrupted))
+
+	localStore, err := storage.NewBadgerStorage(ctx, dir, storage.WithCustomSettings(opts))
+	assert.NoError(t, err)
+	defer localStore.Close(ctx)
+
+	interrupted, err := Interrupted(ctx, localStore)
+	assert.NoError(t, err)
+	assert.True(t, interrupted)
+}
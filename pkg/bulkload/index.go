@@ -0,0 +1,163 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bulkload
+
+import (
+	"bytes"
+	"math/rand"
+)
+
+const (
+	// maxLevel is the maximum number of levels
+	// of an index (enough for billions of keys).
+	maxLevel = 24
+)
+
+// node is a key in an index. Deleted keys
+// are kept so that they hide the values
+// stored in badger until they are flushed.
+type node struct {
+	key     []byte
+	value   []byte
+	deleted bool
+
+	next []*node
+}
+
+// index is a skip list of the keys written (but not yet
+// flushed) by committed transactions, in key order. It is
+// not safe for concurrent use.
+type index struct {
+	head  *node
+	level int
+	rand  *rand.Rand
+
+	// size is the number of bytes of
+	// all keys and values in the index.
+	size int
+}
+
+// newIndex returns an empty *index.
+func newIndex() *index {
+	return &index{
+		head:  &node{next: make([]*node, maxLevel)},
+		level: 1,
+		rand:  rand.New(rand.NewSource(1)), // nolint:gosec
+	}
+}
+
+// randomLevel returns the level of a new node.
+func (i *index) randomLevel() int {
+	level := 1
+	for level < maxLevel && i.rand.Intn(4) == 0 {
+		level++
+	}
+
+	return level
+}
+
+// findLess populates prev with the last node at each level
+// with a key less than key and returns the last of them.
+func (i *index) findLess(key []byte, prev []*node) *node {
+	n := i.head
+	for level := i.level - 1; level >= 0; level-- {
+		for n.next[level] != nil && bytes.Compare(n.next[level].key, key) < 0 {
+			n = n.next[level]
+		}
+
+		if prev != nil {
+			prev[level] = n
+		}
+	}
+
+	return n
+}
+
+// put sets the value of key (or marks it deleted).
+// key and value must not be modified afterwards.
+func (i *index) put(key []byte, value []byte, deleted bool) {
+	prev := make([]*node, maxLevel)
+	n := i.findLess(key, prev)
+	if next := n.next[0]; next != nil && bytes.Equal(next.key, key) {
+		i.size += len(value) - len(next.value)
+		next.value = value
+		next.deleted = deleted
+		return
+	}
+
+	level := i.randomLevel()
+	for l := i.level; l < level; l++ {
+		prev[l] = i.head
+	}
+	if level > i.level {
+		i.level = level
+	}
+
+	inserted := &node{
+		key:     key,
+		value:   value,
+		deleted: deleted,
+		next:    make([]*node, level),
+	}
+	for l := 0; l < level; l++ {
+		inserted.next[l] = prev[l].next[l]
+		prev[l].next[l] = inserted
+	}
+
+	i.size += len(key) + len(value)
+}
+
+// get returns the node of key (or nil).
+func (i *index) get(key []byte) *node {
+	n := i.findLess(key, nil).next[0]
+	if n != nil && bytes.Equal(n.key, key) {
+		return n
+	}
+
+	return nil
+}
+
+// seek returns the first node with a key
+// greater than or equal to key (or nil).
+func (i *index) seek(key []byte) *node {
+	return i.findLess(key, nil).next[0]
+}
+
+// seekForPrev returns the last node with a key
+// less than or equal to key (or nil).
+func (i *index) seekForPrev(key []byte) *node {
+	if n := i.get(key); n != nil {
+		return n
+	}
+
+	return i.prev(key)
+}
+
+// prev returns the last node with a key
+// less than key (or nil).
+func (i *index) prev(key []byte) *node {
+	n := i.findLess(key, nil)
+	if n == i.head {
+		return nil
+	}
+
+	return n
+}
+
+// first returns the node with the
+// smallest key (or nil).
+func (i *index) first() *node {
+	return i.head.next[0]
+}
@@ -0,0 +1,168 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bulkload
+
+import (
+	"bytes"
+	"sync"
+
+	"github.com/dgraph-io/badger/v2"
+)
+
+// source is an ordered stream of keys
+// that is merged by Transaction.Scan.
+type source interface {
+	valid() bool
+	key() []byte
+
+	// value returns the value of the current key
+	// and a boolean indicating if it is deleted.
+	value() ([]byte, bool, error)
+	next()
+}
+
+// nextSource returns the source with the smallest current
+// key (largest if reverse). If many sources have the same
+// key, the first of them is returned.
+func nextSource(sources []source, reverse bool) source {
+	var current source
+	for _, s := range sources {
+		if !s.valid() {
+			continue
+		}
+
+		if current == nil {
+			current = s
+			continue
+		}
+
+		cmp := bytes.Compare(s.key(), current.key())
+		if (!reverse && cmp < 0) || (reverse && cmp > 0) {
+			current = s
+		}
+	}
+
+	return current
+}
+
+// indexSource streams the keys of an index. The value of
+// the current key is copied (while holding lock, if not nil)
+// when the source is moved so that the index can be modified
+// concurrently.
+type indexSource struct {
+	index   *index
+	lock    sync.Locker
+	reverse bool
+
+	// node is the current node (nil when
+	// the source is exhausted).
+	node    *node
+	v       []byte
+	deleted bool
+}
+
+// newIndexSource returns an *indexSource positioned at
+// the first key from seekStart (like badger.Iterator.Seek).
+func newIndexSource(
+	index *index,
+	lock sync.Locker,
+	seekStart []byte,
+	reverse bool,
+) *indexSource {
+	s := &indexSource{
+		index:   index,
+		lock:    lock,
+		reverse: reverse,
+	}
+
+	s.move(func() *node {
+		if reverse {
+			return index.seekForPrev(seekStart)
+		}
+
+		return index.seek(seekStart)
+	})
+
+	return s
+}
+
+// move moves the source to the node returned by find.
+func (s *indexSource) move(find func() *node) {
+	if s.lock != nil {
+		s.lock.Lock()
+		defer s.lock.Unlock()
+	}
+
+	s.node = find()
+	if s.node == nil {
+		return
+	}
+
+	s.v = copyBytes(s.node.value)
+	s.deleted = s.node.deleted
+}
+
+func (s *indexSource) valid() bool {
+	return s.node != nil
+}
+
+func (s *indexSource) key() []byte {
+	return s.node.key
+}
+
+func (s *indexSource) value() ([]byte, bool, error) {
+	return s.v, s.deleted, nil
+}
+
+func (s *indexSource) next() {
+	current := s.node
+	s.move(func() *node {
+		if s.reverse {
+			return s.index.prev(current.key)
+		}
+
+		// Keys are never removed from an index, so
+		// the next node is always the next key.
+		return current.next[0]
+	})
+}
+
+// badgerSource streams the keys of a badger.Iterator.
+type badgerSource struct {
+	it *badger.Iterator
+	k  []byte
+}
+
+func (s *badgerSource) valid() bool {
+	return s.it.Valid()
+}
+
+func (s *badgerSource) key() []byte {
+	if s.k == nil {
+		s.k = s.it.Item().KeyCopy(nil)
+	}
+
+	return s.k
+}
+
+func (s *badgerSource) value() ([]byte, bool, error) {
+	v, err := s.it.Item().ValueCopy(nil)
+	return v, false, err
+}
+
+func (s *badgerSource) next() {
+	s.k = nil
+	s.it.Next()
+}
@@ -0,0 +1,704 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chaos
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coinbase/rosetta-cli/configuration"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/coinbase/rosetta-sdk-go/utils"
+	"github.com/olekukonko/tablewriter"
+)
+
+// FaultType is the type of an injected fault.
+type FaultType string
+
+const (
+	// Timeout holds a request and then fails it
+	// without sending it to the node.
+	Timeout FaultType = "timeout"
+
+	// ServerError returns a 502, 503, or 504 response
+	// without sending the request to the node.
+	ServerError FaultType = "server_error"
+
+	// TruncatedResponse truncates the
+	// response of the node.
+	TruncatedResponse FaultType = "truncated_response"
+
+	// TruncatedRequest sends a truncated copy of a request
+	// to the node (which should respond with an error)
+	// before sending the request itself.
+	TruncatedRequest FaultType = "truncated_request"
+
+	// Reorg replaces a block returned by /block with
+	// a fork of it (with a different hash).
+	Reorg FaultType = "reorg"
+
+	// maxUnhandled is the maximum number of unhandled
+	// faults included in a *Report.
+	maxUnhandled = 100
+
+	// blockEndpoint and accountBalanceEndpoint are matched at
+	// the end of request paths (the online_url may include
+	// a path prefix).
+	blockEndpoint          = "/block"
+	accountBalanceEndpoint = "/account/balance"
+
+	// minTruncatedRequestSize is the minimum size of a
+	// request body that can be truncated.
+	minTruncatedRequestSize = 2
+)
+
+var (
+	// FaultTypes are all types of faults
+	// (in the order they are reported).
+	FaultTypes = []FaultType{
+		Timeout,
+		ServerError,
+		TruncatedResponse,
+		TruncatedRequest,
+		Reorg,
+	}
+
+	// ErrInjectedTimeout is returned when
+	// a timeout is injected into a request.
+	ErrInjectedTimeout = errors.New("injected timeout")
+
+	// serverErrorCodes are the status codes
+	// of injected server errors.
+	serverErrorCodes = []int{
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout,
+	}
+)
+
+// Fault is a fault injected into a request.
+type Fault struct {
+	Type     FaultType `json:"type"`
+	Endpoint string    `json:"endpoint"`
+	Time     time.Time `json:"time"`
+	Detail   string    `json:"detail,omitempty"`
+}
+
+// Stats are the number of faults of a type that were
+// injected and how many of them were handled.
+//
+// A timeout, server error, or truncated response is handled
+// once the same request succeeds (i.e. it is retried by the
+// rosetta-cli). A reorg is handled once the rosetta-cli
+// fetches the canonical block at the same index. A truncated
+// request is handled if the node responds with an error.
+type Stats struct {
+	Injected           uint64 `json:"injected"`
+	Handled            uint64 `json:"handled"`
+	Unhandled          uint64 `json:"unhandled"`
+	MeanRecoveryMillis int64  `json:"mean_recovery_ms"`
+	MaxRecoveryMillis  int64  `json:"max_recovery_ms"`
+
+	recoveries    int64
+	recoveryTotal time.Duration
+}
+
+// Report is a summary of all injected faults.
+type Report struct {
+	Faults map[FaultType]*Stats `json:"faults"`
+
+	// Unhandled includes up to 100 faults that were not
+	// handled (or have not been handled yet).
+	Unhandled []*Fault `json:"unhandled,omitempty"`
+}
+
+// Injector injects faults into requests made
+// with the transports it wraps.
+type Injector struct {
+	config *configuration.ChaosConfiguration
+	delay  time.Duration
+
+	random *rand.Rand
+	stats  map[FaultType]*Stats
+
+	// pending are the faults injected into each request
+	// that have not been handled yet.
+	pending map[string][]*Fault
+
+	// forks are the reorgs injected at each index (nil once
+	// handled) and forkHashes are the canonical hashes of forks.
+	forks      map[int64]*Fault
+	forkHashes map[string]string
+
+	unhandled []*Fault
+	mutex     sync.Mutex
+}
+
+// New returns a new *Injector that injects
+// the faults described by config.
+func New(config *configuration.ChaosConfiguration) *Injector {
+	seed := config.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	stats := map[FaultType]*Stats{}
+	for _, faultType := range FaultTypes {
+		stats[faultType] = &Stats{}
+	}
+
+	return &Injector{
+		config:     config,
+		delay:      time.Duration(config.TimeoutDelay) * time.Second,
+		random:     rand.New(rand.NewSource(seed)), // #nosec G404
+		stats:      stats,
+		pending:    map[string][]*Fault{},
+		forks:      map[int64]*Fault{},
+		forkHashes: map[string]string{},
+	}
+}
+
+// Wrap returns an http.RoundTripper that injects
+// faults into requests made with next.
+func (i *Injector) Wrap(next http.RoundTripper) http.RoundTripper {
+	return &transport{injector: i, next: next}
+}
+
+type transport struct {
+	injector *Injector
+	next     http.RoundTripper
+}
+
+// requestKey identifies identical requests (so that
+// retries of a request can be recognized).
+func requestKey(path string, body []byte) string {
+	digest := sha256.Sum256(body)
+	return path + "/" + hex.EncodeToString(digest[:])
+}
+
+// withBody returns a copy of req with body.
+func withBody(req *http.Request, body []byte) *http.Request {
+	clone := req.Clone(req.Context())
+	clone.Body = ioutil.NopCloser(bytes.NewReader(body))
+	clone.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(body)), nil
+	}
+	clone.ContentLength = int64(len(body))
+
+	return clone
+}
+
+// response returns a copy of resp with body.
+func response(resp *http.Response, body []byte) *http.Response {
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.Header.Del("Content-Length")
+
+	return resp
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	path := req.URL.Path
+	key := requestKey(path, body)
+	body, forkHash := t.injector.canonicalRequest(body)
+
+	switch faultType := t.injector.draw(); faultType {
+	case Timeout:
+		t.injector.inject(faultType, path, key, "")
+
+		select {
+		case <-req.Context().Done():
+		case <-time.After(t.injector.delay):
+		}
+
+		return nil, fmt.Errorf("%w: %s", ErrInjectedTimeout, path)
+	case ServerError:
+		code := t.injector.serverErrorCode()
+		t.injector.inject(faultType, path, key, http.StatusText(code))
+
+		return &http.Response{
+			Status:     fmt.Sprintf("%d %s", code, http.StatusText(code)),
+			StatusCode: code,
+			Proto:      req.Proto,
+			ProtoMajor: req.ProtoMajor,
+			ProtoMinor: req.ProtoMinor,
+			Header:     http.Header{"Content-Type": []string{"text/plain"}},
+			Body:       ioutil.NopCloser(strings.NewReader(http.StatusText(code))),
+			Request:    req,
+		}, nil
+	case TruncatedResponse:
+		return t.truncatedResponse(req, body, key)
+	}
+
+	if t.injector.chance(t.injector.config.TruncatedRequestRate) {
+		t.truncatedRequest(req, body)
+	}
+
+	resp, err := t.next.RoundTrip(withBody(req, body))
+	if err != nil || resp.StatusCode != http.StatusOK {
+		return resp, err
+	}
+
+	t.injector.handled(key)
+
+	switch {
+	case strings.HasSuffix(path, blockEndpoint):
+		return t.block(resp, forkHash)
+	case strings.HasSuffix(path, accountBalanceEndpoint) && len(forkHash) > 0:
+		return t.accountBalance(resp, forkHash)
+	default:
+		return resp, nil
+	}
+}
+
+// truncatedResponse sends req to the node and
+// returns its response with a truncated body.
+func (t *transport) truncatedResponse(
+	req *http.Request,
+	body []byte,
+	key string,
+) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(withBody(req, body))
+	if err != nil {
+		return nil, err
+	}
+
+	responseBody, err := ioutil.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	t.injector.inject(
+		TruncatedResponse,
+		req.URL.Path,
+		key,
+		fmt.Sprintf("%d of %d bytes", len(responseBody)/2, len(responseBody)),
+	)
+
+	return response(resp, responseBody[:len(responseBody)/2]), nil
+}
+
+// truncatedRequest sends a truncated copy of req to the node
+// and records if the node responded with an error.
+func (t *transport) truncatedRequest(req *http.Request, body []byte) {
+	if len(body) < minTruncatedRequestSize {
+		return
+	}
+
+	resp, err := t.next.RoundTrip(withBody(req, body[:len(body)/2]))
+	if err != nil {
+		t.injector.probed(req.URL.Path, false, err.Error())
+		return
+	}
+
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	_ = resp.Body.Close()
+
+	t.injector.probed(req.URL.Path, resp.StatusCode != http.StatusOK, resp.Status)
+}
+
+// block replaces the block in resp with a fork of it (if
+// it was requested by the hash of a fork or a reorg is
+// injected).
+func (t *transport) block(resp *http.Response, forkHash string) (*http.Response, error) {
+	body, err := ioutil.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	var blockResponse types.BlockResponse
+	if err := json.Unmarshal(body, &blockResponse); err != nil || blockResponse.Block == nil {
+		return response(resp, body), nil
+	}
+
+	block := blockResponse.Block
+	if len(forkHash) == 0 {
+		forkHash = t.injector.reorg(block)
+	}
+
+	if len(forkHash) == 0 {
+		return response(resp, body), nil
+	}
+
+	block.BlockIdentifier = &types.BlockIdentifier{
+		Index: block.BlockIdentifier.Index,
+		Hash:  forkHash,
+	}
+	forked, err := json.Marshal(blockResponse)
+	if err != nil {
+		return nil, err
+	}
+
+	return response(resp, forked), nil
+}
+
+// accountBalance replaces the block identifier in resp
+// with the fork the balance was requested at.
+func (t *transport) accountBalance(
+	resp *http.Response,
+	forkHash string,
+) (*http.Response, error) {
+	body, err := ioutil.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	var balanceResponse map[string]interface{}
+	if err := json.Unmarshal(body, &balanceResponse); err != nil {
+		return response(resp, body), nil
+	}
+
+	blockIdentifier, ok := balanceResponse["block_identifier"].(map[string]interface{})
+	if !ok {
+		return response(resp, body), nil
+	}
+	blockIdentifier["hash"] = forkHash
+
+	forked, err := json.Marshal(balanceResponse)
+	if err != nil {
+		return nil, err
+	}
+
+	return response(resp, forked), nil
+}
+
+// canonicalRequest replaces the hash of a fork in the
+// block_identifier of body with its canonical hash (the
+// node is not aware of forks). It returns the replaced
+// hash of the fork (if any).
+func (i *Injector) canonicalRequest(body []byte) ([]byte, string) {
+	i.mutex.Lock()
+	empty := len(i.forkHashes) == 0
+	i.mutex.Unlock()
+	if empty {
+		return body, ""
+	}
+
+	var request map[string]interface{}
+	if err := json.Unmarshal(body, &request); err != nil {
+		return body, ""
+	}
+
+	blockIdentifier, ok := request["block_identifier"].(map[string]interface{})
+	if !ok {
+		return body, ""
+	}
+
+	forkHash, ok := blockIdentifier["hash"].(string)
+	if !ok {
+		return body, ""
+	}
+
+	i.mutex.Lock()
+	canonicalHash, ok := i.forkHashes[forkHash]
+	i.mutex.Unlock()
+	if !ok {
+		return body, ""
+	}
+
+	blockIdentifier["hash"] = canonicalHash
+	canonical, err := json.Marshal(request)
+	if err != nil {
+		return body, ""
+	}
+
+	return canonical, forkHash
+}
+
+// chance returns true with probability rate.
+func (i *Injector) chance(rate float64) bool {
+	if rate == 0 {
+		return false
+	}
+
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	return i.random.Float64() < rate
+}
+
+// draw returns the fault to inject into a request
+// (at most one of a timeout, server error, or
+// truncated response) or an empty FaultType.
+func (i *Injector) draw() FaultType {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	r := i.random.Float64()
+	for _, candidate := range []struct {
+		faultType FaultType
+		rate      float64
+	}{
+		{Timeout, i.config.TimeoutRate},
+		{ServerError, i.config.ServerErrorRate},
+		{TruncatedResponse, i.config.TruncatedResponseRate},
+	} {
+		if r < candidate.rate {
+			return candidate.faultType
+		}
+		r -= candidate.rate
+	}
+
+	return ""
+}
+
+func (i *Injector) serverErrorCode() int {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	return serverErrorCodes[i.random.Intn(len(serverErrorCodes))]
+}
+
+// inject records a fault injected into the
+// request identified by key.
+func (i *Injector) inject(faultType FaultType, endpoint string, key string, detail string) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	i.stats[faultType].Injected++
+	i.pending[key] = append(i.pending[key], &Fault{
+		Type:     faultType,
+		Endpoint: endpoint,
+		Time:     time.Now(),
+		Detail:   detail,
+	})
+}
+
+// recovered records that fault was handled.
+func (i *Injector) recovered(fault *Fault) {
+	stats := i.stats[fault.Type]
+	stats.Handled++
+
+	recovery := time.Since(fault.Time)
+	stats.recoveries++
+	stats.recoveryTotal += recovery
+	if millis := recovery.Milliseconds(); millis > stats.MaxRecoveryMillis {
+		stats.MaxRecoveryMillis = millis
+	}
+}
+
+// handled records that all faults injected into the
+// request identified by key were handled.
+func (i *Injector) handled(key string) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	faults, ok := i.pending[key]
+	if !ok {
+		return
+	}
+
+	for _, fault := range faults {
+		i.recovered(fault)
+	}
+	delete(i.pending, key)
+}
+
+// probed records if the node responded to a
+// truncated request with an error.
+func (i *Injector) probed(endpoint string, rejected bool, detail string) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	stats := i.stats[TruncatedRequest]
+	stats.Injected++
+	if rejected {
+		stats.Handled++
+		return
+	}
+
+	stats.Unhandled++
+	if len(i.unhandled) < maxUnhandled {
+		i.unhandled = append(i.unhandled, &Fault{
+			Type:     TruncatedRequest,
+			Endpoint: endpoint,
+			Time:     time.Now(),
+			Detail:   detail,
+		})
+	}
+}
+
+// reorg returns the hash of a fork to replace block with
+// (or an empty string if no reorg should be injected). If
+// block was previously replaced with a fork, the reorg
+// is handled.
+func (i *Injector) reorg(block *types.Block) string {
+	identifier := block.BlockIdentifier
+	if identifier.Index == block.ParentBlockIdentifier.Index {
+		// The genesis block cannot be forked.
+		return ""
+	}
+
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	if fault, ok := i.forks[identifier.Index]; ok {
+		if fault != nil {
+			i.recovered(fault)
+			i.forks[identifier.Index] = nil
+		}
+
+		return ""
+	}
+
+	if i.config.ReorgRate == 0 || i.random.Float64() >= i.config.ReorgRate {
+		return ""
+	}
+
+	digest := sha256.Sum256([]byte("chaos/" + identifier.Hash))
+	forkHash := hex.EncodeToString(digest[:])
+	fault := &Fault{
+		Type:     Reorg,
+		Endpoint: blockEndpoint,
+		Time:     time.Now(),
+		Detail:   fmt.Sprintf("block %d", identifier.Index),
+	}
+
+	i.stats[Reorg].Injected++
+	i.forks[identifier.Index] = fault
+	i.forkHashes[forkHash] = identifier.Hash
+
+	return forkHash
+}
+
+// Report returns a *Report of all faults injected so far.
+// Faults that have not been handled yet are reported
+// as unhandled.
+func (i *Injector) Report() *Report {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	report := &Report{Faults: map[FaultType]*Stats{}}
+	for faultType, stats := range i.stats {
+		copied := *stats
+		if copied.recoveries > 0 {
+			copied.MeanRecoveryMillis = copied.recoveryTotal.Milliseconds() / copied.recoveries
+		}
+		report.Faults[faultType] = &copied
+	}
+	report.Unhandled = append(report.Unhandled, i.unhandled...)
+
+	unhandled := []*Fault{}
+	for _, faults := range i.pending {
+		unhandled = append(unhandled, faults...)
+	}
+	for _, fault := range i.forks {
+		if fault != nil {
+			unhandled = append(unhandled, fault)
+		}
+	}
+
+	sort.Slice(unhandled, func(a, b int) bool {
+		return unhandled[a].Time.Before(unhandled[b].Time)
+	})
+	for _, fault := range unhandled {
+		report.Faults[fault.Type].Unhandled++
+		if len(report.Unhandled) < maxUnhandled {
+			report.Unhandled = append(report.Unhandled, fault)
+		}
+	}
+
+	return report
+}
+
+// Save writes report to filePath.
+func (r *Report) Save(filePath string) error {
+	if err := utils.SerializeAndWrite(filePath, r); err != nil {
+		return fmt.Errorf("%w: unable to save chaos report", err)
+	}
+
+	return nil
+}
+
+// Print prints the stats of each type of
+// fault and all unhandled faults as tables.
+func (r *Report) Print() {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetRowLine(true)
+	table.SetRowSeparator("-")
+	table.SetHeader([]string{
+		"Fault",
+		"Injected",
+		"Handled",
+		"Unhandled",
+		"Mean Recovery",
+		"Max Recovery",
+	})
+	for _, faultType := range FaultTypes {
+		stats := r.Faults[faultType]
+		meanRecovery := "-"
+		maxRecovery := "-"
+		if stats.Handled > 0 && faultType != TruncatedRequest {
+			meanRecovery = fmt.Sprintf("%dms", stats.MeanRecoveryMillis)
+			maxRecovery = fmt.Sprintf("%dms", stats.MaxRecoveryMillis)
+		}
+
+		table.Append([]string{
+			string(faultType),
+			fmt.Sprintf("%d", stats.Injected),
+			fmt.Sprintf("%d", stats.Handled),
+			fmt.Sprintf("%d", stats.Unhandled),
+			meanRecovery,
+			maxRecovery,
+		})
+	}
+	table.Render()
+
+	if len(r.Unhandled) == 0 {
+		return
+	}
+
+	unhandled := tablewriter.NewWriter(os.Stdout)
+	unhandled.SetRowLine(true)
+	unhandled.SetRowSeparator("-")
+	unhandled.SetHeader([]string{"Unhandled Fault", "Endpoint", "Time", "Detail"})
+	for _, fault := range r.Unhandled {
+		unhandled.Append([]string{
+			string(fault.Type),
+			fault.Endpoint,
+			fault.Time.Format(time.RFC3339),
+			fault.Detail,
+		})
+	}
+	unhandled.Render()
+}
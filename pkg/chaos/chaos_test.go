@@ -0,0 +1,254 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chaos
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/coinbase/rosetta-cli/configuration"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// newNode returns a server that responds to /block with a block
+// at the requested index and to /account/balance with the
+// requested block identifier. Requests that are not valid
+// JSON are rejected.
+func newNode(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			BlockIdentifier *types.PartialBlockIdentifier `json:"block_identifier"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		var response interface{}
+		switch r.URL.Path {
+		case "/block":
+			index := *request.BlockIdentifier.Index
+			parent := index - 1
+			if index == 0 {
+				parent = 0
+			}
+
+			response = &types.BlockResponse{
+				Block: &types.Block{
+					BlockIdentifier: &types.BlockIdentifier{
+						Index: index,
+						Hash:  fmt.Sprintf("block %d", index),
+					},
+					ParentBlockIdentifier: &types.BlockIdentifier{
+						Index: parent,
+						Hash:  fmt.Sprintf("block %d", parent),
+					},
+				},
+			}
+		case "/account/balance":
+			response = &types.AccountBalanceResponse{
+				BlockIdentifier: &types.BlockIdentifier{
+					Index: *request.BlockIdentifier.Index,
+					Hash:  *request.BlockIdentifier.Hash,
+				},
+				Balances: []*types.Amount{},
+			}
+		default:
+			response = &types.NetworkStatusResponse{}
+		}
+
+		assert.NoError(t, json.NewEncoder(w).Encode(response))
+	}))
+}
+
+func post(
+	t *testing.T,
+	client *http.Client,
+	url string,
+	request interface{},
+) (*http.Response, []byte, error) {
+	body, err := json.Marshal(request)
+	assert.NoError(t, err)
+
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	responseBody, err := ioutil.ReadAll(resp.Body)
+	assert.NoError(t, err)
+
+	return resp, responseBody, nil
+}
+
+func TestServerError(t *testing.T) {
+	node := newNode(t)
+	defer node.Close()
+
+	config := &configuration.ChaosConfiguration{ServerErrorRate: 1, Seed: 1}
+	injector := New(config)
+	client := &http.Client{Transport: injector.Wrap(http.DefaultTransport)}
+
+	request := &types.NetworkRequest{}
+	resp, _, err := post(t, client, node.URL+"/network/status", request)
+	assert.NoError(t, err)
+	assert.Contains(t, serverErrorCodes, resp.StatusCode)
+
+	report := injector.Report()
+	assert.Equal(t, uint64(1), report.Faults[ServerError].Injected)
+	assert.Equal(t, uint64(1), report.Faults[ServerError].Unhandled)
+	assert.Len(t, report.Unhandled, 1)
+
+	// The fault is handled once the request is retried.
+	config.ServerErrorRate = 0
+	resp, _, err = post(t, client, node.URL+"/network/status", request)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	report = injector.Report()
+	assert.Equal(t, uint64(1), report.Faults[ServerError].Handled)
+	assert.Equal(t, uint64(0), report.Faults[ServerError].Unhandled)
+	assert.Len(t, report.Unhandled, 0)
+}
+
+func TestTimeout(t *testing.T) {
+	node := newNode(t)
+	defer node.Close()
+
+	injector := New(&configuration.ChaosConfiguration{TimeoutRate: 1})
+	injector.delay = time.Millisecond
+	client := &http.Client{Transport: injector.Wrap(http.DefaultTransport)}
+
+	_, _, err := post(t, client, node.URL+"/network/status", &types.NetworkRequest{})
+	assert.True(t, errors.Is(err, ErrInjectedTimeout))
+
+	report := injector.Report()
+	assert.Equal(t, uint64(1), report.Faults[Timeout].Injected)
+	assert.Equal(t, uint64(1), report.Faults[Timeout].Unhandled)
+	assert.Equal(t, Timeout, report.Unhandled[0].Type)
+	assert.Equal(t, "/network/status", report.Unhandled[0].Endpoint)
+}
+
+func TestTruncatedResponse(t *testing.T) {
+	node := newNode(t)
+	defer node.Close()
+
+	injector := New(&configuration.ChaosConfiguration{TruncatedResponseRate: 1})
+	client := &http.Client{Transport: injector.Wrap(http.DefaultTransport)}
+
+	resp, body, err := post(t, client, node.URL+"/network/status", &types.NetworkRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.False(t, json.Valid(body))
+	assert.Equal(t, uint64(1), injector.Report().Faults[TruncatedResponse].Injected)
+}
+
+func TestTruncatedRequest(t *testing.T) {
+	node := newNode(t)
+	defer node.Close()
+
+	injector := New(&configuration.ChaosConfiguration{TruncatedRequestRate: 1})
+	client := &http.Client{Transport: injector.Wrap(http.DefaultTransport)}
+
+	// The truncated request is rejected by the node
+	// and the request itself is still sent.
+	resp, body, err := post(t, client, node.URL+"/network/status", &types.NetworkRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.True(t, json.Valid(body))
+
+	report := injector.Report()
+	assert.Equal(t, uint64(1), report.Faults[TruncatedRequest].Injected)
+	assert.Equal(t, uint64(1), report.Faults[TruncatedRequest].Handled)
+
+	// A node that accepts truncated requests does
+	// not handle the fault.
+	accepting := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("{}"))
+	}))
+	defer accepting.Close()
+
+	_, _, err = post(t, client, accepting.URL+"/network/status", &types.NetworkRequest{})
+	assert.NoError(t, err)
+
+	report = injector.Report()
+	assert.Equal(t, uint64(2), report.Faults[TruncatedRequest].Injected)
+	assert.Equal(t, uint64(1), report.Faults[TruncatedRequest].Unhandled)
+	assert.Equal(t, TruncatedRequest, report.Unhandled[0].Type)
+}
+
+func TestReorg(t *testing.T) {
+	node := newNode(t)
+	defer node.Close()
+
+	injector := New(&configuration.ChaosConfiguration{ReorgRate: 1})
+	client := &http.Client{Transport: injector.Wrap(http.DefaultTransport)}
+
+	fetchBlock := func(index int64) *types.Block {
+		resp, body, err := post(t, client, node.URL+"/block", &types.BlockRequest{
+			BlockIdentifier: &types.PartialBlockIdentifier{Index: &index},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var blockResponse types.BlockResponse
+		assert.NoError(t, json.Unmarshal(body, &blockResponse))
+
+		return blockResponse.Block
+	}
+
+	// The genesis block is never forked.
+	assert.Equal(t, "block 0", fetchBlock(0).BlockIdentifier.Hash)
+
+	forked := fetchBlock(1)
+	assert.NotEqual(t, "block 1", forked.BlockIdentifier.Hash)
+	assert.Equal(t, "block 0", forked.ParentBlockIdentifier.Hash)
+
+	// Balances can be fetched at the fork.
+	resp, body, err := post(t, client, node.URL+"/account/balance", &types.AccountBalanceRequest{
+		AccountIdentifier: &types.AccountIdentifier{Address: "addr"},
+		BlockIdentifier: &types.PartialBlockIdentifier{
+			Index: &forked.BlockIdentifier.Index,
+			Hash:  &forked.BlockIdentifier.Hash,
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var balanceResponse types.AccountBalanceResponse
+	assert.NoError(t, json.Unmarshal(body, &balanceResponse))
+	assert.Equal(t, forked.BlockIdentifier, balanceResponse.BlockIdentifier)
+
+	report := injector.Report()
+	assert.Equal(t, uint64(1), report.Faults[Reorg].Injected)
+	assert.Equal(t, uint64(1), report.Faults[Reorg].Unhandled)
+
+	// The reorg is handled once the canonical block is fetched.
+	assert.Equal(t, "block 1", fetchBlock(1).BlockIdentifier.Hash)
+
+	report = injector.Report()
+	assert.Equal(t, uint64(1), report.Faults[Reorg].Handled)
+	assert.Equal(t, uint64(0), report.Faults[Reorg].Unhandled)
+}
@@ -0,0 +1,294 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package compact shrinks the badger database of a data
+// directory. Badger only reclaims the space of deleted and
+// overwritten values when its LSM tree is compacted and its
+// value log is garbage collected, which does not happen often
+// enough during long check:data runs.
+package compact
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"time"
+	"unsafe"
+
+	"github.com/coinbase/rosetta-cli/pkg/encryption"
+
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/dgraph-io/badger/v2"
+	"github.com/olekukonko/tablewriter"
+)
+
+const (
+	// lsmExtension is the extension of the
+	// files of the LSM tree.
+	lsmExtension = ".sst"
+
+	// valueLogExtension is the extension
+	// of value log files.
+	valueLogExtension = ".vlog"
+
+	// bytesPerMB converts sizes to MB.
+	bytesPerMB = 1024 * 1024
+
+	// DefaultDiscardRatio is the default fraction of
+	// a value log file that must be discarded for it
+	// to be rewritten.
+	DefaultDiscardRatio = 0.5
+)
+
+var (
+	// ErrInvalidDiscardRatio is returned when the discard
+	// ratio is not in (0, 1).
+	ErrInvalidDiscardRatio = errors.New("discard ratio must be in (0, 1)")
+
+	// ErrUnsupportedDatabase is returned by Online when
+	// the database is not a *storage.BadgerStorage.
+	ErrUnsupportedDatabase = errors.New("database is not a badger database")
+)
+
+// Size is the size (in bytes) of the
+// files in a database directory.
+type Size struct {
+	LSM      int64 `json:"lsm"`
+	ValueLog int64 `json:"value_log"`
+
+	// Total includes all other files (like
+	// the manifest).
+	Total int64 `json:"total"`
+}
+
+// DirectorySize returns the *Size of the files in dir.
+func DirectorySize(dir string) (*Size, error) {
+	size := &Size{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			return nil
+		}
+
+		switch filepath.Ext(path) {
+		case lsmExtension:
+			size.LSM += info.Size()
+		case valueLogExtension:
+			size.ValueLog += info.Size()
+		}
+
+		size.Total += info.Size()
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to walk %s", err, dir)
+	}
+
+	return size, nil
+}
+
+// Results summarizes a compaction.
+type Results struct {
+	Before *Size `json:"before"`
+	After  *Size `json:"after"`
+
+	// RewrittenValueLogs is the number of value
+	// log files rewritten by garbage collection.
+	RewrittenValueLogs int64 `json:"rewritten_value_logs"`
+}
+
+// Run flattens the LSM tree of the badger database in dir (which
+// drops deleted and overwritten keys) and then garbage collects
+// value log files until no file has more than discardRatio of its
+// values discarded. If performance is true, the database is opened
+// with storage.PerformanceBadgerOptions (like check:data does when
+// memory_limit_disabled is true). If the database is encrypted,
+// key must be the key it is encrypted with.
+//
+// The database must not be open in any other process.
+func Run(
+	ctx context.Context,
+	dir string,
+	discardRatio float64,
+	performance bool,
+	key []byte,
+) (*Results, error) {
+	if discardRatio <= 0 || discardRatio >= 1 {
+		return nil, fmt.Errorf("%w: %f", ErrInvalidDiscardRatio, discardRatio)
+	}
+
+	before, err := DirectorySize(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	// The options used by storage.NewBadgerStorage
+	// must be used to read the existing tables.
+	db, err := badger.Open(encryption.BadgerOptions(dir, performance, key))
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to open database", err)
+	}
+
+	results := &Results{Before: before}
+	if err := compact(ctx, db, discardRatio, results); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	// Obsolete files are only deleted once
+	// the database is closed.
+	if err := db.Close(); err != nil {
+		return nil, fmt.Errorf("%w: unable to close database", err)
+	}
+
+	after, err := DirectorySize(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	results.After = after
+	return results, nil
+}
+
+// Online compacts the open database (like Run) without
+// closing it, so that check:data can reclaim the space of
+// pruned blocks while it is running. The space of rewritten
+// value log files is returned to the volume as soon as no
+// transaction is reading them. Only RewrittenValueLogs is
+// set in the returned *Results.
+func Online(
+	ctx context.Context,
+	database storage.Database,
+	discardRatio float64,
+) (*Results, error) {
+	if discardRatio <= 0 || discardRatio >= 1 {
+		return nil, fmt.Errorf("%w: %f", ErrInvalidDiscardRatio, discardRatio)
+	}
+
+	db, err := badgerDB(database)
+	if err != nil {
+		return nil, err
+	}
+
+	results := &Results{}
+	if err := compact(ctx, db, discardRatio, results); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// badgerDB returns the *badger.DB wrapped by database.
+// storage.BadgerStorage does not expose it, so it
+// is read from its unexported db field.
+func badgerDB(database storage.Database) (*badger.DB, error) {
+	badgerStorage, ok := database.(*storage.BadgerStorage)
+	if !ok || badgerStorage == nil {
+		return nil, fmt.Errorf("%w: %T", ErrUnsupportedDatabase, database)
+	}
+
+	field := reflect.ValueOf(badgerStorage).Elem().FieldByName("db")
+	if !field.IsValid() || field.Type() != reflect.TypeOf((*badger.DB)(nil)) {
+		return nil, fmt.Errorf("%w: db field not found", ErrUnsupportedDatabase)
+	}
+
+	db := reflect.NewAt(field.Type(), unsafe.Pointer(field.UnsafeAddr())).Elem()
+	return db.Interface().(*badger.DB), nil
+}
+
+// compact flattens the LSM tree of db and
+// garbage collects its value log.
+func compact(
+	ctx context.Context,
+	db *badger.DB,
+	discardRatio float64,
+	results *Results,
+) error {
+	start := time.Now()
+	log.Println("flattening LSM tree")
+	if err := db.Flatten(runtime.NumCPU()); err != nil {
+		return fmt.Errorf("%w: unable to flatten LSM tree", err)
+	}
+	log.Printf("flattened LSM tree in %s\n", time.Since(start).Round(time.Second))
+
+	start = time.Now()
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		// ErrRejected is returned when another garbage
+		// collection is running (like the periodic garbage
+		// collection of an open storage.BadgerStorage).
+		err := db.RunValueLogGC(discardRatio)
+		if errors.Is(err, badger.ErrNoRewrite) || errors.Is(err, badger.ErrRejected) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("%w: unable to garbage collect value log", err)
+		}
+
+		results.RewrittenValueLogs++
+		log.Printf("rewrote %d value log files\n", results.RewrittenValueLogs)
+	}
+
+	log.Printf(
+		"garbage collected value log in %s (%d files rewritten)\n",
+		time.Since(start).Round(time.Second),
+		results.RewrittenValueLogs,
+	)
+
+	return nil
+}
+
+// FormatMB returns size (in bytes) as
+// a human-readable number of MB.
+func FormatMB(size int64) string {
+	return fmt.Sprintf("%.1f MB", float64(size)/bytesPerMB)
+}
+
+// Print prints the size of each type of file before and
+// after compaction (and the space that was reclaimed).
+func (r *Results) Print() {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetRowLine(true)
+	table.SetRowSeparator("-")
+	table.SetHeader([]string{"Files", "Before", "After", "Reclaimed"})
+	for _, row := range []struct {
+		name   string
+		before int64
+		after  int64
+	}{
+		{"LSM Tree", r.Before.LSM, r.After.LSM},
+		{"Value Log", r.Before.ValueLog, r.After.ValueLog},
+		{"Total", r.Before.Total, r.After.Total},
+	} {
+		table.Append([]string{
+			row.name,
+			FormatMB(row.before),
+			FormatMB(row.after),
+			FormatMB(row.before - row.after),
+		})
+	}
+
+	table.Render()
+}
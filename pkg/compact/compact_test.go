@@ -0,0 +1,151 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compact
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/coinbase/rosetta-sdk-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDirectorySize(t *testing.T) {
+	dir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(dir)
+
+	files := map[string]int{
+		"000001.sst":  10,
+		"000002.sst":  20,
+		"000001.vlog": 30,
+		"MANIFEST":    5,
+	}
+	for name, size := range files {
+		contents := []byte(strings.Repeat("a", size))
+		err := ioutil.WriteFile(path.Join(dir, name), contents, os.FileMode(0600))
+		assert.NoError(t, err)
+	}
+
+	size, err := DirectorySize(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, &Size{LSM: 30, ValueLog: 30, Total: 65}, size)
+}
+
+func TestRun(t *testing.T) {
+	ctx := context.Background()
+	dir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(dir)
+
+	_, err = Run(ctx, dir, 1, false, nil)
+	assert.True(t, errors.Is(err, ErrInvalidDiscardRatio))
+
+	// Most keys are deleted, so compaction
+	// can drop their values.
+	localStore, err := storage.NewBadgerStorage(ctx, dir)
+	assert.NoError(t, err)
+	keys := 1000
+	value := []byte(strings.Repeat("v", 1024))
+	for i := 0; i < keys; i++ {
+		dbTx := localStore.NewDatabaseTransaction(ctx, true)
+		assert.NoError(t, dbTx.Set(ctx, []byte(fmt.Sprintf("key/%d", i)), value, true))
+		assert.NoError(t, dbTx.Commit(ctx))
+	}
+
+	for i := 1; i < keys; i++ {
+		dbTx := localStore.NewDatabaseTransaction(ctx, true)
+		assert.NoError(t, dbTx.Delete(ctx, []byte(fmt.Sprintf("key/%d", i))))
+		assert.NoError(t, dbTx.Commit(ctx))
+	}
+	assert.NoError(t, localStore.Close(ctx))
+
+	results, err := Run(ctx, dir, 0.5, false, nil)
+	assert.NoError(t, err)
+	assert.True(t, results.Before.Total > 0)
+	assert.True(t, results.After.Total > 0)
+
+	// Keys that were not deleted can still be read.
+	localStore, err = storage.NewBadgerStorage(ctx, dir)
+	assert.NoError(t, err)
+	defer localStore.Close(ctx)
+
+	dbTx := localStore.NewDatabaseTransaction(ctx, false)
+	defer dbTx.Discard(ctx)
+
+	exists, stored, err := dbTx.Get(ctx, []byte("key/0"))
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, value, stored)
+
+	exists, _, err = dbTx.Get(ctx, []byte("key/1"))
+	assert.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestOnline(t *testing.T) {
+	ctx := context.Background()
+	dir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(dir)
+
+	localStore, err := storage.NewBadgerStorage(ctx, dir)
+	assert.NoError(t, err)
+	defer localStore.Close(ctx)
+
+	_, err = Online(ctx, localStore, 0)
+	assert.True(t, errors.Is(err, ErrInvalidDiscardRatio))
+
+	_, err = Online(ctx, nil, 0.5)
+	assert.True(t, errors.Is(err, ErrUnsupportedDatabase))
+
+	value := []byte(strings.Repeat("v", 1024))
+	for i := 0; i < 100; i++ {
+		dbTx := localStore.NewDatabaseTransaction(ctx, true)
+		assert.NoError(t, dbTx.Set(ctx, []byte(fmt.Sprintf("key/%d", i)), value, true))
+		assert.NoError(t, dbTx.Commit(ctx))
+	}
+
+	for i := 1; i < 100; i++ {
+		dbTx := localStore.NewDatabaseTransaction(ctx, true)
+		assert.NoError(t, dbTx.Delete(ctx, []byte(fmt.Sprintf("key/%d", i))))
+		assert.NoError(t, dbTx.Commit(ctx))
+	}
+
+	results, err := Online(ctx, localStore, 0.5)
+	assert.NoError(t, err)
+	assert.NotNil(t, results)
+
+	// The database is still open and keys that
+	// were not deleted can still be read.
+	dbTx := localStore.NewDatabaseTransaction(ctx, false)
+	defer dbTx.Discard(ctx)
+
+	exists, stored, err := dbTx.Get(ctx, []byte("key/0"))
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, value, stored)
+
+	exists, _, err = dbTx.Get(ctx, []byte("key/1"))
+	assert.NoError(t, err)
+	assert.False(t, exists)
+}
@@ -0,0 +1,454 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compare
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/coinbase/rosetta-sdk-go/fetcher"
+	"github.com/coinbase/rosetta-sdk-go/parser"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/olekukonko/tablewriter"
+	"golang.org/x/sync/errgroup"
+)
+
+var (
+	// ErrInvalidRange is returned when the start index
+	// is after the end index.
+	ErrInvalidRange = errors.New("start index is after end index")
+)
+
+// Fetcher is the subset of *fetcher.Fetcher
+// used to compare implementations.
+type Fetcher interface {
+	NetworkStatusRetry(
+		ctx context.Context,
+		network *types.NetworkIdentifier,
+		metadata map[string]interface{},
+	) (*types.NetworkStatusResponse, *fetcher.Error)
+
+	BlockRetry(
+		ctx context.Context,
+		network *types.NetworkIdentifier,
+		blockIdentifier *types.PartialBlockIdentifier,
+	) (*types.Block, *fetcher.Error)
+
+	AccountBalanceRetry(
+		ctx context.Context,
+		network *types.NetworkIdentifier,
+		account *types.AccountIdentifier,
+		block *types.PartialBlockIdentifier,
+		currencies []*types.Currency,
+	) (*types.BlockIdentifier, []*types.Amount, map[string]interface{}, *fetcher.Error)
+}
+
+// DivergenceType is the part of a block
+// where two implementations diverge.
+type DivergenceType string
+
+const (
+	// BlockDivergence is a difference in the
+	// block identifier or parent block identifier.
+	BlockDivergence DivergenceType = "block"
+
+	// TransactionDivergence is a difference in the number,
+	// order, or identifiers of the transactions in a block.
+	TransactionDivergence DivergenceType = "transaction"
+
+	// OperationDivergence is a difference in the
+	// operations of a transaction.
+	OperationDivergence DivergenceType = "operation"
+
+	// BalanceDivergence is a difference in the balance of
+	// an account (whose balance changes in a block) at
+	// that block.
+	BalanceDivergence DivergenceType = "balance"
+)
+
+// Divergence is the first difference found
+// between two implementations.
+type Divergence struct {
+	Type        DivergenceType `json:"type"`
+	Index       int64          `json:"index"`
+	Description string         `json:"description"`
+	Left        string         `json:"left"`
+	Right       string         `json:"right"`
+}
+
+// Results summarizes a comparison.
+type Results struct {
+	StartIndex int64       `json:"start_index"`
+	EndIndex   int64       `json:"end_index"`
+	Compared   int64       `json:"compared"`
+	Divergence *Divergence `json:"divergence,omitempty"`
+}
+
+// Comparer fetches each block from two implementations
+// in lock-step and finds the first block where they
+// diverge.
+type Comparer struct {
+	network  *types.NetworkIdentifier
+	left     Fetcher
+	right    Fetcher
+	parser   *parser.Parser
+	balances bool
+}
+
+// New returns a new *Comparer. If balances is true,
+// the balances of all accounts whose balances change
+// in each block are also compared (this requires
+// historical balance lookup).
+func New(
+	network *types.NetworkIdentifier,
+	left Fetcher,
+	right Fetcher,
+	parser *parser.Parser,
+	balances bool,
+) *Comparer {
+	return &Comparer{
+		network:  network,
+		left:     left,
+		right:    right,
+		parser:   parser,
+		balances: balances,
+	}
+}
+
+// compareValues returns a *Divergence if left
+// and right are not equal.
+func compareValues(
+	divergenceType DivergenceType,
+	index int64,
+	description string,
+	left interface{},
+	right interface{},
+) *Divergence {
+	if types.Hash(left) == types.Hash(right) {
+		return nil
+	}
+
+	return &Divergence{
+		Type:        divergenceType,
+		Index:       index,
+		Description: description,
+		Left:        types.PrintStruct(left),
+		Right:       types.PrintStruct(right),
+	}
+}
+
+// CompareBlocks returns the first difference between
+// left and right (or nil if they are equal).
+func CompareBlocks(left *types.Block, right *types.Block) *Divergence {
+	index := left.BlockIdentifier.Index
+	if d := compareValues(
+		BlockDivergence,
+		index,
+		"block identifier",
+		left.BlockIdentifier,
+		right.BlockIdentifier,
+	); d != nil {
+		return d
+	}
+
+	if d := compareValues(
+		BlockDivergence,
+		index,
+		"parent block identifier",
+		left.ParentBlockIdentifier,
+		right.ParentBlockIdentifier,
+	); d != nil {
+		return d
+	}
+
+	if len(left.Transactions) != len(right.Transactions) {
+		return &Divergence{
+			Type:        TransactionDivergence,
+			Index:       index,
+			Description: "number of transactions",
+			Left:        fmt.Sprintf("%d", len(left.Transactions)),
+			Right:       fmt.Sprintf("%d", len(right.Transactions)),
+		}
+	}
+
+	for i, leftTx := range left.Transactions {
+		rightTx := right.Transactions[i]
+		if d := compareValues(
+			TransactionDivergence,
+			index,
+			fmt.Sprintf("transaction %d", i),
+			leftTx.TransactionIdentifier,
+			rightTx.TransactionIdentifier,
+		); d != nil {
+			return d
+		}
+
+		if len(leftTx.Operations) != len(rightTx.Operations) {
+			return &Divergence{
+				Type:  OperationDivergence,
+				Index: index,
+				Description: fmt.Sprintf(
+					"number of operations in transaction %s",
+					leftTx.TransactionIdentifier.Hash,
+				),
+				Left:  fmt.Sprintf("%d", len(leftTx.Operations)),
+				Right: fmt.Sprintf("%d", len(rightTx.Operations)),
+			}
+		}
+
+		for j, leftOp := range leftTx.Operations {
+			if d := compareValues(
+				OperationDivergence,
+				index,
+				fmt.Sprintf(
+					"operation %d in transaction %s",
+					j,
+					leftTx.TransactionIdentifier.Hash,
+				),
+				leftOp,
+				rightTx.Operations[j],
+			); d != nil {
+				return d
+			}
+		}
+	}
+
+	return nil
+}
+
+// balance returns the balance of account in currency at
+// block fetched with f.
+func (c *Comparer) balance(
+	ctx context.Context,
+	f Fetcher,
+	account *types.AccountIdentifier,
+	currency *types.Currency,
+	block *types.BlockIdentifier,
+) (string, error) {
+	_, amounts, _, fetchErr := f.AccountBalanceRetry(
+		ctx,
+		c.network,
+		account,
+		&types.PartialBlockIdentifier{Index: &block.Index, Hash: &block.Hash},
+		nil,
+	)
+	if fetchErr != nil {
+		return "", fmt.Errorf(
+			"%w: unable to fetch balance of %s at %d",
+			fetchErr.Err,
+			types.AccountString(account),
+			block.Index,
+		)
+	}
+
+	for _, amount := range amounts {
+		if types.Hash(amount.Currency) == types.Hash(currency) {
+			return amount.Value, nil
+		}
+	}
+
+	// Currencies with no balance are
+	// often omitted from responses.
+	return "0", nil
+}
+
+// compareBalances compares the balances of all accounts
+// whose balances change in block.
+func (c *Comparer) compareBalances(
+	ctx context.Context,
+	block *types.Block,
+) (*Divergence, error) {
+	changes, err := c.parser.BalanceChanges(ctx, block, false)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"%w: unable to calculate balance changes in block %d",
+			err,
+			block.BlockIdentifier.Index,
+		)
+	}
+
+	for _, change := range changes {
+		change := change
+		var leftBalance, rightBalance string
+		g, gctx := errgroup.WithContext(ctx)
+		g.Go(func() error {
+			var err error
+			leftBalance, err = c.balance(gctx, c.left, change.Account, change.Currency, change.Block)
+			return err
+		})
+		g.Go(func() error {
+			var err error
+			rightBalance, err = c.balance(gctx, c.right, change.Account, change.Currency, change.Block)
+			return err
+		})
+		if err := g.Wait(); err != nil {
+			return nil, err
+		}
+
+		if leftBalance == rightBalance {
+			continue
+		}
+
+		return &Divergence{
+			Type:  BalanceDivergence,
+			Index: block.BlockIdentifier.Index,
+			Description: fmt.Sprintf(
+				"balance of %s in %s",
+				types.AccountString(change.Account),
+				change.Currency.Symbol,
+			),
+			Left:  leftBalance,
+			Right: rightBalance,
+		}, nil
+	}
+
+	return nil, nil
+}
+
+// fetchBlocks fetches the block at index from
+// both implementations concurrently.
+func (c *Comparer) fetchBlocks(
+	ctx context.Context,
+	index int64,
+) (*types.Block, *types.Block, error) {
+	var left, right *types.Block
+	g, gctx := errgroup.WithContext(ctx)
+	g.Go(func() error {
+		var fetchErr *fetcher.Error
+		left, fetchErr = c.left.BlockRetry(gctx, c.network, &types.PartialBlockIdentifier{Index: &index})
+		if fetchErr != nil {
+			return fmt.Errorf("%w: unable to fetch block %d from left", fetchErr.Err, index)
+		}
+
+		return nil
+	})
+	g.Go(func() error {
+		var fetchErr *fetcher.Error
+		right, fetchErr = c.right.BlockRetry(gctx, c.network, &types.PartialBlockIdentifier{Index: &index})
+		if fetchErr != nil {
+			return fmt.Errorf("%w: unable to fetch block %d from right", fetchErr.Err, index)
+		}
+
+		return nil
+	})
+
+	if err := g.Wait(); err != nil {
+		return nil, nil, err
+	}
+
+	return left, right, nil
+}
+
+// bounds returns the range of blocks to compare. If startIndex
+// or endIndex is negative, the genesis block or the lowest current
+// block of both implementations is used.
+func (c *Comparer) bounds(
+	ctx context.Context,
+	startIndex int64,
+	endIndex int64,
+) (int64, int64, error) {
+	if startIndex >= 0 && endIndex >= 0 {
+		return startIndex, endIndex, nil
+	}
+
+	leftStatus, fetchErr := c.left.NetworkStatusRetry(ctx, c.network, nil)
+	if fetchErr != nil {
+		return -1, -1, fmt.Errorf("%w: unable to fetch network status from left", fetchErr.Err)
+	}
+
+	rightStatus, fetchErr := c.right.NetworkStatusRetry(ctx, c.network, nil)
+	if fetchErr != nil {
+		return -1, -1, fmt.Errorf("%w: unable to fetch network status from right", fetchErr.Err)
+	}
+
+	if startIndex < 0 {
+		startIndex = leftStatus.GenesisBlockIdentifier.Index
+	}
+
+	if endIndex < 0 {
+		endIndex = leftStatus.CurrentBlockIdentifier.Index
+		if rightStatus.CurrentBlockIdentifier.Index < endIndex {
+			endIndex = rightStatus.CurrentBlockIdentifier.Index
+		}
+	}
+
+	return startIndex, endIndex, nil
+}
+
+// Run compares each block in [startIndex, endIndex] until
+// the first divergence is found.
+func (c *Comparer) Run(
+	ctx context.Context,
+	startIndex int64,
+	endIndex int64,
+) (*Results, error) {
+	startIndex, endIndex, err := c.bounds(ctx, startIndex, endIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	if startIndex > endIndex {
+		return nil, fmt.Errorf("%w: %d > %d", ErrInvalidRange, startIndex, endIndex)
+	}
+
+	results := &Results{StartIndex: startIndex, EndIndex: endIndex}
+	for index := startIndex; index <= endIndex; index++ {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		left, right, err := c.fetchBlocks(ctx, index)
+		if err != nil {
+			return nil, err
+		}
+
+		results.Divergence = CompareBlocks(left, right)
+		if results.Divergence == nil && c.balances {
+			results.Divergence, err = c.compareBalances(ctx, left)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if results.Divergence != nil {
+			return results, nil
+		}
+
+		results.Compared++
+		log.Printf("blocks %d match\n", index)
+	}
+
+	return results, nil
+}
+
+// Print logs the divergence to the console.
+func (d *Divergence) Print() {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetRowLine(true)
+	table.SetRowSeparator("-")
+	table.SetHeader([]string{"Block", "Type", "Description", "Left", "Right"})
+	table.Append([]string{
+		fmt.Sprintf("%d", d.Index),
+		string(d.Type),
+		d.Description,
+		d.Left,
+		d.Right,
+	})
+
+	table.Render()
+}
@@ -0,0 +1,249 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compare
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/asserter"
+	"github.com/coinbase/rosetta-sdk-go/fetcher"
+	"github.com/coinbase/rosetta-sdk-go/parser"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	network  = &types.NetworkIdentifier{Blockchain: "Bitcoin", Network: "Testnet3"}
+	currency = &types.Currency{Symbol: "BTC", Decimals: 8}
+)
+
+func blockIdentifier(index int64) *types.BlockIdentifier {
+	return &types.BlockIdentifier{Hash: fmt.Sprintf("block %d", index), Index: index}
+}
+
+// mockFetcher serves blocks where addr receives 10 in
+// each block. Blocks can be modified with modify and
+// balances are served from a map keyed by block index.
+type mockFetcher struct {
+	tip      int64
+	modify   func(*types.Block)
+	balances map[int64]string
+}
+
+func (f *mockFetcher) NetworkStatusRetry(
+	context.Context,
+	*types.NetworkIdentifier,
+	map[string]interface{},
+) (*types.NetworkStatusResponse, *fetcher.Error) {
+	return &types.NetworkStatusResponse{
+		GenesisBlockIdentifier: blockIdentifier(0),
+		CurrentBlockIdentifier: blockIdentifier(f.tip),
+	}, nil
+}
+
+func (f *mockFetcher) BlockRetry(
+	ctx context.Context,
+	network *types.NetworkIdentifier,
+	identifier *types.PartialBlockIdentifier,
+) (*types.Block, *fetcher.Error) {
+	index := *identifier.Index
+	block := &types.Block{
+		BlockIdentifier:       blockIdentifier(index),
+		ParentBlockIdentifier: blockIdentifier(index - 1),
+		Transactions: []*types.Transaction{
+			{
+				TransactionIdentifier: &types.TransactionIdentifier{
+					Hash: fmt.Sprintf("tx %d", index),
+				},
+				Operations: []*types.Operation{
+					{
+						OperationIdentifier: &types.OperationIdentifier{Index: 0},
+						Type:                "TRANSFER",
+						Status:              types.String("SUCCESS"),
+						Account:             &types.AccountIdentifier{Address: "addr"},
+						Amount:              &types.Amount{Value: "10", Currency: currency},
+					},
+				},
+			},
+		},
+	}
+
+	if f.modify != nil {
+		f.modify(block)
+	}
+
+	return block, nil
+}
+
+func (f *mockFetcher) AccountBalanceRetry(
+	ctx context.Context,
+	network *types.NetworkIdentifier,
+	account *types.AccountIdentifier,
+	block *types.PartialBlockIdentifier,
+	currencies []*types.Currency,
+) (*types.BlockIdentifier, []*types.Amount, map[string]interface{}, *fetcher.Error) {
+	balance, ok := f.balances[*block.Index]
+	if !ok {
+		return nil, nil, nil, &fetcher.Error{Err: errors.New("balance not found")}
+	}
+
+	return blockIdentifier(*block.Index), []*types.Amount{
+		{Value: balance, Currency: currency},
+	}, nil, nil
+}
+
+func TestComparer(t *testing.T) {
+	a, err := asserter.NewClientWithOptions(
+		network,
+		blockIdentifier(0),
+		[]string{"TRANSFER"},
+		[]*types.OperationStatus{{Status: "SUCCESS", Successful: true}},
+		[]*types.Error{},
+		nil,
+	)
+	assert.NoError(t, err)
+	p := parser.New(a, nil, nil)
+
+	balances := map[int64]string{0: "0", 1: "10", 2: "20", 3: "30"}
+	var tests = map[string]struct {
+		right    *mockFetcher
+		balances bool
+
+		results *Results
+	}{
+		"equal": {
+			right:    &mockFetcher{tip: 3, balances: balances},
+			balances: true,
+			results:  &Results{StartIndex: 0, EndIndex: 3, Compared: 4},
+		},
+		"lower tip": {
+			right:   &mockFetcher{tip: 2},
+			results: &Results{StartIndex: 0, EndIndex: 2, Compared: 3},
+		},
+		"different block hash": {
+			right: &mockFetcher{
+				tip: 3,
+				modify: func(block *types.Block) {
+					if block.BlockIdentifier.Index == 2 {
+						block.BlockIdentifier.Hash = "other block 2"
+					}
+				},
+			},
+			results: &Results{
+				StartIndex: 0,
+				EndIndex:   3,
+				Compared:   2,
+				Divergence: &Divergence{
+					Type:        BlockDivergence,
+					Index:       2,
+					Description: "block identifier",
+					Left:        types.PrintStruct(blockIdentifier(2)),
+					Right: types.PrintStruct(
+						&types.BlockIdentifier{Hash: "other block 2", Index: 2},
+					),
+				},
+			},
+		},
+		"missing transaction": {
+			right: &mockFetcher{
+				tip: 3,
+				modify: func(block *types.Block) {
+					if block.BlockIdentifier.Index == 1 {
+						block.Transactions = []*types.Transaction{}
+					}
+				},
+			},
+			results: &Results{
+				StartIndex: 0,
+				EndIndex:   3,
+				Compared:   1,
+				Divergence: &Divergence{
+					Type:        TransactionDivergence,
+					Index:       1,
+					Description: "number of transactions",
+					Left:        "1",
+					Right:       "0",
+				},
+			},
+		},
+		"different operation": {
+			right: &mockFetcher{
+				tip: 3,
+				modify: func(block *types.Block) {
+					block.Transactions[0].Operations[0].Amount.Value = "11"
+				},
+			},
+			results: &Results{
+				StartIndex: 0,
+				EndIndex:   3,
+				Compared:   0,
+				Divergence: &Divergence{
+					Type:        OperationDivergence,
+					Index:       0,
+					Description: "operation 0 in transaction tx 0",
+					Left: types.PrintStruct(&types.Operation{
+						OperationIdentifier: &types.OperationIdentifier{Index: 0},
+						Type:                "TRANSFER",
+						Status:              types.String("SUCCESS"),
+						Account:             &types.AccountIdentifier{Address: "addr"},
+						Amount:              &types.Amount{Value: "10", Currency: currency},
+					}),
+					Right: types.PrintStruct(&types.Operation{
+						OperationIdentifier: &types.OperationIdentifier{Index: 0},
+						Type:                "TRANSFER",
+						Status:              types.String("SUCCESS"),
+						Account:             &types.AccountIdentifier{Address: "addr"},
+						Amount:              &types.Amount{Value: "11", Currency: currency},
+					}),
+				},
+			},
+		},
+		"different balance": {
+			right:    &mockFetcher{tip: 3, balances: map[int64]string{0: "0", 1: "10", 2: "25", 3: "30"}},
+			balances: true,
+			results: &Results{
+				StartIndex: 0,
+				EndIndex:   3,
+				Compared:   2,
+				Divergence: &Divergence{
+					Type:        BalanceDivergence,
+					Index:       2,
+					Description: "balance of addr in BTC",
+					Left:        "20",
+					Right:       "25",
+				},
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			left := &mockFetcher{tip: 3, balances: balances}
+			c := New(network, left, test.right, p, test.balances)
+			results, err := c.Run(context.Background(), -1, -1)
+			assert.NoError(t, err)
+			assert.Equal(t, test.results, results)
+		})
+	}
+
+	t.Run("invalid range", func(t *testing.T) {
+		c := New(network, &mockFetcher{}, &mockFetcher{}, p, false)
+		_, err := c.Run(context.Background(), 2, 1)
+		assert.True(t, errors.Is(err, ErrInvalidRange))
+	})
+}
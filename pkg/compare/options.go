@@ -0,0 +1,421 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compare
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/coinbase/rosetta-sdk-go/utils"
+	"github.com/olekukonko/tablewriter"
+)
+
+// DriftType is the part of /network/options
+// that changed between two captures.
+type DriftType string
+
+const (
+	// VersionDrift is a change in the Rosetta,
+	// node, or middleware version.
+	VersionDrift DriftType = "version"
+
+	// OperationTypeDrift is an operation type
+	// that was added or removed.
+	OperationTypeDrift DriftType = "operation_type"
+
+	// OperationStatusDrift is an operation status that was
+	// added, removed, or changed whether it is successful.
+	OperationStatusDrift DriftType = "operation_status"
+
+	// ErrorDrift is an error that was added, removed,
+	// or changed its message or retriability.
+	ErrorDrift DriftType = "error"
+
+	// HistoricalBalanceLookupDrift is a change in
+	// support for historical balance lookup.
+	HistoricalBalanceLookupDrift DriftType = "historical_balance_lookup"
+
+	// BalanceExemptionDrift is a balance exemption
+	// that was added or removed.
+	BalanceExemptionDrift DriftType = "balance_exemption"
+
+	// NetworkDrift is a network that was removed.
+	NetworkDrift DriftType = "network"
+)
+
+const (
+	// absent is printed for a value
+	// missing from a capture.
+	absent = "-"
+)
+
+// Drift is a difference between the /network/options
+// of a network in two captures. Breaking drifts are
+// changes that can break existing integrations (like
+// a removed operation type or a changed status).
+type Drift struct {
+	Network     *types.NetworkIdentifier `json:"network_identifier"`
+	Type        DriftType                `json:"type"`
+	Description string                   `json:"description"`
+	Previous    string                   `json:"previous"`
+	Current     string                   `json:"current"`
+	Breaking    bool                     `json:"breaking"`
+}
+
+// NetworkOptions is the /network/options
+// response of a network.
+type NetworkOptions struct {
+	Network *types.NetworkIdentifier      `json:"network_identifier"`
+	Options *types.NetworkOptionsResponse `json:"options"`
+}
+
+// OptionsCapture is the /network/options response
+// of every network of an implementation.
+type OptionsCapture struct {
+	Networks []*NetworkOptions `json:"networks"`
+}
+
+// LoadOptionsCapture reads an *OptionsCapture from filePath.
+func LoadOptionsCapture(filePath string) (*OptionsCapture, error) {
+	var capture OptionsCapture
+	if err := utils.LoadAndParse(filePath, &capture); err != nil {
+		return nil, fmt.Errorf("%w: unable to load network options capture", err)
+	}
+
+	return &capture, nil
+}
+
+// Save writes the capture to filePath.
+func (c *OptionsCapture) Save(filePath string) error {
+	if err := utils.SerializeAndWrite(filePath, c); err != nil {
+		return fmt.Errorf("%w: unable to save network options capture", err)
+	}
+
+	return nil
+}
+
+// printOptional returns the value of
+// s (or absent if s is nil).
+func printOptional(s *string) string {
+	if s == nil {
+		return absent
+	}
+
+	return *s
+}
+
+// diffSets returns drifts for all keys added to or
+// removed from previous (removals are breaking).
+func diffSets(
+	network *types.NetworkIdentifier,
+	driftType DriftType,
+	previous map[string]string,
+	current map[string]string,
+) []*Drift {
+	drifts := []*Drift{}
+	for key, value := range previous {
+		if _, ok := current[key]; ok {
+			continue
+		}
+
+		drifts = append(drifts, &Drift{
+			Network:     network,
+			Type:        driftType,
+			Description: fmt.Sprintf("%s removed", value),
+			Previous:    value,
+			Current:     absent,
+			Breaking:    true,
+		})
+	}
+
+	for key, value := range current {
+		if _, ok := previous[key]; ok {
+			continue
+		}
+
+		drifts = append(drifts, &Drift{
+			Network:     network,
+			Type:        driftType,
+			Description: fmt.Sprintf("%s added", value),
+			Previous:    absent,
+			Current:     value,
+		})
+	}
+
+	return drifts
+}
+
+// versionDrifts returns a drift for each changed version.
+func versionDrifts(
+	network *types.NetworkIdentifier,
+	previous *types.Version,
+	current *types.Version,
+) []*Drift {
+	if previous == nil {
+		previous = &types.Version{}
+	}
+
+	if current == nil {
+		current = &types.Version{}
+	}
+
+	versions := []struct {
+		name     string
+		previous string
+		current  string
+	}{
+		{"rosetta version", previous.RosettaVersion, current.RosettaVersion},
+		{"node version", previous.NodeVersion, current.NodeVersion},
+		{
+			"middleware version",
+			printOptional(previous.MiddlewareVersion),
+			printOptional(current.MiddlewareVersion),
+		},
+	}
+
+	drifts := []*Drift{}
+	for _, version := range versions {
+		if version.previous == version.current {
+			continue
+		}
+
+		drifts = append(drifts, &Drift{
+			Network:     network,
+			Type:        VersionDrift,
+			Description: fmt.Sprintf("%s changed", version.name),
+			Previous:    version.previous,
+			Current:     version.current,
+		})
+	}
+
+	return drifts
+}
+
+// statusDrifts returns a drift for each operation status
+// that was added, removed, or changed whether it is
+// successful.
+func statusDrifts(
+	network *types.NetworkIdentifier,
+	previous []*types.OperationStatus,
+	current []*types.OperationStatus,
+) []*Drift {
+	previousStatuses := map[string]string{}
+	successful := map[string]bool{}
+	for _, status := range previous {
+		previousStatuses[status.Status] = status.Status
+		successful[status.Status] = status.Successful
+	}
+
+	currentStatuses := map[string]string{}
+	drifts := []*Drift{}
+	for _, status := range current {
+		currentStatuses[status.Status] = status.Status
+		if wasSuccessful, ok := successful[status.Status]; ok &&
+			wasSuccessful != status.Successful {
+			drifts = append(drifts, &Drift{
+				Network:     network,
+				Type:        OperationStatusDrift,
+				Description: fmt.Sprintf("%s changed successful", status.Status),
+				Previous:    fmt.Sprintf("%t", wasSuccessful),
+				Current:     fmt.Sprintf("%t", status.Successful),
+				Breaking:    true,
+			})
+		}
+	}
+
+	return append(
+		drifts,
+		diffSets(network, OperationStatusDrift, previousStatuses, currentStatuses)...,
+	)
+}
+
+// errorDrifts returns a drift for each error (by code) that
+// was added, removed, or changed its message or retriability.
+func errorDrifts(
+	network *types.NetworkIdentifier,
+	previous []*types.Error,
+	current []*types.Error,
+) []*Drift {
+	describe := func(err *types.Error) string {
+		return fmt.Sprintf("%d (%s, retriable: %t)", err.Code, err.Message, err.Retriable)
+	}
+
+	previousErrors := map[string]string{}
+	previousByCode := map[string]*types.Error{}
+	for _, err := range previous {
+		code := fmt.Sprintf("%d", err.Code)
+		previousErrors[code] = describe(err)
+		previousByCode[code] = err
+	}
+
+	currentErrors := map[string]string{}
+	drifts := []*Drift{}
+	for _, err := range current {
+		code := fmt.Sprintf("%d", err.Code)
+		currentErrors[code] = describe(err)
+
+		previousErr, ok := previousByCode[code]
+		if !ok || (previousErr.Message == err.Message && previousErr.Retriable == err.Retriable) {
+			continue
+		}
+
+		drifts = append(drifts, &Drift{
+			Network:     network,
+			Type:        ErrorDrift,
+			Description: fmt.Sprintf("error %s changed", code),
+			Previous:    describe(previousErr),
+			Current:     describe(err),
+			Breaking:    true,
+		})
+	}
+
+	return append(drifts, diffSets(network, ErrorDrift, previousErrors, currentErrors)...)
+}
+
+// DiffOptions returns all drifts between the previous and
+// current /network/options of network (sorted by type and
+// description).
+func DiffOptions(
+	network *types.NetworkIdentifier,
+	previous *types.NetworkOptionsResponse,
+	current *types.NetworkOptionsResponse,
+) []*Drift {
+	previousAllow := previous.Allow
+	if previousAllow == nil {
+		previousAllow = &types.Allow{}
+	}
+
+	currentAllow := current.Allow
+	if currentAllow == nil {
+		currentAllow = &types.Allow{}
+	}
+
+	drifts := versionDrifts(network, previous.Version, current.Version)
+
+	previousTypes := map[string]string{}
+	for _, opType := range previousAllow.OperationTypes {
+		previousTypes[opType] = opType
+	}
+
+	currentTypes := map[string]string{}
+	for _, opType := range currentAllow.OperationTypes {
+		currentTypes[opType] = opType
+	}
+
+	drifts = append(drifts, diffSets(network, OperationTypeDrift, previousTypes, currentTypes)...)
+	drifts = append(drifts, statusDrifts(
+		network,
+		previousAllow.OperationStatuses,
+		currentAllow.OperationStatuses,
+	)...)
+	drifts = append(drifts, errorDrifts(network, previousAllow.Errors, currentAllow.Errors)...)
+
+	if previousAllow.HistoricalBalanceLookup != currentAllow.HistoricalBalanceLookup {
+		drifts = append(drifts, &Drift{
+			Network:     network,
+			Type:        HistoricalBalanceLookupDrift,
+			Description: "historical balance lookup changed",
+			Previous:    fmt.Sprintf("%t", previousAllow.HistoricalBalanceLookup),
+			Current:     fmt.Sprintf("%t", currentAllow.HistoricalBalanceLookup),
+			Breaking:    previousAllow.HistoricalBalanceLookup,
+		})
+	}
+
+	previousExemptions := map[string]string{}
+	for _, exemption := range previousAllow.BalanceExemptions {
+		previousExemptions[types.Hash(exemption)] = types.PrintStruct(exemption)
+	}
+
+	currentExemptions := map[string]string{}
+	for _, exemption := range currentAllow.BalanceExemptions {
+		currentExemptions[types.Hash(exemption)] = types.PrintStruct(exemption)
+	}
+
+	// Added exemptions change which balances are
+	// reconciled, so both additions and removals
+	// are breaking.
+	exemptionDrifts := diffSets(
+		network,
+		BalanceExemptionDrift,
+		previousExemptions,
+		currentExemptions,
+	)
+	for _, drift := range exemptionDrifts {
+		drift.Breaking = true
+	}
+	drifts = append(drifts, exemptionDrifts...)
+
+	sort.SliceStable(drifts, func(i, j int) bool {
+		if drifts[i].Type != drifts[j].Type {
+			return drifts[i].Type < drifts[j].Type
+		}
+
+		return drifts[i].Description < drifts[j].Description
+	})
+
+	return drifts
+}
+
+// DiffCaptures returns all drifts between the networks in
+// previous and current. Networks that are missing from
+// current are breaking drifts.
+func DiffCaptures(previous *OptionsCapture, current *OptionsCapture) []*Drift {
+	currentOptions := map[string]*NetworkOptions{}
+	for _, network := range current.Networks {
+		currentOptions[types.Hash(network.Network)] = network
+	}
+
+	drifts := []*Drift{}
+	for _, network := range previous.Networks {
+		options, ok := currentOptions[types.Hash(network.Network)]
+		if !ok {
+			drifts = append(drifts, &Drift{
+				Network:     network.Network,
+				Type:        NetworkDrift,
+				Description: "network removed",
+				Previous:    types.PrintStruct(network.Network),
+				Current:     absent,
+				Breaking:    true,
+			})
+			continue
+		}
+
+		drifts = append(drifts, DiffOptions(network.Network, network.Options, options.Options)...)
+	}
+
+	return drifts
+}
+
+// PrintDrifts prints drifts as a table.
+func PrintDrifts(drifts []*Drift) {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetRowLine(true)
+	table.SetRowSeparator("-")
+	table.SetHeader([]string{"Network", "Type", "Description", "Previous", "Current", "Breaking"})
+	for _, drift := range drifts {
+		table.Append([]string{
+			drift.Network.Network,
+			string(drift.Type),
+			drift.Description,
+			drift.Previous,
+			drift.Current,
+			fmt.Sprintf("%t", drift.Breaking),
+		})
+	}
+
+	table.Render()
+}
@@ -0,0 +1,174 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compare
+
+import (
+	"path"
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/coinbase/rosetta-sdk-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func networkOptions() *types.NetworkOptionsResponse {
+	return &types.NetworkOptionsResponse{
+		Version: &types.Version{RosettaVersion: "1.4.4", NodeVersion: "0.20.1"},
+		Allow: &types.Allow{
+			OperationStatuses: []*types.OperationStatus{
+				{Status: "SUCCESS", Successful: true},
+				{Status: "FAILURE", Successful: false},
+			},
+			OperationTypes: []string{"INPUT", "OUTPUT", "FEE"},
+			Errors: []*types.Error{
+				{Code: 1, Message: "not found", Retriable: false},
+			},
+			HistoricalBalanceLookup: true,
+		},
+	}
+}
+
+func TestDiffOptions(t *testing.T) {
+	var tests = map[string]struct {
+		modify func(*types.NetworkOptionsResponse)
+
+		expected []*Drift
+	}{
+		"no drift": {
+			modify:   func(*types.NetworkOptionsResponse) {},
+			expected: []*Drift{},
+		},
+		"version bump": {
+			modify: func(options *types.NetworkOptionsResponse) {
+				options.Version.NodeVersion = "0.21.0"
+				options.Version.MiddlewareVersion = types.String("0.1.0")
+			},
+			expected: []*Drift{
+				{
+					Network:     network,
+					Type:        VersionDrift,
+					Description: "middleware version changed",
+					Previous:    absent,
+					Current:     "0.1.0",
+				},
+				{
+					Network:     network,
+					Type:        VersionDrift,
+					Description: "node version changed",
+					Previous:    "0.20.1",
+					Current:     "0.21.0",
+				},
+			},
+		},
+		"operation types": {
+			modify: func(options *types.NetworkOptionsResponse) {
+				options.Allow.OperationTypes = []string{"INPUT", "OUTPUT", "COINBASE"}
+			},
+			expected: []*Drift{
+				{
+					Network:     network,
+					Type:        OperationTypeDrift,
+					Description: "COINBASE added",
+					Previous:    absent,
+					Current:     "COINBASE",
+				},
+				{
+					Network:     network,
+					Type:        OperationTypeDrift,
+					Description: "FEE removed",
+					Previous:    "FEE",
+					Current:     absent,
+					Breaking:    true,
+				},
+			},
+		},
+		"changed status": {
+			modify: func(options *types.NetworkOptionsResponse) {
+				options.Allow.OperationStatuses[1].Successful = true
+			},
+			expected: []*Drift{
+				{
+					Network:     network,
+					Type:        OperationStatusDrift,
+					Description: "FAILURE changed successful",
+					Previous:    "false",
+					Current:     "true",
+					Breaking:    true,
+				},
+			},
+		},
+		"changed error": {
+			modify: func(options *types.NetworkOptionsResponse) {
+				options.Allow.Errors[0].Retriable = true
+				options.Allow.HistoricalBalanceLookup = false
+			},
+			expected: []*Drift{
+				{
+					Network:     network,
+					Type:        ErrorDrift,
+					Description: "error 1 changed",
+					Previous:    "1 (not found, retriable: false)",
+					Current:     "1 (not found, retriable: true)",
+					Breaking:    true,
+				},
+				{
+					Network:     network,
+					Type:        HistoricalBalanceLookupDrift,
+					Description: "historical balance lookup changed",
+					Previous:    "true",
+					Current:     "false",
+					Breaking:    true,
+				},
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			current := networkOptions()
+			test.modify(current)
+
+			assert.Equal(t, test.expected, DiffOptions(network, networkOptions(), current))
+		})
+	}
+}
+
+func TestDiffCaptures(t *testing.T) {
+	dir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(dir)
+
+	other := &types.NetworkIdentifier{Blockchain: "Bitcoin", Network: "Mainnet"}
+	previous := &OptionsCapture{
+		Networks: []*NetworkOptions{
+			{Network: network, Options: networkOptions()},
+			{Network: other, Options: networkOptions()},
+		},
+	}
+
+	filePath := path.Join(dir, "options.json")
+	assert.NoError(t, previous.Save(filePath))
+	loaded, err := LoadOptionsCapture(filePath)
+	assert.NoError(t, err)
+	assert.Equal(t, previous, loaded)
+
+	current := &OptionsCapture{
+		Networks: []*NetworkOptions{{Network: network, Options: networkOptions()}},
+	}
+	drifts := DiffCaptures(loaded, current)
+	assert.Len(t, drifts, 1)
+	assert.Equal(t, NetworkDrift, drifts[0].Type)
+	assert.True(t, drifts[0].Breaking)
+}
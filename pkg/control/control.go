@@ -0,0 +1,292 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package control allows operators to pause, resume, and throttle
+// a running check without restarting it (for example, to stop all
+// requests to a node during maintenance).
+package control
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/fatih/color"
+)
+
+// Path is the path the controls are served on.
+const Path = "/control"
+
+var (
+	// ErrPaused is returned when a reconciliation pass
+	// is requested while the check is paused.
+	ErrPaused = errors.New("check is paused")
+
+	// ErrInvalidConcurrency is returned when the
+	// sync concurrency is set to a negative value.
+	ErrInvalidConcurrency = errors.New("sync concurrency must be non-negative")
+)
+
+// Status is the state of a *Controller.
+type Status struct {
+	Paused bool `json:"paused"`
+
+	// SyncConcurrency is the maximum number of concurrent
+	// block fetches (0 if not limited).
+	SyncConcurrency int64 `json:"sync_concurrency"`
+
+	// ActiveFetches is the number of block
+	// fetches currently in progress.
+	ActiveFetches int64 `json:"active_fetches"`
+}
+
+// ReconcileFunc queues all tracked accounts for
+// reconciliation and returns the number queued.
+type ReconcileFunc func(ctx context.Context) (int, error)
+
+// Controller gates the requests made by a running check. Block
+// fetches call Acquire and Release (and are limited to the sync
+// concurrency) and all other requests call Wait. While paused, both
+// block until the Controller is resumed.
+type Controller struct {
+	mutex       sync.Mutex
+	paused      bool
+	concurrency int64
+	active      int64
+
+	// changed is closed (and replaced) whenever
+	// the state of the Controller changes.
+	changed chan struct{}
+}
+
+// New returns a new, unpaused *Controller that
+// does not limit sync concurrency.
+func New() *Controller {
+	return &Controller{changed: make(chan struct{})}
+}
+
+// notify wakes all callers waiting for a state
+// change. The mutex must be held.
+func (c *Controller) notify() {
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+// Pause blocks all subsequent requests
+// until Resume is called.
+func (c *Controller) Pause() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.paused {
+		return
+	}
+
+	c.paused = true
+	c.notify()
+	color.Yellow("paused: no requests will be made until resumed")
+}
+
+// Resume unblocks all requests blocked by Pause.
+func (c *Controller) Resume() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if !c.paused {
+		return
+	}
+
+	c.paused = false
+	c.notify()
+	color.Green("resumed")
+}
+
+// SetConcurrency sets the maximum number of concurrent block
+// fetches. If concurrency is 0, block fetches are not limited.
+// Block fetches already in progress are not interrupted.
+func (c *Controller) SetConcurrency(concurrency int64) error {
+	if concurrency < 0 {
+		return fmt.Errorf("%w: %d", ErrInvalidConcurrency, concurrency)
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.concurrency = concurrency
+	c.notify()
+	color.Yellow("sync concurrency set to %d", concurrency)
+	return nil
+}
+
+// Status returns the current state of the Controller.
+func (c *Controller) Status() *Status {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return &Status{
+		Paused:          c.paused,
+		SyncConcurrency: c.concurrency,
+		ActiveFetches:   c.active,
+	}
+}
+
+// Paused returns a boolean indicating if
+// the Controller is paused.
+func (c *Controller) Paused() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.paused
+}
+
+// wait blocks until ready returns true (called with the
+// mutex held) or ctx is canceled.
+func (c *Controller) wait(ctx context.Context, ready func() bool) error {
+	for {
+		c.mutex.Lock()
+		if ready() {
+			c.mutex.Unlock()
+			return nil
+		}
+		changed := c.changed
+		c.mutex.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// Wait blocks while the Controller is paused.
+func (c *Controller) Wait(ctx context.Context) error {
+	return c.wait(ctx, func() bool {
+		return !c.paused
+	})
+}
+
+// Acquire blocks while the Controller is paused or the
+// sync concurrency is reached. Release must be called
+// once the block fetch is complete.
+func (c *Controller) Acquire(ctx context.Context) error {
+	return c.wait(ctx, func() bool {
+		if c.paused || (c.concurrency > 0 && c.active >= c.concurrency) {
+			return false
+		}
+
+		c.active++
+		return true
+	})
+}
+
+// Release ends a block fetch started with Acquire.
+func (c *Controller) Release() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.active--
+	c.notify()
+}
+
+// Handler returns an http.Handler that serves the controls:
+//
+//	GET  /control              the current Status
+//	POST /control/pause        pause all requests
+//	POST /control/resume       resume all requests
+//	POST /control/concurrency  set the sync concurrency to ?value=
+//	POST /control/reconcile    queue all tracked accounts for
+//	                           reconciliation (using reconcile)
+//
+// If reconcile is nil, /control/reconcile is not served.
+func Handler(c *Controller, reconcile ReconcileFunc) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(Path, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, http.MethodGet)
+			return
+		}
+
+		writeJSON(w, c.Status())
+	})
+	mux.HandleFunc(Path+"/pause", post(func(w http.ResponseWriter, r *http.Request) {
+		c.Pause()
+		writeJSON(w, c.Status())
+	}))
+	mux.HandleFunc(Path+"/resume", post(func(w http.ResponseWriter, r *http.Request) {
+		c.Resume()
+		writeJSON(w, c.Status())
+	}))
+	mux.HandleFunc(Path+"/concurrency", post(func(w http.ResponseWriter, r *http.Request) {
+		concurrency, err := strconv.ParseInt(r.URL.Query().Get("value"), 10, 64)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("%s: invalid concurrency", err.Error()), http.StatusBadRequest)
+			return
+		}
+
+		if err := c.SetConcurrency(concurrency); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		writeJSON(w, c.Status())
+	}))
+	if reconcile != nil {
+		mux.HandleFunc(Path+"/reconcile", post(func(w http.ResponseWriter, r *http.Request) {
+			// Reconciliations cannot be performed while paused,
+			// so queueing them would block until resumed.
+			if c.Paused() {
+				http.Error(w, ErrPaused.Error(), http.StatusConflict)
+				return
+			}
+
+			queued, err := reconcile(r.Context())
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			writeJSON(w, map[string]int{"queued": queued})
+		}))
+	}
+
+	return mux
+}
+
+// post wraps handler so that it only serves POST requests.
+func post(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			methodNotAllowed(w, http.MethodPost)
+			return
+		}
+
+		handler(w, r)
+	}
+}
+
+func methodNotAllowed(w http.ResponseWriter, allow string) {
+	w.Header().Set("Allow", allow)
+	http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,198 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package control
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// waitTimeout is how long to wait for a blocked
+// call to return before failing a test.
+const waitTimeout = 5 * time.Second
+
+func TestPauseResume(t *testing.T) {
+	ctx := context.Background()
+	c := New()
+	assert.NoError(t, c.Wait(ctx))
+
+	c.Pause()
+	done := make(chan error)
+	go func() {
+		done <- c.Wait(ctx)
+	}()
+
+	select {
+	case <-done:
+		assert.Fail(t, "wait returned while paused")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	c.Resume()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(waitTimeout):
+		assert.Fail(t, "wait did not return after resume")
+	}
+
+	// Canceling the context unblocks waiting callers.
+	c.Pause()
+	cancelCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	assert.True(t, errors.Is(c.Acquire(cancelCtx), context.Canceled))
+}
+
+func TestConcurrency(t *testing.T) {
+	ctx := context.Background()
+	c := New()
+	assert.True(t, errors.Is(c.SetConcurrency(-1), ErrInvalidConcurrency))
+	assert.NoError(t, c.SetConcurrency(1))
+
+	assert.NoError(t, c.Acquire(ctx))
+	done := make(chan error)
+	go func() {
+		done <- c.Acquire(ctx)
+	}()
+
+	select {
+	case <-done:
+		assert.Fail(t, "acquire returned at concurrency limit")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	c.Release()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(waitTimeout):
+		assert.Fail(t, "acquire did not return after release")
+	}
+
+	assert.Equal(t, &Status{SyncConcurrency: 1, ActiveFetches: 1}, c.Status())
+
+	// Removing the limit unblocks waiting callers.
+	go func() {
+		done <- c.Acquire(ctx)
+	}()
+	assert.NoError(t, c.SetConcurrency(0))
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(waitTimeout):
+		assert.Fail(t, "acquire did not return after limit was removed")
+	}
+}
+
+func TestHandler(t *testing.T) {
+	c := New()
+	queued := 0
+	server := httptest.NewServer(Handler(c, func(ctx context.Context) (int, error) {
+		queued += 3
+		return 3, nil
+	}))
+	defer server.Close()
+
+	// Requests are made in order because
+	// each request changes the state of c.
+	var tests = []struct {
+		name   string
+		method string
+		path   string
+
+		code   int
+		status *Status
+	}{
+		{
+			name:   "status",
+			method: http.MethodGet,
+			path:   Path,
+			code:   http.StatusOK,
+			status: &Status{},
+		},
+		{
+			name:   "pause with GET",
+			method: http.MethodGet,
+			path:   Path + "/pause",
+			code:   http.StatusMethodNotAllowed,
+		},
+		{
+			name:   "pause",
+			method: http.MethodPost,
+			path:   Path + "/pause",
+			code:   http.StatusOK,
+			status: &Status{Paused: true},
+		},
+		{
+			name:   "reconcile while paused",
+			method: http.MethodPost,
+			path:   Path + "/reconcile",
+			code:   http.StatusConflict,
+		},
+		{
+			name:   "resume",
+			method: http.MethodPost,
+			path:   Path + "/resume",
+			code:   http.StatusOK,
+			status: &Status{},
+		},
+		{
+			name:   "invalid concurrency",
+			method: http.MethodPost,
+			path:   Path + "/concurrency?value=-2",
+			code:   http.StatusBadRequest,
+		},
+		{
+			name:   "concurrency",
+			method: http.MethodPost,
+			path:   Path + "/concurrency?value=4",
+			code:   http.StatusOK,
+			status: &Status{SyncConcurrency: 4},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req, err := http.NewRequest(test.method, server.URL+test.path, nil)
+			assert.NoError(t, err)
+
+			resp, err := http.DefaultClient.Do(req)
+			assert.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, test.code, resp.StatusCode)
+			if test.status == nil {
+				return
+			}
+
+			var status Status
+			assert.NoError(t, json.NewDecoder(resp.Body).Decode(&status))
+			assert.Equal(t, test.status, &status)
+		})
+	}
+
+	resp, err := http.Post(server.URL+Path+"/reconcile", "", nil)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, 3, queued)
+}
@@ -0,0 +1,402 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package curves delegates key generation and signing on curves
+// that are not natively supported (like sr25519 or BLS12-381) to
+// curve plugins. A curve plugin is any executable that uses the same
+// framing as block worker plugins (see pkg/plugin): it reads requests
+// (one JSON object per line) from stdin and writes exactly one
+// response (one JSON object per line) to stdout for each request.
+//
+// A curve plugin must support three types of Request:
+//
+//	generate_key  respond with a new public_key and private_key_hex
+//	public_key    respond with the public_key of private_key_hex
+//	sign          respond with the signature of payload by
+//	              private_key_hex (hex encoded) and its
+//	              signature_type
+//
+// Private keys are opaque to the rosetta-cli (they are stored
+// exactly as returned by generate_key).
+//
+// Key pairs are generated by the generate_key action of the
+// coordinator (which cannot be overridden and only supports native
+// curves). generate_key actions on a plugin curve are rewritten to
+// generate a placeholder key pair on PlaceholderCurve and each
+// placeholder is replaced by a key pair generated by the plugin the
+// first time its public key is derived or stored (like seeded keys).
+package curves
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/coinbase/rosetta-cli/configuration"
+	"github.com/coinbase/rosetta-cli/pkg/plugin"
+
+	"github.com/coinbase/rosetta-sdk-go/constructor/job"
+	"github.com/coinbase/rosetta-sdk-go/keys"
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+const (
+	// ProtocolVersion is the version of the curve plugin
+	// protocol. It is included in every Request so plugins
+	// can reject requests they do not understand.
+	ProtocolVersion = 1
+
+	// GenerateKeyRequest is the Type of a
+	// Request for a new key pair.
+	GenerateKeyRequest = "generate_key"
+
+	// PublicKeyRequest is the Type of a Request for
+	// the public key of a private key.
+	PublicKeyRequest = "public_key"
+
+	// SignRequest is the Type of a Request to
+	// sign a payload with a private key.
+	SignRequest = "sign"
+
+	// PlaceholderCurve is the curve of the key pairs generated
+	// by rewritten generate_key actions.
+	PlaceholderCurve = types.Edwards25519
+)
+
+var (
+	// ErrPluginError is returned when a curve plugin
+	// responds to a request with an error.
+	ErrPluginError = errors.New("curve plugin returned error")
+
+	// ErrInvalidResponse is returned when a curve plugin
+	// responds without the requested key or signature.
+	ErrInvalidResponse = errors.New("invalid curve plugin response")
+
+	// ErrUnsupportedCurve is returned when a request is
+	// made for a curve without a curve plugin.
+	ErrUnsupportedCurve = errors.New("curve has no plugin")
+
+	// ErrAmbiguousPlaceholder is returned when generate_key
+	// actions cannot be rewritten because placeholder key
+	// pairs could not be attributed to a single curve.
+	ErrAmbiguousPlaceholder = errors.New("placeholder key pairs are ambiguous")
+)
+
+// Request is sent to a curve plugin.
+type Request struct {
+	Version       int                   `json:"version"`
+	Type          string                `json:"type"`
+	CurveType     types.CurveType       `json:"curve_type"`
+	PrivateKeyHex string                `json:"private_key_hex,omitempty"`
+	Payload       *types.SigningPayload `json:"payload,omitempty"`
+}
+
+// Response is returned by a curve plugin for each
+// Request. If Error is populated, the request failed.
+type Response struct {
+	Error         string              `json:"error,omitempty"`
+	PublicKey     *types.PublicKey    `json:"public_key,omitempty"`
+	PrivateKeyHex string              `json:"private_key_hex,omitempty"`
+	SignatureHex  string              `json:"signature_hex,omitempty"`
+	SignatureType types.SignatureType `json:"signature_type,omitempty"`
+}
+
+// generateKeyInput is the input of a generate_key action.
+type generateKeyInput struct {
+	CurveType types.CurveType `json:"curve_type"`
+}
+
+// Registry is the curve plugin of each
+// curve that is not natively supported.
+type Registry struct {
+	plugins map[types.CurveType]*plugin.Plugin
+
+	// generated is the curve placeholder key pairs are
+	// replaced on (empty if no generate_key action was
+	// rewritten).
+	generated types.CurveType
+
+	// replaced are the key pairs that replaced each
+	// placeholder (keyed by placeholder public key).
+	replaced map[string]*keys.KeyPair
+	mutex    sync.Mutex
+}
+
+// Start starts the curve plugin described by each config.
+func Start(configs []*configuration.CurvePlugin) (*Registry, error) {
+	r := &Registry{
+		plugins:  map[types.CurveType]*plugin.Plugin{},
+		replaced: map[string]*keys.KeyPair{},
+	}
+
+	for _, config := range configs {
+		p, err := plugin.Start(
+			fmt.Sprintf("%s_curve", config.CurveType),
+			config.Path,
+			config.Args,
+			nil,
+			time.Duration(config.Timeout)*time.Second,
+		)
+		if err != nil {
+			_ = r.Close()
+			return nil, fmt.Errorf("%w: unable to start %s curve plugin", err, config.CurveType)
+		}
+
+		r.plugins[config.CurveType] = p
+	}
+
+	return r, nil
+}
+
+// Supports returns a boolean indicating if
+// curve is handled by a curve plugin.
+func (r *Registry) Supports(curve types.CurveType) bool {
+	_, ok := r.plugins[curve]
+	return ok
+}
+
+// call sends request to the curve plugin of
+// request.CurveType and returns its response.
+func (r *Registry) call(ctx context.Context, request *Request) (*Response, error) {
+	p, ok := r.plugins[request.CurveType]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedCurve, request.CurveType)
+	}
+
+	request.Version = ProtocolVersion
+
+	var response Response
+	if err := p.Call(ctx, request, &response); err != nil {
+		return nil, err
+	}
+
+	if len(response.Error) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrPluginError, response.Error)
+	}
+
+	return &response, nil
+}
+
+// keyPair returns the key pair of privateKeyHex and the public key
+// in response (ensuring it is on curve).
+func keyPair(
+	curve types.CurveType,
+	privateKeyHex string,
+	response *Response,
+) (*keys.KeyPair, error) {
+	if response.PublicKey == nil || len(response.PublicKey.Bytes) == 0 {
+		return nil, fmt.Errorf("%w: %s public key is missing", ErrInvalidResponse, curve)
+	}
+
+	if response.PublicKey.CurveType != curve {
+		return nil, fmt.Errorf(
+			"%w: public key is on %s (expected %s)",
+			ErrInvalidResponse,
+			response.PublicKey.CurveType,
+			curve,
+		)
+	}
+
+	privateKey, err := hex.DecodeString(privateKeyHex)
+	if err != nil || len(privateKey) == 0 {
+		return nil, fmt.Errorf(
+			"%w: %s private key is not hex encoded",
+			ErrInvalidResponse,
+			curve,
+		)
+	}
+
+	return &keys.KeyPair{PublicKey: response.PublicKey, PrivateKey: privateKey}, nil
+}
+
+// GenerateKey returns a new key pair on curve.
+func (r *Registry) GenerateKey(ctx context.Context, curve types.CurveType) (*keys.KeyPair, error) {
+	response, err := r.call(ctx, &Request{Type: GenerateKeyRequest, CurveType: curve})
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to generate %s key", err, curve)
+	}
+
+	return keyPair(curve, response.PrivateKeyHex, response)
+}
+
+// ImportPrivateKey returns the key pair of
+// the hex-encoded privateKeyHex on curve.
+func (r *Registry) ImportPrivateKey(
+	ctx context.Context,
+	privateKeyHex string,
+	curve types.CurveType,
+) (*keys.KeyPair, error) {
+	response, err := r.call(ctx, &Request{
+		Type:          PublicKeyRequest,
+		CurveType:     curve,
+		PrivateKeyHex: privateKeyHex,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to import %s key", err, curve)
+	}
+
+	return keyPair(curve, privateKeyHex, response)
+}
+
+// Sign signs payload with keyPair. If the payload does not
+// specify a signature type, the curve plugin chooses it.
+func (r *Registry) Sign(
+	ctx context.Context,
+	keyPair *keys.KeyPair,
+	payload *types.SigningPayload,
+) (*types.Signature, error) {
+	curve := keyPair.PublicKey.CurveType
+	response, err := r.call(ctx, &Request{
+		Type:          SignRequest,
+		CurveType:     curve,
+		PrivateKeyHex: hex.EncodeToString(keyPair.PrivateKey),
+		Payload:       payload,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to sign with %s key", err, curve)
+	}
+
+	signature, err := hex.DecodeString(response.SignatureHex)
+	if err != nil || len(signature) == 0 {
+		return nil, fmt.Errorf(
+			"%w: signature %s is not hex encoded",
+			ErrInvalidResponse,
+			response.SignatureHex,
+		)
+	}
+
+	signatureType := payload.SignatureType
+	if len(signatureType) == 0 {
+		signatureType = response.SignatureType
+	}
+
+	if len(signatureType) == 0 {
+		return nil, fmt.Errorf("%w: signature type is missing", ErrInvalidResponse)
+	}
+
+	return &types.Signature{
+		SigningPayload: payload,
+		PublicKey:      keyPair.PublicKey,
+		SignatureType:  signatureType,
+		Bytes:          signature,
+	}, nil
+}
+
+// RewriteWorkflows rewrites all generate_key actions on a plugin
+// curve (with a literal input) in workflows to generate placeholder
+// key pairs instead. Placeholders must be attributable to a single
+// curve, so workflows cannot generate keys on more than one plugin
+// curve or also generate keys on PlaceholderCurve.
+func (r *Registry) RewriteWorkflows(workflows []*job.Workflow) error {
+	rewrite := []*job.Action{}
+	generatesPlaceholders := false
+	for _, workflow := range workflows {
+		for _, scenario := range workflow.Scenarios {
+			for _, action := range scenario.Actions {
+				if action.Type != job.GenerateKey {
+					continue
+				}
+
+				var input generateKeyInput
+				if err := json.Unmarshal([]byte(action.Input), &input); err != nil {
+					continue
+				}
+
+				if input.CurveType == PlaceholderCurve {
+					generatesPlaceholders = true
+				}
+
+				if !r.Supports(input.CurveType) {
+					continue
+				}
+
+				if len(r.generated) > 0 && r.generated != input.CurveType {
+					return fmt.Errorf(
+						"%w: keys are generated on %s and %s",
+						ErrAmbiguousPlaceholder,
+						r.generated,
+						input.CurveType,
+					)
+				}
+
+				r.generated = input.CurveType
+				rewrite = append(rewrite, action)
+			}
+		}
+	}
+
+	if len(rewrite) == 0 {
+		return nil
+	}
+
+	if generatesPlaceholders {
+		return fmt.Errorf(
+			"%w: keys are generated on %s and %s",
+			ErrAmbiguousPlaceholder,
+			PlaceholderCurve,
+			r.generated,
+		)
+	}
+
+	placeholder := types.PrintStruct(&generateKeyInput{CurveType: PlaceholderCurve})
+	for _, action := range rewrite {
+		action.Input = placeholder
+	}
+
+	return nil
+}
+
+// Replace returns the key pair generated by the curve plugin
+// that replaces the placeholder publicKey (and a boolean
+// indicating if publicKey is a placeholder).
+func (r *Registry) Replace(
+	ctx context.Context,
+	publicKey *types.PublicKey,
+) (*keys.KeyPair, bool, error) {
+	if len(r.generated) == 0 || publicKey.CurveType != PlaceholderCurve {
+		return nil, false, nil
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	placeholder := hex.EncodeToString(publicKey.Bytes)
+	if keyPair, ok := r.replaced[placeholder]; ok {
+		return keyPair, true, nil
+	}
+
+	keyPair, err := r.GenerateKey(ctx, r.generated)
+	if err != nil {
+		return nil, false, err
+	}
+
+	r.replaced[placeholder] = keyPair
+	return keyPair, true, nil
+}
+
+// Close stops all curve plugins.
+func (r *Registry) Close() error {
+	var closeErr error
+	for curve, p := range r.plugins {
+		if err := p.Close(); err != nil {
+			closeErr = fmt.Errorf("%w: unable to close %s curve plugin", err, curve)
+		}
+	}
+
+	return closeErr
+}
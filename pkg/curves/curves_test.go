@@ -0,0 +1,204 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package curves
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/coinbase/rosetta-cli/configuration"
+
+	"github.com/coinbase/rosetta-sdk-go/constructor/job"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	sr25519 = types.CurveType("sr25519")
+	bls     = types.CurveType("bls12381")
+)
+
+// pluginScript generates the key pair 0a0b/0102 (on sr25519), responds
+// to public_key requests for 0a0b with 0102, and responds to sign
+// requests with signature.
+func pluginScript(signature string) string {
+	return `while read line; do
+  case "$line" in
+    *'"type":"generate_key","curve_type":"sr25519"'*)
+      echo '{"public_key":{"hex_bytes":"0102","curve_type":"sr25519"},"private_key_hex":"0a0b"}' ;;
+    *'"type":"public_key","curve_type":"sr25519","private_key_hex":"0a0b"'*)
+      echo '{"public_key":{"hex_bytes":"0102","curve_type":"sr25519"}}' ;;
+    *'"type":"sign","curve_type":"sr25519","private_key_hex":"0a0b","payload":{'*)
+      echo '` + signature + `' ;;
+    *) echo '{"error":"unexpected request"}' ;;
+  esac
+done`
+}
+
+func startRegistry(t *testing.T, signature string) *Registry {
+	r, err := Start([]*configuration.CurvePlugin{
+		{CurveType: sr25519, Path: "sh", Args: []string{"-c", pluginScript(signature)}},
+	})
+	assert.NoError(t, err)
+
+	return r
+}
+
+func TestSign(t *testing.T) {
+	payload := &types.SigningPayload{
+		AccountIdentifier: &types.AccountIdentifier{Address: "addr1"},
+		Bytes:             []byte("payload"),
+	}
+
+	var tests = map[string]struct {
+		signature string
+
+		expected *types.Signature
+		err      error
+	}{
+		"success": {
+			signature: `{"signature_hex":"abcd","signature_type":"schnorrkel"}`,
+			expected: &types.Signature{
+				SigningPayload: payload,
+				PublicKey:      &types.PublicKey{Bytes: []byte{1, 2}, CurveType: sr25519},
+				SignatureType:  "schnorrkel",
+				Bytes:          []byte{0xab, 0xcd},
+			},
+		},
+		"missing signature type": {
+			signature: `{"signature_hex":"abcd"}`,
+			err:       ErrInvalidResponse,
+		},
+		"plugin error": {
+			signature: `{"error":"invalid payload"}`,
+			err:       ErrPluginError,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			r := startRegistry(t, test.signature)
+			defer r.Close()
+
+			assert.True(t, r.Supports(sr25519))
+			assert.False(t, r.Supports(bls))
+
+			keyPair, err := r.ImportPrivateKey(ctx, "0a0b", sr25519)
+			assert.NoError(t, err)
+
+			signature, err := r.Sign(ctx, keyPair, payload)
+			if test.err != nil {
+				assert.True(t, errors.Is(err, test.err))
+				assert.Nil(t, signature)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, signature)
+		})
+	}
+}
+
+func generateKeyWorkflow(curves ...types.CurveType) *job.Workflow {
+	actions := []*job.Action{}
+	for _, curve := range curves {
+		actions = append(actions, &job.Action{
+			Type:       job.GenerateKey,
+			Input:      types.PrintStruct(&generateKeyInput{CurveType: curve}),
+			OutputPath: "key",
+		})
+	}
+
+	return &job.Workflow{
+		Name:      string(job.CreateAccount),
+		Scenarios: []*job.Scenario{{Name: "create_account", Actions: actions}},
+	}
+}
+
+func TestRewriteWorkflows(t *testing.T) {
+	var tests = map[string]struct {
+		curves []types.CurveType
+
+		expected []types.CurveType
+		err      error
+	}{
+		"no plugin curves": {
+			curves:   []types.CurveType{types.Secp256k1, PlaceholderCurve},
+			expected: []types.CurveType{types.Secp256k1, PlaceholderCurve},
+		},
+		"plugin curve": {
+			curves:   []types.CurveType{types.Secp256k1, sr25519},
+			expected: []types.CurveType{types.Secp256k1, PlaceholderCurve},
+		},
+		"plugin and placeholder curves": {
+			curves: []types.CurveType{PlaceholderCurve, sr25519},
+			err:    ErrAmbiguousPlaceholder,
+		},
+		"multiple plugin curves": {
+			curves: []types.CurveType{bls, sr25519},
+			err:    ErrAmbiguousPlaceholder,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			r, err := Start([]*configuration.CurvePlugin{
+				{CurveType: sr25519, Path: "cat"},
+				{CurveType: bls, Path: "cat"},
+			})
+			assert.NoError(t, err)
+			defer r.Close()
+
+			workflow := generateKeyWorkflow(test.curves...)
+			err = r.RewriteWorkflows([]*job.Workflow{workflow})
+			if test.err != nil {
+				assert.True(t, errors.Is(err, test.err))
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, generateKeyWorkflow(test.expected...), workflow)
+		})
+	}
+}
+
+func TestReplace(t *testing.T) {
+	ctx := context.Background()
+	r := startRegistry(t, "{}")
+	defer r.Close()
+
+	placeholder := &types.PublicKey{Bytes: []byte{0xff}, CurveType: PlaceholderCurve}
+	_, replaced, err := r.Replace(ctx, placeholder)
+	assert.NoError(t, err)
+	assert.False(t, replaced)
+
+	assert.NoError(t, r.RewriteWorkflows([]*job.Workflow{generateKeyWorkflow(sr25519)}))
+	keyPair, replaced, err := r.Replace(ctx, placeholder)
+	assert.NoError(t, err)
+	assert.True(t, replaced)
+	assert.Equal(t, &types.PublicKey{Bytes: []byte{1, 2}, CurveType: sr25519}, keyPair.PublicKey)
+	assert.Equal(t, []byte{0x0a, 0x0b}, keyPair.PrivateKey)
+
+	again, replaced, err := r.Replace(ctx, placeholder)
+	assert.NoError(t, err)
+	assert.True(t, replaced)
+	assert.Equal(t, keyPair, again)
+
+	_, replaced, err = r.Replace(ctx, &types.PublicKey{Bytes: []byte{1}, CurveType: types.Secp256k1})
+	assert.NoError(t, err)
+	assert.False(t, replaced)
+}
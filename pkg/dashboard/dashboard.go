@@ -0,0 +1,173 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dashboard
+
+import (
+	"net/http"
+)
+
+// Path is the path the dashboard is served on.
+const Path = "/dashboard"
+
+// Handler returns an http.Handler that serves a read-only
+// dashboard. The dashboard is a single static page that
+// periodically polls the /status endpoint served by the
+// same server, so it requires no additional state.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(page))
+	})
+}
+
+// page is the dashboard HTML. It is stored as a string
+// (instead of a separate asset) so that the rosetta-cli
+// remains a single binary.
+const page = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>rosetta-cli</title>
+<style>
+  body { font-family: -apple-system, Helvetica, Arial, sans-serif; margin: 2em; color: #222; }
+  h1 { font-size: 1.4em; }
+  h2 { font-size: 1.1em; margin-top: 2em; }
+  table { border-collapse: collapse; }
+  td, th { border: 1px solid #ccc; padding: 4px 10px; text-align: left; }
+  progress { width: 400px; height: 1.2em; }
+  details { margin: 4px 0; }
+  summary { cursor: pointer; }
+  pre { background: #f5f5f5; padding: 8px; overflow-x: auto; }
+  #error { color: #b00; }
+  .muted { color: #888; }
+</style>
+</head>
+<body>
+<h1>rosetta-cli status</h1>
+<div id="error"></div>
+<div id="updated" class="muted"></div>
+
+<section id="progress-section">
+  <h2>Sync Progress</h2>
+  <progress id="progress-bar" max="1" value="0"></progress>
+  <table id="progress"></table>
+</section>
+
+<section id="throughput-section">
+  <h2>Recent Throughput</h2>
+  <table id="throughput"></table>
+</section>
+
+<h2>Counters</h2>
+<table id="stats"></table>
+
+<h2>Recent Failures</h2>
+<div id="failures"><span class="muted">none</span></div>
+
+<script>
+"use strict";
+
+var refreshInterval = 5000;
+
+function label(key) {
+  return key.replace(/_/g, " ");
+}
+
+function renderTable(id, obj) {
+  var table = document.getElementById(id);
+  table.innerHTML = "";
+  Object.keys(obj || {}).forEach(function (key) {
+    var row = table.insertRow();
+    row.insertCell().textContent = label(key);
+    var value = obj[key];
+    row.insertCell().textContent =
+      typeof value === "object" ? JSON.stringify(value) : String(value);
+  });
+}
+
+function renderFailures(failures) {
+  var container = document.getElementById("failures");
+  container.innerHTML = "";
+  if (!failures || failures.length === 0) {
+    container.innerHTML = '<span class="muted">none</span>';
+    return;
+  }
+
+  failures.slice().reverse().forEach(function (failure) {
+    var details = document.createElement("details");
+    var summary = document.createElement("summary");
+    summary.textContent =
+      (failure.account ? failure.account.address : "unknown") +
+      (failure.block ? " at block " + failure.block.index : "") +
+      " (" + failure.type + ")";
+    var body = document.createElement("pre");
+    body.textContent = JSON.stringify(failure, null, 2);
+    details.appendChild(summary);
+    details.appendChild(body);
+    container.appendChild(details);
+  });
+}
+
+function render(status) {
+  var progress = status.progress;
+  document.getElementById("progress-section").hidden = !progress;
+  if (progress) {
+    var bar = document.getElementById("progress-bar");
+    bar.hidden = typeof progress.completed !== "number";
+    if (!bar.hidden) { bar.value = progress.completed / 100; }
+    var summary = {};
+    Object.keys(progress).forEach(function (key) { summary[key] = progress[key]; });
+    if (status.head_block) { summary.head_block = status.head_block.index; }
+    if (status.tip_distance !== undefined) { summary.tip_distance = status.tip_distance; }
+    renderTable("progress", summary);
+  }
+
+  document.getElementById("throughput-section").hidden = !status.throughput;
+  renderTable("throughput", status.throughput);
+
+  renderTable("stats", status.stats);
+  renderFailures(status.recent_failures);
+}
+
+function refresh() {
+  fetch("/status")
+    .then(function (response) {
+      if (!response.ok) { throw new Error(response.status + " " + response.statusText); }
+      return response.json();
+    })
+    .then(function (status) {
+      document.getElementById("error").textContent = "";
+      document.getElementById("updated").textContent =
+        "last updated " + new Date().toLocaleTimeString();
+      render(status);
+    })
+    .catch(function (err) {
+      document.getElementById("error").textContent = "unable to fetch status: " + err;
+    });
+}
+
+refresh();
+setInterval(refresh, refreshInterval);
+</script>
+</body>
+</html>
+`
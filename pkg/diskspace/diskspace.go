@@ -0,0 +1,91 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package diskspace monitors the free space on the volume of
+// the data directory. Badger can corrupt its files when a write
+// fails because the disk is full, so check:data prunes blocks
+// and eventually halts before that happens.
+package diskspace
+
+import (
+	"fmt"
+
+	"github.com/coinbase/rosetta-cli/configuration"
+)
+
+// bytesPerMB converts configured thresholds to bytes.
+const bytesPerMB = 1024 * 1024
+
+// Level describes the free space on a volume
+// relative to the configured thresholds.
+type Level int
+
+const (
+	// Ok indicates there is more free space than
+	// the low threshold.
+	Ok Level = iota
+
+	// Low indicates there is less free space than
+	// the low threshold (but more than the halt
+	// threshold).
+	Low
+
+	// Exhausted indicates there is less free space
+	// than the halt threshold.
+	Exhausted
+)
+
+// Watchdog checks the free space on the
+// volume of a directory.
+type Watchdog struct {
+	dir    string
+	config *configuration.DiskSpaceWatchdog
+
+	// free returns the free space (in bytes) on the volume
+	// of a directory. It is only overridden in tests.
+	free func(dir string) (uint64, error)
+}
+
+// NewWatchdog returns a new *Watchdog for dir.
+func NewWatchdog(dir string, config *configuration.DiskSpaceWatchdog) *Watchdog {
+	return &Watchdog{
+		dir:    dir,
+		config: config,
+		free:   Free,
+	}
+}
+
+// Check returns the free space (in bytes) on the volume of
+// the watched directory and its Level.
+func (w *Watchdog) Check() (uint64, Level, error) {
+	free, err := w.free(w.dir)
+	if err != nil {
+		return 0, Ok, fmt.Errorf("%w: unable to get free space of %s", err, w.dir)
+	}
+
+	switch {
+	case free < w.config.HaltThreshold*bytesPerMB:
+		return free, Exhausted, nil
+	case free < w.config.LowThreshold*bytesPerMB:
+		return free, Low, nil
+	default:
+		return free, Ok, nil
+	}
+}
+
+// FormatMB returns free (in bytes) as
+// a human-readable number of MB.
+func FormatMB(free uint64) string {
+	return fmt.Sprintf("%d MB", free/bytesPerMB)
+}
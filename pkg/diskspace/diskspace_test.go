@@ -0,0 +1,95 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diskspace
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/coinbase/rosetta-cli/configuration"
+
+	"github.com/coinbase/rosetta-sdk-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFree(t *testing.T) {
+	dir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(dir)
+
+	free, err := Free(dir)
+	assert.NoError(t, err)
+	assert.True(t, free > 0)
+
+	_, err = Free("/path/that/does/not/exist")
+	assert.Error(t, err)
+}
+
+func TestCheck(t *testing.T) {
+	var tests = map[string]struct {
+		free uint64
+		err  error
+
+		level Level
+	}{
+		"ok": {
+			free:  3 * bytesPerMB,
+			level: Ok,
+		},
+		"low": {
+			free:  2*bytesPerMB - 1,
+			level: Low,
+		},
+		"at halt threshold": {
+			free:  bytesPerMB,
+			level: Low,
+		},
+		"exhausted": {
+			free:  bytesPerMB - 1,
+			level: Exhausted,
+		},
+		"error": {
+			err: errors.New("unsupported"),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			w := NewWatchdog("dir", &configuration.DiskSpaceWatchdog{
+				LowThreshold:  2,
+				HaltThreshold: 1,
+			})
+			w.free = func(dir string) (uint64, error) {
+				assert.Equal(t, "dir", dir)
+				return test.free, test.err
+			}
+
+			free, level, err := w.Check()
+			if test.err != nil {
+				assert.True(t, errors.Is(err, test.err))
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, test.free, free)
+			assert.Equal(t, test.level, level)
+		})
+	}
+}
+
+func TestFormatMB(t *testing.T) {
+	assert.Equal(t, "0 MB", FormatMB(bytesPerMB-1))
+	assert.Equal(t, "1536 MB", FormatMB(1536*bytesPerMB))
+}
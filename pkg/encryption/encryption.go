@@ -0,0 +1,157 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package encryption loads the key used to encrypt badger
+// databases at rest and rotates it. Badger encrypts all data
+// with data keys that are stored in a key registry encrypted
+// with this key, so rotating the key only rewrites the
+// key registry (and not the data).
+package encryption
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/coinbase/rosetta-cli/configuration"
+
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/dgraph-io/badger/v2"
+)
+
+const (
+	// dataKeyRotationDuration is how often badger
+	// generates a new data key (badger's default).
+	dataKeyRotationDuration = 10 * 24 * time.Hour
+
+	// aes128KeySize, aes192KeySize, and aes256KeySize
+	// are the supported key sizes (in bytes).
+	aes128KeySize = 16
+	aes192KeySize = 24
+	aes256KeySize = 32
+)
+
+var (
+	// ErrInvalidKey is returned when a key is not
+	// 16, 24, or 32 hex-encoded bytes.
+	ErrInvalidKey = errors.New("key must be 16, 24, or 32 hex-encoded bytes")
+
+	// ErrKeyNotFound is returned when no key
+	// can be read from a file or environment variable.
+	ErrKeyNotFound = errors.New("key not found")
+)
+
+// ParseKey decodes a hex-encoded key (surrounding
+// whitespace is ignored).
+func ParseKey(encoded string) ([]byte, error) {
+	key, err := hex.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidKey, err.Error())
+	}
+
+	switch len(key) {
+	case aes128KeySize, aes192KeySize, aes256KeySize:
+		return key, nil
+	default:
+		return nil, fmt.Errorf("%w: found %d bytes", ErrInvalidKey, len(key))
+	}
+}
+
+// ReadKey reads a key from keyFile or, if keyFile
+// is empty, from the environment variable keyEnv.
+func ReadKey(keyFile string, keyEnv string) ([]byte, error) {
+	if len(keyFile) > 0 {
+		contents, err := ioutil.ReadFile(keyFile) // #nosec G304
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to read %s", err, keyFile)
+		}
+
+		return ParseKey(string(contents))
+	}
+
+	if len(keyEnv) == 0 {
+		return nil, fmt.Errorf("%w: no key file or environment variable provided", ErrKeyNotFound)
+	}
+
+	encoded, ok := os.LookupEnv(keyEnv)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s is not set", ErrKeyNotFound, keyEnv)
+	}
+
+	return ParseKey(encoded)
+}
+
+// LoadKey returns the key configured by config. If
+// config is nil, encryption is disabled and a nil
+// key is returned.
+func LoadKey(config *configuration.EncryptionConfiguration) ([]byte, error) {
+	if config == nil {
+		return nil, nil
+	}
+
+	return ReadKey(config.KeyFile, config.KeyEnv)
+}
+
+// BadgerOptions returns the options used to open the badger
+// database in dir (storage.PerformanceBadgerOptions if
+// performance is true and storage.DefaultBadgerOptions otherwise)
+// encrypted with key. If key is nil, the database is not encrypted.
+func BadgerOptions(dir string, performance bool, key []byte) badger.Options {
+	opts := storage.DefaultBadgerOptions(dir)
+	if performance {
+		opts = storage.PerformanceBadgerOptions(dir)
+	}
+
+	if len(key) == 0 {
+		return opts
+	}
+
+	return opts.
+		WithEncryptionKey(key).
+		WithEncryptionKeyRotationDuration(dataKeyRotationDuration)
+}
+
+// Rotate re-encrypts the key registry of the badger database in
+// dir (which was encrypted with oldKey) with newKey. Once rotated,
+// the database can only be opened with newKey.
+//
+// The database must not be open in any other process.
+func Rotate(dir string, oldKey []byte, newKey []byte) error {
+	if len(oldKey) == 0 || len(newKey) == 0 {
+		return fmt.Errorf("%w: both the current and new key must be provided", ErrKeyNotFound)
+	}
+
+	opts := badger.KeyRegistryOptions{
+		Dir:                           dir,
+		ReadOnly:                      true,
+		EncryptionKey:                 oldKey,
+		EncryptionKeyRotationDuration: dataKeyRotationDuration,
+	}
+
+	registry, err := badger.OpenKeyRegistry(opts)
+	if err != nil {
+		return fmt.Errorf("%w: unable to open key registry with current key", err)
+	}
+
+	opts.EncryptionKey = newKey
+	if err := badger.WriteKeyRegistry(registry, opts); err != nil {
+		return fmt.Errorf("%w: unable to write key registry with new key", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,189 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package encryption
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/coinbase/rosetta-cli/configuration"
+
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/coinbase/rosetta-sdk-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testKeyEnv = "ROSETTA_CLI_TEST_ENCRYPTION_KEY"
+
+	oldKey = "000102030405060708090a0b0c0d0e0f"
+	newKey = "f0e0d0c0b0a090807060504030201000f0e0d0c0b0a090807060504030201000"
+)
+
+func TestLoadKey(t *testing.T) {
+	dir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(dir)
+
+	keyFile := path.Join(dir, "key.txt")
+	assert.NoError(t, ioutil.WriteFile(keyFile, []byte(oldKey+"\n"), os.FileMode(0600)))
+
+	assert.NoError(t, os.Setenv(testKeyEnv, newKey))
+	defer os.Unsetenv(testKeyEnv)
+
+	var tests = map[string]struct {
+		config *configuration.EncryptionConfiguration
+
+		expected string
+		err      error
+	}{
+		"disabled": {},
+		"key file": {
+			config:   &configuration.EncryptionConfiguration{KeyFile: keyFile},
+			expected: oldKey,
+		},
+		"key env": {
+			config:   &configuration.EncryptionConfiguration{KeyEnv: testKeyEnv},
+			expected: newKey,
+		},
+		"missing key env": {
+			config: &configuration.EncryptionConfiguration{KeyEnv: "ROSETTA_CLI_MISSING_KEY"},
+			err:    ErrKeyNotFound,
+		},
+		"no key": {
+			config: &configuration.EncryptionConfiguration{},
+			err:    ErrKeyNotFound,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			key, err := LoadKey(test.config)
+			if test.err != nil {
+				assert.True(t, errors.Is(err, test.err))
+				return
+			}
+
+			assert.NoError(t, err)
+			if len(test.expected) == 0 {
+				assert.Nil(t, key)
+				return
+			}
+
+			expected, err := ParseKey(test.expected)
+			assert.NoError(t, err)
+			assert.Equal(t, expected, key)
+		})
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	var tests = map[string]struct {
+		encoded string
+
+		size int
+		err  bool
+	}{
+		"AES-128": {
+			encoded: oldKey,
+			size:    16,
+		},
+		"AES-192": {
+			encoded: strings.Repeat("ab", 24),
+			size:    24,
+		},
+		"AES-256 with whitespace": {
+			encoded: " " + newKey + "\n",
+			size:    32,
+		},
+		"invalid hex": {
+			encoded: strings.Repeat("zz", 16),
+			err:     true,
+		},
+		"invalid size": {
+			encoded: strings.Repeat("ab", 20),
+			err:     true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			key, err := ParseKey(test.encoded)
+			if test.err {
+				assert.True(t, errors.Is(err, ErrInvalidKey))
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Len(t, key, test.size)
+		})
+	}
+}
+
+// open opens the database in dir encrypted with key.
+func open(ctx context.Context, dir string, key []byte) (storage.Database, error) {
+	return storage.NewBadgerStorage(
+		ctx,
+		dir,
+		storage.WithCustomSettings(BadgerOptions(dir, false, key)),
+	)
+}
+
+func TestRotate(t *testing.T) {
+	ctx := context.Background()
+	dir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(dir)
+
+	current, err := ParseKey(oldKey)
+	assert.NoError(t, err)
+	rotated, err := ParseKey(newKey)
+	assert.NoError(t, err)
+
+	db, err := open(ctx, dir, current)
+	assert.NoError(t, err)
+	dbTx := db.NewDatabaseTransaction(ctx, true)
+	assert.NoError(t, dbTx.Set(ctx, []byte("key"), []byte("private"), true))
+	assert.NoError(t, dbTx.Commit(ctx))
+	assert.NoError(t, db.Close(ctx))
+
+	// The database cannot be opened without its key.
+	_, err = open(ctx, dir, nil)
+	assert.Error(t, err)
+
+	// The current key must be provided.
+	assert.Error(t, Rotate(dir, rotated, rotated))
+	assert.NoError(t, Rotate(dir, current, rotated))
+
+	_, err = open(ctx, dir, current)
+	assert.Error(t, err)
+
+	db, err = open(ctx, dir, rotated)
+	assert.NoError(t, err)
+	defer db.Close(ctx)
+
+	dbTx = db.NewDatabaseTransaction(ctx, false)
+	defer dbTx.Discard(ctx)
+
+	exists, value, err := dbTx.Get(ctx, []byte("key"))
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, []byte("private"), value)
+}
@@ -0,0 +1,470 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package export
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"strconv"
+
+	"github.com/coinbase/rosetta-cli/pkg/archive"
+
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/coinbase/rosetta-sdk-go/utils"
+)
+
+const (
+	// CSVFormat exports each table to a CSV file.
+	CSVFormat = "csv"
+
+	// ParquetFormat exports each table to a Parquet file.
+	ParquetFormat = "parquet"
+
+	// BlocksTable is the name of the table (and file)
+	// containing one row per block.
+	BlocksTable = "blocks"
+
+	// TransactionsTable is the name of the table (and file)
+	// containing one row per transaction.
+	TransactionsTable = "transactions"
+
+	// OperationsTable is the name of the table (and file)
+	// containing one row per operation.
+	OperationsTable = "operations"
+
+	// logFrequency is the number of exported blocks
+	// between progress logs.
+	logFrequency = 10000
+)
+
+var (
+	// ErrUnsupportedFormat is returned when an export
+	// format is not CSVFormat or ParquetFormat.
+	ErrUnsupportedFormat = errors.New("unsupported export format")
+
+	// blockColumns are the columns of BlocksTable.
+	blockColumns = []string{
+		"index",
+		"hash",
+		"parent_index",
+		"parent_hash",
+		"timestamp",
+		"transaction_count",
+		"metadata",
+	}
+
+	// transactionColumns are the columns of TransactionsTable.
+	transactionColumns = []string{
+		"block_index",
+		"block_hash",
+		"transaction_hash",
+		"operation_count",
+		"metadata",
+	}
+
+	// operationColumns are the columns of OperationsTable.
+	operationColumns = []string{
+		"block_index",
+		"block_hash",
+		"transaction_hash",
+		"operation_index",
+		"network_index",
+		"related_operations",
+		"type",
+		"status",
+		"account_address",
+		"sub_account_address",
+		"amount_value",
+		"currency_symbol",
+		"currency_decimals",
+		"coin_identifier",
+		"coin_action",
+		"metadata",
+	}
+)
+
+// tableWriter writes rows to a single table.
+type tableWriter interface {
+	Write(row []string) error
+	Close() error
+}
+
+// csvWriter implements the tableWriter interface
+// for CSV files.
+type csvWriter struct {
+	file   *os.File
+	writer *csv.Writer
+}
+
+func newCSVWriter(filePath string, columns []string) (*csvWriter, error) {
+	file, err := os.Create(path.Clean(filePath))
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to create %s", err, filePath)
+	}
+
+	w := &csvWriter{file: file, writer: csv.NewWriter(file)}
+	if err := w.Write(columns); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+
+	return w, nil
+}
+
+func (w *csvWriter) Write(row []string) error {
+	if err := w.writer.Write(row); err != nil {
+		return fmt.Errorf("%w: unable to write row to %s", err, w.file.Name())
+	}
+
+	return nil
+}
+
+func (w *csvWriter) Close() error {
+	w.writer.Flush()
+	if err := w.writer.Error(); err != nil {
+		_ = w.file.Close()
+		return fmt.Errorf("%w: unable to flush %s", err, w.file.Name())
+	}
+
+	return w.file.Close()
+}
+
+// Exporter writes blocks to the blocks, transactions,
+// and operations tables.
+type Exporter struct {
+	blocks       tableWriter
+	transactions tableWriter
+	operations   tableWriter
+}
+
+// NewExporter returns a new *Exporter that writes
+// tables in format to outputDirectory.
+func NewExporter(outputDirectory string, format string) (*Exporter, error) {
+	var newWriter func(string, []string) (tableWriter, error)
+	switch format {
+	case CSVFormat:
+		newWriter = func(filePath string, columns []string) (tableWriter, error) {
+			return newCSVWriter(filePath, columns)
+		}
+	case ParquetFormat:
+		newWriter = func(filePath string, columns []string) (tableWriter, error) {
+			return newParquetWriter(filePath, columns)
+		}
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedFormat, format)
+	}
+
+	if err := utils.EnsurePathExists(outputDirectory); err != nil {
+		return nil, fmt.Errorf("%w: unable to create output directory", err)
+	}
+
+	e := &Exporter{}
+	tables := []struct {
+		name    string
+		columns []string
+		writer  *tableWriter
+	}{
+		{BlocksTable, blockColumns, &e.blocks},
+		{TransactionsTable, transactionColumns, &e.transactions},
+		{OperationsTable, operationColumns, &e.operations},
+	}
+
+	for _, table := range tables {
+		w, err := newWriter(
+			path.Join(outputDirectory, fmt.Sprintf("%s.%s", table.name, format)),
+			table.columns,
+		)
+		if err != nil {
+			_ = e.Close()
+			return nil, err
+		}
+
+		*table.writer = w
+	}
+
+	return e, nil
+}
+
+// Close flushes and closes all tables.
+func (e *Exporter) Close() error {
+	var closeErr error
+	for _, w := range []tableWriter{e.blocks, e.transactions, e.operations} {
+		if w == nil {
+			continue
+		}
+
+		if err := w.Close(); err != nil && closeErr == nil {
+			closeErr = err
+		}
+	}
+
+	return closeErr
+}
+
+// encodeMetadata returns metadata as a JSON string
+// (or an empty string if there is no metadata).
+func encodeMetadata(metadata interface{}) (string, error) {
+	switch m := metadata.(type) {
+	case map[string]interface{}:
+		if len(m) == 0 {
+			return "", nil
+		}
+	case []*types.OperationIdentifier:
+		if len(m) == 0 {
+			return "", nil
+		}
+	}
+
+	encoded, err := json.Marshal(metadata)
+	if err != nil {
+		return "", fmt.Errorf("%w: unable to encode metadata", err)
+	}
+
+	return string(encoded), nil
+}
+
+// operationRow returns the OperationsTable row of op.
+func operationRow(
+	block *types.BlockIdentifier,
+	tx *types.TransactionIdentifier,
+	op *types.Operation,
+) ([]string, error) {
+	var networkIndex string
+	if op.OperationIdentifier.NetworkIndex != nil {
+		networkIndex = strconv.FormatInt(*op.OperationIdentifier.NetworkIndex, 10)
+	}
+
+	related, err := encodeMetadata(op.RelatedOperations)
+	if err != nil {
+		return nil, err
+	}
+
+	var address, subAccount string
+	if op.Account != nil {
+		address = op.Account.Address
+		if op.Account.SubAccount != nil {
+			subAccount = op.Account.SubAccount.Address
+		}
+	}
+
+	var value, symbol, decimals string
+	if op.Amount != nil {
+		value = op.Amount.Value
+		symbol = op.Amount.Currency.Symbol
+		decimals = strconv.FormatInt(int64(op.Amount.Currency.Decimals), 10)
+	}
+
+	var status string
+	if op.Status != nil {
+		status = *op.Status
+	}
+
+	var coinIdentifier, coinAction string
+	if op.CoinChange != nil {
+		coinIdentifier = op.CoinChange.CoinIdentifier.Identifier
+		coinAction = string(op.CoinChange.CoinAction)
+	}
+
+	metadata, err := encodeMetadata(op.Metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	return []string{
+		strconv.FormatInt(block.Index, 10),
+		block.Hash,
+		tx.Hash,
+		strconv.FormatInt(op.OperationIdentifier.Index, 10),
+		networkIndex,
+		related,
+		op.Type,
+		status,
+		address,
+		subAccount,
+		value,
+		symbol,
+		decimals,
+		coinIdentifier,
+		coinAction,
+		metadata,
+	}, nil
+}
+
+// AddBlock writes block (and all of its transactions
+// and operations) to the tables.
+func (e *Exporter) AddBlock(block *types.Block) error {
+	metadata, err := encodeMetadata(block.Metadata)
+	if err != nil {
+		return err
+	}
+
+	if err := e.blocks.Write([]string{
+		strconv.FormatInt(block.BlockIdentifier.Index, 10),
+		block.BlockIdentifier.Hash,
+		strconv.FormatInt(block.ParentBlockIdentifier.Index, 10),
+		block.ParentBlockIdentifier.Hash,
+		strconv.FormatInt(block.Timestamp, 10),
+		strconv.Itoa(len(block.Transactions)),
+		metadata,
+	}); err != nil {
+		return err
+	}
+
+	for _, tx := range block.Transactions {
+		metadata, err := encodeMetadata(tx.Metadata)
+		if err != nil {
+			return err
+		}
+
+		if err := e.transactions.Write([]string{
+			strconv.FormatInt(block.BlockIdentifier.Index, 10),
+			block.BlockIdentifier.Hash,
+			tx.TransactionIdentifier.Hash,
+			strconv.Itoa(len(tx.Operations)),
+			metadata,
+		}); err != nil {
+			return err
+		}
+
+		for _, op := range tx.Operations {
+			row, err := operationRow(block.BlockIdentifier, tx.TransactionIdentifier, op)
+			if err != nil {
+				return err
+			}
+
+			if err := e.operations.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// getBlock returns the block with index from blockStorage
+// or, if it was pruned, from blockArchive (if not nil).
+// Blocks that are in neither return storage.ErrBlockNotFound.
+func getBlock(
+	ctx context.Context,
+	blockStorage *storage.BlockStorage,
+	blockArchive *archive.Archive,
+	index int64,
+) (*types.Block, error) {
+	block, err := blockStorage.GetBlock(ctx, &types.PartialBlockIdentifier{Index: &index})
+	if !errors.Is(err, storage.ErrBlockNotFound) &&
+		!errors.Is(err, storage.ErrCannotAccessPrunedData) {
+		return block, err
+	}
+
+	if blockArchive == nil {
+		return nil, storage.ErrBlockNotFound
+	}
+
+	block, err = blockArchive.Get(index)
+	if errors.Is(err, archive.ErrBlockNotFound) {
+		return nil, storage.ErrBlockNotFound
+	}
+
+	return block, err
+}
+
+// ExportBlocks writes all blocks in blockStorage (and
+// blockArchive, if not nil) with an index between startIndex
+// and endIndex (inclusive) to outputDirectory in format and
+// returns the number of blocks exported. If startIndex is -1,
+// blocks are exported from the oldest block in storage (or in
+// the archive). If endIndex is -1, blocks are exported until
+// the head block. Blocks that are not in storage or in the
+// archive (for example, because they were pruned without
+// being archived) are skipped.
+func ExportBlocks(
+	ctx context.Context,
+	blockStorage *storage.BlockStorage,
+	blockArchive *archive.Archive,
+	outputDirectory string,
+	format string,
+	startIndex int64,
+	endIndex int64,
+) (int64, error) {
+	head, err := blockStorage.GetHeadBlockIdentifier(ctx)
+	if err != nil {
+		return -1, fmt.Errorf("%w: unable to get head block", err)
+	}
+
+	if endIndex == -1 || endIndex > head.Index {
+		endIndex = head.Index
+	}
+
+	if startIndex == -1 {
+		oldestIndex, err := blockStorage.GetOldestBlockIndex(ctx)
+		switch {
+		case err == nil:
+			startIndex = oldestIndex
+		case errors.Is(err, storage.ErrOldestIndexMissing):
+			startIndex = 0
+		default:
+			return -1, fmt.Errorf("%w: unable to get oldest block index", err)
+		}
+
+		if blockArchive != nil && blockArchive.FirstIndex() != -1 {
+			startIndex = blockArchive.FirstIndex()
+		}
+	}
+
+	exporter, err := NewExporter(outputDirectory, format)
+	if err != nil {
+		return -1, err
+	}
+
+	exported := int64(0)
+	for index := startIndex; index <= endIndex; index++ {
+		if ctx.Err() != nil {
+			_ = exporter.Close()
+			return -1, ctx.Err()
+		}
+
+		block, err := getBlock(ctx, blockStorage, blockArchive, index)
+		if errors.Is(err, storage.ErrBlockNotFound) {
+			continue
+		}
+		if err != nil {
+			_ = exporter.Close()
+			return -1, fmt.Errorf("%w: unable to get block %d", err, index)
+		}
+
+		if err := exporter.AddBlock(block); err != nil {
+			_ = exporter.Close()
+			return -1, fmt.Errorf("%w: unable to export block %d", err, index)
+		}
+
+		exported++
+		if exported%logFrequency == 0 {
+			log.Printf("exported %d blocks (current index: %d)\n", exported, index)
+		}
+	}
+
+	if err := exporter.Close(); err != nil {
+		return -1, err
+	}
+
+	return exported, nil
+}
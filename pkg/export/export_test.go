@@ -0,0 +1,230 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package export
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/coinbase/rosetta-cli/pkg/archive"
+
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/coinbase/rosetta-sdk-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func readTable(t *testing.T, dir string, table string) [][]string {
+	f, err := os.Open(path.Join(dir, table+".csv"))
+	assert.NoError(t, err)
+	defer f.Close()
+
+	rows, err := csv.NewReader(f).ReadAll()
+	assert.NoError(t, err)
+
+	return rows
+}
+
+func TestExportBlocks(t *testing.T) {
+	ctx := context.Background()
+
+	dbDir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(dbDir)
+
+	outputDir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(outputDir)
+
+	database, err := storage.NewBadgerStorage(ctx, dbDir)
+	assert.NoError(t, err)
+	defer database.Close(ctx)
+
+	blockStorage := storage.NewBlockStorage(database)
+	blockStorage.Initialize([]storage.BlockWorker{})
+
+	status := "SUCCESS"
+	networkIndex := int64(3)
+	blocks := []*types.Block{
+		{
+			BlockIdentifier:       &types.BlockIdentifier{Hash: "block 0", Index: 0},
+			ParentBlockIdentifier: &types.BlockIdentifier{Hash: "block 0", Index: 0},
+			Timestamp:             1000,
+		},
+		{
+			BlockIdentifier:       &types.BlockIdentifier{Hash: "block 1", Index: 1},
+			ParentBlockIdentifier: &types.BlockIdentifier{Hash: "block 0", Index: 0},
+			Timestamp:             2000,
+			Transactions: []*types.Transaction{
+				{
+					TransactionIdentifier: &types.TransactionIdentifier{Hash: "tx 1"},
+					Operations: []*types.Operation{
+						{
+							OperationIdentifier: &types.OperationIdentifier{
+								Index:        0,
+								NetworkIndex: &networkIndex,
+							},
+							Type:   "TRANSFER",
+							Status: &status,
+							Account: &types.AccountIdentifier{
+								Address:    "addr 1",
+								SubAccount: &types.SubAccountIdentifier{Address: "sub"},
+							},
+							Amount: &types.Amount{
+								Value:    "-100",
+								Currency: &types.Currency{Symbol: "BTC", Decimals: 8},
+							},
+							Metadata: map[string]interface{}{"memo": "hello"},
+						},
+						{
+							OperationIdentifier: &types.OperationIdentifier{Index: 1},
+							RelatedOperations: []*types.OperationIdentifier{
+								{Index: 0},
+							},
+							Type:   "TRANSFER",
+							Status: &status,
+							Account: &types.AccountIdentifier{
+								Address: "addr 2",
+							},
+							Amount: &types.Amount{
+								Value:    "100",
+								Currency: &types.Currency{Symbol: "BTC", Decimals: 8},
+							},
+						},
+					},
+				},
+			},
+			Metadata: map[string]interface{}{"size": float64(10)},
+		},
+	}
+
+	for _, block := range blocks {
+		assert.NoError(t, blockStorage.AddBlock(ctx, block))
+	}
+
+	t.Run("unsupported format", func(t *testing.T) {
+		_, err := ExportBlocks(ctx, blockStorage, nil, outputDir, "json", -1, -1)
+		assert.True(t, errors.Is(err, ErrUnsupportedFormat))
+	})
+
+	t.Run("parquet", func(t *testing.T) {
+		exported, err := ExportBlocks(ctx, blockStorage, nil, outputDir, ParquetFormat, -1, -1)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), exported)
+
+		columns, _, rows := readParquet(t, path.Join(outputDir, BlocksTable+".parquet"))
+		assert.Equal(t, blockColumns, columns)
+		assert.Equal(t, [][]interface{}{
+			{int64(0), "block 0", int64(0), "block 0", int64(1000), int64(0), nil},
+			{int64(1), "block 1", int64(0), "block 0", int64(2000), int64(1), `{"size":10}`},
+		}, rows)
+
+		columns, _, rows = readParquet(t, path.Join(outputDir, TransactionsTable+".parquet"))
+		assert.Equal(t, transactionColumns, columns)
+		assert.Equal(t, [][]interface{}{
+			{int64(1), "block 1", "tx 1", int64(2), nil},
+		}, rows)
+
+		columns, _, rows = readParquet(t, path.Join(outputDir, OperationsTable+".parquet"))
+		assert.Equal(t, operationColumns, columns)
+		assert.Equal(t, [][]interface{}{
+			{
+				int64(1), "block 1", "tx 1", int64(0), int64(3), nil, "TRANSFER", "SUCCESS",
+				"addr 1", "sub", "-100", "BTC", int64(8), nil, nil, `{"memo":"hello"}`,
+			},
+			{
+				int64(1), "block 1", "tx 1", int64(1), nil, `[{"index":0}]`, "TRANSFER", "SUCCESS",
+				"addr 2", nil, "100", "BTC", int64(8), nil, nil, nil,
+			},
+		}, rows)
+	})
+
+	t.Run("all blocks", func(t *testing.T) {
+		exported, err := ExportBlocks(ctx, blockStorage, nil, outputDir, CSVFormat, -1, -1)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), exported)
+
+		assert.Equal(t, [][]string{
+			blockColumns,
+			{"0", "block 0", "0", "block 0", "1000", "0", ""},
+			{"1", "block 1", "0", "block 0", "2000", "1", `{"size":10}`},
+		}, readTable(t, outputDir, BlocksTable))
+
+		assert.Equal(t, [][]string{
+			transactionColumns,
+			{"1", "block 1", "tx 1", "2", ""},
+		}, readTable(t, outputDir, TransactionsTable))
+
+		assert.Equal(t, [][]string{
+			operationColumns,
+			{
+				"1", "block 1", "tx 1", "0", "3", "", "TRANSFER", "SUCCESS",
+				"addr 1", "sub", "-100", "BTC", "8", "", "", `{"memo":"hello"}`,
+			},
+			{
+				"1", "block 1", "tx 1", "1", "", `[{"index":0}]`, "TRANSFER", "SUCCESS",
+				"addr 2", "", "100", "BTC", "8", "", "", "",
+			},
+		}, readTable(t, outputDir, OperationsTable))
+	})
+
+	t.Run("range", func(t *testing.T) {
+		exported, err := ExportBlocks(ctx, blockStorage, nil, outputDir, CSVFormat, 1, 10)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), exported)
+		assert.Len(t, readTable(t, outputDir, BlocksTable), 2)
+	})
+
+	t.Run("archived blocks", func(t *testing.T) {
+		archiveDir, err := utils.CreateTempDir()
+		assert.NoError(t, err)
+		defer utils.RemoveTempDir(archiveDir)
+
+		blockArchive, err := archive.Open(archiveDir, 1<<20)
+		assert.NoError(t, err)
+		defer blockArchive.Close()
+
+		assert.NoError(t, blockArchive.Append(blocks[0]))
+		firstPruned, lastPruned, err := blockStorage.Prune(ctx, 0, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(0), firstPruned)
+		assert.Equal(t, int64(0), lastPruned)
+
+		exported, err := ExportBlocks(ctx, blockStorage, nil, outputDir, CSVFormat, -1, -1)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), exported)
+
+		exported, err = ExportBlocks(
+			ctx,
+			blockStorage,
+			blockArchive,
+			outputDir,
+			CSVFormat,
+			-1,
+			-1,
+		)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), exported)
+		assert.Equal(t, [][]string{
+			blockColumns,
+			{"0", "block 0", "0", "block 0", "1000", "0", ""},
+			{"1", "block 1", "0", "block 0", "2000", "1", `{"size":10}`},
+		}, readTable(t, outputDir, BlocksTable))
+	})
+}
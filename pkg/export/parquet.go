@@ -0,0 +1,461 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+)
+
+const (
+	// parquetMagic starts and ends every Parquet file.
+	parquetMagic = "PAR1"
+
+	// parquetRowGroupSize is the number of rows
+	// buffered in memory before they are written
+	// as a row group.
+	parquetRowGroupSize = 50000
+
+	// parquetCreatedBy is the name of the application
+	// that wrote the file (stored in its metadata).
+	parquetCreatedBy = "rosetta-cli"
+
+	// Physical types.
+	parquetInt64     = 2
+	parquetByteArray = 6
+
+	// Converted types.
+	parquetUTF8 = 0
+
+	// Repetition types.
+	parquetOptional = 1
+
+	// Encodings.
+	parquetPlain = 0
+	parquetRLE   = 3
+
+	// Page types.
+	parquetDataPage = 0
+
+	// Compression codecs.
+	parquetUncompressed = 0
+
+	// Thrift compact protocol types.
+	compactI32    = 5
+	compactI64    = 6
+	compactBinary = 8
+	compactList   = 9
+	compactStruct = 12
+)
+
+// integerColumns are the columns (of any table)
+// exported as INT64 columns. All other columns
+// are exported as UTF8 columns.
+var integerColumns = map[string]bool{
+	"index":             true,
+	"parent_index":      true,
+	"timestamp":         true,
+	"transaction_count": true,
+	"block_index":       true,
+	"operation_count":   true,
+	"operation_index":   true,
+	"network_index":     true,
+	"currency_decimals": true,
+}
+
+// compactWriter encodes Thrift structs
+// with the Thrift compact protocol.
+type compactWriter struct {
+	buf bytes.Buffer
+
+	// fields is the last field ID written
+	// in each struct that is being written.
+	fields []int16
+}
+
+func (w *compactWriter) varint(v uint64) {
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(b[:], v)
+	w.buf.Write(b[:n])
+}
+
+func (w *compactWriter) zigzag(v int64) {
+	w.varint(uint64((v << 1) ^ (v >> 63)))
+}
+
+// field writes the header of the field with id.
+func (w *compactWriter) field(id int16, fieldType byte) {
+	last := &w.fields[len(w.fields)-1]
+	if delta := id - *last; delta > 0 && delta <= 15 {
+		w.buf.WriteByte(byte(delta)<<4 | fieldType)
+	} else {
+		w.buf.WriteByte(fieldType)
+		w.zigzag(int64(id))
+	}
+
+	*last = id
+}
+
+// begin starts a struct (which must be
+// the value of a field or list item).
+func (w *compactWriter) begin() {
+	w.fields = append(w.fields, 0)
+}
+
+// end ends the current struct.
+func (w *compactWriter) end() {
+	w.buf.WriteByte(0)
+	w.fields = w.fields[:len(w.fields)-1]
+}
+
+func (w *compactWriter) i32Field(id int16, v int32) {
+	w.field(id, compactI32)
+	w.zigzag(int64(v))
+}
+
+func (w *compactWriter) i64Field(id int16, v int64) {
+	w.field(id, compactI64)
+	w.zigzag(v)
+}
+
+func (w *compactWriter) binary(s string) {
+	w.varint(uint64(len(s)))
+	w.buf.WriteString(s)
+}
+
+func (w *compactWriter) stringField(id int16, s string) {
+	w.field(id, compactBinary)
+	w.binary(s)
+}
+
+// listField writes the header of a list field
+// with size items of elementType (which must be
+// written by the caller).
+func (w *compactWriter) listField(id int16, elementType byte, size int) {
+	w.field(id, compactList)
+	if size < 15 {
+		w.buf.WriteByte(byte(size)<<4 | elementType)
+		return
+	}
+
+	w.buf.WriteByte(0xf0 | elementType)
+	w.varint(uint64(size))
+}
+
+// structField starts a struct field (which
+// must be ended by the caller).
+func (w *compactWriter) structField(id int16) {
+	w.field(id, compactStruct)
+	w.begin()
+}
+
+// parquetChunk is a column chunk
+// written to a Parquet file.
+type parquetChunk struct {
+	offset int64
+	size   int64
+}
+
+// parquetRowGroup is a row group
+// written to a Parquet file.
+type parquetRowGroup struct {
+	chunks []*parquetChunk
+	rows   int64
+	size   int64
+}
+
+// parquetWriter implements the tableWriter interface for
+// Parquet files. It writes the subset of the Parquet format
+// (https://github.com/apache/parquet-format) needed to export
+// flat tables: each column is an optional INT64 or UTF8
+// BYTE_ARRAY column (empty values are null), each row group
+// has a single uncompressed data page per column (with PLAIN
+// encoded values and RLE encoded definition levels), and all
+// metadata is encoded with the Thrift compact protocol.
+type parquetWriter struct {
+	file    *os.File
+	writer  *bufio.Writer
+	columns []string
+
+	rows      [][]string
+	offset    int64
+	rowGroups []*parquetRowGroup
+}
+
+func newParquetWriter(filePath string, columns []string) (*parquetWriter, error) {
+	file, err := os.Create(path.Clean(filePath))
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to create %s", err, filePath)
+	}
+
+	w := &parquetWriter{
+		file:    file,
+		writer:  bufio.NewWriter(file),
+		columns: columns,
+	}
+	if err := w.write([]byte(parquetMagic)); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// write writes b to the file.
+func (w *parquetWriter) write(b []byte) error {
+	n, err := w.writer.Write(b)
+	w.offset += int64(n)
+	if err != nil {
+		return fmt.Errorf("%w: unable to write to %s", err, w.file.Name())
+	}
+
+	return nil
+}
+
+func (w *parquetWriter) Write(row []string) error {
+	if len(row) != len(w.columns) {
+		return fmt.Errorf(
+			"row has %d values but %s has %d columns",
+			len(row),
+			w.file.Name(),
+			len(w.columns),
+		)
+	}
+
+	w.rows = append(w.rows, row)
+	if len(w.rows) < parquetRowGroupSize {
+		return nil
+	}
+
+	return w.writeRowGroup()
+}
+
+// encodeColumn returns the data page of
+// column in all buffered rows.
+func (w *parquetWriter) encodeColumn(column int) ([]byte, error) {
+	var page, values bytes.Buffer
+	levelWriter := &compactWriter{}
+	writeRun := func(defined bool, count uint64) {
+		// Runs are RLE runs (with a bit width of 1).
+		levelWriter.varint(count << 1)
+		if defined {
+			levelWriter.buf.WriteByte(1)
+		} else {
+			levelWriter.buf.WriteByte(0)
+		}
+	}
+
+	var run uint64
+	var runDefined bool
+	for i, row := range w.rows {
+		value := row[column]
+		defined := len(value) > 0
+		if i > 0 && defined != runDefined {
+			writeRun(runDefined, run)
+			run = 0
+		}
+		runDefined = defined
+		run++
+
+		if !defined {
+			continue
+		}
+
+		if !integerColumns[w.columns[column]] {
+			var length [4]byte
+			binary.LittleEndian.PutUint32(length[:], uint32(len(value)))
+			values.Write(length[:])
+			values.WriteString(value)
+			continue
+		}
+
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"%w: %s is not an integer (column %s)",
+				err,
+				value,
+				w.columns[column],
+			)
+		}
+
+		var encoded [8]byte
+		binary.LittleEndian.PutUint64(encoded[:], uint64(parsed))
+		values.Write(encoded[:])
+	}
+	if run > 0 {
+		writeRun(runDefined, run)
+	}
+
+	var length [4]byte
+	binary.LittleEndian.PutUint32(length[:], uint32(levelWriter.buf.Len()))
+	page.Write(length[:])
+	page.Write(levelWriter.buf.Bytes())
+	page.Write(values.Bytes())
+
+	return page.Bytes(), nil
+}
+
+// pageHeader returns the header of a data
+// page of size bytes with values values.
+func pageHeader(size int, values int) []byte {
+	w := &compactWriter{}
+	w.begin()
+	w.i32Field(1, parquetDataPage)
+	w.i32Field(2, int32(size))
+	w.i32Field(3, int32(size))
+	w.structField(5)
+	w.i32Field(1, int32(values))
+	w.i32Field(2, parquetPlain)
+	w.i32Field(3, parquetRLE)
+	w.i32Field(4, parquetRLE)
+	w.end()
+	w.end()
+
+	return w.buf.Bytes()
+}
+
+// writeRowGroup writes all buffered rows
+// as a row group.
+func (w *parquetWriter) writeRowGroup() error {
+	if len(w.rows) == 0 {
+		return nil
+	}
+
+	rowGroup := &parquetRowGroup{rows: int64(len(w.rows))}
+	for column := range w.columns {
+		page, err := w.encodeColumn(column)
+		if err != nil {
+			return fmt.Errorf("%w: unable to encode row group of %s", err, w.file.Name())
+		}
+
+		chunk := &parquetChunk{offset: w.offset}
+		if err := w.write(pageHeader(len(page), len(w.rows))); err != nil {
+			return err
+		}
+
+		if err := w.write(page); err != nil {
+			return err
+		}
+
+		chunk.size = w.offset - chunk.offset
+		rowGroup.size += chunk.size
+		rowGroup.chunks = append(rowGroup.chunks, chunk)
+	}
+
+	w.rowGroups = append(w.rowGroups, rowGroup)
+	w.rows = nil
+	return nil
+}
+
+// fileMetadata returns the footer of the file.
+func (w *parquetWriter) fileMetadata() []byte {
+	numRows := int64(0)
+	for _, rowGroup := range w.rowGroups {
+		numRows += rowGroup.rows
+	}
+
+	m := &compactWriter{}
+	m.begin()
+	m.i32Field(1, 1)
+
+	m.listField(2, compactStruct, len(w.columns)+1)
+	m.begin()
+	m.stringField(4, "schema")
+	m.i32Field(5, int32(len(w.columns)))
+	m.end()
+	for _, column := range w.columns {
+		m.begin()
+		if integerColumns[column] {
+			m.i32Field(1, parquetInt64)
+			m.i32Field(3, parquetOptional)
+			m.stringField(4, column)
+		} else {
+			m.i32Field(1, parquetByteArray)
+			m.i32Field(3, parquetOptional)
+			m.stringField(4, column)
+			m.i32Field(6, parquetUTF8)
+		}
+		m.end()
+	}
+
+	m.i64Field(3, numRows)
+
+	m.listField(4, compactStruct, len(w.rowGroups))
+	for _, rowGroup := range w.rowGroups {
+		m.begin()
+		m.listField(1, compactStruct, len(rowGroup.chunks))
+		for i, chunk := range rowGroup.chunks {
+			columnType := int32(parquetByteArray)
+			if integerColumns[w.columns[i]] {
+				columnType = parquetInt64
+			}
+
+			m.begin()
+			m.i64Field(2, chunk.offset)
+			m.structField(3)
+			m.i32Field(1, columnType)
+			m.listField(2, compactI32, 2)
+			m.zigzag(parquetPlain)
+			m.zigzag(parquetRLE)
+			m.listField(3, compactBinary, 1)
+			m.binary(w.columns[i])
+			m.i32Field(4, parquetUncompressed)
+			m.i64Field(5, rowGroup.rows)
+			m.i64Field(6, chunk.size)
+			m.i64Field(7, chunk.size)
+			m.i64Field(9, chunk.offset)
+			m.end()
+			m.end()
+		}
+		m.i64Field(2, rowGroup.size)
+		m.i64Field(3, rowGroup.rows)
+		m.end()
+	}
+
+	m.stringField(6, parquetCreatedBy)
+	m.end()
+
+	return m.buf.Bytes()
+}
+
+func (w *parquetWriter) Close() error {
+	if err := w.writeRowGroup(); err != nil {
+		_ = w.file.Close()
+		return err
+	}
+
+	metadata := w.fileMetadata()
+	var length [4]byte
+	binary.LittleEndian.PutUint32(length[:], uint32(len(metadata)))
+	for _, b := range [][]byte{metadata, length[:], []byte(parquetMagic)} {
+		if err := w.write(b); err != nil {
+			_ = w.file.Close()
+			return err
+		}
+	}
+
+	if err := w.writer.Flush(); err != nil {
+		_ = w.file.Close()
+		return fmt.Errorf("%w: unable to flush %s", err, w.file.Name())
+	}
+
+	return w.file.Close()
+}
@@ -0,0 +1,291 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package export
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strconv"
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+// compactReader decodes Thrift structs encoded with the
+// Thrift compact protocol into maps of field ID to value.
+type compactReader struct {
+	r *bytes.Reader
+}
+
+func (r *compactReader) varint() uint64 {
+	v, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		panic(err)
+	}
+
+	return v
+}
+
+func (r *compactReader) zigzag() int64 {
+	v := r.varint()
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (r *compactReader) byte() byte {
+	b, err := r.r.ReadByte()
+	if err != nil {
+		panic(err)
+	}
+
+	return b
+}
+
+func (r *compactReader) value(valueType byte) interface{} {
+	switch valueType {
+	case 1:
+		return true
+	case 2:
+		return false
+	case 3:
+		return int64(int8(r.byte()))
+	case compactI32, compactI64, 4:
+		return r.zigzag()
+	case compactBinary:
+		b := make([]byte, r.varint())
+		if _, err := r.r.Read(b); err != nil && len(b) > 0 {
+			panic(err)
+		}
+
+		return string(b)
+	case compactList:
+		header := r.byte()
+		size := uint64(header >> 4)
+		if size == 15 {
+			size = r.varint()
+		}
+
+		items := make([]interface{}, size)
+		for i := range items {
+			items[i] = r.value(header & 0x0f)
+		}
+
+		return items
+	case compactStruct:
+		return r.structValue()
+	default:
+		panic(fmt.Sprintf("unsupported type %d", valueType))
+	}
+}
+
+func (r *compactReader) structValue() map[int16]interface{} {
+	fields := map[int16]interface{}{}
+	last := int16(0)
+	for {
+		header := r.byte()
+		if header == 0 {
+			return fields
+		}
+
+		id := last + int16(header>>4)
+		if header>>4 == 0 {
+			id = int16(r.zigzag())
+		}
+
+		fields[id] = r.value(header & 0x0f)
+		last = id
+	}
+}
+
+// readParquet returns the columns (and the type of each
+// column) and rows of the Parquet file at filePath. Null
+// values are nil and INT64 values are int64.
+func readParquet(t *testing.T, filePath string) ([]string, []int64, [][]interface{}) {
+	contents, err := ioutil.ReadFile(filePath)
+	assert.NoError(t, err)
+	assert.Equal(t, parquetMagic, string(contents[:4]))
+	assert.Equal(t, parquetMagic, string(contents[len(contents)-4:]))
+
+	length := binary.LittleEndian.Uint32(contents[len(contents)-8:])
+	footer := contents[len(contents)-8-int(length) : len(contents)-8]
+	metadata := (&compactReader{r: bytes.NewReader(footer)}).structValue()
+	assert.Equal(t, int64(1), metadata[1])
+	assert.Equal(t, parquetCreatedBy, metadata[6])
+
+	schema := metadata[2].([]interface{})
+	root := schema[0].(map[int16]interface{})
+	assert.Equal(t, int64(len(schema)-1), root[5])
+
+	columns := []string{}
+	columnTypes := []int64{}
+	for _, item := range schema[1:] {
+		element := item.(map[int16]interface{})
+		assert.Equal(t, int64(parquetOptional), element[3])
+		columns = append(columns, element[4].(string))
+		columnTypes = append(columnTypes, element[1].(int64))
+
+		if element[1] == int64(parquetByteArray) {
+			assert.Equal(t, int64(parquetUTF8), element[6])
+		}
+	}
+
+	rows := [][]interface{}{}
+	for _, item := range metadata[4].([]interface{}) {
+		rowGroup := item.(map[int16]interface{})
+		numRows := rowGroup[3].(int64)
+		groupRows := make([][]interface{}, numRows)
+		for i := range groupRows {
+			groupRows[i] = make([]interface{}, len(columns))
+		}
+
+		for column, chunkItem := range rowGroup[1].([]interface{}) {
+			chunk := chunkItem.(map[int16]interface{})[3].(map[int16]interface{})
+			assert.Equal(t, columnTypes[column], chunk[1])
+			assert.Equal(t, []interface{}{columns[column]}, chunk[3])
+			assert.Equal(t, int64(parquetUncompressed), chunk[4])
+			assert.Equal(t, numRows, chunk[5])
+
+			offset := chunk[9].(int64)
+			size := chunk[6].(int64)
+			reader := bytes.NewReader(contents[offset : offset+size])
+			header := (&compactReader{r: reader}).structValue()
+			assert.Equal(t, int64(parquetDataPage), header[1])
+			pageSize := header[2].(int64)
+			assert.Equal(t, int64(reader.Len()), pageSize)
+
+			dataPage := header[5].(map[int16]interface{})
+			assert.Equal(t, numRows, dataPage[1])
+			assert.Equal(t, int64(parquetPlain), dataPage[2])
+			assert.Equal(t, int64(parquetRLE), dataPage[3])
+
+			// Definition levels are RLE runs
+			// (with a bit width of 1).
+			var levelsLength uint32
+			assert.NoError(t, binary.Read(reader, binary.LittleEndian, &levelsLength))
+			levelBytes := make([]byte, levelsLength)
+			_, err := reader.Read(levelBytes)
+			assert.NoError(t, err)
+			levels := &compactReader{r: bytes.NewReader(levelBytes)}
+
+			defined := []bool{}
+			for levels.r.Len() > 0 {
+				runHeader := levels.varint()
+				assert.Equal(t, uint64(0), runHeader&1)
+				value := levels.byte()
+				for i := uint64(0); i < runHeader>>1; i++ {
+					defined = append(defined, value == 1)
+				}
+			}
+			assert.Len(t, defined, int(numRows))
+
+			for i, isDefined := range defined {
+				if !isDefined {
+					continue
+				}
+
+				if columnTypes[column] == parquetInt64 {
+					var value int64
+					assert.NoError(t, binary.Read(reader, binary.LittleEndian, &value))
+					groupRows[i][column] = value
+					continue
+				}
+
+				var valueLength uint32
+				assert.NoError(t, binary.Read(reader, binary.LittleEndian, &valueLength))
+				value := make([]byte, valueLength)
+				_, err := reader.Read(value)
+				assert.NoError(t, err)
+				groupRows[i][column] = string(value)
+			}
+			assert.Equal(t, 0, reader.Len())
+		}
+
+		rows = append(rows, groupRows...)
+	}
+
+	assert.Equal(t, int64(len(rows)), metadata[3])
+	return columns, columnTypes, rows
+}
+
+func TestParquetWriter(t *testing.T) {
+	var tests = map[string]struct {
+		rows int
+	}{
+		"no rows": {},
+		"single row group": {
+			rows: 3,
+		},
+		"many row groups": {
+			rows: 2*parquetRowGroupSize + 1,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			dir, err := utils.CreateTempDir()
+			assert.NoError(t, err)
+			defer utils.RemoveTempDir(dir)
+
+			filePath := path.Join(dir, "table.parquet")
+			columns := []string{"index", "hash", "network_index", "metadata"}
+			w, err := newParquetWriter(filePath, columns)
+			assert.NoError(t, err)
+
+			expected := [][]interface{}{}
+			for i := 0; i < test.rows; i++ {
+				row := []string{strconv.Itoa(i), fmt.Sprintf("block %d", i), "", ""}
+				expectedRow := []interface{}{int64(i), fmt.Sprintf("block %d", i), nil, nil}
+				if i%2 == 0 {
+					row[2] = strconv.Itoa(-i)
+					expectedRow[2] = int64(-i)
+				}
+				if i%3 == 0 {
+					row[3] = `{"size":10}`
+					expectedRow[3] = `{"size":10}`
+				}
+
+				assert.NoError(t, w.Write(row))
+				expected = append(expected, expectedRow)
+			}
+
+			assert.Error(t, w.Write([]string{"1"}))
+			assert.NoError(t, w.Close())
+
+			readColumns, columnTypes, rows := readParquet(t, filePath)
+			assert.Equal(t, columns, readColumns)
+			assert.Equal(t, []int64{
+				parquetInt64,
+				parquetByteArray,
+				parquetInt64,
+				parquetByteArray,
+			}, columnTypes)
+			assert.Equal(t, expected, rows)
+		})
+	}
+}
+
+func TestParquetWriterInvalidInteger(t *testing.T) {
+	dir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(dir)
+
+	w, err := newParquetWriter(path.Join(dir, "table.parquet"), []string{"index"})
+	assert.NoError(t, err)
+	assert.NoError(t, w.Write([]string{"one"}))
+	assert.Error(t, w.Close())
+}
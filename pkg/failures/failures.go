@@ -0,0 +1,338 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package failures
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/olekukonko/tablewriter"
+)
+
+const (
+	// SchemaVersion is the version of the Failure schema. It
+	// is incremented whenever a field is removed or its meaning
+	// changes (adding fields does not change the version).
+	SchemaVersion = 1
+
+	// failureNamespace is prepended to all
+	// stored failures.
+	failureNamespace = "failure"
+)
+
+// Kind is the type of a Failure.
+type Kind string
+
+const (
+	// ReconciliationFailure is recorded when a computed
+	// balance does not match the live balance. Expected
+	// is the live balance and Actual is the computed balance.
+	ReconciliationFailure Kind = "reconciliation"
+
+	// AccountNotCreatedFailure is recorded when an operation
+	// references an account before it is created.
+	AccountNotCreatedFailure Kind = "account_not_created"
+
+	// InvariantFailure is recorded when a block, transaction,
+	// or operation violates a configured invariant. The
+	// invariant and assertion are included in the Context.
+	InvariantFailure Kind = "invariant_violation"
+
+	// BlockFetchMismatchFailure is recorded when a block fetched
+	// by hash does not match the block fetched by index. Expected
+	// and Actual are the hashes of the blocks fetched by index
+	// and by hash, respectively.
+	BlockFetchMismatchFailure Kind = "block_fetch_mismatch"
+
+	// TransactionFetchMismatchFailure is recorded when a transaction
+	// fetched with /block/transaction does not match the transaction
+	// in the /block response. Expected and Actual are the hashes of
+	// the transactions in the block and fetched individually,
+	// respectively.
+	TransactionFetchMismatchFailure Kind = "transaction_fetch_mismatch"
+
+	// DeepReorgFailure is recorded when the syncer processes
+	// a reorg deeper than the configured max depth. Expected is
+	// the max depth and Actual is the depth of the reorg.
+	DeepReorgFailure Kind = "deep_reorg"
+
+	// OrphanRateFailure is recorded when the rate of orphaned
+	// blocks exceeds the configured max orphan rate. Expected is
+	// the max orphan rate and Actual is the observed rate.
+	OrphanRateFailure Kind = "orphan_rate"
+
+	// QuorumDissentFailure is recorded when an endpoint returns
+	// a different block than the majority of endpoints. Expected
+	// and Actual are the hashes of the majority block and the
+	// dissenting block, respectively. The endpoint is included
+	// in the Context.
+	QuorumDissentFailure Kind = "quorum_dissent"
+
+	// FeeMismatchFailure is recorded when the fee operations
+	// in a transaction are inconsistent. If the total debited
+	// by fee operations does not match the fee in the transaction
+	// metadata, Expected is the metadata fee and Actual is the
+	// total debited.
+	FeeMismatchFailure Kind = "fee_mismatch"
+
+	// DuplicateHashFailure is recorded when a transaction hash
+	// appears in more than one canonical block or a block hash
+	// is used at more than one index. Expected is the index of
+	// the first block containing the hash.
+	DuplicateHashFailure Kind = "duplicate_hash"
+
+	// TimestampFailure is recorded when a block timestamp is
+	// before the timestamp of its parent or outside of a plausible
+	// window of wall-clock time. Expected is the bound that was
+	// violated and Actual is the block timestamp (both in
+	// milliseconds).
+	TimestampFailure Kind = "timestamp"
+
+	// SupplyMismatchFailure is recorded when the tracked supply
+	// of a currency differs from the expected supply at a
+	// checkpoint (by more than the tolerance). Expected is the
+	// expected supply and Actual is the tracked supply.
+	SupplyMismatchFailure Kind = "supply_mismatch"
+
+	// PluginFailure is recorded when a block worker
+	// plugin fails to process a block.
+	PluginFailure Kind = "plugin"
+
+	// DiskSpaceFailure is recorded when the free space on
+	// the volume of the data directory drops below the low
+	// threshold. Actual is the free space (in bytes).
+	DiskSpaceFailure Kind = "disk_space"
+
+	// CheckFailure is recorded when a check exits
+	// with an error.
+	CheckFailure Kind = "check_error"
+)
+
+// Failure is a structured record of something that went
+// wrong during a check. Only the fields relevant to the
+// Kind are populated.
+type Failure struct {
+	Version     int                          `json:"version"`
+	Kind        Kind                         `json:"kind"`
+	Timestamp   int64                        `json:"timestamp"`
+	Block       *types.BlockIdentifier       `json:"block_identifier,omitempty"`
+	Transaction *types.TransactionIdentifier `json:"transaction_identifier,omitempty"`
+	Operation   *types.OperationIdentifier   `json:"operation_identifier,omitempty"`
+	Account     *types.AccountIdentifier     `json:"account_identifier,omitempty"`
+	Currency    *types.Currency              `json:"currency,omitempty"`
+	Expected    string                       `json:"expected,omitempty"`
+	Actual      string                       `json:"actual,omitempty"`
+	Context     map[string]string            `json:"context,omitempty"`
+	Message     string                       `json:"message"`
+}
+
+// Storage persists failures in a storage.Database.
+//
+// Failures are persisted as soon as they are recorded:
+// Record writes a failure in its own write transaction and
+// RecordInTransaction writes a failure in the transaction
+// of the block being processed (only one write transaction
+// can be open at a time, so a storage.BlockWorker cannot
+// open another). Failures that cause the block transaction
+// to be discarded (because the block worker returns an
+// error) must be recorded with Defer and are only persisted
+// when Flush (or Record) is called.
+type Storage struct {
+	db storage.Database
+
+	mutex   sync.Mutex
+	pending []*Failure
+	lastKey int64
+}
+
+// NewStorage returns a new *Storage.
+func NewStorage(db storage.Database) *Storage {
+	return &Storage{db: db}
+}
+
+func getFailureKey(key int64) []byte {
+	return []byte(fmt.Sprintf("%s/%020d", failureNamespace, key))
+}
+
+func getFailurePrefix() []byte {
+	return []byte(fmt.Sprintf("%s/", failureNamespace))
+}
+
+// populate sets the Version and Timestamp of failure.
+func populate(failure *Failure) {
+	failure.Version = SchemaVersion
+	if failure.Timestamp == 0 {
+		failure.Timestamp = time.Now().Unix()
+	}
+}
+
+// Defer queues failure to be persisted on the next call
+// to Flush or Record. It should only be used for failures
+// that cause the block transaction to be discarded.
+func (s *Storage) Defer(failure *Failure) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	populate(failure)
+	s.pending = append(s.pending, failure)
+}
+
+// Record persists failure (and any
+// deferred failures).
+func (s *Storage) Record(ctx context.Context, failure *Failure) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	populate(failure)
+	s.pending = append(s.pending, failure)
+	return s.flush(ctx)
+}
+
+// RecordInTransaction persists failure in dbTx, so it
+// is only stored if dbTx is committed. It is used to
+// record failures that do not stop a block from being
+// committed while the block transaction is open.
+func (s *Storage) RecordInTransaction(
+	ctx context.Context,
+	dbTx storage.DatabaseTransaction,
+	failure *Failure,
+) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	populate(failure)
+	return s.store(ctx, dbTx, failure)
+}
+
+// Flush persists all deferred failures.
+func (s *Storage) Flush(ctx context.Context) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.flush(ctx)
+}
+
+// store writes failure to dbTx.
+func (s *Storage) store(
+	ctx context.Context,
+	dbTx storage.DatabaseTransaction,
+	failure *Failure,
+) error {
+	encoded, err := s.db.Encoder().Encode(failureNamespace, failure)
+	if err != nil {
+		return fmt.Errorf("%w: unable to encode failure", err)
+	}
+
+	// Keys are ordered by the time they are recorded
+	// (and are unique, even if they are recorded at
+	// the same time).
+	key := time.Now().UnixNano()
+	if key <= s.lastKey {
+		key = s.lastKey + 1
+	}
+	s.lastKey = key
+
+	if err := dbTx.Set(ctx, getFailureKey(key), encoded, true); err != nil {
+		return fmt.Errorf("%w: unable to store failure", err)
+	}
+
+	return nil
+}
+
+func (s *Storage) flush(ctx context.Context) error {
+	if len(s.pending) == 0 {
+		return nil
+	}
+
+	dbTx := s.db.NewDatabaseTransaction(ctx, true)
+	defer dbTx.Discard(ctx)
+
+	for _, failure := range s.pending {
+		if err := s.store(ctx, dbTx, failure); err != nil {
+			return err
+		}
+	}
+
+	if err := dbTx.Commit(ctx); err != nil {
+		return fmt.Errorf("%w: unable to commit failures", err)
+	}
+
+	s.pending = nil
+	return nil
+}
+
+// GetAll returns all persisted failures
+// in the order they were recorded.
+func (s *Storage) GetAll(ctx context.Context) ([]*Failure, error) {
+	dbTx := s.db.NewDatabaseTransaction(ctx, false)
+	defer dbTx.Discard(ctx)
+
+	failures := []*Failure{}
+	_, err := dbTx.Scan(
+		ctx,
+		getFailurePrefix(),
+		getFailurePrefix(),
+		func(k []byte, v []byte) error {
+			var failure Failure
+			if err := s.db.Encoder().Decode(failureNamespace, v, &failure, false); err != nil {
+				return fmt.Errorf("%w: unable to decode failure", err)
+			}
+
+			failures = append(failures, &failure)
+			return nil
+		},
+		false,
+		false,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to scan failures", err)
+	}
+
+	return failures, nil
+}
+
+// Print logs failures to the console as a table.
+func Print(failures []*Failure) {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetRowLine(true)
+	table.SetRowSeparator("-")
+	table.SetHeader([]string{"Kind", "Block", "Account", "Expected", "Actual", "Message"})
+	for _, failure := range failures {
+		var block, account string
+		if failure.Block != nil {
+			block = fmt.Sprintf("%s:%d", failure.Block.Hash, failure.Block.Index)
+		}
+
+		if failure.Account != nil {
+			account = types.PrintStruct(failure.Account)
+		}
+
+		table.Append([]string{
+			string(failure.Kind),
+			block,
+			account,
+			failure.Expected,
+			failure.Actual,
+			failure.Message,
+		})
+	}
+
+	table.Render()
+}
@@ -0,0 +1,117 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package failures
+
+import (
+	"context"
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/coinbase/rosetta-sdk-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStorage(t *testing.T) {
+	ctx := context.Background()
+	dir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(dir)
+
+	database, err := storage.NewBadgerStorage(
+		ctx,
+		dir,
+		storage.WithIndexCacheSize(storage.TinyIndexCacheSize),
+	)
+	assert.NoError(t, err)
+	defer database.Close(ctx)
+
+	s := NewStorage(database)
+	block := &types.BlockIdentifier{Hash: "block 1", Index: 1}
+	reconciliation := &Failure{
+		Kind:      ReconciliationFailure,
+		Timestamp: 10,
+		Block:     block,
+		Account:   &types.AccountIdentifier{Address: "addr1"},
+		Currency:  &types.Currency{Symbol: "BTC", Decimals: 8},
+		Expected:  "100",
+		Actual:    "90",
+		Context:   map[string]string{"reconciliation_type": "ACTIVE"},
+		Message:   "reconciliation failed",
+	}
+	invariant := &Failure{
+		Kind:        InvariantFailure,
+		Block:       block,
+		Transaction: &types.TransactionIdentifier{Hash: "tx1"},
+		Context:     map[string]string{"invariant": "max operations"},
+		Message:     "invariant violated",
+	}
+
+	t.Run("no failures", func(t *testing.T) {
+		assert.NoError(t, s.Flush(ctx))
+
+		failures, err := s.GetAll(ctx)
+		assert.NoError(t, err)
+		assert.Len(t, failures, 0)
+	})
+
+	t.Run("deferred failures are not persisted until flushed", func(t *testing.T) {
+		s.Defer(invariant)
+
+		failures, err := s.GetAll(ctx)
+		assert.NoError(t, err)
+		assert.Len(t, failures, 0)
+	})
+
+	t.Run("record persists deferred failures", func(t *testing.T) {
+		assert.NoError(t, s.Record(ctx, reconciliation))
+
+		failures, err := s.GetAll(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, []*Failure{invariant, reconciliation}, failures)
+		assert.Equal(t, SchemaVersion, failures[0].Version)
+		assert.NotZero(t, failures[0].Timestamp)
+		assert.Equal(t, int64(10), failures[1].Timestamp)
+	})
+
+	t.Run("failures persist across storage instances", func(t *testing.T) {
+		check := &Failure{Kind: CheckFailure, Message: "check failed"}
+		s2 := NewStorage(database)
+		s2.Defer(check)
+		assert.NoError(t, s2.Flush(ctx))
+
+		failures, err := s2.GetAll(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, []*Failure{invariant, reconciliation, check}, failures)
+	})
+
+	t.Run("failures recorded in a transaction are persisted on commit", func(t *testing.T) {
+		discarded := &Failure{Kind: DeepReorgFailure, Block: block, Message: "deep reorg"}
+		dbTx := database.NewDatabaseTransaction(ctx, true)
+		assert.NoError(t, s.RecordInTransaction(ctx, dbTx, discarded))
+		dbTx.Discard(ctx)
+
+		orphanRate := &Failure{Kind: OrphanRateFailure, Block: block, Message: "orphan rate"}
+		dbTx = database.NewDatabaseTransaction(ctx, true)
+		assert.NoError(t, s.RecordInTransaction(ctx, dbTx, orphanRate))
+		assert.NoError(t, dbTx.Commit(ctx))
+
+		failures, err := s.GetAll(ctx)
+		assert.NoError(t, err)
+		assert.Len(t, failures, 4)
+		assert.Equal(t, orphanRate, failures[3])
+		assert.Equal(t, SchemaVersion, failures[3].Version)
+	})
+}
@@ -0,0 +1,319 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fixture
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/coinbase/rosetta-sdk-go/utils"
+	"github.com/olekukonko/tablewriter"
+)
+
+const (
+	// constructionPrefix is the path prefix of
+	// all Construction API endpoints.
+	constructionPrefix = "/construction/"
+)
+
+// offlineEndpoints are the Construction API endpoints
+// that must not require a connection to a node. Only
+// these endpoints are re-executed during a replay.
+var offlineEndpoints = map[string]struct{}{
+	"/construction/derive":     {},
+	"/construction/preprocess": {},
+	"/construction/payloads":   {},
+	"/construction/combine":    {},
+	"/construction/parse":      {},
+	"/construction/hash":       {},
+}
+
+// Interaction is a single Construction API
+// request and the response to it.
+type Interaction struct {
+	Endpoint   string          `json:"endpoint"`
+	Request    json.RawMessage `json:"request"`
+	StatusCode int             `json:"status_code"`
+	Response   json.RawMessage `json:"response"`
+}
+
+// Offline returns a boolean indicating if the
+// Interaction was with an offline endpoint.
+func (i *Interaction) Offline() bool {
+	_, ok := offlineEndpoints[i.Endpoint]
+	return ok
+}
+
+// Fixture is the trace of all Construction API
+// interactions of a check:construction run.
+type Fixture struct {
+	Network      *types.NetworkIdentifier `json:"network_identifier"`
+	Interactions []*Interaction           `json:"interactions"`
+}
+
+// Load reads a *Fixture from filePath.
+func Load(filePath string) (*Fixture, error) {
+	var fixture Fixture
+	if err := utils.LoadAndParse(filePath, &fixture); err != nil {
+		return nil, fmt.Errorf("%w: unable to load fixture", err)
+	}
+
+	return &fixture, nil
+}
+
+// Recorder records all Construction API requests made
+// with the transports it wraps. All other requests are
+// passed through without being recorded.
+type Recorder struct {
+	network *types.NetworkIdentifier
+
+	interactions []*Interaction
+	mutex        sync.Mutex
+}
+
+// NewRecorder returns a new *Recorder for network.
+func NewRecorder(network *types.NetworkIdentifier) *Recorder {
+	return &Recorder{network: network}
+}
+
+// Wrap returns an http.RoundTripper that records
+// requests made with next. A single *Recorder can
+// wrap multiple transports (i.e. the transports
+// of the online and offline fetchers).
+func (r *Recorder) Wrap(next http.RoundTripper) http.RoundTripper {
+	return &recordingTransport{recorder: r, next: next}
+}
+
+type recordingTransport struct {
+	recorder *Recorder
+	next     http.RoundTripper
+}
+
+// RoundTrip records req and its response if req
+// is made to a Construction API endpoint.
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !strings.HasPrefix(req.URL.Path, constructionPrefix) || req.Body == nil {
+		return t.next.RoundTrip(req)
+	}
+
+	requestBody, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	_ = req.Body.Close()
+
+	req.Body = ioutil.NopCloser(bytes.NewReader(requestBody))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(requestBody)), nil
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	responseBody, err := ioutil.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(responseBody))
+
+	// Responses that are not JSON (i.e. errors returned by
+	// a proxy) are not part of the Construction API trace.
+	if json.Valid(requestBody) && json.Valid(responseBody) {
+		t.recorder.record(&Interaction{
+			Endpoint:   req.URL.Path,
+			Request:    requestBody,
+			StatusCode: resp.StatusCode,
+			Response:   responseBody,
+		})
+	}
+
+	return resp, nil
+}
+
+func (r *Recorder) record(interaction *Interaction) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.interactions = append(r.interactions, interaction)
+}
+
+// Fixture returns a *Fixture of all
+// interactions recorded so far.
+func (r *Recorder) Fixture() *Fixture {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	interactions := make([]*Interaction, len(r.interactions))
+	copy(interactions, r.interactions)
+
+	return &Fixture{
+		Network:      r.network,
+		Interactions: interactions,
+	}
+}
+
+// Save writes all interactions recorded
+// so far to filePath.
+func (r *Recorder) Save(filePath string) error {
+	if err := utils.SerializeAndWrite(filePath, r.Fixture()); err != nil {
+		return fmt.Errorf("%w: unable to save fixture", err)
+	}
+
+	return nil
+}
+
+// Mismatch is an offline interaction that returned
+// a different response during a replay.
+type Mismatch struct {
+	Index            int             `json:"index"`
+	Endpoint         string          `json:"endpoint"`
+	Request          json.RawMessage `json:"request"`
+	ExpectedStatus   int             `json:"expected_status"`
+	ExpectedResponse json.RawMessage `json:"expected_response"`
+	ActualStatus     int             `json:"actual_status"`
+	ActualResponse   json.RawMessage `json:"actual_response"`
+}
+
+// Replay re-executes all offline interactions in fixture
+// against the implementation at serverURL and returns
+// a *Mismatch for each response that differs from the
+// recorded response. Responses are compared as JSON
+// values (so field order and whitespace are ignored).
+func Replay(
+	ctx context.Context,
+	client *http.Client,
+	serverURL string,
+	fixture *Fixture,
+) ([]*Mismatch, error) {
+	mismatches := []*Mismatch{}
+	for i, interaction := range fixture.Interactions {
+		if !interaction.Offline() {
+			continue
+		}
+
+		status, response, err := interaction.Execute(ctx, client, serverURL)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to replay interaction %d", err, i)
+		}
+
+		equal, err := jsonEqual(interaction.Response, response)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to compare interaction %d", err, i)
+		}
+
+		if status == interaction.StatusCode && equal {
+			continue
+		}
+
+		mismatches = append(mismatches, &Mismatch{
+			Index:            i,
+			Endpoint:         interaction.Endpoint,
+			Request:          interaction.Request,
+			ExpectedStatus:   interaction.StatusCode,
+			ExpectedResponse: interaction.Response,
+			ActualStatus:     status,
+			ActualResponse:   response,
+		})
+	}
+
+	return mismatches, nil
+}
+
+// Execute sends the request of the Interaction to the
+// implementation at serverURL and returns the status
+// code and body of the response.
+func (i *Interaction) Execute(
+	ctx context.Context,
+	client *http.Client,
+	serverURL string,
+) (int, json.RawMessage, error) {
+	return post(ctx, client, serverURL+i.Endpoint, i.Request)
+}
+
+func post(
+	ctx context.Context,
+	client *http.Client,
+	url string,
+	body []byte,
+) (int, json.RawMessage, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	response, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if !json.Valid(response) {
+		return 0, nil, fmt.Errorf("invalid JSON response from %s: %s", url, string(response))
+	}
+
+	return resp.StatusCode, response, nil
+}
+
+// jsonEqual returns a boolean indicating
+// if a and b encode the same JSON value.
+func jsonEqual(a json.RawMessage, b json.RawMessage) (bool, error) {
+	var aValue, bValue interface{}
+	if err := json.Unmarshal(a, &aValue); err != nil {
+		return false, err
+	}
+
+	if err := json.Unmarshal(b, &bValue); err != nil {
+		return false, err
+	}
+
+	return reflect.DeepEqual(aValue, bValue), nil
+}
+
+// PrintMismatches prints mismatches as a table.
+func PrintMismatches(mismatches []*Mismatch) {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetRowLine(true)
+	table.SetRowSeparator("-")
+	table.SetHeader([]string{"Index", "Endpoint", "Expected", "Actual"})
+	for _, mismatch := range mismatches {
+		table.Append([]string{
+			fmt.Sprintf("%d", mismatch.Index),
+			mismatch.Endpoint,
+			fmt.Sprintf("%d %s", mismatch.ExpectedStatus, string(mismatch.ExpectedResponse)),
+			fmt.Sprintf("%d %s", mismatch.ActualStatus, string(mismatch.ActualResponse)),
+		})
+	}
+
+	table.Render()
+}
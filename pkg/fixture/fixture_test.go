@@ -0,0 +1,121 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fixture
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/coinbase/rosetta-sdk-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+// newServer returns a server that responds to all
+// requests with the response for the request path.
+func newServer(responses map[string]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response, ok := responses[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(`{"code":1,"message":"unknown endpoint","retriable":false}`))
+			return
+		}
+
+		_, _ = w.Write([]byte(response))
+	}))
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	ctx := context.Background()
+	network := &types.NetworkIdentifier{Blockchain: "Bitcoin", Network: "Testnet"}
+
+	original := newServer(map[string]string{
+		"/network/list":            `{"network_identifiers":[]}`,
+		"/construction/derive":     `{"address":"addr1"}`,
+		"/construction/metadata":   `{"metadata":{"nonce":1}}`,
+		"/construction/payloads":   `{"unsigned_transaction":"tx","payloads":[]}`,
+		"/construction/preprocess": `{"options":{"a":1,"b":2}}`,
+	})
+	defer original.Close()
+
+	recorder := NewRecorder(network)
+	client := &http.Client{Transport: recorder.Wrap(http.DefaultTransport)}
+	for _, endpoint := range []string{
+		"/network/list",
+		"/construction/derive",
+		"/construction/metadata",
+		"/construction/payloads",
+		"/construction/preprocess",
+		"/construction/hash",
+	} {
+		resp, err := client.Post(original.URL+endpoint, "application/json", bytes.NewReader([]byte(`{}`)))
+		assert.NoError(t, err)
+
+		// The response body can still be read
+		// by the client after it is recorded.
+		body, err := ioutil.ReadAll(resp.Body)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, body)
+		assert.NoError(t, resp.Body.Close())
+	}
+
+	// Only Construction API requests are recorded.
+	recorded := recorder.Fixture()
+	assert.Equal(t, network, recorded.Network)
+	assert.Len(t, recorded.Interactions, 5)
+	assert.Equal(t, "/construction/derive", recorded.Interactions[0].Endpoint)
+	assert.False(t, recorded.Interactions[1].Offline())
+	assert.Equal(t, http.StatusInternalServerError, recorded.Interactions[4].StatusCode)
+
+	dir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(dir)
+
+	filePath := path.Join(dir, "fixture.json")
+	assert.NoError(t, recorder.Save(filePath))
+	loaded, err := Load(filePath)
+	assert.NoError(t, err)
+	assert.Len(t, loaded.Interactions, 5)
+
+	t.Run("identical implementation", func(t *testing.T) {
+		mismatches, err := Replay(ctx, http.DefaultClient, original.URL, loaded)
+		assert.NoError(t, err)
+		assert.Len(t, mismatches, 0)
+	})
+
+	t.Run("regressed implementation", func(t *testing.T) {
+		// Field order is ignored and online
+		// endpoints are not replayed.
+		updated := newServer(map[string]string{
+			"/construction/derive":     `{"address":"addr2"}`,
+			"/construction/payloads":   `{"payloads":[],"unsigned_transaction":"tx"}`,
+			"/construction/preprocess": `{"options":{"b":2,"a":1}}`,
+		})
+		defer updated.Close()
+
+		mismatches, err := Replay(ctx, http.DefaultClient, updated.URL, loaded)
+		assert.NoError(t, err)
+		assert.Len(t, mismatches, 1)
+		assert.Equal(t, 0, mismatches[0].Index)
+		assert.Equal(t, "/construction/derive", mismatches[0].Endpoint)
+		assert.JSONEq(t, `{"address":"addr2"}`, string(mismatches[0].ActualResponse))
+	})
+}
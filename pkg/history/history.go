@@ -0,0 +1,231 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package history
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"strconv"
+
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/olekukonko/tablewriter"
+)
+
+// logFrequency is the number of scanned
+// blocks between progress logs.
+const logFrequency = 10000
+
+// SuccessFunc returns a boolean indicating if
+// an operation was successful (and should be
+// applied to the running balance). This is usually
+// (*asserter.Asserter).OperationSuccessful.
+type SuccessFunc func(*types.Operation) (bool, error)
+
+// Entry is an operation affecting an account
+// and the running balance of the operation's
+// currency after it is applied.
+type Entry struct {
+	Block       *types.BlockIdentifier       `json:"block_identifier"`
+	Transaction *types.TransactionIdentifier `json:"transaction_identifier"`
+	Operation   *types.Operation             `json:"operation"`
+	Successful  bool                         `json:"successful"`
+	Balance     *types.Amount                `json:"balance"`
+}
+
+// AccountHistory returns an *Entry for every operation
+// affecting account in all blocks in blockStorage with an
+// index between startIndex and endIndex (inclusive). If
+// startIndex is -1, blocks are scanned from the oldest
+// block in storage. If endIndex is -1, blocks are scanned
+// until the head block.
+//
+// The running balance of each currency starts at 0 at
+// startIndex, so it only matches the balance of account if
+// all blocks where account was modified are in storage (and
+// account did not have a bootstrap balance).
+func AccountHistory(
+	ctx context.Context,
+	blockStorage *storage.BlockStorage,
+	successful SuccessFunc,
+	account *types.AccountIdentifier,
+	startIndex int64,
+	endIndex int64,
+) ([]*Entry, error) {
+	head, err := blockStorage.GetHeadBlockIdentifier(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to get head block", err)
+	}
+
+	if endIndex == -1 || endIndex > head.Index {
+		endIndex = head.Index
+	}
+
+	if startIndex == -1 {
+		oldestIndex, err := blockStorage.GetOldestBlockIndex(ctx)
+		switch {
+		case err == nil:
+			startIndex = oldestIndex
+		case errors.Is(err, storage.ErrOldestIndexMissing):
+			startIndex = 0
+		default:
+			return nil, fmt.Errorf("%w: unable to get oldest block index", err)
+		}
+	}
+
+	accountKey := types.Hash(account)
+	balances := map[string]*big.Int{}
+	entries := []*Entry{}
+	for index := startIndex; index <= endIndex; index++ {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		i := index
+		block, err := blockStorage.GetBlock(ctx, &types.PartialBlockIdentifier{Index: &i})
+		if errors.Is(err, storage.ErrBlockNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to get block %d", err, index)
+		}
+
+		for _, tx := range block.Transactions {
+			for _, op := range tx.Operations {
+				if op.Account == nil || types.Hash(op.Account) != accountKey {
+					continue
+				}
+
+				entry, err := newEntry(balances, successful, block, tx, op)
+				if err != nil {
+					return nil, fmt.Errorf(
+						"%w: unable to apply operation %d in transaction %s",
+						err,
+						op.OperationIdentifier.Index,
+						tx.TransactionIdentifier.Hash,
+					)
+				}
+
+				entries = append(entries, entry)
+			}
+		}
+
+		if (index-startIndex+1)%logFrequency == 0 {
+			log.Printf("scanned %d blocks (current index: %d)\n", index-startIndex+1, index)
+		}
+	}
+
+	return entries, nil
+}
+
+// newEntry applies op to balances (if op is successful
+// and has an amount) and returns the *Entry for op.
+func newEntry(
+	balances map[string]*big.Int,
+	successful SuccessFunc,
+	block *types.Block,
+	tx *types.Transaction,
+	op *types.Operation,
+) (*Entry, error) {
+	success, err := successful(op)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to determine if operation is successful", err)
+	}
+
+	entry := &Entry{
+		Block:       block.BlockIdentifier,
+		Transaction: tx.TransactionIdentifier,
+		Operation:   op,
+		Successful:  success,
+	}
+
+	if op.Amount == nil {
+		return entry, nil
+	}
+
+	currencyKey := types.Hash(op.Amount.Currency)
+	balance, ok := balances[currencyKey]
+	if !ok {
+		balance = big.NewInt(0)
+	}
+
+	if success {
+		value, err := types.BigInt(op.Amount.Value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to parse amount", err)
+		}
+
+		balance = new(big.Int).Add(balance, value)
+		balances[currencyKey] = balance
+	}
+
+	entry.Balance = &types.Amount{
+		Value:    balance.String(),
+		Currency: op.Amount.Currency,
+	}
+
+	return entry, nil
+}
+
+// Print logs all entries in a table.
+func Print(entries []*Entry) {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetRowLine(true)
+	table.SetRowSeparator("-")
+	table.SetHeader([]string{
+		"Block",
+		"Transaction",
+		"Operation",
+		"Type",
+		"Status",
+		"Amount",
+		"Balance",
+		"Currency",
+	})
+	for _, entry := range entries {
+		var status string
+		if entry.Operation.Status != nil {
+			status = *entry.Operation.Status
+		}
+
+		if !entry.Successful {
+			status = fmt.Sprintf("%s (not applied)", status)
+		}
+
+		var amount, balance, currency string
+		if entry.Operation.Amount != nil {
+			amount = entry.Operation.Amount.Value
+			balance = entry.Balance.Value
+			currency = types.PrintStruct(entry.Operation.Amount.Currency)
+		}
+
+		table.Append([]string{
+			fmt.Sprintf("%s:%d", entry.Block.Hash, entry.Block.Index),
+			entry.Transaction.Hash,
+			strconv.FormatInt(entry.Operation.OperationIdentifier.Index, 10),
+			entry.Operation.Type,
+			status,
+			amount,
+			balance,
+			currency,
+		})
+	}
+
+	table.Render()
+}
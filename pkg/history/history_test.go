@@ -0,0 +1,134 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package history
+
+import (
+	"context"
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/coinbase/rosetta-sdk-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func successful(op *types.Operation) (bool, error) {
+	return *op.Status == "SUCCESS", nil
+}
+
+func transferOp(
+	index int64,
+	status string,
+	account *types.AccountIdentifier,
+	value string,
+) *types.Operation {
+	return &types.Operation{
+		OperationIdentifier: &types.OperationIdentifier{Index: index},
+		Type:                "TRANSFER",
+		Status:              &status,
+		Account:             account,
+		Amount: &types.Amount{
+			Value:    value,
+			Currency: &types.Currency{Symbol: "BTC", Decimals: 8},
+		},
+	}
+}
+
+func TestAccountHistory(t *testing.T) {
+	ctx := context.Background()
+
+	dir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(dir)
+
+	database, err := storage.NewBadgerStorage(ctx, dir)
+	assert.NoError(t, err)
+	defer database.Close(ctx)
+
+	blockStorage := storage.NewBlockStorage(database)
+	blockStorage.Initialize([]storage.BlockWorker{})
+
+	addr1 := &types.AccountIdentifier{Address: "addr 1"}
+	addr2 := &types.AccountIdentifier{Address: "addr 2"}
+	blocks := []*types.Block{
+		{
+			BlockIdentifier:       &types.BlockIdentifier{Hash: "block 0", Index: 0},
+			ParentBlockIdentifier: &types.BlockIdentifier{Hash: "block 0", Index: 0},
+			Transactions: []*types.Transaction{
+				{
+					TransactionIdentifier: &types.TransactionIdentifier{Hash: "tx 0"},
+					Operations: []*types.Operation{
+						transferOp(0, "SUCCESS", addr1, "100"),
+					},
+				},
+			},
+		},
+		{
+			BlockIdentifier:       &types.BlockIdentifier{Hash: "block 1", Index: 1},
+			ParentBlockIdentifier: &types.BlockIdentifier{Hash: "block 0", Index: 0},
+			Transactions: []*types.Transaction{
+				{
+					TransactionIdentifier: &types.TransactionIdentifier{Hash: "tx 1"},
+					Operations: []*types.Operation{
+						transferOp(0, "SUCCESS", addr1, "-40"),
+						transferOp(1, "SUCCESS", addr2, "40"),
+					},
+				},
+				{
+					TransactionIdentifier: &types.TransactionIdentifier{Hash: "tx 2"},
+					Operations: []*types.Operation{
+						transferOp(0, "FAILURE", addr1, "-10"),
+					},
+				},
+			},
+		},
+	}
+
+	for _, block := range blocks {
+		assert.NoError(t, blockStorage.AddBlock(ctx, block))
+	}
+
+	t.Run("all blocks", func(t *testing.T) {
+		entries, err := AccountHistory(ctx, blockStorage, successful, addr1, -1, -1)
+		assert.NoError(t, err)
+		assert.Len(t, entries, 3)
+
+		assert.Equal(t, "tx 0", entries[0].Transaction.Hash)
+		assert.True(t, entries[0].Successful)
+		assert.Equal(t, "100", entries[0].Balance.Value)
+
+		assert.Equal(t, "tx 1", entries[1].Transaction.Hash)
+		assert.Equal(t, int64(1), entries[1].Block.Index)
+		assert.Equal(t, "60", entries[1].Balance.Value)
+
+		assert.Equal(t, "tx 2", entries[2].Transaction.Hash)
+		assert.False(t, entries[2].Successful)
+		assert.Equal(t, "60", entries[2].Balance.Value)
+	})
+
+	t.Run("range", func(t *testing.T) {
+		entries, err := AccountHistory(ctx, blockStorage, successful, addr1, 1, 10)
+		assert.NoError(t, err)
+		assert.Len(t, entries, 2)
+		assert.Equal(t, "-40", entries[0].Balance.Value)
+	})
+
+	t.Run("other account", func(t *testing.T) {
+		entries, err := AccountHistory(ctx, blockStorage, successful, addr2, -1, -1)
+		assert.NoError(t, err)
+		assert.Len(t, entries, 1)
+		assert.Equal(t, "40", entries[0].Balance.Value)
+	})
+}
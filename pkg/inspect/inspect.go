@@ -0,0 +1,322 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package inspect
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/coinbase/rosetta-cli/pkg/results"
+
+	"github.com/coinbase/rosetta-sdk-go/asserter"
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+const (
+	// prompt is printed before
+	// reading each query.
+	prompt = "> "
+
+	// blockStreamFile is the file the logger writes
+	// added and removed blocks to (if log_blocks
+	// is enabled).
+	blockStreamFile = "blocks.txt"
+
+	// removedBlockPrefix is the prefix of
+	// lines in blockStreamFile for orphaned blocks.
+	removedBlockPrefix = "Remove Block"
+
+	// defaultOrphans is the number of orphans
+	// printed if no limit is provided.
+	defaultOrphans = 10
+
+	helpText = `Queries:
+  block <index or hash>             print a stored block
+  balance <account> [block index]   print the computed balances of an account
+                                    (at the head block if no index is provided)
+  orphans [limit]                   print the most recently orphaned blocks
+                                    (requires log_blocks)
+  counters                          print all counters
+  help                              print this message
+  exit                              exit the explorer
+`
+)
+
+var (
+	// ErrExit is returned by Execute when
+	// the exit query is executed.
+	ErrExit = errors.New("exit")
+
+	// ErrUnknownQuery is returned when
+	// a query is not supported.
+	ErrUnknownQuery = errors.New("unknown query")
+
+	// ErrInvalidArguments is returned when the
+	// arguments of a query cannot be parsed.
+	ErrInvalidArguments = errors.New("invalid arguments")
+)
+
+// Explorer executes interactive queries against data
+// stored by check:data. No data is written to storage.
+type Explorer struct {
+	database       storage.Database
+	blockStorage   *storage.BlockStorage
+	balanceStorage *storage.BalanceStorage
+	counterStorage *storage.CounterStorage
+	logDir         string
+}
+
+// New returns a new *Explorer for database. logDir
+// is the directory check:data wrote logs to.
+func New(database storage.Database, logDir string) *Explorer {
+	return &Explorer{
+		database:       database,
+		blockStorage:   storage.NewBlockStorage(database),
+		balanceStorage: storage.NewBalanceStorage(database),
+		counterStorage: storage.NewCounterStorage(database),
+		logDir:         logDir,
+	}
+}
+
+// Run reads queries from r (one per line) and writes
+// results to w until the exit query is executed, r
+// is exhausted, or ctx is canceled. Errors encountered
+// executing a query are written to w.
+func (e *Explorer) Run(ctx context.Context, r io.Reader, w io.Writer) error {
+	scanner := bufio.NewScanner(r)
+	fmt.Fprint(w, prompt)
+	for scanner.Scan() {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		err := e.Execute(ctx, scanner.Text(), w)
+		if errors.Is(err, ErrExit) {
+			return nil
+		}
+
+		if err != nil {
+			fmt.Fprintf(w, "error: %s\n", err.Error())
+		}
+
+		fmt.Fprint(w, prompt)
+	}
+
+	return scanner.Err()
+}
+
+// Execute executes a single query and
+// writes the result to w.
+func (e *Explorer) Execute(ctx context.Context, query string, w io.Writer) error {
+	query = strings.TrimSpace(query)
+	if len(query) == 0 {
+		return nil
+	}
+
+	name, args := query, ""
+	if i := strings.IndexAny(query, " \t"); i >= 0 {
+		name, args = query[:i], strings.TrimSpace(query[i+1:])
+	}
+
+	switch name {
+	case "block":
+		return e.block(ctx, args, w)
+	case "balance":
+		return e.balance(ctx, args, w)
+	case "orphans":
+		return e.orphans(args, w)
+	case "counters":
+		return e.counters(ctx, w)
+	case "help":
+		fmt.Fprint(w, helpText)
+		return nil
+	case "exit", "quit":
+		return ErrExit
+	default:
+		return fmt.Errorf("%w: %s (run help to see all queries)", ErrUnknownQuery, name)
+	}
+}
+
+func (e *Explorer) block(ctx context.Context, args string, w io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: block index or hash must be provided", ErrInvalidArguments)
+	}
+
+	identifier := &types.PartialBlockIdentifier{}
+	if index, err := strconv.ParseInt(args, 10, 64); err == nil {
+		identifier.Index = &index
+	} else {
+		identifier.Hash = &args
+	}
+
+	block, err := e.blockStorage.GetBlock(ctx, identifier)
+	if err != nil {
+		return fmt.Errorf("%w: unable to get block %s", err, args)
+	}
+
+	fmt.Fprintln(w, types.PrettyPrintStruct(block))
+	return nil
+}
+
+// parseAccount parses an account (an address or a JSON
+// types.AccountIdentifier) from the start of args and
+// returns the remaining arguments.
+func parseAccount(args string) (*types.AccountIdentifier, string, error) {
+	if !strings.HasPrefix(args, "{") {
+		fields := strings.Fields(args)
+		if len(fields) == 0 {
+			return nil, "", fmt.Errorf("%w: account must be provided", ErrInvalidArguments)
+		}
+
+		return &types.AccountIdentifier{Address: fields[0]},
+			strings.TrimSpace(strings.TrimPrefix(args, fields[0])), nil
+	}
+
+	end := strings.LastIndex(args, "}")
+	account := &types.AccountIdentifier{}
+	if err := json.Unmarshal([]byte(args[:end+1]), account); err != nil {
+		return nil, "", fmt.Errorf("%w: unable to unmarshal account %s", err, args)
+	}
+
+	if err := asserter.AccountIdentifier(account); err != nil {
+		return nil, "", fmt.Errorf("%w: invalid account identifier", err)
+	}
+
+	return account, strings.TrimSpace(args[end+1:]), nil
+}
+
+func (e *Explorer) balance(ctx context.Context, args string, w io.Writer) error {
+	account, rest, err := parseAccount(args)
+	if err != nil {
+		return err
+	}
+
+	// A nil *types.PartialBlockIdentifier
+	// returns the head block.
+	var identifier *types.PartialBlockIdentifier
+	if len(rest) > 0 {
+		index, err := strconv.ParseInt(rest, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%w: block index %s is not an integer", ErrInvalidArguments, rest)
+		}
+
+		identifier = &types.PartialBlockIdentifier{Index: &index}
+	}
+
+	block, err := e.blockStorage.GetBlock(ctx, identifier)
+	if err != nil {
+		return fmt.Errorf("%w: unable to get block", err)
+	}
+
+	accountCurrencies, err := e.balanceStorage.GetAllAccountCurrency(ctx)
+	if err != nil {
+		return fmt.Errorf("%w: unable to get accounts", err)
+	}
+
+	dbTx := e.database.NewDatabaseTransaction(ctx, false)
+	defer dbTx.Discard(ctx)
+
+	accountKey := types.Hash(account)
+	balances := []*types.Amount{}
+	for _, accountCurrency := range accountCurrencies {
+		if types.Hash(accountCurrency.Account) != accountKey {
+			continue
+		}
+
+		amount, err := e.balanceStorage.GetBalanceTransactional(
+			ctx,
+			dbTx,
+			account,
+			accountCurrency.Currency,
+			block.BlockIdentifier.Index,
+		)
+		if err != nil {
+			return fmt.Errorf(
+				"%w: unable to get %s balance",
+				err,
+				types.PrintStruct(accountCurrency.Currency),
+			)
+		}
+
+		balances = append(balances, amount)
+	}
+
+	fmt.Fprintln(w, types.PrettyPrintStruct(&types.AccountBalanceResponse{
+		BlockIdentifier: block.BlockIdentifier,
+		Balances:        balances,
+	}))
+	return nil
+}
+
+func (e *Explorer) orphans(args string, w io.Writer) error {
+	limit := defaultOrphans
+	if len(args) > 0 {
+		var err error
+		limit, err = strconv.Atoi(args)
+		if err != nil || limit <= 0 {
+			return fmt.Errorf("%w: limit %s must be a positive integer", ErrInvalidArguments, args)
+		}
+	}
+
+	f, err := os.Open(path.Join(e.logDir, blockStreamFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%w: orphans are only available if log_blocks is enabled", err)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: unable to open block stream", err)
+	}
+	defer f.Close()
+
+	orphans := []string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if !strings.HasPrefix(scanner.Text(), removedBlockPrefix) {
+			continue
+		}
+
+		orphans = append(orphans, strings.TrimSpace(strings.TrimPrefix(scanner.Text(), removedBlockPrefix)))
+		if len(orphans) > limit {
+			orphans = orphans[1:]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("%w: unable to read block stream", err)
+	}
+
+	for i := len(orphans) - 1; i >= 0; i-- {
+		fmt.Fprintln(w, orphans[i])
+	}
+
+	return nil
+}
+
+func (e *Explorer) counters(ctx context.Context, w io.Writer) error {
+	stats := results.ComputeCheckDataStats(ctx, e.counterStorage, e.balanceStorage)
+	if stats == nil {
+		return errors.New("unable to get counters")
+	}
+
+	fmt.Fprintln(w, types.PrettyPrintStruct(stats))
+	return nil
+}
@@ -0,0 +1,170 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package inspect
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/coinbase/rosetta-sdk-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExplorer(t *testing.T) {
+	ctx := context.Background()
+	dir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(dir)
+
+	database, err := storage.NewBadgerStorage(ctx, path.Join(dir, "db"))
+	assert.NoError(t, err)
+	defer database.Close(ctx)
+
+	blockStorage := storage.NewBlockStorage(database)
+	blockStorage.Initialize([]storage.BlockWorker{})
+	blocks := []*types.Block{
+		{
+			BlockIdentifier:       &types.BlockIdentifier{Hash: "block 0", Index: 0},
+			ParentBlockIdentifier: &types.BlockIdentifier{Hash: "block 0", Index: 0},
+		},
+		{
+			BlockIdentifier:       &types.BlockIdentifier{Hash: "block 1", Index: 1},
+			ParentBlockIdentifier: &types.BlockIdentifier{Hash: "block 0", Index: 0},
+		},
+	}
+	for _, block := range blocks {
+		assert.NoError(t, blockStorage.AddBlock(ctx, block))
+	}
+
+	account := &types.AccountIdentifier{
+		Address:    "addr",
+		SubAccount: &types.SubAccountIdentifier{Address: "staking"},
+	}
+	dbTx := database.NewDatabaseTransaction(ctx, true)
+	assert.NoError(t, storage.NewBalanceStorage(database).SetBalance(
+		ctx,
+		dbTx,
+		account,
+		&types.Amount{Value: "100", Currency: &types.Currency{Symbol: "BTC", Decimals: 8}},
+		blocks[0].BlockIdentifier,
+	))
+	assert.NoError(t, dbTx.Commit(ctx))
+
+	assert.NoError(t, ioutil.WriteFile(path.Join(dir, blockStreamFile), []byte(
+		"Add Block 0:block 0 with Parent Block 0:block 0\n"+
+			"Add Block 1:block 1a with Parent Block 0:block 0\n"+
+			"Remove Block 1:block 1a\n"+
+			"Add Block 1:block 1b with Parent Block 0:block 0\n"+
+			"Remove Block 1:block 1b\n"+
+			"Add Block 1:block 1 with Parent Block 0:block 0\n",
+	), 0600))
+
+	e := New(database, dir)
+
+	var tests = map[string]struct {
+		query string
+
+		contains    []string
+		notContains []string
+		err         error
+	}{
+		"block by index": {
+			query:    "block 1",
+			contains: []string{`"hash": "block 1"`},
+		},
+		"block by hash": {
+			query:    "block block 0",
+			contains: []string{`"hash": "block 0"`},
+		},
+		"missing block": {
+			query: "block 10",
+			err:   storage.ErrBlockNotFound,
+		},
+		"balance at head": {
+			query:    `balance {"address":"addr","sub_account":{"address":"staking"}}`,
+			contains: []string{`"hash": "block 1"`, `"value": "100"`},
+		},
+		"balance at index": {
+			query:    `balance {"address": "addr", "sub_account": {"address": "staking"}} 0`,
+			contains: []string{`"hash": "block 0"`, `"value": "100"`},
+		},
+		"balance of unknown account": {
+			query:       "balance other",
+			contains:    []string{`"balances": []`},
+			notContains: []string{`"value"`},
+		},
+		"balance with invalid index": {
+			query: "balance addr latest",
+			err:   ErrInvalidArguments,
+		},
+		"orphans": {
+			query:    "orphans",
+			contains: []string{"1:block 1b\n1:block 1a\n"},
+		},
+		"orphans with limit": {
+			query:       "orphans 1",
+			contains:    []string{"1:block 1b"},
+			notContains: []string{"block 1a"},
+		},
+		"counters": {
+			query:    "counters",
+			contains: []string{`"blocks": 0`},
+		},
+		"unknown query": {
+			query: "delete block 1",
+			err:   ErrUnknownQuery,
+		},
+		"exit": {
+			query: "exit",
+			err:   ErrExit,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := e.Execute(ctx, test.query, &out)
+			if test.err != nil {
+				assert.True(t, errors.Is(err, test.err), err)
+				return
+			}
+
+			assert.NoError(t, err)
+			for _, s := range test.contains {
+				assert.Contains(t, out.String(), s)
+			}
+			for _, s := range test.notContains {
+				assert.NotContains(t, out.String(), s)
+			}
+		})
+	}
+
+	t.Run("run", func(t *testing.T) {
+		var out bytes.Buffer
+		assert.NoError(t, e.Run(ctx, strings.NewReader("help\nblock 10\nexit\nblock 1\n"), &out))
+		assert.Contains(t, out.String(), "Queries:")
+		assert.Contains(t, out.String(), "error: ")
+
+		// Queries after exit are not executed.
+		assert.NotContains(t, out.String(), `"hash": "block 1"`)
+	})
+}
@@ -0,0 +1,497 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package invariant
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/tidwall/gjson"
+)
+
+var (
+	// ErrInvalidExpression is returned when an
+	// expression cannot be parsed.
+	ErrInvalidExpression = errors.New("invalid expression")
+
+	// ErrEvaluationFailed is returned when an
+	// expression cannot be evaluated.
+	ErrEvaluationFailed = errors.New("unable to evaluate expression")
+)
+
+// tokenType is the type of a lexed token.
+type tokenType int
+
+const (
+	tokenEOF tokenType = iota
+	tokenPath
+	tokenNumber
+	tokenString
+	tokenOperator
+	tokenLeftParen
+	tokenRightParen
+)
+
+type token struct {
+	kind  tokenType
+	value string
+}
+
+// operators are all supported operators (longest first
+// so that "<=" is not lexed as "<").
+var operators = []string{"==", "!=", "<=", ">=", "&&", "||", "<", ">", "!"}
+
+func isPathRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("_.#", r)
+}
+
+// lex splits source into tokens.
+func lex(source string) ([]token, error) {
+	tokens := []token{}
+	runes := []rune(source)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, token{kind: tokenLeftParen, value: "("})
+			i++
+		case r == ')':
+			tokens = append(tokens, token{kind: tokenRightParen, value: ")"})
+			i++
+		case r == '"':
+			j := i + 1
+			for ; j < len(runes) && runes[j] != '"'; j++ {
+				if runes[j] == '\\' {
+					j++
+				}
+			}
+			if j >= len(runes) {
+				return nil, fmt.Errorf("%w: unterminated string", ErrInvalidExpression)
+			}
+
+			value, err := strconv.Unquote(string(runes[i : j+1]))
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s is not a valid string", ErrInvalidExpression, string(runes[i:j+1]))
+			}
+
+			tokens = append(tokens, token{kind: tokenString, value: value})
+			i = j + 1
+		case unicode.IsDigit(r) || (r == '-' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			j := i + 1
+			for ; j < len(runes) && (unicode.IsDigit(runes[j]) || runes[j] == '.'); j++ {
+			}
+
+			tokens = append(tokens, token{kind: tokenNumber, value: string(runes[i:j])})
+			i = j
+		case unicode.IsLetter(r) || r == '_':
+			j := i + 1
+			for ; j < len(runes) && isPathRune(runes[j]); j++ {
+			}
+
+			tokens = append(tokens, token{kind: tokenPath, value: string(runes[i:j])})
+			i = j
+		default:
+			matched := false
+			for _, op := range operators {
+				if strings.HasPrefix(string(runes[i:]), op) {
+					tokens = append(tokens, token{kind: tokenOperator, value: op})
+					i += len([]rune(op))
+					matched = true
+					break
+				}
+			}
+
+			if !matched {
+				return nil, fmt.Errorf("%w: unexpected character %q", ErrInvalidExpression, r)
+			}
+		}
+	}
+
+	return append(tokens, token{kind: tokenEOF}), nil
+}
+
+// valueKind is the kind of an evaluated value.
+type valueKind int
+
+const (
+	nullValue valueKind = iota
+	boolValue
+	numberValue
+	stringValue
+	jsonValue
+)
+
+// value is the result of evaluating a node.
+type value struct {
+	kind valueKind
+	b    bool
+	s    string
+}
+
+// number returns the value as a number (strings are parsed so
+// that amounts, which are encoded as strings, can be compared).
+func (v value) number() (*big.Rat, bool) {
+	if v.kind != numberValue && v.kind != stringValue {
+		return nil, false
+	}
+
+	return new(big.Rat).SetString(v.s)
+}
+
+func (v value) String() string {
+	switch v.kind {
+	case nullValue:
+		return "null"
+	case boolValue:
+		return strconv.FormatBool(v.b)
+	case stringValue:
+		return strconv.Quote(v.s)
+	default:
+		return v.s
+	}
+}
+
+// node is a parsed expression.
+type node interface {
+	evaluate(root gjson.Result) (value, error)
+}
+
+type literalNode struct {
+	value value
+}
+
+func (n *literalNode) evaluate(root gjson.Result) (value, error) {
+	return n.value, nil
+}
+
+type pathNode struct {
+	path string
+}
+
+func (n *pathNode) evaluate(root gjson.Result) (value, error) {
+	result := root.Get(n.path)
+	switch result.Type {
+	case gjson.Null:
+		return value{kind: nullValue}, nil
+	case gjson.False, gjson.True:
+		return value{kind: boolValue, b: result.Bool()}, nil
+	case gjson.Number:
+		return value{kind: numberValue, s: result.Raw}, nil
+	case gjson.String:
+		return value{kind: stringValue, s: result.Str}, nil
+	default:
+		return value{kind: jsonValue, s: result.Raw}, nil
+	}
+}
+
+type notNode struct {
+	operand node
+}
+
+func (n *notNode) evaluate(root gjson.Result) (value, error) {
+	b, err := evaluateBool(n.operand, root)
+	if err != nil {
+		return value{}, err
+	}
+
+	return value{kind: boolValue, b: !b}, nil
+}
+
+type logicalNode struct {
+	operator    string
+	left, right node
+}
+
+func (n *logicalNode) evaluate(root gjson.Result) (value, error) {
+	left, err := evaluateBool(n.left, root)
+	if err != nil {
+		return value{}, err
+	}
+
+	// Short-circuit so that the right side can
+	// depend on the left side (e.g. a path existing).
+	if (n.operator == "&&" && !left) || (n.operator == "||" && left) {
+		return value{kind: boolValue, b: left}, nil
+	}
+
+	right, err := evaluateBool(n.right, root)
+	if err != nil {
+		return value{}, err
+	}
+
+	return value{kind: boolValue, b: right}, nil
+}
+
+type comparisonNode struct {
+	operator    string
+	left, right node
+}
+
+func (n *comparisonNode) evaluate(root gjson.Result) (value, error) {
+	left, err := n.left.evaluate(root)
+	if err != nil {
+		return value{}, err
+	}
+
+	right, err := n.right.evaluate(root)
+	if err != nil {
+		return value{}, err
+	}
+
+	leftNumber, leftOk := left.number()
+	rightNumber, rightOk := right.number()
+	if leftOk && rightOk {
+		cmp := leftNumber.Cmp(rightNumber)
+		var result bool
+		switch n.operator {
+		case "==":
+			result = cmp == 0
+		case "!=":
+			result = cmp != 0
+		case "<":
+			result = cmp < 0
+		case "<=":
+			result = cmp <= 0
+		case ">":
+			result = cmp > 0
+		case ">=":
+			result = cmp >= 0
+		}
+
+		return value{kind: boolValue, b: result}, nil
+	}
+
+	switch n.operator {
+	case "==", "!=":
+		equal := left.kind == right.kind && left.b == right.b && left.s == right.s
+		return value{kind: boolValue, b: equal == (n.operator == "==")}, nil
+	default:
+		return value{}, fmt.Errorf(
+			"%w: cannot compare %s %s %s",
+			ErrEvaluationFailed,
+			left,
+			n.operator,
+			right,
+		)
+	}
+}
+
+func evaluateBool(n node, root gjson.Result) (bool, error) {
+	v, err := n.evaluate(root)
+	if err != nil {
+		return false, err
+	}
+
+	if v.kind != boolValue {
+		return false, fmt.Errorf("%w: %s is not a boolean", ErrEvaluationFailed, v)
+	}
+
+	return v.b, nil
+}
+
+// parser is a recursive descent parser for expressions:
+//
+//	or         = and { "||" and }
+//	and        = unary { "&&" unary }
+//	unary      = "!" unary | comparison
+//	comparison = operand [ ( "==" | "!=" | "<" | "<=" | ">" | ">=" ) operand ]
+//	operand    = path | number | string | "true" | "false" | "null" | "(" or ")"
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+
+	return t
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peek().kind == tokenOperator && p.peek().value == "||" {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+
+		left = &logicalNode{operator: "||", left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peek().kind == tokenOperator && p.peek().value == "&&" {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		left = &logicalNode{operator: "&&", left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.peek().kind == tokenOperator && p.peek().value == "!" {
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		return &notNode{operand: operand}, nil
+	}
+
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (node, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	t := p.peek()
+	if t.kind != tokenOperator {
+		return left, nil
+	}
+
+	switch t.value {
+	case "==", "!=", "<", "<=", ">", ">=":
+		p.next()
+		right, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+
+		return &comparisonNode{operator: t.value, left: left, right: right}, nil
+	default:
+		return left, nil
+	}
+}
+
+func (p *parser) parseOperand() (node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenLeftParen:
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+
+		if p.next().kind != tokenRightParen {
+			return nil, fmt.Errorf("%w: missing )", ErrInvalidExpression)
+		}
+
+		return n, nil
+	case tokenNumber:
+		if _, ok := new(big.Rat).SetString(t.value); !ok {
+			return nil, fmt.Errorf("%w: %s is not a valid number", ErrInvalidExpression, t.value)
+		}
+
+		return &literalNode{value: value{kind: numberValue, s: t.value}}, nil
+	case tokenString:
+		return &literalNode{value: value{kind: stringValue, s: t.value}}, nil
+	case tokenPath:
+		switch t.value {
+		case "true", "false":
+			return &literalNode{value: value{kind: boolValue, b: t.value == "true"}}, nil
+		case "null":
+			return &literalNode{value: value{kind: nullValue}}, nil
+		default:
+			return &pathNode{path: t.value}, nil
+		}
+	case tokenEOF:
+		return nil, fmt.Errorf("%w: unexpected end of expression", ErrInvalidExpression)
+	default:
+		return nil, fmt.Errorf("%w: unexpected %s", ErrInvalidExpression, t.value)
+	}
+}
+
+// Expression is a compiled boolean expression that is
+// evaluated against a JSON document. Paths (like
+// amount.value or operations.#) are resolved using
+// gjson syntax (https://github.com/tidwall/gjson) and
+// evaluate to null if they do not exist.
+//
+// Numbers (and strings containing numbers, like
+// amount values) are compared numerically.
+type Expression struct {
+	source string
+	root   node
+}
+
+// Compile parses source into an *Expression.
+func Compile(source string) (*Expression, error) {
+	tokens, err := lex(source)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", err, source)
+	}
+
+	p := &parser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", err, source)
+	}
+
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, fmt.Errorf("%w: unexpected %s: %s", ErrInvalidExpression, t.value, source)
+	}
+
+	return &Expression{source: source, root: root}, nil
+}
+
+// Evaluate returns the result of evaluating the
+// expression against document.
+func (e *Expression) Evaluate(document gjson.Result) (bool, error) {
+	result, err := evaluateBool(e.root, document)
+	if err != nil {
+		return false, fmt.Errorf("%w: %s", err, e.source)
+	}
+
+	return result, nil
+}
+
+// String returns the source of the expression.
+func (e *Expression) String() string {
+	return e.source
+}
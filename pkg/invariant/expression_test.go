@@ -0,0 +1,132 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package invariant
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/gjson"
+)
+
+func TestExpression(t *testing.T) {
+	document := gjson.Parse(`{
+		"type": "FEE",
+		"status": "SUCCESS",
+		"amount": {"value": "-100", "currency": {"symbol": "BTC", "decimals": 8}},
+		"related_operations": [{"index": 0}, {"index": 1}],
+		"metadata": {"memo": true}
+	}`)
+
+	var tests = map[string]struct {
+		expression string
+
+		expected    bool
+		compileErr  error
+		evaluateErr error
+	}{
+		"string equality": {
+			expression: `type == "FEE"`,
+			expected:   true,
+		},
+		"string inequality": {
+			expression: `type != "FEE"`,
+			expected:   false,
+		},
+		"numeric string comparison": {
+			expression: "amount.value < 0",
+			expected:   true,
+		},
+		"large numbers": {
+			expression: "amount.value > -100000000000000000000000000000",
+			expected:   true,
+		},
+		"number comparison": {
+			expression: "amount.currency.decimals >= 8",
+			expected:   true,
+		},
+		"array length": {
+			expression: "related_operations.# == 2",
+			expected:   true,
+		},
+		"missing path": {
+			expression: "account == null",
+			expected:   true,
+		},
+		"boolean path": {
+			expression: "metadata.memo && !(status == \"FAILURE\")",
+			expected:   true,
+		},
+		"logical precedence": {
+			expression: `type == "TRANSFER" || type == "FEE" && amount.value < 0`,
+			expected:   true,
+		},
+		"short circuit": {
+			expression: "account != null && account.address > 0",
+			expected:   false,
+		},
+		"invalid ordering": {
+			expression:  `type < "GAS"`,
+			evaluateErr: ErrEvaluationFailed,
+		},
+		"not a boolean": {
+			expression:  "amount.value",
+			evaluateErr: ErrEvaluationFailed,
+		},
+		"missing operand": {
+			expression: "amount.value <",
+			compileErr: ErrInvalidExpression,
+		},
+		"missing paren": {
+			expression: "(amount.value < 0",
+			compileErr: ErrInvalidExpression,
+		},
+		"unterminated string": {
+			expression: `type == "FEE`,
+			compileErr: ErrInvalidExpression,
+		},
+		"unexpected character": {
+			expression: "amount.value < 0 + 1",
+			compileErr: ErrInvalidExpression,
+		},
+		"trailing tokens": {
+			expression: "amount.value < 0 0",
+			compileErr: ErrInvalidExpression,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			expression, err := Compile(test.expression)
+			if test.compileErr != nil {
+				assert.True(t, errors.Is(err, test.compileErr))
+				assert.Nil(t, expression)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expression, expression.String())
+
+			result, err := expression.Evaluate(document)
+			if test.evaluateErr != nil {
+				assert.True(t, errors.Is(err, test.evaluateErr))
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, result)
+		})
+	}
+}
@@ -0,0 +1,201 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package invariant
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/tidwall/gjson"
+)
+
+// Scope is the type of object an
+// invariant is evaluated against.
+type Scope string
+
+const (
+	// BlockScope invariants are evaluated
+	// against each *types.Block.
+	BlockScope Scope = "block"
+
+	// TransactionScope invariants are evaluated
+	// against each *types.Transaction.
+	TransactionScope Scope = "transaction"
+
+	// OperationScope invariants are evaluated
+	// against each *types.Operation.
+	OperationScope Scope = "operation"
+)
+
+// ErrInvalidScope is returned when an
+// invariant has an unsupported scope.
+var ErrInvalidScope = errors.New("invalid invariant scope")
+
+// Invariant is an assertion that must hold
+// for every object in its scope.
+type Invariant struct {
+	Name  string
+	Scope Scope
+
+	// condition is optional. If populated, the
+	// assertion is only evaluated against objects
+	// where the condition is true.
+	condition *Expression
+	assertion *Expression
+}
+
+// New compiles the provided expressions
+// into an *Invariant.
+func New(name string, scope Scope, condition string, assertion string) (*Invariant, error) {
+	switch scope {
+	case BlockScope, TransactionScope, OperationScope:
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrInvalidScope, scope)
+	}
+
+	i := &Invariant{Name: name, Scope: scope}
+	if len(condition) > 0 {
+		expression, err := Compile(condition)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to compile condition of %s", err, name)
+		}
+
+		i.condition = expression
+	}
+
+	expression, err := Compile(assertion)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to compile assertion of %s", err, name)
+	}
+
+	i.assertion = expression
+
+	return i, nil
+}
+
+// holds returns a boolean indicating if the
+// invariant holds for document.
+func (i *Invariant) holds(document gjson.Result) (bool, error) {
+	if i.condition != nil {
+		applies, err := i.condition.Evaluate(document)
+		if err != nil {
+			return false, fmt.Errorf("%w: unable to evaluate condition of %s", err, i.Name)
+		}
+
+		if !applies {
+			return true, nil
+		}
+	}
+
+	holds, err := i.assertion.Evaluate(document)
+	if err != nil {
+		return false, fmt.Errorf("%w: unable to evaluate assertion of %s", err, i.Name)
+	}
+
+	return holds, nil
+}
+
+// Violation describes an object that did
+// not satisfy an invariant. Transaction and
+// Operation are only populated for invariants
+// with the corresponding scope.
+type Violation struct {
+	Invariant   string                       `json:"invariant"`
+	Assertion   string                       `json:"assertion"`
+	Block       *types.BlockIdentifier       `json:"block_identifier"`
+	Transaction *types.TransactionIdentifier `json:"transaction_identifier,omitempty"`
+	Operation   *types.OperationIdentifier   `json:"operation_identifier,omitempty"`
+}
+
+// String returns a human-readable description
+// of the violation.
+func (v *Violation) String() string {
+	location := fmt.Sprintf("block %s:%d", v.Block.Hash, v.Block.Index)
+	if v.Transaction != nil {
+		location = fmt.Sprintf("%s transaction %s", location, v.Transaction.Hash)
+	}
+
+	if v.Operation != nil {
+		location = fmt.Sprintf("%s operation %d", location, v.Operation.Index)
+	}
+
+	return fmt.Sprintf("%s violated by %s (%s)", v.Invariant, location, v.Assertion)
+}
+
+// Check evaluates invariants against block (and all of
+// its transactions and operations) and returns all
+// violations.
+func Check(invariants []*Invariant, block *types.Block) ([]*Violation, error) {
+	encoded, err := json.Marshal(block)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to encode block", err)
+	}
+
+	document := gjson.ParseBytes(encoded)
+	transactions := document.Get("transactions").Array()
+	violations := []*Violation{}
+	check := func(
+		i *Invariant,
+		document gjson.Result,
+		tx *types.TransactionIdentifier,
+		op *types.OperationIdentifier,
+	) error {
+		holds, err := i.holds(document)
+		if err != nil {
+			return err
+		}
+
+		if !holds {
+			violations = append(violations, &Violation{
+				Invariant:   i.Name,
+				Assertion:   i.assertion.String(),
+				Block:       block.BlockIdentifier,
+				Transaction: tx,
+				Operation:   op,
+			})
+		}
+
+		return nil
+	}
+
+	for _, i := range invariants {
+		switch i.Scope {
+		case BlockScope:
+			if err := check(i, document, nil, nil); err != nil {
+				return nil, err
+			}
+		case TransactionScope:
+			for j, tx := range block.Transactions {
+				if err := check(i, transactions[j], tx.TransactionIdentifier, nil); err != nil {
+					return nil, err
+				}
+			}
+		case OperationScope:
+			for j, tx := range block.Transactions {
+				operations := transactions[j].Get("operations").Array()
+				for k, op := range tx.Operations {
+					err := check(i, operations[k], tx.TransactionIdentifier, op.OperationIdentifier)
+					if err != nil {
+						return nil, err
+					}
+				}
+			}
+		}
+	}
+
+	return violations, nil
+}
@@ -0,0 +1,163 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package invariant
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	blockIdentifier = &types.BlockIdentifier{Hash: "block 1", Index: 1}
+	tx1             = &types.TransactionIdentifier{Hash: "tx1"}
+	tx2             = &types.TransactionIdentifier{Hash: "tx2"}
+
+	block = &types.Block{
+		BlockIdentifier:       blockIdentifier,
+		ParentBlockIdentifier: &types.BlockIdentifier{Hash: "block 0", Index: 0},
+		Transactions: []*types.Transaction{
+			{
+				TransactionIdentifier: tx1,
+				Operations: []*types.Operation{
+					{
+						OperationIdentifier: &types.OperationIdentifier{Index: 0},
+						Type:                "FEE",
+						Amount: &types.Amount{
+							Value:    "-10",
+							Currency: &types.Currency{Symbol: "BTC", Decimals: 8},
+						},
+					},
+					{
+						OperationIdentifier: &types.OperationIdentifier{Index: 1},
+						Type:                "TRANSFER",
+						Amount: &types.Amount{
+							Value:    "10",
+							Currency: &types.Currency{Symbol: "BTC", Decimals: 8},
+						},
+					},
+				},
+			},
+			{
+				TransactionIdentifier: tx2,
+				Operations: []*types.Operation{
+					{
+						OperationIdentifier: &types.OperationIdentifier{Index: 0},
+						Type:                "FEE",
+						Amount: &types.Amount{
+							Value:    "10",
+							Currency: &types.Currency{Symbol: "BTC", Decimals: 8},
+						},
+					},
+				},
+			},
+		},
+	}
+)
+
+func TestNew(t *testing.T) {
+	_, err := New("fees", "account", "", "amount.value < 0")
+	assert.True(t, errors.Is(err, ErrInvalidScope))
+
+	_, err = New("fees", OperationScope, "type ==", "amount.value < 0")
+	assert.True(t, errors.Is(err, ErrInvalidExpression))
+
+	_, err = New("fees", OperationScope, `type == "FEE"`, "amount.value <")
+	assert.True(t, errors.Is(err, ErrInvalidExpression))
+}
+
+func TestCheck(t *testing.T) {
+	var tests = map[string]struct {
+		scope     Scope
+		condition string
+		assertion string
+
+		expected []*Violation
+		err      bool
+	}{
+		"block holds": {
+			scope:     BlockScope,
+			assertion: "block_identifier.index > parent_block_identifier.index",
+			expected:  []*Violation{},
+		},
+		"block violated": {
+			scope:     BlockScope,
+			assertion: "transactions.# < 2",
+			expected: []*Violation{
+				{
+					Invariant: "test",
+					Assertion: "transactions.# < 2",
+					Block:     blockIdentifier,
+				},
+			},
+		},
+		"transaction violated": {
+			scope:     TransactionScope,
+			assertion: "operations.# > 1",
+			expected: []*Violation{
+				{
+					Invariant:   "test",
+					Assertion:   "operations.# > 1",
+					Block:       blockIdentifier,
+					Transaction: tx2,
+				},
+			},
+		},
+		"operation holds": {
+			scope:     OperationScope,
+			condition: `type == "TRANSFER"`,
+			assertion: "amount.value > 0",
+			expected:  []*Violation{},
+		},
+		"operation violated": {
+			scope:     OperationScope,
+			condition: `type == "FEE"`,
+			assertion: "amount.value < 0",
+			expected: []*Violation{
+				{
+					Invariant:   "test",
+					Assertion:   "amount.value < 0",
+					Block:       blockIdentifier,
+					Transaction: tx2,
+					Operation:   &types.OperationIdentifier{Index: 0},
+				},
+			},
+		},
+		"evaluation error": {
+			scope:     OperationScope,
+			assertion: "type > 0",
+			err:       true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			i, err := New("test", test.scope, test.condition, test.assertion)
+			assert.NoError(t, err)
+
+			violations, err := Check([]*Invariant{i}, block)
+			if test.err {
+				assert.Error(t, err)
+				assert.Nil(t, violations)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, violations)
+		})
+	}
+}
@@ -0,0 +1,210 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package keyfile encodes the keys stored by check:construction
+// in formats that can be imported by wallets (and decodes keys
+// generated by wallets so they can be stored).
+package keyfile
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/coinbase/rosetta-sdk-go/keys"
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+const (
+	// HexFormat is a JSON array of accounts
+	// and their hex-encoded private keys.
+	HexFormat = "hex"
+
+	// WIFFormat is a text file with one account
+	// address and WIF-encoded private key per line
+	// (only secp256k1 keys are supported).
+	WIFFormat = "wif"
+
+	// KeystoreFormat is an encrypted Web3 Secret
+	// Storage keystore (one per account).
+	KeystoreFormat = "keystore"
+
+	// wifLineFields is the number of fields in
+	// each line of a file in WIFFormat.
+	wifLineFields = 2
+)
+
+var (
+	// ErrUnsupportedCurve is returned when a key cannot
+	// be encoded in a format because of its curve.
+	ErrUnsupportedCurve = errors.New("unsupported curve")
+)
+
+// Key is the private key of an account.
+type Key struct {
+	Account *types.AccountIdentifier
+	KeyPair *keys.KeyPair
+}
+
+// hexKey is the encoding of a Key in HexFormat.
+type hexKey struct {
+	Account    *types.AccountIdentifier `json:"account_identifier"`
+	CurveType  types.CurveType          `json:"curve_type"`
+	PrivateKey string                   `json:"private_key"`
+}
+
+// NewKey returns a *Key for account with
+// privateKey (deriving its public key).
+func NewKey(
+	account *types.AccountIdentifier,
+	privateKey []byte,
+	curve types.CurveType,
+) (*Key, error) {
+	keyPair, err := keys.ImportPrivateKey(hex.EncodeToString(privateKey), curve)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"%w: unable to import private key of %s",
+			err,
+			types.AccountString(account),
+		)
+	}
+
+	return &Key{Account: account, KeyPair: keyPair}, nil
+}
+
+// EncodeHex encodes keys in HexFormat.
+func EncodeHex(keys []*Key) ([]byte, error) {
+	encoded := make([]*hexKey, len(keys))
+	for i, key := range keys {
+		encoded[i] = &hexKey{
+			Account:    key.Account,
+			CurveType:  key.KeyPair.PublicKey.CurveType,
+			PrivateKey: hex.EncodeToString(key.KeyPair.PrivateKey),
+		}
+	}
+
+	return json.MarshalIndent(encoded, "", " ")
+}
+
+// DecodeHex decodes keys encoded in HexFormat.
+func DecodeHex(data []byte) ([]*Key, error) {
+	var encoded []*hexKey
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return nil, fmt.Errorf("%w: unable to decode keys", err)
+	}
+
+	decoded := make([]*Key, len(encoded))
+	for i, e := range encoded {
+		if e.Account == nil {
+			return nil, fmt.Errorf("key %d is missing an account identifier", i)
+		}
+
+		privateKey, err := hex.DecodeString(e.PrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to decode private key %d", err, i)
+		}
+
+		key, err := NewKey(e.Account, privateKey, e.CurveType)
+		if err != nil {
+			return nil, err
+		}
+
+		decoded[i] = key
+	}
+
+	return decoded, nil
+}
+
+// EncodeWIFList encodes keys in WIFFormat. Accounts with
+// a SubAccountIdentifier cannot be encoded (use HexFormat
+// instead).
+func EncodeWIFList(keys []*Key, mainnet bool) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, key := range keys {
+		if key.KeyPair.PublicKey.CurveType != types.Secp256k1 {
+			return nil, fmt.Errorf(
+				"%w: %s key of %s cannot be WIF-encoded",
+				ErrUnsupportedCurve,
+				key.KeyPair.PublicKey.CurveType,
+				types.AccountString(key.Account),
+			)
+		}
+
+		if key.Account.SubAccount != nil {
+			return nil, fmt.Errorf(
+				"sub-account %s cannot be WIF-encoded",
+				types.AccountString(key.Account),
+			)
+		}
+
+		wif, err := EncodeWIF(key.KeyPair.PrivateKey, mainnet)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"%w: unable to encode key of %s",
+				err,
+				types.AccountString(key.Account),
+			)
+		}
+
+		fmt.Fprintf(&buf, "%s %s\n", key.Account.Address, wif)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// DecodeWIFList decodes keys encoded in WIFFormat.
+// Empty lines and lines starting with # are ignored.
+func DecodeWIFList(data []byte) ([]*Key, error) {
+	decoded := []*Key{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if len(text) == 0 || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Fields(text)
+		if len(fields) != wifLineFields {
+			return nil, fmt.Errorf("line %d must contain an address and a WIF", line)
+		}
+
+		privateKey, err := DecodeWIF(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to decode line %d", err, line)
+		}
+
+		key, err := NewKey(
+			&types.AccountIdentifier{Address: fields[0]},
+			privateKey,
+			types.Secp256k1,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		decoded = append(decoded, key)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%w: unable to read keys", err)
+	}
+
+	return decoded, nil
+}
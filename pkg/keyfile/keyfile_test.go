@@ -0,0 +1,174 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keyfile
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/keys"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWIF(t *testing.T) {
+	privateKey, err := hex.DecodeString(
+		"0c28fca386c7a227600b2fe50b7cae11ec86d3bf1fbe471be89827e19d72aa1d",
+	)
+	assert.NoError(t, err)
+
+	var tests = map[string]struct {
+		mainnet bool
+		wif     string
+	}{
+		"mainnet": {
+			mainnet: true,
+			wif:     "KwdMAjGmerYanjeui5SHS7JkmpZvVipYvB2LJGU1ZxJwYvP98617",
+		},
+		"testnet": {
+			wif: "cMzLdeGd5vEqxB8B6VFQoRopQ3sLAAvEzDAoQgvX54xwofSWj1fx",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			wif, err := EncodeWIF(privateKey, test.mainnet)
+			assert.NoError(t, err)
+			assert.Equal(t, test.wif, wif)
+
+			decoded, err := DecodeWIF(wif)
+			assert.NoError(t, err)
+			assert.Equal(t, privateKey, decoded)
+		})
+	}
+
+	// Uncompressed mainnet key
+	decoded, err := DecodeWIF("5HueCGU8rMjxEXxiPuD5BDku4MkFqeZyd4dZ1jvhTVqvbTLvyTJ")
+	assert.NoError(t, err)
+	assert.Equal(t, privateKey, decoded)
+
+	_, err = DecodeWIF("KwdMAjGmerYanjeui5SHS7JkmpZvVipYvB2LJGU1ZxJwYvP98618")
+	assert.True(t, errors.Is(err, ErrInvalidWIF))
+
+	_, err = DecodeWIF("0OIl")
+	assert.True(t, errors.Is(err, ErrInvalidWIF))
+}
+
+func TestKeystore(t *testing.T) {
+	privateKey, err := hex.DecodeString(
+		"7a28b5ba57c53603b0b07b56bba752f7784bf506fa95edc395f5cf6c7514fe9d",
+	)
+	assert.NoError(t, err)
+
+	// Use a small N so the test runs quickly.
+	encrypted, err := EncryptKeystore(
+		privateKey,
+		"0x008AeEda4D805471dF9b2A5B0f38A0C3bCBA786b",
+		"testpassword",
+		1<<10,
+		StandardScryptP,
+	)
+	assert.NoError(t, err)
+
+	decrypted, address, err := DecryptKeystore(encrypted, "testpassword")
+	assert.NoError(t, err)
+	assert.Equal(t, privateKey, decrypted)
+	assert.Equal(t, "008aeeda4d805471df9b2a5b0f38a0c3bcba786b", address)
+
+	_, _, err = DecryptKeystore(encrypted, "wrongpassword")
+	assert.True(t, errors.Is(err, ErrInvalidPassphrase))
+
+	_, _, err = DecryptKeystore([]byte(`{"version":1}`), "testpassword")
+	assert.True(t, errors.Is(err, ErrInvalidKeystore))
+
+	// A short iv must be rejected before it
+	// is used (instead of panicking).
+	var keystore Keystore
+	assert.NoError(t, json.Unmarshal(encrypted, &keystore))
+	keystore.Crypto.CipherParams.IV = "0011"
+	shortIV, err := json.Marshal(keystore)
+	assert.NoError(t, err)
+
+	_, _, err = DecryptKeystore(shortIV, "testpassword")
+	assert.True(t, errors.Is(err, ErrInvalidKeystore))
+}
+
+func TestKeystorePBKDF2(t *testing.T) {
+	// Test vector from the Web3 Secret Storage Definition
+	keystore := []byte(`{
+		"crypto" : {
+			"cipher" : "aes-128-ctr",
+			"cipherparams" : {"iv" : "6087dab2f9fdbbfaddc31a909735c1e6"},
+			"ciphertext" : "5318b4d5bcd28de64ee5559e671353e16f075ecae9f99c7a79a38af5f869aa46",
+			"kdf" : "pbkdf2",
+			"kdfparams" : {
+				"c" : 262144,
+				"dklen" : 32,
+				"prf" : "hmac-sha256",
+				"salt" : "ae3cd4e7013836a3df6bd7241b12db061dbe2c6785853cce422d148a624ce0bd"
+			},
+			"mac" : "517ead924a9d0dc3124507e3393d175ce3ff7c1e96529c6c555ce9e51205e9b2"
+		},
+		"id" : "3198bc9c-6672-5ab3-d995-4942343ae5b6",
+		"version" : 3
+	}`)
+
+	decrypted, _, err := DecryptKeystore(keystore, "testpassword")
+	assert.NoError(t, err)
+	assert.Equal(
+		t,
+		"7a28b5ba57c53603b0b07b56bba752f7784bf506fa95edc395f5cf6c7514fe9d",
+		hex.EncodeToString(decrypted),
+	)
+}
+
+func TestEncodeDecodeKeys(t *testing.T) {
+	keyPair, err := keys.GenerateKeypair(types.Secp256k1)
+	assert.NoError(t, err)
+	edwardsKeyPair, err := keys.GenerateKeypair(types.Edwards25519)
+	assert.NoError(t, err)
+
+	secpKey := &Key{Account: &types.AccountIdentifier{Address: "addr1"}, KeyPair: keyPair}
+	edwardsKey := &Key{
+		Account: &types.AccountIdentifier{
+			Address:    "addr2",
+			SubAccount: &types.SubAccountIdentifier{Address: "sub"},
+		},
+		KeyPair: edwardsKeyPair,
+	}
+
+	t.Run("hex", func(t *testing.T) {
+		encoded, err := EncodeHex([]*Key{secpKey, edwardsKey})
+		assert.NoError(t, err)
+
+		decoded, err := DecodeHex(encoded)
+		assert.NoError(t, err)
+		assert.Equal(t, []*Key{secpKey, edwardsKey}, decoded)
+	})
+
+	t.Run("wif", func(t *testing.T) {
+		encoded, err := EncodeWIFList([]*Key{secpKey}, false)
+		assert.NoError(t, err)
+
+		decoded, err := DecodeWIFList(append([]byte("# comment\n\n"), encoded...))
+		assert.NoError(t, err)
+		assert.Equal(t, []*Key{secpKey}, decoded)
+
+		_, err = EncodeWIFList([]*Key{edwardsKey}, false)
+		assert.True(t, errors.Is(err, ErrUnsupportedCurve))
+	})
+}
@@ -0,0 +1,326 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keyfile
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/crypto/sha3"
+)
+
+const (
+	// StandardScryptN is the scrypt N parameter used
+	// by most wallets when encrypting keystores.
+	StandardScryptN = 1 << 18
+
+	// StandardScryptP is the scrypt P parameter used
+	// by most wallets when encrypting keystores.
+	StandardScryptP = 1
+
+	keystoreVersion = 3
+	keystoreCipher  = "aes-128-ctr"
+	scryptKDF       = "scrypt"
+	pbkdf2KDF       = "pbkdf2"
+	scryptR         = 8
+	derivedKeyLen   = 32
+	saltLength      = 32
+	uuidLength      = 16
+
+	// UUID version (4) and variant (RFC 4122) bits
+	uuidVersionMask = 0x0f
+	uuidVersion     = 0x40
+	uuidVariantMask = 0x3f
+	uuidVariant     = 0x80
+
+	// The derived key is split into the encryption
+	// key and the key used to compute the MAC.
+	encryptionKeyLength = 16
+)
+
+var (
+	// ErrInvalidKeystore is returned when a keystore
+	// cannot be decoded.
+	ErrInvalidKeystore = errors.New("invalid keystore")
+
+	// ErrInvalidPassphrase is returned when a keystore
+	// cannot be decrypted with the provided passphrase.
+	ErrInvalidPassphrase = errors.New("invalid passphrase")
+)
+
+// Keystore is an encrypted private key in the Web3 Secret
+// Storage Definition (version 3) format, which can be imported
+// by most Ethereum wallets.
+type Keystore struct {
+	Address string         `json:"address,omitempty"`
+	Crypto  KeystoreCrypto `json:"crypto"`
+	ID      string         `json:"id"`
+	Version int            `json:"version"`
+}
+
+// KeystoreCrypto contains the encrypted private key
+// and the parameters used to encrypt it.
+type KeystoreCrypto struct {
+	Cipher       string                 `json:"cipher"`
+	CipherText   string                 `json:"ciphertext"`
+	CipherParams KeystoreCipherParams   `json:"cipherparams"`
+	KDF          string                 `json:"kdf"`
+	KDFParams    map[string]interface{} `json:"kdfparams"`
+	MAC          string                 `json:"mac"`
+}
+
+// KeystoreCipherParams contains the initialization
+// vector of the cipher.
+type KeystoreCipherParams struct {
+	IV string `json:"iv"`
+}
+
+func randomBytes(n int) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return nil, fmt.Errorf("%w: unable to read random bytes", err)
+	}
+
+	return b, nil
+}
+
+// newUUID returns a random (version 4) UUID.
+func newUUID() (string, error) {
+	u, err := randomBytes(uuidLength)
+	if err != nil {
+		return "", err
+	}
+
+	u[6] = (u[6] & uuidVersionMask) | uuidVersion
+	u[8] = (u[8] & uuidVariantMask) | uuidVariant
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:]), nil
+}
+
+func keccak256(data ...[]byte) []byte {
+	hash := sha3.NewLegacyKeccak256()
+	for _, d := range data {
+		_, _ = hash.Write(d)
+	}
+
+	return hash.Sum(nil)
+}
+
+// aesCTR encrypts (or decrypts) input with key and iv.
+func aesCTR(key []byte, iv []byte, input []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to create cipher", err)
+	}
+
+	output := make([]byte, len(input))
+	cipher.NewCTR(block, iv).XORKeyStream(output, input)
+	return output, nil
+}
+
+// EncryptKeystore encrypts privateKey with passphrase using
+// scrypt with the parameters n and p. The address is included
+// in the keystore (without any 0x prefix) if it is not empty.
+func EncryptKeystore(
+	privateKey []byte,
+	address string,
+	passphrase string,
+	n int,
+	p int,
+) ([]byte, error) {
+	salt, err := randomBytes(saltLength)
+	if err != nil {
+		return nil, err
+	}
+
+	derivedKey, err := scrypt.Key([]byte(passphrase), salt, n, scryptR, p, derivedKeyLen)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to derive key", err)
+	}
+
+	iv, err := randomBytes(aes.BlockSize)
+	if err != nil {
+		return nil, err
+	}
+
+	cipherText, err := aesCTR(derivedKey[:encryptionKeyLength], iv, privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := newUUID()
+	if err != nil {
+		return nil, err
+	}
+
+	keystore := &Keystore{
+		Address: strings.ToLower(strings.TrimPrefix(address, "0x")),
+		Crypto: KeystoreCrypto{
+			Cipher:       keystoreCipher,
+			CipherText:   hex.EncodeToString(cipherText),
+			CipherParams: KeystoreCipherParams{IV: hex.EncodeToString(iv)},
+			KDF:          scryptKDF,
+			KDFParams: map[string]interface{}{
+				"dklen": derivedKeyLen,
+				"n":     n,
+				"p":     p,
+				"r":     scryptR,
+				"salt":  hex.EncodeToString(salt),
+			},
+			MAC: hex.EncodeToString(keccak256(derivedKey[encryptionKeyLength:derivedKeyLen], cipherText)),
+		},
+		ID:      id,
+		Version: keystoreVersion,
+	}
+
+	return json.MarshalIndent(keystore, "", " ")
+}
+
+// kdfInt returns the integer parameter name of
+// the key derivation function.
+func kdfInt(params map[string]interface{}, name string) (int, error) {
+	value, ok := params[name].(float64)
+	if !ok {
+		return 0, fmt.Errorf("%w: missing kdf parameter %s", ErrInvalidKeystore, name)
+	}
+
+	return int(value), nil
+}
+
+// deriveKey derives the decryption key of
+// keystore from passphrase.
+func deriveKey(keystore *Keystore, passphrase string) ([]byte, error) {
+	params := keystore.Crypto.KDFParams
+	saltHex, _ := params["salt"].(string)
+	salt, err := hex.DecodeString(saltHex)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid salt", ErrInvalidKeystore)
+	}
+
+	dkLen, err := kdfInt(params, "dklen")
+	if err != nil {
+		return nil, err
+	}
+
+	switch keystore.Crypto.KDF {
+	case scryptKDF:
+		n, err := kdfInt(params, "n")
+		if err != nil {
+			return nil, err
+		}
+
+		r, err := kdfInt(params, "r")
+		if err != nil {
+			return nil, err
+		}
+
+		p, err := kdfInt(params, "p")
+		if err != nil {
+			return nil, err
+		}
+
+		return scrypt.Key([]byte(passphrase), salt, n, r, p, dkLen)
+	case pbkdf2KDF:
+		if prf, _ := params["prf"].(string); prf != "hmac-sha256" {
+			return nil, fmt.Errorf("%w: unsupported prf %s", ErrInvalidKeystore, prf)
+		}
+
+		c, err := kdfInt(params, "c")
+		if err != nil {
+			return nil, err
+		}
+
+		return pbkdf2.Key([]byte(passphrase), salt, c, dkLen, sha256.New), nil
+	default:
+		return nil, fmt.Errorf("%w: unsupported kdf %s", ErrInvalidKeystore, keystore.Crypto.KDF)
+	}
+}
+
+// DecryptKeystore decrypts the private key in the
+// JSON-encoded keystore data with passphrase and returns
+// it with the address in the keystore (if any).
+func DecryptKeystore(data []byte, passphrase string) ([]byte, string, error) {
+	var keystore Keystore
+	if err := json.Unmarshal(data, &keystore); err != nil {
+		return nil, "", fmt.Errorf("%w: %s", ErrInvalidKeystore, err.Error())
+	}
+
+	if keystore.Version != keystoreVersion {
+		return nil, "", fmt.Errorf("%w: unsupported version %d", ErrInvalidKeystore, keystore.Version)
+	}
+
+	if keystore.Crypto.Cipher != keystoreCipher {
+		return nil, "", fmt.Errorf(
+			"%w: unsupported cipher %s",
+			ErrInvalidKeystore,
+			keystore.Crypto.Cipher,
+		)
+	}
+
+	cipherText, err := hex.DecodeString(keystore.Crypto.CipherText)
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: invalid ciphertext", ErrInvalidKeystore)
+	}
+
+	iv, err := hex.DecodeString(keystore.Crypto.CipherParams.IV)
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: invalid iv", ErrInvalidKeystore)
+	}
+
+	if len(iv) != aes.BlockSize {
+		return nil, "", fmt.Errorf(
+			"%w: iv must be %d bytes (got %d)",
+			ErrInvalidKeystore,
+			aes.BlockSize,
+			len(iv),
+		)
+	}
+
+	mac, err := hex.DecodeString(keystore.Crypto.MAC)
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: invalid mac", ErrInvalidKeystore)
+	}
+
+	derivedKey, err := deriveKey(&keystore, passphrase)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if len(derivedKey) < derivedKeyLen {
+		return nil, "", fmt.Errorf("%w: derived key is too short", ErrInvalidKeystore)
+	}
+
+	expectedMAC := keccak256(derivedKey[encryptionKeyLength:derivedKeyLen], cipherText)
+	if subtle.ConstantTimeCompare(expectedMAC, mac) != 1 {
+		return nil, "", ErrInvalidPassphrase
+	}
+
+	privateKey, err := aesCTR(derivedKey[:encryptionKeyLength], iv, cipherText)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return privateKey, keystore.Address, nil
+}
@@ -0,0 +1,168 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keyfile
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+const (
+	// wifMainnetVersion is the version byte of
+	// WIF-encoded keys used on Bitcoin mainnet.
+	wifMainnetVersion = 0x80
+
+	// wifTestnetVersion is the version byte of
+	// WIF-encoded keys used on Bitcoin testnets.
+	wifTestnetVersion = 0xef
+
+	// wifCompressedSuffix is appended to WIF-encoded
+	// keys whose public key is compressed.
+	wifCompressedSuffix = 0x01
+
+	// privateKeyLength is the length of a
+	// secp256k1 private key.
+	privateKeyLength = 32
+
+	checksumLength = 4
+
+	base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+)
+
+var (
+	// ErrInvalidWIF is returned when a WIF-encoded
+	// key cannot be decoded.
+	ErrInvalidWIF = errors.New("invalid WIF")
+)
+
+// checksum returns the first 4 bytes of the
+// double SHA256 hash of payload.
+func checksum(payload []byte) []byte {
+	first := sha256.Sum256(payload)
+	second := sha256.Sum256(first[:])
+	return second[:checksumLength]
+}
+
+// base58Encode encodes input with the Bitcoin
+// base58 alphabet.
+func base58Encode(input []byte) string {
+	n := new(big.Int).SetBytes(input)
+	radix := big.NewInt(int64(len(base58Alphabet)))
+	mod := new(big.Int)
+
+	encoded := []byte{}
+	for n.Sign() > 0 {
+		n.DivMod(n, radix, mod)
+		encoded = append(encoded, base58Alphabet[mod.Int64()])
+	}
+
+	// Leading zero bytes are encoded as
+	// the first character of the alphabet.
+	for _, b := range input {
+		if b != 0 {
+			break
+		}
+
+		encoded = append(encoded, base58Alphabet[0])
+	}
+
+	for i, j := 0, len(encoded)-1; i < j; i, j = i+1, j-1 {
+		encoded[i], encoded[j] = encoded[j], encoded[i]
+	}
+
+	return string(encoded)
+}
+
+// base58Decode decodes input encoded with the
+// Bitcoin base58 alphabet.
+func base58Decode(input string) ([]byte, error) {
+	n := new(big.Int)
+	radix := big.NewInt(int64(len(base58Alphabet)))
+	for _, c := range input {
+		index := bytes.IndexRune([]byte(base58Alphabet), c)
+		if index < 0 {
+			return nil, fmt.Errorf("%w: invalid character %q", ErrInvalidWIF, c)
+		}
+
+		n.Mul(n, radix)
+		n.Add(n, big.NewInt(int64(index)))
+	}
+
+	leadingZeros := 0
+	for leadingZeros < len(input) && input[leadingZeros] == base58Alphabet[0] {
+		leadingZeros++
+	}
+
+	return append(make([]byte, leadingZeros), n.Bytes()...), nil
+}
+
+// EncodeWIF encodes a secp256k1 private key in the Wallet
+// Import Format (for a compressed public key). If mainnet
+// is false, the Bitcoin testnet version byte is used.
+func EncodeWIF(privateKey []byte, mainnet bool) (string, error) {
+	if len(privateKey) != privateKeyLength {
+		return "", fmt.Errorf(
+			"private key is %d bytes but must be %d bytes",
+			len(privateKey),
+			privateKeyLength,
+		)
+	}
+
+	version := byte(wifTestnetVersion)
+	if mainnet {
+		version = wifMainnetVersion
+	}
+
+	payload := append([]byte{version}, privateKey...)
+	payload = append(payload, wifCompressedSuffix)
+	return base58Encode(append(payload, checksum(payload)...)), nil
+}
+
+// DecodeWIF returns the private key encoded in wif. Both
+// mainnet and testnet keys (for compressed and uncompressed
+// public keys) are supported.
+func DecodeWIF(wif string) ([]byte, error) {
+	decoded, err := base58Decode(wif)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(decoded) < 1+privateKeyLength+checksumLength {
+		return nil, fmt.Errorf("%w: too short", ErrInvalidWIF)
+	}
+
+	payload := decoded[:len(decoded)-checksumLength]
+	if !bytes.Equal(checksum(payload), decoded[len(decoded)-checksumLength:]) {
+		return nil, fmt.Errorf("%w: checksum mismatch", ErrInvalidWIF)
+	}
+
+	if payload[0] != wifMainnetVersion && payload[0] != wifTestnetVersion {
+		return nil, fmt.Errorf("%w: unsupported version %x", ErrInvalidWIF, payload[0])
+	}
+
+	key := payload[1:]
+	switch {
+	case len(key) == privateKeyLength:
+	case len(key) == privateKeyLength+1 && key[privateKeyLength] == wifCompressedSuffix:
+		key = key[:privateKeyLength]
+	default:
+		return nil, fmt.Errorf("%w: invalid length", ErrInvalidWIF)
+	}
+
+	return key, nil
+}
@@ -0,0 +1,216 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logger
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/coinbase/rosetta-cli/configuration"
+)
+
+const (
+	// timestampLength is the length of the timestamp
+	// prepended to each line by log.LstdFlags.
+	timestampLength = len("2006/01/02 15:04:05 ")
+)
+
+// severities orders all log levels.
+var severities = map[configuration.LogLevel]int{
+	configuration.DebugLogLevel: 0,
+	configuration.InfoLogLevel:  1,
+	configuration.WarnLogLevel:  2,
+	configuration.ErrorLogLevel: 3,
+}
+
+// errorWords are the words that indicate a message
+// logged without a level is an error.
+var errorWords = []string{"error", "fail", "unable"}
+
+// moduleFiles attributes files whose names contain a
+// fragment to a module (before moduleDirs are matched).
+var moduleFiles = map[string]configuration.LogModule{
+	"broadcast": configuration.BroadcastLogModule,
+	"reconcil":  configuration.ReconcilerLogModule,
+}
+
+// moduleDirs attributes all files in a
+// package directory to a module.
+var moduleDirs = map[string]configuration.LogModule{
+	"syncer":         configuration.SyncerLogModule,
+	"statefulsyncer": configuration.SyncerLogModule,
+	"storage":        configuration.StorageLogModule,
+	"reconciler":     configuration.ReconcilerLogModule,
+	"fetcher":        configuration.FetcherLogModule,
+	"retry":          configuration.FetcherLogModule,
+}
+
+var (
+	levels      = &configuration.LoggingConfiguration{Level: configuration.InfoLogLevel}
+	levelsMutex sync.RWMutex
+
+	// leveled logs messages with a level (without
+	// attributing them to a file).
+	leveled = log.New(os.Stderr, "", log.LstdFlags)
+)
+
+// ConfigureLevels sets the minimum level of messages logged
+// overall and by each module. Messages logged with the log package
+// are filtered by the module of the file that logged them.
+func ConfigureLevels(config *configuration.LoggingConfiguration) {
+	levelsMutex.Lock()
+	defer levelsMutex.Unlock()
+
+	levels = &configuration.LoggingConfiguration{
+		Level:   configuration.InfoLogLevel,
+		Modules: map[configuration.LogModule]configuration.LogLevel{},
+	}
+	if config != nil {
+		if len(config.Level) > 0 {
+			levels.Level = config.Level
+		}
+
+		for module, level := range config.Modules {
+			levels.Modules[module] = level
+		}
+	}
+
+	log.SetFlags(log.LstdFlags | log.Llongfile)
+	log.SetOutput(&levelFilter{out: os.Stderr})
+}
+
+// Enabled returns a boolean indicating if messages
+// of level logged by module are logged. module may
+// be empty (for messages not attributed to a module).
+func Enabled(module configuration.LogModule, level configuration.LogLevel) bool {
+	levelsMutex.RLock()
+	defer levelsMutex.RUnlock()
+
+	minimum, ok := levels.Modules[module]
+	if !ok {
+		minimum = levels.Level
+	}
+
+	return severities[level] >= severities[minimum]
+}
+
+// logf logs a message of level by module (if enabled).
+func logf(
+	module configuration.LogModule,
+	level configuration.LogLevel,
+	format string,
+	args ...interface{},
+) {
+	if !Enabled(module, level) {
+		return
+	}
+
+	leveled.Printf(
+		"%s %s: %s",
+		strings.ToUpper(string(level)),
+		module,
+		fmt.Sprintf(format, args...),
+	)
+}
+
+// Debugf logs a debug message by module.
+func Debugf(module configuration.LogModule, format string, args ...interface{}) {
+	logf(module, configuration.DebugLogLevel, format, args...)
+}
+
+// Infof logs an info message by module.
+func Infof(module configuration.LogModule, format string, args ...interface{}) {
+	logf(module, configuration.InfoLogLevel, format, args...)
+}
+
+// Warnf logs a warning by module.
+func Warnf(module configuration.LogModule, format string, args ...interface{}) {
+	logf(module, configuration.WarnLogLevel, format, args...)
+}
+
+// Errorf logs an error by module.
+func Errorf(module configuration.LogModule, format string, args ...interface{}) {
+	logf(module, configuration.ErrorLogLevel, format, args...)
+}
+
+// moduleOf returns the module the file
+// at path is attributed to (if any).
+func moduleOf(path string) configuration.LogModule {
+	for fragment, module := range moduleFiles {
+		if strings.Contains(filepath.Base(path), fragment) {
+			return module
+		}
+	}
+
+	return moduleDirs[filepath.Base(filepath.Dir(path))]
+}
+
+// levelOf returns the level of a
+// message logged without a level.
+func levelOf(message []byte) configuration.LogLevel {
+	lower := bytes.ToLower(message)
+	for _, word := range errorWords {
+		if bytes.Contains(lower, []byte(word)) {
+			return configuration.ErrorLogLevel
+		}
+	}
+
+	return configuration.InfoLogLevel
+}
+
+// levelFilter is the output of the log package. Each line
+// is prefixed with a timestamp and the file that logged it
+// (log.LstdFlags | log.Llongfile), which is used to attribute
+// the line to a module and removed before it is written.
+type levelFilter struct {
+	out io.Writer
+}
+
+func (f *levelFilter) Write(p []byte) (int, error) {
+	if len(p) < timestampLength {
+		return f.out.Write(p)
+	}
+
+	// The file is followed by ":<line>: ".
+	caller := p[timestampLength:]
+	fileEnd := bytes.Index(caller, []byte(".go:"))
+	if fileEnd < 0 {
+		return f.out.Write(p)
+	}
+
+	messageStart := bytes.Index(caller[fileEnd:], []byte(": "))
+	if messageStart < 0 {
+		return f.out.Write(p)
+	}
+
+	message := caller[fileEnd+messageStart+len(": "):]
+	module := moduleOf(string(caller[:fileEnd]))
+	if !Enabled(module, levelOf(message)) {
+		return len(p), nil
+	}
+
+	line := append(append([]byte{}, p[:timestampLength]...), message...)
+	if _, err := f.out.Write(line); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
@@ -0,0 +1,105 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logger
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"testing"
+
+	"github.com/coinbase/rosetta-cli/configuration"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLevelFilter(t *testing.T) {
+	ConfigureLevels(&configuration.LoggingConfiguration{
+		Level: configuration.WarnLogLevel,
+		Modules: map[configuration.LogModule]configuration.LogLevel{
+			configuration.ReconcilerLogModule: configuration.DebugLogLevel,
+			configuration.SyncerLogModule:     configuration.ErrorLogLevel,
+		},
+	})
+	defer func() {
+		ConfigureLevels(nil)
+		log.SetFlags(log.LstdFlags)
+		log.SetOutput(os.Stderr)
+	}()
+
+	const timestamp = "2020/11/01 12:00:00 "
+	var tests = map[string]struct {
+		line string
+
+		expected string
+	}{
+		"reconciler info": {
+			line:     timestamp + "/go/rosetta-sdk-go/reconciler/reconciler.go:12: reconciled\n",
+			expected: timestamp + "reconciled\n",
+		},
+		"reconciler file": {
+			line:     timestamp + "/go/rosetta-cli/pkg/processor/reconciler_handler.go:1: done\n",
+			expected: timestamp + "done\n",
+		},
+		"syncer info": {
+			line: timestamp + "/go/rosetta-sdk-go/syncer/syncer.go:40: synced block 10\n",
+		},
+		"syncer error": {
+			line:     timestamp + "/go/rosetta-sdk-go/syncer/syncer.go:40: unable to sync\n",
+			expected: timestamp + "unable to sync\n",
+		},
+		"default info": {
+			line: timestamp + "/go/rosetta-cli/cmd/root.go:10: loaded\n",
+		},
+		"default error": {
+			line:     timestamp + "/go/rosetta-cli/cmd/root.go:10: error: invalid\n",
+			expected: timestamp + "error: invalid\n",
+		},
+		"no caller": {
+			line:     timestamp + "no caller\n",
+			expected: timestamp + "no caller\n",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			f := &levelFilter{out: &buf}
+
+			n, err := f.Write([]byte(test.line))
+			assert.NoError(t, err)
+			assert.Equal(t, len(test.line), n)
+			assert.Equal(t, test.expected, buf.String())
+		})
+	}
+}
+
+func TestEnabled(t *testing.T) {
+	ConfigureLevels(&configuration.LoggingConfiguration{
+		Modules: map[configuration.LogModule]configuration.LogLevel{
+			configuration.FetcherLogModule: configuration.DebugLogLevel,
+		},
+	})
+	defer func() {
+		ConfigureLevels(nil)
+		log.SetFlags(log.LstdFlags)
+		log.SetOutput(os.Stderr)
+	}()
+
+	assert.True(t, Enabled(configuration.FetcherLogModule, configuration.DebugLogLevel))
+	assert.False(t, Enabled(configuration.StorageLogModule, configuration.DebugLogLevel))
+	assert.True(t, Enabled(configuration.StorageLogModule, configuration.InfoLogLevel))
+	assert.True(t, Enabled("", configuration.ErrorLogLevel))
+}
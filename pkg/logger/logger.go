@@ -16,11 +16,11 @@ package logger
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
-	"log"
-	"os"
-	"path"
 
+	"github.com/coinbase/rosetta-cli/configuration"
+	"github.com/coinbase/rosetta-cli/pkg/provenance"
 	"github.com/coinbase/rosetta-cli/pkg/results"
 
 	"github.com/coinbase/rosetta-sdk-go/parser"
@@ -46,11 +46,19 @@ const (
 	// balance changes.
 	balanceStreamFile = "balance_changes.txt"
 
+	// balanceOperationStreamFile contains the stream of
+	// balance-changing operations (one JSON object per line).
+	balanceOperationStreamFile = "balance_operations.jsonl"
+
 	// reconcileSuccessStreamFile contains the stream of processed
 	// reconciliations.
 	reconcileSuccessStreamFile = "successful_reconciliations.txt"
 	reconcileFailureStreamFile = "failure_reconciliations.txt"
 
+	// reconciliationStreamFile contains the stream of all
+	// reconciliation attempts (one JSON object per line).
+	reconciliationStreamFile = "reconciliations.jsonl"
+
 	// addEvent is printed in a stream
 	// when an event is added.
 	addEvent = "Add"
@@ -62,6 +70,10 @@ const (
 
 // Logger contains all logic to record validator output
 // and benchmark a Rosetta Server.
+//
+// All streams are written to files by a background
+// writer with a bounded queue, so Close must be called
+// to ensure all queued entries are written to disk.
 type Logger struct {
 	logDir            string
 	logBlocks         bool
@@ -69,6 +81,13 @@ type Logger struct {
 	logBalanceChanges bool
 	logReconciliation bool
 
+	// logReconciliationStream determines if all
+	// reconciliation attempts are written to the
+	// reconciliationStreamFile.
+	logReconciliationStream bool
+
+	writer *streamWriter
+
 	lastStatsMessage    string
 	lastProgressMessage string
 }
@@ -80,14 +99,31 @@ func NewLogger(
 	logTransactions bool,
 	logBalanceChanges bool,
 	logReconciliation bool,
+	logReconciliationStream bool,
 ) *Logger {
 	return &Logger{
-		logDir:            logDir,
-		logBlocks:         logBlocks,
-		logTransactions:   logTransactions,
-		logBalanceChanges: logBalanceChanges,
-		logReconciliation: logReconciliation,
+		logDir:                  logDir,
+		logBlocks:               logBlocks,
+		logTransactions:         logTransactions,
+		logBalanceChanges:       logBalanceChanges,
+		logReconciliation:       logReconciliation,
+		logReconciliationStream: logReconciliationStream,
+		writer:                  newStreamWriter(logDir, streamQueueSize),
+	}
+}
+
+// StampRun configures the Logger to write run (as a line
+// of JSON) to each stream file before the first line written
+// to it by this run. It must be called before any streams
+// are written.
+func (l *Logger) StampRun(run *provenance.Run) error {
+	header, err := run.Header()
+	if err != nil {
+		return err
 	}
+
+	l.writer.header = header
+	return nil
 }
 
 // LogDataStatus logs results.CheckDataStatus.
@@ -140,6 +176,22 @@ func (l *Logger) LogDataStatus(ctx context.Context, status *results.CheckDataSta
 
 	l.lastProgressMessage = progressMessage
 	color.Cyan(progressMessage)
+
+	// Throughput is nil until enough samples
+	// have been recorded.
+	if status.Throughput == nil {
+		return
+	}
+
+	color.Cyan(
+		"[THROUGHPUT] Blocks: %f/second Operations: %f/second (Last %s) Index: %d/%d Time to Tip: %s", // nolint:lll
+		status.Throughput.BlockRate,
+		status.Throughput.OperationRate,
+		status.Throughput.Window,
+		status.Throughput.Index,
+		status.Throughput.Tip,
+		status.Throughput.TimeRemaining,
+	)
 }
 
 // LogConstructionStatus logs results.CheckConstructionStatus.
@@ -188,17 +240,6 @@ func (l *Logger) AddBlockStream(
 		return nil
 	}
 
-	f, err := os.OpenFile(
-		path.Join(l.logDir, blockStreamFile),
-		os.O_APPEND|os.O_CREATE|os.O_WRONLY,
-		os.FileMode(utils.DefaultFilePermissions),
-	)
-	if err != nil {
-		return err
-	}
-
-	defer closeFile(f)
-
 	blockString := fmt.Sprintf(
 		"%s Block %d:%s with Parent Block %d:%s\n",
 		addEvent,
@@ -207,8 +248,10 @@ func (l *Logger) AddBlockStream(
 		block.ParentBlockIdentifier.Index,
 		block.ParentBlockIdentifier.Hash,
 	)
-	fmt.Print(blockString)
-	if _, err := f.WriteString(blockString); err != nil {
+	if Enabled(configuration.SyncerLogModule, configuration.InfoLogLevel) {
+		fmt.Print(blockString)
+	}
+	if err := l.writer.Write(ctx, blockStreamFile, []string{blockString}); err != nil {
 		return err
 	}
 
@@ -225,26 +268,16 @@ func (l *Logger) RemoveBlockStream(
 		return nil
 	}
 
-	f, err := os.OpenFile(
-		path.Join(l.logDir, blockStreamFile),
-		os.O_APPEND|os.O_CREATE|os.O_WRONLY,
-		os.FileMode(utils.DefaultFilePermissions),
-	)
-	if err != nil {
-		return err
-	}
-
-	defer closeFile(f)
-
 	blockString := fmt.Sprintf(
 		"%s Block %d:%s\n",
 		removeEvent,
 		block.Index,
 		block.Hash,
 	)
-	fmt.Print(blockString)
-	_, err = f.WriteString(blockString)
-	return err
+	if Enabled(configuration.SyncerLogModule, configuration.InfoLogLevel) {
+		fmt.Print(blockString)
+	}
+	return l.writer.Write(ctx, blockStreamFile, []string{blockString})
 }
 
 // TransactionStream writes the next processed block's transactions
@@ -257,27 +290,14 @@ func (l *Logger) TransactionStream(
 		return nil
 	}
 
-	f, err := os.OpenFile(
-		path.Join(l.logDir, transactionStreamFile),
-		os.O_APPEND|os.O_CREATE|os.O_WRONLY,
-		os.FileMode(utils.DefaultFilePermissions),
-	)
-	if err != nil {
-		return err
-	}
-
-	defer closeFile(f)
-
+	lines := []string{}
 	for _, tx := range block.Transactions {
-		_, err = f.WriteString(fmt.Sprintf(
+		lines = append(lines, fmt.Sprintf(
 			"Transaction %s at Block %d:%s\n",
 			tx.TransactionIdentifier.Hash,
 			block.BlockIdentifier.Index,
 			block.BlockIdentifier.Hash,
 		))
-		if err != nil {
-			return err
-		}
 
 		for _, op := range tx.Operations {
 			amount := ""
@@ -296,7 +316,7 @@ func (l *Logger) TransactionStream(
 				networkIndex = *op.OperationIdentifier.NetworkIndex
 			}
 
-			_, err = f.WriteString(fmt.Sprintf(
+			lines = append(lines, fmt.Sprintf(
 				"TxOp %d(%d) %s %s %s %s %s\n",
 				op.OperationIdentifier.Index,
 				networkIndex,
@@ -306,13 +326,10 @@ func (l *Logger) TransactionStream(
 				symbol,
 				*op.Status,
 			))
-			if err != nil {
-				return err
-			}
 		}
 	}
 
-	return nil
+	return l.writer.Write(ctx, transactionStreamFile, lines)
 }
 
 // BalanceStream writes a slice of storage.BalanceChanges
@@ -325,32 +342,72 @@ func (l *Logger) BalanceStream(
 		return nil
 	}
 
-	f, err := os.OpenFile(
-		path.Join(l.logDir, balanceStreamFile),
-		os.O_APPEND|os.O_CREATE|os.O_WRONLY,
-		os.FileMode(utils.DefaultFilePermissions),
-	)
-	if err != nil {
-		return err
-	}
-
-	defer closeFile(f)
-
-	for _, balanceChange := range balanceChanges {
-		balanceLog := fmt.Sprintf(
-			"Account: %s Change: %s:%s Block: %d:%s",
+	lines := make([]string, len(balanceChanges))
+	for i, balanceChange := range balanceChanges {
+		lines[i] = fmt.Sprintf(
+			"Account: %s Change: %s:%s Block: %d:%s\n",
 			balanceChange.Account.Address,
 			balanceChange.Difference,
 			types.CurrencyString(balanceChange.Currency),
 			balanceChange.Block.Index,
 			balanceChange.Block.Hash,
 		)
+	}
+
+	return l.writer.Write(ctx, balanceStreamFile, lines)
+}
+
+// BalanceOperation is a balance-changing operation and the
+// computed balance of its account after it was applied.
+type BalanceOperation struct {
+	// Event is "Add" when the block of the operation
+	// was added and "Remove" when it was orphaned. It is
+	// populated by BalanceOperationStream.
+	Event          string                       `json:"event"`
+	Block          *types.BlockIdentifier       `json:"block_identifier"`
+	Transaction    *types.TransactionIdentifier `json:"transaction_identifier"`
+	OperationIndex int64                        `json:"operation_index"`
+	NetworkIndex   *int64                       `json:"network_index,omitempty"`
+	Type           string                       `json:"type"`
+	Account        *types.AccountIdentifier     `json:"account_identifier"`
+	Currency       *types.Currency              `json:"currency"`
+	Difference     string                       `json:"difference"`
+
+	// Balance is the computed balance of the account after
+	// the operation was applied (omitted if the balance of
+	// the account could not be looked up).
+	Balance string `json:"balance,omitempty"`
+}
+
+// BalanceOperationStream writes each *BalanceOperation as a
+// line of JSON to the balanceOperationStreamFile. The event
+// of each operation is populated with removed.
+func (l *Logger) BalanceOperationStream(
+	ctx context.Context,
+	removed bool,
+	operations []*BalanceOperation,
+) error {
+	if !l.logBalanceChanges {
+		return nil
+	}
 
-		if _, err := f.WriteString(fmt.Sprintf("%s\n", balanceLog)); err != nil {
-			return err
+	event := addEvent
+	if removed {
+		event = removeEvent
+	}
+
+	lines := make([]string, len(operations))
+	for i, operation := range operations {
+		operation.Event = event
+		line, err := json.Marshal(operation)
+		if err != nil {
+			return fmt.Errorf("%w: unable to encode balance operation", err)
 		}
+
+		lines[i] = string(line) + "\n"
 	}
-	return nil
+
+	return l.writer.Write(ctx, balanceOperationStreamFile, lines)
 }
 
 // ReconcileSuccessStream logs all reconciliation checks performed
@@ -367,25 +424,15 @@ func (l *Logger) ReconcileSuccessStream(
 		return nil
 	}
 
-	f, err := os.OpenFile(
-		path.Join(l.logDir, reconcileSuccessStreamFile),
-		os.O_APPEND|os.O_CREATE|os.O_WRONLY,
-		os.FileMode(utils.DefaultFilePermissions),
-	)
-	if err != nil {
-		return err
-	}
-
-	defer closeFile(f)
-
-	log.Printf(
-		"%s Reconciled %s at %d\n",
+	Infof(
+		configuration.ReconcilerLogModule,
+		"%s Reconciled %s at %d",
 		reconciliationType,
 		types.AccountString(account),
 		block.Index,
 	)
 
-	_, err = f.WriteString(fmt.Sprintf(
+	return l.writer.Write(ctx, reconcileSuccessStreamFile, []string{fmt.Sprintf(
 		"Type:%s Account: %s Currency: %s Balance: %s Block: %d:%s\n",
 		reconciliationType,
 		types.AccountString(account),
@@ -393,12 +440,7 @@ func (l *Logger) ReconcileSuccessStream(
 		balance,
 		block.Index,
 		block.Hash,
-	))
-	if err != nil {
-		return err
-	}
-
-	return nil
+	)})
 }
 
 // ReconcileFailureStream logs all reconciliation checks performed
@@ -412,8 +454,11 @@ func (l *Logger) ReconcileFailureStream(
 	liveBalance string,
 	block *types.BlockIdentifier,
 ) error {
-	// Always print out reconciliation failures
-	if reconciliationType == reconciler.InactiveReconciliation {
+	// Print out reconciliation failures unless
+	// reconciler warnings are not logged
+	switch {
+	case !Enabled(configuration.ReconcilerLogModule, configuration.WarnLogLevel):
+	case reconciliationType == reconciler.InactiveReconciliation:
 		color.Yellow(
 			"Missing balance-changing operation detected for %s computed: %s%s live: %s%s",
 			types.AccountString(account),
@@ -422,7 +467,7 @@ func (l *Logger) ReconcileFailureStream(
 			liveBalance,
 			currency.Symbol,
 		)
-	} else {
+	default:
 		color.Yellow(
 			"Reconciliation failed for %s at %d computed: %s%s live: %s%s",
 			types.AccountString(account),
@@ -438,18 +483,7 @@ func (l *Logger) ReconcileFailureStream(
 		return nil
 	}
 
-	f, err := os.OpenFile(
-		path.Join(l.logDir, reconcileFailureStreamFile),
-		os.O_APPEND|os.O_CREATE|os.O_WRONLY,
-		os.FileMode(utils.DefaultFilePermissions),
-	)
-	if err != nil {
-		return err
-	}
-
-	defer closeFile(f)
-
-	_, err = f.WriteString(fmt.Sprintf(
+	return l.writer.Write(ctx, reconcileFailureStreamFile, []string{fmt.Sprintf(
 		"Type:%s Account: %s Currency: %s Block: %s:%d computed: %s live: %s\n",
 		reconciliationType,
 		types.AccountString(account),
@@ -458,20 +492,32 @@ func (l *Logger) ReconcileFailureStream(
 		block.Index,
 		computedBalance,
 		liveBalance,
-	))
+	)})
+}
+
+// ReconciliationStream writes reconciliation as a line
+// of JSON to the reconciliationStreamFile.
+func (l *Logger) ReconciliationStream(
+	ctx context.Context,
+	reconciliation *results.ReconciliationStatus,
+) error {
+	if !l.logReconciliationStream {
+		return nil
+	}
+
+	line, err := json.Marshal(reconciliation)
 	if err != nil {
-		return err
+		return fmt.Errorf("%w: unable to encode reconciliation", err)
 	}
 
-	return nil
+	return l.writer.Write(ctx, reconciliationStreamFile, []string{string(line) + "\n"})
 }
 
-// Helper function to close log file
-func closeFile(f *os.File) {
-	err := f.Close()
-	if err != nil {
-		log.Fatal(fmt.Errorf("%w: unable to close file", err))
-	}
+// Close writes all queued stream entries to disk and
+// closes all stream files. Streams cannot be written
+// after Close is called.
+func (l *Logger) Close() error {
+	return l.writer.Close()
 }
 
 // LogTransactionCreated logs the hash of created
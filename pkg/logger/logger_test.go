@@ -0,0 +1,88 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logger
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/coinbase/rosetta-cli/pkg/results"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/coinbase/rosetta-sdk-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReconciliationStream(t *testing.T) {
+	ctx := context.Background()
+	reconciliation := &results.ReconciliationStatus{
+		Type:            "ACTIVE",
+		Result:          "failure",
+		Account:         &types.AccountIdentifier{Address: "addr1"},
+		Currency:        &types.Currency{Symbol: "BTC", Decimals: 8},
+		Block:           &types.BlockIdentifier{Hash: "block 10", Index: 10},
+		Timestamp:       1604188800,
+		ComputedBalance: "100",
+		LiveBalance:     "90",
+	}
+
+	t.Run("enabled", func(t *testing.T) {
+		dir, err := utils.CreateTempDir()
+		assert.NoError(t, err)
+		defer utils.RemoveTempDir(dir)
+
+		l := NewLogger(dir, false, false, false, false, true)
+		assert.NoError(t, l.ReconciliationStream(ctx, reconciliation))
+		assert.NoError(t, l.ReconciliationStream(ctx, &results.ReconciliationStatus{
+			Type:      "INACTIVE",
+			Result:    "skipped",
+			Account:   &types.AccountIdentifier{Address: "addr2"},
+			Currency:  &types.Currency{Symbol: "BTC", Decimals: 8},
+			Timestamp: 1604188801,
+			Cause:     "head behind",
+		}))
+		assert.NoError(t, l.Close())
+
+		stream, err := ioutil.ReadFile(path.Join(dir, reconciliationStreamFile))
+		assert.NoError(t, err)
+		assert.Equal(
+			t,
+			`{"type":"ACTIVE","result":"failure","account":{"address":"addr1"},`+
+				`"currency":{"symbol":"BTC","decimals":8},`+
+				`"block":{"index":10,"hash":"block 10"},"timestamp":1604188800,`+
+				`"computed_balance":"100","live_balance":"90"}`+"\n"+
+				`{"type":"INACTIVE","result":"skipped","account":{"address":"addr2"},`+
+				`"currency":{"symbol":"BTC","decimals":8},"block":null,`+
+				`"timestamp":1604188801,"cause":"head behind"}`+"\n",
+			string(stream),
+		)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		dir, err := utils.CreateTempDir()
+		assert.NoError(t, err)
+		defer utils.RemoveTempDir(dir)
+
+		l := NewLogger(dir, false, false, false, true, false)
+		assert.NoError(t, l.ReconciliationStream(ctx, reconciliation))
+		assert.NoError(t, l.Close())
+
+		_, err = os.Stat(path.Join(dir, reconciliationStreamFile))
+		assert.True(t, os.IsNotExist(err))
+	})
+}
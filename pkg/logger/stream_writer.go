@@ -0,0 +1,188 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/coinbase/rosetta-sdk-go/utils"
+)
+
+const (
+	// streamQueueSize is the maximum number of stream writes
+	// that can be queued before callers block.
+	streamQueueSize = 1024
+)
+
+var (
+	// ErrLoggerClosed is returned when a stream is
+	// written after the *Logger is closed.
+	ErrLoggerClosed = errors.New("logger is closed")
+)
+
+// streamEntry is a collection of lines
+// to append to a stream file.
+type streamEntry struct {
+	file  string
+	lines []string
+}
+
+// streamWriter appends lines to stream files in a
+// background goroutine so that disk I/O does not block
+// syncing. If the queue is full, callers block until
+// space is available (backpressure).
+type streamWriter struct {
+	logDir string
+	queue  chan *streamEntry
+	done   chan struct{}
+
+	// files is only accessed by the
+	// background goroutine.
+	files map[string]*os.File
+
+	// header is written to each stream file
+	// before the first line written to it.
+	header string
+
+	start sync.Once
+
+	// closedMutex ensures no entries are
+	// queued after queue is closed.
+	closedMutex sync.RWMutex
+	closed      bool
+
+	errMutex sync.Mutex
+	err      error
+}
+
+func newStreamWriter(logDir string, queueSize int) *streamWriter {
+	return &streamWriter{
+		logDir: logDir,
+		queue:  make(chan *streamEntry, queueSize),
+		done:   make(chan struct{}),
+		files:  map[string]*os.File{},
+	}
+}
+
+// Write queues lines to be appended to file. Because
+// writes occur in the background, an error returned
+// by Write may be from an earlier write. Once a write
+// fails, all subsequent writes are dropped.
+func (w *streamWriter) Write(ctx context.Context, file string, lines []string) error {
+	if err := w.Err(); err != nil {
+		return err
+	}
+
+	w.closedMutex.RLock()
+	defer w.closedMutex.RUnlock()
+
+	if w.closed {
+		return ErrLoggerClosed
+	}
+
+	w.start.Do(func() { go w.run() })
+
+	select {
+	case w.queue <- &streamEntry{file: file, lines: lines}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close writes all queued lines and closes all
+// stream files. It returns the first write error
+// encountered (if any).
+func (w *streamWriter) Close() error {
+	w.closedMutex.Lock()
+	if !w.closed {
+		w.closed = true
+		w.start.Do(func() { go w.run() })
+		close(w.queue)
+	}
+	w.closedMutex.Unlock()
+
+	<-w.done
+	return w.Err()
+}
+
+// Err returns the first error encountered
+// while writing (if any).
+func (w *streamWriter) Err() error {
+	w.errMutex.Lock()
+	defer w.errMutex.Unlock()
+
+	return w.err
+}
+
+func (w *streamWriter) setErr(err error) {
+	w.errMutex.Lock()
+	defer w.errMutex.Unlock()
+
+	if w.err == nil {
+		w.err = err
+	}
+}
+
+func (w *streamWriter) run() {
+	defer close(w.done)
+
+	for entry := range w.queue {
+		if w.Err() != nil {
+			continue
+		}
+
+		if err := w.write(entry); err != nil {
+			w.setErr(fmt.Errorf("%w: unable to write to %s", err, entry.file))
+		}
+	}
+
+	for file, f := range w.files {
+		if err := f.Close(); err != nil {
+			w.setErr(fmt.Errorf("%w: unable to close %s", err, file))
+		}
+	}
+}
+
+func (w *streamWriter) write(entry *streamEntry) error {
+	f, ok := w.files[entry.file]
+	if !ok {
+		var err error
+		f, err = os.OpenFile(
+			path.Join(w.logDir, entry.file),
+			os.O_APPEND|os.O_CREATE|os.O_WRONLY,
+			os.FileMode(utils.DefaultFilePermissions),
+		)
+		if err != nil {
+			return err
+		}
+
+		w.files[entry.file] = f
+		if len(w.header) > 0 {
+			if _, err := f.WriteString(w.header); err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err := f.WriteString(strings.Join(entry.lines, ""))
+	return err
+}
@@ -0,0 +1,115 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStreamWriter(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("flush on close", func(t *testing.T) {
+		dir, err := utils.CreateTempDir()
+		assert.NoError(t, err)
+		defer utils.RemoveTempDir(dir)
+
+		// A queue size of 1 ensures writers
+		// block while the queue is full.
+		w := newStreamWriter(dir, 1)
+		expected := ""
+		for i := 0; i < 100; i++ {
+			line := fmt.Sprintf("line %d\n", i)
+			assert.NoError(t, w.Write(ctx, "a.txt", []string{line}))
+			expected += line
+		}
+		assert.NoError(t, w.Write(ctx, "b.txt", []string{"b1\n", "b2\n"}))
+		assert.NoError(t, w.Close())
+
+		a, err := ioutil.ReadFile(path.Join(dir, "a.txt"))
+		assert.NoError(t, err)
+		assert.Equal(t, expected, string(a))
+
+		b, err := ioutil.ReadFile(path.Join(dir, "b.txt"))
+		assert.NoError(t, err)
+		assert.Equal(t, "b1\nb2\n", string(b))
+
+		// Writes after close are rejected.
+		assert.True(t, errors.Is(w.Write(ctx, "a.txt", []string{"late\n"}), ErrLoggerClosed))
+		assert.NoError(t, w.Close())
+	})
+
+	t.Run("header", func(t *testing.T) {
+		dir, err := utils.CreateTempDir()
+		assert.NoError(t, err)
+		defer utils.RemoveTempDir(dir)
+
+		// The header is written once per run (so each
+		// run appended to a stream is stamped).
+		for _, run := range []string{"run 1", "run 2"} {
+			w := newStreamWriter(dir, 1)
+			w.header = run + "\n"
+			assert.NoError(t, w.Write(ctx, "a.txt", []string{"a1\n"}))
+			assert.NoError(t, w.Write(ctx, "a.txt", []string{"a2\n"}))
+			assert.NoError(t, w.Close())
+		}
+
+		a, err := ioutil.ReadFile(path.Join(dir, "a.txt"))
+		assert.NoError(t, err)
+		assert.Equal(t, "run 1\na1\na2\nrun 2\na1\na2\n", string(a))
+	})
+
+	t.Run("close without writes", func(t *testing.T) {
+		w := newStreamWriter("", 1)
+		assert.NoError(t, w.Close())
+	})
+
+	t.Run("write error", func(t *testing.T) {
+		w := newStreamWriter("/path/does/not/exist", 1)
+		assert.NoError(t, w.Write(ctx, "a.txt", []string{"line\n"}))
+
+		err := w.Close()
+		assert.Error(t, err)
+		assert.True(t, strings.Contains(err.Error(), "a.txt"))
+
+		// The write error is returned on subsequent writes.
+		assert.Equal(t, err, w.Write(ctx, "a.txt", []string{"line\n"}))
+	})
+
+	t.Run("canceled context", func(t *testing.T) {
+		dir, err := utils.CreateTempDir()
+		assert.NoError(t, err)
+		defer utils.RemoveTempDir(dir)
+
+		w := newStreamWriter(dir, 0)
+		canceledCtx, cancel := context.WithCancel(ctx)
+		cancel()
+
+		// The background writer may receive the entry
+		// before the canceled context is observed.
+		err = w.Write(canceledCtx, "a.txt", []string{"line\n"})
+		assert.True(t, err == nil || errors.Is(err, context.Canceled))
+		assert.NoError(t, w.Close())
+	})
+}
@@ -0,0 +1,138 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package metrics emits check:data metrics to a
+// statsd (or DogStatsD) agent.
+package metrics
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/coinbase/rosetta-cli/pkg/results"
+)
+
+const (
+	gaugeType  = "g"
+	countType  = "c"
+	timingType = "ms"
+)
+
+// Client sends metrics to a statsd agent over UDP using
+// the DogStatsD format (tags are appended to each metric
+// with "|#"). Metrics are sent on a best-effort basis, so
+// errors sending a metric are ignored.
+type Client struct {
+	conn   net.Conn
+	prefix string
+	tags   string
+}
+
+// New returns a new *Client that sends metrics to the
+// agent at host:port. The name of each metric is prefixed
+// with prefix (followed by a ".") and tagged with tags.
+func New(host string, port uint, prefix string, tags []string) (*Client, error) {
+	conn, err := net.Dial("udp", net.JoinHostPort(host, strconv.FormatUint(uint64(port), 10)))
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to connect to statsd agent", err)
+	}
+
+	return &Client{
+		conn:   conn,
+		prefix: prefix,
+		tags:   strings.Join(tags, ","),
+	}, nil
+}
+
+// send writes a single metric to the agent.
+func (c *Client) send(name string, value string, metricType string) {
+	if len(c.prefix) > 0 {
+		name = c.prefix + "." + name
+	}
+
+	metric := fmt.Sprintf("%s:%s|%s", name, value, metricType)
+	if len(c.tags) > 0 {
+		metric = fmt.Sprintf("%s|#%s", metric, c.tags)
+	}
+
+	_, _ = c.conn.Write([]byte(metric))
+}
+
+// Gauge sets the gauge name to value.
+func (c *Client) Gauge(name string, value float64) {
+	c.send(name, strconv.FormatFloat(value, 'f', -1, 64), gaugeType)
+}
+
+// Count increments the counter name by value.
+func (c *Client) Count(name string, value int64) {
+	c.send(name, strconv.FormatInt(value, 10), countType)
+}
+
+// Timing records a duration for the timer name.
+func (c *Client) Timing(name string, value time.Duration) {
+	c.send(name, strconv.FormatInt(value.Milliseconds(), 10), timingType)
+}
+
+// ReportStatus sets a gauge for each
+// populated field in status.
+func (c *Client) ReportStatus(status *results.CheckDataStatus) {
+	if stats := status.Stats; stats != nil {
+		c.Gauge("blocks", float64(stats.Blocks))
+		c.Gauge("orphans", float64(stats.Orphans))
+		c.Gauge("transactions", float64(stats.Transactions))
+		c.Gauge("operations", float64(stats.Operations))
+		c.Gauge("reconciliations.active", float64(stats.ActiveReconciliations))
+		c.Gauge("reconciliations.inactive", float64(stats.InactiveReconciliations))
+		c.Gauge("reconciliations.exempt", float64(stats.ExemptReconciliations))
+		c.Gauge("reconciliations.failed", float64(stats.FailedReconciliations))
+		c.Gauge("reconciliations.skipped", float64(stats.SkippedReconciliations))
+		c.Gauge("reconciliations.coverage", stats.ReconciliationCoverage)
+	}
+
+	if progress := status.Progress; progress != nil {
+		c.Gauge("progress.tip", float64(progress.Tip))
+		c.Gauge("progress.completed", progress.Completed)
+		c.Gauge("progress.rate", progress.Rate)
+		c.Gauge("progress.reconciler_queue_size", float64(progress.ReconcilerQueueSize))
+	}
+
+	if reconciler := status.Reconciler; reconciler != nil {
+		c.Gauge("reconciler.queue_size", float64(reconciler.QueueSize))
+		c.Gauge("reconciler.backlog_size", float64(reconciler.BacklogSize))
+		c.Gauge("reconciler.blocked_seconds", reconciler.BlockedSeconds)
+		c.Gauge("reconciler.active_concurrency", float64(reconciler.ActiveConcurrency))
+		c.Gauge("reconciler.inactive_concurrency", float64(reconciler.InactiveConcurrency))
+	}
+
+	if throughput := status.Throughput; throughput != nil {
+		c.Gauge("throughput.block_rate", throughput.BlockRate)
+		c.Gauge("throughput.operation_rate", throughput.OperationRate)
+	}
+
+	if status.HeadBlock != nil {
+		c.Gauge("head_block", float64(status.HeadBlock.Index))
+	}
+
+	if status.TipDistance != nil {
+		c.Gauge("tip_distance", float64(*status.TipDistance))
+	}
+}
+
+// Close closes the connection to the agent.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
@@ -0,0 +1,117 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/coinbase/rosetta-cli/pkg/results"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient(t *testing.T) {
+	tipDistance := int64(3)
+	var tests = map[string]struct {
+		prefix string
+		tags   []string
+		emit   func(c *Client)
+
+		expected []string
+	}{
+		"gauge": {
+			prefix: "rosetta_cli",
+			emit: func(c *Client) {
+				c.Gauge("progress.completed", 12.5)
+			},
+			expected: []string{"rosetta_cli.progress.completed:12.5|g"},
+		},
+		"count with tags": {
+			prefix: "rosetta_cli",
+			tags:   []string{"network:testnet", "ci"},
+			emit: func(c *Client) {
+				c.Count("blocks.added", 1)
+			},
+			expected: []string{"rosetta_cli.blocks.added:1|c|#network:testnet,ci"},
+		},
+		"timing without prefix": {
+			emit: func(c *Client) {
+				c.Timing("block.sync_time", 1500*time.Millisecond)
+			},
+			expected: []string{"block.sync_time:1500|ms"},
+		},
+		"partial status": {
+			prefix: "rosetta_cli",
+			emit: func(c *Client) {
+				c.ReportStatus(&results.CheckDataStatus{
+					HeadBlock:   &types.BlockIdentifier{Hash: "block 10", Index: 10},
+					TipDistance: &tipDistance,
+				})
+			},
+			expected: []string{
+				"rosetta_cli.head_block:10|g",
+				"rosetta_cli.tip_distance:3|g",
+			},
+		},
+		"reconciler status": {
+			prefix: "rosetta_cli",
+			emit: func(c *Client) {
+				c.ReportStatus(&results.CheckDataStatus{
+					Reconciler: &results.ReconcilerStatus{
+						ActiveConcurrency:   16,
+						InactiveConcurrency: 4,
+						QueueSize:           120,
+						BacklogSize:         1000,
+						BacklogMode:         "block",
+						BlockedSeconds:      2.5,
+					},
+				})
+			},
+			expected: []string{
+				"rosetta_cli.reconciler.queue_size:120|g",
+				"rosetta_cli.reconciler.backlog_size:1000|g",
+				"rosetta_cli.reconciler.blocked_seconds:2.5|g",
+				"rosetta_cli.reconciler.active_concurrency:16|g",
+				"rosetta_cli.reconciler.inactive_concurrency:4|g",
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+			assert.NoError(t, err)
+			defer listener.Close()
+
+			addr := listener.LocalAddr().(*net.UDPAddr)
+			client, err := New("127.0.0.1", uint(addr.Port), test.prefix, test.tags)
+			assert.NoError(t, err)
+			defer client.Close()
+
+			test.emit(client)
+
+			buf := make([]byte, 1024)
+			for _, expected := range test.expected {
+				assert.NoError(t, listener.SetReadDeadline(time.Now().Add(time.Second)))
+				n, _, err := listener.ReadFrom(buf)
+				assert.NoError(t, err)
+				assert.Equal(t, expected, string(buf[:n]))
+			}
+		})
+	}
+}
@@ -0,0 +1,635 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mock
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/coinbase/rosetta-cli/pkg/processor"
+
+	"github.com/coinbase/rosetta-sdk-go/keys"
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+const (
+	// RewardOperation credits the block reward (or an
+	// initial balance in the genesis block) to an account.
+	RewardOperation = "REWARD"
+
+	// TransferOperation debits or credits an
+	// account as part of a transfer.
+	TransferOperation = "TRANSFER"
+
+	// SuccessStatus is the status of all
+	// operations included in blocks.
+	SuccessStatus = "SUCCESS"
+
+	// addressSize is the number of bytes of the SHA-256
+	// digest of a public key used as an address.
+	addressSize = 20
+)
+
+var (
+	// Currency is the only currency of the mock blockchain.
+	Currency = &types.Currency{Symbol: "MOCK", Decimals: 8}
+
+	// InitialBalance is credited to each account
+	// in the genesis block.
+	InitialBalance = big.NewInt(100000000000)
+
+	// BlockReward is credited to one account
+	// in each block.
+	BlockReward = big.NewInt(5000000000)
+
+	// TransferAmount is transferred between two
+	// accounts in each block.
+	TransferAmount = big.NewInt(100000)
+
+	// ErrInsufficientFunds is returned when a transaction
+	// debits more than the balance of an account.
+	ErrInsufficientFunds = errors.New("insufficient funds")
+
+	// ErrInvalidOperations is returned when the operations
+	// of a transaction are not a balanced set of transfers.
+	ErrInvalidOperations = errors.New("invalid operations")
+
+	// ErrTransactionNotInMempool is returned when a
+	// transaction is not in the mempool.
+	ErrTransactionNotInMempool = errors.New("transaction not in mempool")
+)
+
+// Config configures a mock blockchain.
+type Config struct {
+	// Network is the network identifier of the blockchain.
+	Network *types.NetworkIdentifier
+
+	// Accounts is the number of accounts funded
+	// in the genesis block.
+	Accounts int
+
+	// Seed is the seed the private keys of
+	// accounts are derived from.
+	Seed string
+
+	// ReorgFrequency is the number of blocks between injected
+	// reorgs. If 0, reorgs are never injected.
+	ReorgFrequency int64
+
+	// ReorgDepth is the number of blocks orphaned
+	// by each injected reorg.
+	ReorgDepth int64
+}
+
+// Account is an account funded in the genesis
+// block (with its key pair).
+type Account struct {
+	Identifier *types.AccountIdentifier
+	KeyPair    *keys.KeyPair
+}
+
+// balanceEntry is the balance of an account
+// after the block at index.
+type balanceEntry struct {
+	index   int64
+	balance *big.Int
+}
+
+// minedBlock is a canonical block and the submitted
+// transactions it includes (which are returned to the
+// mempool if it is orphaned).
+type minedBlock struct {
+	block     *types.Block
+	submitted []*types.Transaction
+}
+
+// debit is the total debited from an
+// account by a transaction.
+type debit struct {
+	account *types.AccountIdentifier
+	amount  *big.Int
+}
+
+// Chain is an in-memory synthetic blockchain. Each block credits
+// the block reward to an account, transfers a small amount
+// between two accounts, and includes all submitted transactions
+// that are still affordable.
+type Chain struct {
+	config   *Config
+	accounts []*Account
+
+	blocks   []*minedBlock
+	hashes   map[string]int64
+	balances map[string][]*balanceEntry
+	mempool  []*types.Transaction
+	reorgs   int64
+
+	mutex sync.Mutex
+}
+
+// Address returns the address of the account
+// controlled by publicKey.
+func Address(publicKey []byte) string {
+	digest := sha256.Sum256(publicKey)
+	return "0x" + hex.EncodeToString(digest[:addressSize])
+}
+
+// NewChain returns a new *Chain with a genesis
+// block created at genesisTime.
+func NewChain(config *Config, genesisTime time.Time) (*Chain, error) {
+	accounts := make([]*Account, config.Accounts)
+	for i := range accounts {
+		keyPair, err := processor.DeriveKeyPair(config.Seed, types.Secp256k1, int64(i))
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to derive account %d", err, i)
+		}
+
+		accounts[i] = &Account{
+			Identifier: &types.AccountIdentifier{Address: Address(keyPair.PublicKey.Bytes)},
+			KeyPair:    keyPair,
+		}
+	}
+
+	c := &Chain{
+		config:   config,
+		accounts: accounts,
+		hashes:   map[string]int64{},
+		balances: map[string][]*balanceEntry{},
+	}
+
+	genesis := &types.Block{
+		BlockIdentifier: &types.BlockIdentifier{
+			Hash:  blockHash("", 0, 0),
+			Index: 0,
+		},
+		Timestamp: timestamp(genesisTime),
+	}
+	genesis.ParentBlockIdentifier = genesis.BlockIdentifier
+
+	ops := make([]*types.Operation, len(accounts))
+	for i, account := range accounts {
+		ops[i] = operation(int64(i), RewardOperation, account.Identifier, InitialBalance)
+	}
+	genesis.Transactions = []*types.Transaction{
+		{
+			TransactionIdentifier: &types.TransactionIdentifier{
+				Hash: transactionHash(genesis.BlockIdentifier.Hash, 0),
+			},
+			Operations: ops,
+		},
+	}
+
+	c.apply(&minedBlock{block: genesis})
+
+	return c, nil
+}
+
+// Accounts returns the accounts funded
+// in the genesis block.
+func (c *Chain) Accounts() []*Account {
+	return c.accounts
+}
+
+func timestamp(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+func blockHash(parent string, index int64, reorgs int64) string {
+	digest := sha256.Sum256([]byte(fmt.Sprintf("%s/%d/%d", parent, index, reorgs)))
+	return hex.EncodeToString(digest[:])
+}
+
+func transactionHash(block string, index int) string {
+	digest := sha256.Sum256([]byte(fmt.Sprintf("%s/%d", block, index)))
+	return hex.EncodeToString(digest[:])
+}
+
+func operation(
+	index int64,
+	opType string,
+	account *types.AccountIdentifier,
+	amount *big.Int,
+) *types.Operation {
+	return &types.Operation{
+		OperationIdentifier: &types.OperationIdentifier{Index: index},
+		Type:                opType,
+		Status:              types.String(SuccessStatus),
+		Account:             account,
+		Amount: &types.Amount{
+			Value:    amount.String(),
+			Currency: Currency,
+		},
+	}
+}
+
+// debits returns the total debited from each
+// account by the operations of tx.
+func debits(tx *types.Transaction) map[string]*debit {
+	totals := map[string]*debit{}
+	for _, op := range tx.Operations {
+		amount, _ := new(big.Int).SetString(op.Amount.Value, 10)
+		if amount.Sign() >= 0 {
+			continue
+		}
+
+		key := types.Hash(op.Account)
+		if _, ok := totals[key]; !ok {
+			totals[key] = &debit{account: op.Account, amount: big.NewInt(0)}
+		}
+		totals[key].amount.Sub(totals[key].amount, amount)
+	}
+
+	return totals
+}
+
+// balance returns the balance of account
+// after the block at index.
+func (c *Chain) balance(account *types.AccountIdentifier, index int64) *big.Int {
+	entries := c.balances[types.Hash(account)]
+	i := sort.Search(len(entries), func(i int) bool { return entries[i].index > index })
+	if i == 0 {
+		return big.NewInt(0)
+	}
+
+	return new(big.Int).Set(entries[i-1].balance)
+}
+
+// apply adds block to the canonical chain and
+// updates the balances of all accounts it changes.
+func (c *Chain) apply(block *minedBlock) {
+	index := block.block.BlockIdentifier.Index
+	for _, tx := range block.block.Transactions {
+		for _, op := range tx.Operations {
+			amount, _ := new(big.Int).SetString(op.Amount.Value, 10)
+			balance := c.balance(op.Account, index)
+			balance.Add(balance, amount)
+
+			key := types.Hash(op.Account)
+			entries := c.balances[key]
+			if len(entries) > 0 && entries[len(entries)-1].index == index {
+				entries[len(entries)-1].balance = balance
+				continue
+			}
+
+			c.balances[key] = append(entries, &balanceEntry{index: index, balance: balance})
+		}
+	}
+
+	c.blocks = append(c.blocks, block)
+	c.hashes[block.block.BlockIdentifier.Hash] = index
+}
+
+// orphan removes the head block from the canonical chain
+// and returns its submitted transactions to the mempool.
+func (c *Chain) orphan() *types.Block {
+	head := c.blocks[len(c.blocks)-1]
+	index := head.block.BlockIdentifier.Index
+	for key, entries := range c.balances {
+		if entries[len(entries)-1].index != index {
+			continue
+		}
+
+		if len(entries) == 1 {
+			delete(c.balances, key)
+			continue
+		}
+
+		c.balances[key] = entries[:len(entries)-1]
+	}
+
+	c.blocks = c.blocks[:len(c.blocks)-1]
+	delete(c.hashes, head.block.BlockIdentifier.Hash)
+	c.mempool = append(head.submitted, c.mempool...)
+
+	return head.block
+}
+
+// affordable returns the transactions in the mempool that can
+// be afforded (in order) after the block at index, given the
+// amounts already spent by each account.
+func (c *Chain) affordable(index int64, spent map[string]*big.Int) []*types.Transaction {
+	txs := []*types.Transaction{}
+	for _, tx := range c.mempool {
+		txDebits := debits(tx)
+		ok := true
+		for key, d := range txDebits {
+			total := new(big.Int).Set(d.amount)
+			if previous, exists := spent[key]; exists {
+				total.Add(total, previous)
+			}
+
+			if c.balance(d.account, index).Cmp(total) < 0 {
+				ok = false
+				break
+			}
+		}
+
+		if !ok {
+			continue
+		}
+
+		for key, d := range txDebits {
+			if _, exists := spent[key]; !exists {
+				spent[key] = big.NewInt(0)
+			}
+			spent[key].Add(spent[key], d.amount)
+		}
+
+		txs = append(txs, tx)
+	}
+
+	return txs
+}
+
+// mine adds a block created at blockTime to the canonical chain.
+// Submitted transactions that can no longer be afforded (because
+// of a transaction included before them) are dropped.
+func (c *Chain) mine(blockTime int64) *types.Block {
+	parent := c.blocks[len(c.blocks)-1].block
+	index := parent.BlockIdentifier.Index + 1
+	block := &types.Block{
+		BlockIdentifier: &types.BlockIdentifier{
+			Hash:  blockHash(parent.BlockIdentifier.Hash, index, c.reorgs),
+			Index: index,
+		},
+		ParentBlockIdentifier: parent.BlockIdentifier,
+		Timestamp:             blockTime,
+		Transactions:          []*types.Transaction{},
+	}
+
+	ops := []*types.Operation{}
+	spent := map[string]*big.Int{}
+	n := int64(len(c.accounts))
+	if n > 0 {
+		ops = append(ops, operation(0, RewardOperation, c.accounts[index%n].Identifier, BlockReward))
+	}
+
+	if n > 1 {
+		from := c.accounts[(index+1)%n].Identifier
+		to := c.accounts[(index+2)%n].Identifier
+		if c.balance(from, parent.BlockIdentifier.Index).Cmp(TransferAmount) >= 0 {
+			ops = append(
+				ops,
+				operation(1, TransferOperation, from, new(big.Int).Neg(TransferAmount)),
+				operation(2, TransferOperation, to, TransferAmount),
+			)
+			spent[types.Hash(from)] = new(big.Int).Set(TransferAmount)
+		}
+	}
+
+	if len(ops) > 0 {
+		block.Transactions = append(block.Transactions, &types.Transaction{
+			TransactionIdentifier: &types.TransactionIdentifier{
+				Hash: transactionHash(block.BlockIdentifier.Hash, 0),
+			},
+			Operations: ops,
+		})
+	}
+
+	submitted := c.affordable(parent.BlockIdentifier.Index, spent)
+	block.Transactions = append(block.Transactions, submitted...)
+	c.mempool = nil
+
+	c.apply(&minedBlock{block: block, submitted: submitted})
+
+	return block
+}
+
+// Advance adds a block created at now to the canonical chain.
+// If a reorg is due, the last ReorgDepth blocks are orphaned
+// and replaced (with the same timestamps) first. Advance returns
+// the orphaned blocks and the added blocks.
+func (c *Chain) Advance(now time.Time) ([]*types.Block, []*types.Block) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	orphaned := []*types.Block{}
+	added := []*types.Block{}
+
+	head := c.blocks[len(c.blocks)-1].block.BlockIdentifier.Index
+	depth := c.config.ReorgDepth
+	if c.config.ReorgFrequency > 0 &&
+		depth > 0 &&
+		head > depth &&
+		head%c.config.ReorgFrequency == 0 {
+		c.reorgs++
+
+		timestamps := make([]int64, depth)
+		for i := depth - 1; i >= 0; i-- {
+			block := c.orphan()
+			orphaned = append(orphaned, block)
+			timestamps[i] = block.Timestamp
+		}
+
+		for _, blockTime := range timestamps {
+			added = append(added, c.mine(blockTime))
+		}
+	}
+
+	added = append(added, c.mine(timestamp(now)))
+
+	return orphaned, added
+}
+
+// Head returns the head block.
+func (c *Chain) Head() *types.Block {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.blocks[len(c.blocks)-1].block
+}
+
+// Genesis returns the genesis block.
+func (c *Chain) Genesis() *types.Block {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.blocks[0].block
+}
+
+// Block returns the canonical block identified by
+// identifier (or the head block if identifier is empty)
+// and a boolean indicating if it exists.
+func (c *Chain) Block(identifier *types.PartialBlockIdentifier) (*types.Block, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.block(identifier)
+}
+
+func (c *Chain) block(identifier *types.PartialBlockIdentifier) (*types.Block, bool) {
+	if identifier == nil || (identifier.Hash == nil && identifier.Index == nil) {
+		return c.blocks[len(c.blocks)-1].block, true
+	}
+
+	index := int64(-1)
+	if identifier.Index != nil {
+		index = *identifier.Index
+	}
+
+	if identifier.Hash != nil {
+		hashIndex, ok := c.hashes[*identifier.Hash]
+		if !ok || (identifier.Index != nil && hashIndex != index) {
+			return nil, false
+		}
+		index = hashIndex
+	}
+
+	if index < 0 || index >= int64(len(c.blocks)) {
+		return nil, false
+	}
+
+	return c.blocks[index].block, true
+}
+
+// Balance returns the balance of account after the block
+// identified by identifier (or the head block if identifier
+// is empty), the block, and a boolean indicating if the
+// block exists.
+func (c *Chain) Balance(
+	account *types.AccountIdentifier,
+	identifier *types.PartialBlockIdentifier,
+) (*big.Int, *types.Block, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	block, ok := c.block(identifier)
+	if !ok {
+		return nil, nil, false
+	}
+
+	return c.balance(account, block.BlockIdentifier.Index), block, true
+}
+
+// validate returns an error if the operations of tx are not a
+// balanced set of transfers that the head balances can afford.
+func (c *Chain) validate(tx *types.Transaction) error {
+	if len(tx.Operations) == 0 {
+		return fmt.Errorf("%w: no operations", ErrInvalidOperations)
+	}
+
+	sum := big.NewInt(0)
+	for _, op := range tx.Operations {
+		if op.Type != TransferOperation {
+			return fmt.Errorf("%w: %s is not supported", ErrInvalidOperations, op.Type)
+		}
+
+		if op.Account == nil ||
+			op.Amount == nil ||
+			types.Hash(op.Amount.Currency) != types.Hash(Currency) {
+			return fmt.Errorf(
+				"%w: account and %s amount are required",
+				ErrInvalidOperations,
+				Currency.Symbol,
+			)
+		}
+
+		amount, ok := new(big.Int).SetString(op.Amount.Value, 10)
+		if !ok {
+			return fmt.Errorf("%w: %s is not an integer", ErrInvalidOperations, op.Amount.Value)
+		}
+		sum.Add(sum, amount)
+	}
+
+	if sum.Sign() != 0 {
+		return fmt.Errorf("%w: operations sum to %s", ErrInvalidOperations, sum.String())
+	}
+
+	head := c.blocks[len(c.blocks)-1].block.BlockIdentifier.Index
+	for _, d := range debits(tx) {
+		if balance := c.balance(d.account, head); balance.Cmp(d.amount) < 0 {
+			return fmt.Errorf(
+				"%w: %s has %s but %s is debited",
+				ErrInsufficientFunds,
+				d.account.Address,
+				balance.String(),
+				d.amount.String(),
+			)
+		}
+	}
+
+	return nil
+}
+
+// Validate returns an error if the operations of tx are not a
+// balanced set of transfers that the head balances can afford.
+func (c *Chain) Validate(tx *types.Transaction) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.validate(tx)
+}
+
+// Submit adds tx to the mempool (it is included in the next
+// block if it is still affordable). Submitting a transaction
+// that is already in the mempool is a no-op.
+func (c *Chain) Submit(tx *types.Transaction) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, pending := range c.mempool {
+		if pending.TransactionIdentifier.Hash == tx.TransactionIdentifier.Hash {
+			return nil
+		}
+	}
+
+	if err := c.validate(tx); err != nil {
+		return err
+	}
+
+	for _, op := range tx.Operations {
+		op.Status = types.String(SuccessStatus)
+	}
+	c.mempool = append(c.mempool, tx)
+
+	return nil
+}
+
+// Mempool returns the identifiers of all
+// transactions in the mempool.
+func (c *Chain) Mempool() []*types.TransactionIdentifier {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	identifiers := make([]*types.TransactionIdentifier, len(c.mempool))
+	for i, tx := range c.mempool {
+		identifiers[i] = tx.TransactionIdentifier
+	}
+
+	return identifiers
+}
+
+// MempoolTransaction returns the transaction
+// in the mempool identified by identifier.
+func (c *Chain) MempoolTransaction(
+	identifier *types.TransactionIdentifier,
+) (*types.Transaction, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, tx := range c.mempool {
+		if tx.TransactionIdentifier.Hash == identifier.Hash {
+			return tx, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrTransactionNotInMempool, identifier.Hash)
+}
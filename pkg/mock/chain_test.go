@@ -0,0 +1,166 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mock
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	network = &types.NetworkIdentifier{Blockchain: "Mock", Network: "Testnet"}
+	genesis = time.Unix(1600000000, 0)
+)
+
+func newChain(t *testing.T, reorgFrequency int64, reorgDepth int64) *Chain {
+	chain, err := NewChain(&Config{
+		Network:        network,
+		Accounts:       3,
+		Seed:           "mock",
+		ReorgFrequency: reorgFrequency,
+		ReorgDepth:     reorgDepth,
+	}, genesis)
+	assert.NoError(t, err)
+
+	return chain
+}
+
+func balance(t *testing.T, chain *Chain, account *Account, index int64) *big.Int {
+	value, _, ok := chain.Balance(
+		account.Identifier,
+		&types.PartialBlockIdentifier{Index: &index},
+	)
+	assert.True(t, ok)
+
+	return value
+}
+
+func transfer(from *Account, to *Account, amount int64) *types.Transaction {
+	return &types.Transaction{
+		TransactionIdentifier: &types.TransactionIdentifier{Hash: "tx"},
+		Operations: []*types.Operation{
+			operation(0, TransferOperation, from.Identifier, big.NewInt(-amount)),
+			operation(1, TransferOperation, to.Identifier, big.NewInt(amount)),
+		},
+	}
+}
+
+func TestChain(t *testing.T) {
+	chain := newChain(t, 0, 0)
+	accounts := chain.Accounts()
+	assert.Len(t, accounts, 3)
+
+	// Accounts are derived deterministically from the seed.
+	other := newChain(t, 0, 0)
+	assert.Equal(t, accounts[0].Identifier, other.Accounts()[0].Identifier)
+
+	for _, account := range accounts {
+		assert.Equal(t, InitialBalance, balance(t, chain, account, 0))
+	}
+
+	_, added := chain.Advance(genesis.Add(time.Second))
+	assert.Len(t, added, 1)
+	assert.Equal(t, chain.Genesis().BlockIdentifier, added[0].ParentBlockIdentifier)
+	assert.Equal(t, added[0], chain.Head())
+
+	// Block 1 credits the reward to account 1 and
+	// transfers from account 2 to account 0.
+	assert.Equal(
+		t,
+		new(big.Int).Add(InitialBalance, TransferAmount),
+		balance(t, chain, accounts[0], 1),
+	)
+	assert.Equal(
+		t,
+		new(big.Int).Add(InitialBalance, BlockReward),
+		balance(t, chain, accounts[1], 1),
+	)
+	assert.Equal(
+		t,
+		new(big.Int).Sub(InitialBalance, TransferAmount),
+		balance(t, chain, accounts[2], 1),
+	)
+
+	// Historical balances are unchanged.
+	assert.Equal(t, InitialBalance, balance(t, chain, accounts[0], 0))
+
+	index := int64(2)
+	_, _, ok := chain.Balance(accounts[0].Identifier, &types.PartialBlockIdentifier{Index: &index})
+	assert.False(t, ok)
+}
+
+func TestChainReorg(t *testing.T) {
+	chain := newChain(t, 3, 2)
+
+	var canonical []*types.Block
+	for i := 1; i <= 3; i++ {
+		orphaned, added := chain.Advance(genesis.Add(time.Duration(i) * time.Second))
+		assert.Len(t, orphaned, 0)
+		canonical = append(canonical, added...)
+	}
+
+	orphaned, added := chain.Advance(genesis.Add(4 * time.Second))
+	assert.Equal(t, []*types.Block{canonical[2], canonical[1]}, orphaned)
+	assert.Len(t, added, 3)
+
+	// Replacement blocks have new hashes but the same timestamps.
+	for i, block := range added[:2] {
+		assert.Equal(t, canonical[i+1].BlockIdentifier.Index, block.BlockIdentifier.Index)
+		assert.NotEqual(t, canonical[i+1].BlockIdentifier.Hash, block.BlockIdentifier.Hash)
+		assert.Equal(t, canonical[i+1].Timestamp, block.Timestamp)
+	}
+	assert.Equal(t, canonical[0].BlockIdentifier, added[0].ParentBlockIdentifier)
+	assert.Equal(t, int64(4), chain.Head().BlockIdentifier.Index)
+
+	_, ok := chain.Block(&types.PartialBlockIdentifier{Hash: &canonical[1].BlockIdentifier.Hash})
+	assert.False(t, ok)
+
+	block, ok := chain.Block(&types.PartialBlockIdentifier{Hash: &added[1].BlockIdentifier.Hash})
+	assert.True(t, ok)
+	assert.Equal(t, added[1], block)
+}
+
+func TestChainSubmit(t *testing.T) {
+	chain := newChain(t, 0, 0)
+	accounts := chain.Accounts()
+
+	tx := transfer(accounts[0], accounts[1], 10)
+	assert.NoError(t, chain.Submit(tx))
+	assert.NoError(t, chain.Submit(tx))
+	assert.Equal(t, []*types.TransactionIdentifier{tx.TransactionIdentifier}, chain.Mempool())
+
+	pending, err := chain.MempoolTransaction(tx.TransactionIdentifier)
+	assert.NoError(t, err)
+	assert.Equal(t, tx, pending)
+
+	_, added := chain.Advance(genesis.Add(time.Second))
+	assert.Contains(t, added[0].Transactions, tx)
+	assert.Len(t, chain.Mempool(), 0)
+
+	_, err = chain.MempoolTransaction(tx.TransactionIdentifier)
+	assert.True(t, errors.Is(err, ErrTransactionNotInMempool))
+
+	err = chain.Submit(transfer(accounts[0], accounts[1], InitialBalance.Int64()*2))
+	assert.True(t, errors.Is(err, ErrInsufficientFunds))
+
+	unbalanced := transfer(accounts[0], accounts[1], 10)
+	unbalanced.Operations = unbalanced.Operations[:1]
+	assert.True(t, errors.Is(chain.Submit(unbalanced), ErrInvalidOperations))
+}
@@ -0,0 +1,704 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mock
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+const (
+	// rosettaVersion is the version of the
+	// Rosetta API served by the Server.
+	rosettaVersion = "1.4.10"
+
+	// nodeVersion is the version of the
+	// "node" returned by /network/options.
+	nodeVersion = "mock"
+)
+
+var (
+	// ErrInvalidRequest is returned when a request
+	// cannot be decoded.
+	ErrInvalidRequest = &types.Error{
+		Code:    1,
+		Message: "invalid request",
+	}
+
+	// ErrNetworkNotSupported is returned when a request
+	// is made for a network that is not served.
+	ErrNetworkNotSupported = &types.Error{
+		Code:    2,
+		Message: "network not supported",
+	}
+
+	// ErrBlockNotFound is returned when a block
+	// is not in the canonical chain.
+	ErrBlockNotFound = &types.Error{
+		Code:    3,
+		Message: "block not found",
+	}
+
+	// ErrInvalidTransaction is returned when a transaction
+	// cannot be decoded or its operations are invalid.
+	ErrInvalidTransaction = &types.Error{
+		Code:    4,
+		Message: "invalid transaction",
+	}
+
+	// ErrUnfundedTransaction is returned when a transaction
+	// debits more than the balance of an account.
+	ErrUnfundedTransaction = &types.Error{
+		Code:    5,
+		Message: "insufficient funds",
+	}
+
+	// ErrTransactionNotFound is returned when a transaction
+	// is not in a block or the mempool.
+	ErrTransactionNotFound = &types.Error{
+		Code:    6,
+		Message: "transaction not found",
+	}
+
+	// Errors are all errors returned by the Server.
+	Errors = []*types.Error{
+		ErrInvalidRequest,
+		ErrNetworkNotSupported,
+		ErrBlockNotFound,
+		ErrInvalidTransaction,
+		ErrUnfundedTransaction,
+		ErrTransactionNotFound,
+	}
+)
+
+// Server serves the Rosetta Data and
+// Construction APIs for a *Chain.
+type Server struct {
+	network *types.NetworkIdentifier
+	chain   *Chain
+}
+
+// NewServer returns a new *Server for chain.
+func NewServer(network *types.NetworkIdentifier, chain *Chain) *Server {
+	return &Server{
+		network: network,
+		chain:   chain,
+	}
+}
+
+// Handler returns an http.Handler that
+// serves all supported endpoints.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/network/list", s.handle(s.networkList))
+	mux.HandleFunc("/network/status", s.handle(s.networkStatus))
+	mux.HandleFunc("/network/options", s.handle(s.networkOptions))
+	mux.HandleFunc("/block", s.handle(s.block))
+	mux.HandleFunc("/block/transaction", s.handle(s.blockTransaction))
+	mux.HandleFunc("/account/balance", s.handle(s.accountBalance))
+	mux.HandleFunc("/mempool", s.handle(s.mempool))
+	mux.HandleFunc("/mempool/transaction", s.handle(s.mempoolTransaction))
+	mux.HandleFunc("/construction/derive", s.handle(s.constructionDerive))
+	mux.HandleFunc("/construction/preprocess", s.handle(s.constructionPreprocess))
+	mux.HandleFunc("/construction/metadata", s.handle(s.constructionMetadata))
+	mux.HandleFunc("/construction/payloads", s.handle(s.constructionPayloads))
+	mux.HandleFunc("/construction/combine", s.handle(s.constructionCombine))
+	mux.HandleFunc("/construction/parse", s.handle(s.constructionParse))
+	mux.HandleFunc("/construction/hash", s.handle(s.constructionHash))
+	mux.HandleFunc("/construction/submit", s.handle(s.constructionSubmit))
+
+	return mux
+}
+
+// endpoint decodes a request from body and returns
+// the response (or a *types.Error).
+type endpoint func(ctx context.Context, body *json.Decoder) (interface{}, *types.Error)
+
+// handle adapts an endpoint to an http.HandlerFunc.
+func (s *Server) handle(e endpoint) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		response, rosettaErr := e(r.Context(), json.NewDecoder(r.Body))
+		if rosettaErr != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			_ = json.NewEncoder(w).Encode(rosettaErr)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(response)
+	}
+}
+
+// wrapErr returns a copy of rosettaErr with
+// err included in the details.
+func wrapErr(rosettaErr *types.Error, err error) *types.Error {
+	wrapped := *rosettaErr
+	wrapped.Details = map[string]interface{}{"context": err.Error()}
+	return &wrapped
+}
+
+// checkNetwork returns an error if network
+// is not the network served by s.
+func (s *Server) checkNetwork(network *types.NetworkIdentifier) *types.Error {
+	if types.Hash(network) != types.Hash(s.network) {
+		return wrapErr(
+			ErrNetworkNotSupported,
+			fmt.Errorf("%s is not supported", types.PrintStruct(network)),
+		)
+	}
+
+	return nil
+}
+
+func (s *Server) networkList(
+	ctx context.Context,
+	body *json.Decoder,
+) (interface{}, *types.Error) {
+	var request types.MetadataRequest
+	if err := body.Decode(&request); err != nil {
+		return nil, wrapErr(ErrInvalidRequest, err)
+	}
+
+	return &types.NetworkListResponse{
+		NetworkIdentifiers: []*types.NetworkIdentifier{s.network},
+	}, nil
+}
+
+func (s *Server) networkStatus(
+	ctx context.Context,
+	body *json.Decoder,
+) (interface{}, *types.Error) {
+	var request types.NetworkRequest
+	if err := body.Decode(&request); err != nil {
+		return nil, wrapErr(ErrInvalidRequest, err)
+	}
+
+	if err := s.checkNetwork(request.NetworkIdentifier); err != nil {
+		return nil, err
+	}
+
+	head := s.chain.Head()
+	return &types.NetworkStatusResponse{
+		CurrentBlockIdentifier: head.BlockIdentifier,
+		CurrentBlockTimestamp:  head.Timestamp,
+		GenesisBlockIdentifier: s.chain.Genesis().BlockIdentifier,
+		Peers:                  []*types.Peer{},
+	}, nil
+}
+
+func (s *Server) networkOptions(
+	ctx context.Context,
+	body *json.Decoder,
+) (interface{}, *types.Error) {
+	var request types.NetworkRequest
+	if err := body.Decode(&request); err != nil {
+		return nil, wrapErr(ErrInvalidRequest, err)
+	}
+
+	if err := s.checkNetwork(request.NetworkIdentifier); err != nil {
+		return nil, err
+	}
+
+	return &types.NetworkOptionsResponse{
+		Version: &types.Version{
+			RosettaVersion: rosettaVersion,
+			NodeVersion:    nodeVersion,
+		},
+		Allow: &types.Allow{
+			OperationStatuses: []*types.OperationStatus{
+				{Status: SuccessStatus, Successful: true},
+			},
+			OperationTypes:          []string{RewardOperation, TransferOperation},
+			Errors:                  Errors,
+			HistoricalBalanceLookup: true,
+		},
+	}, nil
+}
+
+func (s *Server) block(
+	ctx context.Context,
+	body *json.Decoder,
+) (interface{}, *types.Error) {
+	var request types.BlockRequest
+	if err := body.Decode(&request); err != nil {
+		return nil, wrapErr(ErrInvalidRequest, err)
+	}
+
+	if err := s.checkNetwork(request.NetworkIdentifier); err != nil {
+		return nil, err
+	}
+
+	block, ok := s.chain.Block(request.BlockIdentifier)
+	if !ok {
+		return nil, wrapErr(
+			ErrBlockNotFound,
+			fmt.Errorf("%s is not canonical", types.PrintStruct(request.BlockIdentifier)),
+		)
+	}
+
+	return &types.BlockResponse{Block: block}, nil
+}
+
+func (s *Server) blockTransaction(
+	ctx context.Context,
+	body *json.Decoder,
+) (interface{}, *types.Error) {
+	var request types.BlockTransactionRequest
+	if err := body.Decode(&request); err != nil {
+		return nil, wrapErr(ErrInvalidRequest, err)
+	}
+
+	if err := s.checkNetwork(request.NetworkIdentifier); err != nil {
+		return nil, err
+	}
+
+	if request.BlockIdentifier == nil || request.TransactionIdentifier == nil {
+		return nil, wrapErr(
+			ErrInvalidRequest,
+			errors.New("block and transaction identifiers are required"),
+		)
+	}
+
+	block, ok := s.chain.Block(&types.PartialBlockIdentifier{
+		Hash:  &request.BlockIdentifier.Hash,
+		Index: &request.BlockIdentifier.Index,
+	})
+	if !ok {
+		return nil, wrapErr(
+			ErrBlockNotFound,
+			fmt.Errorf("%s is not canonical", types.PrintStruct(request.BlockIdentifier)),
+		)
+	}
+
+	for _, tx := range block.Transactions {
+		if tx.TransactionIdentifier.Hash == request.TransactionIdentifier.Hash {
+			return &types.BlockTransactionResponse{Transaction: tx}, nil
+		}
+	}
+
+	return nil, wrapErr(
+		ErrTransactionNotFound,
+		fmt.Errorf("%s is not in block", request.TransactionIdentifier.Hash),
+	)
+}
+
+// accountBalance returns the balance of an account at the
+// requested block (or the head block). Accounts only hold
+// Currency, so the balance of any other requested currency
+// is 0.
+func (s *Server) accountBalance(
+	ctx context.Context,
+	body *json.Decoder,
+) (interface{}, *types.Error) {
+	var request types.AccountBalanceRequest
+	if err := body.Decode(&request); err != nil {
+		return nil, wrapErr(ErrInvalidRequest, err)
+	}
+
+	if err := s.checkNetwork(request.NetworkIdentifier); err != nil {
+		return nil, err
+	}
+
+	if request.AccountIdentifier == nil {
+		return nil, wrapErr(ErrInvalidRequest, errors.New("account identifier is missing"))
+	}
+
+	balance, block, ok := s.chain.Balance(request.AccountIdentifier, request.BlockIdentifier)
+	if !ok {
+		return nil, wrapErr(
+			ErrBlockNotFound,
+			fmt.Errorf("%s is not canonical", types.PrintStruct(request.BlockIdentifier)),
+		)
+	}
+
+	currencies := request.Currencies
+	if len(currencies) == 0 {
+		currencies = []*types.Currency{Currency}
+	}
+
+	balances := make([]*types.Amount, len(currencies))
+	for i, currency := range currencies {
+		value := "0"
+		if types.Hash(currency) == types.Hash(Currency) {
+			value = balance.String()
+		}
+
+		balances[i] = &types.Amount{Value: value, Currency: currency}
+	}
+
+	return &types.AccountBalanceResponse{
+		BlockIdentifier: block.BlockIdentifier,
+		Balances:        balances,
+	}, nil
+}
+
+func (s *Server) mempool(
+	ctx context.Context,
+	body *json.Decoder,
+) (interface{}, *types.Error) {
+	var request types.NetworkRequest
+	if err := body.Decode(&request); err != nil {
+		return nil, wrapErr(ErrInvalidRequest, err)
+	}
+
+	if err := s.checkNetwork(request.NetworkIdentifier); err != nil {
+		return nil, err
+	}
+
+	return &types.MempoolResponse{TransactionIdentifiers: s.chain.Mempool()}, nil
+}
+
+func (s *Server) mempoolTransaction(
+	ctx context.Context,
+	body *json.Decoder,
+) (interface{}, *types.Error) {
+	var request types.MempoolTransactionRequest
+	if err := body.Decode(&request); err != nil {
+		return nil, wrapErr(ErrInvalidRequest, err)
+	}
+
+	if err := s.checkNetwork(request.NetworkIdentifier); err != nil {
+		return nil, err
+	}
+
+	if request.TransactionIdentifier == nil {
+		return nil, wrapErr(ErrInvalidRequest, errors.New("transaction identifier is missing"))
+	}
+
+	tx, err := s.chain.MempoolTransaction(request.TransactionIdentifier)
+	if err != nil {
+		return nil, wrapErr(ErrTransactionNotFound, err)
+	}
+
+	return &types.MempoolTransactionResponse{Transaction: tx}, nil
+}
+
+// transaction is the (hex-encoded JSON) transaction
+// created by /construction/payloads and signed by
+// /construction/combine.
+type transaction struct {
+	Operations []*types.Operation `json:"operations"`
+	Signatures []*types.Signature `json:"signatures,omitempty"`
+}
+
+func encodeTransaction(tx *transaction) (string, error) {
+	b, err := json.Marshal(tx)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+func decodeTransaction(encoded string) (*transaction, *types.Error) {
+	b, err := hex.DecodeString(encoded)
+	if err != nil {
+		return nil, wrapErr(ErrInvalidTransaction, err)
+	}
+
+	var tx transaction
+	if err := json.Unmarshal(b, &tx); err != nil {
+		return nil, wrapErr(ErrInvalidTransaction, err)
+	}
+
+	return &tx, nil
+}
+
+// signingPayload returns the bytes that must be signed
+// by each signer of a transaction with operations.
+func signingPayload(operations []*types.Operation) ([]byte, error) {
+	b, err := json.Marshal(operations)
+	if err != nil {
+		return nil, err
+	}
+
+	digest := sha256.Sum256(b)
+	return digest[:], nil
+}
+
+// signers returns the accounts debited by operations
+// (in the order they are first debited).
+func signers(operations []*types.Operation) []*types.AccountIdentifier {
+	accounts := []*types.AccountIdentifier{}
+	seen := map[string]struct{}{}
+	for _, op := range operations {
+		if op.Account == nil || op.Amount == nil || len(op.Amount.Value) == 0 ||
+			op.Amount.Value[0] != '-' {
+			continue
+		}
+
+		key := types.Hash(op.Account)
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+
+		accounts = append(accounts, op.Account)
+	}
+
+	return accounts
+}
+
+// hashTransaction returns the identifier of
+// the signed transaction encoded as signed.
+func hashTransaction(signed string) *types.TransactionIdentifier {
+	digest := sha256.Sum256([]byte(signed))
+	return &types.TransactionIdentifier{Hash: hex.EncodeToString(digest[:])}
+}
+
+func (s *Server) constructionDerive(
+	ctx context.Context,
+	body *json.Decoder,
+) (interface{}, *types.Error) {
+	var request types.ConstructionDeriveRequest
+	if err := body.Decode(&request); err != nil {
+		return nil, wrapErr(ErrInvalidRequest, err)
+	}
+
+	if err := s.checkNetwork(request.NetworkIdentifier); err != nil {
+		return nil, err
+	}
+
+	if request.PublicKey == nil || request.PublicKey.CurveType != types.Secp256k1 {
+		return nil, wrapErr(ErrInvalidRequest, errors.New("secp256k1 public key is required"))
+	}
+
+	return &types.ConstructionDeriveResponse{
+		AccountIdentifier: &types.AccountIdentifier{Address: Address(request.PublicKey.Bytes)},
+	}, nil
+}
+
+func (s *Server) constructionPreprocess(
+	ctx context.Context,
+	body *json.Decoder,
+) (interface{}, *types.Error) {
+	var request types.ConstructionPreprocessRequest
+	if err := body.Decode(&request); err != nil {
+		return nil, wrapErr(ErrInvalidRequest, err)
+	}
+
+	if err := s.checkNetwork(request.NetworkIdentifier); err != nil {
+		return nil, err
+	}
+
+	return &types.ConstructionPreprocessResponse{Options: map[string]interface{}{}}, nil
+}
+
+func (s *Server) constructionMetadata(
+	ctx context.Context,
+	body *json.Decoder,
+) (interface{}, *types.Error) {
+	var request types.ConstructionMetadataRequest
+	if err := body.Decode(&request); err != nil {
+		return nil, wrapErr(ErrInvalidRequest, err)
+	}
+
+	if err := s.checkNetwork(request.NetworkIdentifier); err != nil {
+		return nil, err
+	}
+
+	return &types.ConstructionMetadataResponse{Metadata: map[string]interface{}{}}, nil
+}
+
+func (s *Server) constructionPayloads(
+	ctx context.Context,
+	body *json.Decoder,
+) (interface{}, *types.Error) {
+	var request types.ConstructionPayloadsRequest
+	if err := body.Decode(&request); err != nil {
+		return nil, wrapErr(ErrInvalidRequest, err)
+	}
+
+	if err := s.checkNetwork(request.NetworkIdentifier); err != nil {
+		return nil, err
+	}
+
+	if err := s.chain.Validate(&types.Transaction{Operations: request.Operations}); err != nil {
+		return nil, wrapErr(ErrInvalidTransaction, err)
+	}
+
+	unsigned, err := encodeTransaction(&transaction{Operations: request.Operations})
+	if err != nil {
+		return nil, wrapErr(ErrInvalidTransaction, err)
+	}
+
+	payload, err := signingPayload(request.Operations)
+	if err != nil {
+		return nil, wrapErr(ErrInvalidTransaction, err)
+	}
+
+	accounts := signers(request.Operations)
+	payloads := make([]*types.SigningPayload, len(accounts))
+	for i, account := range accounts {
+		payloads[i] = &types.SigningPayload{
+			AccountIdentifier: account,
+			Bytes:             payload,
+			SignatureType:     types.Ecdsa,
+		}
+	}
+
+	return &types.ConstructionPayloadsResponse{
+		UnsignedTransaction: unsigned,
+		Payloads:            payloads,
+	}, nil
+}
+
+// constructionCombine attaches signatures to an unsigned
+// transaction. Signatures are not cryptographically verified,
+// but each signer must have a signature from a public key
+// that derives its address.
+func (s *Server) constructionCombine(
+	ctx context.Context,
+	body *json.Decoder,
+) (interface{}, *types.Error) {
+	var request types.ConstructionCombineRequest
+	if err := body.Decode(&request); err != nil {
+		return nil, wrapErr(ErrInvalidRequest, err)
+	}
+
+	if err := s.checkNetwork(request.NetworkIdentifier); err != nil {
+		return nil, err
+	}
+
+	tx, rosettaErr := decodeTransaction(request.UnsignedTransaction)
+	if rosettaErr != nil {
+		return nil, rosettaErr
+	}
+
+	signed := map[string]struct{}{}
+	for _, signature := range request.Signatures {
+		if signature.PublicKey == nil || len(signature.Bytes) == 0 {
+			return nil, wrapErr(ErrInvalidRequest, errors.New("signature is incomplete"))
+		}
+
+		signed[Address(signature.PublicKey.Bytes)] = struct{}{}
+	}
+
+	for _, account := range signers(tx.Operations) {
+		if _, ok := signed[account.Address]; !ok {
+			return nil, wrapErr(
+				ErrInvalidRequest,
+				fmt.Errorf("missing signature for %s", account.Address),
+			)
+		}
+	}
+
+	tx.Signatures = request.Signatures
+	signedTx, err := encodeTransaction(tx)
+	if err != nil {
+		return nil, wrapErr(ErrInvalidTransaction, err)
+	}
+
+	return &types.ConstructionCombineResponse{SignedTransaction: signedTx}, nil
+}
+
+func (s *Server) constructionParse(
+	ctx context.Context,
+	body *json.Decoder,
+) (interface{}, *types.Error) {
+	var request types.ConstructionParseRequest
+	if err := body.Decode(&request); err != nil {
+		return nil, wrapErr(ErrInvalidRequest, err)
+	}
+
+	if err := s.checkNetwork(request.NetworkIdentifier); err != nil {
+		return nil, err
+	}
+
+	tx, rosettaErr := decodeTransaction(request.Transaction)
+	if rosettaErr != nil {
+		return nil, rosettaErr
+	}
+
+	response := &types.ConstructionParseResponse{Operations: tx.Operations}
+	if request.Signed {
+		response.AccountIdentifierSigners = signers(tx.Operations)
+	}
+
+	return response, nil
+}
+
+func (s *Server) constructionHash(
+	ctx context.Context,
+	body *json.Decoder,
+) (interface{}, *types.Error) {
+	var request types.ConstructionHashRequest
+	if err := body.Decode(&request); err != nil {
+		return nil, wrapErr(ErrInvalidRequest, err)
+	}
+
+	if err := s.checkNetwork(request.NetworkIdentifier); err != nil {
+		return nil, err
+	}
+
+	if _, err := decodeTransaction(request.SignedTransaction); err != nil {
+		return nil, err
+	}
+
+	return &types.TransactionIdentifierResponse{
+		TransactionIdentifier: hashTransaction(request.SignedTransaction),
+	}, nil
+}
+
+func (s *Server) constructionSubmit(
+	ctx context.Context,
+	body *json.Decoder,
+) (interface{}, *types.Error) {
+	var request types.ConstructionSubmitRequest
+	if err := body.Decode(&request); err != nil {
+		return nil, wrapErr(ErrInvalidRequest, err)
+	}
+
+	if err := s.checkNetwork(request.NetworkIdentifier); err != nil {
+		return nil, err
+	}
+
+	tx, rosettaErr := decodeTransaction(request.SignedTransaction)
+	if rosettaErr != nil {
+		return nil, rosettaErr
+	}
+
+	if len(tx.Signatures) == 0 {
+		return nil, wrapErr(ErrInvalidTransaction, errors.New("transaction is not signed"))
+	}
+
+	identifier := hashTransaction(request.SignedTransaction)
+	if err := s.chain.Submit(&types.Transaction{
+		TransactionIdentifier: identifier,
+		Operations:            tx.Operations,
+	}); err != nil {
+		if errors.Is(err, ErrInsufficientFunds) {
+			return nil, wrapErr(ErrUnfundedTransaction, err)
+		}
+
+		return nil, wrapErr(ErrInvalidTransaction, err)
+	}
+
+	return &types.TransactionIdentifierResponse{TransactionIdentifier: identifier}, nil
+}
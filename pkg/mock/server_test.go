@@ -0,0 +1,241 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mock
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func post(
+	t *testing.T,
+	handler http.Handler,
+	path string,
+	request interface{},
+	response interface{},
+) int {
+	body, err := json.Marshal(request)
+	assert.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, path, bytes.NewReader(body)))
+	assert.NoError(t, json.NewDecoder(recorder.Body).Decode(response))
+
+	return recorder.Code
+}
+
+func TestServerData(t *testing.T) {
+	chain := newChain(t, 0, 0)
+	chain.Advance(genesis.Add(time.Second))
+	handler := NewServer(network, chain).Handler()
+	account := chain.Accounts()[1]
+
+	var status types.NetworkStatusResponse
+	assert.Equal(t, http.StatusOK, post(
+		t,
+		handler,
+		"/network/status",
+		&types.NetworkRequest{NetworkIdentifier: network},
+		&status,
+	))
+	assert.Equal(t, chain.Head().BlockIdentifier, status.CurrentBlockIdentifier)
+	assert.Equal(t, chain.Genesis().BlockIdentifier, status.GenesisBlockIdentifier)
+
+	head := int64(1)
+	var block types.BlockResponse
+	assert.Equal(t, http.StatusOK, post(
+		t,
+		handler,
+		"/block",
+		&types.BlockRequest{
+			NetworkIdentifier: network,
+			BlockIdentifier:   &types.PartialBlockIdentifier{Index: &head},
+		},
+		&block,
+	))
+	assert.Equal(t, chain.Head(), block.Block)
+
+	genesisIndex := int64(0)
+	var balance types.AccountBalanceResponse
+	assert.Equal(t, http.StatusOK, post(
+		t,
+		handler,
+		"/account/balance",
+		&types.AccountBalanceRequest{
+			NetworkIdentifier: network,
+			AccountIdentifier: account.Identifier,
+			BlockIdentifier:   &types.PartialBlockIdentifier{Index: &genesisIndex},
+		},
+		&balance,
+	))
+	assert.Equal(t, chain.Genesis().BlockIdentifier, balance.BlockIdentifier)
+	assert.Equal(t, []*types.Amount{
+		{Value: InitialBalance.String(), Currency: Currency},
+	}, balance.Balances)
+
+	missing := int64(2)
+	var rosettaErr types.Error
+	assert.Equal(t, http.StatusInternalServerError, post(
+		t,
+		handler,
+		"/block",
+		&types.BlockRequest{
+			NetworkIdentifier: network,
+			BlockIdentifier:   &types.PartialBlockIdentifier{Index: &missing},
+		},
+		&rosettaErr,
+	))
+	assert.Equal(t, ErrBlockNotFound.Code, rosettaErr.Code)
+
+	assert.Equal(t, http.StatusInternalServerError, post(
+		t,
+		handler,
+		"/network/status",
+		&types.NetworkRequest{
+			NetworkIdentifier: &types.NetworkIdentifier{Blockchain: "Mock", Network: "Mainnet"},
+		},
+		&rosettaErr,
+	))
+	assert.Equal(t, ErrNetworkNotSupported.Code, rosettaErr.Code)
+}
+
+func TestServerConstruction(t *testing.T) {
+	chain := newChain(t, 0, 0)
+	handler := NewServer(network, chain).Handler()
+	from := chain.Accounts()[0]
+	to := chain.Accounts()[1]
+
+	var derive types.ConstructionDeriveResponse
+	assert.Equal(t, http.StatusOK, post(
+		t,
+		handler,
+		"/construction/derive",
+		&types.ConstructionDeriveRequest{
+			NetworkIdentifier: network,
+			PublicKey:         from.KeyPair.PublicKey,
+		},
+		&derive,
+	))
+	assert.Equal(t, from.Identifier, derive.AccountIdentifier)
+
+	ops := transfer(from, to, 10).Operations
+	var payloads types.ConstructionPayloadsResponse
+	assert.Equal(t, http.StatusOK, post(
+		t,
+		handler,
+		"/construction/payloads",
+		&types.ConstructionPayloadsRequest{NetworkIdentifier: network, Operations: ops},
+		&payloads,
+	))
+	assert.Len(t, payloads.Payloads, 1)
+	assert.Equal(t, from.Identifier, payloads.Payloads[0].AccountIdentifier)
+
+	var rosettaErr types.Error
+	assert.Equal(t, http.StatusInternalServerError, post(
+		t,
+		handler,
+		"/construction/combine",
+		&types.ConstructionCombineRequest{
+			NetworkIdentifier:   network,
+			UnsignedTransaction: payloads.UnsignedTransaction,
+			Signatures:          []*types.Signature{},
+		},
+		&rosettaErr,
+	))
+	assert.Equal(t, ErrInvalidRequest.Code, rosettaErr.Code)
+
+	signer, err := from.KeyPair.Signer()
+	assert.NoError(t, err)
+	signature, err := signer.Sign(payloads.Payloads[0], types.Ecdsa)
+	assert.NoError(t, err)
+
+	var combine types.ConstructionCombineResponse
+	assert.Equal(t, http.StatusOK, post(
+		t,
+		handler,
+		"/construction/combine",
+		&types.ConstructionCombineRequest{
+			NetworkIdentifier:   network,
+			UnsignedTransaction: payloads.UnsignedTransaction,
+			Signatures:          []*types.Signature{signature},
+		},
+		&combine,
+	))
+
+	var parse types.ConstructionParseResponse
+	assert.Equal(t, http.StatusOK, post(
+		t,
+		handler,
+		"/construction/parse",
+		&types.ConstructionParseRequest{
+			NetworkIdentifier: network,
+			Signed:            true,
+			Transaction:       combine.SignedTransaction,
+		},
+		&parse,
+	))
+	assert.Equal(t, ops, parse.Operations)
+	assert.Equal(t, []*types.AccountIdentifier{from.Identifier}, parse.AccountIdentifierSigners)
+
+	var hash types.TransactionIdentifierResponse
+	assert.Equal(t, http.StatusOK, post(
+		t,
+		handler,
+		"/construction/hash",
+		&types.ConstructionHashRequest{
+			NetworkIdentifier: network,
+			SignedTransaction: combine.SignedTransaction,
+		},
+		&hash,
+	))
+
+	var submit types.TransactionIdentifierResponse
+	assert.Equal(t, http.StatusOK, post(
+		t,
+		handler,
+		"/construction/submit",
+		&types.ConstructionSubmitRequest{
+			NetworkIdentifier: network,
+			SignedTransaction: combine.SignedTransaction,
+		},
+		&submit,
+	))
+	assert.Equal(t, hash.TransactionIdentifier, submit.TransactionIdentifier)
+
+	var mempool types.MempoolResponse
+	assert.Equal(t, http.StatusOK, post(
+		t,
+		handler,
+		"/mempool",
+		&types.NetworkRequest{NetworkIdentifier: network},
+		&mempool,
+	))
+	assert.Equal(
+		t,
+		[]*types.TransactionIdentifier{submit.TransactionIdentifier},
+		mempool.TransactionIdentifiers,
+	)
+
+	_, added := chain.Advance(genesis.Add(time.Second))
+	tx := added[0].Transactions[len(added[0].Transactions)-1]
+	assert.Equal(t, submit.TransactionIdentifier, tx.TransactionIdentifier)
+}
@@ -0,0 +1,255 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package offline
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coinbase/rosetta-cli/pkg/fixture"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// Attempt is an outbound connection the offline
+// implementation attempted through the Proxy.
+type Attempt struct {
+	Method string    `json:"method"`
+	Host   string    `json:"host"`
+	Time   time.Time `json:"time"`
+}
+
+// Proxy is an HTTP proxy that blocks (and records) every
+// outbound connection. The offline implementation is run with
+// its proxy environment variables (HTTP_PROXY and HTTPS_PROXY)
+// set to the address of the Proxy, so any connection it makes
+// to another host is made through the Proxy.
+type Proxy struct {
+	attempts []*Attempt
+	mutex    sync.Mutex
+}
+
+// NewProxy returns a new *Proxy.
+func NewProxy() *Proxy {
+	return &Proxy{attempts: []*Attempt{}}
+}
+
+// ServeHTTP records the request as an Attempt and rejects it.
+// CONNECT requests (used to tunnel HTTPS) are rejected before
+// any connection to the requested host is made.
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	host := r.Host
+	if r.URL != nil && len(r.URL.Host) > 0 {
+		host = r.URL.Host
+	}
+
+	p.mutex.Lock()
+	p.attempts = append(p.attempts, &Attempt{
+		Method: r.Method,
+		Host:   host,
+		Time:   time.Now(),
+	})
+	p.mutex.Unlock()
+
+	http.Error(w, "outbound connections are blocked in offline mode", http.StatusForbidden)
+}
+
+// Attempts returns all attempts recorded
+// after the first skip attempts.
+func (p *Proxy) Attempts(skip int) []*Attempt {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if skip >= len(p.attempts) {
+		return []*Attempt{}
+	}
+
+	attempts := make([]*Attempt, len(p.attempts)-skip)
+	copy(attempts, p.attempts[skip:])
+
+	return attempts
+}
+
+// Count returns the number of attempts recorded.
+func (p *Proxy) Count() int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return len(p.attempts)
+}
+
+// Violation is a replayed interaction during which the offline
+// implementation attempted an outbound connection or that did
+// not return the recorded status code (which usually means the
+// implementation could not reach a node).
+type Violation struct {
+	Index          int        `json:"index"`
+	Endpoint       string     `json:"endpoint"`
+	Attempts       []*Attempt `json:"attempts,omitempty"`
+	ExpectedStatus int        `json:"expected_status"`
+	ActualStatus   int        `json:"actual_status"`
+}
+
+// EndpointStats are the results of all replayed
+// interactions with an endpoint.
+type EndpointStats struct {
+	Requests   int `json:"requests"`
+	Attempts   int `json:"attempts"`
+	Violations int `json:"violations"`
+}
+
+// Report is the result of an offline check.
+type Report struct {
+	Endpoints  map[string]*EndpointStats `json:"endpoints"`
+	Violations []*Violation              `json:"violations"`
+
+	// Unattributed are attempts recorded before the
+	// first interaction was replayed (i.e. while the
+	// offline implementation was starting).
+	Unattributed []*Attempt `json:"unattributed,omitempty"`
+}
+
+// Passed returns a boolean indicating if no outbound
+// connection was attempted and no endpoint failed.
+func (r *Report) Passed() bool {
+	return len(r.Violations) == 0 && len(r.Unattributed) == 0
+}
+
+// Check replays all offline interactions in f (one at a time)
+// against the implementation at serverURL and attributes each
+// attempt recorded by proxy to the interaction being replayed.
+func Check(
+	ctx context.Context,
+	client *http.Client,
+	serverURL string,
+	f *fixture.Fixture,
+	proxy *Proxy,
+) (*Report, error) {
+	report := &Report{
+		Endpoints:    map[string]*EndpointStats{},
+		Violations:   []*Violation{},
+		Unattributed: proxy.Attempts(0),
+	}
+
+	for i, interaction := range f.Interactions {
+		if !interaction.Offline() {
+			continue
+		}
+
+		recorded := proxy.Count()
+		status, _, err := interaction.Execute(ctx, client, serverURL)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to replay interaction %d", err, i)
+		}
+		attempts := proxy.Attempts(recorded)
+
+		stats, ok := report.Endpoints[interaction.Endpoint]
+		if !ok {
+			stats = &EndpointStats{}
+			report.Endpoints[interaction.Endpoint] = stats
+		}
+
+		stats.Requests++
+		stats.Attempts += len(attempts)
+		if len(attempts) == 0 && status == interaction.StatusCode {
+			continue
+		}
+
+		stats.Violations++
+		report.Violations = append(report.Violations, &Violation{
+			Index:          i,
+			Endpoint:       interaction.Endpoint,
+			Attempts:       attempts,
+			ExpectedStatus: interaction.StatusCode,
+			ActualStatus:   status,
+		})
+	}
+
+	return report, nil
+}
+
+// hosts returns the distinct hosts of attempts.
+func hosts(attempts []*Attempt) string {
+	seen := map[string]struct{}{}
+	for _, attempt := range attempts {
+		seen[attempt.Host] = struct{}{}
+	}
+
+	distinct := make([]string, 0, len(seen))
+	for host := range seen {
+		distinct = append(distinct, host)
+	}
+	sort.Strings(distinct)
+
+	return strings.Join(distinct, ", ")
+}
+
+// Print prints the stats of each endpoint
+// and all violations as tables.
+func (r *Report) Print() {
+	endpoints := make([]string, 0, len(r.Endpoints))
+	for endpoint := range r.Endpoints {
+		endpoints = append(endpoints, endpoint)
+	}
+	sort.Strings(endpoints)
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetRowLine(true)
+	table.SetRowSeparator("-")
+	table.SetHeader([]string{"Endpoint", "Requests", "Outbound Attempts", "Violations"})
+	for _, endpoint := range endpoints {
+		stats := r.Endpoints[endpoint]
+		table.Append([]string{
+			endpoint,
+			fmt.Sprintf("%d", stats.Requests),
+			fmt.Sprintf("%d", stats.Attempts),
+			fmt.Sprintf("%d", stats.Violations),
+		})
+	}
+	table.Render()
+
+	if len(r.Unattributed) > 0 {
+		fmt.Printf(
+			"%d outbound connections attempted during startup: %s\n",
+			len(r.Unattributed),
+			hosts(r.Unattributed),
+		)
+	}
+
+	if len(r.Violations) == 0 {
+		return
+	}
+
+	violations := tablewriter.NewWriter(os.Stdout)
+	violations.SetRowLine(true)
+	violations.SetRowSeparator("-")
+	violations.SetHeader([]string{"Index", "Endpoint", "Outbound Hosts", "Expected", "Actual"})
+	for _, violation := range r.Violations {
+		violations.Append([]string{
+			fmt.Sprintf("%d", violation.Index),
+			violation.Endpoint,
+			hosts(violation.Attempts),
+			fmt.Sprintf("%d", violation.ExpectedStatus),
+			fmt.Sprintf("%d", violation.ActualStatus),
+		})
+	}
+	violations.Render()
+}
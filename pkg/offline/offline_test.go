@@ -0,0 +1,129 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package offline
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/coinbase/rosetta-cli/pkg/fixture"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheck(t *testing.T) {
+	proxy := NewProxy()
+	proxyServer := httptest.NewServer(proxy)
+	defer proxyServer.Close()
+
+	proxyURL, err := url.Parse(proxyServer.URL)
+	assert.NoError(t, err)
+
+	// The implementation makes outbound requests with its proxy
+	// environment variables (simulated with an explicit proxy).
+	outbound := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/construction/payloads":
+			resp, err := outbound.Get("http://node.example:8080/block")
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+			_ = resp.Body.Close()
+		case "/construction/hash":
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+
+		_, _ = w.Write([]byte("{}"))
+	}
+	implementation := httptest.NewServer(http.HandlerFunc(handler))
+	defer implementation.Close()
+
+	f := &fixture.Fixture{
+		Interactions: []*fixture.Interaction{
+			{
+				Endpoint:   "/construction/derive",
+				Request:    json.RawMessage(`{}`),
+				StatusCode: http.StatusOK,
+				Response:   json.RawMessage(`{}`),
+			},
+			{
+				Endpoint:   "/construction/metadata",
+				Request:    json.RawMessage(`{}`),
+				StatusCode: http.StatusOK,
+				Response:   json.RawMessage(`{}`),
+			},
+			{
+				Endpoint:   "/construction/payloads",
+				Request:    json.RawMessage(`{}`),
+				StatusCode: http.StatusOK,
+				Response:   json.RawMessage(`{}`),
+			},
+			{
+				Endpoint:   "/construction/hash",
+				Request:    json.RawMessage(`{}`),
+				StatusCode: http.StatusOK,
+				Response:   json.RawMessage(`{}`),
+			},
+			{
+				Endpoint:   "/construction/derive",
+				Request:    json.RawMessage(`{}`),
+				StatusCode: http.StatusOK,
+				Response:   json.RawMessage(`{}`),
+			},
+		},
+	}
+
+	report, err := Check(context.Background(), http.DefaultClient, implementation.URL, f, proxy)
+	assert.NoError(t, err)
+	assert.False(t, report.Passed())
+	assert.Len(t, report.Unattributed, 0)
+
+	assert.Equal(t, map[string]*EndpointStats{
+		"/construction/derive":   {Requests: 2},
+		"/construction/payloads": {Requests: 1, Attempts: 1, Violations: 1},
+		"/construction/hash":     {Requests: 1, Violations: 1},
+	}, report.Endpoints)
+
+	assert.Len(t, report.Violations, 2)
+	assert.Equal(t, 2, report.Violations[0].Index)
+	assert.Equal(t, "node.example:8080", report.Violations[0].Attempts[0].Host)
+	assert.Equal(t, 3, report.Violations[1].Index)
+	assert.Len(t, report.Violations[1].Attempts, 0)
+	assert.Equal(t, http.StatusInternalServerError, report.Violations[1].ActualStatus)
+}
+
+func TestCheckStartupAttempts(t *testing.T) {
+	proxy := NewProxy()
+	proxyServer := httptest.NewServer(proxy)
+	defer proxyServer.Close()
+
+	req, err := http.NewRequest(http.MethodConnect, proxyServer.URL, nil)
+	assert.NoError(t, err)
+	req.Host = "node.example:443"
+	resp, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	_ = resp.Body.Close()
+
+	report, err := Check(context.Background(), http.DefaultClient, "", &fixture.Fixture{}, proxy)
+	assert.NoError(t, err)
+	assert.False(t, report.Passed())
+	assert.Equal(t, http.MethodConnect, report.Unattributed[0].Method)
+	assert.Equal(t, "node.example:443", report.Unattributed[0].Host)
+}
@@ -0,0 +1,376 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package opstats
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/coinbase/rosetta-sdk-go/asserter"
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/olekukonko/tablewriter"
+)
+
+const (
+	// statsNamespace is prepended to the stored
+	// statistics of each operation type.
+	statsNamespace = "operation_stats/type"
+
+	// accountNamespace is prepended to the stored index
+	// of the block where an account was first seen in an
+	// operation of some type.
+	accountNamespace = "operation_stats/account"
+)
+
+var _ storage.BlockWorker = (*Storage)(nil)
+
+// Volume is the total absolute value of all successful
+// operations of some type in a currency.
+type Volume struct {
+	Currency *types.Currency `json:"currency"`
+	Total    string          `json:"total"`
+}
+
+// OperationStats are the statistics of all operations
+// of some type in canonical blocks.
+type OperationStats struct {
+	Type           string    `json:"type"`
+	Count          int64     `json:"count"`
+	UniqueAccounts int64     `json:"unique_accounts"`
+	Volumes        []*Volume `json:"volumes,omitempty"`
+}
+
+// Storage implements the storage.BlockWorker interface and
+// aggregates the statistics of each operation type while
+// syncing. All statistics are reverted when a block is
+// orphaned.
+type Storage struct {
+	db       storage.Database
+	asserter *asserter.Asserter
+}
+
+// NewStorage returns a new *Storage. The asserter is only
+// required when the Storage is used as a block worker.
+func NewStorage(db storage.Database, asserter *asserter.Asserter) *Storage {
+	return &Storage{
+		db:       db,
+		asserter: asserter,
+	}
+}
+
+func getStatsKey(opType string) []byte {
+	return []byte(fmt.Sprintf("%s/%s", statsNamespace, opType))
+}
+
+func getStatsPrefix() []byte {
+	return []byte(statsNamespace + "/")
+}
+
+func getAccountKey(opType string, account *types.AccountIdentifier) []byte {
+	return []byte(fmt.Sprintf("%s/%s/%s", accountNamespace, opType, types.Hash(account)))
+}
+
+// stats are the OperationStats of an operation type
+// with volumes keyed by currency.
+type stats struct {
+	Type           string             `json:"type"`
+	Count          int64              `json:"count"`
+	UniqueAccounts int64              `json:"unique_accounts"`
+	Volumes        map[string]*Volume `json:"volumes"`
+}
+
+func (s *Storage) getStats(
+	ctx context.Context,
+	dbTx storage.DatabaseTransaction,
+	opType string,
+) (*stats, error) {
+	exists, val, err := dbTx.Get(ctx, getStatsKey(opType))
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to get %s stats", err, opType)
+	}
+
+	if !exists {
+		return &stats{Type: opType, Volumes: map[string]*Volume{}}, nil
+	}
+
+	var opStats stats
+	if err := s.db.Encoder().Decode(statsNamespace, val, &opStats, false); err != nil {
+		return nil, fmt.Errorf("%w: unable to decode %s stats", err, opType)
+	}
+
+	return &opStats, nil
+}
+
+// updateAccount records the first sighting of account in an
+// operation of type opType in block (or removes it when the
+// block is removed) and returns the change in unique accounts.
+func (s *Storage) updateAccount(
+	ctx context.Context,
+	dbTx storage.DatabaseTransaction,
+	opType string,
+	account *types.AccountIdentifier,
+	block *types.BlockIdentifier,
+	remove bool,
+) (int64, error) {
+	key := getAccountKey(opType, account)
+	exists, val, err := dbTx.Get(ctx, key)
+	if err != nil {
+		return 0, fmt.Errorf("%w: unable to get account record", err)
+	}
+
+	if !remove {
+		if exists {
+			return 0, nil
+		}
+
+		return 1, dbTx.Set(ctx, key, []byte(strconv.FormatInt(block.Index, 10)), true)
+	}
+
+	if !exists || string(val) != strconv.FormatInt(block.Index, 10) {
+		return 0, nil
+	}
+
+	return -1, dbTx.Delete(ctx, key)
+}
+
+// applyOperation adds op (or removes it if remove is
+// true) to opStats.
+func (s *Storage) applyOperation(
+	ctx context.Context,
+	dbTx storage.DatabaseTransaction,
+	opStats *stats,
+	op *types.Operation,
+	block *types.BlockIdentifier,
+	remove bool,
+) error {
+	sign := int64(1)
+	if remove {
+		sign = -1
+	}
+
+	opStats.Count += sign
+
+	if op.Account != nil {
+		change, err := s.updateAccount(ctx, dbTx, op.Type, op.Account, block, remove)
+		if err != nil {
+			return err
+		}
+
+		opStats.UniqueAccounts += change
+	}
+
+	if op.Amount == nil {
+		return nil
+	}
+
+	success, err := s.asserter.OperationSuccessful(op)
+	if err != nil {
+		return fmt.Errorf("%w: unable to check operation success", err)
+	}
+
+	if !success {
+		return nil
+	}
+
+	return addVolume(opStats, op.Amount, sign)
+}
+
+// update applies all operations in block to the stored
+// statistics (reverting them if remove is true).
+func (s *Storage) update(
+	ctx context.Context,
+	block *types.Block,
+	dbTx storage.DatabaseTransaction,
+	remove bool,
+) error {
+	updated := map[string]*stats{}
+	for _, tx := range block.Transactions {
+		for _, op := range tx.Operations {
+			opStats, ok := updated[op.Type]
+			if !ok {
+				var err error
+				opStats, err = s.getStats(ctx, dbTx, op.Type)
+				if err != nil {
+					return err
+				}
+
+				updated[op.Type] = opStats
+			}
+
+			if err := s.applyOperation(
+				ctx,
+				dbTx,
+				opStats,
+				op,
+				block.BlockIdentifier,
+				remove,
+			); err != nil {
+				return err
+			}
+		}
+	}
+
+	for opType, opStats := range updated {
+		val, err := s.db.Encoder().Encode(statsNamespace, opStats)
+		if err != nil {
+			return fmt.Errorf("%w: unable to encode %s stats", err, opType)
+		}
+
+		if err := dbTx.Set(ctx, getStatsKey(opType), val, true); err != nil {
+			return fmt.Errorf("%w: unable to store %s stats", err, opType)
+		}
+	}
+
+	return nil
+}
+
+// addVolume adds the absolute value of amount (multiplied
+// by sign) to the volume of its currency in opStats.
+func addVolume(opStats *stats, amount *types.Amount, sign int64) error {
+	value, err := types.BigInt(amount.Value)
+	if err != nil {
+		return fmt.Errorf("%w: unable to parse amount", err)
+	}
+
+	currencyKey := types.Hash(amount.Currency)
+	volume, ok := opStats.Volumes[currencyKey]
+	if !ok {
+		volume = &Volume{Currency: amount.Currency, Total: "0"}
+		opStats.Volumes[currencyKey] = volume
+	}
+
+	total, err := types.BigInt(volume.Total)
+	if err != nil {
+		return fmt.Errorf("%w: unable to parse volume", err)
+	}
+
+	value.Abs(value)
+	value.Mul(value, big.NewInt(sign))
+	volume.Total = total.Add(total, value).String()
+
+	return nil
+}
+
+// AddingBlock adds all operations in block
+// to the stored statistics.
+func (s *Storage) AddingBlock(
+	ctx context.Context,
+	block *types.Block,
+	transaction storage.DatabaseTransaction,
+) (storage.CommitWorker, error) {
+	return nil, s.update(ctx, block, transaction, false)
+}
+
+// RemovingBlock removes all operations in block
+// from the stored statistics.
+func (s *Storage) RemovingBlock(
+	ctx context.Context,
+	block *types.Block,
+	transaction storage.DatabaseTransaction,
+) (storage.CommitWorker, error) {
+	return nil, s.update(ctx, block, transaction, true)
+}
+
+// GetAll returns the statistics of all operation
+// types seen while syncing (sorted by type).
+func (s *Storage) GetAll(ctx context.Context) ([]*OperationStats, error) {
+	dbTx := s.db.NewDatabaseTransaction(ctx, false)
+	defer dbTx.Discard(ctx)
+
+	all := []*OperationStats{}
+	_, err := dbTx.Scan(
+		ctx,
+		getStatsPrefix(),
+		getStatsPrefix(),
+		func(k []byte, v []byte) error {
+			var opStats stats
+			if err := s.db.Encoder().Decode(statsNamespace, v, &opStats, false); err != nil {
+				return fmt.Errorf("%w: unable to decode stats", err)
+			}
+
+			// Operation types with no operations left
+			// after a reorg (and empty volumes) are omitted.
+			if opStats.Count == 0 {
+				return nil
+			}
+
+			volumes := []*Volume{}
+			for _, volume := range opStats.Volumes {
+				if volume.Total != "0" {
+					volumes = append(volumes, volume)
+				}
+			}
+			sort.Slice(volumes, func(i, j int) bool {
+				if volumes[i].Currency.Symbol != volumes[j].Currency.Symbol {
+					return volumes[i].Currency.Symbol < volumes[j].Currency.Symbol
+				}
+
+				return types.Hash(volumes[i].Currency) < types.Hash(volumes[j].Currency)
+			})
+
+			all = append(all, &OperationStats{
+				Type:           opStats.Type,
+				Count:          opStats.Count,
+				UniqueAccounts: opStats.UniqueAccounts,
+				Volumes:        volumes,
+			})
+			return nil
+		},
+		false,
+		false,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to scan operation stats", err)
+	}
+
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].Type < all[j].Type
+	})
+
+	return all, nil
+}
+
+// Print logs the statistics of all operation
+// types to the console as a table.
+func Print(all []*OperationStats) {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetRowLine(true)
+	table.SetRowSeparator("-")
+	table.SetHeader([]string{"Operation Type", "Count", "Unique Accounts", "Volume"})
+	for _, opStats := range all {
+		volume := ""
+		for i, v := range opStats.Volumes {
+			if i > 0 {
+				volume += "\n"
+			}
+
+			volume += fmt.Sprintf("%s %s", v.Total, v.Currency.Symbol)
+		}
+
+		table.Append([]string{
+			opStats.Type,
+			strconv.FormatInt(opStats.Count, 10),
+			strconv.FormatInt(opStats.UniqueAccounts, 10),
+			volume,
+		})
+	}
+
+	table.Render()
+}
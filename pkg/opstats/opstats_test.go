@@ -0,0 +1,158 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package opstats
+
+import (
+	"context"
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/asserter"
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/coinbase/rosetta-sdk-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	btc = &types.Currency{Symbol: "BTC", Decimals: 8}
+	eth = &types.Currency{Symbol: "ETH", Decimals: 18}
+)
+
+func testOp(opType string, status string, address string, amount *types.Amount) *types.Operation {
+	return &types.Operation{
+		Type:    opType,
+		Status:  types.String(status),
+		Account: &types.AccountIdentifier{Address: address},
+		Amount:  amount,
+	}
+}
+
+func testBlock(index int64, ops ...*types.Operation) *types.Block {
+	return &types.Block{
+		BlockIdentifier: &types.BlockIdentifier{Index: index},
+		Transactions: []*types.Transaction{
+			{
+				TransactionIdentifier: &types.TransactionIdentifier{Hash: "tx"},
+				Operations:            ops,
+			},
+		},
+	}
+}
+
+func TestStorage(t *testing.T) {
+	ctx := context.Background()
+	dir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(dir)
+
+	localStore, err := storage.NewBadgerStorage(
+		ctx,
+		dir,
+		storage.WithIndexCacheSize(storage.TinyIndexCacheSize),
+	)
+	assert.NoError(t, err)
+	defer localStore.Close(ctx)
+
+	a, err := asserter.NewClientWithOptions(
+		&types.NetworkIdentifier{Blockchain: "blah", Network: "testnet"},
+		&types.BlockIdentifier{Hash: "genesis", Index: 0},
+		[]string{"FEE", "TRANSFER"},
+		[]*types.OperationStatus{
+			{Status: "SUCCESS", Successful: true},
+			{Status: "FAILURE", Successful: false},
+		},
+		[]*types.Error{},
+		nil,
+	)
+	assert.NoError(t, err)
+
+	s := NewStorage(localStore, a)
+
+	addBlock := func(block *types.Block) {
+		dbTx := localStore.NewDatabaseTransaction(ctx, true)
+		defer dbTx.Discard(ctx)
+
+		_, err := s.AddingBlock(ctx, block, dbTx)
+		assert.NoError(t, err)
+		assert.NoError(t, dbTx.Commit(ctx))
+	}
+
+	removeBlock := func(block *types.Block) {
+		dbTx := localStore.NewDatabaseTransaction(ctx, true)
+		defer dbTx.Discard(ctx)
+
+		_, err := s.RemovingBlock(ctx, block, dbTx)
+		assert.NoError(t, err)
+		assert.NoError(t, dbTx.Commit(ctx))
+	}
+
+	block1 := testBlock(
+		1,
+		testOp("TRANSFER", "SUCCESS", "addr1", &types.Amount{Value: "-100", Currency: btc}),
+		testOp("TRANSFER", "SUCCESS", "addr2", &types.Amount{Value: "100", Currency: btc}),
+		testOp("FEE", "SUCCESS", "addr1", &types.Amount{Value: "-1", Currency: btc}),
+	)
+	block2 := testBlock(
+		2,
+		testOp("TRANSFER", "SUCCESS", "addr1", &types.Amount{Value: "-5", Currency: eth}),
+		testOp("TRANSFER", "SUCCESS", "addr3", &types.Amount{Value: "5", Currency: eth}),
+		testOp("TRANSFER", "FAILURE", "addr3", &types.Amount{Value: "7", Currency: btc}),
+		testOp("TRANSFER", "SUCCESS", "addr3", nil),
+	)
+
+	addBlock(block1)
+	addBlock(block2)
+
+	all, err := s.GetAll(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []*OperationStats{
+		{
+			Type:           "FEE",
+			Count:          1,
+			UniqueAccounts: 1,
+			Volumes:        []*Volume{{Currency: btc, Total: "1"}},
+		},
+		{
+			Type:           "TRANSFER",
+			Count:          6,
+			UniqueAccounts: 3,
+			Volumes: []*Volume{
+				{Currency: btc, Total: "200"},
+				{Currency: eth, Total: "10"},
+			},
+		},
+	}, all)
+	Print(all) // make sure doesn't panic
+
+	// Orphaned blocks are removed from the statistics
+	// (and operation types without operations are omitted).
+	removeBlock(block2)
+	removeBlock(block1)
+	addBlock(testBlock(
+		1,
+		testOp("TRANSFER", "SUCCESS", "addr3", &types.Amount{Value: "3", Currency: eth}),
+	))
+
+	all, err = s.GetAll(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []*OperationStats{
+		{
+			Type:           "TRANSFER",
+			Count:          1,
+			UniqueAccounts: 1,
+			Volumes:        []*Volume{{Currency: eth, Total: "3"}},
+		},
+	}, all)
+}
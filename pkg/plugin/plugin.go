@@ -0,0 +1,285 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package plugin implements the protocol used to attach external
+// block workers to check:data. A plugin is any executable that reads
+// requests (one JSON object per line) from stdin and writes exactly
+// one response (one JSON object per line) to stdout for each request.
+// Anything written to stderr is forwarded to the stderr of the
+// rosetta-cli.
+//
+// For every block added (or removed in a reorg) by check:data, a
+// Request is sent with the block. If the plugin responds with an
+// error, check:data exits with that error. Because blocks are sent
+// before they are committed to storage, a plugin may receive the same
+// block more than once (for example, after check:data is restarted).
+package plugin
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+const (
+	// ProtocolVersion is the version of the plugin protocol.
+	// It is included in every Request so plugins can reject
+	// requests they do not understand.
+	ProtocolVersion = 1
+
+	// AddingBlock is the Type of a Request sent
+	// when a block is added to storage.
+	AddingBlock = "adding_block"
+
+	// RemovingBlock is the Type of a Request sent
+	// when a block is orphaned.
+	RemovingBlock = "removing_block"
+
+	// exitTimeout is how long Close waits for a
+	// plugin to exit before killing it.
+	exitTimeout = 10 * time.Second
+)
+
+var (
+	// ErrPluginError is returned when a plugin
+	// responds to a request with an error.
+	ErrPluginError = errors.New("plugin returned error")
+
+	// ErrPluginTimeout is returned when a plugin does not
+	// respond to a request before the timeout.
+	ErrPluginTimeout = errors.New("plugin timed out")
+
+	// ErrPluginExited is returned when a plugin exits
+	// (or closes stdout) before responding to a request.
+	ErrPluginExited = errors.New("plugin exited")
+)
+
+// Request is sent to a plugin for each
+// block processed by check:data.
+type Request struct {
+	Version           int                      `json:"version"`
+	Type              string                   `json:"type"`
+	NetworkIdentifier *types.NetworkIdentifier `json:"network_identifier"`
+	Block             *types.Block             `json:"block"`
+}
+
+// Response is returned by a plugin for each Request.
+// If Error is populated, processing the block failed.
+type Response struct {
+	Error string `json:"error,omitempty"`
+}
+
+// Plugin is a running plugin process.
+type Plugin struct {
+	name        string
+	network     *types.NetworkIdentifier
+	timeout     time.Duration
+	exitTimeout time.Duration
+
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+
+	// failed is populated once the plugin can no
+	// longer be used (i.e. it timed out or exited).
+	failed error
+	mutex  sync.Mutex
+}
+
+// Start starts the plugin at path with args. If timeout is
+// 0, the plugin is allowed to take any amount of time to
+// respond to each request.
+func Start(
+	name string,
+	path string,
+	args []string,
+	network *types.NetworkIdentifier,
+	timeout time.Duration,
+) (*Plugin, error) {
+	cmd := exec.Command(path, args...) // #nosec G204
+	cmd.Stderr = os.Stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to open stdin of plugin %s", err, name)
+	}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to open stdout of plugin %s", err, name)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("%w: unable to start plugin %s", err, name)
+	}
+
+	return &Plugin{
+		name:        name,
+		network:     network,
+		timeout:     timeout,
+		exitTimeout: exitTimeout,
+		cmd:         cmd,
+		stdin:       stdin,
+		stdout:      bufio.NewReader(stdout),
+	}, nil
+}
+
+// Name returns the name of the plugin.
+func (p *Plugin) Name() string {
+	return p.name
+}
+
+// readResponse reads the next response from
+// the plugin into response.
+func (p *Plugin) readResponse(response interface{}) error {
+	line, err := p.stdout.ReadBytes('\n')
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrPluginExited, err.Error())
+	}
+
+	if err := json.Unmarshal(line, response); err != nil {
+		return fmt.Errorf("%w: unable to decode response %s", err, string(line))
+	}
+
+	return nil
+}
+
+// Send sends block to the plugin and waits for its response.
+// If the plugin times out or exits, all subsequent calls to
+// Send return an error.
+func (p *Plugin) Send(ctx context.Context, requestType string, block *types.Block) error {
+	var response Response
+	if err := p.Call(ctx, &Request{
+		Version:           ProtocolVersion,
+		Type:              requestType,
+		NetworkIdentifier: p.network,
+		Block:             block,
+	}, &response); err != nil {
+		return err
+	}
+
+	if len(response.Error) > 0 {
+		return fmt.Errorf("%w: %s", ErrPluginError, response.Error)
+	}
+
+	return nil
+}
+
+// Call sends request (encoded as JSON) to the plugin and
+// decodes its response into response. Call allows other
+// request types to be exchanged with a plugin process using
+// the same framing (one JSON object per line) as Send. If the
+// plugin times out or exits, all subsequent calls return an
+// error.
+func (p *Plugin) Call(ctx context.Context, request interface{}, response interface{}) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.failed != nil {
+		return p.failed
+	}
+
+	encoded, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("%w: unable to encode request", err)
+	}
+
+	if _, err := p.stdin.Write(append(encoded, '\n')); err != nil {
+		p.failed = fmt.Errorf("%w: unable to write request: %s", ErrPluginExited, err.Error())
+		return p.failed
+	}
+
+	results := make(chan error, 1)
+	go func() {
+		results <- p.readResponse(response)
+	}()
+
+	var timeout <-chan time.Time
+	if p.timeout > 0 {
+		timer := time.NewTimer(p.timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case err := <-results:
+		if err != nil {
+			p.failed = err
+			return err
+		}
+
+		return nil
+	case <-timeout:
+		// The pending read could consume the response to the
+		// next request, so the plugin cannot be used again.
+		p.failed = fmt.Errorf("%w after %s", ErrPluginTimeout, p.timeout)
+		return p.failed
+	case <-ctx.Done():
+		p.failed = ctx.Err()
+		return p.failed
+	}
+}
+
+// Close closes the stdin of the plugin (signaling
+// that no more requests will be sent) and waits for
+// it to exit. If the plugin has failed (or does not
+// exit in time), it is killed.
+func (p *Plugin) Close() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.failed != nil {
+		_ = p.cmd.Process.Kill()
+	}
+
+	if err := p.stdin.Close(); err != nil {
+		return fmt.Errorf("%w: unable to close stdin of plugin %s", err, p.name)
+	}
+
+	exited := make(chan error, 1)
+	go func() {
+		exited <- p.cmd.Wait()
+	}()
+
+	timer := time.NewTimer(p.exitTimeout)
+	defer timer.Stop()
+
+	select {
+	case err := <-exited:
+		if err != nil && p.failed == nil {
+			return fmt.Errorf("%w: plugin %s did not exit cleanly", err, p.name)
+		}
+
+		return nil
+	case <-timer.C:
+		_ = p.cmd.Process.Kill()
+		<-exited
+
+		return fmt.Errorf(
+			"%w: plugin %s did not exit after %s",
+			ErrPluginTimeout,
+			p.name,
+			p.exitTimeout,
+		)
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	network = &types.NetworkIdentifier{Blockchain: "Bitcoin", Network: "Testnet3"}
+	block   = &types.Block{
+		BlockIdentifier:       &types.BlockIdentifier{Hash: "block 1", Index: 1},
+		ParentBlockIdentifier: &types.BlockIdentifier{Hash: "block 0", Index: 0},
+	}
+)
+
+func TestPlugin(t *testing.T) {
+	var tests = map[string]struct {
+		script  string
+		timeout time.Duration
+
+		err    error
+		failed bool
+	}{
+		"success": {
+			script: `while read line; do echo '{}'; done`,
+		},
+		"error": {
+			script: `while read line; do echo '{"error":"bad block"}'; done`,
+			err:    ErrPluginError,
+		},
+		"request fields": {
+			script: `while read line; do
+  case "$line" in
+    *'"version":1,"type":"'*'_block","network_identifier":{"blockchain":"Bitcoin"'*'"hash":"block 1"'*) echo '{}' ;;
+    *) echo '{"error":"unexpected request"}' ;;
+  esac
+done`,
+		},
+		"exited": {
+			script: `read line; exit 1`,
+			err:    ErrPluginExited,
+			failed: true,
+		},
+		"timeout": {
+			script:  `while read line; do sleep 5; echo '{}'; done`,
+			timeout: 100 * time.Millisecond,
+			err:     ErrPluginTimeout,
+			failed:  true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			p, err := Start(name, "sh", []string{"-c", test.script}, network, test.timeout)
+			assert.NoError(t, err)
+			assert.Equal(t, name, p.Name())
+
+			ctx := context.Background()
+			err = p.Send(ctx, AddingBlock, block)
+			if test.err != nil {
+				assert.True(t, errors.Is(err, test.err))
+			} else {
+				assert.NoError(t, err)
+			}
+
+			// Plugins that fail cannot be used again while
+			// plugins that return errors can.
+			secondErr := p.Send(ctx, RemovingBlock, block)
+			if test.failed {
+				assert.Equal(t, err, secondErr)
+			} else if test.err == nil {
+				assert.NoError(t, secondErr)
+			}
+
+			assert.NoError(t, p.Close())
+		})
+	}
+}
+
+func TestCloseTimeout(t *testing.T) {
+	p, err := Start(
+		"slow exit",
+		"sh",
+		[]string{"-c", `while read line; do echo '{}'; done; exec sleep 5`},
+		network,
+		0,
+	)
+	assert.NoError(t, err)
+	p.exitTimeout = 100 * time.Millisecond
+
+	assert.NoError(t, p.Send(context.Background(), AddingBlock, block))
+
+	start := time.Now()
+	err = p.Close()
+	assert.True(t, errors.Is(err, ErrPluginTimeout))
+	assert.Less(t, int64(time.Since(start)), int64(time.Second))
+}
+
+func TestStartMissing(t *testing.T) {
+	_, err := Start("missing", "/path/to/missing/plugin", nil, network, 0)
+	assert.Error(t, err)
+}
@@ -0,0 +1,300 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/coinbase/rosetta-cli/pkg/bootstrap"
+	"github.com/coinbase/rosetta-cli/pkg/failures"
+	"github.com/coinbase/rosetta-cli/pkg/results"
+
+	"github.com/coinbase/rosetta-sdk-go/asserter"
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+const (
+	// accountCreationNamespace is prepended to any stored
+	// account creation record.
+	accountCreationNamespace = "account_creation"
+
+	// bootstrapCreationIndex is stored for accounts that
+	// were created in bootstrap balances. These records
+	// are never removed during a reorg.
+	bootstrapCreationIndex = int64(-1)
+
+	// accountCreationBatchSize is the number of bootstrapped
+	// accounts to write in a single database transaction.
+	accountCreationBatchSize = 5000
+)
+
+var _ storage.BlockWorker = (*AccountCreationWorker)(nil)
+
+// AccountCreationWorker implements the storage.BlockWorker interface
+// and ensures no successful operation references an account
+// before a successful operation with a creation type
+// has been applied to it.
+//
+// Accounts are tracked by address (sub-accounts are considered
+// created with their parent account). Unsuccessful operations
+// are ignored because a failed operation on a missing account
+// is often the expected on-chain behavior.
+type AccountCreationWorker struct {
+	asserter       *asserter.Asserter
+	creationTypes  map[string]struct{}
+	failureStorage *failures.Storage
+}
+
+// NewAccountCreationWorker returns a new *AccountCreationWorker.
+func NewAccountCreationWorker(
+	asserter *asserter.Asserter,
+	creationTypes []string,
+	failureStorage *failures.Storage,
+) *AccountCreationWorker {
+	typeMap := map[string]struct{}{}
+	for _, t := range creationTypes {
+		typeMap[t] = struct{}{}
+	}
+
+	return &AccountCreationWorker{
+		asserter:       asserter,
+		creationTypes:  typeMap,
+		failureStorage: failureStorage,
+	}
+}
+
+func getAccountCreationKey(address string) []byte {
+	return []byte(fmt.Sprintf("%s/%s", accountCreationNamespace, address))
+}
+
+func (w *AccountCreationWorker) created(
+	ctx context.Context,
+	transaction storage.DatabaseTransaction,
+	address string,
+) (bool, int64, error) {
+	exists, val, err := transaction.Get(ctx, getAccountCreationKey(address))
+	if err != nil {
+		return false, -1, fmt.Errorf("%w: unable to get account creation record", err)
+	}
+
+	if !exists {
+		return false, -1, nil
+	}
+
+	index, err := strconv.ParseInt(string(val), 10, 64)
+	if err != nil {
+		return false, -1, fmt.Errorf("%w: unable to parse account creation record", err)
+	}
+
+	return true, index, nil
+}
+
+func (w *AccountCreationWorker) setCreated(
+	ctx context.Context,
+	transaction storage.DatabaseTransaction,
+	address string,
+	index int64,
+) error {
+	return transaction.Set(
+		ctx,
+		getAccountCreationKey(address),
+		[]byte(strconv.FormatInt(index, 10)),
+		true,
+	)
+}
+
+// successfulAccountOperations invokes handler on each successful
+// operation in block that references an account.
+func (w *AccountCreationWorker) successfulAccountOperations(
+	block *types.Block,
+	handler func(*types.Transaction, *types.Operation) error,
+) error {
+	for _, tx := range block.Transactions {
+		for _, op := range tx.Operations {
+			if op.Account == nil {
+				continue
+			}
+
+			success, err := w.asserter.OperationSuccessful(op)
+			if err != nil {
+				return fmt.Errorf("%w: unable to check operation success", err)
+			}
+
+			if !success {
+				continue
+			}
+
+			if err := handler(tx, op); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// AddingBlock records all accounts created in block and returns
+// an error listing every operation in block that referenced an
+// account before it was created.
+func (w *AccountCreationWorker) AddingBlock(
+	ctx context.Context,
+	block *types.Block,
+	transaction storage.DatabaseTransaction,
+) (storage.CommitWorker, error) {
+	violations := []string{}
+	err := w.successfulAccountOperations(
+		block,
+		func(tx *types.Transaction, op *types.Operation) error {
+			address := op.Account.Address
+			if _, ok := w.creationTypes[op.Type]; ok {
+				return w.setCreated(ctx, transaction, address, block.BlockIdentifier.Index)
+			}
+
+			exists, _, err := w.created(ctx, transaction, address)
+			if err != nil {
+				return err
+			}
+
+			if !exists {
+				violation := fmt.Sprintf(
+					"%s operation %d (%s) in transaction %s",
+					address,
+					op.OperationIdentifier.Index,
+					op.Type,
+					tx.TransactionIdentifier.Hash,
+				)
+				violations = append(violations, violation)
+
+				// The block transaction is discarded when we return
+				// an error, so the failure must be deferred.
+				w.failureStorage.Defer(&failures.Failure{
+					Kind:        failures.AccountNotCreatedFailure,
+					Block:       block.BlockIdentifier,
+					Transaction: tx.TransactionIdentifier,
+					Operation:   op.OperationIdentifier,
+					Account:     op.Account,
+					Message:     fmt.Sprintf("account referenced before creation: %s", violation),
+				})
+			}
+
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(violations) == 0 {
+		return nil, nil
+	}
+
+	for _, violation := range violations {
+		log.Printf(
+			"account referenced before creation in block %s:%d: %s\n",
+			block.BlockIdentifier.Hash,
+			block.BlockIdentifier.Index,
+			violation,
+		)
+	}
+
+	return nil, fmt.Errorf(
+		"%w: %d violations in block %s:%d [%s]",
+		results.ErrAccountNotCreated,
+		len(violations),
+		block.BlockIdentifier.Hash,
+		block.BlockIdentifier.Index,
+		strings.Join(violations, "; "),
+	)
+}
+
+// RemovingBlock removes all account creation records
+// added in block.
+func (w *AccountCreationWorker) RemovingBlock(
+	ctx context.Context,
+	block *types.Block,
+	transaction storage.DatabaseTransaction,
+) (storage.CommitWorker, error) {
+	err := w.successfulAccountOperations(
+		block,
+		func(tx *types.Transaction, op *types.Operation) error {
+			if _, ok := w.creationTypes[op.Type]; !ok {
+				return nil
+			}
+
+			exists, index, err := w.created(ctx, transaction, op.Account.Address)
+			if err != nil {
+				return err
+			}
+
+			if !exists || index != block.BlockIdentifier.Index {
+				return nil
+			}
+
+			return transaction.Delete(ctx, getAccountCreationKey(op.Account.Address))
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// BootstrapAccounts marks all accounts in the bootstrap
+// balances file at filePath as created.
+func (w *AccountCreationWorker) BootstrapAccounts(
+	ctx context.Context,
+	database storage.Database,
+	filePath string,
+) error {
+	marked := 0
+	dbTx := database.NewDatabaseTransaction(ctx, true)
+	defer func() {
+		dbTx.Discard(ctx)
+	}()
+
+	err := bootstrap.StreamBalances(filePath, func(balance *storage.BootstrapBalance) error {
+		if err := w.setCreated(
+			ctx,
+			dbTx,
+			balance.Account.Address,
+			bootstrapCreationIndex,
+		); err != nil {
+			return fmt.Errorf("%w: unable to mark account as created", err)
+		}
+
+		marked++
+		if marked%accountCreationBatchSize != 0 {
+			return nil
+		}
+
+		if err := dbTx.Commit(ctx); err != nil {
+			return fmt.Errorf("%w: unable to commit account creation records", err)
+		}
+		dbTx = database.NewDatabaseTransaction(ctx, true)
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return dbTx.Commit(ctx)
+}
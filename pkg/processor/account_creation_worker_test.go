@@ -0,0 +1,161 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/coinbase/rosetta-cli/pkg/failures"
+	"github.com/coinbase/rosetta-cli/pkg/results"
+
+	"github.com/coinbase/rosetta-sdk-go/asserter"
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/coinbase/rosetta-sdk-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func creationTestBlock(index int64, ops ...*types.Operation) *types.Block {
+	for i, op := range ops {
+		op.OperationIdentifier = &types.OperationIdentifier{Index: int64(i)}
+	}
+
+	return &types.Block{
+		BlockIdentifier: &types.BlockIdentifier{Index: index},
+		Transactions: []*types.Transaction{
+			{
+				TransactionIdentifier: &types.TransactionIdentifier{Hash: "tx"},
+				Operations:            ops,
+			},
+		},
+	}
+}
+
+func creationTestOp(opType string, status string, address string) *types.Operation {
+	return &types.Operation{
+		Type:    opType,
+		Status:  types.String(status),
+		Account: &types.AccountIdentifier{Address: address},
+	}
+}
+
+func TestAccountCreationWorker(t *testing.T) {
+	ctx := context.Background()
+	dir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(dir)
+
+	localStore, err := storage.NewBadgerStorage(
+		ctx,
+		dir,
+		storage.WithIndexCacheSize(storage.TinyIndexCacheSize),
+	)
+	assert.NoError(t, err)
+	defer localStore.Close(ctx)
+
+	a, err := asserter.NewClientWithOptions(
+		&types.NetworkIdentifier{Blockchain: "blah", Network: "testnet"},
+		&types.BlockIdentifier{Hash: "genesis", Index: 0},
+		[]string{"CREATE", "TRANSFER"},
+		[]*types.OperationStatus{
+			{Status: "SUCCESS", Successful: true},
+			{Status: "FAILURE", Successful: false},
+		},
+		[]*types.Error{},
+		nil,
+	)
+	assert.NoError(t, err)
+
+	failureStorage := failures.NewStorage(localStore)
+	w := NewAccountCreationWorker(a, []string{"CREATE"}, failureStorage)
+
+	addBlock := func(block *types.Block) error {
+		dbTx := localStore.NewDatabaseTransaction(ctx, true)
+		defer dbTx.Discard(ctx)
+
+		if _, err := w.AddingBlock(ctx, block, dbTx); err != nil {
+			return err
+		}
+
+		return dbTx.Commit(ctx)
+	}
+
+	removeBlock := func(block *types.Block) error {
+		dbTx := localStore.NewDatabaseTransaction(ctx, true)
+		defer dbTx.Discard(ctx)
+
+		if _, err := w.RemovingBlock(ctx, block, dbTx); err != nil {
+			return err
+		}
+
+		return dbTx.Commit(ctx)
+	}
+
+	t.Run("created and used in same block", func(t *testing.T) {
+		assert.NoError(t, addBlock(creationTestBlock(
+			1,
+			creationTestOp("CREATE", "SUCCESS", "addr1"),
+			creationTestOp("TRANSFER", "SUCCESS", "addr1"),
+		)))
+	})
+
+	t.Run("failed operation on missing account", func(t *testing.T) {
+		assert.NoError(t, addBlock(creationTestBlock(
+			2,
+			creationTestOp("TRANSFER", "FAILURE", "addr2"),
+		)))
+	})
+
+	t.Run("used before creation", func(t *testing.T) {
+		err := addBlock(creationTestBlock(
+			3,
+			creationTestOp("TRANSFER", "SUCCESS", "addr1"),
+			creationTestOp("TRANSFER", "SUCCESS", "addr2"),
+			creationTestOp("TRANSFER", "SUCCESS", "addr3"),
+			creationTestOp("CREATE", "SUCCESS", "addr2"),
+		))
+		assert.True(t, errors.Is(err, results.ErrAccountNotCreated))
+		assert.Contains(t, err.Error(), "2 violations")
+
+		assert.NoError(t, failureStorage.Flush(ctx))
+		recorded, err := failureStorage.GetAll(ctx)
+		assert.NoError(t, err)
+		assert.Len(t, recorded, 2)
+		for i, address := range []string{"addr2", "addr3"} {
+			assert.Equal(t, failures.AccountNotCreatedFailure, recorded[i].Kind)
+			assert.Equal(t, int64(3), recorded[i].Block.Index)
+			assert.Equal(t, address, recorded[i].Account.Address)
+			assert.Equal(t, int64(i+1), recorded[i].Operation.Index)
+		}
+	})
+
+	t.Run("creation removed in reorg", func(t *testing.T) {
+		block := creationTestBlock(3, creationTestOp("CREATE", "SUCCESS", "addr2"))
+		assert.NoError(t, addBlock(block))
+		assert.NoError(t, addBlock(creationTestBlock(
+			4,
+			creationTestOp("TRANSFER", "SUCCESS", "addr2"),
+		)))
+
+		assert.NoError(t, removeBlock(block))
+		err := addBlock(creationTestBlock(
+			3,
+			creationTestOp("TRANSFER", "SUCCESS", "addr2"),
+		))
+		assert.True(t, errors.Is(err, results.ErrAccountNotCreated))
+	})
+}
@@ -0,0 +1,77 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"sync"
+
+	"github.com/coinbase/rosetta-cli/pkg/history"
+
+	"github.com/coinbase/rosetta-sdk-go/asserter"
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+// AsserterGuard guards the *asserter.Asserter shared by the
+// fetcher, parser, and coin storage. Every component holds the
+// same pointer, so a refreshed asserter must be copied over it
+// in place. Syncing is stopped during a refresh, but reconciliations
+// keep running, so they hold the guard while they use the asserter
+// and refreshes wait for them to finish.
+//
+// A nil *AsserterGuard guards nothing (the asserter is never
+// refreshed).
+type AsserterGuard struct {
+	mutex sync.RWMutex
+}
+
+// NewAsserterGuard returns a new *AsserterGuard.
+func NewAsserterGuard() *AsserterGuard {
+	return &AsserterGuard{}
+}
+
+// Use blocks while the asserter is being refreshed and returns
+// a function that must be called once the asserter is no longer
+// used.
+func (g *AsserterGuard) Use() func() {
+	if g == nil {
+		return func() {}
+	}
+
+	g.mutex.RLock()
+	return g.mutex.RUnlock
+}
+
+// Replace copies refreshed over a once no
+// reconciliation is using it.
+func (g *AsserterGuard) Replace(a *asserter.Asserter, refreshed *asserter.Asserter) {
+	if g != nil {
+		g.mutex.Lock()
+		defer g.mutex.Unlock()
+	}
+
+	*a = *refreshed
+}
+
+// Successful returns a history.SuccessFunc that determines
+// if an operation is successful using a (while holding the
+// guard, so a is not replaced during the check).
+func (g *AsserterGuard) Successful(a *asserter.Asserter) history.SuccessFunc {
+	return func(op *types.Operation) (bool, error) {
+		release := g.Use()
+		defer release()
+
+		return a.OperationSuccessful(op)
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/coinbase/rosetta-sdk-go/asserter"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func guardTestAsserter(t *testing.T, opType string) *asserter.Asserter {
+	a, err := asserter.NewClientWithOptions(
+		&types.NetworkIdentifier{Blockchain: "blah", Network: "testnet"},
+		&types.BlockIdentifier{Hash: "genesis", Index: 0},
+		[]string{opType},
+		[]*types.OperationStatus{{Status: "SUCCESS", Successful: true}},
+		[]*types.Error{},
+		nil,
+	)
+	assert.NoError(t, err)
+
+	return a
+}
+
+func TestAsserterGuard(t *testing.T) {
+	a := guardTestAsserter(t, "TRANSFER")
+	refreshed := guardTestAsserter(t, "FEE")
+
+	// A nil guard replaces the asserter immediately.
+	var nilGuard *AsserterGuard
+	nilGuard.Use()()
+	unguarded := guardTestAsserter(t, "TRANSFER")
+	nilGuard.Replace(unguarded, refreshed)
+	config, err := unguarded.ClientConfiguration()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"FEE"}, config.AllowedOperationTypes)
+
+	// The asserter is only replaced once
+	// it is no longer used.
+	guard := NewAsserterGuard()
+	release := guard.Use()
+	replaced := make(chan struct{})
+	go func() {
+		guard.Replace(a, refreshed)
+		close(replaced)
+	}()
+
+	select {
+	case <-replaced:
+		t.Fatal("asserter replaced while in use")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	release()
+	<-replaced
+
+	successful, err := guard.Successful(a)(&types.Operation{
+		Type:   "FEE",
+		Status: types.String("SUCCESS"),
+	})
+	assert.NoError(t, err)
+	assert.True(t, successful)
+}
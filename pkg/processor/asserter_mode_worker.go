@@ -0,0 +1,416 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/coinbase/rosetta-cli/configuration"
+	"github.com/coinbase/rosetta-cli/pkg/results"
+
+	"github.com/coinbase/rosetta-sdk-go/asserter"
+	"github.com/coinbase/rosetta-sdk-go/fetcher"
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+var _ storage.BlockWorker = (*AsserterModeWorker)(nil)
+
+var (
+	// ErrZeroAmountOperation is returned in strict mode
+	// when a block contains an operation with a zero amount.
+	ErrZeroAmountOperation = errors.New("operation amount is zero")
+
+	// ErrUnknownOperationStatuses is returned in permissive mode
+	// to stop syncing before a block that contains operation statuses
+	// that must be added to the asserter.
+	ErrUnknownOperationStatuses = errors.New("unknown operation statuses must be added to asserter")
+)
+
+// AsserterModeWorker implements the storage.BlockWorker interface
+// and the statefulsyncer.BlockFetcher interface to enforce the
+// configured configuration.AsserterMode.
+//
+// In strict mode, blocks with zero-amount operations are rejected. In
+// permissive mode, blocks that fail assertion only because of warnable
+// violations are accepted and the number of violations is counted in
+// the same database transaction that stores each block. Operations
+// with an unknown status are considered unsuccessful: like with the
+// AsserterRefreshWorker, syncing is stopped so that the status can be
+// added to the shared asserter (see ShouldRefresh and Refresh).
+type AsserterModeWorker struct {
+	network        *types.NetworkIdentifier
+	fetcher        *fetcher.Fetcher
+	guard          *AsserterGuard
+	counterStorage *storage.CounterStorage
+	strict         bool
+	warnings       map[configuration.AsserterViolation]struct{}
+
+	// timestampViolations are the hashes of fetched blocks
+	// that were accepted without a valid timestamp.
+	timestampViolations map[string]struct{}
+
+	// addedStatuses are the unknown statuses that were
+	// added to the asserter and pendingStatuses are the
+	// unknown statuses that will be added on the next
+	// refresh.
+	addedStatuses   map[string]struct{}
+	pendingStatuses map[string]struct{}
+	mutex           sync.Mutex
+}
+
+// NewAsserterModeWorker returns a new *AsserterModeWorker. In
+// permissive mode, if warnings is empty, all classes of
+// violations are treated as warnings.
+func NewAsserterModeWorker(
+	network *types.NetworkIdentifier,
+	fetcher *fetcher.Fetcher,
+	guard *AsserterGuard,
+	counterStorage *storage.CounterStorage,
+	mode configuration.AsserterMode,
+	warnings []configuration.AsserterViolation,
+) *AsserterModeWorker {
+	warningMap := map[configuration.AsserterViolation]struct{}{}
+	if mode == configuration.PermissiveAsserterMode {
+		if len(warnings) == 0 {
+			warnings = configuration.AsserterViolations
+		}
+
+		for _, warning := range warnings {
+			warningMap[warning] = struct{}{}
+		}
+	}
+
+	return &AsserterModeWorker{
+		network:             network,
+		fetcher:             fetcher,
+		guard:               guard,
+		counterStorage:      counterStorage,
+		strict:              mode == configuration.StrictAsserterMode,
+		warnings:            warningMap,
+		timestampViolations: map[string]struct{}{},
+		addedStatuses:       map[string]struct{}{},
+		pendingStatuses:     map[string]struct{}{},
+	}
+}
+
+// warning returns a boolean indicating if
+// violation is treated as a warning.
+func (w *AsserterModeWorker) warning(violation configuration.AsserterViolation) bool {
+	_, ok := w.warnings[violation]
+	return ok
+}
+
+// violationClass returns the class of the asserter violation
+// that caused err (or "" if it cannot be treated as a warning).
+// The fetcher wraps asserter errors with %s, so we must match
+// on error strings instead of using errors.Is.
+func violationClass(err error) configuration.AsserterViolation {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, asserter.ErrOperationStatusInvalid.Error()):
+		return configuration.UnknownOperationStatusViolation
+	case strings.Contains(msg, asserter.ErrTimestampBeforeMin.Error()):
+		return configuration.MissingTimestampViolation
+	default:
+		return ""
+	}
+}
+
+// Block fetches a block. If the block fails assertion
+// because of a violation treated as a warning, the block is
+// re-fetched without assertion and asserted by assertBlock.
+func (w *AsserterModeWorker) Block(
+	ctx context.Context,
+	network *types.NetworkIdentifier,
+	blockIdentifier *types.PartialBlockIdentifier,
+) (*types.Block, error) {
+	block, fetchErr := w.fetcher.BlockRetry(ctx, network, blockIdentifier)
+	if fetchErr == nil {
+		return block, nil
+	}
+
+	if !w.warning(violationClass(fetchErr.Err)) {
+		return nil, fetchErr.Err
+	}
+
+	block, fetchErr = w.fetcher.UnsafeBlock(ctx, network, blockIdentifier)
+	if fetchErr != nil {
+		return nil, fetchErr.Err
+	}
+
+	if err := w.assertBlock(block); err != nil {
+		return nil, err
+	}
+
+	return block, nil
+}
+
+// assertBlock asserts block, ignoring all violations
+// treated as warnings.
+func (w *AsserterModeWorker) assertBlock(block *types.Block) error {
+	checked := *block
+	for {
+		err := w.fetcher.Asserter.Block(&checked)
+		if err == nil {
+			return nil
+		}
+
+		violation := violationClass(err)
+		if !w.warning(violation) {
+			return err
+		}
+
+		switch violation {
+		case configuration.MissingTimestampViolation:
+			if checked.Timestamp == asserter.MinUnixEpoch {
+				return err
+			}
+
+			// We only replace the timestamp of the copy so
+			// that the rest of the block is asserted.
+			checked.Timestamp = asserter.MinUnixEpoch
+			w.mutex.Lock()
+			w.timestampViolations[types.Hash(block.BlockIdentifier)] = struct{}{}
+			w.mutex.Unlock()
+		case configuration.UnknownOperationStatusViolation:
+			return w.queueUnknownStatuses(block)
+		}
+	}
+}
+
+// queueUnknownStatuses adds all operation statuses in block
+// that are not supported by the asserter to pendingStatuses.
+func (w *AsserterModeWorker) queueUnknownStatuses(block *types.Block) error {
+	config, err := w.fetcher.Asserter.ClientConfiguration()
+	if err != nil {
+		return fmt.Errorf("%w: unable to get asserter configuration", err)
+	}
+
+	known := map[string]struct{}{}
+	for _, status := range config.AllowedOperationStatuses {
+		known[status.Status] = struct{}{}
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	statuses := []string{}
+	for _, tx := range block.Transactions {
+		for _, op := range tx.Operations {
+			if op.Status == nil {
+				continue
+			}
+
+			if _, ok := known[*op.Status]; ok {
+				continue
+			}
+
+			known[*op.Status] = struct{}{}
+			statuses = append(statuses, *op.Status)
+			w.pendingStatuses[*op.Status] = struct{}{}
+		}
+	}
+
+	return fmt.Errorf(
+		"%w: %s in block %s:%d",
+		ErrUnknownOperationStatuses,
+		strings.Join(statuses, ","),
+		block.BlockIdentifier.Hash,
+		block.BlockIdentifier.Index,
+	)
+}
+
+// ShouldRefresh returns a boolean indicating if err was
+// caused by unknown operation statuses that must be added
+// to the asserter.
+func (w *AsserterModeWorker) ShouldRefresh(err error) bool {
+	return err != nil && strings.Contains(err.Error(), ErrUnknownOperationStatuses.Error())
+}
+
+// Refresh rebuilds the fetcher's asserter from /network/status
+// and /network/options with all unknown operation statuses
+// added as unsuccessful statuses.
+func (w *AsserterModeWorker) Refresh(ctx context.Context) error {
+	networkStatus, fetchErr := w.fetcher.NetworkStatusRetry(ctx, w.network, nil)
+	if fetchErr != nil {
+		return fmt.Errorf("%w: unable to get network status", fetchErr.Err)
+	}
+
+	networkOptions, fetchErr := w.fetcher.NetworkOptionsRetry(ctx, w.network, nil)
+	if fetchErr != nil {
+		return fmt.Errorf("%w: unable to get network options", fetchErr.Err)
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	for status := range w.pendingStatuses {
+		w.addedStatuses[status] = struct{}{}
+	}
+	w.pendingStatuses = map[string]struct{}{}
+
+	supported := map[string]struct{}{}
+	for _, status := range networkOptions.Allow.OperationStatuses {
+		supported[status.Status] = struct{}{}
+	}
+
+	added := []string{}
+	for status := range w.addedStatuses {
+		if _, ok := supported[status]; ok {
+			continue
+		}
+
+		added = append(added, status)
+	}
+	sort.Strings(added)
+
+	for _, status := range added {
+		networkOptions.Allow.OperationStatuses = append(
+			networkOptions.Allow.OperationStatuses,
+			&types.OperationStatus{Status: status, Successful: false},
+		)
+	}
+
+	refreshed, err := asserter.NewClientWithResponses(w.network, networkStatus, networkOptions)
+	if err != nil {
+		return fmt.Errorf("%w: unable to create asserter with unknown statuses", err)
+	}
+
+	// All components hold the same *asserter.Asserter, so we
+	// must update it in place.
+	w.guard.Replace(w.fetcher.Asserter, refreshed)
+
+	log.Printf(
+		"treating unknown operation statuses %s as unsuccessful\n",
+		strings.Join(added, ","),
+	)
+
+	return nil
+}
+
+// violations returns the number of violations of
+// each class treated as a warning in block.
+func (w *AsserterModeWorker) violations(
+	block *types.Block,
+) (map[configuration.AsserterViolation]int64, error) {
+	counts := map[configuration.AsserterViolation]int64{}
+
+	w.mutex.Lock()
+	hash := types.Hash(block.BlockIdentifier)
+	if _, ok := w.timestampViolations[hash]; ok {
+		counts[configuration.MissingTimestampViolation] = 1
+		delete(w.timestampViolations, hash)
+	}
+
+	zeroAmounts := int64(0)
+	for _, tx := range block.Transactions {
+		for _, op := range tx.Operations {
+			if op.Status != nil {
+				if _, ok := w.addedStatuses[*op.Status]; ok {
+					counts[configuration.UnknownOperationStatusViolation]++
+				}
+			}
+
+			if op.Amount == nil {
+				continue
+			}
+
+			amount, err := types.BigInt(op.Amount.Value)
+			if err == nil && amount.Sign() == 0 {
+				zeroAmounts++
+			}
+		}
+	}
+	w.mutex.Unlock()
+
+	if zeroAmounts == 0 {
+		return counts, nil
+	}
+
+	if w.warning(configuration.ZeroAmountViolation) {
+		counts[configuration.ZeroAmountViolation] = zeroAmounts
+		return counts, nil
+	}
+
+	if w.strict {
+		return nil, fmt.Errorf(
+			"%w: %d operations in block %s:%d",
+			ErrZeroAmountOperation,
+			zeroAmounts,
+			block.BlockIdentifier.Hash,
+			block.BlockIdentifier.Index,
+		)
+	}
+
+	return counts, nil
+}
+
+// AddingBlock returns an error if block violates the
+// strict asserter mode. Otherwise, it increments the
+// counter of each class of violations found in block.
+func (w *AsserterModeWorker) AddingBlock(
+	ctx context.Context,
+	block *types.Block,
+	transaction storage.DatabaseTransaction,
+) (storage.CommitWorker, error) {
+	counts, err := w.violations(block)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, violation := range configuration.AsserterViolations {
+		count, ok := counts[violation]
+		if !ok {
+			continue
+		}
+
+		log.Printf(
+			"asserter warning: %d %s violations in block %s:%d\n",
+			count,
+			violation,
+			block.BlockIdentifier.Hash,
+			block.BlockIdentifier.Index,
+		)
+
+		if _, err := w.counterStorage.UpdateTransactional(
+			ctx,
+			transaction,
+			results.AsserterViolationCounter(violation),
+			big.NewInt(count),
+		); err != nil {
+			return nil, fmt.Errorf("%w: unable to update %s violations counter", err, violation)
+		}
+	}
+
+	return nil, nil
+}
+
+// RemovingBlock is a no-op because violations
+// are counted for every block that is added.
+func (w *AsserterModeWorker) RemovingBlock(
+	ctx context.Context,
+	block *types.Block,
+	transaction storage.DatabaseTransaction,
+) (storage.CommitWorker, error) {
+	return nil, nil
+}
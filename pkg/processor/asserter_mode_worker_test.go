@@ -0,0 +1,209 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/coinbase/rosetta-cli/configuration"
+	"github.com/coinbase/rosetta-cli/pkg/results"
+
+	"github.com/coinbase/rosetta-sdk-go/asserter"
+	"github.com/coinbase/rosetta-sdk-go/fetcher"
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/coinbase/rosetta-sdk-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+// modeTestBlock returns a block at index 1 that passes
+// assertion (unless modified).
+func modeTestBlock(ops ...*types.Operation) *types.Block {
+	block := creationTestBlock(1, ops...)
+	block.BlockIdentifier.Hash = "block 1"
+	block.ParentBlockIdentifier = &types.BlockIdentifier{Hash: "genesis", Index: 0}
+	block.Timestamp = asserter.MinUnixEpoch + 1
+	return block
+}
+
+func TestAsserterModeWorker(t *testing.T) {
+	ctx := context.Background()
+	network := &types.NetworkIdentifier{Blockchain: "blah", Network: "testnet"}
+	a, err := asserter.NewClientWithOptions(
+		network,
+		&types.BlockIdentifier{Hash: "genesis", Index: 0},
+		[]string{"TRANSFER"},
+		[]*types.OperationStatus{
+			{Status: "SUCCESS", Successful: true},
+			{Status: "FAILURE", Successful: false},
+		},
+		[]*types.Error{},
+		nil,
+	)
+	assert.NoError(t, err)
+
+	var tests = map[string]struct {
+		mode     configuration.AsserterMode
+		warnings []configuration.AsserterViolation
+		block    *types.Block
+
+		assertErr error
+		addErr    error
+		expected  map[configuration.AsserterViolation]int64
+	}{
+		"valid block": {
+			mode:     configuration.StrictAsserterMode,
+			block:    modeTestBlock(feeTestOp("TRANSFER", "SUCCESS", "addr1", "10")),
+			expected: map[configuration.AsserterViolation]int64{},
+		},
+		"zero amount in strict mode": {
+			mode:     configuration.StrictAsserterMode,
+			block:    modeTestBlock(feeTestOp("TRANSFER", "SUCCESS", "addr1", "0")),
+			addErr:   ErrZeroAmountOperation,
+			expected: map[configuration.AsserterViolation]int64{},
+		},
+		"zero amount without mode": {
+			block:    modeTestBlock(feeTestOp("TRANSFER", "SUCCESS", "addr1", "0")),
+			expected: map[configuration.AsserterViolation]int64{},
+		},
+		"zero amount in permissive mode": {
+			mode: configuration.PermissiveAsserterMode,
+			block: modeTestBlock(
+				feeTestOp("TRANSFER", "SUCCESS", "addr1", "0"),
+				feeTestOp("TRANSFER", "SUCCESS", "addr2", "0"),
+			),
+			expected: map[configuration.AsserterViolation]int64{
+				configuration.ZeroAmountViolation: 2,
+			},
+		},
+		"missing timestamp in strict mode": {
+			mode: configuration.StrictAsserterMode,
+			block: func() *types.Block {
+				block := modeTestBlock(feeTestOp("TRANSFER", "SUCCESS", "addr1", "10"))
+				block.Timestamp = 0
+				return block
+			}(),
+			assertErr: asserter.ErrTimestampBeforeMin,
+		},
+		"missing timestamp in permissive mode": {
+			mode: configuration.PermissiveAsserterMode,
+			block: func() *types.Block {
+				block := modeTestBlock(feeTestOp("TRANSFER", "SUCCESS", "addr1", "10"))
+				block.Timestamp = 0
+				return block
+			}(),
+			expected: map[configuration.AsserterViolation]int64{
+				configuration.MissingTimestampViolation: 1,
+			},
+		},
+		"missing timestamp not a warning": {
+			mode:     configuration.PermissiveAsserterMode,
+			warnings: []configuration.AsserterViolation{configuration.ZeroAmountViolation},
+			block: func() *types.Block {
+				block := modeTestBlock(feeTestOp("TRANSFER", "SUCCESS", "addr1", "10"))
+				block.Timestamp = 0
+				return block
+			}(),
+			assertErr: asserter.ErrTimestampBeforeMin,
+		},
+		"unknown status in permissive mode": {
+			mode:      configuration.PermissiveAsserterMode,
+			block:     modeTestBlock(feeTestOp("TRANSFER", "REVERTED", "addr1", "10")),
+			assertErr: ErrUnknownOperationStatuses,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			dir, err := utils.CreateTempDir()
+			assert.NoError(t, err)
+			defer utils.RemoveTempDir(dir)
+
+			localStore, err := storage.NewBadgerStorage(
+				ctx,
+				dir,
+				storage.WithIndexCacheSize(storage.TinyIndexCacheSize),
+			)
+			assert.NoError(t, err)
+			defer localStore.Close(ctx)
+
+			counterStorage := storage.NewCounterStorage(localStore)
+			w := NewAsserterModeWorker(
+				network,
+				&fetcher.Fetcher{Asserter: a},
+				nil,
+				counterStorage,
+				test.mode,
+				test.warnings,
+			)
+
+			err = w.assertBlock(test.block)
+			if test.assertErr != nil {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), test.assertErr.Error())
+				return
+			}
+			assert.NoError(t, err)
+
+			dbTx := localStore.NewDatabaseTransaction(ctx, true)
+			defer dbTx.Discard(ctx)
+
+			_, err = w.AddingBlock(ctx, test.block, dbTx)
+			if test.addErr != nil {
+				assert.True(t, errors.Is(err, test.addErr))
+				return
+			}
+			assert.NoError(t, err)
+			assert.NoError(t, dbTx.Commit(ctx))
+
+			for _, violation := range configuration.AsserterViolations {
+				count, err := counterStorage.Get(ctx, results.AsserterViolationCounter(violation))
+				assert.NoError(t, err)
+				assert.Equal(t, big.NewInt(test.expected[violation]), count, violation)
+			}
+		})
+	}
+
+	t.Run("added unknown status", func(t *testing.T) {
+		w := NewAsserterModeWorker(
+			network,
+			&fetcher.Fetcher{Asserter: a},
+			nil,
+			nil,
+			configuration.PermissiveAsserterMode,
+			nil,
+		)
+
+		block := modeTestBlock(
+			feeTestOp("TRANSFER", "REVERTED", "addr1", "10"),
+			feeTestOp("TRANSFER", "REVERTED", "addr2", "-10"),
+		)
+		err := w.assertBlock(block)
+		assert.True(t, w.ShouldRefresh(err))
+		assert.Contains(t, w.pendingStatuses, "REVERTED")
+
+		// Refresh requires a node, so we move
+		// the status ourselves.
+		w.addedStatuses["REVERTED"] = struct{}{}
+		counts, err := w.violations(block)
+		assert.NoError(t, err)
+		assert.Equal(t, map[configuration.AsserterViolation]int64{
+			configuration.UnknownOperationStatusViolation: 2,
+		}, counts)
+	})
+}
@@ -0,0 +1,185 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/coinbase/rosetta-cli/configuration"
+
+	"github.com/coinbase/rosetta-sdk-go/asserter"
+	"github.com/coinbase/rosetta-sdk-go/fetcher"
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+var _ storage.BlockWorker = (*AsserterRefreshWorker)(nil)
+
+// ErrAsserterRefreshRequired is returned by the AsserterRefreshWorker
+// to stop syncing before a block that requires a refreshed asserter.
+var ErrAsserterRefreshRequired = errors.New("asserter refresh required")
+
+// AsserterRefreshWorker implements the storage.BlockWorker interface
+// and stops syncing whenever the asserter derived from /network/options
+// should be refreshed.
+//
+// The asserter is shared by the fetcher, parser, and coin storage, so it
+// is only refreshed once the syncer has stopped (and, with the
+// AsserterGuard, once no reconciliation is using it). The syncer wraps
+// worker and fetch errors with %v, so ShouldRefresh matches on error
+// strings instead of using errors.Is.
+type AsserterRefreshWorker struct {
+	network *types.NetworkIdentifier
+	fetcher *fetcher.Fetcher
+	guard   *AsserterGuard
+
+	indices       []int64
+	frequency     time.Duration
+	onUnknownType bool
+
+	lastRefresh  time.Time
+	pendingIndex int64
+}
+
+// NewAsserterRefreshWorker returns a new *AsserterRefreshWorker.
+func NewAsserterRefreshWorker(
+	network *types.NetworkIdentifier,
+	fetcher *fetcher.Fetcher,
+	guard *AsserterGuard,
+	config *configuration.AsserterRefresh,
+) *AsserterRefreshWorker {
+	indices := make([]int64, len(config.Indices))
+	copy(indices, config.Indices)
+	sort.Slice(indices, func(i, j int) bool { return indices[i] < indices[j] })
+
+	return &AsserterRefreshWorker{
+		network:       network,
+		fetcher:       fetcher,
+		guard:         guard,
+		indices:       indices,
+		frequency:     time.Duration(config.Frequency) * time.Second,
+		onUnknownType: config.OnUnknownType,
+		lastRefresh:   time.Now(),
+		pendingIndex:  -1,
+	}
+}
+
+// AddingBlock returns ErrAsserterRefreshRequired if the asserter
+// should be refreshed before the block is stored.
+func (w *AsserterRefreshWorker) AddingBlock(
+	ctx context.Context,
+	block *types.Block,
+	transaction storage.DatabaseTransaction,
+) (storage.CommitWorker, error) {
+	index := block.BlockIdentifier.Index
+	indexReached := len(w.indices) > 0 && index >= w.indices[0]
+	frequencyElapsed := w.frequency > 0 && time.Since(w.lastRefresh) >= w.frequency
+	if !indexReached && !frequencyElapsed {
+		return nil, nil
+	}
+
+	w.pendingIndex = index
+	return nil, fmt.Errorf("%w: before block %d", ErrAsserterRefreshRequired, index)
+}
+
+// RemovingBlock is a no-op.
+func (w *AsserterRefreshWorker) RemovingBlock(
+	ctx context.Context,
+	block *types.Block,
+	transaction storage.DatabaseTransaction,
+) (storage.CommitWorker, error) {
+	return nil, nil
+}
+
+// ShouldRefresh returns a boolean indicating if err was caused by
+// a requested refresh or (if configured) by an operation type or
+// status missing from the asserter.
+func (w *AsserterRefreshWorker) ShouldRefresh(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	msg := err.Error()
+	if strings.Contains(msg, ErrAsserterRefreshRequired.Error()) {
+		return true
+	}
+
+	if !w.onUnknownType {
+		return false
+	}
+
+	return strings.Contains(msg, asserter.ErrOperationTypeInvalid.Error()) ||
+		strings.Contains(msg, asserter.ErrOperationStatusInvalid.Error())
+}
+
+// Refresh rebuilds the fetcher's asserter from /network/status and
+// /network/options. If the refresh was not requested by AddingBlock
+// (i.e. it was caused by an unknown operation type or status) and
+// the allowed options are unchanged, cause is returned.
+func (w *AsserterRefreshWorker) Refresh(ctx context.Context, cause error) error {
+	networkStatus, fetchErr := w.fetcher.NetworkStatusRetry(ctx, w.network, nil)
+	if fetchErr != nil {
+		return fmt.Errorf("%w: unable to get network status", fetchErr.Err)
+	}
+
+	networkOptions, fetchErr := w.fetcher.NetworkOptionsRetry(ctx, w.network, nil)
+	if fetchErr != nil {
+		return fmt.Errorf("%w: unable to get network options", fetchErr.Err)
+	}
+
+	refreshed, err := asserter.NewClientWithResponses(w.network, networkStatus, networkOptions)
+	if err != nil {
+		return fmt.Errorf("%w: unable to create refreshed asserter", err)
+	}
+
+	previous, err := w.fetcher.Asserter.ClientConfiguration()
+	if err != nil {
+		return fmt.Errorf("%w: unable to get asserter configuration", err)
+	}
+
+	current, err := refreshed.ClientConfiguration()
+	if err != nil {
+		return fmt.Errorf("%w: unable to get refreshed asserter configuration", err)
+	}
+
+	requested := w.pendingIndex != -1
+	if !requested && types.Hash(previous) == types.Hash(current) {
+		return cause
+	}
+
+	// All components hold the same *asserter.Asserter, so we
+	// must update it in place.
+	w.guard.Replace(w.fetcher.Asserter, refreshed)
+
+	for len(w.indices) > 0 && w.indices[0] <= w.pendingIndex {
+		w.indices = w.indices[1:]
+	}
+	w.pendingIndex = -1
+	w.lastRefresh = time.Now()
+
+	log.Printf(
+		"refreshed asserter with operation types %s and statuses %s\n",
+		types.PrintStruct(current.AllowedOperationTypes),
+		types.PrintStruct(current.AllowedOperationStatuses),
+	)
+
+	return nil
+}
@@ -0,0 +1,113 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/coinbase/rosetta-cli/configuration"
+
+	"github.com/coinbase/rosetta-sdk-go/asserter"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAsserterRefreshWorkerAddingBlock(t *testing.T) {
+	var tests = map[string]struct {
+		config  *configuration.AsserterRefresh
+		index   int64
+		refresh bool
+	}{
+		"no refresh configured": {
+			config: &configuration.AsserterRefresh{},
+			index:  100,
+		},
+		"before refresh index": {
+			config: &configuration.AsserterRefresh{Indices: []int64{200, 50}},
+			index:  49,
+		},
+		"at refresh index": {
+			config:  &configuration.AsserterRefresh{Indices: []int64{200, 50}},
+			index:   50,
+			refresh: true,
+		},
+		"past refresh index": {
+			config:  &configuration.AsserterRefresh{Indices: []int64{200, 50}},
+			index:   150,
+			refresh: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			w := NewAsserterRefreshWorker(nil, nil, nil, test.config)
+			_, err := w.AddingBlock(
+				context.Background(),
+				&types.Block{BlockIdentifier: &types.BlockIdentifier{Index: test.index}},
+				nil,
+			)
+			assert.Equal(t, test.refresh, errors.Is(err, ErrAsserterRefreshRequired))
+			assert.Equal(t, test.refresh, w.ShouldRefresh(err))
+		})
+	}
+}
+
+func TestAsserterRefreshWorkerShouldRefresh(t *testing.T) {
+	var tests = map[string]struct {
+		onUnknownType bool
+		err           error
+		refresh       bool
+	}{
+		"nil error": {
+			onUnknownType: true,
+		},
+		"flattened refresh request": {
+			err:     fmt.Errorf("unable to sync: %v", ErrAsserterRefreshRequired),
+			refresh: true,
+		},
+		"unknown type (disabled)": {
+			err: fmt.Errorf("unable to fetch block: %v", asserter.ErrOperationTypeInvalid),
+		},
+		"unknown type": {
+			onUnknownType: true,
+			err:           fmt.Errorf("unable to fetch block: %v", asserter.ErrOperationTypeInvalid),
+			refresh:       true,
+		},
+		"unknown status": {
+			onUnknownType: true,
+			err:           fmt.Errorf("unable to fetch block: %v", asserter.ErrOperationStatusInvalid),
+			refresh:       true,
+		},
+		"unrelated error": {
+			onUnknownType: true,
+			err:           errors.New("connection refused"),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			w := NewAsserterRefreshWorker(
+				nil,
+				nil,
+				nil,
+				&configuration.AsserterRefresh{OnUnknownType: test.onUnknownType},
+			)
+			assert.Equal(t, test.refresh, w.ShouldRefresh(test.err))
+		})
+	}
+}
@@ -0,0 +1,483 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/coinbase/rosetta-sdk-go/parser"
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+// cachedBalance is the balance of an account and currency
+// after block, the last block that changed it.
+type cachedBalance struct {
+	key   string
+	block *types.BlockIdentifier
+
+	// value is nil until the balance is first looked up
+	// (the cache only learns the difference of a change).
+	value *big.Int
+}
+
+// BalanceCache is an LRU cache of the computed balances of
+// recently changed accounts (on many chains, a handful of
+// accounts like popular contracts change in every block). It
+// sits in front of the *storage.BalanceStorage: the balance
+// changes of cached accounts are applied to their cached
+// balances (see Updater) and computed balance lookups (made
+// by the reconciler) are served from memory instead of the
+// database.
+//
+// Each entry is the balance of an account and currency after
+// the last block that changed it. An entry is added when a
+// block changing the account is committed with the balance
+// the block was applied with. From then on, the changes of
+// the account are applied to the cached balance without
+// reading the database. Entries are explicitly invalidated
+// when balances are set (SetBalance), when a block changing
+// them is removed, when they are reconciled after they last
+// changed (so accounts that stopped changing make room for
+// active ones), and when their balances are pruned.
+type BalanceCache struct {
+	database       storage.Database
+	balanceStorage *storage.BalanceStorage
+	size           int
+
+	mutex   sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+
+	// head is the last block whose changes were applied
+	// (nil until the first block is added). Entries only
+	// vouch for balances at or below head.
+	head *types.BlockIdentifier
+
+	// pending are the balances written by the block being
+	// applied (pendingBlock), which are only cached once
+	// the block is committed (BlockAdded).
+	pending      map[string]*big.Int
+	pendingBlock *types.BlockIdentifier
+}
+
+// NewBalanceCache returns a new *BalanceCache that caches
+// at most size balances (of an account and currency) stored
+// in balanceStorage.
+func NewBalanceCache(
+	database storage.Database,
+	balanceStorage *storage.BalanceStorage,
+	size int,
+) *BalanceCache {
+	return &BalanceCache{
+		database:       database,
+		balanceStorage: balanceStorage,
+		size:           size,
+		entries:        map[string]*list.Element{},
+		lru:            list.New(),
+		pending:        map[string]*big.Int{},
+	}
+}
+
+// cacheKey returns the key of account and currency.
+func cacheKey(account *types.AccountIdentifier, currency *types.Currency) string {
+	return types.Hash(&types.AccountCurrency{Account: account, Currency: currency})
+}
+
+// sameBlock returns true if a and b
+// identify the same block.
+func sameBlock(a *types.BlockIdentifier, b *types.BlockIdentifier) bool {
+	return a != nil && b != nil && a.Index == b.Index && a.Hash == b.Hash
+}
+
+// lookup returns the entry of key if it is the balance at
+// index. c.mutex must be held.
+func (c *BalanceCache) lookup(key string, index int64) (*cachedBalance, bool) {
+	element, ok := c.entries[key]
+	if !ok || c.head == nil || index > c.head.Index {
+		return nil, false
+	}
+
+	entry := element.Value.(*cachedBalance)
+	if entry.block.Index > index {
+		return nil, false
+	}
+
+	c.lru.MoveToFront(element)
+	return entry, true
+}
+
+// evict removes key from the cache. c.mutex must be held.
+func (c *BalanceCache) evict(key string) {
+	if element, ok := c.entries[key]; ok {
+		c.lru.Remove(element)
+		delete(c.entries, key)
+	}
+}
+
+// GetBalanceTransactional returns the balance of account and
+// currency at index. Cached balances are returned without
+// reading the database. Otherwise, the balance is read with
+// the *storage.BalanceStorage (the first lookup of a cached
+// account reads it in a new transaction so that it includes
+// every committed block the entry vouches for).
+func (c *BalanceCache) GetBalanceTransactional(
+	ctx context.Context,
+	dbTx storage.DatabaseTransaction,
+	account *types.AccountIdentifier,
+	currency *types.Currency,
+	index int64,
+) (*types.Amount, error) {
+	key := cacheKey(account, currency)
+
+	c.mutex.Lock()
+	entry, ok := c.lookup(key, index)
+	if ok && entry.value != nil {
+		amount := &types.Amount{Value: entry.value.String(), Currency: currency}
+		c.mutex.Unlock()
+		return amount, nil
+	}
+	c.mutex.Unlock()
+
+	if !ok {
+		return c.balanceStorage.GetBalanceTransactional(ctx, dbTx, account, currency, index)
+	}
+
+	readTx := c.database.NewDatabaseTransaction(ctx, false)
+	defer readTx.Discard(ctx)
+
+	amount, err := c.balanceStorage.GetBalanceTransactional(ctx, readTx, account, currency, index)
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := types.BigInt(amount.Value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to parse balance", err)
+	}
+
+	// The entry is only populated if no block changed
+	// the account while the balance was read.
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if element, ok := c.entries[key]; ok && element.Value.(*cachedBalance) == entry &&
+		entry.value == nil && entry.block.Index <= index {
+		entry.value = value
+	}
+
+	return amount, nil
+}
+
+// BlockAdded applies the committed changes of block
+// to the cache. It must be called once the changes are
+// committed (and before the next block is added).
+func (c *BalanceCache) BlockAdded(
+	block *types.BlockIdentifier,
+	changes []*parser.BalanceChange,
+) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	pending := c.pending
+	if !sameBlock(c.pendingBlock, block) {
+		pending = map[string]*big.Int{}
+	}
+	c.pending = map[string]*big.Int{}
+	c.pendingBlock = nil
+
+	for _, change := range changes {
+		key := cacheKey(change.Account, change.Currency)
+		difference, err := types.BigInt(change.Difference)
+		if err != nil {
+			// The parser only returns valid differences, but
+			// an account that cannot be tracked is not cached.
+			c.evict(key)
+			continue
+		}
+
+		// The balance written when the block was applied
+		// is preferred (it includes any balance exemption).
+		applied, hasApplied := pending[key]
+
+		if element, ok := c.entries[key]; ok {
+			entry := element.Value.(*cachedBalance)
+			value := entry.value
+			switch {
+			case hasApplied:
+				value = applied
+			case value != nil:
+				value = new(big.Int).Add(value, difference)
+			}
+
+			// Entries are replaced (instead of modified in place)
+			// so that lookups in progress can detect the change.
+			element.Value = &cachedBalance{
+				key:   key,
+				block: change.Block,
+				value: value,
+			}
+			c.lru.MoveToFront(element)
+			continue
+		}
+
+		c.entries[key] = c.lru.PushFront(&cachedBalance{
+			key:   key,
+			block: change.Block,
+			value: applied,
+		})
+		if c.lru.Len() > c.size {
+			c.evict(c.lru.Back().Value.(*cachedBalance).key)
+		}
+	}
+
+	c.head = block
+}
+
+// BlockRemoved evicts the accounts changed by block (which
+// was removed) from the cache.
+func (c *BalanceCache) BlockRemoved(
+	block *types.Block,
+	changes []*parser.BalanceChange,
+) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, change := range changes {
+		c.evict(cacheKey(change.Account, change.Currency))
+	}
+
+	c.head = block.ParentBlockIdentifier
+	c.pending = map[string]*big.Int{}
+	c.pendingBlock = nil
+}
+
+// current returns the cached balance of key if it is the
+// balance at parentBlock (the parent of the block being
+// applied). c.mutex must be held.
+func (c *BalanceCache) current(key string, parentBlock *types.BlockIdentifier) (*big.Int, bool) {
+	if !sameBlock(c.head, parentBlock) {
+		return nil, false
+	}
+
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := element.Value.(*cachedBalance)
+	if entry.value == nil || entry.block.Index > parentBlock.Index {
+		return nil, false
+	}
+
+	return entry.value, true
+}
+
+// apply records that the balance of key is value after
+// block (which is being applied). c.mutex must be held.
+func (c *BalanceCache) apply(key string, block *types.BlockIdentifier, value *big.Int) {
+	if !sameBlock(c.pendingBlock, block) {
+		c.pending = map[string]*big.Int{}
+		c.pendingBlock = block
+	}
+
+	c.pending[key] = value
+}
+
+// Updater returns a BalanceUpdater that applies balance changes
+// with the cache. The changes of cached accounts are applied to
+// their cached balances (without reading the database) and all
+// other changes are applied by updater. helper must be the helper
+// the *storage.BalanceStorage was initialized with (the changes
+// of accounts with balance exemptions are always applied by
+// updater because they are compared with the live balance).
+//
+// Only UpdateBalance applies changes with the cache, so the
+// returned BalanceUpdater must be used by a *ShardedBalanceWorker.
+func (c *BalanceCache) Updater(
+	updater BalanceUpdater,
+	helper storage.BalanceStorageHelper,
+) BalanceUpdater {
+	return &cachedBalanceUpdater{
+		BalanceUpdater: updater,
+		cache:          c,
+		parser: parser.New(
+			helper.Asserter(),
+			helper.ExemptFunc(),
+			helper.BalanceExemptions(),
+		),
+	}
+}
+
+// cachedBalanceUpdater is the BalanceUpdater
+// returned by BalanceCache.Updater.
+type cachedBalanceUpdater struct {
+	BalanceUpdater
+
+	cache  *BalanceCache
+	parser *parser.Parser
+}
+
+// UpdateBalance applies change. If the balance of the account
+// at parentBlock is cached, the new balance is written without
+// reading the database. Otherwise, change is applied by the
+// wrapped BalanceUpdater and the balance it wrote (which is
+// pending in dbTransaction, so it is not read from the database)
+// is cached once the block is committed.
+func (u *cachedBalanceUpdater) UpdateBalance(
+	ctx context.Context,
+	dbTransaction storage.DatabaseTransaction,
+	change *parser.BalanceChange,
+	parentBlock *types.BlockIdentifier,
+) error {
+	if change.Currency == nil || len(u.parser.FindExemptions(change.Account, change.Currency)) > 0 {
+		return u.BalanceUpdater.UpdateBalance(ctx, dbTransaction, change, parentBlock)
+	}
+
+	key := cacheKey(change.Account, change.Currency)
+	historicalKey := storage.GetHistoricalBalanceKey(
+		change.Account,
+		change.Currency,
+		change.Block.Index,
+	)
+
+	u.cache.mutex.Lock()
+	value, ok := u.cache.current(key, parentBlock)
+	u.cache.mutex.Unlock()
+
+	if !ok {
+		if err := u.BalanceUpdater.UpdateBalance(ctx, dbTransaction, change, parentBlock); err != nil {
+			return err
+		}
+
+		exists, written, err := dbTransaction.Get(ctx, historicalKey)
+		if err != nil {
+			return fmt.Errorf("%w: unable to get updated balance", err)
+		}
+
+		if value, ok := new(big.Int).SetString(string(written), 10); exists && ok {
+			u.cache.mutex.Lock()
+			u.cache.apply(key, change.Block, value)
+			u.cache.mutex.Unlock()
+		}
+
+		return nil
+	}
+
+	difference, err := types.BigInt(change.Difference)
+	if err != nil {
+		return fmt.Errorf("%w: unable to parse balance change", err)
+	}
+
+	newValue := new(big.Int).Add(value, difference)
+	if newValue.Sign() == -1 {
+		return fmt.Errorf(
+			"%w %s:%+v for %+v at %+v",
+			storage.ErrNegativeBalance,
+			newValue.String(),
+			change.Currency,
+			change.Account,
+			change.Block,
+		)
+	}
+
+	if err := dbTransaction.Set(ctx, historicalKey, []byte(newValue.String()), true); err != nil {
+		return err
+	}
+
+	u.cache.mutex.Lock()
+	u.cache.apply(key, change.Block, newValue)
+	u.cache.mutex.Unlock()
+
+	return nil
+}
+
+// SetBalance sets the balance of account with the
+// *storage.BalanceStorage and evicts it from the cache.
+func (c *BalanceCache) SetBalance(
+	ctx context.Context,
+	dbTransaction storage.DatabaseTransaction,
+	account *types.AccountIdentifier,
+	amount *types.Amount,
+	block *types.BlockIdentifier,
+) error {
+	c.Evict(account, amount.Currency)
+
+	return c.balanceStorage.SetBalance(ctx, dbTransaction, account, amount, block)
+}
+
+// Reconciled records that account and currency were
+// reconciled at block with the *storage.BalanceStorage. If
+// the account has not changed since its cached balance (so
+// it was reconciled by inactive reconciliation), it is evicted
+// from the cache.
+func (c *BalanceCache) Reconciled(
+	ctx context.Context,
+	account *types.AccountIdentifier,
+	currency *types.Currency,
+	block *types.BlockIdentifier,
+) error {
+	if err := c.balanceStorage.Reconciled(ctx, account, currency, block); err != nil {
+		return err
+	}
+
+	key := cacheKey(account, currency)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if element, ok := c.entries[key]; ok && element.Value.(*cachedBalance).block.Index < block.Index {
+		c.evict(key)
+	}
+
+	return nil
+}
+
+// Pruned evicts account and currency from the cache if its
+// cached balance is at or below index (where balances were
+// pruned).
+func (c *BalanceCache) Pruned(
+	account *types.AccountIdentifier,
+	currency *types.Currency,
+	index int64,
+) {
+	key := cacheKey(account, currency)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if element, ok := c.entries[key]; ok && element.Value.(*cachedBalance).block.Index <= index {
+		c.evict(key)
+	}
+}
+
+// Evict removes account and currency from the cache.
+func (c *BalanceCache) Evict(account *types.AccountIdentifier, currency *types.Currency) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.evict(cacheKey(account, currency))
+}
+
+// Len returns the number of cached accounts.
+func (c *BalanceCache) Len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.lru.Len()
+}
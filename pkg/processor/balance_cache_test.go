@@ -0,0 +1,318 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/asserter"
+	"github.com/coinbase/rosetta-sdk-go/fetcher"
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/coinbase/rosetta-sdk-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+// countingTransaction counts the reads
+// made on a storage.DatabaseTransaction.
+type countingTransaction struct {
+	storage.DatabaseTransaction
+
+	reads int
+}
+
+func (t *countingTransaction) Get(ctx context.Context, key []byte) (bool, []byte, error) {
+	t.reads++
+	return t.DatabaseTransaction.Get(ctx, key)
+}
+
+func (t *countingTransaction) Scan(
+	ctx context.Context,
+	prefix []byte,
+	seekStart []byte,
+	worker func([]byte, []byte) error,
+	logEntries bool,
+	reverse bool,
+) (int, error) {
+	t.reads++
+	return t.DatabaseTransaction.Scan(ctx, prefix, seekStart, worker, logEntries, reverse)
+}
+
+// countingDatabase counts the reads made on the
+// transactions of a storage.Database.
+type countingDatabase struct {
+	storage.Database
+
+	transactions []*countingTransaction
+}
+
+func (d *countingDatabase) NewDatabaseTransaction(
+	ctx context.Context,
+	write bool,
+) storage.DatabaseTransaction {
+	counting := &countingTransaction{
+		DatabaseTransaction: d.Database.NewDatabaseTransaction(ctx, write),
+	}
+	d.transactions = append(d.transactions, counting)
+
+	return counting
+}
+
+// reads returns the number of reads made since
+// the last call to reads.
+func (d *countingDatabase) reads() int {
+	reads := 0
+	for _, transaction := range d.transactions {
+		reads += transaction.reads
+	}
+	d.transactions = nil
+
+	return reads
+}
+
+// cacheTestBlock returns a block at index with ops
+// whose parent is the block at index-1.
+func cacheTestBlock(index int64, ops ...*types.Operation) *types.Block {
+	block := creationTestBlock(index, ops...)
+	block.BlockIdentifier.Hash = fmt.Sprintf("block %d", index)
+	block.ParentBlockIdentifier = &types.BlockIdentifier{
+		Hash:  fmt.Sprintf("block %d", index-1),
+		Index: index - 1,
+	}
+
+	return block
+}
+
+func TestBalanceCache(t *testing.T) {
+	ctx := context.Background()
+	network := &types.NetworkIdentifier{Blockchain: "blah", Network: "testnet"}
+	a, err := asserter.NewClientWithOptions(
+		network,
+		&types.BlockIdentifier{Hash: "genesis", Index: 0},
+		[]string{"TRANSFER"},
+		[]*types.OperationStatus{{Status: "SUCCESS", Successful: true}},
+		[]*types.Error{},
+		nil,
+	)
+	assert.NoError(t, err)
+
+	helper := NewBalanceStorageHelper(
+		network,
+		fetcher.New("http://localhost", fetcher.WithAsserter(a)),
+		false,
+		nil,
+		nil,
+		false,
+		nil,
+		false,
+	)
+
+	dir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(dir)
+
+	localStore, err := storage.NewBadgerStorage(
+		ctx,
+		dir,
+		storage.WithIndexCacheSize(storage.TinyIndexCacheSize),
+	)
+	assert.NoError(t, err)
+	defer localStore.Close(ctx)
+
+	recorder := &changeRecorder{}
+	balanceStorage := storage.NewBalanceStorage(localStore)
+	balanceStorage.Initialize(helper, recorder)
+	cache := NewBalanceCache(localStore, balanceStorage, 2)
+
+	// Balance changes are applied by a sharded balance worker
+	// whose shard transactions count their reads.
+	counting := &countingDatabase{Database: localStore}
+	worker := NewShardedBalanceWorker(
+		counting,
+		cache.Updater(balanceStorage, helper),
+		helper,
+		recorder,
+		1,
+	)
+
+	account := &types.AccountIdentifier{Address: "addr1"}
+	currency := &types.Currency{Symbol: "BTC", Decimals: 8}
+
+	// addBlock adds a block with ops, updates the cache
+	// once it is committed, and returns the number of
+	// reads made while applying its balance changes.
+	addBlock := func(index int64, ops ...*types.Operation) int {
+		block := cacheTestBlock(index, ops...)
+		dbTx := localStore.NewDatabaseTransaction(ctx, true)
+		commitWorker, err := worker.AddingBlock(ctx, block, dbTx)
+		assert.NoError(t, err)
+		reads := counting.reads()
+		assert.NoError(t, dbTx.Commit(ctx))
+		assert.NoError(t, commitWorker(ctx))
+		cache.BlockAdded(block.BlockIdentifier, recorder.added[len(recorder.added)-1])
+
+		return reads
+	}
+
+	// lookup returns the balance of addr1 at index and the
+	// number of reads made on the transaction passed in.
+	lookup := func(index int64) (string, int) {
+		dbTx := localStore.NewDatabaseTransaction(ctx, false)
+		defer dbTx.Discard(ctx)
+
+		counting := &countingTransaction{DatabaseTransaction: dbTx}
+		amount, err := cache.GetBalanceTransactional(ctx, counting, account, currency, index)
+		assert.NoError(t, err)
+
+		return amount.Value, counting.reads
+	}
+
+	// stored returns the balance of addr1 at
+	// index stored in the database.
+	stored := func(index int64) string {
+		amount, err := balanceStorage.GetBalance(ctx, account, currency, index)
+		assert.NoError(t, err)
+
+		return amount.Value
+	}
+
+	// cached returns the cached balance of addr1 (if known).
+	cached := func() (string, bool) {
+		element, ok := cache.entries[cacheKey(account, currency)]
+		if !ok || element.Value.(*cachedBalance).value == nil {
+			return "", false
+		}
+
+		return element.Value.(*cachedBalance).value.String(), true
+	}
+
+	// The balance of a newly seen account is applied with
+	// the database and cached once the block is committed.
+	reads := addBlock(1, feeTestOp("TRANSFER", "SUCCESS", "addr1", "10"))
+	assert.True(t, reads > 0)
+	assert.Equal(t, 1, cache.Len())
+	value, ok := cached()
+	assert.True(t, ok)
+	assert.Equal(t, "10", value)
+
+	// Changes of cached accounts are applied without
+	// reading the database (and written to it).
+	reads = addBlock(2, feeTestOp("TRANSFER", "SUCCESS", "addr1", "5"))
+	assert.Equal(t, 0, reads)
+	assert.Equal(t, "15", stored(2))
+	value, _ = cached()
+	assert.Equal(t, "15", value)
+
+	value, reads = lookup(2)
+	assert.Equal(t, "15", value)
+	assert.Equal(t, 0, reads)
+
+	// Cached balances cannot go negative.
+	dbTx := localStore.NewDatabaseTransaction(ctx, true)
+	_, err = worker.AddingBlock(
+		ctx,
+		cacheTestBlock(3, feeTestOp("TRANSFER", "SUCCESS", "addr1", "-16")),
+		dbTx,
+	)
+	assert.True(t, errors.Is(err, storage.ErrNegativeBalance))
+	dbTx.Discard(ctx)
+
+	// Balances before the cached balance or after the
+	// last applied block are read from the database.
+	value, reads = lookup(1)
+	assert.Equal(t, "10", value)
+	assert.True(t, reads > 0)
+
+	value, reads = lookup(3)
+	assert.Equal(t, "15", value)
+	assert.True(t, reads > 0)
+
+	// Reconciling a cached account at the block it last
+	// changed does not evict it, reconciling it after it
+	// last changed does.
+	assert.NoError(t, cache.Reconciled(ctx, account, currency, &types.BlockIdentifier{Index: 2}))
+	assert.Equal(t, 1, cache.Len())
+
+	addBlock(3, feeTestOp("TRANSFER", "SUCCESS", "addr2", "1"))
+	assert.NoError(t, cache.Reconciled(ctx, account, currency, &types.BlockIdentifier{Index: 3}))
+	_, ok = cache.entries[cacheKey(account, currency)]
+	assert.False(t, ok)
+
+	// Accounts changed by a removed block are evicted
+	// (so their changes are applied with the database).
+	reads = addBlock(4, feeTestOp("TRANSFER", "SUCCESS", "addr1", "1"))
+	assert.True(t, reads > 0)
+	value, _ = cached()
+	assert.Equal(t, "16", value)
+
+	removed := cacheTestBlock(4, feeTestOp("TRANSFER", "SUCCESS", "addr1", "1"))
+	dbTx = localStore.NewDatabaseTransaction(ctx, true)
+	commitWorker, err := worker.RemovingBlock(ctx, removed, dbTx)
+	assert.NoError(t, err)
+	assert.NoError(t, dbTx.Commit(ctx))
+	assert.NoError(t, commitWorker(ctx))
+	cache.BlockRemoved(removed, recorder.added[len(recorder.added)-1])
+	_, ok = cache.entries[cacheKey(account, currency)]
+	assert.False(t, ok)
+	assert.Equal(t, int64(3), cache.head.Index)
+
+	reads = addBlock(4, feeTestOp("TRANSFER", "SUCCESS", "addr1", "2"))
+	assert.True(t, reads > 0)
+	assert.Equal(t, "17", stored(4))
+	value, _ = cached()
+	assert.Equal(t, "17", value)
+
+	// The least recently changed account is
+	// evicted when the cache is full.
+	addBlock(
+		5,
+		feeTestOp("TRANSFER", "SUCCESS", "addr2", "1"),
+		feeTestOp("TRANSFER", "SUCCESS", "addr3", "1"),
+	)
+	assert.Equal(t, 2, cache.Len())
+	_, ok = cache.entries[cacheKey(account, currency)]
+	assert.False(t, ok)
+
+	addBlock(6, feeTestOp("TRANSFER", "SUCCESS", "addr1", "1"))
+	assert.Equal(t, 2, cache.Len())
+	value, _ = cached()
+	assert.Equal(t, "18", value)
+
+	// Accounts are only evicted when their
+	// cached balance is pruned.
+	cache.Pruned(account, currency, 5)
+	_, ok = cache.entries[cacheKey(account, currency)]
+	assert.True(t, ok)
+	cache.Pruned(account, currency, 6)
+	_, ok = cache.entries[cacheKey(account, currency)]
+	assert.False(t, ok)
+
+	// Setting a balance evicts the account.
+	addBlock(7, feeTestOp("TRANSFER", "SUCCESS", "addr1", "1"))
+	dbTx = localStore.NewDatabaseTransaction(ctx, true)
+	assert.NoError(t, cache.SetBalance(
+		ctx,
+		dbTx,
+		account,
+		&types.Amount{Value: "100", Currency: currency},
+		&types.BlockIdentifier{Index: 7},
+	))
+	dbTx.Discard(ctx)
+	_, ok = cache.entries[cacheKey(account, currency)]
+	assert.False(t, ok)
+}
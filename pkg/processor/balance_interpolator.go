@@ -0,0 +1,229 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+// InterpolationReconciliation is the reconciliation type
+// of reconciliations performed at heights sampled between
+// two successful reconciliations of an account.
+const InterpolationReconciliation = "INTERPOLATION"
+
+// InterpolationHelper is used by the BalanceInterpolator
+// to lookup computed and live balances at sampled heights.
+// It is implemented by *ReconcilerHelper.
+type InterpolationHelper interface {
+	DatabaseTransaction(ctx context.Context) storage.DatabaseTransaction
+
+	CanonicalBlock(
+		ctx context.Context,
+		dbTx storage.DatabaseTransaction,
+		block *types.BlockIdentifier,
+	) (bool, error)
+
+	ComputedBalance(
+		ctx context.Context,
+		dbTx storage.DatabaseTransaction,
+		account *types.AccountIdentifier,
+		currency *types.Currency,
+		index int64,
+	) (*types.Amount, error)
+
+	LiveBalance(
+		ctx context.Context,
+		account *types.AccountIdentifier,
+		currency *types.Currency,
+		index int64,
+	) (*types.Amount, *types.BlockIdentifier, error)
+}
+
+// InterpolationMismatch is a sampled height where the
+// computed balance of an account did not match the
+// live balance.
+type InterpolationMismatch struct {
+	Block           *types.BlockIdentifier
+	ComputedBalance string
+	LiveBalance     string
+}
+
+// BalanceInterpolator checks that the balance of an account
+// is correct at heights between two successful reconciliations
+// of the account. Some implementations only return correct
+// balances at certain heights (for example, at the end of an
+// epoch), which is not caught by reconciliation alone.
+type BalanceInterpolator struct {
+	helper  InterpolationHelper
+	samples int64
+
+	// lastReconciled is the index of the last successful
+	// reconciliation of each *types.AccountCurrency.
+	lastReconciled map[string]int64
+	mutex          sync.Mutex
+}
+
+// NewBalanceInterpolator returns a new *BalanceInterpolator
+// that checks up to samples heights between each pair of
+// successful reconciliations of an account.
+func NewBalanceInterpolator(
+	helper InterpolationHelper,
+	samples uint64,
+) *BalanceInterpolator {
+	return &BalanceInterpolator{
+		helper:         helper,
+		samples:        int64(samples),
+		lastReconciled: map[string]int64{},
+	}
+}
+
+// sampleIndices returns up to b.samples random indices
+// strictly between start and end (in ascending order).
+func (b *BalanceInterpolator) sampleIndices(start int64, end int64) []int64 {
+	gap := end - start - 1
+	if gap <= 0 {
+		return nil
+	}
+
+	if gap <= b.samples {
+		indices := make([]int64, gap)
+		for i := range indices {
+			indices[i] = start + 1 + int64(i)
+		}
+
+		return indices
+	}
+
+	picked := map[int64]struct{}{}
+	indices := []int64{}
+	for int64(len(indices)) < b.samples {
+		index := start + 1 + rand.Int63n(gap) // #nosec G404
+		if _, ok := picked[index]; ok {
+			continue
+		}
+
+		picked[index] = struct{}{}
+		indices = append(indices, index)
+	}
+
+	sort.Slice(indices, func(i, j int) bool { return indices[i] < indices[j] })
+	return indices
+}
+
+// Check is called each time an account is successfully
+// reconciled at block. If the account was previously reconciled,
+// the computed balance is compared to the live balance at sampled
+// heights between the two reconciliations and the first
+// mismatch (if any) is returned.
+//
+// Sampled heights where the computed balance is no longer
+// available (because it was pruned) or where the live balance
+// is returned at a non-canonical block are skipped.
+func (b *BalanceInterpolator) Check(
+	ctx context.Context,
+	account *types.AccountIdentifier,
+	currency *types.Currency,
+	block *types.BlockIdentifier,
+) (*InterpolationMismatch, error) {
+	key := types.Hash(&types.AccountCurrency{Account: account, Currency: currency})
+
+	b.mutex.Lock()
+	lastIndex, ok := b.lastReconciled[key]
+	if !ok || block.Index > lastIndex {
+		b.lastReconciled[key] = block.Index
+	}
+	b.mutex.Unlock()
+
+	if !ok || block.Index <= lastIndex {
+		return nil, nil
+	}
+
+	for _, index := range b.sampleIndices(lastIndex, block.Index) {
+		mismatch, err := b.checkIndex(ctx, account, currency, index)
+		if err != nil {
+			return nil, err
+		}
+
+		if mismatch != nil {
+			return mismatch, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// checkIndex compares the computed balance to the live
+// balance at index.
+func (b *BalanceInterpolator) checkIndex(
+	ctx context.Context,
+	account *types.AccountIdentifier,
+	currency *types.Currency,
+	index int64,
+) (*InterpolationMismatch, error) {
+	liveAmount, liveBlock, err := b.helper.LiveBalance(ctx, account, currency, index)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"%w: unable to get live balance of %s at %d",
+			err,
+			types.PrintStruct(account),
+			index,
+		)
+	}
+
+	dbTx := b.helper.DatabaseTransaction(ctx)
+	defer dbTx.Discard(ctx)
+
+	canonical, err := b.helper.CanonicalBlock(ctx, dbTx, liveBlock)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to determine if block is canonical", err)
+	}
+
+	if !canonical {
+		return nil, nil
+	}
+
+	computedAmount, err := b.helper.ComputedBalance(ctx, dbTx, account, currency, liveBlock.Index)
+	if err != nil {
+		// The computed balance may have been pruned.
+		return nil, nil
+	}
+
+	computed, err := types.BigInt(computedAmount.Value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to parse computed balance", err)
+	}
+
+	live, err := types.BigInt(liveAmount.Value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to parse live balance", err)
+	}
+
+	if computed.Cmp(live) == 0 {
+		return nil, nil
+	}
+
+	return &InterpolationMismatch{
+		Block:           liveBlock,
+		ComputedBalance: computedAmount.Value,
+		LiveBalance:     liveAmount.Value,
+	}, nil
+}
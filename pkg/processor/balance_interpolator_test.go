@@ -0,0 +1,170 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/coinbase/rosetta-sdk-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+var _ InterpolationHelper = (*ReconcilerHelper)(nil)
+
+// interpolationHelper returns balances from
+// maps of index to balance.
+type interpolationHelper struct {
+	database storage.Database
+
+	computed map[int64]string
+	live     map[int64]string
+	orphaned map[int64]bool
+
+	liveLookups []int64
+}
+
+func (h *interpolationHelper) DatabaseTransaction(
+	ctx context.Context,
+) storage.DatabaseTransaction {
+	return h.database.NewDatabaseTransaction(ctx, false)
+}
+
+func (h *interpolationHelper) CanonicalBlock(
+	ctx context.Context,
+	dbTx storage.DatabaseTransaction,
+	block *types.BlockIdentifier,
+) (bool, error) {
+	return !h.orphaned[block.Index], nil
+}
+
+func (h *interpolationHelper) ComputedBalance(
+	ctx context.Context,
+	dbTx storage.DatabaseTransaction,
+	account *types.AccountIdentifier,
+	currency *types.Currency,
+	index int64,
+) (*types.Amount, error) {
+	value, ok := h.computed[index]
+	if !ok {
+		return nil, errors.New("balance pruned")
+	}
+
+	return &types.Amount{Value: value, Currency: currency}, nil
+}
+
+func (h *interpolationHelper) LiveBalance(
+	ctx context.Context,
+	account *types.AccountIdentifier,
+	currency *types.Currency,
+	index int64,
+) (*types.Amount, *types.BlockIdentifier, error) {
+	h.liveLookups = append(h.liveLookups, index)
+	return &types.Amount{Value: h.live[index], Currency: currency},
+		&types.BlockIdentifier{Hash: fmt.Sprintf("block %d", index), Index: index},
+		nil
+}
+
+func TestBalanceInterpolator(t *testing.T) {
+	ctx := context.Background()
+	dir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(dir)
+
+	database, err := storage.NewBadgerStorage(
+		ctx,
+		dir,
+		storage.WithIndexCacheSize(storage.TinyIndexCacheSize),
+	)
+	assert.NoError(t, err)
+	defer database.Close(ctx)
+
+	account := &types.AccountIdentifier{Address: "addr"}
+	currency := &types.Currency{Symbol: "BTC", Decimals: 8}
+	block := func(index int64) *types.BlockIdentifier {
+		return &types.BlockIdentifier{Hash: fmt.Sprintf("block %d", index), Index: index}
+	}
+
+	t.Run("all heights checked", func(t *testing.T) {
+		helper := &interpolationHelper{
+			database: database,
+			computed: map[int64]string{11: "10", 12: "20", 13: "30"},
+			live:     map[int64]string{11: "10", 12: "20", 13: "30"},
+		}
+		interpolator := NewBalanceInterpolator(helper, 5)
+
+		mismatch, err := interpolator.Check(ctx, account, currency, block(10))
+		assert.NoError(t, err)
+		assert.Nil(t, mismatch)
+		assert.Empty(t, helper.liveLookups)
+
+		mismatch, err = interpolator.Check(ctx, account, currency, block(14))
+		assert.NoError(t, err)
+		assert.Nil(t, mismatch)
+		assert.Equal(t, []int64{11, 12, 13}, helper.liveLookups)
+
+		// Reconciliations at or before the last reconciliation
+		// are not checked.
+		mismatch, err = interpolator.Check(ctx, account, currency, block(12))
+		assert.NoError(t, err)
+		assert.Nil(t, mismatch)
+		assert.Len(t, helper.liveLookups, 3)
+	})
+
+	t.Run("sampled heights", func(t *testing.T) {
+		helper := &interpolationHelper{database: database}
+		interpolator := NewBalanceInterpolator(helper, 3)
+
+		_, err := interpolator.Check(ctx, account, currency, block(0))
+		assert.NoError(t, err)
+		_, err = interpolator.Check(ctx, account, currency, block(1000))
+		assert.NoError(t, err)
+
+		assert.Len(t, helper.liveLookups, 3)
+		for i, index := range helper.liveLookups {
+			assert.True(t, index > 0 && index < 1000)
+			if i > 0 {
+				assert.True(t, index > helper.liveLookups[i-1])
+			}
+		}
+	})
+
+	t.Run("mismatch", func(t *testing.T) {
+		helper := &interpolationHelper{
+			database: database,
+			computed: map[int64]string{11: "10", 13: "30", 14: "40"},
+			live:     map[int64]string{11: "10", 12: "15", 13: "30", 14: "45"},
+			orphaned: map[int64]bool{13: true},
+		}
+		interpolator := NewBalanceInterpolator(helper, 5)
+
+		_, err := interpolator.Check(ctx, account, currency, block(10))
+		assert.NoError(t, err)
+
+		// Index 12 is pruned and index 13 is orphaned,
+		// so the first mismatch is at index 14.
+		mismatch, err := interpolator.Check(ctx, account, currency, block(15))
+		assert.NoError(t, err)
+		assert.Equal(t, &InterpolationMismatch{
+			Block:           block(14),
+			ComputedBalance: "40",
+			LiveBalance:     "45",
+		}, mismatch)
+	})
+}
@@ -16,7 +16,13 @@ package processor
 
 import (
 	"context"
+	"fmt"
+	"math/big"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/coinbase/rosetta-cli/pkg/history"
 	"github.com/coinbase/rosetta-cli/pkg/logger"
 
 	"github.com/coinbase/rosetta-sdk-go/parser"
@@ -27,6 +33,10 @@ import (
 
 var _ storage.BalanceStorageHandler = (*BalanceStorageHandler)(nil)
 
+// backlogPollInterval is how often the size of the active
+// reconciliation backlog is checked while syncing is blocked.
+const backlogPollInterval = 100 * time.Millisecond
+
 // BalanceStorageHandler is invoked whenever a block is added
 // or removed from block storage so that balance changes
 // can be sent to other functions (ex: reconciler).
@@ -36,6 +46,30 @@ type BalanceStorageHandler struct {
 
 	reconcile          bool
 	interestingAccount *types.AccountCurrency
+
+	// interestingAccounts are reconciled at every block
+	// (instead of by the reconciler) so that they can be
+	// replaced while syncing.
+	interestingAccounts []*types.AccountCurrency
+	interestingMutex    sync.RWMutex
+
+	// When populated, every balance-changing operation (and
+	// the computed balance after it) is written to the
+	// balance operation stream of the logger.
+	successful     history.SuccessFunc
+	database       storage.Database
+	balanceStorage *storage.BalanceStorage
+
+	// When backlogSize is populated, changes are only queued once
+	// they fit in the active reconciliation backlog. blockedNanos
+	// is the total time spent waiting (accessed atomically).
+	backlogSize  int
+	blockedNanos int64
+
+	// When balanceCache is populated, it is updated with
+	// the changes of each block (before they are queued
+	// for reconciliation).
+	balanceCache *BalanceCache
 }
 
 // NewBalanceStorageHandler returns a new *BalanceStorageHandler.
@@ -53,13 +87,313 @@ func NewBalanceStorageHandler(
 	}
 }
 
+// SetInterestingAccounts replaces the accounts that are
+// reconciled at every block (even if their balance did not
+// change).
+func (h *BalanceStorageHandler) SetInterestingAccounts(accounts []*types.AccountCurrency) {
+	h.interestingMutex.Lock()
+	defer h.interestingMutex.Unlock()
+
+	h.interestingAccounts = accounts
+}
+
+// EnableBalanceOperations writes every balance-changing operation
+// (and the computed balance after it, looked up in balanceStorage)
+// to the balance operation stream of the logger.
+func (h *BalanceStorageHandler) EnableBalanceOperations(
+	successful history.SuccessFunc,
+	database storage.Database,
+	balanceStorage *storage.BalanceStorage,
+) {
+	h.successful = successful
+	h.database = database
+	h.balanceStorage = balanceStorage
+}
+
+// EnableBacklogBlocking blocks syncing until each change fits
+// in the active reconciliation backlog (of size backlogSize)
+// instead of skipping the reconciliation of changes that do not
+// fit. The changes of a block are queued as room is made in the
+// backlog, so blocks with more changes than the backlog holds are
+// also reconciled in full.
+func (h *BalanceStorageHandler) EnableBacklogBlocking(backlogSize int) {
+	h.backlogSize = backlogSize
+}
+
+// EnableBalanceCache updates balanceCache with the
+// changes of every block added or removed.
+func (h *BalanceStorageHandler) EnableBalanceCache(balanceCache *BalanceCache) {
+	h.balanceCache = balanceCache
+}
+
+// BlockedDuration returns the total time syncing was blocked
+// waiting for room in the active reconciliation backlog.
+func (h *BalanceStorageHandler) BlockedDuration() time.Duration {
+	return time.Duration(atomic.LoadInt64(&h.blockedNanos))
+}
+
+// waitForBacklog blocks until there is room in a backlog of
+// size backlogSize that currently holds queueSize() changes
+// and returns the number of changes that can be added to it.
+func waitForBacklog(
+	ctx context.Context,
+	queueSize func() int,
+	backlogSize int,
+) (int, error) {
+	for {
+		if room := backlogSize - queueSize(); room > 0 {
+			return room, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-time.After(backlogPollInterval):
+		}
+	}
+}
+
+// queueChanges queues changes for reconciliation. If backlog
+// blocking is enabled, changes are only queued once there is
+// room for them in the backlog.
+func (h *BalanceStorageHandler) queueChanges(
+	ctx context.Context,
+	block *types.BlockIdentifier,
+	changes []*parser.BalanceChange,
+) error {
+	if h.backlogSize <= 0 || len(changes) == 0 {
+		return h.reconciler.QueueChanges(ctx, block, changes)
+	}
+
+	for len(changes) > 0 {
+		start := time.Now()
+		room, err := waitForBacklog(ctx, h.reconciler.QueueSize, h.backlogSize)
+		if err != nil {
+			return err
+		}
+
+		atomic.AddInt64(&h.blockedNanos, int64(time.Since(start)))
+
+		if room > len(changes) {
+			room = len(changes)
+		}
+
+		if err := h.reconciler.QueueChanges(ctx, block, changes[:room]); err != nil {
+			return err
+		}
+
+		changes = changes[room:]
+	}
+
+	return nil
+}
+
+// accountCurrencyKey returns the key of the
+// account currency of a balance change.
+func accountCurrencyKey(account *types.AccountIdentifier, currency *types.Currency) string {
+	return types.Hash(&types.AccountCurrency{Account: account, Currency: currency})
+}
+
+// balanceOperations returns a *logger.BalanceOperation for each
+// successful operation in block that changed the balance of an
+// account currency in changes (other operations did not change
+// a tracked balance) and the sum of the differences of the
+// operations of each account currency.
+func balanceOperations(
+	block *types.Block,
+	changes []*parser.BalanceChange,
+	successful history.SuccessFunc,
+) ([]*logger.BalanceOperation, map[string]*big.Int, error) {
+	tracked := map[string]struct{}{}
+	for _, change := range changes {
+		tracked[accountCurrencyKey(change.Account, change.Currency)] = struct{}{}
+	}
+
+	operations := []*logger.BalanceOperation{}
+	sums := map[string]*big.Int{}
+	for _, tx := range block.Transactions {
+		for _, op := range tx.Operations {
+			if op.Account == nil || op.Amount == nil {
+				continue
+			}
+
+			key := accountCurrencyKey(op.Account, op.Amount.Currency)
+			if _, ok := tracked[key]; !ok {
+				continue
+			}
+
+			success, err := successful(op)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%w: unable to determine if operation is successful", err)
+			}
+
+			if !success {
+				continue
+			}
+
+			difference, err := types.BigInt(op.Amount.Value)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%w: unable to parse amount", err)
+			}
+
+			if _, ok := sums[key]; !ok {
+				sums[key] = big.NewInt(0)
+			}
+			sums[key].Add(sums[key], difference)
+
+			operations = append(operations, &logger.BalanceOperation{
+				Block:          block.BlockIdentifier,
+				Transaction:    tx.TransactionIdentifier,
+				OperationIndex: op.OperationIdentifier.Index,
+				NetworkIndex:   op.OperationIdentifier.NetworkIndex,
+				Type:           op.Type,
+				Account:        op.Account,
+				Currency:       op.Amount.Currency,
+				Difference:     op.Amount.Value,
+			})
+		}
+	}
+
+	return operations, sums, nil
+}
+
+// applyBalances populates the balance of each operation with the
+// running balance of its account currency, starting at the balance
+// in start. Operations of account currencies missing from start
+// are not populated.
+func applyBalances(operations []*logger.BalanceOperation, start map[string]*big.Int) {
+	balances := map[string]*big.Int{}
+	for key, balance := range start {
+		balances[key] = new(big.Int).Set(balance)
+	}
+
+	for _, operation := range operations {
+		balance, ok := balances[accountCurrencyKey(operation.Account, operation.Currency)]
+		if !ok {
+			continue
+		}
+
+		difference, _ := new(big.Int).SetString(operation.Difference, 10)
+		balance.Add(balance, difference)
+		operation.Balance = balance.String()
+	}
+}
+
+// streamBalanceOperations writes the balance-changing operations
+// in block to the balance operation stream of the logger. The
+// computed balance before block is looked up after block was
+// added (or removed), so it must be called after the balance
+// changes of block are committed.
+func (h *BalanceStorageHandler) streamBalanceOperations(
+	ctx context.Context,
+	block *types.Block,
+	changes []*parser.BalanceChange,
+	removed bool,
+) error {
+	if h.successful == nil {
+		return nil
+	}
+
+	operations, sums, err := balanceOperations(block, changes, h.successful)
+	if err != nil {
+		return err
+	}
+
+	dbTx := h.database.NewDatabaseTransaction(ctx, false)
+	defer dbTx.Discard(ctx)
+
+	// When a block is added, the balance before it is the
+	// balance after it minus all differences in it. When
+	// a block is removed, it is the balance at its parent.
+	index := block.BlockIdentifier.Index
+	if removed {
+		index = block.ParentBlockIdentifier.Index
+	}
+
+	start := map[string]*big.Int{}
+	for _, change := range changes {
+		key := accountCurrencyKey(change.Account, change.Currency)
+		sum, ok := sums[key]
+		if !ok {
+			continue
+		}
+
+		amount, err := h.balanceStorage.GetBalanceTransactional(
+			ctx,
+			dbTx,
+			change.Account,
+			change.Currency,
+			index,
+		)
+		if err != nil {
+			continue
+		}
+
+		balance, err := types.BigInt(amount.Value)
+		if err != nil {
+			continue
+		}
+
+		if !removed {
+			balance.Sub(balance, sum)
+		}
+		start[key] = balance
+	}
+
+	applyBalances(operations, start)
+	return h.logger.BalanceOperationStream(ctx, removed, operations)
+}
+
+// addInterestingChanges returns changes with a change with a
+// difference of 0 for each interesting account that did not
+// change in block.
+func (h *BalanceStorageHandler) addInterestingChanges(
+	block *types.BlockIdentifier,
+	changes []*parser.BalanceChange,
+) []*parser.BalanceChange {
+	h.interestingMutex.RLock()
+	defer h.interestingMutex.RUnlock()
+
+	if len(h.interestingAccounts) == 0 {
+		return changes
+	}
+
+	changed := map[string]struct{}{}
+	for _, change := range changes {
+		changed[types.Hash(&types.AccountCurrency{
+			Account:  change.Account,
+			Currency: change.Currency,
+		})] = struct{}{}
+	}
+
+	for _, account := range h.interestingAccounts {
+		if _, ok := changed[types.Hash(account)]; ok {
+			continue
+		}
+
+		changes = append(changes, &parser.BalanceChange{
+			Account:    account.Account,
+			Currency:   account.Currency,
+			Block:      block,
+			Difference: "0",
+		})
+	}
+
+	return changes
+}
+
 // BlockAdded is called whenever a block is committed to BlockStorage.
 func (h *BalanceStorageHandler) BlockAdded(
 	ctx context.Context,
 	block *types.Block,
 	changes []*parser.BalanceChange,
 ) error {
+	if h.balanceCache != nil {
+		h.balanceCache.BlockAdded(block.BlockIdentifier, changes)
+	}
+
 	_ = h.logger.BalanceStream(ctx, changes)
+	_ = h.streamBalanceOperations(ctx, block, changes, false)
 
 	// When testing, it can be useful to not run any reconciliations to just check
 	// if blocks are well formatted and balances don't go negative.
@@ -87,11 +421,13 @@ func (h *BalanceStorageHandler) BlockAdded(
 		} else {
 			changes = []*parser.BalanceChange{}
 		}
+	} else {
+		changes = h.addInterestingChanges(block.BlockIdentifier, changes)
 	}
 
 	// Mark accounts for reconciliation...this may be
 	// blocking
-	return h.reconciler.QueueChanges(ctx, block.BlockIdentifier, changes)
+	return h.queueChanges(ctx, block.BlockIdentifier, changes)
 }
 
 // BlockRemoved is called whenever a block is removed from BlockStorage.
@@ -100,7 +436,12 @@ func (h *BalanceStorageHandler) BlockRemoved(
 	block *types.Block,
 	changes []*parser.BalanceChange,
 ) error {
+	if h.balanceCache != nil {
+		h.balanceCache.BlockRemoved(block, changes)
+	}
+
 	_ = h.logger.BalanceStream(ctx, changes)
+	_ = h.streamBalanceOperations(ctx, block, changes, true)
 
 	// We only attempt to reconciler changes when blocks are added,
 	// not removed
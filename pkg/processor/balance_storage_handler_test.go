@@ -0,0 +1,271 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/coinbase/rosetta-cli/pkg/logger"
+
+	"github.com/coinbase/rosetta-sdk-go/parser"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddInterestingChanges(t *testing.T) {
+	block := &types.BlockIdentifier{Hash: "block 1", Index: 1}
+	btc := &types.Currency{Symbol: "BTC", Decimals: 8}
+	account1 := &types.AccountCurrency{
+		Account:  &types.AccountIdentifier{Address: "addr1"},
+		Currency: btc,
+	}
+	account2 := &types.AccountCurrency{
+		Account:  &types.AccountIdentifier{Address: "addr2"},
+		Currency: btc,
+	}
+	change1 := &parser.BalanceChange{
+		Account:    account1.Account,
+		Currency:   btc,
+		Block:      block,
+		Difference: "100",
+	}
+
+	var tests = map[string]struct {
+		interestingAccounts []*types.AccountCurrency
+		expected            []*parser.BalanceChange
+	}{
+		"no interesting accounts": {
+			expected: []*parser.BalanceChange{change1},
+		},
+		"interesting account changed": {
+			interestingAccounts: []*types.AccountCurrency{account1},
+			expected:            []*parser.BalanceChange{change1},
+		},
+		"interesting account not changed": {
+			interestingAccounts: []*types.AccountCurrency{account1, account2},
+			expected: []*parser.BalanceChange{
+				change1,
+				{
+					Account:    account2.Account,
+					Currency:   btc,
+					Block:      block,
+					Difference: "0",
+				},
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			h := NewBalanceStorageHandler(nil, nil, true, nil)
+			h.SetInterestingAccounts(test.interestingAccounts)
+
+			changes := h.addInterestingChanges(block, []*parser.BalanceChange{change1})
+			assert.Equal(t, test.expected, changes)
+		})
+	}
+}
+
+func TestBalanceOperations(t *testing.T) {
+	btc := &types.Currency{Symbol: "BTC", Decimals: 8}
+	eth := &types.Currency{Symbol: "ETH", Decimals: 18}
+	addr1 := &types.AccountIdentifier{Address: "addr1"}
+	addr2 := &types.AccountIdentifier{Address: "addr2"}
+	success := types.String("SUCCESS")
+	failure := types.String("FAILURE")
+	networkIndex := int64(5)
+
+	op := func(
+		index int64,
+		account *types.AccountIdentifier,
+		value string,
+		currency *types.Currency,
+		status *string,
+	) *types.Operation {
+		return &types.Operation{
+			OperationIdentifier: &types.OperationIdentifier{Index: index},
+			Type:                "Transfer",
+			Status:              status,
+			Account:             account,
+			Amount:              &types.Amount{Value: value, Currency: currency},
+		}
+	}
+
+	block := &types.Block{
+		BlockIdentifier:       &types.BlockIdentifier{Hash: "block 1", Index: 1},
+		ParentBlockIdentifier: &types.BlockIdentifier{Hash: "block 0", Index: 0},
+		Transactions: []*types.Transaction{
+			{
+				TransactionIdentifier: &types.TransactionIdentifier{Hash: "tx 1"},
+				Operations: []*types.Operation{
+					op(0, addr1, "-100", btc, success),
+					op(1, addr2, "100", btc, success),
+					op(2, addr1, "-50", btc, failure),
+				},
+			},
+			{
+				TransactionIdentifier: &types.TransactionIdentifier{Hash: "tx 2"},
+				Operations: []*types.Operation{
+					op(0, addr1, "-10", btc, success),
+					op(1, addr1, "-5", eth, success),
+					{
+						OperationIdentifier: &types.OperationIdentifier{
+							Index:        2,
+							NetworkIndex: &networkIndex,
+						},
+						Type:    "Fee",
+						Status:  success,
+						Account: addr2,
+						Amount:  &types.Amount{Value: "10", Currency: btc},
+					},
+				},
+			},
+		},
+	}
+
+	// ETH balances of addr1 are not tracked.
+	changes := []*parser.BalanceChange{
+		{Account: addr1, Currency: btc, Block: block.BlockIdentifier, Difference: "-110"},
+		{Account: addr2, Currency: btc, Block: block.BlockIdentifier, Difference: "110"},
+	}
+
+	successful := func(op *types.Operation) (bool, error) {
+		return *op.Status == *success, nil
+	}
+
+	operations, sums, err := balanceOperations(block, changes, successful)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]*big.Int{
+		accountCurrencyKey(addr1, btc): big.NewInt(-110),
+		accountCurrencyKey(addr2, btc): big.NewInt(110),
+	}, sums)
+
+	// The balance of addr2 could not be looked up.
+	applyBalances(operations, map[string]*big.Int{
+		accountCurrencyKey(addr1, btc): big.NewInt(1000),
+	})
+
+	assert.Equal(t, []*logger.BalanceOperation{
+		{
+			Block:          block.BlockIdentifier,
+			Transaction:    &types.TransactionIdentifier{Hash: "tx 1"},
+			OperationIndex: 0,
+			Type:           "Transfer",
+			Account:        addr1,
+			Currency:       btc,
+			Difference:     "-100",
+			Balance:        "900",
+		},
+		{
+			Block:          block.BlockIdentifier,
+			Transaction:    &types.TransactionIdentifier{Hash: "tx 1"},
+			OperationIndex: 1,
+			Type:           "Transfer",
+			Account:        addr2,
+			Currency:       btc,
+			Difference:     "100",
+		},
+		{
+			Block:          block.BlockIdentifier,
+			Transaction:    &types.TransactionIdentifier{Hash: "tx 2"},
+			OperationIndex: 0,
+			Type:           "Transfer",
+			Account:        addr1,
+			Currency:       btc,
+			Difference:     "-10",
+			Balance:        "890",
+		},
+		{
+			Block:          block.BlockIdentifier,
+			Transaction:    &types.TransactionIdentifier{Hash: "tx 2"},
+			OperationIndex: 2,
+			NetworkIndex:   &networkIndex,
+			Type:           "Fee",
+			Account:        addr2,
+			Currency:       btc,
+			Difference:     "10",
+		},
+	}, operations)
+}
+
+func TestWaitForBacklog(t *testing.T) {
+	ctx := context.Background()
+
+	// Each check of the queue size simulates
+	// a reconciler worker draining 1 change.
+	queue := func(size int) func() int {
+		return func() int {
+			current := size
+			if size > 0 {
+				size--
+			}
+
+			return current
+		}
+	}
+
+	var tests = map[string]struct {
+		queued  int
+		backlog int
+
+		room   int
+		checks int
+	}{
+		"empty backlog": {
+			queued:  0,
+			backlog: 5,
+			room:    5,
+			checks:  1,
+		},
+		"room in backlog": {
+			queued:  2,
+			backlog: 5,
+			room:    3,
+			checks:  1,
+		},
+		"backlog full": {
+			queued:  6,
+			backlog: 5,
+			room:    1,
+			checks:  3,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			checks := 0
+			queueSize := queue(test.queued)
+			room, err := waitForBacklog(ctx, func() int {
+				checks++
+				return queueSize()
+			}, test.backlog)
+			assert.NoError(t, err)
+			assert.Equal(t, test.room, room)
+			assert.Equal(t, test.checks, checks)
+		})
+	}
+
+	t.Run("canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		cancel()
+
+		room, err := waitForBacklog(ctx, func() int { return 10 }, 5)
+		assert.True(t, errors.Is(err, context.Canceled))
+		assert.Equal(t, 0, room)
+	})
+}
@@ -17,6 +17,7 @@ package processor
 import (
 	"context"
 	"fmt"
+	"sync"
 
 	"github.com/coinbase/rosetta-sdk-go/asserter"
 	"github.com/coinbase/rosetta-sdk-go/fetcher"
@@ -38,12 +39,22 @@ type BalanceStorageHelper struct {
 	// Configuration settings
 	lookupBalanceByBlock bool
 	exemptAccounts       map[string]struct{}
+	exemptMutex          sync.RWMutex
+	currencyFilter       *CurrencyFilter
 	balanceExemptions    []*types.BalanceExemption
 	initialFetchDisabled bool
 
 	// Interesting-only Parsing
 	interestingOnly      bool
 	interestingAddresses map[string]struct{}
+
+	// Subscribed-only Parsing (if nil,
+	// all accounts are tracked)
+	subscribedAccounts map[string]struct{}
+
+	// Checkpoint balances (if nil, fetched
+	// balances are not recorded)
+	checkpoints *CheckpointBalanceWorker
 }
 
 // NewBalanceStorageHelper returns a new BalanceStorageHelper.
@@ -52,23 +63,17 @@ func NewBalanceStorageHelper(
 	fetcher *fetcher.Fetcher,
 	lookupBalanceByBlock bool,
 	exemptAccounts []*types.AccountCurrency,
+	currencyFilter *CurrencyFilter,
 	interestingOnly bool,
 	balanceExemptions []*types.BalanceExemption,
 	initialFetchDisabled bool,
 ) *BalanceStorageHelper {
-	exemptMap := map[string]struct{}{}
-
-	// Pre-process exemptAccounts on initialization
-	// to provide fast lookup while syncing.
-	for _, account := range exemptAccounts {
-		exemptMap[types.Hash(account)] = struct{}{}
-	}
-
 	return &BalanceStorageHelper{
 		network:              network,
 		fetcher:              fetcher,
 		lookupBalanceByBlock: lookupBalanceByBlock,
-		exemptAccounts:       exemptMap,
+		exemptAccounts:       exemptAccountMap(exemptAccounts),
+		currencyFilter:       currencyFilter,
 		interestingAddresses: map[string]struct{}{},
 		interestingOnly:      interestingOnly,
 		balanceExemptions:    balanceExemptions,
@@ -76,6 +81,17 @@ func NewBalanceStorageHelper(
 	}
 }
 
+// exemptAccountMap pre-processes exemptAccounts
+// to provide fast lookup while syncing.
+func exemptAccountMap(exemptAccounts []*types.AccountCurrency) map[string]struct{} {
+	exemptMap := map[string]struct{}{}
+	for _, account := range exemptAccounts {
+		exemptMap[types.Hash(account)] = struct{}{}
+	}
+
+	return exemptMap
+}
+
 // AccountBalance attempts to fetch the balance
 // for a missing account in storage. This is necessary
 // for running the "check" command at an arbitrary height
@@ -114,6 +130,15 @@ func (h *BalanceStorageHelper) AccountBalance(
 		return nil, syncer.ErrOrphanHead
 	}
 
+	if h.checkpoints != nil {
+		h.checkpoints.Add(&CheckpointBalance{
+			Account:  account,
+			Currency: currency,
+			Block:    lookupBlock,
+			Value:    amount.Value,
+		})
+	}
+
 	return &types.Amount{
 		Value:    amount.Value,
 		Currency: currency,
@@ -131,6 +156,34 @@ func (h *BalanceStorageHelper) AddInterestingAddress(address string) {
 	h.interestingAddresses[address] = struct{}{}
 }
 
+// SubscribeAccounts restricts balance tracking to accounts.
+// Operations on all other accounts are exempt, so their
+// balances are never computed or reconciled.
+func (h *BalanceStorageHelper) SubscribeAccounts(accounts []*types.AccountCurrency) {
+	h.subscribedAccounts = map[string]struct{}{}
+	for _, account := range accounts {
+		h.subscribedAccounts[types.Hash(account)] = struct{}{}
+	}
+}
+
+// SetExemptAccounts replaces the exempt accounts while syncing.
+// Operations on accounts that are no longer exempt are tracked
+// from the next block parsed.
+func (h *BalanceStorageHelper) SetExemptAccounts(accounts []*types.AccountCurrency) {
+	exemptMap := exemptAccountMap(accounts)
+
+	h.exemptMutex.Lock()
+	defer h.exemptMutex.Unlock()
+
+	h.exemptAccounts = exemptMap
+}
+
+// RecordCheckpoints records every balance fetched for
+// a newly seen account with checkpoints.
+func (h *BalanceStorageHelper) RecordCheckpoints(checkpoints *CheckpointBalanceWorker) {
+	h.checkpoints = checkpoints
+}
+
 // ExemptFunc returns a parser.ExemptOperation.
 func (h *BalanceStorageHelper) ExemptFunc() parser.ExemptOperation {
 	return func(op *types.Operation) bool {
@@ -140,11 +193,24 @@ func (h *BalanceStorageHelper) ExemptFunc() parser.ExemptOperation {
 			}
 		}
 
+		if !h.currencyFilter.Tracked(op.Amount.Currency) {
+			return true
+		}
+
 		thisAcct := types.Hash(&types.AccountCurrency{
 			Account:  op.Account,
 			Currency: op.Amount.Currency,
 		})
 
+		if h.subscribedAccounts != nil {
+			if _, exists := h.subscribedAccounts[thisAcct]; !exists {
+				return true
+			}
+		}
+
+		h.exemptMutex.RLock()
+		defer h.exemptMutex.RUnlock()
+
 		_, exists := h.exemptAccounts[thisAcct]
 		return exists
 	}
@@ -82,6 +82,7 @@ func TestExemptFuncExemptAccounts(t *testing.T) {
 				nil,
 				false,
 				test.exemptAccounts,
+				nil,
 				false,
 				nil,
 				false,
@@ -125,6 +126,7 @@ func TestExemptFuncInterestingParsing(t *testing.T) {
 				nil,
 				false,
 				nil,
+				nil,
 				true,
 				nil,
 				false,
@@ -146,3 +148,111 @@ func TestExemptFuncInterestingParsing(t *testing.T) {
 		})
 	}
 }
+
+func TestExemptFuncSubscribedAccounts(t *testing.T) {
+	var tests = map[string]struct {
+		subscribedAccounts []*types.AccountCurrency
+		exempt             bool
+	}{
+		"no subscribed accounts": {
+			subscribedAccounts: []*types.AccountCurrency{},
+			exempt:             true,
+		},
+		"account subscribed": {
+			subscribedAccounts: []*types.AccountCurrency{
+				{
+					Account: &types.AccountIdentifier{
+						Address: "addr1",
+					},
+					Currency: opAmountCurrency.Currency,
+				},
+				opAmountCurrency,
+			},
+			exempt: false,
+		},
+		"currency not subscribed": {
+			subscribedAccounts: []*types.AccountCurrency{
+				{
+					Account: opAmountCurrency.Account,
+					Currency: &types.Currency{
+						Symbol:   "ETH",
+						Decimals: 18,
+					},
+				},
+			},
+			exempt: true,
+		},
+		"sub-account not subscribed": {
+			subscribedAccounts: []*types.AccountCurrency{
+				{
+					Account: &types.AccountIdentifier{
+						Address: opAmountCurrency.Account.Address,
+						SubAccount: &types.SubAccountIdentifier{
+							Address: "staked",
+						},
+					},
+					Currency: opAmountCurrency.Currency,
+				},
+			},
+			exempt: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			helper := NewBalanceStorageHelper(
+				nil,
+				nil,
+				false,
+				nil,
+				nil,
+				false,
+				nil,
+				false,
+			)
+			helper.SubscribeAccounts(test.subscribedAccounts)
+
+			result := helper.ExemptFunc()(&types.Operation{
+				Account: opAmountCurrency.Account,
+				Amount: &types.Amount{
+					Value:    "100",
+					Currency: opAmountCurrency.Currency,
+				},
+			})
+
+			assert.Equal(t, test.exempt, result)
+		})
+	}
+}
+
+func TestSetExemptAccounts(t *testing.T) {
+	helper := NewBalanceStorageHelper(
+		nil,
+		nil,
+		false,
+		[]*types.AccountCurrency{opAmountCurrency},
+		nil,
+		false,
+		nil,
+		false,
+	)
+
+	op := &types.Operation{
+		Account: opAmountCurrency.Account,
+		Amount: &types.Amount{
+			Value:    "100",
+			Currency: opAmountCurrency.Currency,
+		},
+	}
+
+	// The ExemptFunc passed to the parser reflects
+	// exempt accounts replaced while syncing.
+	exemptFunc := helper.ExemptFunc()
+	assert.True(t, exemptFunc(op))
+
+	helper.SetExemptAccounts([]*types.AccountCurrency{})
+	assert.False(t, exemptFunc(op))
+
+	helper.SetExemptAccounts([]*types.AccountCurrency{opAmountCurrency})
+	assert.True(t, exemptFunc(op))
+}
@@ -0,0 +1,117 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/coinbase/rosetta-cli/pkg/failures"
+
+	"github.com/coinbase/rosetta-sdk-go/fetcher"
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+var _ storage.BlockWorker = (*BlockFetchWorker)(nil)
+
+// ErrBlockFetchMismatch is returned when a block fetched
+// by hash is not equal to the same block fetched by index.
+var ErrBlockFetchMismatch = errors.New("block fetched by hash does not match block fetched by index")
+
+// BlockFetchWorker implements the storage.BlockWorker interface
+// and periodically fetches a synced block (which the syncer fetched
+// by index) again by hash to ensure both lookups return the
+// same block.
+type BlockFetchWorker struct {
+	network        *types.NetworkIdentifier
+	fetcher        *fetcher.Fetcher
+	frequency      int64
+	failureStorage *failures.Storage
+}
+
+// NewBlockFetchWorker returns a new *BlockFetchWorker that
+// verifies every block with an index divisible by frequency.
+func NewBlockFetchWorker(
+	network *types.NetworkIdentifier,
+	fetcher *fetcher.Fetcher,
+	frequency uint64,
+	failureStorage *failures.Storage,
+) *BlockFetchWorker {
+	return &BlockFetchWorker{
+		network:        network,
+		fetcher:        fetcher,
+		frequency:      int64(frequency),
+		failureStorage: failureStorage,
+	}
+}
+
+// AddingBlock fetches block by hash (if it should be verified)
+// and returns an error if it is not semantically equal to block.
+func (w *BlockFetchWorker) AddingBlock(
+	ctx context.Context,
+	block *types.Block,
+	transaction storage.DatabaseTransaction,
+) (storage.CommitWorker, error) {
+	if block.BlockIdentifier.Index%w.frequency != 0 {
+		return nil, nil
+	}
+
+	hashBlock, fetchErr := w.fetcher.BlockRetry(
+		ctx,
+		w.network,
+		&types.PartialBlockIdentifier{Hash: &block.BlockIdentifier.Hash},
+	)
+	if fetchErr != nil {
+		return nil, fmt.Errorf(
+			"%w: unable to fetch block %s:%d by hash",
+			fetchErr.Err,
+			block.BlockIdentifier.Hash,
+			block.BlockIdentifier.Index,
+		)
+	}
+
+	if types.Hash(hashBlock) == types.Hash(block) {
+		return nil, nil
+	}
+
+	log.Printf("block fetched by index: %s\n", types.PrintStruct(block))
+	log.Printf("block fetched by hash: %s\n", types.PrintStruct(hashBlock))
+	w.failureStorage.Defer(&failures.Failure{
+		Kind:     failures.BlockFetchMismatchFailure,
+		Block:    block.BlockIdentifier,
+		Expected: types.Hash(block),
+		Actual:   types.Hash(hashBlock),
+		Message:  ErrBlockFetchMismatch.Error(),
+	})
+
+	return nil, fmt.Errorf(
+		"%w: %s:%d",
+		ErrBlockFetchMismatch,
+		block.BlockIdentifier.Hash,
+		block.BlockIdentifier.Index,
+	)
+}
+
+// RemovingBlock is a no-op.
+func (w *BlockFetchWorker) RemovingBlock(
+	ctx context.Context,
+	block *types.Block,
+	transaction storage.DatabaseTransaction,
+) (storage.CommitWorker, error) {
+	return nil, nil
+}
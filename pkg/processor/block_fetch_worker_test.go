@@ -0,0 +1,122 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/coinbase/rosetta-cli/pkg/failures"
+
+	"github.com/coinbase/rosetta-sdk-go/asserter"
+	"github.com/coinbase/rosetta-sdk-go/fetcher"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlockFetchWorker(t *testing.T) {
+	network := &types.NetworkIdentifier{Blockchain: "blah", Network: "testnet"}
+	block := &types.Block{
+		BlockIdentifier:       &types.BlockIdentifier{Hash: "block 10", Index: 10},
+		ParentBlockIdentifier: &types.BlockIdentifier{Hash: "block 9", Index: 9},
+		Timestamp:             asserter.MinUnixEpoch + 1,
+		Transactions:          []*types.Transaction{},
+	}
+	divergent := &types.Block{
+		BlockIdentifier:       block.BlockIdentifier,
+		ParentBlockIdentifier: &types.BlockIdentifier{Hash: "other block 9", Index: 9},
+		Timestamp:             block.Timestamp,
+		Transactions:          []*types.Transaction{},
+	}
+
+	var tests = map[string]struct {
+		index     int64
+		hashBlock *types.Block
+		err       error
+	}{
+		"not verified": {
+			index:     11,
+			hashBlock: divergent,
+		},
+		"matching block": {
+			index:     10,
+			hashBlock: block,
+		},
+		"divergent block": {
+			index:     10,
+			hashBlock: divergent,
+			err:       ErrBlockFetchMismatch,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			requests := 0
+			server := httptest.NewServer(http.HandlerFunc(
+				func(w http.ResponseWriter, r *http.Request) {
+					requests++
+					w.Header().Set("Content-Type", "application/json")
+					assert.NoError(t, json.NewEncoder(w).Encode(&types.BlockResponse{
+						Block: test.hashBlock,
+					}))
+				},
+			))
+			defer server.Close()
+
+			a, err := asserter.NewClientWithOptions(
+				network,
+				&types.BlockIdentifier{Hash: "block 0", Index: 0},
+				[]string{"TRANSFER"},
+				[]*types.OperationStatus{{Status: "SUCCESS", Successful: true}},
+				[]*types.Error{},
+				nil,
+			)
+			assert.NoError(t, err)
+
+			w := NewBlockFetchWorker(
+				network,
+				fetcher.New(server.URL, fetcher.WithAsserter(a), fetcher.WithMaxRetries(0)),
+				5,
+				failures.NewStorage(nil),
+			)
+
+			syncedBlock := block
+			if test.index != block.BlockIdentifier.Index {
+				syncedBlock = &types.Block{
+					BlockIdentifier:       &types.BlockIdentifier{Hash: "block 11", Index: 11},
+					ParentBlockIdentifier: block.BlockIdentifier,
+					Timestamp:             block.Timestamp,
+				}
+			}
+
+			_, err = w.AddingBlock(context.Background(), syncedBlock, nil)
+			if test.err != nil {
+				assert.True(t, errors.Is(err, test.err))
+			} else {
+				assert.NoError(t, err)
+			}
+
+			if test.index%5 == 0 {
+				assert.Equal(t, 1, requests)
+			} else {
+				assert.Equal(t, 0, requests)
+			}
+		})
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"math/big"
 
 	"github.com/coinbase/rosetta-cli/configuration"
+	"github.com/coinbase/rosetta-cli/pkg/results"
 
 	"github.com/coinbase/rosetta-sdk-go/constructor/coordinator"
 	"github.com/coinbase/rosetta-sdk-go/parser"
@@ -33,27 +34,47 @@ var _ storage.BroadcastStorageHandler = (*BroadcastStorageHandler)(nil)
 // or removed from block storage so that balance changes
 // can be sent to other functions (ex: reconciler).
 type BroadcastStorageHandler struct {
-	config         *configuration.Configuration
-	counterStorage *storage.CounterStorage
-	coordinator    *coordinator.Coordinator
-	parser         *parser.Parser
+	config          *configuration.Configuration
+	counterStorage  *storage.CounterStorage
+	coordinator     *coordinator.Coordinator
+	parser          *parser.Parser
+	matchers        []IntentMatcher
+	feeEstimator    *FeeEstimator
+	sequenceTracker *SequenceTracker
 }
 
 // NewBroadcastStorageHandler returns a new *BroadcastStorageHandler.
+// matchers are applied (in order) to the operations of each confirmed
+// transaction before they are compared to its intent.
 func NewBroadcastStorageHandler(
 	config *configuration.Configuration,
 	counterStorage *storage.CounterStorage,
 	coordinator *coordinator.Coordinator,
 	parser *parser.Parser,
+	matchers []IntentMatcher,
 ) *BroadcastStorageHandler {
 	return &BroadcastStorageHandler{
 		config:         config,
 		counterStorage: counterStorage,
 		coordinator:    coordinator,
 		parser:         parser,
+		matchers:       matchers,
 	}
 }
 
+// EstimateFees compares the fee charged for each confirmed
+// transaction to its suggested fee using feeEstimator.
+func (h *BroadcastStorageHandler) EstimateFees(feeEstimator *FeeEstimator) {
+	h.feeEstimator = feeEstimator
+}
+
+// TrackSequences validates the order transactions sent
+// concurrently from the same account confirm in using
+// sequenceTracker.
+func (h *BroadcastStorageHandler) TrackSequences(sequenceTracker *SequenceTracker) {
+	h.sequenceTracker = sequenceTracker
+}
+
 // TransactionConfirmed is called when a transaction is observed on-chain for the
 // last time at a block height < current block height - confirmationDepth.
 func (h *BroadcastStorageHandler) TransactionConfirmed(
@@ -64,8 +85,37 @@ func (h *BroadcastStorageHandler) TransactionConfirmed(
 	transaction *types.Transaction,
 	intent []*types.Operation,
 ) error {
-	if err := h.parser.ExpectedOperations(intent, transaction.Operations, false, true); err != nil {
-		return fmt.Errorf("%w: confirmed transaction did not match intent", err)
+	observed := transaction.Operations
+	for _, matcher := range h.matchers {
+		observed = matcher(intent, observed)
+	}
+
+	if err := h.parser.ExpectedOperations(intent, observed, false, true); err != nil {
+		diff, diffErr := DiffIntent(h.parser.Asserter, intent, observed)
+		if diffErr != nil {
+			return fmt.Errorf("%w: unable to diff intent", diffErr)
+		}
+
+		diff.Print(transaction.TransactionIdentifier)
+		return fmt.Errorf(
+			"%w: transaction %s has %s (%s)",
+			ErrIntentMismatch,
+			transaction.TransactionIdentifier.Hash,
+			diff.String(),
+			err.Error(),
+		)
+	}
+
+	if h.feeEstimator != nil {
+		if err := h.feeEstimator.Confirmed(identifier, transaction); err != nil {
+			return err
+		}
+	}
+
+	if h.sequenceTracker != nil {
+		if err := h.sequenceTracker.Confirmed(identifier, blockIdentifier); err != nil {
+			return err
+		}
 	}
 
 	_, _ = h.counterStorage.UpdateTransactional(
@@ -75,6 +125,22 @@ func (h *BroadcastStorageHandler) TransactionConfirmed(
 		big.NewInt(1),
 	)
 
+	// Operation types are counted to report
+	// which types were covered by workflows.
+	opTypes := map[string]int64{}
+	for _, op := range intent {
+		opTypes[op.Type]++
+	}
+
+	for opType, count := range opTypes {
+		_, _ = h.counterStorage.UpdateTransactional(
+			ctx,
+			dbTx,
+			results.ConstructionOperationCounter(opType),
+			big.NewInt(count),
+		)
+	}
+
 	if err := h.coordinator.BroadcastComplete(
 		ctx,
 		dbTx,
@@ -122,6 +188,10 @@ func (h *BroadcastStorageHandler) BroadcastFailed(
 		big.NewInt(1),
 	)
 
+	if h.sequenceTracker != nil {
+		h.sequenceTracker.Failed(identifier)
+	}
+
 	if err := h.coordinator.BroadcastComplete(
 		ctx,
 		dbTx,
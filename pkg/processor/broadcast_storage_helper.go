@@ -17,6 +17,7 @@ package processor
 import (
 	"context"
 	"fmt"
+	"log"
 
 	"github.com/coinbase/rosetta-sdk-go/fetcher"
 	"github.com/coinbase/rosetta-sdk-go/storage"
@@ -28,18 +29,28 @@ var _ storage.BroadcastStorageHelper = (*BroadcastStorageHelper)(nil)
 // BroadcastStorageHelper implements the storage.Helper
 // interface.
 type BroadcastStorageHelper struct {
+	network      *types.NetworkIdentifier
 	blockStorage *storage.BlockStorage
 	fetcher      *fetcher.Fetcher
+
+	// verifyCanonical determines if the block containing a
+	// transaction is compared to the block returned by the
+	// implementation at the same index before it is found.
+	verifyCanonical bool
 }
 
 // NewBroadcastStorageHelper returns a new BroadcastStorageHelper.
 func NewBroadcastStorageHelper(
+	network *types.NetworkIdentifier,
 	blockStorage *storage.BlockStorage,
 	fetcher *fetcher.Fetcher,
+	verifyCanonical bool,
 ) *BroadcastStorageHelper {
 	return &BroadcastStorageHelper{
-		blockStorage: blockStorage,
-		fetcher:      fetcher,
+		network:         network,
+		blockStorage:    blockStorage,
+		fetcher:         fetcher,
+		verifyCanonical: verifyCanonical,
 	}
 }
 
@@ -82,6 +93,38 @@ func (h *BroadcastStorageHelper) FindTransaction(
 		return nil, nil, fmt.Errorf("%w: unable to perform transaction search", err)
 	}
 
+	if newestBlock == nil || !h.verifyCanonical {
+		return newestBlock, transaction, nil
+	}
+
+	// If the implementation returns a different block at the same
+	// index, the block containing the transaction was orphaned by a
+	// reorg we have not synced yet. We consider the transaction not
+	// found (instead of confirming it) until the reorg is synced.
+	canonical, fetchErr := h.fetcher.BlockRetry(
+		ctx,
+		h.network,
+		&types.PartialBlockIdentifier{Index: &newestBlock.Index},
+	)
+	if fetchErr != nil {
+		return nil, nil, fmt.Errorf(
+			"%w: unable to fetch block %d to verify transaction %s",
+			fetchErr.Err,
+			newestBlock.Index,
+			transactionIdentifier.Hash,
+		)
+	}
+
+	if types.Hash(canonical.BlockIdentifier) != types.Hash(newestBlock) {
+		log.Printf(
+			"transaction %s found in block %s but canonical block is %s\n",
+			transactionIdentifier.Hash,
+			types.PrintStruct(newestBlock),
+			types.PrintStruct(canonical.BlockIdentifier),
+		)
+		return nil, nil, nil
+	}
+
 	return newestBlock, transaction, nil
 }
 
@@ -0,0 +1,221 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"sync"
+
+	"github.com/coinbase/rosetta-cli/pkg/results"
+
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/olekukonko/tablewriter"
+)
+
+// checkpointBalanceNamespace is prepended to any stored
+// checkpoint balance.
+const checkpointBalanceNamespace = "checkpoint_balance"
+
+var _ storage.BlockWorker = (*CheckpointBalanceWorker)(nil)
+
+// CheckpointBalance is a balance fetched from /account/balance
+// and trusted (instead of computed) because the account was
+// first seen after syncing started.
+type CheckpointBalance struct {
+	Account  *types.AccountIdentifier `json:"account_identifier"`
+	Currency *types.Currency          `json:"currency"`
+	Block    *types.BlockIdentifier   `json:"block_identifier"`
+	Value    string                   `json:"value"`
+}
+
+// CheckpointBalanceWorker implements the storage.BlockWorker interface
+// and persists the balances trusted by the BalanceStorageHelper.
+//
+// The BalanceStorageHelper does not have access to the database
+// transaction of the block it fetches balances for, so trusted
+// balances are held in memory until the block is added (this
+// worker must run after balance storage).
+type CheckpointBalanceWorker struct {
+	db             storage.Database
+	counterStorage *storage.CounterStorage
+
+	pending []*CheckpointBalance
+	mutex   sync.Mutex
+}
+
+// NewCheckpointBalanceWorker returns a new *CheckpointBalanceWorker.
+func NewCheckpointBalanceWorker(
+	db storage.Database,
+	counterStorage *storage.CounterStorage,
+) *CheckpointBalanceWorker {
+	return &CheckpointBalanceWorker{
+		db:             db,
+		counterStorage: counterStorage,
+	}
+}
+
+func getCheckpointBalanceKey(account *types.AccountIdentifier, currency *types.Currency) []byte {
+	return []byte(fmt.Sprintf(
+		"%s/%s",
+		checkpointBalanceNamespace,
+		types.Hash(&types.AccountCurrency{Account: account, Currency: currency}),
+	))
+}
+
+func getCheckpointBalancePrefix() []byte {
+	return []byte(checkpointBalanceNamespace + "/")
+}
+
+// Add records a balance trusted at balance.Block. It is
+// persisted when the child of balance.Block is added.
+func (w *CheckpointBalanceWorker) Add(balance *CheckpointBalance) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.pending = append(w.pending, balance)
+}
+
+// flush returns all pending balances trusted at the parent of
+// block (any other pending balances were fetched for a block
+// that was never added and are discarded).
+func (w *CheckpointBalanceWorker) flush(block *types.Block) []*CheckpointBalance {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	balances := []*CheckpointBalance{}
+	for _, balance := range w.pending {
+		if types.Hash(balance.Block) == types.Hash(block.ParentBlockIdentifier) {
+			balances = append(balances, balance)
+		}
+	}
+	w.pending = nil
+
+	return balances
+}
+
+// AddingBlock persists all balances trusted while
+// block was added to balance storage.
+func (w *CheckpointBalanceWorker) AddingBlock(
+	ctx context.Context,
+	block *types.Block,
+	transaction storage.DatabaseTransaction,
+) (storage.CommitWorker, error) {
+	balances := w.flush(block)
+	if len(balances) == 0 {
+		return nil, nil
+	}
+
+	for _, balance := range balances {
+		val, err := w.db.Encoder().Encode(checkpointBalanceNamespace, balance)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to encode checkpoint balance", err)
+		}
+
+		key := getCheckpointBalanceKey(balance.Account, balance.Currency)
+		if err := transaction.Set(ctx, key, val, true); err != nil {
+			return nil, fmt.Errorf("%w: unable to store checkpoint balance", err)
+		}
+	}
+
+	if _, err := w.counterStorage.UpdateTransactional(
+		ctx,
+		transaction,
+		results.CheckpointBalanceCounter,
+		big.NewInt(int64(len(balances))),
+	); err != nil {
+		return nil, fmt.Errorf("%w: unable to update checkpoint balance counter", err)
+	}
+
+	return func(ctx context.Context) error {
+		for _, balance := range balances {
+			log.Printf(
+				"trusted checkpoint balance %s %s for %s at block %d\n",
+				balance.Value,
+				balance.Currency.Symbol,
+				types.PrintStruct(balance.Account),
+				balance.Block.Index,
+			)
+		}
+
+		return nil
+	}, nil
+}
+
+// RemovingBlock is a no-op because balance storage does
+// not forget the balance of an account during a reorg (so
+// the trusted balance remains in use).
+func (w *CheckpointBalanceWorker) RemovingBlock(
+	ctx context.Context,
+	block *types.Block,
+	transaction storage.DatabaseTransaction,
+) (storage.CommitWorker, error) {
+	return nil, nil
+}
+
+// GetCheckpointBalances returns all balances that were
+// trusted when an account was first seen.
+func GetCheckpointBalances(
+	ctx context.Context,
+	db storage.Database,
+) ([]*CheckpointBalance, error) {
+	dbTx := db.NewDatabaseTransaction(ctx, false)
+	defer dbTx.Discard(ctx)
+
+	balances := []*CheckpointBalance{}
+	_, err := dbTx.Scan(
+		ctx,
+		getCheckpointBalancePrefix(),
+		getCheckpointBalancePrefix(),
+		func(k []byte, v []byte) error {
+			var balance CheckpointBalance
+			if err := db.Encoder().Decode(checkpointBalanceNamespace, v, &balance, false); err != nil {
+				return fmt.Errorf("%w: unable to decode checkpoint balance", err)
+			}
+
+			balances = append(balances, &balance)
+			return nil
+		},
+		false,
+		false,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to scan checkpoint balances", err)
+	}
+
+	return balances, nil
+}
+
+// PrintCheckpointBalances logs balances to the console as a table.
+func PrintCheckpointBalances(balances []*CheckpointBalance) {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetRowLine(true)
+	table.SetRowSeparator("-")
+	table.SetHeader([]string{"Account", "Currency", "Block", "Value"})
+	for _, balance := range balances {
+		table.Append([]string{
+			types.PrintStruct(balance.Account),
+			types.PrintStruct(balance.Currency),
+			fmt.Sprintf("%s:%d", balance.Block.Hash, balance.Block.Index),
+			balance.Value,
+		})
+	}
+
+	table.Render()
+}
@@ -0,0 +1,92 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/coinbase/rosetta-cli/pkg/results"
+
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/coinbase/rosetta-sdk-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckpointBalanceWorker(t *testing.T) {
+	ctx := context.Background()
+	dir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(dir)
+
+	localStore, err := storage.NewBadgerStorage(
+		ctx,
+		dir,
+		storage.WithIndexCacheSize(storage.TinyIndexCacheSize),
+	)
+	assert.NoError(t, err)
+	defer localStore.Close(ctx)
+
+	counterStorage := storage.NewCounterStorage(localStore)
+	w := NewCheckpointBalanceWorker(localStore, counterStorage)
+
+	addBlock := func(block *types.Block) {
+		dbTx := localStore.NewDatabaseTransaction(ctx, true)
+		defer dbTx.Discard(ctx)
+
+		_, err := w.AddingBlock(ctx, block, dbTx)
+		assert.NoError(t, err)
+		assert.NoError(t, dbTx.Commit(ctx))
+	}
+
+	block1 := &types.BlockIdentifier{Hash: "block1", Index: 1}
+	block2 := &types.BlockIdentifier{Hash: "block2", Index: 2}
+	stale := &types.BlockIdentifier{Hash: "orphan", Index: 2}
+	balance := &CheckpointBalance{
+		Account:  opAmountCurrency.Account,
+		Currency: opAmountCurrency.Currency,
+		Block:    block1,
+		Value:    "100",
+	}
+
+	// Balances fetched for a block that was never
+	// added are discarded.
+	w.Add(&CheckpointBalance{
+		Account:  &types.AccountIdentifier{Address: "orphaned"},
+		Currency: opAmountCurrency.Currency,
+		Block:    stale,
+		Value:    "10",
+	})
+	w.Add(balance)
+	addBlock(&types.Block{
+		BlockIdentifier:       block2,
+		ParentBlockIdentifier: block1,
+	})
+
+	// Blocks without new accounts are ignored.
+	addBlock(&types.Block{
+		BlockIdentifier:       &types.BlockIdentifier{Hash: "block3", Index: 3},
+		ParentBlockIdentifier: block2,
+	})
+
+	balances, err := GetCheckpointBalances(ctx, localStore)
+	assert.NoError(t, err)
+	assert.Equal(t, []*CheckpointBalance{balance}, balances)
+
+	count, err := counterStorage.Get(ctx, results.CheckpointBalanceCounter)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count.Int64())
+}
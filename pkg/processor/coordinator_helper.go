@@ -21,6 +21,9 @@ import (
 	"log"
 	"math/big"
 
+	"github.com/coinbase/rosetta-cli/configuration"
+	"github.com/coinbase/rosetta-cli/pkg/logger"
+
 	"github.com/coinbase/rosetta-sdk-go/constructor/coordinator"
 	"github.com/coinbase/rosetta-sdk-go/fetcher"
 	"github.com/coinbase/rosetta-sdk-go/keys"
@@ -70,11 +73,42 @@ type CoordinatorHelper struct {
 	broadcastStorage *storage.BroadcastStorage
 	counterStorage   *storage.CounterStorage
 
+	// multisigKeyStorage stores any additional
+	// keys of multi-signature accounts.
+	multisigKeyStorage *MultisigKeyStorage
+
 	balanceStorageHelper *BalanceStorageHelper
 
+	// minConfirmationDepth is the minimum confirmation
+	// depth of any broadcast (regardless of the depth
+	// requested by the workflow).
+	minConfirmationDepth int64
+
+	// workflowConfirmationDepths are the minimum confirmation
+	// depths of broadcasts by jobs of each workflow (looked
+	// up in jobStorage).
+	jobStorage                 *storage.JobStorage
+	workflowConfirmationDepths map[string]int64
+
 	// quiet determines if requests/responses logging
 	// should be silenced.
 	quiet bool
+
+	// seededKeys replaces generated key pairs with key
+	// pairs derived from a seed (if not nil).
+	seededKeys *SeededKeys
+
+	// curvePlugins replaces placeholder key pairs with
+	// key pairs generated by curve plugins (if not nil).
+	curvePlugins CurvePlugins
+
+	// feeEstimator records the fee suggested for
+	// each broadcast transaction (if not nil).
+	feeEstimator *FeeEstimator
+
+	// sequenceTracker tracks transactions sent
+	// concurrently from the same account (if not nil).
+	sequenceTracker *SequenceTracker
 }
 
 // NewCoordinatorHelper returns a new *CoordinatorHelper.
@@ -89,23 +123,58 @@ func NewCoordinatorHelper(
 	broadcastStorage *storage.BroadcastStorage,
 	balanceStorageHelper *BalanceStorageHelper,
 	counterStorage *storage.CounterStorage,
+	minConfirmationDepth int64,
+	jobStorage *storage.JobStorage,
+	workflowConfirmationDepths map[string]int64,
 	quiet bool,
+	remoteSigner RemoteSigner,
 ) *CoordinatorHelper {
 	return &CoordinatorHelper{
-		offlineFetcher:       offlineFetcher,
-		onlineFetcher:        onlineFetcher,
-		database:             database,
-		blockStorage:         blockStorage,
-		keyStorage:           keyStorage,
-		balanceStorage:       balanceStorage,
-		coinStorage:          coinStorage,
-		broadcastStorage:     broadcastStorage,
-		counterStorage:       counterStorage,
-		balanceStorageHelper: balanceStorageHelper,
-		quiet:                quiet,
+		offlineFetcher:             offlineFetcher,
+		onlineFetcher:              onlineFetcher,
+		database:                   database,
+		blockStorage:               blockStorage,
+		keyStorage:                 keyStorage,
+		balanceStorage:             balanceStorage,
+		coinStorage:                coinStorage,
+		broadcastStorage:           broadcastStorage,
+		counterStorage:             counterStorage,
+		multisigKeyStorage:         NewMultisigKeyStorage(database, keyStorage, remoteSigner),
+		balanceStorageHelper:       balanceStorageHelper,
+		minConfirmationDepth:       minConfirmationDepth,
+		jobStorage:                 jobStorage,
+		workflowConfirmationDepths: workflowConfirmationDepths,
+		quiet:                      quiet,
 	}
 }
 
+// SeedKeys replaces all key pairs generated by the
+// coordinator with key pairs derived by seededKeys.
+func (c *CoordinatorHelper) SeedKeys(seededKeys *SeededKeys) {
+	c.seededKeys = seededKeys
+}
+
+// UseCurvePlugins configures the helper to replace placeholder
+// key pairs with key pairs generated by curvePlugins and to sign
+// with curvePlugins for keys on their curves.
+func (c *CoordinatorHelper) UseCurvePlugins(curvePlugins CurvePlugins) {
+	c.curvePlugins = curvePlugins
+	c.multisigKeyStorage.curvePlugins = curvePlugins
+}
+
+// EstimateFees records the fee suggested by /construction/metadata
+// for each broadcast transaction in feeEstimator.
+func (c *CoordinatorHelper) EstimateFees(feeEstimator *FeeEstimator) {
+	c.feeEstimator = feeEstimator
+}
+
+// TrackSequences unlocks accounts with pending concurrent
+// sends and records the nonce of each broadcast transaction
+// in sequenceTracker.
+func (c *CoordinatorHelper) TrackSequences(sequenceTracker *SequenceTracker) {
+	c.sequenceTracker = sequenceTracker
+}
+
 // DatabaseTransaction returns a new write-ready storage.DatabaseTransaction.
 func (c *CoordinatorHelper) DatabaseTransaction(ctx context.Context) storage.DatabaseTransaction {
 	return c.database.NewDatabaseTransaction(ctx, true)
@@ -137,6 +206,26 @@ func (c *CoordinatorHelper) Derive(
 	publicKey *types.PublicKey,
 	metadata map[string]interface{},
 ) (*types.AccountIdentifier, map[string]interface{}, error) {
+	if c.seededKeys != nil {
+		seededKey, err := c.seededKeys.PublicKey(publicKey)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		publicKey = seededKey
+	}
+
+	if c.curvePlugins != nil {
+		keyPair, replaced, err := c.curvePlugins.Replace(ctx, publicKey)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if replaced {
+			publicKey = keyPair.PublicKey
+		}
+	}
+
 	c.verboseLog(request, constructionDerive,
 		arg{argNetwork, networkIdentifier},
 		arg{"public_key", publicKey},
@@ -221,6 +310,15 @@ func (c *CoordinatorHelper) Metadata(
 		arg{argMetadata, metadata},
 		arg{"suggested_fee", suggestedFee},
 	)
+
+	if c.feeEstimator != nil {
+		c.feeEstimator.Suggested(suggestedFee)
+	}
+
+	if c.sequenceTracker != nil {
+		c.sequenceTracker.Metadata(metadata)
+	}
+
 	return metadata, suggestedFee, nil
 }
 
@@ -346,13 +444,16 @@ func (c *CoordinatorHelper) Hash(
 	return res, nil
 }
 
-// Sign invokes the KeyStorage backend
-// to sign some payloads.
+// Sign invokes the KeyStorage backend (or the
+// remote signer, for accounts it manages) to sign
+// some payloads. If multiple payloads must be signed
+// by the same multi-signature account, each is signed
+// by a different key.
 func (c *CoordinatorHelper) Sign(
 	ctx context.Context,
 	payloads []*types.SigningPayload,
 ) ([]*types.Signature, error) {
-	return c.keyStorage.Sign(ctx, payloads)
+	return c.multisigKeyStorage.Sign(ctx, payloads)
 }
 
 // GetKey is called to get the *types.KeyPair
@@ -366,23 +467,53 @@ func (c *CoordinatorHelper) GetKey(
 }
 
 // StoreKey stores a KeyPair and address
-// in KeyStorage.
+// in KeyStorage. If a KeyPair is already stored
+// for the address, KeyPair is stored as an additional
+// signer of the (multi-signature) account.
 func (c *CoordinatorHelper) StoreKey(
 	ctx context.Context,
 	dbTx storage.DatabaseTransaction,
 	account *types.AccountIdentifier,
 	keyPair *keys.KeyPair,
 ) error {
+	if c.seededKeys != nil {
+		seededKeyPair, err := c.seededKeys.StoreTransactional(ctx, dbTx, keyPair)
+		if err != nil {
+			return err
+		}
+
+		keyPair = seededKeyPair
+	}
+
+	if c.curvePlugins != nil {
+		pluginKeyPair, replaced, err := c.curvePlugins.Replace(ctx, keyPair.PublicKey)
+		if err != nil {
+			return err
+		}
+
+		if replaced {
+			keyPair = pluginKeyPair
+		}
+	}
+
 	// We optimisically add the interesting address although the dbTx could be reverted.
 	c.balanceStorageHelper.AddInterestingAddress(account.Address)
 
-	_, _ = c.counterStorage.UpdateTransactional(
-		ctx,
-		dbTx,
-		storage.AddressesCreatedCounter,
-		big.NewInt(1),
-	)
-	return c.keyStorage.StoreTransactional(ctx, account, keyPair, dbTx)
+	created, err := c.multisigKeyStorage.StoreTransactional(ctx, dbTx, account, keyPair)
+	if err != nil {
+		return err
+	}
+
+	if created {
+		_, _ = c.counterStorage.UpdateTransactional(
+			ctx,
+			dbTx,
+			storage.AddressesCreatedCounter,
+			big.NewInt(1),
+		)
+	}
+
+	return nil
 }
 
 // Balance returns the balance
@@ -442,12 +573,22 @@ func (c *CoordinatorHelper) Coins(
 }
 
 // LockedAccounts returns a slice of all accounts currently sending or receiving
-// funds.
+// funds (except accounts with fewer than the max pending concurrent sends).
 func (c *CoordinatorHelper) LockedAccounts(
 	ctx context.Context,
 	dbTx storage.DatabaseTransaction,
 ) ([]*types.AccountIdentifier, error) {
-	return c.broadcastStorage.LockedAccounts(ctx, dbTx)
+	locked, err := c.broadcastStorage.LockedAccounts(ctx, dbTx)
+	if err != nil || c.sequenceTracker == nil || len(locked) == 0 {
+		return locked, err
+	}
+
+	broadcasts, err := c.broadcastStorage.GetAllBroadcasts(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to get broadcasts", err)
+	}
+
+	return c.sequenceTracker.Locked(locked, broadcasts), nil
 }
 
 // AllBroadcasts returns a slice of all in-progress broadcasts in BroadcastStorage.
@@ -477,7 +618,34 @@ func (c *CoordinatorHelper) Broadcast(
 		arg{argTransactionIdentifier, transactionIdentifier},
 		arg{argNetworkTransaction, payload},
 	)
-	return c.broadcastStorage.Broadcast(
+
+	// Transactions are never considered confirmed
+	// before they are at a final block.
+	if confirmationDepth < c.minConfirmationDepth {
+		confirmationDepth = c.minConfirmationDepth
+	}
+
+	workflowDepth, err := c.workflowConfirmationDepth(ctx, dbTx, identifier)
+	if err != nil {
+		return err
+	}
+
+	if confirmationDepth < workflowDepth {
+		confirmationDepth = workflowDepth
+	}
+
+	if c.sequenceTracker != nil {
+		j, err := c.jobStorage.Get(ctx, dbTx, identifier)
+		if err != nil {
+			return fmt.Errorf("%w: unable to get job %s", err, identifier)
+		}
+
+		if err := c.sequenceTracker.Broadcast(identifier, j.Workflow, intent); err != nil {
+			return err
+		}
+	}
+
+	if err := c.broadcastStorage.Broadcast(
 		ctx,
 		dbTx,
 		identifier,
@@ -486,7 +654,42 @@ func (c *CoordinatorHelper) Broadcast(
 		transactionIdentifier,
 		payload,
 		confirmationDepth,
+	); err != nil {
+		return err
+	}
+
+	logger.Debugf(
+		configuration.BroadcastLogModule,
+		"queued broadcast %s of %s (confirmation depth: %d)",
+		identifier,
+		transactionIdentifier.Hash,
+		confirmationDepth,
 	)
+
+	if c.feeEstimator != nil {
+		c.feeEstimator.Broadcast(identifier)
+	}
+
+	return nil
+}
+
+// workflowConfirmationDepth returns the minimum confirmation
+// depth configured for the workflow of the job with identifier.
+func (c *CoordinatorHelper) workflowConfirmationDepth(
+	ctx context.Context,
+	dbTx storage.DatabaseTransaction,
+	identifier string,
+) (int64, error) {
+	if len(c.workflowConfirmationDepths) == 0 {
+		return 0, nil
+	}
+
+	j, err := c.jobStorage.Get(ctx, dbTx, identifier)
+	if err != nil {
+		return 0, fmt.Errorf("%w: unable to get job %s", err, identifier)
+	}
+
+	return c.workflowConfirmationDepths[j.Workflow], nil
 }
 
 // BroadcastAll attempts to broadcast all ready transactions.
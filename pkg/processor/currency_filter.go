@@ -0,0 +1,92 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+// CurrencyFilter determines which currencies should
+// have their balances tracked and reconciled. A nil
+// *CurrencyFilter tracks all currencies.
+type CurrencyFilter struct {
+	tracked map[string]struct{}
+	ignored map[string]struct{}
+}
+
+// NewCurrencyFilter returns a new *CurrencyFilter. If
+// tracked is populated, only currencies in tracked are
+// tracked. Otherwise, all currencies not in ignored are
+// tracked. If both are empty, nil is returned.
+func NewCurrencyFilter(
+	tracked []*types.Currency,
+	ignored []*types.Currency,
+) *CurrencyFilter {
+	if len(tracked) == 0 && len(ignored) == 0 {
+		return nil
+	}
+
+	// Pre-process currencies on initialization
+	// to provide fast lookup while syncing.
+	toMap := func(currencies []*types.Currency) map[string]struct{} {
+		m := map[string]struct{}{}
+		for _, currency := range currencies {
+			m[types.Hash(currency)] = struct{}{}
+		}
+
+		return m
+	}
+
+	return &CurrencyFilter{
+		tracked: toMap(tracked),
+		ignored: toMap(ignored),
+	}
+}
+
+// Tracked returns a boolean indicating if the
+// balances of currency should be tracked.
+func (f *CurrencyFilter) Tracked(currency *types.Currency) bool {
+	if f == nil {
+		return true
+	}
+
+	key := types.Hash(currency)
+	if len(f.tracked) > 0 {
+		_, ok := f.tracked[key]
+		return ok
+	}
+
+	_, ok := f.ignored[key]
+	return !ok
+}
+
+// FilterAccounts returns all accounts in
+// accounts with a tracked currency.
+func (f *CurrencyFilter) FilterAccounts(
+	accounts []*types.AccountCurrency,
+) []*types.AccountCurrency {
+	if f == nil {
+		return accounts
+	}
+
+	filtered := []*types.AccountCurrency{}
+	for _, account := range accounts {
+		if f.Tracked(account.Currency) {
+			filtered = append(filtered, account)
+		}
+	}
+
+	return filtered
+}
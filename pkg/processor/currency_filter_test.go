@@ -0,0 +1,78 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCurrencyFilter(t *testing.T) {
+	eth := &types.Currency{Symbol: "ETH", Decimals: 18}
+	usdc := &types.Currency{
+		Symbol:   "USDC",
+		Decimals: 6,
+		Metadata: map[string]interface{}{"contract": "0xa0b8"},
+	}
+	spam := &types.Currency{Symbol: "SPAM", Decimals: 0}
+
+	var tests = map[string]struct {
+		tracked []*types.Currency
+		ignored []*types.Currency
+
+		expected map[*types.Currency]bool
+	}{
+		"no filter": {
+			expected: map[*types.Currency]bool{eth: true, usdc: true, spam: true},
+		},
+		"tracked": {
+			tracked:  []*types.Currency{eth, usdc},
+			expected: map[*types.Currency]bool{eth: true, usdc: true, spam: false},
+		},
+		"ignored": {
+			ignored:  []*types.Currency{spam},
+			expected: map[*types.Currency]bool{eth: true, usdc: true, spam: false},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			filter := NewCurrencyFilter(test.tracked, test.ignored)
+			for currency, tracked := range test.expected {
+				assert.Equal(t, tracked, filter.Tracked(currency), currency.Symbol)
+			}
+
+			// Currency metadata must match.
+			assert.Equal(
+				t,
+				len(test.tracked) == 0,
+				filter.Tracked(&types.Currency{Symbol: "USDC", Decimals: 6}),
+			)
+
+			accounts := []*types.AccountCurrency{
+				{Account: &types.AccountIdentifier{Address: "addr1"}, Currency: eth},
+				{Account: &types.AccountIdentifier{Address: "addr2"}, Currency: spam},
+			}
+			filtered := filter.FilterAccounts(accounts)
+			if test.expected[spam] {
+				assert.Equal(t, accounts, filtered)
+			} else {
+				assert.Equal(t, accounts[:1], filtered)
+			}
+		})
+	}
+}
@@ -0,0 +1,293 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/coinbase/rosetta-cli/pkg/failures"
+	"github.com/coinbase/rosetta-cli/pkg/results"
+
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+const (
+	// duplicateBlockNamespace is prepended to any stored
+	// block hash fingerprint.
+	duplicateBlockNamespace = "duplicate_hash/block"
+
+	// duplicateTransactionNamespace is prepended to any
+	// stored transaction hash fingerprint.
+	duplicateTransactionNamespace = "duplicate_hash/tx"
+
+	// hashFingerprintSize is the number of bytes of the
+	// SHA-256 digest of a hash that are stored. This keeps
+	// records small regardless of the length of the hashes
+	// returned by the implementation while making accidental
+	// collisions negligible.
+	hashFingerprintSize = 16
+
+	// hashRecordSize is the size of a stored block index.
+	hashRecordSize = 8
+)
+
+var _ storage.BlockWorker = (*DuplicateHashWorker)(nil)
+
+// DuplicateHashWorker implements the storage.BlockWorker interface
+// and ensures no transaction hash appears in more than one canonical
+// block and no block hash is used at more than one index.
+//
+// Rather than storing every hash, the worker stores a fixed-size
+// fingerprint of each hash (keyed by the fingerprint with the
+// index of the block it was seen in as the value). Records are
+// removed when their block is orphaned, so transactions that are
+// included again after a reorg are not reported.
+type DuplicateHashWorker struct {
+	exemptTransactions map[string]struct{}
+	failureStorage     *failures.Storage
+}
+
+// NewDuplicateHashWorker returns a new *DuplicateHashWorker.
+// Transactions with a hash in exemptTransactions are
+// not checked.
+func NewDuplicateHashWorker(
+	exemptTransactions []string,
+	failureStorage *failures.Storage,
+) *DuplicateHashWorker {
+	exempt := map[string]struct{}{}
+	for _, hash := range exemptTransactions {
+		exempt[hash] = struct{}{}
+	}
+
+	return &DuplicateHashWorker{
+		exemptTransactions: exempt,
+		failureStorage:     failureStorage,
+	}
+}
+
+func getDuplicateHashKey(namespace string, hash string) []byte {
+	digest := sha256.Sum256([]byte(hash))
+	return append([]byte(namespace+"/"), digest[:hashFingerprintSize]...)
+}
+
+// seen returns a boolean indicating if hash was recorded
+// in namespace and the index of the block it was seen in.
+func (w *DuplicateHashWorker) seen(
+	ctx context.Context,
+	transaction storage.DatabaseTransaction,
+	namespace string,
+	hash string,
+) (bool, int64, error) {
+	exists, val, err := transaction.Get(ctx, getDuplicateHashKey(namespace, hash))
+	if err != nil {
+		return false, -1, fmt.Errorf("%w: unable to get hash record", err)
+	}
+
+	if !exists {
+		return false, -1, nil
+	}
+
+	if len(val) != hashRecordSize {
+		return false, -1, fmt.Errorf("hash record for %s is corrupt", hash)
+	}
+
+	return true, int64(binary.BigEndian.Uint64(val)), nil
+}
+
+func (w *DuplicateHashWorker) setSeen(
+	ctx context.Context,
+	transaction storage.DatabaseTransaction,
+	namespace string,
+	hash string,
+	index int64,
+) error {
+	val := make([]byte, hashRecordSize)
+	binary.BigEndian.PutUint64(val, uint64(index))
+
+	return transaction.Set(ctx, getDuplicateHashKey(namespace, hash), val, true)
+}
+
+// record checks if hash was seen in a block other than
+// block and records it (returning a violation if it was).
+func (w *DuplicateHashWorker) record(
+	ctx context.Context,
+	transaction storage.DatabaseTransaction,
+	namespace string,
+	hash string,
+	block *types.Block,
+) (string, int64, error) {
+	exists, index, err := w.seen(ctx, transaction, namespace, hash)
+	if err != nil {
+		return "", -1, err
+	}
+
+	if exists && index != block.BlockIdentifier.Index {
+		return fmt.Sprintf("%s was already seen in block %d", hash, index), index, nil
+	}
+
+	return "", -1, w.setSeen(ctx, transaction, namespace, hash, block.BlockIdentifier.Index)
+}
+
+// AddingBlock records the hashes of block and its transactions
+// and returns an error listing every hash that was already seen
+// in another block.
+func (w *DuplicateHashWorker) AddingBlock(
+	ctx context.Context,
+	block *types.Block,
+	transaction storage.DatabaseTransaction,
+) (storage.CommitWorker, error) {
+	violations := []string{}
+	violation, index, err := w.record(
+		ctx,
+		transaction,
+		duplicateBlockNamespace,
+		block.BlockIdentifier.Hash,
+		block,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to check block hash", err)
+	}
+
+	if len(violation) > 0 {
+		violation = fmt.Sprintf("block %s", violation)
+		violations = append(violations, violation)
+
+		// The block transaction is discarded when we return
+		// an error, so the failure must be deferred.
+		w.failureStorage.Defer(&failures.Failure{
+			Kind:     failures.DuplicateHashFailure,
+			Block:    block.BlockIdentifier,
+			Expected: strconv.FormatInt(index, 10),
+			Actual:   strconv.FormatInt(block.BlockIdentifier.Index, 10),
+			Message:  violation,
+		})
+	}
+
+	for _, tx := range block.Transactions {
+		hash := tx.TransactionIdentifier.Hash
+		if _, ok := w.exemptTransactions[hash]; ok {
+			continue
+		}
+
+		violation, index, err := w.record(
+			ctx,
+			transaction,
+			duplicateTransactionNamespace,
+			hash,
+			block,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to check transaction hash", err)
+		}
+
+		if len(violation) == 0 {
+			continue
+		}
+
+		violation = fmt.Sprintf("transaction %s", violation)
+		violations = append(violations, violation)
+		w.failureStorage.Defer(&failures.Failure{
+			Kind:        failures.DuplicateHashFailure,
+			Block:       block.BlockIdentifier,
+			Transaction: tx.TransactionIdentifier,
+			Expected:    strconv.FormatInt(index, 10),
+			Actual:      strconv.FormatInt(block.BlockIdentifier.Index, 10),
+			Message:     violation,
+		})
+	}
+
+	if len(violations) == 0 {
+		return nil, nil
+	}
+
+	for _, violation := range violations {
+		log.Printf(
+			"duplicate hash in block %s:%d: %s\n",
+			block.BlockIdentifier.Hash,
+			block.BlockIdentifier.Index,
+			violation,
+		)
+	}
+
+	return nil, fmt.Errorf(
+		"%w: %d violations in block %s:%d [%s]",
+		results.ErrDuplicateHash,
+		len(violations),
+		block.BlockIdentifier.Hash,
+		block.BlockIdentifier.Index,
+		strings.Join(violations, "; "),
+	)
+}
+
+// forget removes the record of hash if it
+// was recorded for the block at index.
+func (w *DuplicateHashWorker) forget(
+	ctx context.Context,
+	transaction storage.DatabaseTransaction,
+	namespace string,
+	hash string,
+	index int64,
+) error {
+	exists, seenIndex, err := w.seen(ctx, transaction, namespace, hash)
+	if err != nil {
+		return err
+	}
+
+	if !exists || seenIndex != index {
+		return nil
+	}
+
+	return transaction.Delete(ctx, getDuplicateHashKey(namespace, hash))
+}
+
+// RemovingBlock removes the records of all
+// hashes added in block.
+func (w *DuplicateHashWorker) RemovingBlock(
+	ctx context.Context,
+	block *types.Block,
+	transaction storage.DatabaseTransaction,
+) (storage.CommitWorker, error) {
+	index := block.BlockIdentifier.Index
+	if err := w.forget(
+		ctx,
+		transaction,
+		duplicateBlockNamespace,
+		block.BlockIdentifier.Hash,
+		index,
+	); err != nil {
+		return nil, fmt.Errorf("%w: unable to remove block hash record", err)
+	}
+
+	for _, tx := range block.Transactions {
+		if err := w.forget(
+			ctx,
+			transaction,
+			duplicateTransactionNamespace,
+			tx.TransactionIdentifier.Hash,
+			index,
+		); err != nil {
+			return nil, fmt.Errorf("%w: unable to remove transaction hash record", err)
+		}
+	}
+
+	return nil, nil
+}
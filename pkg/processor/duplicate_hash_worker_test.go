@@ -0,0 +1,127 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/coinbase/rosetta-cli/pkg/failures"
+	"github.com/coinbase/rosetta-cli/pkg/results"
+
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/coinbase/rosetta-sdk-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func duplicateTestBlock(hash string, index int64, txs ...string) *types.Block {
+	block := &types.Block{
+		BlockIdentifier: &types.BlockIdentifier{Hash: hash, Index: index},
+		Transactions:    []*types.Transaction{},
+	}
+	for _, tx := range txs {
+		block.Transactions = append(block.Transactions, &types.Transaction{
+			TransactionIdentifier: &types.TransactionIdentifier{Hash: tx},
+		})
+	}
+
+	return block
+}
+
+func TestDuplicateHashWorker(t *testing.T) {
+	ctx := context.Background()
+	dir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(dir)
+
+	localStore, err := storage.NewBadgerStorage(
+		ctx,
+		dir,
+		storage.WithIndexCacheSize(storage.TinyIndexCacheSize),
+	)
+	assert.NoError(t, err)
+	defer localStore.Close(ctx)
+
+	failureStorage := failures.NewStorage(localStore)
+	w := NewDuplicateHashWorker([]string{"coinbase"}, failureStorage)
+
+	addBlock := func(block *types.Block) error {
+		dbTx := localStore.NewDatabaseTransaction(ctx, true)
+		defer dbTx.Discard(ctx)
+
+		if _, err := w.AddingBlock(ctx, block, dbTx); err != nil {
+			return err
+		}
+
+		return dbTx.Commit(ctx)
+	}
+
+	removeBlock := func(block *types.Block) error {
+		dbTx := localStore.NewDatabaseTransaction(ctx, true)
+		defer dbTx.Discard(ctx)
+
+		if _, err := w.RemovingBlock(ctx, block, dbTx); err != nil {
+			return err
+		}
+
+		return dbTx.Commit(ctx)
+	}
+
+	block1 := duplicateTestBlock("block1", 1, "tx1", "coinbase")
+	block2 := duplicateTestBlock("block2", 2, "tx2")
+
+	t.Run("unique hashes", func(t *testing.T) {
+		assert.NoError(t, addBlock(block1))
+		assert.NoError(t, addBlock(block2))
+	})
+
+	t.Run("exempt transaction", func(t *testing.T) {
+		assert.NoError(t, addBlock(duplicateTestBlock("block3", 3, "coinbase")))
+	})
+
+	t.Run("duplicate hashes", func(t *testing.T) {
+		err := addBlock(duplicateTestBlock("block1", 4, "tx3", "tx1", "tx2"))
+		assert.True(t, errors.Is(err, results.ErrDuplicateHash))
+		assert.Contains(t, err.Error(), "3 violations")
+
+		assert.NoError(t, failureStorage.Flush(ctx))
+		recorded, err := failureStorage.GetAll(ctx)
+		assert.NoError(t, err)
+		assert.Len(t, recorded, 3)
+		for i, expected := range []string{"1", "1", "2"} {
+			assert.Equal(t, failures.DuplicateHashFailure, recorded[i].Kind)
+			assert.Equal(t, expected, recorded[i].Expected)
+			assert.Equal(t, "4", recorded[i].Actual)
+		}
+		assert.Nil(t, recorded[0].Transaction)
+		assert.Equal(t, "tx2", recorded[2].Transaction.Hash)
+	})
+
+	t.Run("transaction included again after reorg", func(t *testing.T) {
+		assert.NoError(t, removeBlock(block2))
+		assert.NoError(t, addBlock(duplicateTestBlock("block2b", 2, "tx2")))
+	})
+
+	t.Run("removing a block keeps earlier records", func(t *testing.T) {
+		// tx1 was first seen in block 1, so removing
+		// a block at another index does not forget it.
+		assert.NoError(t, removeBlock(duplicateTestBlock("block5", 5, "tx1")))
+
+		err := addBlock(duplicateTestBlock("block5", 5, "tx1"))
+		assert.True(t, errors.Is(err, results.ErrDuplicateHash))
+	})
+}
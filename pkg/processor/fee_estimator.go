@@ -0,0 +1,355 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/coinbase/rosetta-cli/pkg/results"
+
+	"github.com/coinbase/rosetta-sdk-go/asserter"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/coinbase/rosetta-sdk-go/utils"
+	"github.com/olekukonko/tablewriter"
+)
+
+const (
+	percent = 100
+
+	// Percentiles reported for the deviations
+	// of each currency.
+	p50 = 50
+	p90 = 90
+	p99 = 99
+)
+
+// FeeEstimator compares the fee suggested by /construction/metadata
+// for each broadcast transaction to the fee charged once the
+// transaction is confirmed.
+//
+// The coordinator processes a single job at a time, so the last
+// fee suggested before a broadcast is the fee suggested for the
+// broadcast transaction. Suggested fees are not persisted, so
+// transactions broadcast before a restart are not compared.
+type FeeEstimator struct {
+	asserter     *asserter.Asserter
+	maxDeviation float64
+
+	mutex      sync.Mutex
+	suggested  []*types.Amount
+	broadcasts map[string][]*types.Amount
+	deviations map[string]*feeDeviations
+}
+
+// feeDeviations are the deviations (in percent)
+// of charged fees from suggested fees in a currency.
+type feeDeviations struct {
+	currency *types.Currency
+	values   []float64
+	exceeded int
+}
+
+// NewFeeEstimator returns a new *FeeEstimator. A confirmed
+// transaction fails validation if its fee deviates from the
+// suggested fee by more than maxDeviation percent.
+func NewFeeEstimator(asserter *asserter.Asserter, maxDeviation float64) *FeeEstimator {
+	return &FeeEstimator{
+		asserter:     asserter,
+		maxDeviation: maxDeviation,
+		broadcasts:   map[string][]*types.Amount{},
+		deviations:   map[string]*feeDeviations{},
+	}
+}
+
+// Suggested records the fee suggested by /construction/metadata.
+func (e *FeeEstimator) Suggested(fee []*types.Amount) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.suggested = fee
+}
+
+// Broadcast attributes the last suggested fee to the
+// transaction broadcast by the job with identifier.
+func (e *FeeEstimator) Broadcast(identifier string) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if len(e.suggested) == 0 {
+		return
+	}
+
+	e.broadcasts[identifier] = e.suggested
+	e.suggested = nil
+}
+
+// chargedFees returns the net amount debited by the successful
+// operations in transaction (keyed by the hash of each currency).
+func (e *FeeEstimator) chargedFees(transaction *types.Transaction) (map[string]*big.Int, error) {
+	charged := map[string]*big.Int{}
+	for _, op := range transaction.Operations {
+		if op.Amount == nil {
+			continue
+		}
+
+		success, err := e.asserter.OperationSuccessful(op)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to check operation success", err)
+		}
+
+		if !success {
+			continue
+		}
+
+		amount, err := types.BigInt(op.Amount.Value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to parse operation amount", err)
+		}
+
+		key := types.Hash(op.Amount.Currency)
+		if _, ok := charged[key]; !ok {
+			charged[key] = new(big.Int)
+		}
+
+		charged[key].Sub(charged[key], amount)
+	}
+
+	return charged, nil
+}
+
+// deviation returns the percentage that charged
+// deviates from suggested (which must not be 0).
+func deviation(suggested *big.Int, charged *big.Int) float64 {
+	difference := new(big.Float).SetInt(new(big.Int).Sub(charged, suggested))
+	ratio := new(big.Float).Quo(difference, new(big.Float).SetInt(suggested))
+	value, _ := ratio.Float64()
+
+	return value * percent
+}
+
+// record adds a deviation in currency.
+func (e *FeeEstimator) record(currency *types.Currency, value float64, exceeded bool) {
+	key := types.Hash(currency)
+	if _, ok := e.deviations[key]; !ok {
+		e.deviations[key] = &feeDeviations{currency: currency}
+	}
+
+	e.deviations[key].values = append(e.deviations[key].values, value)
+	if exceeded {
+		e.deviations[key].exceeded++
+	}
+}
+
+// Confirmed compares the fee suggested for the transaction broadcast
+// by the job with identifier to the fee charged in transaction. It
+// returns an error if the fee charged in any currency deviates by
+// more than the max deviation.
+func (e *FeeEstimator) Confirmed(identifier string, transaction *types.Transaction) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	suggested, ok := e.broadcasts[identifier]
+	if !ok {
+		return nil
+	}
+	delete(e.broadcasts, identifier)
+
+	charged, err := e.chargedFees(transaction)
+	if err != nil {
+		return fmt.Errorf("%w: unable to compute charged fees", err)
+	}
+
+	violations := []string{}
+	for _, amount := range suggested {
+		suggestedFee, err := types.BigInt(amount.Value)
+		if err != nil {
+			return fmt.Errorf("%w: unable to parse suggested fee", err)
+		}
+
+		chargedFee, ok := charged[types.Hash(amount.Currency)]
+		if !ok {
+			chargedFee = new(big.Int)
+		}
+
+		// A deviation from a suggested fee of 0
+		// cannot be expressed as a percentage.
+		if suggestedFee.Sign() == 0 {
+			if chargedFee.Sign() == 0 {
+				e.record(amount.Currency, 0, false)
+				continue
+			}
+
+			violations = append(violations, fmt.Sprintf(
+				"suggested fee of 0 %s but %s was charged",
+				amount.Currency.Symbol,
+				chargedFee.String(),
+			))
+			continue
+		}
+
+		value := deviation(suggestedFee, chargedFee)
+		exceeded := math.Abs(value) > e.maxDeviation
+		e.record(amount.Currency, value, exceeded)
+		if exceeded {
+			violations = append(violations, fmt.Sprintf(
+				"suggested fee of %s %s but %s was charged (%.2f%%)",
+				suggestedFee.String(),
+				amount.Currency.Symbol,
+				chargedFee.String(),
+				value,
+			))
+		}
+	}
+
+	if len(violations) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf(
+		"%w: transaction %s [%s]",
+		results.ErrFeeDeviation,
+		transaction.TransactionIdentifier.Hash,
+		strings.Join(violations, "; "),
+	)
+}
+
+// FeeDeviationStats describes the distribution of deviations
+// (in percent) of charged fees from suggested fees in a currency.
+// Positive deviations are fees charged above the suggested fee.
+type FeeDeviationStats struct {
+	Currency     *types.Currency `json:"currency"`
+	Transactions int             `json:"transactions"`
+	Exceeded     int             `json:"exceeded"`
+	Mean         float64         `json:"mean"`
+	Min          float64         `json:"min"`
+	Max          float64         `json:"max"`
+	P50          float64         `json:"p50"`
+	P90          float64         `json:"p90"`
+	P99          float64         `json:"p99"`
+}
+
+// FeeEstimationReport contains the distribution of fee
+// deviations of each currency.
+type FeeEstimationReport struct {
+	MaxDeviation float64              `json:"max_deviation"`
+	Currencies   []*FeeDeviationStats `json:"currencies"`
+}
+
+// percentile returns the nearest-rank percentile
+// p of sorted (which must not be empty).
+func percentile(sorted []float64, p int) float64 {
+	rank := (p*len(sorted) + percent - 1) / percent
+	if rank < 1 {
+		rank = 1
+	}
+
+	return sorted[rank-1]
+}
+
+// Report returns the *FeeEstimationReport of all
+// confirmed transactions (sorted by currency symbol).
+func (e *FeeEstimator) Report() *FeeEstimationReport {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	report := &FeeEstimationReport{
+		MaxDeviation: e.maxDeviation,
+		Currencies:   []*FeeDeviationStats{},
+	}
+	for _, deviations := range e.deviations {
+		sorted := make([]float64, len(deviations.values))
+		copy(sorted, deviations.values)
+		sort.Float64s(sorted)
+
+		var sum float64
+		for _, value := range sorted {
+			sum += value
+		}
+
+		report.Currencies = append(report.Currencies, &FeeDeviationStats{
+			Currency:     deviations.currency,
+			Transactions: len(sorted),
+			Exceeded:     deviations.exceeded,
+			Mean:         sum / float64(len(sorted)),
+			Min:          sorted[0],
+			Max:          sorted[len(sorted)-1],
+			P50:          percentile(sorted, p50),
+			P90:          percentile(sorted, p90),
+			P99:          percentile(sorted, p99),
+		})
+	}
+
+	sort.Slice(report.Currencies, func(i, j int) bool {
+		return report.Currencies[i].Currency.Symbol < report.Currencies[j].Currency.Symbol
+	})
+
+	return report
+}
+
+// Save writes the report to filePath.
+func (r *FeeEstimationReport) Save(filePath string) error {
+	if err := utils.SerializeAndWrite(filePath, r); err != nil {
+		return fmt.Errorf("%w: unable to save fee estimation report", err)
+	}
+
+	return nil
+}
+
+// Print prints the distribution of fee
+// deviations of each currency as a table.
+func (r *FeeEstimationReport) Print() {
+	if len(r.Currencies) == 0 {
+		return
+	}
+
+	formatPercent := func(value float64) string {
+		return fmt.Sprintf("%.2f%%", value)
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetRowLine(true)
+	table.SetRowSeparator("-")
+	table.SetHeader([]string{
+		"Fee Currency",
+		"Transactions",
+		fmt.Sprintf("Exceeded %s", formatPercent(r.MaxDeviation)),
+		"Mean",
+		"Min",
+		"P50",
+		"P90",
+		"P99",
+		"Max",
+	})
+	for _, stats := range r.Currencies {
+		table.Append([]string{
+			stats.Currency.Symbol,
+			fmt.Sprintf("%d", stats.Transactions),
+			fmt.Sprintf("%d", stats.Exceeded),
+			formatPercent(stats.Mean),
+			formatPercent(stats.Min),
+			formatPercent(stats.P50),
+			formatPercent(stats.P90),
+			formatPercent(stats.P99),
+			formatPercent(stats.Max),
+		})
+	}
+	table.Render()
+}
@@ -0,0 +1,179 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/coinbase/rosetta-cli/pkg/results"
+
+	"github.com/coinbase/rosetta-sdk-go/asserter"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func feeEstimatorTestTransaction(hash string, ops ...*types.Operation) *types.Transaction {
+	return &types.Transaction{
+		TransactionIdentifier: &types.TransactionIdentifier{Hash: hash},
+		Operations:            ops,
+	}
+}
+
+func TestFeeEstimator(t *testing.T) {
+	a, err := asserter.NewClientWithOptions(
+		&types.NetworkIdentifier{Blockchain: "blah", Network: "testnet"},
+		&types.BlockIdentifier{Hash: "genesis", Index: 0},
+		[]string{"FEE", "TRANSFER"},
+		[]*types.OperationStatus{
+			{Status: "SUCCESS", Successful: true},
+			{Status: "FAILURE", Successful: false},
+		},
+		[]*types.Error{},
+		nil,
+	)
+	assert.NoError(t, err)
+
+	btc := &types.Currency{Symbol: "BTC", Decimals: 8}
+	suggest := func(value string) []*types.Amount {
+		return []*types.Amount{{Value: value, Currency: btc}}
+	}
+
+	var tests = map[string]struct {
+		suggested   []*types.Amount
+		transaction *types.Transaction
+
+		err       bool
+		deviation float64
+	}{
+		"exact fee": {
+			suggested: suggest("10"),
+			transaction: feeEstimatorTestTransaction(
+				"tx1",
+				feeTestOp("TRANSFER", "SUCCESS", "addr1", "-100"),
+				feeTestOp("TRANSFER", "SUCCESS", "addr2", "100"),
+				feeTestOp("FEE", "SUCCESS", "addr1", "-10"),
+			),
+		},
+		"fee within max deviation": {
+			suggested: suggest("10"),
+			transaction: feeEstimatorTestTransaction(
+				"tx2",
+				feeTestOp("TRANSFER", "SUCCESS", "addr1", "-100"),
+				feeTestOp("TRANSFER", "SUCCESS", "addr2", "91"),
+			),
+			deviation: -10,
+		},
+		"failed operations are not charged": {
+			suggested: suggest("10"),
+			transaction: feeEstimatorTestTransaction(
+				"tx3",
+				feeTestOp("TRANSFER", "FAILURE", "addr1", "-100"),
+				feeTestOp("FEE", "SUCCESS", "addr1", "-11"),
+			),
+			deviation: 10,
+		},
+		"fee exceeds max deviation": {
+			suggested: suggest("10"),
+			transaction: feeEstimatorTestTransaction(
+				"tx4",
+				feeTestOp("FEE", "SUCCESS", "addr1", "-20"),
+			),
+			err:       true,
+			deviation: 100,
+		},
+		"fee charged with zero suggested fee": {
+			suggested: suggest("0"),
+			transaction: feeEstimatorTestTransaction(
+				"tx5",
+				feeTestOp("FEE", "SUCCESS", "addr1", "-1"),
+			),
+			err: true,
+		},
+		"no suggested fee": {
+			transaction: feeEstimatorTestTransaction(
+				"tx6",
+				feeTestOp("FEE", "SUCCESS", "addr1", "-1"),
+			),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			estimator := NewFeeEstimator(a, 10)
+			estimator.Suggested(test.suggested)
+			estimator.Broadcast("job")
+
+			err := estimator.Confirmed("job", test.transaction)
+			if test.err {
+				assert.True(t, errors.Is(err, results.ErrFeeDeviation))
+			} else {
+				assert.NoError(t, err)
+			}
+
+			report := estimator.Report()
+			if len(test.suggested) == 0 || test.suggested[0].Value == "0" {
+				assert.Len(t, report.Currencies, 0)
+				return
+			}
+
+			assert.Len(t, report.Currencies, 1)
+			assert.Equal(t, btc, report.Currencies[0].Currency)
+			assert.InDelta(t, test.deviation, report.Currencies[0].Mean, 1e-9)
+
+			// Transactions are only compared once.
+			assert.NoError(t, estimator.Confirmed("job", test.transaction))
+			assert.Equal(t, 1, estimator.Report().Currencies[0].Transactions)
+		})
+	}
+}
+
+func TestFeeEstimatorReport(t *testing.T) {
+	estimator := NewFeeEstimator(nil, 10)
+	btc := &types.Currency{Symbol: "BTC", Decimals: 8}
+	eth := &types.Currency{Symbol: "ETH", Decimals: 18}
+	for i := 1; i <= 10; i++ {
+		estimator.record(btc, float64(i), i > 5)
+	}
+	estimator.record(eth, -5, false)
+
+	report := estimator.Report()
+	assert.Equal(t, &FeeEstimationReport{
+		MaxDeviation: 10,
+		Currencies: []*FeeDeviationStats{
+			{
+				Currency:     btc,
+				Transactions: 10,
+				Exceeded:     5,
+				Mean:         5.5,
+				Min:          1,
+				Max:          10,
+				P50:          5,
+				P90:          9,
+				P99:          10,
+			},
+			{
+				Currency:     eth,
+				Transactions: 1,
+				Mean:         -5,
+				Min:          -5,
+				Max:          -5,
+				P50:          -5,
+				P90:          -5,
+				P99:          -5,
+			},
+		},
+	}, report)
+}
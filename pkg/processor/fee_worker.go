@@ -0,0 +1,268 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+
+	"github.com/coinbase/rosetta-cli/pkg/failures"
+	"github.com/coinbase/rosetta-cli/pkg/results"
+
+	"github.com/coinbase/rosetta-sdk-go/asserter"
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/tidwall/gjson"
+)
+
+var _ storage.BlockWorker = (*FeeWorker)(nil)
+
+// FeeWorker implements the storage.BlockWorker interface
+// and ensures the fee operations in each transaction are
+// consistent:
+//   - every fee operation debits an account and is successful
+//     (otherwise the fee is not included in the balance change
+//     of the fee payer)
+//   - if a metadata path is provided, the total debited by fee
+//     operations equals the fee in the transaction metadata
+//
+// Reconciliation eventually catches incorrect fees but usually
+// many blocks after the transaction that caused them.
+type FeeWorker struct {
+	asserter       *asserter.Asserter
+	feeTypes       map[string]struct{}
+	metadataPath   string
+	failureStorage *failures.Storage
+}
+
+// NewFeeWorker returns a new *FeeWorker. If metadataPath
+// is empty, fees are not compared to transaction metadata.
+func NewFeeWorker(
+	asserter *asserter.Asserter,
+	feeTypes []string,
+	metadataPath string,
+	failureStorage *failures.Storage,
+) *FeeWorker {
+	typeMap := map[string]struct{}{}
+	for _, t := range feeTypes {
+		typeMap[t] = struct{}{}
+	}
+
+	return &FeeWorker{
+		asserter:       asserter,
+		feeTypes:       typeMap,
+		metadataPath:   metadataPath,
+		failureStorage: failureStorage,
+	}
+}
+
+// feeViolation describes an inconsistent fee
+// in a transaction.
+type feeViolation struct {
+	transaction *types.TransactionIdentifier
+	operation   *types.OperationIdentifier
+	account     *types.AccountIdentifier
+	expected    string
+	actual      string
+	message     string
+}
+
+func (v *feeViolation) String() string {
+	return fmt.Sprintf("transaction %s: %s", v.transaction.Hash, v.message)
+}
+
+// metadataFee returns the fee at metadataPath in the
+// metadata of tx (or nil if it is not populated).
+func (w *FeeWorker) metadataFee(tx *types.Transaction) (*big.Int, error) {
+	if len(w.metadataPath) == 0 || len(tx.Metadata) == 0 {
+		return nil, nil
+	}
+
+	encoded, err := json.Marshal(tx.Metadata)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to marshal transaction metadata", err)
+	}
+
+	result := gjson.GetBytes(encoded, w.metadataPath)
+	if !result.Exists() {
+		return nil, nil
+	}
+
+	fee, ok := new(big.Int).SetString(result.String(), 10)
+	if !ok {
+		return nil, fmt.Errorf(
+			"metadata fee %s in transaction %s is not an integer",
+			result.String(),
+			tx.TransactionIdentifier.Hash,
+		)
+	}
+
+	return fee, nil
+}
+
+// checkTransaction returns all fee violations in tx.
+func (w *FeeWorker) checkTransaction(tx *types.Transaction) ([]*feeViolation, error) {
+	violations := []*feeViolation{}
+	total := new(big.Int)
+	currencies := map[string]struct{}{}
+	feeOps := 0
+	for _, op := range tx.Operations {
+		if _, ok := w.feeTypes[op.Type]; !ok {
+			continue
+		}
+		feeOps++
+
+		if op.Account == nil || op.Amount == nil {
+			violations = append(violations, &feeViolation{
+				transaction: tx.TransactionIdentifier,
+				operation:   op.OperationIdentifier,
+				message: fmt.Sprintf(
+					"fee operation %d has no account or amount",
+					op.OperationIdentifier.Index,
+				),
+			})
+			continue
+		}
+
+		amount, err := types.BigInt(op.Amount.Value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to parse fee amount", err)
+		}
+
+		// Fee operations that credit an account (like a
+		// fee paid to a validator) are not fee payments.
+		if amount.Sign() >= 0 {
+			continue
+		}
+
+		success, err := w.asserter.OperationSuccessful(op)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to check operation success", err)
+		}
+
+		if !success {
+			violations = append(violations, &feeViolation{
+				transaction: tx.TransactionIdentifier,
+				operation:   op.OperationIdentifier,
+				account:     op.Account,
+				message: fmt.Sprintf(
+					"fee operation %d paid by %s is not successful",
+					op.OperationIdentifier.Index,
+					types.AccountString(op.Account),
+				),
+			})
+			continue
+		}
+
+		total.Sub(total, amount)
+		currencies[types.Hash(op.Amount.Currency)] = struct{}{}
+	}
+
+	fee, err := w.metadataFee(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	if fee == nil || (feeOps == 0 && fee.Sign() == 0) {
+		return violations, nil
+	}
+
+	if len(currencies) > 1 {
+		violations = append(violations, &feeViolation{
+			transaction: tx.TransactionIdentifier,
+			message: fmt.Sprintf(
+				"fee operations use %d currencies but metadata contains a single fee",
+				len(currencies),
+			),
+		})
+		return violations, nil
+	}
+
+	if total.Cmp(fee) != 0 {
+		violations = append(violations, &feeViolation{
+			transaction: tx.TransactionIdentifier,
+			expected:    fee.String(),
+			actual:      total.String(),
+			message: fmt.Sprintf(
+				"fee operations debit %s but metadata fee at %s is %s",
+				total.String(),
+				w.metadataPath,
+				fee.String(),
+			),
+		})
+	}
+
+	return violations, nil
+}
+
+// AddingBlock returns an error listing every
+// inconsistent fee in block.
+func (w *FeeWorker) AddingBlock(
+	ctx context.Context,
+	block *types.Block,
+	transaction storage.DatabaseTransaction,
+) (storage.CommitWorker, error) {
+	descriptions := []string{}
+	for _, tx := range block.Transactions {
+		violations, err := w.checkTransaction(tx)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to check fees", err)
+		}
+
+		for _, violation := range violations {
+			description := violation.String()
+			descriptions = append(descriptions, description)
+			log.Printf("fee mismatch: %s\n", description)
+
+			w.failureStorage.Defer(&failures.Failure{
+				Kind:        failures.FeeMismatchFailure,
+				Block:       block.BlockIdentifier,
+				Transaction: violation.transaction,
+				Operation:   violation.operation,
+				Account:     violation.account,
+				Expected:    violation.expected,
+				Actual:      violation.actual,
+				Message:     description,
+			})
+		}
+	}
+
+	if len(descriptions) == 0 {
+		return nil, nil
+	}
+
+	return nil, fmt.Errorf(
+		"%w: %d violations in block %s:%d [%s]",
+		results.ErrFeeMismatch,
+		len(descriptions),
+		block.BlockIdentifier.Hash,
+		block.BlockIdentifier.Index,
+		strings.Join(descriptions, "; "),
+	)
+}
+
+// RemovingBlock is a no-op because fees
+// are only checked when blocks are added.
+func (w *FeeWorker) RemovingBlock(
+	ctx context.Context,
+	block *types.Block,
+	transaction storage.DatabaseTransaction,
+) (storage.CommitWorker, error) {
+	return nil, nil
+}
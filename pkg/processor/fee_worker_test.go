@@ -0,0 +1,131 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/coinbase/rosetta-cli/pkg/failures"
+	"github.com/coinbase/rosetta-cli/pkg/results"
+
+	"github.com/coinbase/rosetta-sdk-go/asserter"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func feeTestOp(opType string, status string, address string, value string) *types.Operation {
+	return &types.Operation{
+		Type:    opType,
+		Status:  types.String(status),
+		Account: &types.AccountIdentifier{Address: address},
+		Amount: &types.Amount{
+			Value:    value,
+			Currency: &types.Currency{Symbol: "BTC", Decimals: 8},
+		},
+	}
+}
+
+func feeTestBlock(metadata map[string]interface{}, ops ...*types.Operation) *types.Block {
+	block := creationTestBlock(1, ops...)
+	block.Transactions[0].Metadata = metadata
+	return block
+}
+
+func TestFeeWorker(t *testing.T) {
+	ctx := context.Background()
+	a, err := asserter.NewClientWithOptions(
+		&types.NetworkIdentifier{Blockchain: "blah", Network: "testnet"},
+		&types.BlockIdentifier{Hash: "genesis", Index: 0},
+		[]string{"FEE", "TRANSFER"},
+		[]*types.OperationStatus{
+			{Status: "SUCCESS", Successful: true},
+			{Status: "FAILURE", Successful: false},
+		},
+		[]*types.Error{},
+		nil,
+	)
+	assert.NoError(t, err)
+
+	var tests = map[string]struct {
+		block *types.Block
+
+		violations int
+	}{
+		"consistent fee": {
+			block: feeTestBlock(
+				map[string]interface{}{"receipt": map[string]interface{}{"fee": "10"}},
+				feeTestOp("TRANSFER", "SUCCESS", "addr1", "-100"),
+				feeTestOp("TRANSFER", "SUCCESS", "addr2", "100"),
+				feeTestOp("FEE", "SUCCESS", "addr1", "-6"),
+				feeTestOp("FEE", "SUCCESS", "addr1", "-4"),
+				feeTestOp("FEE", "SUCCESS", "validator", "10"),
+			),
+		},
+		"numeric metadata fee": {
+			block: feeTestBlock(
+				map[string]interface{}{"receipt": map[string]interface{}{"fee": 10}},
+				feeTestOp("FEE", "SUCCESS", "addr1", "-10"),
+			),
+		},
+		"no metadata fee": {
+			block: feeTestBlock(
+				nil,
+				feeTestOp("FEE", "SUCCESS", "addr1", "-10"),
+			),
+		},
+		"mismatched metadata fee": {
+			block: feeTestBlock(
+				map[string]interface{}{"receipt": map[string]interface{}{"fee": "10"}},
+				feeTestOp("FEE", "SUCCESS", "addr1", "-9"),
+			),
+			violations: 1,
+		},
+		"missing fee operation": {
+			block: feeTestBlock(
+				map[string]interface{}{"receipt": map[string]interface{}{"fee": "10"}},
+				feeTestOp("TRANSFER", "SUCCESS", "addr1", "-10"),
+			),
+			violations: 1,
+		},
+		"unsuccessful fee operation": {
+			block: feeTestBlock(
+				map[string]interface{}{"receipt": map[string]interface{}{"fee": "10"}},
+				feeTestOp("FEE", "FAILURE", "addr1", "-10"),
+			),
+			violations: 2,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			failureStorage := failures.NewStorage(nil)
+			w := NewFeeWorker(a, []string{"FEE"}, "receipt.fee", failureStorage)
+
+			_, err := w.AddingBlock(ctx, test.block, nil)
+			if test.violations == 0 {
+				assert.NoError(t, err)
+				return
+			}
+
+			assert.True(t, errors.Is(err, results.ErrFeeMismatch))
+
+			violations, err := w.checkTransaction(test.block.Transactions[0])
+			assert.NoError(t, err)
+			assert.Len(t, violations, test.violations)
+		})
+	}
+}
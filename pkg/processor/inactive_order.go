@@ -0,0 +1,98 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/coinbase/rosetta-cli/configuration"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+// decimalBase is the base of the decimals of a currency.
+const decimalBase = 10
+
+// BalanceLookup returns the computed balance
+// of an account in a currency.
+type BalanceLookup func(*types.AccountCurrency) (string, error)
+
+// magnitude returns the absolute value of balance in
+// whole units of currency (so that balances in currencies
+// with different decimals can be compared).
+func magnitude(balance string, currency *types.Currency) (*big.Float, error) {
+	value, err := types.BigInt(balance)
+	if err != nil {
+		return nil, err
+	}
+
+	scale := new(big.Int).Exp(
+		big.NewInt(decimalBase),
+		big.NewInt(int64(currency.Decimals)),
+		nil,
+	)
+
+	return new(big.Float).Quo(
+		new(big.Float).SetInt(value.Abs(value)),
+		new(big.Float).SetInt(scale),
+	), nil
+}
+
+// OrderInactiveAccounts returns accounts in the order they should
+// be queued for inactive reconciliation. With the balance order,
+// accounts with the largest balances (returned by lookup) are
+// returned first and accounts with equal balances keep their
+// relative order. With any other order, accounts are
+// returned unchanged.
+func OrderInactiveAccounts(
+	accounts []*types.AccountCurrency,
+	order configuration.InactiveReconciliationOrder,
+	lookup BalanceLookup,
+) ([]*types.AccountCurrency, error) {
+	if order != configuration.BalanceInactiveReconciliationOrder {
+		return accounts, nil
+	}
+
+	magnitudes := make(map[string]*big.Float, len(accounts))
+	for _, account := range accounts {
+		balance, err := lookup(account)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"%w: unable to get balance of %s",
+				err,
+				types.PrintStruct(account),
+			)
+		}
+
+		m, err := magnitude(balance, account.Currency)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to parse balance %s", err, balance)
+		}
+
+		magnitudes[types.Hash(account)] = m
+	}
+
+	ordered := make([]*types.AccountCurrency, len(accounts))
+	copy(ordered, accounts)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		left := magnitudes[types.Hash(ordered[i])]
+		right := magnitudes[types.Hash(ordered[j])]
+		return left.Cmp(right) > 0
+	})
+
+	return ordered, nil
+}
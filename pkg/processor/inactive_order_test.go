@@ -0,0 +1,103 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/coinbase/rosetta-cli/configuration"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOrderInactiveAccounts(t *testing.T) {
+	btc := &types.Currency{Symbol: "BTC", Decimals: 8}
+	eth := &types.Currency{Symbol: "ETH", Decimals: 18}
+	accountCurrency := func(address string, currency *types.Currency) *types.AccountCurrency {
+		return &types.AccountCurrency{
+			Account:  &types.AccountIdentifier{Address: address},
+			Currency: currency,
+		}
+	}
+
+	small := accountCurrency("small", btc)                // 0.1 BTC
+	large := accountCurrency("large", btc)                // 5 BTC
+	negative := accountCurrency("negative", btc)          // -2 BTC
+	manyDecimals := accountCurrency("many decimals", eth) // 3 ETH
+	empty := accountCurrency("empty", btc)                // 0 BTC
+	balances := map[string]string{
+		"small":         "10000000",
+		"large":         "500000000",
+		"negative":      "-200000000",
+		"many decimals": "3000000000000000000",
+		"empty":         "0",
+	}
+	lookup := func(account *types.AccountCurrency) (string, error) {
+		balance, ok := balances[account.Account.Address]
+		if !ok {
+			return "", errors.New("balance not found")
+		}
+
+		return balance, nil
+	}
+
+	accounts := []*types.AccountCurrency{small, empty, large, negative, manyDecimals}
+
+	var tests = map[string]struct {
+		order    configuration.InactiveReconciliationOrder
+		accounts []*types.AccountCurrency
+
+		expected []*types.AccountCurrency
+		err      bool
+	}{
+		"default": {
+			accounts: accounts,
+			expected: accounts,
+		},
+		"seen": {
+			order:    configuration.SeenInactiveReconciliationOrder,
+			accounts: accounts,
+			expected: accounts,
+		},
+		"balance": {
+			order:    configuration.BalanceInactiveReconciliationOrder,
+			accounts: accounts,
+			expected: []*types.AccountCurrency{large, manyDecimals, negative, small, empty},
+		},
+		"missing balance": {
+			order:    configuration.BalanceInactiveReconciliationOrder,
+			accounts: []*types.AccountCurrency{small, accountCurrency("missing", btc)},
+			err:      true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ordered, err := OrderInactiveAccounts(test.accounts, test.order, lookup)
+			if test.err {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, ordered)
+		})
+	}
+
+	// The provided accounts are not reordered.
+	assert.Equal(t, []*types.AccountCurrency{small, empty, large, negative, manyDecimals}, accounts)
+}
@@ -0,0 +1,316 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sync"
+
+	"github.com/coinbase/rosetta-sdk-go/asserter"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/olekukonko/tablewriter"
+)
+
+const (
+	// MergeOperationsMatcher is the name of the built-in
+	// IntentMatcher returned by MergeOperations.
+	MergeOperationsMatcher = "merge_operations"
+)
+
+var (
+	// ErrIntentMismatch is returned when the operations of a
+	// confirmed transaction do not match its intent.
+	ErrIntentMismatch = errors.New("confirmed transaction did not match intent")
+
+	// ErrUnknownIntentMatcher is returned when an
+	// IntentMatcher is not registered.
+	ErrUnknownIntentMatcher = errors.New("unknown intent matcher")
+
+	intentMatchers = map[string]IntentMatcher{
+		MergeOperationsMatcher: MergeOperations,
+	}
+	intentMatchersMutex sync.Mutex
+)
+
+// IntentMatcher rewrites the operations of a confirmed
+// transaction before they are compared to its intent. This
+// is useful for blockchains that rewrite operations when a
+// transaction is included on-chain (for example, by splitting
+// a fee payment into multiple operations).
+type IntentMatcher func(intent []*types.Operation, observed []*types.Operation) []*types.Operation
+
+// RegisterIntentMatcher registers matcher so that it can
+// be referenced by name in the construction configuration.
+func RegisterIntentMatcher(name string, matcher IntentMatcher) {
+	intentMatchersMutex.Lock()
+	defer intentMatchersMutex.Unlock()
+
+	intentMatchers[name] = matcher
+}
+
+// GetIntentMatchers returns the registered IntentMatchers
+// with the provided names (in the same order).
+func GetIntentMatchers(names []string) ([]IntentMatcher, error) {
+	intentMatchersMutex.Lock()
+	defer intentMatchersMutex.Unlock()
+
+	matchers := make([]IntentMatcher, len(names))
+	for i, name := range names {
+		matcher, ok := intentMatchers[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownIntentMatcher, name)
+		}
+
+		matchers[i] = matcher
+	}
+
+	return matchers, nil
+}
+
+// operationKey identifies the account and
+// type of an operation (ignoring its amount).
+func operationKey(op *types.Operation) string {
+	return fmt.Sprintf("%s/%s", op.Type, types.Hash(op.Account))
+}
+
+// MergeOperations is an IntentMatcher that merges all observed
+// operations with the same type, account, and currency into a
+// single operation (summing their amounts). Unsuccessful operations
+// and operations without an amount are not merged.
+func MergeOperations(intent []*types.Operation, observed []*types.Operation) []*types.Operation {
+	merged := []*types.Operation{}
+	mergedIndex := map[string]int{}
+	for _, op := range observed {
+		if op.Amount == nil || op.Status == nil {
+			merged = append(merged, op)
+			continue
+		}
+
+		key := fmt.Sprintf(
+			"%s/%s/%s",
+			operationKey(op),
+			*op.Status,
+			types.Hash(op.Amount.Currency),
+		)
+		i, ok := mergedIndex[key]
+		if !ok {
+			mergedIndex[key] = len(merged)
+			merged = append(merged, op)
+			continue
+		}
+
+		sum, err := types.AddValues(merged[i].Amount.Value, op.Amount.Value)
+		if err != nil {
+			// Invalid amounts are left as-is so
+			// they are surfaced by the intent diff.
+			merged = append(merged, op)
+			continue
+		}
+
+		mergedOp := *merged[i]
+		mergedOp.Amount = &types.Amount{
+			Value:    sum,
+			Currency: op.Amount.Currency,
+			Metadata: merged[i].Amount.Metadata,
+		}
+		merged[i] = &mergedOp
+	}
+
+	return merged
+}
+
+// OperationMismatch is an intended operation that
+// was observed on-chain with a different amount
+// or was unsuccessful.
+type OperationMismatch struct {
+	Intended *types.Operation `json:"intended"`
+	Observed *types.Operation `json:"observed"`
+	Reason   string           `json:"reason"`
+}
+
+// IntentDiff describes how the operations of a confirmed
+// transaction differ from its intent.
+type IntentDiff struct {
+	// Missing are intended operations with no observed
+	// operation of the same type and account.
+	Missing []*types.Operation `json:"missing,omitempty"`
+
+	// Mismatched are intended operations with an observed
+	// operation of the same type and account that does
+	// not match.
+	Mismatched []*OperationMismatch `json:"mismatched,omitempty"`
+
+	// Unexpected are observed operations that do not
+	// correspond to any intended operation.
+	Unexpected []*types.Operation `json:"unexpected,omitempty"`
+}
+
+// String returns a summary of the diff.
+func (d *IntentDiff) String() string {
+	return fmt.Sprintf(
+		"%d missing, %d mismatched, %d unexpected operations",
+		len(d.Missing),
+		len(d.Mismatched),
+		len(d.Unexpected),
+	)
+}
+
+func amountString(amount *types.Amount) string {
+	if amount == nil {
+		return ""
+	}
+
+	return fmt.Sprintf("%s %s", amount.Value, amount.Currency.Symbol)
+}
+
+// Print logs the diff to the console.
+func (d *IntentDiff) Print(transaction *types.TransactionIdentifier) {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetRowLine(true)
+	table.SetRowSeparator("-")
+	table.SetHeader([]string{
+		fmt.Sprintf("Intent Diff (%s)", transaction.Hash),
+		"Type",
+		"Account",
+		"Intended",
+		"Observed",
+	})
+	for _, op := range d.Missing {
+		table.Append([]string{
+			"MISSING",
+			op.Type,
+			types.AccountString(op.Account),
+			amountString(op.Amount),
+			"",
+		})
+	}
+	for _, mismatch := range d.Mismatched {
+		table.Append([]string{
+			fmt.Sprintf("MISMATCHED (%s)", mismatch.Reason),
+			mismatch.Intended.Type,
+			types.AccountString(mismatch.Intended.Account),
+			amountString(mismatch.Intended.Amount),
+			amountString(mismatch.Observed.Amount),
+		})
+	}
+	for _, op := range d.Unexpected {
+		table.Append([]string{
+			"UNEXPECTED",
+			op.Type,
+			types.AccountString(op.Account),
+			"",
+			amountString(op.Amount),
+		})
+	}
+
+	table.Render()
+}
+
+// DiffIntent returns the *IntentDiff between intent and the
+// operations observed on-chain. Operations are paired by type
+// and account, preferring observed operations that match exactly.
+func DiffIntent(
+	asserter *asserter.Asserter,
+	intent []*types.Operation,
+	observed []*types.Operation,
+) (*IntentDiff, error) {
+	successful := make([]bool, len(observed))
+	for i, op := range observed {
+		success, err := asserter.OperationSuccessful(op)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to check operation success", err)
+		}
+
+		successful[i] = success
+	}
+
+	used := make([]bool, len(observed))
+	matched := make([]bool, len(intent))
+	for i, intended := range intent {
+		for j, op := range observed {
+			if used[j] || !successful[j] || operationKey(op) != operationKey(intended) {
+				continue
+			}
+
+			if types.Hash(op.Amount) == types.Hash(intended.Amount) {
+				used[j] = true
+				matched[i] = true
+				break
+			}
+		}
+	}
+
+	diff := &IntentDiff{}
+	for i, intended := range intent {
+		if matched[i] {
+			continue
+		}
+
+		mismatch := &OperationMismatch{Intended: intended}
+		for j, op := range observed {
+			if used[j] || operationKey(op) != operationKey(intended) {
+				continue
+			}
+
+			used[j] = true
+			mismatch.Observed = op
+			mismatch.Reason = mismatchReason(intended, op, successful[j])
+			break
+		}
+
+		if mismatch.Observed == nil {
+			diff.Missing = append(diff.Missing, intended)
+			continue
+		}
+
+		diff.Mismatched = append(diff.Mismatched, mismatch)
+	}
+
+	for j, op := range observed {
+		if !used[j] {
+			diff.Unexpected = append(diff.Unexpected, op)
+		}
+	}
+
+	return diff, nil
+}
+
+// mismatchReason returns why observed does not match
+// intended (assuming both have the same type and account).
+func mismatchReason(intended *types.Operation, observed *types.Operation, success bool) string {
+	switch {
+	case !success:
+		return "unsuccessful"
+	case intended.Amount == nil || observed.Amount == nil:
+		return "amount"
+	case types.Hash(intended.Amount.Currency) != types.Hash(observed.Amount.Currency):
+		return "currency"
+	}
+
+	intendedValue, err := types.BigInt(intended.Amount.Value)
+	if err != nil {
+		return "amount"
+	}
+
+	observedValue, err := types.BigInt(observed.Amount.Value)
+	if err != nil {
+		return "amount"
+	}
+
+	return fmt.Sprintf("amount differs by %s", new(big.Int).Sub(observedValue, intendedValue))
+}
@@ -0,0 +1,155 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/asserter"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func intentTestOp(opType string, address string, value string) *types.Operation {
+	return &types.Operation{
+		Type:    opType,
+		Account: &types.AccountIdentifier{Address: address},
+		Amount: &types.Amount{
+			Value:    value,
+			Currency: &types.Currency{Symbol: "BTC", Decimals: 8},
+		},
+	}
+}
+
+func observedTestOp(opType string, status string, address string, value string) *types.Operation {
+	op := intentTestOp(opType, address, value)
+	op.Status = types.String(status)
+	return op
+}
+
+func TestDiffIntent(t *testing.T) {
+	a, err := asserter.NewClientWithOptions(
+		&types.NetworkIdentifier{Blockchain: "blah", Network: "testnet"},
+		&types.BlockIdentifier{Hash: "genesis", Index: 0},
+		[]string{"FEE", "TRANSFER"},
+		[]*types.OperationStatus{
+			{Status: "SUCCESS", Successful: true},
+			{Status: "FAILURE", Successful: false},
+		},
+		[]*types.Error{},
+		nil,
+	)
+	assert.NoError(t, err)
+
+	intent := []*types.Operation{
+		intentTestOp("TRANSFER", "addr1", "-100"),
+		intentTestOp("TRANSFER", "addr2", "100"),
+		intentTestOp("FEE", "addr1", "-10"),
+	}
+
+	var tests = map[string]struct {
+		observed []*types.Operation
+
+		diff *IntentDiff
+	}{
+		"matching": {
+			observed: []*types.Operation{
+				observedTestOp("FEE", "SUCCESS", "addr1", "-10"),
+				observedTestOp("TRANSFER", "SUCCESS", "addr1", "-100"),
+				observedTestOp("TRANSFER", "SUCCESS", "addr2", "100"),
+			},
+			diff: &IntentDiff{},
+		},
+		"missing, mismatched, and unexpected": {
+			observed: []*types.Operation{
+				observedTestOp("TRANSFER", "SUCCESS", "addr1", "-100"),
+				observedTestOp("TRANSFER", "SUCCESS", "addr2", "90"),
+				observedTestOp("FEE", "SUCCESS", "validator", "10"),
+			},
+			diff: &IntentDiff{
+				Missing: []*types.Operation{intent[2]},
+				Mismatched: []*OperationMismatch{
+					{
+						Intended: intent[1],
+						Observed: observedTestOp("TRANSFER", "SUCCESS", "addr2", "90"),
+						Reason:   "amount differs by -10",
+					},
+				},
+				Unexpected: []*types.Operation{
+					observedTestOp("FEE", "SUCCESS", "validator", "10"),
+				},
+			},
+		},
+		"unsuccessful": {
+			observed: []*types.Operation{
+				observedTestOp("TRANSFER", "FAILURE", "addr1", "-100"),
+				observedTestOp("TRANSFER", "SUCCESS", "addr2", "100"),
+				observedTestOp("FEE", "SUCCESS", "addr1", "-10"),
+			},
+			diff: &IntentDiff{
+				Mismatched: []*OperationMismatch{
+					{
+						Intended: intent[0],
+						Observed: observedTestOp("TRANSFER", "FAILURE", "addr1", "-100"),
+						Reason:   "unsuccessful",
+					},
+				},
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			diff, err := DiffIntent(a, intent, test.observed)
+			assert.NoError(t, err)
+			assert.Equal(t, test.diff, diff)
+		})
+	}
+}
+
+func TestMergeOperations(t *testing.T) {
+	observed := []*types.Operation{
+		observedTestOp("TRANSFER", "SUCCESS", "addr1", "-100"),
+		observedTestOp("FEE", "SUCCESS", "addr1", "-6"),
+		observedTestOp("FEE", "SUCCESS", "addr1", "-4"),
+		observedTestOp("FEE", "FAILURE", "addr1", "-1"),
+		observedTestOp("FEE", "SUCCESS", "validator", "10"),
+	}
+
+	assert.Equal(t, []*types.Operation{
+		observedTestOp("TRANSFER", "SUCCESS", "addr1", "-100"),
+		observedTestOp("FEE", "SUCCESS", "addr1", "-10"),
+		observedTestOp("FEE", "FAILURE", "addr1", "-1"),
+		observedTestOp("FEE", "SUCCESS", "validator", "10"),
+	}, MergeOperations(nil, observed))
+
+	// Observed operations are not modified.
+	assert.Equal(t, "-6", observed[1].Amount.Value)
+}
+
+func TestGetIntentMatchers(t *testing.T) {
+	RegisterIntentMatcher("identity", func(intent, observed []*types.Operation) []*types.Operation {
+		return observed
+	})
+
+	matchers, err := GetIntentMatchers([]string{MergeOperationsMatcher, "identity"})
+	assert.NoError(t, err)
+	assert.Len(t, matchers, 2)
+
+	matchers, err = GetIntentMatchers([]string{"fee_split"})
+	assert.True(t, errors.Is(err, ErrUnknownIntentMatcher))
+	assert.Nil(t, matchers)
+}
@@ -0,0 +1,104 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/coinbase/rosetta-cli/pkg/failures"
+	"github.com/coinbase/rosetta-cli/pkg/invariant"
+	"github.com/coinbase/rosetta-cli/pkg/results"
+
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+var _ storage.BlockWorker = (*InvariantWorker)(nil)
+
+// InvariantWorker implements the storage.BlockWorker interface
+// and ensures every added block satisfies a collection of
+// user-defined invariants.
+type InvariantWorker struct {
+	invariants     []*invariant.Invariant
+	failureStorage *failures.Storage
+}
+
+// NewInvariantWorker returns a new *InvariantWorker.
+func NewInvariantWorker(
+	invariants []*invariant.Invariant,
+	failureStorage *failures.Storage,
+) *InvariantWorker {
+	return &InvariantWorker{
+		invariants:     invariants,
+		failureStorage: failureStorage,
+	}
+}
+
+// AddingBlock returns an error listing every
+// invariant violation in block.
+func (w *InvariantWorker) AddingBlock(
+	ctx context.Context,
+	block *types.Block,
+	transaction storage.DatabaseTransaction,
+) (storage.CommitWorker, error) {
+	violations, err := invariant.Check(w.invariants, block)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to check invariants", err)
+	}
+
+	if len(violations) == 0 {
+		return nil, nil
+	}
+
+	descriptions := make([]string, len(violations))
+	for i, violation := range violations {
+		descriptions[i] = violation.String()
+		log.Printf("invariant violation: %s\n", descriptions[i])
+
+		w.failureStorage.Defer(&failures.Failure{
+			Kind:        failures.InvariantFailure,
+			Block:       violation.Block,
+			Transaction: violation.Transaction,
+			Operation:   violation.Operation,
+			Context: map[string]string{
+				"invariant": violation.Invariant,
+				"assertion": violation.Assertion,
+			},
+			Message: descriptions[i],
+		})
+	}
+
+	return nil, fmt.Errorf(
+		"%w: %d violations in block %s:%d [%s]",
+		results.ErrInvariantViolation,
+		len(violations),
+		block.BlockIdentifier.Hash,
+		block.BlockIdentifier.Index,
+		strings.Join(descriptions, "; "),
+	)
+}
+
+// RemovingBlock is a no-op because invariants
+// are only evaluated when blocks are added.
+func (w *InvariantWorker) RemovingBlock(
+	ctx context.Context,
+	block *types.Block,
+	transaction storage.DatabaseTransaction,
+) (storage.CommitWorker, error) {
+	return nil, nil
+}
@@ -0,0 +1,71 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/coinbase/rosetta-cli/pkg/failures"
+	"github.com/coinbase/rosetta-cli/pkg/invariant"
+	"github.com/coinbase/rosetta-cli/pkg/results"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInvariantWorker(t *testing.T) {
+	ctx := context.Background()
+	fees, err := invariant.New(
+		"negative fees",
+		invariant.OperationScope,
+		`type == "FEE"`,
+		"amount.value < 0",
+	)
+	assert.NoError(t, err)
+
+	// Failures are only deferred by the worker, so
+	// no database is needed.
+	w := NewInvariantWorker([]*invariant.Invariant{fees}, failures.NewStorage(nil))
+	feeOp := func(value string) *types.Operation {
+		return &types.Operation{
+			Type: "FEE",
+			Amount: &types.Amount{
+				Value:    value,
+				Currency: &types.Currency{Symbol: "BTC", Decimals: 8},
+			},
+		}
+	}
+
+	t.Run("no violations", func(t *testing.T) {
+		commitWorker, err := w.AddingBlock(ctx, creationTestBlock(1, feeOp("-10")), nil)
+		assert.Nil(t, commitWorker)
+		assert.NoError(t, err)
+	})
+
+	t.Run("violation", func(t *testing.T) {
+		block := creationTestBlock(2, feeOp("-10"), feeOp("10"))
+		commitWorker, err := w.AddingBlock(ctx, block, nil)
+		assert.Nil(t, commitWorker)
+		assert.True(t, errors.Is(err, results.ErrInvariantViolation))
+		assert.Contains(t, err.Error(), "negative fees violated by block :2 transaction tx operation 1")
+
+		// Invariants are not evaluated when blocks are removed.
+		commitWorker, err = w.RemovingBlock(ctx, block, nil)
+		assert.Nil(t, commitWorker)
+		assert.NoError(t, err)
+	})
+}
@@ -0,0 +1,84 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"time"
+
+	"github.com/coinbase/rosetta-cli/pkg/metrics"
+
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+var _ storage.BlockWorker = (*MetricsWorker)(nil)
+
+// MetricsWorker implements the storage.BlockWorker interface
+// and emits a metric for each added and removed block. Metrics
+// are only emitted once the block is committed to storage.
+type MetricsWorker struct {
+	client *metrics.Client
+
+	// lastCommit is when the last block was added
+	// (blocks are never added concurrently).
+	lastCommit time.Time
+}
+
+// NewMetricsWorker returns a new *MetricsWorker.
+func NewMetricsWorker(client *metrics.Client) *MetricsWorker {
+	return &MetricsWorker{client: client}
+}
+
+// AddingBlock increments the block, transaction, and
+// operation counters and records the time since the
+// previous block was added.
+func (w *MetricsWorker) AddingBlock(
+	ctx context.Context,
+	block *types.Block,
+	transaction storage.DatabaseTransaction,
+) (storage.CommitWorker, error) {
+	return func(ctx context.Context) error {
+		operations := 0
+		for _, tx := range block.Transactions {
+			operations += len(tx.Operations)
+		}
+
+		w.client.Count("blocks.added", 1)
+		w.client.Count("transactions.added", int64(len(block.Transactions)))
+		w.client.Count("operations.added", int64(operations))
+
+		now := time.Now()
+		if !w.lastCommit.IsZero() {
+			w.client.Timing("block.sync_time", now.Sub(w.lastCommit))
+		}
+		w.lastCommit = now
+
+		return nil
+	}, nil
+}
+
+// RemovingBlock increments the orphaned
+// block counter.
+func (w *MetricsWorker) RemovingBlock(
+	ctx context.Context,
+	block *types.Block,
+	transaction storage.DatabaseTransaction,
+) (storage.CommitWorker, error) {
+	return func(ctx context.Context) error {
+		w.client.Count("blocks.removed", 1)
+		return nil
+	}, nil
+}
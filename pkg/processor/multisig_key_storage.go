@@ -0,0 +1,269 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/coinbase/rosetta-sdk-go/keys"
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+// multisigSignerNamespace is prepended to any stored
+// additional signer of a multi-signature account.
+const multisigSignerNamespace = "multisig_signer"
+
+// ErrMissingSigner is returned when more payloads must be
+// signed by an account than it has keys.
+var ErrMissingSigner = errors.New("not enough keys to sign payloads")
+
+// RemoteSigner signs payloads for accounts whose private
+// keys are not stored locally (see pkg/signer).
+type RemoteSigner interface {
+	Manages(account *types.AccountIdentifier) bool
+	Sign(ctx context.Context, payload *types.SigningPayload) (*types.Signature, error)
+}
+
+// CurvePlugins generate keys and sign payloads on curves
+// that are not natively supported (see pkg/curves).
+type CurvePlugins interface {
+	Supports(curve types.CurveType) bool
+	Sign(
+		ctx context.Context,
+		keyPair *keys.KeyPair,
+		payload *types.SigningPayload,
+	) (*types.Signature, error)
+	Replace(ctx context.Context, publicKey *types.PublicKey) (*keys.KeyPair, bool, error)
+}
+
+// MultisigKeyStorage wraps a *storage.KeyStorage to allow
+// multiple keys to be stored for a single account (i.e. a
+// multi-signature account).
+//
+// The first key stored for an account is stored in the
+// *storage.KeyStorage. Any other key stored for the same
+// account is stored as an additional signer. When signing,
+// the nth payload for an account is signed by the nth key
+// stored for the account (so an implementation can request
+// multiple signatures for a multi-signature account by
+// returning multiple payloads for it from /construction/payloads).
+//
+// Payloads for accounts managed by a RemoteSigner are signed
+// by the RemoteSigner instead (with a single key). Payloads
+// for keys on a curve handled by CurvePlugins are signed by
+// the curve plugin.
+type MultisigKeyStorage struct {
+	db           storage.Database
+	keyStorage   *storage.KeyStorage
+	remoteSigner RemoteSigner
+	curvePlugins CurvePlugins
+}
+
+// NewMultisigKeyStorage returns a new *MultisigKeyStorage.
+// If remoteSigner is nil, all payloads are signed with
+// keys in keyStorage.
+func NewMultisigKeyStorage(
+	db storage.Database,
+	keyStorage *storage.KeyStorage,
+	remoteSigner RemoteSigner,
+) *MultisigKeyStorage {
+	return &MultisigKeyStorage{
+		db:           db,
+		keyStorage:   keyStorage,
+		remoteSigner: remoteSigner,
+	}
+}
+
+func getSignerPrefix(account *types.AccountIdentifier) []byte {
+	return []byte(fmt.Sprintf("%s/%s/", multisigSignerNamespace, types.Hash(account)))
+}
+
+func getSignerKey(account *types.AccountIdentifier, index int) []byte {
+	return []byte(fmt.Sprintf("%s%010d", getSignerPrefix(account), index))
+}
+
+// additionalSigners returns all additional signers
+// of account (in the order they were stored).
+func (m *MultisigKeyStorage) additionalSigners(
+	ctx context.Context,
+	dbTx storage.DatabaseTransaction,
+	account *types.AccountIdentifier,
+) ([]*keys.KeyPair, error) {
+	signers := []*keys.KeyPair{}
+	_, err := dbTx.Scan(
+		ctx,
+		getSignerPrefix(account),
+		getSignerPrefix(account),
+		func(k []byte, v []byte) error {
+			var key storage.Key
+			if err := m.db.Encoder().Decode("", v, &key, false); err != nil {
+				return fmt.Errorf("%w: unable to decode signer", err)
+			}
+
+			signers = append(signers, key.KeyPair)
+			return nil
+		},
+		false,
+		false,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to scan signers", err)
+	}
+
+	return signers, nil
+}
+
+// StoreTransactional stores keyPair for account and returns
+// a boolean indicating if account was created (i.e. keyPair
+// is the first key stored for account).
+func (m *MultisigKeyStorage) StoreTransactional(
+	ctx context.Context,
+	dbTx storage.DatabaseTransaction,
+	account *types.AccountIdentifier,
+	keyPair *keys.KeyPair,
+) (bool, error) {
+	_, err := m.keyStorage.GetTransactional(ctx, dbTx, account)
+	if errors.Is(err, storage.ErrAddrNotFound) {
+		return true, m.keyStorage.StoreTransactional(ctx, account, keyPair, dbTx)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	signers, err := m.additionalSigners(ctx, dbTx, account)
+	if err != nil {
+		return false, err
+	}
+
+	val, err := m.db.Encoder().Encode("", &storage.Key{
+		Account: account,
+		KeyPair: keyPair,
+	})
+	if err != nil {
+		return false, fmt.Errorf("%w: unable to encode signer", err)
+	}
+
+	if err := dbTx.Set(ctx, getSignerKey(account, len(signers)), val, true); err != nil {
+		return false, fmt.Errorf("%w: unable to store signer", err)
+	}
+
+	return false, nil
+}
+
+// SignersTransactional returns all keys stored for
+// account (starting with the first key stored).
+func (m *MultisigKeyStorage) SignersTransactional(
+	ctx context.Context,
+	dbTx storage.DatabaseTransaction,
+	account *types.AccountIdentifier,
+) ([]*keys.KeyPair, error) {
+	keyPair, err := m.keyStorage.GetTransactional(ctx, dbTx, account)
+	if err != nil {
+		return nil, err
+	}
+
+	signers, err := m.additionalSigners(ctx, dbTx, account)
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]*keys.KeyPair{keyPair}, signers...), nil
+}
+
+// Sign signs each payload with the next key stored
+// for the payload's account.
+func (m *MultisigKeyStorage) Sign(
+	ctx context.Context,
+	payloads []*types.SigningPayload,
+) ([]*types.Signature, error) {
+	dbTx := m.db.NewDatabaseTransaction(ctx, false)
+	defer dbTx.Discard(ctx)
+
+	accountSigners := map[string][]*keys.KeyPair{}
+	signed := map[string]int{}
+	signatures := make([]*types.Signature, len(payloads))
+	for i, payload := range payloads {
+		if m.remoteSigner != nil && m.remoteSigner.Manages(payload.AccountIdentifier) {
+			signature, err := m.remoteSigner.Sign(ctx, payload)
+			if err != nil {
+				return nil, fmt.Errorf("%w for %d: %v", storage.ErrSignPayloadFailed, i, err)
+			}
+
+			signatures[i] = signature
+			continue
+		}
+
+		accountKey := types.Hash(payload.AccountIdentifier)
+		signers, ok := accountSigners[accountKey]
+		if !ok {
+			var err error
+			signers, err = m.SignersTransactional(ctx, dbTx, payload.AccountIdentifier)
+			if err != nil {
+				return nil, fmt.Errorf(
+					"%w: unable to get keys for %s",
+					err,
+					types.PrintStruct(payload.AccountIdentifier),
+				)
+			}
+
+			accountSigners[accountKey] = signers
+		}
+
+		if signed[accountKey] >= len(signers) {
+			return nil, fmt.Errorf(
+				"%w: payload %d is signature %d for %s but only %d keys are stored",
+				ErrMissingSigner,
+				i,
+				signed[accountKey]+1,
+				types.PrintStruct(payload.AccountIdentifier),
+				len(signers),
+			)
+		}
+
+		keyPair := signers[signed[accountKey]]
+		if m.curvePlugins != nil && m.curvePlugins.Supports(keyPair.PublicKey.CurveType) {
+			signature, err := m.curvePlugins.Sign(ctx, keyPair, payload)
+			if err != nil {
+				return nil, fmt.Errorf("%w for %d: %v", storage.ErrSignPayloadFailed, i, err)
+			}
+
+			signatures[i] = signature
+			signed[accountKey]++
+			continue
+		}
+
+		if len(payload.SignatureType) == 0 {
+			return nil, fmt.Errorf("%w %d", storage.ErrDetermineSigTypeFailed, i)
+		}
+
+		signer, err := keyPair.Signer()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", storage.ErrSignerCreateFailed, err)
+		}
+
+		signature, err := signer.Sign(payload, payload.SignatureType)
+		if err != nil {
+			return nil, fmt.Errorf("%w for %d: %v", storage.ErrSignPayloadFailed, i, err)
+		}
+
+		signatures[i] = signature
+		signed[accountKey]++
+	}
+
+	return signatures, nil
+}
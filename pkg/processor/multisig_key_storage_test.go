@@ -0,0 +1,272 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/keys"
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/coinbase/rosetta-sdk-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMultisigKeyStorage(t *testing.T) {
+	ctx := context.Background()
+	dir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(dir)
+
+	database, err := storage.NewBadgerStorage(
+		ctx,
+		dir,
+		storage.WithIndexCacheSize(storage.TinyIndexCacheSize),
+	)
+	assert.NoError(t, err)
+	defer database.Close(ctx)
+
+	m := NewMultisigKeyStorage(database, storage.NewKeyStorage(database), nil)
+	single := &types.AccountIdentifier{Address: "single"}
+	multisig := &types.AccountIdentifier{Address: "multisig"}
+	keyPairs := make([]*keys.KeyPair, 3)
+	for i := range keyPairs {
+		keyPairs[i], err = keys.GenerateKeypair(types.Edwards25519)
+		assert.NoError(t, err)
+	}
+
+	t.Run("store keys", func(t *testing.T) {
+		dbTx := database.NewDatabaseTransaction(ctx, true)
+		defer dbTx.Discard(ctx)
+
+		created, err := m.StoreTransactional(ctx, dbTx, single, keyPairs[0])
+		assert.NoError(t, err)
+		assert.True(t, created)
+
+		for i, keyPair := range keyPairs {
+			created, err := m.StoreTransactional(ctx, dbTx, multisig, keyPair)
+			assert.NoError(t, err)
+			assert.Equal(t, i == 0, created)
+		}
+
+		assert.NoError(t, dbTx.Commit(ctx))
+	})
+
+	t.Run("get signers", func(t *testing.T) {
+		dbTx := database.NewDatabaseTransaction(ctx, false)
+		defer dbTx.Discard(ctx)
+
+		signers, err := m.SignersTransactional(ctx, dbTx, single)
+		assert.NoError(t, err)
+		assert.Equal(t, keyPairs[:1], signers)
+
+		signers, err = m.SignersTransactional(ctx, dbTx, multisig)
+		assert.NoError(t, err)
+		assert.Equal(t, keyPairs, signers)
+
+		_, err = m.SignersTransactional(ctx, dbTx, &types.AccountIdentifier{Address: "missing"})
+		assert.True(t, errors.Is(err, storage.ErrAddrNotFound))
+	})
+
+	payload := func(account *types.AccountIdentifier, bytes string) *types.SigningPayload {
+		return &types.SigningPayload{
+			AccountIdentifier: account,
+			Bytes:             []byte(bytes),
+			SignatureType:     types.Ed25519,
+		}
+	}
+
+	t.Run("sign with each key", func(t *testing.T) {
+		payloads := []*types.SigningPayload{
+			payload(multisig, "payload 1"),
+			payload(single, "payload 2"),
+			payload(multisig, "payload 3"),
+			payload(multisig, "payload 4"),
+		}
+		signatures, err := m.Sign(ctx, payloads)
+		assert.NoError(t, err)
+		assert.Len(t, signatures, len(payloads))
+
+		expectedSigners := []*keys.KeyPair{keyPairs[0], keyPairs[0], keyPairs[1], keyPairs[2]}
+		for i, signature := range signatures {
+			assert.Equal(t, payloads[i], signature.SigningPayload)
+			assert.Equal(t, expectedSigners[i].PublicKey, signature.PublicKey)
+
+			signer, err := expectedSigners[i].Signer()
+			assert.NoError(t, err)
+			assert.NoError(t, signer.Verify(signature))
+		}
+	})
+
+	t.Run("too many payloads", func(t *testing.T) {
+		signatures, err := m.Sign(ctx, []*types.SigningPayload{
+			payload(single, "payload 1"),
+			payload(single, "payload 2"),
+		})
+		assert.Nil(t, signatures)
+		assert.True(t, errors.Is(err, ErrMissingSigner))
+	})
+
+	t.Run("missing account", func(t *testing.T) {
+		signatures, err := m.Sign(ctx, []*types.SigningPayload{
+			payload(&types.AccountIdentifier{Address: "missing"}, "payload 1"),
+		})
+		assert.Nil(t, signatures)
+		assert.True(t, errors.Is(err, storage.ErrAddrNotFound))
+	})
+}
+
+// testRemoteSigner signs payloads for
+// account with keyPair.
+type testRemoteSigner struct {
+	account *types.AccountIdentifier
+	keyPair *keys.KeyPair
+}
+
+func (s *testRemoteSigner) Manages(account *types.AccountIdentifier) bool {
+	return types.Hash(account) == types.Hash(s.account)
+}
+
+func (s *testRemoteSigner) Sign(
+	ctx context.Context,
+	payload *types.SigningPayload,
+) (*types.Signature, error) {
+	signer, err := s.keyPair.Signer()
+	if err != nil {
+		return nil, err
+	}
+
+	return signer.Sign(payload, payload.SignatureType)
+}
+
+func TestMultisigKeyStorageRemoteSigner(t *testing.T) {
+	ctx := context.Background()
+	dir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(dir)
+
+	database, err := storage.NewBadgerStorage(
+		ctx,
+		dir,
+		storage.WithIndexCacheSize(storage.TinyIndexCacheSize),
+	)
+	assert.NoError(t, err)
+	defer database.Close(ctx)
+
+	localKey, err := keys.GenerateKeypair(types.Edwards25519)
+	assert.NoError(t, err)
+	remoteKey, err := keys.GenerateKeypair(types.Edwards25519)
+	assert.NoError(t, err)
+
+	local := &types.AccountIdentifier{Address: "local"}
+	remote := &types.AccountIdentifier{Address: "remote"}
+	keyStorage := storage.NewKeyStorage(database)
+	assert.NoError(t, keyStorage.Store(ctx, local, localKey))
+
+	// The remote account is not in keyStorage, so signing
+	// only succeeds if its payload is routed to the remote signer.
+	m := NewMultisigKeyStorage(
+		database,
+		keyStorage,
+		&testRemoteSigner{account: remote, keyPair: remoteKey},
+	)
+	payloads := []*types.SigningPayload{
+		{AccountIdentifier: remote, Bytes: []byte("payload 1"), SignatureType: types.Ed25519},
+		{AccountIdentifier: local, Bytes: []byte("payload 2"), SignatureType: types.Ed25519},
+	}
+	signatures, err := m.Sign(ctx, payloads)
+	assert.NoError(t, err)
+	assert.Len(t, signatures, len(payloads))
+
+	for i, keyPair := range []*keys.KeyPair{remoteKey, localKey} {
+		assert.Equal(t, keyPair.PublicKey, signatures[i].PublicKey)
+
+		signer, err := keyPair.Signer()
+		assert.NoError(t, err)
+		assert.NoError(t, signer.Verify(signatures[i]))
+	}
+}
+
+// testCurvePlugins signs payloads for keys on
+// curve with a fixed signature.
+type testCurvePlugins struct {
+	curve types.CurveType
+}
+
+func (p *testCurvePlugins) Supports(curve types.CurveType) bool {
+	return curve == p.curve
+}
+
+func (p *testCurvePlugins) Sign(
+	ctx context.Context,
+	keyPair *keys.KeyPair,
+	payload *types.SigningPayload,
+) (*types.Signature, error) {
+	return &types.Signature{
+		SigningPayload: payload,
+		PublicKey:      keyPair.PublicKey,
+		SignatureType:  "schnorrkel",
+		Bytes:          keyPair.PrivateKey,
+	}, nil
+}
+
+func (p *testCurvePlugins) Replace(
+	ctx context.Context,
+	publicKey *types.PublicKey,
+) (*keys.KeyPair, bool, error) {
+	return nil, false, nil
+}
+
+func TestMultisigKeyStorageCurvePlugins(t *testing.T) {
+	ctx := context.Background()
+	dir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(dir)
+
+	database, err := storage.NewBadgerStorage(
+		ctx,
+		dir,
+		storage.WithIndexCacheSize(storage.TinyIndexCacheSize),
+	)
+	assert.NoError(t, err)
+	defer database.Close(ctx)
+
+	pluginKey := &keys.KeyPair{
+		PublicKey:  &types.PublicKey{Bytes: []byte{1, 2}, CurveType: "sr25519"},
+		PrivateKey: []byte{3, 4},
+	}
+	account := &types.AccountIdentifier{Address: "substrate"}
+	keyStorage := storage.NewKeyStorage(database)
+	assert.NoError(t, keyStorage.Store(ctx, account, pluginKey))
+
+	m := NewMultisigKeyStorage(database, keyStorage, nil)
+	m.curvePlugins = &testCurvePlugins{curve: "sr25519"}
+
+	// The payload has no signature type (sr25519 is not a valid
+	// signature type), so the curve plugin chooses it.
+	payload := &types.SigningPayload{AccountIdentifier: account, Bytes: []byte("payload")}
+	signatures, err := m.Sign(ctx, []*types.SigningPayload{payload})
+	assert.NoError(t, err)
+	assert.Equal(t, []*types.Signature{
+		{
+			SigningPayload: payload,
+			PublicKey:      pluginKey.PublicKey,
+			SignatureType:  "schnorrkel",
+			Bytes:          []byte{3, 4},
+		},
+	}, signatures)
+}
@@ -0,0 +1,234 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coinbase/rosetta-sdk-go/fetcher"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/fatih/color"
+)
+
+const (
+	// nodePollInterval is the frequency that /network/status
+	// is queried while waiting for an unavailable node.
+	nodePollInterval = 5 * time.Second
+)
+
+var (
+	// ErrNodeUnavailable is returned when a node does not
+	// become available before the configured patience
+	// is exhausted.
+	ErrNodeUnavailable = errors.New("node unavailable")
+
+	// ErrNodeGenesisChanged is returned when a node comes
+	// back online with a different genesis block (usually
+	// because its data directory was reset).
+	ErrNodeGenesisChanged = errors.New("node genesis block changed")
+
+	// connectionErrors are substrings of errors returned when
+	// a node cannot be reached. Errors are often wrapped with %v
+	// by the syncer and reconciler, so we must match on strings
+	// instead of using errors.Is.
+	connectionErrors = []string{
+		"connection refused",
+		"connection reset by peer",
+		"no such host",
+		"EOF",
+	}
+)
+
+// connectionError returns a boolean indicating if err
+// was caused by a failure to connect to a node.
+func connectionError(err error) bool {
+	msg := err.Error()
+	for _, connectionErr := range connectionErrors {
+		if strings.Contains(msg, connectionErr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// NodeUnavailable returns a boolean indicating if err may
+// have been caused by a node that could not be reached. The
+// fetcher does not include the cause of the last attempt when
+// retries are exhausted, so any exhausted request is considered
+// a potential node restart.
+func NodeUnavailable(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	return connectionError(err) ||
+		strings.Contains(err.Error(), fetcher.ErrExhaustedRetries.Error())
+}
+
+// NodeMonitor pauses callers while a node is unavailable
+// (for example, while it restarts during maintenance) so that
+// a long-running check does not exit or burn its retry budget.
+type NodeMonitor struct {
+	network      *types.NetworkIdentifier
+	fetcher      *fetcher.Fetcher
+	patience     time.Duration
+	pollInterval time.Duration
+
+	// All callers that encounter an unavailable node
+	// share a single wait.
+	waitMutex sync.Mutex
+	current   *nodeWait
+}
+
+// nodeWait is the result of a single wait
+// for an unavailable node.
+type nodeWait struct {
+	done chan struct{}
+	err  error
+}
+
+// NewNodeMonitor returns a new *NodeMonitor that waits up to
+// patience for a node to become available.
+func NewNodeMonitor(
+	network *types.NetworkIdentifier,
+	fetcher *fetcher.Fetcher,
+	patience time.Duration,
+) *NodeMonitor {
+	return &NodeMonitor{
+		network:      network,
+		fetcher:      fetcher,
+		patience:     patience,
+		pollInterval: nodePollInterval,
+	}
+}
+
+// Wait returns err if it was not caused by an unavailable node.
+// Otherwise, it blocks until the node is available again and
+// returns nil (indicating the caller should retry), or returns
+// an error if the node does not return within the configured
+// patience or returns with a different genesis block.
+//
+// If err was only caused by exhausted retries and the node is
+// immediately available, the node did not restart and err is
+// returned (otherwise, a request that always fails would be
+// retried forever).
+func (m *NodeMonitor) Wait(ctx context.Context, err error) error {
+	if !NodeUnavailable(err) {
+		return err
+	}
+
+	m.waitMutex.Lock()
+	w := m.current
+	if w == nil {
+		w = &nodeWait{done: make(chan struct{})}
+		m.current = w
+		go m.wait(ctx, err, w)
+	}
+	m.waitMutex.Unlock()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-w.done:
+		return w.err
+	}
+}
+
+// wait polls the node until it is available, the patience
+// is exhausted, or ctx is canceled and then closes w.done.
+func (m *NodeMonitor) wait(ctx context.Context, cause error, w *nodeWait) {
+	w.err = m.poll(ctx, cause)
+	if w.err == nil {
+		color.Green("node is available, resuming")
+	}
+
+	m.waitMutex.Lock()
+	m.current = nil
+	m.waitMutex.Unlock()
+
+	close(w.done)
+}
+
+// poll queries /network/status until the node is
+// available or the patience is exhausted.
+func (m *NodeMonitor) poll(ctx context.Context, cause error) error {
+	status, fetchErr := m.fetcher.NetworkStatus(ctx, m.network, nil)
+	if fetchErr == nil {
+		if !connectionError(cause) {
+			return cause
+		}
+
+		return m.checkGenesis(status)
+	}
+
+	color.Yellow(
+		"%s: node appears to be unavailable, waiting up to %s for it to return",
+		cause.Error(),
+		m.patience,
+	)
+
+	deadline := time.Now().Add(m.patience)
+	ticker := time.NewTicker(m.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		status, fetchErr = m.fetcher.NetworkStatus(ctx, m.network, nil)
+		if fetchErr == nil {
+			return m.checkGenesis(status)
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf(
+				"%w: not available after %s: %v",
+				ErrNodeUnavailable,
+				m.patience,
+				fetchErr.Err,
+			)
+		}
+	}
+}
+
+// checkGenesis ensures the genesis block of a node that has
+// returned matches the genesis block the asserter was
+// initialized with.
+func (m *NodeMonitor) checkGenesis(status *types.NetworkStatusResponse) error {
+	config, err := m.fetcher.Asserter.ClientConfiguration()
+	if err != nil {
+		return fmt.Errorf("%w: unable to get asserter configuration", err)
+	}
+
+	if types.Hash(config.GenesisBlockIdentifier) != types.Hash(status.GenesisBlockIdentifier) {
+		return fmt.Errorf(
+			"%w: expected %s but node returned %s",
+			ErrNodeGenesisChanged,
+			types.PrintStruct(config.GenesisBlockIdentifier),
+			types.PrintStruct(status.GenesisBlockIdentifier),
+		)
+	}
+
+	return nil
+}
@@ -0,0 +1,156 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/coinbase/rosetta-sdk-go/asserter"
+	"github.com/coinbase/rosetta-sdk-go/fetcher"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNodeUnavailable(t *testing.T) {
+	var tests = map[string]struct {
+		err         error
+		unavailable bool
+	}{
+		"nil": {},
+		"connection refused": {
+			err:         errors.New("dial tcp 127.0.0.1:8080: connect: connection refused"),
+			unavailable: true,
+		},
+		"exhausted retries": {
+			err:         fmt.Errorf("%w: block 10", fetcher.ErrExhaustedRetries),
+			unavailable: true,
+		},
+		"other error": {
+			err: errors.New("invalid block"),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.unavailable, NodeUnavailable(test.err))
+		})
+	}
+}
+
+func TestNodeMonitorWait(t *testing.T) {
+	network := &types.NetworkIdentifier{Blockchain: "blah", Network: "testnet"}
+	genesis := &types.BlockIdentifier{Hash: "block 0", Index: 0}
+	connectionErr := errors.New("connect: connection refused")
+	exhaustedErr := fmt.Errorf("%w: block 10", fetcher.ErrExhaustedRetries)
+
+	var tests = map[string]struct {
+		cause       error
+		failures    int
+		genesis     *types.BlockIdentifier
+		returnCause bool
+		expectedErr error
+	}{
+		"other error": {
+			cause:       errors.New("invalid block"),
+			genesis:     genesis,
+			returnCause: true,
+		},
+		"node returns": {
+			cause:    connectionErr,
+			failures: 2,
+			genesis:  genesis,
+		},
+		"node never returns": {
+			cause:       connectionErr,
+			failures:    1000,
+			genesis:     genesis,
+			expectedErr: ErrNodeUnavailable,
+		},
+		"node returns with new genesis": {
+			cause:       connectionErr,
+			failures:    1,
+			genesis:     &types.BlockIdentifier{Hash: "other block 0", Index: 0},
+			expectedErr: ErrNodeGenesisChanged,
+		},
+		"exhausted retries without restart": {
+			cause:       exhaustedErr,
+			genesis:     genesis,
+			returnCause: true,
+		},
+		"exhausted retries with restart": {
+			cause:    exhaustedErr,
+			failures: 1,
+			genesis:  genesis,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			requests := 0
+			server := httptest.NewServer(http.HandlerFunc(
+				func(w http.ResponseWriter, r *http.Request) {
+					requests++
+					if requests <= test.failures {
+						w.WriteHeader(http.StatusServiceUnavailable)
+						return
+					}
+
+					w.Header().Set("Content-Type", "application/json")
+					assert.NoError(t, json.NewEncoder(w).Encode(&types.NetworkStatusResponse{
+						CurrentBlockIdentifier: &types.BlockIdentifier{Hash: "block 10", Index: 10},
+						CurrentBlockTimestamp:  asserter.MinUnixEpoch + 1,
+						GenesisBlockIdentifier: test.genesis,
+						Peers:                  []*types.Peer{},
+					}))
+				},
+			))
+			defer server.Close()
+
+			a, err := asserter.NewClientWithOptions(
+				network,
+				genesis,
+				[]string{"TRANSFER"},
+				[]*types.OperationStatus{{Status: "SUCCESS", Successful: true}},
+				[]*types.Error{},
+				nil,
+			)
+			assert.NoError(t, err)
+
+			m := NewNodeMonitor(
+				network,
+				fetcher.New(server.URL, fetcher.WithAsserter(a), fetcher.WithMaxRetries(0)),
+				50*time.Millisecond,
+			)
+			m.pollInterval = 10 * time.Millisecond
+
+			err = m.Wait(context.Background(), test.cause)
+			switch {
+			case test.returnCause:
+				assert.Equal(t, test.cause, err)
+			case test.expectedErr != nil:
+				assert.True(t, errors.Is(err, test.expectedErr))
+			default:
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
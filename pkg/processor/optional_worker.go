@@ -0,0 +1,165 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"sync"
+
+	"github.com/coinbase/rosetta-cli/pkg/results"
+
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/fatih/color"
+)
+
+var _ storage.BlockWorker = (*OptionalWorker)(nil)
+
+// OptionalWorker implements the storage.BlockWorker interface
+// and wraps a storage.BlockWorker whose errors should not cause
+// syncing to fail. Errors returned by the wrapped worker (or by
+// its storage.CommitWorker) are logged and ignored. Once the
+// wrapped worker returns maxErrors consecutive errors, it is
+// disabled for the rest of the run.
+type OptionalWorker struct {
+	name      string
+	worker    storage.BlockWorker
+	maxErrors uint64
+
+	mutex             sync.Mutex
+	consecutiveErrors uint64
+	errors            uint64
+	lastError         error
+	disabledAt        *types.BlockIdentifier
+}
+
+// NewOptionalWorker returns a new *OptionalWorker.
+func NewOptionalWorker(
+	name string,
+	worker storage.BlockWorker,
+	maxErrors uint64,
+) *OptionalWorker {
+	return &OptionalWorker{
+		name:      name,
+		worker:    worker,
+		maxErrors: maxErrors,
+	}
+}
+
+func (w *OptionalWorker) disabled() bool {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	return w.disabledAt != nil
+}
+
+// record updates the error counts of the wrapped worker
+// after it processes block.
+func (w *OptionalWorker) record(block *types.BlockIdentifier, err error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if err == nil {
+		w.consecutiveErrors = 0
+		return
+	}
+
+	w.consecutiveErrors++
+	w.errors++
+	w.lastError = err
+	color.Yellow(
+		"%s: optional worker %s failed at block %d (%d consecutive errors)",
+		err.Error(),
+		w.name,
+		block.Index,
+		w.consecutiveErrors,
+	)
+
+	if w.disabledAt == nil && w.consecutiveErrors >= w.maxErrors {
+		w.disabledAt = block
+		color.Red(
+			"disabling optional worker %s after %d consecutive errors",
+			w.name,
+			w.consecutiveErrors,
+		)
+	}
+}
+
+// handle records the result of calling the wrapped
+// worker and wraps its storage.CommitWorker (if any).
+func (w *OptionalWorker) handle(
+	block *types.BlockIdentifier,
+	commitWorker storage.CommitWorker,
+	err error,
+) storage.CommitWorker {
+	if err != nil || commitWorker == nil {
+		w.record(block, err)
+		return nil
+	}
+
+	return func(ctx context.Context) error {
+		w.record(block, commitWorker(ctx))
+		return nil
+	}
+}
+
+// AddingBlock calls AddingBlock on the wrapped worker
+// (if it is not disabled).
+func (w *OptionalWorker) AddingBlock(
+	ctx context.Context,
+	block *types.Block,
+	transaction storage.DatabaseTransaction,
+) (storage.CommitWorker, error) {
+	if w.disabled() {
+		return nil, nil
+	}
+
+	commitWorker, err := w.worker.AddingBlock(ctx, block, transaction)
+	return w.handle(block.BlockIdentifier, commitWorker, err), nil
+}
+
+// RemovingBlock calls RemovingBlock on the wrapped worker
+// (if it is not disabled).
+func (w *OptionalWorker) RemovingBlock(
+	ctx context.Context,
+	block *types.Block,
+	transaction storage.DatabaseTransaction,
+) (storage.CommitWorker, error) {
+	if w.disabled() {
+		return nil, nil
+	}
+
+	commitWorker, err := w.worker.RemovingBlock(ctx, block, transaction)
+	return w.handle(block.BlockIdentifier, commitWorker, err), nil
+}
+
+// Status returns a *results.DegradedWorker describing the
+// errors returned by the wrapped worker or nil if it has
+// not returned any errors.
+func (w *OptionalWorker) Status() *results.DegradedWorker {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.errors == 0 {
+		return nil
+	}
+
+	return &results.DegradedWorker{
+		Name:       w.name,
+		Errors:     w.errors,
+		LastError:  w.lastError.Error(),
+		DisabledAt: w.disabledAt,
+	}
+}
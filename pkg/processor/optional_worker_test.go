@@ -0,0 +1,134 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/coinbase/rosetta-cli/pkg/results"
+
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+var _ storage.BlockWorker = (*flakyWorker)(nil)
+
+// flakyWorker returns the next error in errs
+// each time it processes a block.
+type flakyWorker struct {
+	errs   []error
+	commit bool
+	calls  int
+}
+
+func (w *flakyWorker) next() (storage.CommitWorker, error) {
+	err := w.errs[w.calls]
+	w.calls++
+
+	if w.commit {
+		return func(context.Context) error { return err }, nil
+	}
+
+	return nil, err
+}
+
+func (w *flakyWorker) AddingBlock(
+	ctx context.Context,
+	block *types.Block,
+	transaction storage.DatabaseTransaction,
+) (storage.CommitWorker, error) {
+	return w.next()
+}
+
+func (w *flakyWorker) RemovingBlock(
+	ctx context.Context,
+	block *types.Block,
+	transaction storage.DatabaseTransaction,
+) (storage.CommitWorker, error) {
+	return w.next()
+}
+
+func TestOptionalWorker(t *testing.T) {
+	errFailed := errors.New("export failed")
+
+	var tests = map[string]struct {
+		errs   []error
+		commit bool
+
+		expectedCalls  int
+		expectedStatus *results.DegradedWorker
+	}{
+		"no errors": {
+			errs:          []error{nil, nil, nil, nil},
+			expectedCalls: 4,
+		},
+		"intermittent errors": {
+			errs:          []error{errFailed, nil, errFailed, nil},
+			expectedCalls: 4,
+			expectedStatus: &results.DegradedWorker{
+				Name:      "exporter",
+				Errors:    2,
+				LastError: errFailed.Error(),
+			},
+		},
+		"consecutive errors": {
+			errs:          []error{nil, errFailed, errFailed, nil},
+			expectedCalls: 3,
+			expectedStatus: &results.DegradedWorker{
+				Name:       "exporter",
+				Errors:     2,
+				LastError:  errFailed.Error(),
+				DisabledAt: &types.BlockIdentifier{Hash: "block", Index: 2},
+			},
+		},
+		"consecutive commit errors": {
+			errs:          []error{nil, errFailed, errFailed, nil},
+			commit:        true,
+			expectedCalls: 3,
+			expectedStatus: &results.DegradedWorker{
+				Name:       "exporter",
+				Errors:     2,
+				LastError:  errFailed.Error(),
+				DisabledAt: &types.BlockIdentifier{Hash: "block", Index: 2},
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			flaky := &flakyWorker{errs: test.errs, commit: test.commit}
+			w := NewOptionalWorker("exporter", flaky, 2)
+
+			for i := range test.errs {
+				block := &types.Block{
+					BlockIdentifier: &types.BlockIdentifier{Hash: "block", Index: int64(i)},
+				}
+
+				commitWorker, err := w.AddingBlock(ctx, block, nil)
+				assert.NoError(t, err)
+				if commitWorker != nil {
+					assert.NoError(t, commitWorker(ctx))
+				}
+			}
+
+			assert.Equal(t, test.expectedCalls, flaky.calls)
+			assert.Equal(t, test.expectedStatus, w.Status())
+		})
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/coinbase/rosetta-cli/pkg/failures"
+	"github.com/coinbase/rosetta-cli/pkg/plugin"
+	"github.com/coinbase/rosetta-cli/pkg/results"
+
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+var _ storage.BlockWorker = (*PluginWorker)(nil)
+
+// PluginWorker implements the storage.BlockWorker interface
+// and sends every added and removed block to a plugin.
+type PluginWorker struct {
+	plugin         *plugin.Plugin
+	failureStorage *failures.Storage
+}
+
+// NewPluginWorker returns a new *PluginWorker.
+func NewPluginWorker(
+	plugin *plugin.Plugin,
+	failureStorage *failures.Storage,
+) *PluginWorker {
+	return &PluginWorker{
+		plugin:         plugin,
+		failureStorage: failureStorage,
+	}
+}
+
+// send sends block to the plugin and records
+// a failure if the plugin returns an error.
+func (w *PluginWorker) send(
+	ctx context.Context,
+	requestType string,
+	block *types.Block,
+) error {
+	err := w.plugin.Send(ctx, requestType, block)
+	if err == nil {
+		return nil
+	}
+
+	message := fmt.Sprintf(
+		"plugin %s failed to process %s for block %s:%d: %s",
+		w.plugin.Name(),
+		requestType,
+		block.BlockIdentifier.Hash,
+		block.BlockIdentifier.Index,
+		err.Error(),
+	)
+	log.Println(message)
+
+	w.failureStorage.Defer(&failures.Failure{
+		Kind:  failures.PluginFailure,
+		Block: block.BlockIdentifier,
+		Context: map[string]string{
+			"plugin":  w.plugin.Name(),
+			"request": requestType,
+		},
+		Message: message,
+	})
+
+	return fmt.Errorf("%w: %s", results.ErrPluginFailure, message)
+}
+
+// AddingBlock sends the added block to the plugin.
+func (w *PluginWorker) AddingBlock(
+	ctx context.Context,
+	block *types.Block,
+	transaction storage.DatabaseTransaction,
+) (storage.CommitWorker, error) {
+	return nil, w.send(ctx, plugin.AddingBlock, block)
+}
+
+// RemovingBlock sends the orphaned block to the plugin.
+func (w *PluginWorker) RemovingBlock(
+	ctx context.Context,
+	block *types.Block,
+	transaction storage.DatabaseTransaction,
+) (storage.CommitWorker, error) {
+	return nil, w.send(ctx, plugin.RemovingBlock, block)
+}
@@ -0,0 +1,187 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/coinbase/rosetta-cli/configuration"
+
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+var _ storage.BlockWorker = (*RebroadcastWorker)(nil)
+
+// RebroadcastWorker is a storage.BlockWorker that rebroadcasts
+// pending transactions that have not been seen on-chain according
+// to a configuration.RebroadcastBackoff.
+//
+// storage.BroadcastStorage only rebroadcasts a transaction after a
+// fixed number of blocks. When RebroadcastBackoff is populated,
+// BroadcastStorage is configured to broadcast each transaction once
+// (and to fail the broadcast at LostDepth) and this worker performs
+// all rebroadcasts. Resubmitting a signed transaction does not change
+// its identifier, so BroadcastStorage still confirms it.
+type RebroadcastWorker struct {
+	broadcastStorage *storage.BroadcastStorage
+	helper           *BroadcastStorageHelper
+	schedule         []int64
+
+	// rebroadcasts is the number of rebroadcasts performed
+	// of each pending broadcast (by identifier). It is not
+	// persisted, so a due rebroadcast is performed again
+	// after a restart (which is harmless).
+	rebroadcasts map[string]int
+	mutex        sync.Mutex
+}
+
+// NewRebroadcastWorker returns a new *RebroadcastWorker.
+func NewRebroadcastWorker(
+	config *configuration.RebroadcastBackoff,
+	broadcastStorage *storage.BroadcastStorage,
+	helper *BroadcastStorageHelper,
+) *RebroadcastWorker {
+	return &RebroadcastWorker{
+		broadcastStorage: broadcastStorage,
+		helper:           helper,
+		schedule:         config.Schedule(),
+		rebroadcasts:     map[string]int{},
+	}
+}
+
+// due returns the number of rebroadcasts that should have
+// occurred depth blocks after a transaction was broadcast.
+func (w *RebroadcastWorker) due(depth int64) int {
+	count := 0
+	for _, rebroadcastDepth := range w.schedule {
+		if depth < rebroadcastDepth {
+			break
+		}
+
+		count++
+	}
+
+	return count
+}
+
+// dueBroadcasts returns the broadcasts that should be
+// rebroadcast when the block at index is added.
+func (w *RebroadcastWorker) dueBroadcasts(
+	broadcasts []*storage.Broadcast,
+	index int64,
+) []*storage.Broadcast {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	pending := map[string]int{}
+	due := []*storage.Broadcast{}
+	for _, broadcast := range broadcasts {
+		// Broadcasts that have not been broadcast yet
+		// are broadcast by BroadcastStorage.
+		if broadcast.LastBroadcast == nil {
+			continue
+		}
+
+		dueCount := w.due(index - broadcast.LastBroadcast.Index)
+		pending[broadcast.Identifier] = w.rebroadcasts[broadcast.Identifier]
+		if dueCount > pending[broadcast.Identifier] {
+			pending[broadcast.Identifier] = dueCount
+			due = append(due, broadcast)
+		}
+	}
+
+	// Broadcasts that are no longer pending are forgotten.
+	w.rebroadcasts = pending
+
+	return due
+}
+
+// AddingBlock rebroadcasts all pending transactions that are
+// due for a rebroadcast and have not been seen on-chain once
+// the block is committed.
+func (w *RebroadcastWorker) AddingBlock(
+	ctx context.Context,
+	block *types.Block,
+	transaction storage.DatabaseTransaction,
+) (storage.CommitWorker, error) {
+	broadcasts, err := w.broadcastStorage.GetAllBroadcasts(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to get broadcasts", err)
+	}
+
+	rebroadcasts := []*storage.Broadcast{}
+	for _, broadcast := range w.dueBroadcasts(broadcasts, block.BlockIdentifier.Index) {
+		// Transactions that have been seen on-chain are
+		// waiting for confirmations.
+		foundBlock, _, err := w.helper.FindTransaction(
+			ctx,
+			broadcast.TransactionIdentifier,
+			transaction,
+		)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"%w: unable to find transaction %s",
+				err,
+				broadcast.TransactionIdentifier.Hash,
+			)
+		}
+
+		if foundBlock == nil {
+			rebroadcasts = append(rebroadcasts, broadcast)
+		}
+	}
+
+	if len(rebroadcasts) == 0 {
+		return nil, nil
+	}
+
+	return func(ctx context.Context) error {
+		for _, broadcast := range rebroadcasts {
+			log.Printf(
+				"rebroadcasting transaction %s (%s) at block %d\n",
+				broadcast.TransactionIdentifier.Hash,
+				broadcast.Identifier,
+				block.BlockIdentifier.Index,
+			)
+
+			// Rebroadcast failures are not fatal because the
+			// broadcast fails once the transaction is lost.
+			_, err := w.helper.BroadcastTransaction(
+				ctx,
+				broadcast.NetworkIdentifier,
+				broadcast.Payload,
+			)
+			if err != nil {
+				log.Printf("%s: unable to rebroadcast %s\n", err.Error(), broadcast.Identifier)
+			}
+		}
+
+		return nil
+	}, nil
+}
+
+// RemovingBlock is a no-op because rebroadcasts
+// are scheduled from the last broadcast.
+func (w *RebroadcastWorker) RemovingBlock(
+	ctx context.Context,
+	block *types.Block,
+	transaction storage.DatabaseTransaction,
+) (storage.CommitWorker, error) {
+	return nil, nil
+}
@@ -0,0 +1,84 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"testing"
+
+	"github.com/coinbase/rosetta-cli/configuration"
+
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func identifiers(broadcasts []*storage.Broadcast) []string {
+	ids := []string{}
+	for _, broadcast := range broadcasts {
+		ids = append(ids, broadcast.Identifier)
+	}
+
+	return ids
+}
+
+func TestRebroadcastWorkerDueBroadcasts(t *testing.T) {
+	w := NewRebroadcastWorker(
+		&configuration.RebroadcastBackoff{
+			InitialInterval: 2,
+			Multiplier:      2,
+			MaxRebroadcasts: 2,
+			LostDepth:       10,
+		},
+		nil,
+		nil,
+	)
+
+	broadcasts := []*storage.Broadcast{
+		{Identifier: "a", LastBroadcast: &types.BlockIdentifier{Index: 10}},
+		{Identifier: "b", LastBroadcast: &types.BlockIdentifier{Index: 11}},
+		{Identifier: "unsent"},
+	}
+
+	// Rebroadcasts are due 2 and 6 blocks
+	// after the broadcast.
+	var steps = []struct {
+		index    int64
+		expected []string
+	}{
+		{index: 11, expected: []string{}},
+		{index: 12, expected: []string{"a"}},
+		{index: 13, expected: []string{"b"}},
+		{index: 15, expected: []string{}},
+		{index: 16, expected: []string{"a"}},
+		{index: 20, expected: []string{"b"}},
+		{index: 30, expected: []string{}},
+	}
+
+	for _, step := range steps {
+		due := w.dueBroadcasts(broadcasts, step.index)
+		assert.Equal(t, step.expected, identifiers(due), step.index)
+	}
+
+	// Broadcasts that are no longer pending are forgotten
+	// (and a broadcast with the same identifier is
+	// rebroadcast on schedule).
+	assert.Len(t, w.dueBroadcasts([]*storage.Broadcast{}, 31), 0)
+	assert.Len(t, w.rebroadcasts, 0)
+
+	due := w.dueBroadcasts([]*storage.Broadcast{
+		{Identifier: "a", LastBroadcast: &types.BlockIdentifier{Index: 30}},
+	}, 32)
+	assert.Equal(t, []string{"a"}, identifiers(due))
+}
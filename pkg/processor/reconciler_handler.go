@@ -18,48 +18,292 @@ import (
 	"context"
 	"fmt"
 	"math/big"
+	"sync"
+	"time"
 
+	"github.com/coinbase/rosetta-cli/configuration"
+	"github.com/coinbase/rosetta-cli/pkg/failures"
 	"github.com/coinbase/rosetta-cli/pkg/logger"
 	"github.com/coinbase/rosetta-cli/pkg/results"
 
 	"github.com/coinbase/rosetta-sdk-go/reconciler"
 	"github.com/coinbase/rosetta-sdk-go/storage"
 	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/fatih/color"
 )
 
 var _ reconciler.Handler = (*ReconcilerHandler)(nil)
+var _ results.ReconciliationTracker = (*ReconcilerHandler)(nil)
+
+// maxRecentFailures is the maximum number of reconciliation
+// failures returned by RecentFailures.
+const maxRecentFailures = 100
+
+// BalanceReconciler records the last block an account was
+// reconciled at (implemented by *storage.BalanceStorage and
+// *BalanceCache).
+type BalanceReconciler interface {
+	Reconciled(
+		ctx context.Context,
+		account *types.AccountIdentifier,
+		currency *types.Currency,
+		block *types.BlockIdentifier,
+	) error
+}
 
 // ReconcilerHandler implements the Reconciler.Handler interface.
 type ReconcilerHandler struct {
 	logger                    *logger.Logger
 	counterStorage            *storage.CounterStorage
-	balanceStorage            *storage.BalanceStorage
+	balanceStorage            BalanceReconciler
+	failureStorage            *failures.Storage
+	interpolator              *BalanceInterpolator
+	dumper                    *ReconciliationDumper
+	tolerances                map[string]*big.Int
 	haltOnReconciliationError bool
+	failureBudget             uint64
 
 	InactiveFailure      *types.AccountCurrency
 	InactiveFailureBlock *types.BlockIdentifier
 
 	ActiveFailureBlock *types.BlockIdentifier
+
+	lastReconciliation  *results.ReconciliationStatus
+	recentFailures      []*results.ReconciliationStatus
+	budgetFailures      []*results.ReconciliationStatus
+	reconciliationMutex sync.Mutex
 }
 
-// NewReconcilerHandler creates a new ReconcilerHandler.
+// NewReconcilerHandler creates a new ReconcilerHandler. If
+// failureStorage is not nil, reconciliation failures are
+// persisted in it. If interpolator is not nil, balances
+// at heights between successful reconciliations of an
+// account are also checked. If dumper is not nil, the
+// context of each reconciliation failure is written to a
+// file. Differences within the tolerance of a currency in
+// tolerances are treated as drift instead of failures. If
+// haltOnReconciliationError is true and failureBudget is
+// non-zero, reconciliation only halts once failureBudget
+// failures have been collected.
 func NewReconcilerHandler(
 	logger *logger.Logger,
 	counterStorage *storage.CounterStorage,
-	balanceStorage *storage.BalanceStorage,
+	balanceStorage BalanceReconciler,
+	failureStorage *failures.Storage,
+	interpolator *BalanceInterpolator,
+	dumper *ReconciliationDumper,
+	tolerances []*configuration.ReconciliationTolerance,
 	haltOnReconciliationError bool,
+	failureBudget uint64,
 ) *ReconcilerHandler {
+	// Tolerances are validated when the
+	// configuration is loaded.
+	epsilons := map[string]*big.Int{}
+	for _, tolerance := range tolerances {
+		epsilon, ok := new(big.Int).SetString(tolerance.Epsilon, 10)
+		if !ok {
+			continue
+		}
+
+		epsilons[types.Hash(tolerance.Currency)] = epsilon
+	}
+
 	return &ReconcilerHandler{
 		logger:                    logger,
 		counterStorage:            counterStorage,
 		balanceStorage:            balanceStorage,
+		failureStorage:            failureStorage,
+		interpolator:              interpolator,
+		dumper:                    dumper,
+		tolerances:                epsilons,
 		haltOnReconciliationError: haltOnReconciliationError,
+		failureBudget:             failureBudget,
+	}
+}
+
+// Reconciliation results stored in LastReconciliation.
+const (
+	reconciliationSuccess = "success"
+	reconciliationFailure = "failure"
+	reconciliationExempt  = "exempt"
+	reconciliationDrift   = "drift"
+
+	// reconciliationSkipped is only written to
+	// the reconciliation stream.
+	reconciliationSkipped = "skipped"
+)
+
+// recordReconciliation stores reconciliation as the most recent
+// reconciliation and, if it failed, adds it to the recent failures
+// (discarding the oldest failure if there are more than
+// maxRecentFailures). Reconciliations are performed concurrently,
+// so this must be protected by a mutex. The reconciliation is
+// also written to the reconciliation stream.
+func (h *ReconcilerHandler) recordReconciliation(
+	ctx context.Context,
+	reconciliation *results.ReconciliationStatus,
+) error {
+	h.reconciliationMutex.Lock()
+	reconciliation.Timestamp = time.Now().Unix()
+	h.lastReconciliation = reconciliation
+	if reconciliation.Result == reconciliationFailure {
+		h.recentFailures = append(h.recentFailures, reconciliation)
+		if len(h.recentFailures) > maxRecentFailures {
+			h.recentFailures = h.recentFailures[1:]
+		}
+	}
+	h.reconciliationMutex.Unlock()
+
+	// The stream is written without holding the mutex
+	// because writes block while the stream queue is full.
+	if err := h.logger.ReconciliationStream(ctx, reconciliation); err != nil {
+		return fmt.Errorf("%w: unable to write reconciliation stream", err)
+	}
+
+	return nil
+}
+
+// LastReconciliation returns the most recent reconciliation
+// (or nil if no reconciliations have been performed).
+func (h *ReconcilerHandler) LastReconciliation() *results.ReconciliationStatus {
+	h.reconciliationMutex.Lock()
+	defer h.reconciliationMutex.Unlock()
+
+	return h.lastReconciliation
+}
+
+// RecentFailures returns up to maxRecentFailures of the
+// most recent reconciliation failures (oldest first).
+func (h *ReconcilerHandler) RecentFailures() []*results.ReconciliationStatus {
+	h.reconciliationMutex.Lock()
+	defer h.reconciliationMutex.Unlock()
+
+	failures := make([]*results.ReconciliationStatus, len(h.recentFailures))
+	copy(failures, h.recentFailures)
+	return failures
+}
+
+// spendFailureBudget adds reconciliation to the failures
+// collected towards the failure budget and returns a boolean
+// indicating if the budget is exhausted. If there is no
+// failure budget, it is exhausted by any failure.
+func (h *ReconcilerHandler) spendFailureBudget(
+	reconciliation *results.ReconciliationStatus,
+) bool {
+	if h.failureBudget == 0 {
+		return true
+	}
+
+	h.reconciliationMutex.Lock()
+	defer h.reconciliationMutex.Unlock()
+
+	h.budgetFailures = append(h.budgetFailures, reconciliation)
+	if uint64(len(h.budgetFailures)) < h.failureBudget {
+		color.Yellow(
+			"collected %d of %d reconciliation failures before halting",
+			len(h.budgetFailures),
+			h.failureBudget,
+		)
+		return false
+	}
+
+	color.Red("reconciliation failure budget of %d exhausted", h.failureBudget)
+	return true
+}
+
+// BudgetFailures returns all reconciliation failures
+// collected towards the failure budget (oldest first).
+func (h *ReconcilerHandler) BudgetFailures() []*results.ReconciliationStatus {
+	h.reconciliationMutex.Lock()
+	defer h.reconciliationMutex.Unlock()
+
+	failures := make([]*results.ReconciliationStatus, len(h.budgetFailures))
+	copy(failures, h.budgetFailures)
+	return failures
+}
+
+// drift returns the absolute difference between computedBalance
+// and liveBalance and a boolean indicating if it is within the
+// tolerance of currency (false if currency has no tolerance or
+// either balance cannot be parsed).
+func (h *ReconcilerHandler) drift(
+	currency *types.Currency,
+	computedBalance string,
+	liveBalance string,
+) (*big.Int, bool) {
+	epsilon, ok := h.tolerances[types.Hash(currency)]
+	if !ok {
+		return nil, false
+	}
+
+	computed, ok := new(big.Int).SetString(computedBalance, 10)
+	if !ok {
+		return nil, false
+	}
+
+	live, ok := new(big.Int).SetString(liveBalance, 10)
+	if !ok {
+		return nil, false
+	}
+
+	difference := new(big.Int).Abs(new(big.Int).Sub(computed, live))
+	return difference, difference.Cmp(epsilon) <= 0
+}
+
+// reconciliationDrifted is called instead of handling a failure
+// when the difference between computedBalance and liveBalance
+// is within the tolerance of currency.
+func (h *ReconcilerHandler) reconciliationDrifted(
+	ctx context.Context,
+	reconciliationType string,
+	account *types.AccountIdentifier,
+	currency *types.Currency,
+	computedBalance string,
+	liveBalance string,
+	block *types.BlockIdentifier,
+	drift *big.Int,
+) error {
+	_, _ = h.counterStorage.Update(ctx, results.DriftReconciliationCounter, big.NewInt(1))
+	_, _ = h.counterStorage.Update(ctx, results.ReconciliationDriftCounter(currency), drift)
+	if err := h.recordReconciliation(ctx, &results.ReconciliationStatus{
+		Type:            reconciliationType,
+		Result:          reconciliationDrift,
+		Account:         account,
+		Currency:        currency,
+		Block:           block,
+		ComputedBalance: computedBalance,
+		LiveBalance:     liveBalance,
+	}); err != nil {
+		return err
 	}
+
+	color.Yellow(
+		"%s reconciliation of %s drifted by %s%s at %d (computed: %s%s, live: %s%s)",
+		reconciliationType,
+		account.Address,
+		drift.String(),
+		currency.Symbol,
+		block.Index,
+		computedBalance,
+		currency.Symbol,
+		liveBalance,
+		currency.Symbol,
+	)
+
+	// Like exempt reconciliations, drifted reconciliations still
+	// count towards reconciliation coverage.
+	if err := h.balanceStorage.Reconciled(ctx, account, currency, block); err != nil {
+		return fmt.Errorf("%w: unable to store updated reconciliation", err)
+	}
+
+	return nil
 }
 
 // ReconciliationFailed is called each time a reconciliation fails.
-// In this Handler implementation, we halt if haltOnReconciliationError
-// was set to true. We also cancel the context.
+// Differences within the tolerance of the currency are handled as
+// drift. Otherwise, in this Handler implementation, we halt if
+// haltOnReconciliationError was set to true and the failure budget
+// is exhausted. We also cancel the context.
 func (h *ReconcilerHandler) ReconciliationFailed(
 	ctx context.Context,
 	reconciliationType string,
@@ -69,7 +313,74 @@ func (h *ReconcilerHandler) ReconciliationFailed(
 	liveBalance string,
 	block *types.BlockIdentifier,
 ) error {
+	if drift, ok := h.drift(currency, computedBalance, liveBalance); ok {
+		return h.reconciliationDrifted(
+			ctx,
+			reconciliationType,
+			account,
+			currency,
+			computedBalance,
+			liveBalance,
+			block,
+			drift,
+		)
+	}
+
 	_, _ = h.counterStorage.Update(ctx, storage.FailedReconciliationCounter, big.NewInt(1))
+	reconciliation := &results.ReconciliationStatus{
+		Type:            reconciliationType,
+		Result:          reconciliationFailure,
+		Account:         account,
+		Currency:        currency,
+		Block:           block,
+		ComputedBalance: computedBalance,
+		LiveBalance:     liveBalance,
+	}
+	if err := h.recordReconciliation(ctx, reconciliation); err != nil {
+		return err
+	}
+
+	failureContext := map[string]string{"reconciliation_type": reconciliationType}
+	if h.dumper != nil {
+		// A failure to write the dump should not
+		// hide the reconciliation failure itself.
+		dumpPath, err := h.dumper.Dump(
+			ctx,
+			reconciliationType,
+			account,
+			currency,
+			computedBalance,
+			liveBalance,
+			block,
+		)
+		if err != nil {
+			color.Yellow("%s: unable to dump reconciliation failure context", err.Error())
+		} else {
+			color.Yellow("reconciliation failure context written to %s", dumpPath)
+			failureContext["dump_file"] = dumpPath
+		}
+	}
+
+	if h.failureStorage != nil {
+		err := h.failureStorage.Record(ctx, &failures.Failure{
+			Kind:     failures.ReconciliationFailure,
+			Block:    block,
+			Account:  account,
+			Currency: currency,
+			Expected: liveBalance,
+			Actual:   computedBalance,
+			Context:  failureContext,
+			Message: fmt.Sprintf(
+				"%s reconciliation failed for %s at %d",
+				reconciliationType,
+				account.Address,
+				block.Index,
+			),
+		})
+		if err != nil {
+			return fmt.Errorf("%w: unable to record reconciliation failure", err)
+		}
+	}
 
 	err := h.logger.ReconcileFailureStream(
 		ctx,
@@ -84,7 +395,7 @@ func (h *ReconcilerHandler) ReconciliationFailed(
 		return err
 	}
 
-	if h.haltOnReconciliationError {
+	if h.haltOnReconciliationError && h.spendFailureBudget(reconciliation) {
 		if reconciliationType == reconciler.InactiveReconciliation {
 			// Populate inactive failure information so we can try to find block with
 			// missing ops.
@@ -135,6 +446,17 @@ func (h *ReconcilerHandler) ReconciliationExempt(
 	exemption *types.BalanceExemption,
 ) error {
 	_, _ = h.counterStorage.Update(ctx, storage.ExemptReconciliationCounter, big.NewInt(1))
+	if err := h.recordReconciliation(ctx, &results.ReconciliationStatus{
+		Type:            reconciliationType,
+		Result:          reconciliationExempt,
+		Account:         account,
+		Currency:        currency,
+		Block:           block,
+		ComputedBalance: computedBalance,
+		LiveBalance:     liveBalance,
+	}); err != nil {
+		return err
+	}
 
 	// Although the reconciliation was exempt (non-zero difference that was ignored),
 	// we still mark the account as being reconciled because the balance was in the range
@@ -156,10 +478,27 @@ func (h *ReconcilerHandler) ReconciliationSkipped(
 ) error {
 	_, _ = h.counterStorage.Update(ctx, storage.SkippedReconciliationsCounter, big.NewInt(1))
 
+	// Skipped reconciliations are not stored as the
+	// most recent reconciliation (no balance was checked).
+	err := h.logger.ReconciliationStream(ctx, &results.ReconciliationStatus{
+		Type:      reconciliationType,
+		Result:    reconciliationSkipped,
+		Account:   account,
+		Currency:  currency,
+		Timestamp: time.Now().Unix(),
+		Cause:     cause,
+	})
+	if err != nil {
+		return fmt.Errorf("%w: unable to write reconciliation stream", err)
+	}
+
 	return nil
 }
 
 // ReconciliationSucceeded is called each time a reconciliation succeeds.
+// If an interpolator is configured and the balance of the account is
+// incorrect at a height sampled since its last successful reconciliation,
+// the reconciliation is handled as an InterpolationReconciliation failure.
 func (h *ReconcilerHandler) ReconciliationSucceeded(
 	ctx context.Context,
 	reconciliationType string,
@@ -175,17 +514,62 @@ func (h *ReconcilerHandler) ReconciliationSucceeded(
 	}
 
 	_, _ = h.counterStorage.Update(ctx, counter, big.NewInt(1))
+	logger.Debugf(
+		configuration.ReconcilerLogModule,
+		"%s reconciliation of %s succeeded at %d (balance: %s%s)",
+		reconciliationType,
+		types.AccountString(account),
+		block.Index,
+		balance,
+		currency.Symbol,
+	)
+	if err := h.recordReconciliation(ctx, &results.ReconciliationStatus{
+		Type:            reconciliationType,
+		Result:          reconciliationSuccess,
+		Account:         account,
+		Currency:        currency,
+		Block:           block,
+		ComputedBalance: balance,
+		LiveBalance:     balance,
+	}); err != nil {
+		return err
+	}
 
 	if err := h.balanceStorage.Reconciled(ctx, account, currency, block); err != nil {
 		return fmt.Errorf("%w: unable to store updated reconciliation", err)
 	}
 
-	return h.logger.ReconcileSuccessStream(
+	if err := h.logger.ReconcileSuccessStream(
 		ctx,
 		reconciliationType,
 		account,
 		currency,
 		balance,
 		block,
+	); err != nil {
+		return err
+	}
+
+	if h.interpolator == nil {
+		return nil
+	}
+
+	mismatch, err := h.interpolator.Check(ctx, account, currency, block)
+	if err != nil {
+		return fmt.Errorf("%w: unable to check interpolated balances", err)
+	}
+
+	if mismatch == nil {
+		return nil
+	}
+
+	return h.ReconciliationFailed(
+		ctx,
+		InterpolationReconciliation,
+		account,
+		currency,
+		mismatch.ComputedBalance,
+		mismatch.LiveBalance,
+		mismatch.Block,
 	)
 }
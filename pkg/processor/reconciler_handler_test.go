@@ -0,0 +1,120 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/coinbase/rosetta-cli/configuration"
+	"github.com/coinbase/rosetta-cli/pkg/results"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpendFailureBudget(t *testing.T) {
+	failure := func(index int64) *results.ReconciliationStatus {
+		return &results.ReconciliationStatus{
+			Type:     "active",
+			Result:   reconciliationFailure,
+			Account:  &types.AccountIdentifier{Address: "addr"},
+			Currency: &types.Currency{Symbol: "BTC", Decimals: 8},
+			Block:    &types.BlockIdentifier{Hash: "block", Index: index},
+		}
+	}
+
+	t.Run("no budget", func(t *testing.T) {
+		h := NewReconcilerHandler(nil, nil, nil, nil, nil, nil, nil, true, 0)
+		assert.True(t, h.spendFailureBudget(failure(1)))
+		assert.Len(t, h.BudgetFailures(), 0)
+	})
+
+	t.Run("budget", func(t *testing.T) {
+		h := NewReconcilerHandler(nil, nil, nil, nil, nil, nil, nil, true, 3)
+		assert.False(t, h.spendFailureBudget(failure(1)))
+		assert.False(t, h.spendFailureBudget(failure(2)))
+		assert.True(t, h.spendFailureBudget(failure(3)))
+
+		collected := h.BudgetFailures()
+		assert.Len(t, collected, 3)
+		for i, f := range collected {
+			assert.Equal(t, int64(i+1), f.Block.Index)
+		}
+	})
+}
+
+func TestDrift(t *testing.T) {
+	btc := &types.Currency{Symbol: "BTC", Decimals: 8}
+	h := NewReconcilerHandler(
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+		[]*configuration.ReconciliationTolerance{{Currency: btc, Epsilon: "10"}},
+		true,
+		0,
+	)
+
+	var tests = map[string]struct {
+		currency *types.Currency
+		computed string
+		live     string
+
+		drift  *big.Int
+		within bool
+	}{
+		"within tolerance": {
+			currency: btc,
+			computed: "100",
+			live:     "105",
+			drift:    big.NewInt(5),
+			within:   true,
+		},
+		"at tolerance": {
+			currency: btc,
+			computed: "110",
+			live:     "100",
+			drift:    big.NewInt(10),
+			within:   true,
+		},
+		"outside tolerance": {
+			currency: btc,
+			computed: "100",
+			live:     "111",
+			drift:    big.NewInt(11),
+		},
+		"no tolerance": {
+			currency: &types.Currency{Symbol: "ETH", Decimals: 18},
+			computed: "100",
+			live:     "101",
+		},
+		"invalid balance": {
+			currency: btc,
+			computed: "100",
+			live:     "hello",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			drift, within := h.drift(test.currency, test.computed, test.live)
+			assert.Equal(t, test.drift, drift)
+			assert.Equal(t, test.within, within)
+		})
+	}
+}
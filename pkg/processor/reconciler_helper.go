@@ -18,6 +18,7 @@ import (
 	"context"
 
 	"github.com/coinbase/rosetta-cli/configuration"
+	"github.com/coinbase/rosetta-cli/pkg/control"
 
 	"github.com/coinbase/rosetta-sdk-go/fetcher"
 	"github.com/coinbase/rosetta-sdk-go/reconciler"
@@ -39,9 +40,22 @@ type ReconcilerHelper struct {
 	database       storage.Database
 	blockStorage   *storage.BlockStorage
 	balanceStorage *storage.BalanceStorage
+
+	nodeMonitor   *NodeMonitor
+	controller    *control.Controller
+	balanceCache  *BalanceCache
+	asserterGuard *AsserterGuard
 }
 
-// NewReconcilerHelper returns a new ReconcilerHelper.
+// NewReconcilerHelper returns a new ReconcilerHelper. If
+// nodeMonitor is not nil, live balance lookups that fail
+// because the node is unavailable are retried once the
+// node returns. If controller is not nil, live balance
+// lookups are not made while it is paused. If balanceCache
+// is not nil, computed balances are looked up in it (and
+// accounts are evicted from it when their balances are
+// pruned). Live balance lookups hold asserterGuard (if not
+// nil) so the asserter is not refreshed while it is used.
 func NewReconcilerHelper(
 	config *configuration.Configuration,
 	network *types.NetworkIdentifier,
@@ -49,6 +63,10 @@ func NewReconcilerHelper(
 	database storage.Database,
 	blockStorage *storage.BlockStorage,
 	balanceStorage *storage.BalanceStorage,
+	nodeMonitor *NodeMonitor,
+	controller *control.Controller,
+	balanceCache *BalanceCache,
+	asserterGuard *AsserterGuard,
 ) *ReconcilerHelper {
 	return &ReconcilerHelper{
 		config:         config,
@@ -57,6 +75,10 @@ func NewReconcilerHelper(
 		database:       database,
 		blockStorage:   blockStorage,
 		balanceStorage: balanceStorage,
+		nodeMonitor:    nodeMonitor,
+		controller:     controller,
+		balanceCache:   balanceCache,
+		asserterGuard:  asserterGuard,
 	}
 }
 
@@ -115,6 +137,10 @@ func (h *ReconcilerHelper) ComputedBalance(
 	currency *types.Currency,
 	index int64,
 ) (*types.Amount, error) {
+	if h.balanceCache != nil {
+		return h.balanceCache.GetBalanceTransactional(ctx, dbTx, account, currency, index)
+	}
+
 	return h.balanceStorage.GetBalanceTransactional(ctx, dbTx, account, currency, index)
 }
 
@@ -125,18 +151,35 @@ func (h *ReconcilerHelper) LiveBalance(
 	currency *types.Currency,
 	index int64,
 ) (*types.Amount, *types.BlockIdentifier, error) {
-	amt, block, err := utils.CurrencyBalance(
-		ctx,
-		h.network,
-		h.fetcher,
-		account,
-		currency,
-		index,
-	)
-	if err != nil {
-		return nil, nil, err
+	for {
+		if h.controller != nil {
+			if err := h.controller.Wait(ctx); err != nil {
+				return nil, nil, err
+			}
+		}
+
+		release := h.asserterGuard.Use()
+		amt, block, err := utils.CurrencyBalance(
+			ctx,
+			h.network,
+			h.fetcher,
+			account,
+			currency,
+			index,
+		)
+		release()
+		if err == nil {
+			return amt, block, nil
+		}
+
+		if h.nodeMonitor == nil {
+			return nil, nil, err
+		}
+
+		if err := h.nodeMonitor.Wait(ctx, err); err != nil {
+			return nil, nil, err
+		}
 	}
-	return amt, block, nil
 }
 
 // PruneBalances removes all historical balance states
@@ -153,6 +196,19 @@ func (h *ReconcilerHelper) PruneBalances(
 		return nil
 	}
 
+	// Historical balances are required to process reorgs,
+	// so we only prune balances at final blocks.
+	if h.config.Finality != nil {
+		index = h.config.Finality.FinalizedIndex(index)
+		if index < 0 {
+			return nil
+		}
+	}
+
+	if h.balanceCache != nil {
+		defer h.balanceCache.Pruned(account, currency, index)
+	}
+
 	return h.balanceStorage.PruneBalances(
 		ctx,
 		account,
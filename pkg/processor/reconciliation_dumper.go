@@ -0,0 +1,258 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+
+	"github.com/coinbase/rosetta-cli/pkg/history"
+
+	"github.com/coinbase/rosetta-sdk-go/fetcher"
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/coinbase/rosetta-sdk-go/utils"
+)
+
+// AccountBalanceFetcher is the subset of *fetcher.Fetcher
+// used to fetch live balances for a ReconciliationDump.
+type AccountBalanceFetcher interface {
+	AccountBalanceRetry(
+		ctx context.Context,
+		network *types.NetworkIdentifier,
+		account *types.AccountIdentifier,
+		block *types.PartialBlockIdentifier,
+		currencies []*types.Currency,
+	) (*types.BlockIdentifier, []*types.Amount, map[string]interface{}, *fetcher.Error)
+}
+
+// ComputedBalance is the balance of an account computed
+// by check:data at a block (or the error returned when
+// looking it up, if it was pruned for example).
+type ComputedBalance struct {
+	Block   *types.BlockIdentifier `json:"block_identifier"`
+	Balance string                 `json:"balance,omitempty"`
+	Error   string                 `json:"error,omitempty"`
+}
+
+// ReconciliationDump is the context of a reconciliation
+// failure written to a JSON file to simplify triage.
+type ReconciliationDump struct {
+	Type            string                   `json:"reconciliation_type"`
+	Account         *types.AccountIdentifier `json:"account_identifier"`
+	Currency        *types.Currency          `json:"currency"`
+	Block           *types.BlockIdentifier   `json:"block_identifier"`
+	ComputedBalance string                   `json:"computed_balance"`
+	LiveBalance     string                   `json:"live_balance"`
+
+	// LiveBalanceResponse is the /account/balance response
+	// at Block (re-fetched when the dump is created).
+	LiveBalanceResponse *types.AccountBalanceResponse `json:"live_balance_response,omitempty"`
+	LiveBalanceError    string                        `json:"live_balance_error,omitempty"`
+
+	// ComputedBalances are the computed balances of the
+	// account at each block in Blocks.
+	ComputedBalances []*ComputedBalance `json:"computed_balances"`
+
+	// Operations are all operations affecting the account
+	// in the blocks before (and including) Block. The running
+	// balance of each entry starts at 0 at the first block
+	// scanned.
+	Operations []*history.Entry `json:"operations"`
+
+	// Blocks are the identifiers of all blocks involved
+	// in the failure (Block, the block of the live balance,
+	// and all blocks in Operations) in ascending order.
+	Blocks []*types.BlockIdentifier `json:"blocks"`
+}
+
+// ReconciliationDumper writes a *ReconciliationDump to
+// a file in a directory for each reconciliation failure.
+type ReconciliationDumper struct {
+	network        *types.NetworkIdentifier
+	fetcher        AccountBalanceFetcher
+	successful     history.SuccessFunc
+	database       storage.Database
+	blockStorage   *storage.BlockStorage
+	balanceStorage *storage.BalanceStorage
+	directory      string
+
+	// blocks is the number of blocks (ending at
+	// the failure block) to scan for operations.
+	blocks int64
+}
+
+// NewReconciliationDumper returns a new *ReconciliationDumper
+// that scans the last blocks blocks for operations affecting
+// an account when a reconciliation fails.
+func NewReconciliationDumper(
+	network *types.NetworkIdentifier,
+	fetcher AccountBalanceFetcher,
+	successful history.SuccessFunc,
+	database storage.Database,
+	blockStorage *storage.BlockStorage,
+	balanceStorage *storage.BalanceStorage,
+	directory string,
+	blocks int64,
+) *ReconciliationDumper {
+	return &ReconciliationDumper{
+		network:        network,
+		fetcher:        fetcher,
+		successful:     successful,
+		database:       database,
+		blockStorage:   blockStorage,
+		balanceStorage: balanceStorage,
+		directory:      directory,
+		blocks:         blocks,
+	}
+}
+
+// liveBalance populates the live balance response
+// of dump (or the error returned while fetching it).
+func (d *ReconciliationDumper) liveBalance(ctx context.Context, dump *ReconciliationDump) {
+	block, amounts, metadata, fetchErr := d.fetcher.AccountBalanceRetry(
+		ctx,
+		d.network,
+		dump.Account,
+		&types.PartialBlockIdentifier{Index: &dump.Block.Index},
+		[]*types.Currency{dump.Currency},
+	)
+	if fetchErr != nil {
+		dump.LiveBalanceError = fetchErr.Err.Error()
+		return
+	}
+
+	dump.LiveBalanceResponse = &types.AccountBalanceResponse{
+		BlockIdentifier: block,
+		Balances:        amounts,
+		Metadata:        metadata,
+	}
+}
+
+// computedBalances populates the computed balances
+// of dump at each block in dump.Blocks.
+func (d *ReconciliationDumper) computedBalances(ctx context.Context, dump *ReconciliationDump) {
+	dbTx := d.database.NewDatabaseTransaction(ctx, false)
+	defer dbTx.Discard(ctx)
+
+	dump.ComputedBalances = make([]*ComputedBalance, len(dump.Blocks))
+	for i, block := range dump.Blocks {
+		computed := &ComputedBalance{Block: block}
+		amount, err := d.balanceStorage.GetBalanceTransactional(
+			ctx,
+			dbTx,
+			dump.Account,
+			dump.Currency,
+			block.Index,
+		)
+		if err != nil {
+			computed.Error = err.Error()
+		} else {
+			computed.Balance = amount.Value
+		}
+
+		dump.ComputedBalances[i] = computed
+	}
+}
+
+// involvedBlocks returns the unique identifiers of all
+// blocks in dump in ascending order of index.
+func involvedBlocks(dump *ReconciliationDump) []*types.BlockIdentifier {
+	candidates := []*types.BlockIdentifier{}
+	for _, entry := range dump.Operations {
+		candidates = append(candidates, entry.Block)
+	}
+
+	candidates = append(candidates, dump.Block)
+	if dump.LiveBalanceResponse != nil {
+		candidates = append(candidates, dump.LiveBalanceResponse.BlockIdentifier)
+	}
+
+	seen := map[string]struct{}{}
+	blocks := []*types.BlockIdentifier{}
+	for _, block := range candidates {
+		key := types.Hash(block)
+		if _, ok := seen[key]; ok {
+			continue
+		}
+
+		seen[key] = struct{}{}
+		blocks = append(blocks, block)
+	}
+
+	sort.SliceStable(blocks, func(i, j int) bool { return blocks[i].Index < blocks[j].Index })
+	return blocks
+}
+
+// Dump writes the context of a reconciliation failure
+// to a file and returns its path.
+func (d *ReconciliationDumper) Dump(
+	ctx context.Context,
+	reconciliationType string,
+	account *types.AccountIdentifier,
+	currency *types.Currency,
+	computedBalance string,
+	liveBalance string,
+	block *types.BlockIdentifier,
+) (string, error) {
+	dump := &ReconciliationDump{
+		Type:            reconciliationType,
+		Account:         account,
+		Currency:        currency,
+		Block:           block,
+		ComputedBalance: computedBalance,
+		LiveBalance:     liveBalance,
+	}
+
+	d.liveBalance(ctx, dump)
+
+	startIndex := block.Index - d.blocks + 1
+	if startIndex < 0 {
+		startIndex = 0
+	}
+
+	entries, err := history.AccountHistory(
+		ctx,
+		d.blockStorage,
+		d.successful,
+		account,
+		startIndex,
+		block.Index,
+	)
+	if err != nil {
+		return "", fmt.Errorf("%w: unable to find operations affecting %s", err, account.Address)
+	}
+
+	dump.Operations = entries
+	dump.Blocks = involvedBlocks(dump)
+	d.computedBalances(ctx, dump)
+
+	if err := utils.EnsurePathExists(d.directory); err != nil {
+		return "", fmt.Errorf("%w: unable to create dump directory", err)
+	}
+
+	filePath := path.Join(d.directory, fmt.Sprintf(
+		"%d_%s.json",
+		block.Index,
+		types.Hash(&types.AccountCurrency{Account: account, Currency: currency}),
+	))
+	if err := utils.SerializeAndWrite(filePath, dump); err != nil {
+		return "", fmt.Errorf("%w: unable to write reconciliation dump", err)
+	}
+
+	return filePath, nil
+}
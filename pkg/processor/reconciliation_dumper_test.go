@@ -0,0 +1,165 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/fetcher"
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/coinbase/rosetta-sdk-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockBalanceFetcher struct {
+	err error
+}
+
+func (f *mockBalanceFetcher) AccountBalanceRetry(
+	ctx context.Context,
+	network *types.NetworkIdentifier,
+	account *types.AccountIdentifier,
+	block *types.PartialBlockIdentifier,
+	currencies []*types.Currency,
+) (*types.BlockIdentifier, []*types.Amount, map[string]interface{}, *fetcher.Error) {
+	if f.err != nil {
+		return nil, nil, nil, &fetcher.Error{Err: f.err}
+	}
+
+	return &types.BlockIdentifier{Hash: fmt.Sprintf("block %d", *block.Index), Index: *block.Index},
+		[]*types.Amount{{Value: "25", Currency: currencies[0]}},
+		nil,
+		nil
+}
+
+func TestReconciliationDumper(t *testing.T) {
+	ctx := context.Background()
+
+	dir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(dir)
+
+	database, err := storage.NewBadgerStorage(ctx, path.Join(dir, "db"))
+	assert.NoError(t, err)
+	defer database.Close(ctx)
+
+	blockStorage := storage.NewBlockStorage(database)
+	blockStorage.Initialize([]storage.BlockWorker{})
+
+	account := &types.AccountIdentifier{Address: "addr1"}
+	currency := &types.Currency{Symbol: "BTC", Decimals: 8}
+	for i := int64(0); i < 4; i++ {
+		parent := i - 1
+		if parent < 0 {
+			parent = 0
+		}
+
+		assert.NoError(t, blockStorage.AddBlock(ctx, &types.Block{
+			BlockIdentifier: &types.BlockIdentifier{Hash: fmt.Sprintf("block %d", i), Index: i},
+			ParentBlockIdentifier: &types.BlockIdentifier{
+				Hash:  fmt.Sprintf("block %d", parent),
+				Index: parent,
+			},
+			Transactions: []*types.Transaction{
+				{
+					TransactionIdentifier: &types.TransactionIdentifier{
+						Hash: fmt.Sprintf("tx %d", i),
+					},
+					Operations: []*types.Operation{
+						{
+							OperationIdentifier: &types.OperationIdentifier{Index: 0},
+							Type:                "TRANSFER",
+							Status:              types.String("SUCCESS"),
+							Account:             account,
+							Amount:              &types.Amount{Value: "10", Currency: currency},
+						},
+					},
+				},
+			},
+		}))
+	}
+
+	successful := func(*types.Operation) (bool, error) { return true, nil }
+	failureBlock := &types.BlockIdentifier{Hash: "block 3", Index: 3}
+
+	var tests = map[string]struct {
+		fetcher *mockBalanceFetcher
+
+		liveBalanceError string
+	}{
+		"live balance": {
+			fetcher: &mockBalanceFetcher{},
+		},
+		"live balance error": {
+			fetcher:          &mockBalanceFetcher{err: errors.New("node unavailable")},
+			liveBalanceError: "node unavailable",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			dumpDir := path.Join(dir, name)
+			dumper := NewReconciliationDumper(
+				&types.NetworkIdentifier{Blockchain: "Bitcoin", Network: "Testnet3"},
+				test.fetcher,
+				successful,
+				database,
+				blockStorage,
+				storage.NewBalanceStorage(database),
+				dumpDir,
+				2,
+			)
+
+			filePath, err := dumper.Dump(
+				ctx,
+				"ACTIVE",
+				account,
+				currency,
+				"40",
+				"25",
+				failureBlock,
+			)
+			assert.NoError(t, err)
+			assert.Equal(t, dumpDir, path.Dir(filePath))
+
+			var dump ReconciliationDump
+			assert.NoError(t, utils.LoadAndParse(filePath, &dump))
+			assert.Equal(t, "ACTIVE", dump.Type)
+			assert.Equal(t, failureBlock, dump.Block)
+			assert.Equal(t, "40", dump.ComputedBalance)
+			assert.Equal(t, "25", dump.LiveBalance)
+			assert.Equal(t, test.liveBalanceError, dump.LiveBalanceError)
+			if len(test.liveBalanceError) == 0 {
+				assert.Equal(t, "25", dump.LiveBalanceResponse.Balances[0].Value)
+			}
+
+			// Only the last 2 blocks are scanned.
+			assert.Len(t, dump.Operations, 2)
+			assert.Equal(t, "tx 2", dump.Operations[0].Transaction.Hash)
+			assert.Equal(t, "tx 3", dump.Operations[1].Transaction.Hash)
+
+			assert.Equal(t, []*types.BlockIdentifier{
+				{Hash: "block 2", Index: 2},
+				failureBlock,
+			}, dump.Blocks)
+			assert.Len(t, dump.ComputedBalances, len(dump.Blocks))
+		})
+	}
+}
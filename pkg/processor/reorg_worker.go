@@ -0,0 +1,158 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/coinbase/rosetta-cli/configuration"
+	"github.com/coinbase/rosetta-cli/pkg/failures"
+	"github.com/coinbase/rosetta-cli/pkg/results"
+
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/fatih/color"
+)
+
+var _ storage.BlockWorker = (*ReorgWorker)(nil)
+
+// ReorgWorker implements the storage.BlockWorker interface
+// and alerts when the syncer processes a reorg deeper than
+// the configured maximum depth or when the rate of orphaned
+// blocks exceeds the configured maximum rate. Deep reorgs and
+// frequent orphans during validation usually indicate that the
+// node is misconfigured.
+//
+// The syncer adds and removes blocks sequentially, so
+// the depth of a reorg is the number of blocks removed
+// before the next block is added.
+type ReorgWorker struct {
+	config         *configuration.ReorgAlerts
+	failureStorage *failures.Storage
+
+	// depth is the number of blocks removed
+	// since the last block was added.
+	depth int64
+
+	// windowBlocks and windowOrphans are the number of
+	// blocks added and removed in the current orphan
+	// rate window.
+	windowBlocks  uint64
+	windowOrphans uint64
+}
+
+// NewReorgWorker returns a new *ReorgWorker.
+func NewReorgWorker(
+	config *configuration.ReorgAlerts,
+	failureStorage *failures.Storage,
+) *ReorgWorker {
+	return &ReorgWorker{
+		config:         config,
+		failureStorage: failureStorage,
+	}
+}
+
+// AddingBlock ends any reorg in progress and alerts if the
+// orphan rate of the window ending at block is too high.
+func (w *ReorgWorker) AddingBlock(
+	ctx context.Context,
+	block *types.Block,
+	transaction storage.DatabaseTransaction,
+) (storage.CommitWorker, error) {
+	windowBlocks := w.windowBlocks + 1
+	windowOrphans := w.windowOrphans
+	if w.config.OrphanRateWindow > 0 && windowBlocks >= w.config.OrphanRateWindow {
+		rate := float64(windowOrphans) / float64(windowBlocks)
+		if w.config.MaxOrphanRate > 0 && rate > w.config.MaxOrphanRate {
+			message := fmt.Sprintf(
+				"orphan rate %f over the last %d blocks exceeds max orphan rate %f",
+				rate,
+				windowBlocks,
+				w.config.MaxOrphanRate,
+			)
+			color.Red("ALERT: %s (at block %d)", message, block.BlockIdentifier.Index)
+			err := w.failureStorage.RecordInTransaction(ctx, transaction, &failures.Failure{
+				Kind:     failures.OrphanRateFailure,
+				Block:    block.BlockIdentifier,
+				Expected: strconv.FormatFloat(w.config.MaxOrphanRate, 'f', -1, 64),
+				Actual:   strconv.FormatFloat(rate, 'f', -1, 64),
+				Message:  message,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("%w: unable to record orphan rate failure", err)
+			}
+		}
+
+		windowBlocks = 0
+		windowOrphans = 0
+	}
+
+	return func(ctx context.Context) error {
+		w.depth = 0
+		w.windowBlocks = windowBlocks
+		w.windowOrphans = windowOrphans
+		return nil
+	}, nil
+}
+
+// RemovingBlock alerts (and returns an error if
+// HaltOnDeepReorg is set) if removing block makes
+// the current reorg deeper than MaxDepth.
+func (w *ReorgWorker) RemovingBlock(
+	ctx context.Context,
+	block *types.Block,
+	transaction storage.DatabaseTransaction,
+) (storage.CommitWorker, error) {
+	depth := w.depth + 1
+	if w.config.MaxDepth > 0 && depth == w.config.MaxDepth+1 {
+		message := fmt.Sprintf(
+			"reorg depth %d exceeds max depth %d",
+			depth,
+			w.config.MaxDepth,
+		)
+		color.Red("CRITICAL: %s (removing block %d)", message, block.BlockIdentifier.Index)
+		failure := &failures.Failure{
+			Kind:     failures.DeepReorgFailure,
+			Block:    block.BlockIdentifier,
+			Expected: strconv.FormatInt(w.config.MaxDepth, 10),
+			Actual:   strconv.FormatInt(depth, 10),
+			Message:  message,
+		}
+
+		if w.config.HaltOnDeepReorg {
+			// The block transaction is discarded when we return
+			// an error, so the failure must be deferred.
+			w.failureStorage.Defer(failure)
+			return nil, fmt.Errorf(
+				"%w: removing block %s:%d",
+				results.ErrDeepReorg,
+				block.BlockIdentifier.Hash,
+				block.BlockIdentifier.Index,
+			)
+		}
+
+		if err := w.failureStorage.RecordInTransaction(ctx, transaction, failure); err != nil {
+			return nil, fmt.Errorf("%w: unable to record deep reorg failure", err)
+		}
+	}
+
+	return func(ctx context.Context) error {
+		w.depth = depth
+		w.windowOrphans++
+		return nil
+	}, nil
+}
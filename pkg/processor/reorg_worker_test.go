@@ -0,0 +1,177 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/coinbase/rosetta-cli/configuration"
+	"github.com/coinbase/rosetta-cli/pkg/failures"
+	"github.com/coinbase/rosetta-cli/pkg/results"
+
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/coinbase/rosetta-sdk-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReorgWorker(t *testing.T) {
+	ctx := context.Background()
+	dir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(dir)
+
+	localStore, err := storage.NewBadgerStorage(
+		ctx,
+		dir,
+		storage.WithIndexCacheSize(storage.TinyIndexCacheSize),
+	)
+	assert.NoError(t, err)
+	defer localStore.Close(ctx)
+
+	// addBlock and removeBlock apply the commit worker
+	// and commit the block transaction (as if the block
+	// was committed).
+	addBlock := func(w *ReorgWorker, index int64) {
+		dbTx := localStore.NewDatabaseTransaction(ctx, true)
+		defer dbTx.Discard(ctx)
+
+		commitWorker, err := w.AddingBlock(ctx, creationTestBlock(index), dbTx)
+		assert.NoError(t, err)
+		assert.NoError(t, dbTx.Commit(ctx))
+		assert.NoError(t, commitWorker(ctx))
+	}
+	removeBlock := func(w *ReorgWorker, index int64) error {
+		dbTx := localStore.NewDatabaseTransaction(ctx, true)
+		defer dbTx.Discard(ctx)
+
+		commitWorker, err := w.RemovingBlock(ctx, creationTestBlock(index), dbTx)
+		if err != nil {
+			return err
+		}
+
+		if err := dbTx.Commit(ctx); err != nil {
+			return err
+		}
+
+		return commitWorker(ctx)
+	}
+
+	// recorded returns the kinds of all
+	// persisted failures.
+	failureStorage := failures.NewStorage(localStore)
+	recorded := func() []failures.Kind {
+		all, err := failureStorage.GetAll(ctx)
+		assert.NoError(t, err)
+
+		kinds := []failures.Kind{}
+		for _, failure := range all {
+			kinds = append(kinds, failure.Kind)
+		}
+
+		return kinds
+	}
+
+	t.Run("reorg within max depth", func(t *testing.T) {
+		w := NewReorgWorker(
+			&configuration.ReorgAlerts{MaxDepth: 2, HaltOnDeepReorg: true},
+			failureStorage,
+		)
+		for i := int64(0); i < 5; i++ {
+			addBlock(w, i)
+		}
+
+		assert.NoError(t, removeBlock(w, 4))
+		assert.NoError(t, removeBlock(w, 3))
+		addBlock(w, 3)
+
+		// The depth is reset once a block is added.
+		assert.NoError(t, removeBlock(w, 3))
+		assert.NoError(t, removeBlock(w, 2))
+		assert.Equal(t, int64(2), w.depth)
+		assert.Equal(t, []failures.Kind{}, recorded())
+	})
+
+	t.Run("deep reorg", func(t *testing.T) {
+		w := NewReorgWorker(
+			&configuration.ReorgAlerts{MaxDepth: 1, HaltOnDeepReorg: true},
+			failureStorage,
+		)
+		addBlock(w, 0)
+		addBlock(w, 1)
+		addBlock(w, 2)
+
+		assert.NoError(t, removeBlock(w, 2))
+		err := removeBlock(w, 1)
+		assert.True(t, errors.Is(err, results.ErrDeepReorg))
+
+		// The depth is not updated if removal fails.
+		assert.Equal(t, int64(1), w.depth)
+
+		// The failure is deferred because the block
+		// transaction is discarded.
+		assert.Equal(t, []failures.Kind{}, recorded())
+		assert.NoError(t, failureStorage.Flush(ctx))
+		assert.Equal(t, []failures.Kind{failures.DeepReorgFailure}, recorded())
+	})
+
+	t.Run("deep reorg without halt", func(t *testing.T) {
+		w := NewReorgWorker(
+			&configuration.ReorgAlerts{MaxDepth: 1},
+			failureStorage,
+		)
+		addBlock(w, 0)
+		addBlock(w, 1)
+		addBlock(w, 2)
+
+		assert.NoError(t, removeBlock(w, 2))
+		assert.NoError(t, removeBlock(w, 1))
+		assert.NoError(t, removeBlock(w, 0))
+		assert.Equal(t, int64(3), w.depth)
+
+		// The failure is persisted with the block
+		// transaction (without being flushed).
+		assert.Equal(t, []failures.Kind{
+			failures.DeepReorgFailure,
+			failures.DeepReorgFailure,
+		}, recorded())
+	})
+
+	t.Run("orphan rate", func(t *testing.T) {
+		w := NewReorgWorker(
+			&configuration.ReorgAlerts{MaxOrphanRate: 0.2, OrphanRateWindow: 5},
+			failureStorage,
+		)
+		addBlock(w, 0)
+		addBlock(w, 1)
+		assert.NoError(t, removeBlock(w, 1))
+		assert.NoError(t, removeBlock(w, 0))
+		addBlock(w, 0)
+		addBlock(w, 1)
+		assert.Equal(t, uint64(4), w.windowBlocks)
+		assert.Equal(t, uint64(2), w.windowOrphans)
+
+		// The window is reset once it is full.
+		addBlock(w, 2)
+		assert.Equal(t, uint64(0), w.windowBlocks)
+		assert.Equal(t, uint64(0), w.windowOrphans)
+		assert.Equal(t, []failures.Kind{
+			failures.DeepReorgFailure,
+			failures.DeepReorgFailure,
+			failures.OrphanRateFailure,
+		}, recorded())
+	})
+}
@@ -0,0 +1,176 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/coinbase/rosetta-sdk-go/keys"
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+// seededKeysNextKey is the key of the index of the
+// next seeded key pair that has not been stored.
+const seededKeysNextKey = "seeded_keys/next"
+
+// DeriveKeyPair returns the key pair at index derived from
+// seed for curve. The private key is the HMAC-SHA256 (keyed
+// by seed) of the curve and index.
+func DeriveKeyPair(seed string, curve types.CurveType, index int64) (*keys.KeyPair, error) {
+	mac := hmac.New(sha256.New, []byte(seed))
+	_, _ = mac.Write([]byte(fmt.Sprintf("%s/%d", curve, index)))
+
+	keyPair, err := keys.ImportPrivateKey(hex.EncodeToString(mac.Sum(nil)), curve)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to derive %s key pair %d", err, curve, index)
+	}
+
+	return keyPair, nil
+}
+
+// seededKeyPair is a seeded key pair and its index.
+type seededKeyPair struct {
+	keyPair *keys.KeyPair
+	index   int64
+}
+
+// SeededKeys replaces the key pairs generated by check:construction
+// with key pairs deterministically derived from a seed.
+//
+// Key pairs are generated by the generate_key action of the
+// coordinator (which cannot be overridden), so each generated key pair
+// is replaced by the next seeded key pair (of the same curve) the first
+// time its public key is derived or stored. Accounts are then derived
+// from (and signed for with) seeded key pairs only. Replaced key pairs
+// are only held in memory because generate_key, derive, and
+// save_account are executed in a single scenario.
+type SeededKeys struct {
+	seed string
+
+	next     int64
+	replaced map[string]*seededKeyPair
+	mutex    sync.Mutex
+}
+
+// NewSeededKeys returns a new *SeededKeys that derives
+// key pairs after the last key pair stored in db.
+func NewSeededKeys(ctx context.Context, db storage.Database, seed string) (*SeededKeys, error) {
+	dbTx := db.NewDatabaseTransaction(ctx, false)
+	defer dbTx.Discard(ctx)
+
+	next, err := getNextSeededKey(ctx, dbTx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SeededKeys{
+		seed:     seed,
+		next:     next,
+		replaced: map[string]*seededKeyPair{},
+	}, nil
+}
+
+func getNextSeededKey(ctx context.Context, dbTx storage.DatabaseTransaction) (int64, error) {
+	exists, val, err := dbTx.Get(ctx, []byte(seededKeysNextKey))
+	if err != nil {
+		return -1, fmt.Errorf("%w: unable to get next seeded key", err)
+	}
+
+	if !exists {
+		return 0, nil
+	}
+
+	next, err := strconv.ParseInt(string(val), 10, 64)
+	if err != nil {
+		return -1, fmt.Errorf("%w: unable to parse next seeded key", err)
+	}
+
+	return next, nil
+}
+
+// replace returns the seeded key pair that
+// replaces the generated publicKey.
+func (s *SeededKeys) replace(publicKey *types.PublicKey) (*seededKeyPair, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	generated := hex.EncodeToString(publicKey.Bytes)
+	if seeded, ok := s.replaced[generated]; ok {
+		return seeded, nil
+	}
+
+	keyPair, err := DeriveKeyPair(s.seed, publicKey.CurveType, s.next)
+	if err != nil {
+		return nil, err
+	}
+
+	seeded := &seededKeyPair{keyPair: keyPair, index: s.next}
+	s.replaced[generated] = seeded
+	s.next++
+
+	return seeded, nil
+}
+
+// PublicKey returns the public key of the seeded
+// key pair that replaces the generated publicKey.
+func (s *SeededKeys) PublicKey(publicKey *types.PublicKey) (*types.PublicKey, error) {
+	seeded, err := s.replace(publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return seeded.keyPair.PublicKey, nil
+}
+
+// StoreTransactional returns the seeded key pair that replaces the
+// generated keyPair and records that it was stored (so it is never
+// derived again when check:construction is restarted).
+func (s *SeededKeys) StoreTransactional(
+	ctx context.Context,
+	dbTx storage.DatabaseTransaction,
+	keyPair *keys.KeyPair,
+) (*keys.KeyPair, error) {
+	seeded, err := s.replace(keyPair.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	next, err := getNextSeededKey(ctx, dbTx)
+	if err != nil {
+		return nil, err
+	}
+
+	if seeded.index < next {
+		return seeded.keyPair, nil
+	}
+
+	if err := dbTx.Set(
+		ctx,
+		[]byte(seededKeysNextKey),
+		[]byte(strconv.FormatInt(seeded.index+1, 10)),
+		true,
+	); err != nil {
+		return nil, fmt.Errorf("%w: unable to store next seeded key", err)
+	}
+
+	return seeded.keyPair, nil
+}
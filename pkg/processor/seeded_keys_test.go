@@ -0,0 +1,108 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/keys"
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/coinbase/rosetta-sdk-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeriveKeyPair(t *testing.T) {
+	for _, curve := range []types.CurveType{types.Secp256k1, types.Edwards25519} {
+		keyPair, err := DeriveKeyPair("seed", curve, 0)
+		assert.NoError(t, err)
+		assert.NoError(t, keyPair.IsValid())
+
+		again, err := DeriveKeyPair("seed", curve, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, keyPair, again)
+
+		next, err := DeriveKeyPair("seed", curve, 1)
+		assert.NoError(t, err)
+		assert.NotEqual(t, keyPair.PrivateKey, next.PrivateKey)
+
+		other, err := DeriveKeyPair("other seed", curve, 0)
+		assert.NoError(t, err)
+		assert.NotEqual(t, keyPair.PrivateKey, other.PrivateKey)
+	}
+}
+
+func TestSeededKeys(t *testing.T) {
+	ctx := context.Background()
+	dir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(dir)
+
+	localStore, err := storage.NewBadgerStorage(
+		ctx,
+		dir,
+		storage.WithIndexCacheSize(storage.TinyIndexCacheSize),
+	)
+	assert.NoError(t, err)
+	defer localStore.Close(ctx)
+
+	s, err := NewSeededKeys(ctx, localStore, "seed")
+	assert.NoError(t, err)
+
+	generate := func() *keys.KeyPair {
+		keyPair, err := keys.GenerateKeypair(types.Secp256k1)
+		assert.NoError(t, err)
+		return keyPair
+	}
+
+	store := func(s *SeededKeys, keyPair *keys.KeyPair) *keys.KeyPair {
+		dbTx := localStore.NewDatabaseTransaction(ctx, true)
+		defer dbTx.Discard(ctx)
+
+		seeded, err := s.StoreTransactional(ctx, dbTx, keyPair)
+		assert.NoError(t, err)
+		assert.NoError(t, dbTx.Commit(ctx))
+
+		return seeded
+	}
+
+	seeded := func(index int64) *keys.KeyPair {
+		keyPair, err := DeriveKeyPair("seed", types.Secp256k1, index)
+		assert.NoError(t, err)
+		return keyPair
+	}
+
+	// A generated key pair is replaced by the same seeded
+	// key pair when it is derived and stored.
+	generated := generate()
+	publicKey, err := s.PublicKey(generated.PublicKey)
+	assert.NoError(t, err)
+	assert.Equal(t, seeded(0).PublicKey, publicKey)
+	assert.Equal(t, seeded(0), store(s, generated))
+
+	// Key pairs that are derived but never stored are
+	// not derived again in this run.
+	publicKey, err = s.PublicKey(generate().PublicKey)
+	assert.NoError(t, err)
+	assert.Equal(t, seeded(1).PublicKey, publicKey)
+	assert.Equal(t, seeded(2), store(s, generate()))
+
+	// After a restart, derivation resumes after
+	// the last stored key pair.
+	restarted, err := NewSeededKeys(ctx, localStore, "seed")
+	assert.NoError(t, err)
+	assert.Equal(t, seeded(3), store(restarted, generate()))
+}
@@ -0,0 +1,366 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/coinbase/rosetta-cli/configuration"
+	"github.com/coinbase/rosetta-cli/pkg/results"
+
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/fatih/color"
+	"github.com/olekukonko/tablewriter"
+	"github.com/tidwall/gjson"
+)
+
+// SequenceTracker tracks transactions sent concurrently from
+// the same account by the workflows configured in
+// configuration.ConcurrentSends. It keeps accounts with pending
+// concurrent sends unlocked (until MaxPending sends are pending),
+// rejects transactions built with the nonce of a pending
+// transaction from the same account, and validates that
+// transactions from the same account confirm in the order
+// they were broadcast.
+//
+// Like the FeeEstimator, the SequenceTracker relies on the
+// coordinator processing a single job at a time (so the last
+// nonce returned by /construction/metadata before a broadcast is
+// the nonce of the broadcast transaction). Sends are not persisted,
+// so accounts with broadcasts pending before a restart stay locked
+// until they are confirmed.
+type SequenceTracker struct {
+	config    *configuration.ConcurrentSends
+	workflows map[string]struct{}
+
+	mutex    sync.Mutex
+	nonce    string
+	sequence int64
+	sends    map[string][]*sequencedSend
+	accounts map[string]*accountSends
+
+	sent       int
+	confirmed  int
+	failed     int
+	maxPending int
+}
+
+// sequencedSend is a transaction sent
+// concurrently from an account.
+type sequencedSend struct {
+	identifier string
+	account    string
+	sequence   int64
+	nonce      string
+	block      *types.BlockIdentifier
+}
+
+// accountSends are the pending sends of an account and
+// the sends confirmed since the account last had no
+// pending sends.
+type accountSends struct {
+	pending   map[string]*sequencedSend
+	confirmed []*sequencedSend
+}
+
+// NewSequenceTracker returns a new *SequenceTracker.
+func NewSequenceTracker(config *configuration.ConcurrentSends) *SequenceTracker {
+	workflows := map[string]struct{}{}
+	for _, workflow := range config.Workflows {
+		workflows[workflow] = struct{}{}
+	}
+
+	return &SequenceTracker{
+		config:    config,
+		workflows: workflows,
+		sends:     map[string][]*sequencedSend{},
+		accounts:  map[string]*accountSends{},
+	}
+}
+
+// Metadata records the nonce in the metadata
+// returned by /construction/metadata.
+func (t *SequenceTracker) Metadata(metadata map[string]interface{}) {
+	if len(t.config.NonceKey) == 0 {
+		return
+	}
+
+	nonce := ""
+	encoded, err := json.Marshal(metadata)
+	if err == nil {
+		if result := gjson.GetBytes(encoded, t.config.NonceKey); result.Exists() {
+			nonce = result.String()
+		}
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.nonce = nonce
+}
+
+// senders returns the hashes of the accounts
+// debited by intent (without duplicates).
+func senders(intent []*types.Operation) []string {
+	seen := map[string]struct{}{}
+	accounts := []string{}
+	for _, op := range intent {
+		if op.Account == nil || op.Amount == nil || !strings.HasPrefix(op.Amount.Value, "-") {
+			continue
+		}
+
+		key := types.Hash(op.Account)
+		if _, ok := seen[key]; ok {
+			continue
+		}
+
+		seen[key] = struct{}{}
+		accounts = append(accounts, key)
+	}
+
+	return accounts
+}
+
+// Broadcast records the transaction broadcast by the job with
+// identifier (if workflow sends concurrently). It returns an
+// error if the transaction was built with the nonce of a
+// pending transaction from the same account.
+func (t *SequenceTracker) Broadcast(
+	identifier string,
+	workflow string,
+	intent []*types.Operation,
+) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	nonce := t.nonce
+	t.nonce = ""
+	if _, ok := t.workflows[workflow]; !ok {
+		return nil
+	}
+
+	accounts := senders(intent)
+	for _, account := range accounts {
+		sends, ok := t.accounts[account]
+		if !ok || len(nonce) == 0 {
+			continue
+		}
+
+		for _, send := range sends.pending {
+			if send.nonce == nonce {
+				return fmt.Errorf(
+					"%w: job %s built a transaction with nonce %s of pending job %s",
+					results.ErrNonceReuse,
+					identifier,
+					nonce,
+					send.identifier,
+				)
+			}
+		}
+	}
+
+	t.sequence++
+	t.sent++
+	for _, account := range accounts {
+		sends, ok := t.accounts[account]
+		if !ok {
+			sends = &accountSends{pending: map[string]*sequencedSend{}}
+			t.accounts[account] = sends
+		}
+
+		send := &sequencedSend{
+			identifier: identifier,
+			account:    account,
+			sequence:   t.sequence,
+			nonce:      nonce,
+		}
+		sends.pending[identifier] = send
+		t.sends[identifier] = append(t.sends[identifier], send)
+
+		if len(sends.pending) > t.maxPending {
+			t.maxPending = len(sends.pending)
+		}
+	}
+
+	return nil
+}
+
+// Confirmed validates that the transaction broadcast by the job
+// with identifier (confirmed in block) did not confirm before
+// a transaction broadcast earlier from the same account (or
+// after a transaction broadcast later).
+func (t *SequenceTracker) Confirmed(identifier string, block *types.BlockIdentifier) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	sends, ok := t.sends[identifier]
+	if !ok {
+		return nil
+	}
+
+	for _, send := range sends {
+		account := t.accounts[send.account]
+		for _, other := range account.confirmed {
+			earlier := other.sequence < send.sequence
+			if (earlier && other.block.Index > block.Index) ||
+				(!earlier && other.block.Index < block.Index) {
+				return fmt.Errorf(
+					"%w: job %s confirmed in block %d but job %s confirmed in block %d",
+					results.ErrOutOfOrderConfirmation,
+					identifier,
+					block.Index,
+					other.identifier,
+					other.block.Index,
+				)
+			}
+		}
+
+		send.block = block
+		account.confirmed = append(account.confirmed, send)
+	}
+
+	t.confirmed++
+	t.remove(identifier)
+	return nil
+}
+
+// Failed removes the transaction broadcast by the job
+// with identifier (which will never be confirmed).
+func (t *SequenceTracker) Failed(identifier string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if _, ok := t.sends[identifier]; !ok {
+		return
+	}
+
+	t.failed++
+	t.remove(identifier)
+}
+
+// remove removes the pending sends of the job with identifier
+// and forgets the confirmed sends of accounts without any
+// remaining pending sends.
+func (t *SequenceTracker) remove(identifier string) {
+	for _, send := range t.sends[identifier] {
+		account := t.accounts[send.account]
+		delete(account.pending, identifier)
+		if len(account.pending) == 0 {
+			delete(t.accounts, send.account)
+		}
+	}
+
+	delete(t.sends, identifier)
+}
+
+// Locked returns the accounts in locked that must stay locked.
+// An account is unlocked if all broadcasts involving it
+// are pending concurrent sends from the account and fewer
+// than MaxPending sends are pending.
+func (t *SequenceTracker) Locked(
+	locked []*types.AccountIdentifier,
+	broadcasts []*storage.Broadcast,
+) []*types.AccountIdentifier {
+	involved := map[string]int{}
+	for _, broadcast := range broadcasts {
+		seen := map[string]struct{}{}
+		for _, op := range broadcast.Intent {
+			if op.Account == nil {
+				continue
+			}
+
+			key := types.Hash(op.Account)
+			if _, ok := seen[key]; ok {
+				continue
+			}
+
+			seen[key] = struct{}{}
+			involved[key]++
+		}
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	stillLocked := []*types.AccountIdentifier{}
+	for _, account := range locked {
+		key := types.Hash(account)
+		sends, ok := t.accounts[key]
+		if ok && len(sends.pending) == involved[key] &&
+			len(sends.pending) < t.config.MaxPending {
+			continue
+		}
+
+		stillLocked = append(stillLocked, account)
+	}
+
+	return stillLocked
+}
+
+// SequenceReport summarizes the transactions
+// sent concurrently from the same account.
+type SequenceReport struct {
+	Sent      int `json:"sent"`
+	Confirmed int `json:"confirmed"`
+	Failed    int `json:"failed"`
+
+	// MaxPending is the greatest number of sends
+	// pending from the same account at once.
+	MaxPending int `json:"max_pending"`
+}
+
+// Report returns a *SequenceReport of all tracked sends.
+func (t *SequenceTracker) Report() *SequenceReport {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	return &SequenceReport{
+		Sent:       t.sent,
+		Confirmed:  t.confirmed,
+		Failed:     t.failed,
+		MaxPending: t.maxPending,
+	}
+}
+
+// Print prints the report as a table and warns if
+// no transactions were pending from the same
+// account at once.
+func (r *SequenceReport) Print() {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetRowLine(true)
+	table.SetRowSeparator("-")
+	table.SetHeader([]string{
+		"Concurrent Sends",
+		"Confirmed",
+		"Failed",
+		"Max Pending From Account",
+	})
+	table.Append([]string{
+		fmt.Sprintf("%d", r.Sent),
+		fmt.Sprintf("%d", r.Confirmed),
+		fmt.Sprintf("%d", r.Failed),
+		fmt.Sprintf("%d", r.MaxPending),
+	})
+	table.Render()
+
+	if r.MaxPending < 2 {
+		color.Yellow("no transactions were pending from the same account at once")
+	}
+}
@@ -0,0 +1,171 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/coinbase/rosetta-cli/configuration"
+	"github.com/coinbase/rosetta-cli/pkg/results"
+
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func sequenceTestIntent(from string, to string) []*types.Operation {
+	return []*types.Operation{
+		feeTestOp("TRANSFER", "", from, "-10"),
+		feeTestOp("TRANSFER", "", to, "10"),
+	}
+}
+
+func TestSequenceTrackerLocked(t *testing.T) {
+	tracker := NewSequenceTracker(&configuration.ConcurrentSends{
+		Workflows:  []string{"transfer"},
+		MaxPending: 2,
+	})
+
+	addr1 := &types.AccountIdentifier{Address: "addr1"}
+	addr2 := &types.AccountIdentifier{Address: "addr2"}
+	addr3 := &types.AccountIdentifier{Address: "addr3"}
+	locked := []*types.AccountIdentifier{addr1, addr2}
+
+	broadcasts := []*storage.Broadcast{
+		{Identifier: "1", Intent: sequenceTestIntent("addr1", "addr2")},
+	}
+	assert.NoError(t, tracker.Broadcast("1", "transfer", broadcasts[0].Intent))
+
+	// The recipient of a concurrent send stays locked.
+	assert.Equal(t, []*types.AccountIdentifier{addr2}, tracker.Locked(locked, broadcasts))
+
+	// Sends of other workflows are not tracked.
+	broadcasts = append(broadcasts, &storage.Broadcast{
+		Identifier: "2",
+		Intent:     sequenceTestIntent("addr3", "addr1"),
+	})
+	assert.NoError(t, tracker.Broadcast("2", "other", broadcasts[1].Intent))
+	assert.Equal(
+		t,
+		[]*types.AccountIdentifier{addr1, addr2, addr3},
+		tracker.Locked([]*types.AccountIdentifier{addr1, addr2, addr3}, broadcasts),
+	)
+
+	// Accounts with MaxPending sends stay locked.
+	broadcasts = []*storage.Broadcast{
+		broadcasts[0],
+		{Identifier: "3", Intent: sequenceTestIntent("addr1", "addr3")},
+	}
+	assert.NoError(t, tracker.Broadcast("3", "transfer", broadcasts[1].Intent))
+	assert.Equal(
+		t,
+		[]*types.AccountIdentifier{addr1, addr2, addr3},
+		tracker.Locked([]*types.AccountIdentifier{addr1, addr2, addr3}, broadcasts),
+	)
+
+	tracker.Failed("3")
+	assert.Equal(t, []*types.AccountIdentifier{addr2}, tracker.Locked(locked, broadcasts[:1]))
+	assert.Equal(t, &SequenceReport{Sent: 2, Failed: 1, MaxPending: 2}, tracker.Report())
+}
+
+func TestSequenceTrackerNonceReuse(t *testing.T) {
+	tracker := NewSequenceTracker(&configuration.ConcurrentSends{
+		Workflows:  []string{"transfer"},
+		MaxPending: 3,
+		NonceKey:   "account.nonce",
+	})
+
+	metadata := func(nonce int) map[string]interface{} {
+		return map[string]interface{}{
+			"account": map[string]interface{}{"nonce": nonce},
+		}
+	}
+
+	tracker.Metadata(metadata(1))
+	assert.NoError(t, tracker.Broadcast("1", "transfer", sequenceTestIntent("addr1", "addr2")))
+
+	// Another account may use the same nonce.
+	tracker.Metadata(metadata(1))
+	assert.NoError(t, tracker.Broadcast("2", "transfer", sequenceTestIntent("addr2", "addr1")))
+
+	tracker.Metadata(metadata(1))
+	err := tracker.Broadcast("3", "transfer", sequenceTestIntent("addr1", "addr3"))
+	assert.True(t, errors.Is(err, results.ErrNonceReuse))
+
+	// The nonce of a confirmed transaction may be reused
+	// (like when a chain assigns nonces per block).
+	assert.NoError(t, tracker.Confirmed("1", &types.BlockIdentifier{Index: 10}))
+	tracker.Metadata(metadata(1))
+	assert.NoError(t, tracker.Broadcast("3", "transfer", sequenceTestIntent("addr1", "addr3")))
+}
+
+func TestSequenceTrackerConfirmed(t *testing.T) {
+	var tests = map[string]struct {
+		confirmations map[string]int64
+		order         []string
+
+		err bool
+	}{
+		"in order": {
+			confirmations: map[string]int64{"1": 10, "2": 10, "3": 11},
+			order:         []string{"1", "2", "3"},
+		},
+		"same block confirmed in any order": {
+			confirmations: map[string]int64{"1": 10, "2": 10, "3": 10},
+			order:         []string{"3", "1", "2"},
+		},
+		"later send in earlier block": {
+			confirmations: map[string]int64{"1": 11, "2": 10, "3": 12},
+			order:         []string{"2", "1", "3"},
+			err:           true,
+		},
+		"earlier send in later block": {
+			confirmations: map[string]int64{"1": 10, "2": 12, "3": 11},
+			order:         []string{"1", "3", "2"},
+			err:           true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			tracker := NewSequenceTracker(&configuration.ConcurrentSends{
+				Workflows:  []string{"transfer"},
+				MaxPending: 3,
+			})
+
+			for _, identifier := range []string{"1", "2", "3"} {
+				intent := sequenceTestIntent("addr1", "addr2")
+				assert.NoError(t, tracker.Broadcast(identifier, "transfer", intent))
+			}
+
+			var err error
+			for _, identifier := range test.order {
+				block := &types.BlockIdentifier{Index: test.confirmations[identifier]}
+				if err = tracker.Confirmed(identifier, block); err != nil {
+					break
+				}
+			}
+
+			if test.err {
+				assert.True(t, errors.Is(err, results.ErrOutOfOrderConfirmation))
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, &SequenceReport{Sent: 3, Confirmed: 3, MaxPending: 3}, tracker.Report())
+		})
+	}
+}
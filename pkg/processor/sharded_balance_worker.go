@@ -0,0 +1,391 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sort"
+
+	"github.com/coinbase/rosetta-sdk-go/parser"
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"golang.org/x/sync/errgroup"
+)
+
+var _ storage.BlockWorker = (*ShardedBalanceWorker)(nil)
+
+// ErrShardWriteConflict is returned when the balance changes
+// applied by different shards write the same key.
+var ErrShardWriteConflict = errors.New("key written by multiple shards")
+
+// BalanceUpdater applies a balance change to
+// storage (implemented by *storage.BalanceStorage).
+type BalanceUpdater interface {
+	storage.BlockWorker
+
+	UpdateBalance(
+		ctx context.Context,
+		dbTransaction storage.DatabaseTransaction,
+		change *parser.BalanceChange,
+		parentBlock *types.BlockIdentifier,
+	) error
+}
+
+// ShardedBalanceWorker implements the storage.BlockWorker interface
+// and replaces the *storage.BalanceStorage as a block worker when
+// blocks are added. The balance changes of each block are merged
+// into a single delta per account and currency (by the parser) and
+// partitioned into shards by account, so each account is owned by
+// exactly one shard. Shards are applied concurrently, each with its
+// own shardTransaction: reads are made on a read-only transaction
+// owned by the shard and writes are buffered in memory. Once all
+// shards are done, their writes are merged into the block's storage
+// transaction (so the block is still committed atomically).
+//
+// Blocks are removed by the *storage.BalanceStorage (reorgs
+// rarely orphan enough blocks for this to be a bottleneck).
+type ShardedBalanceWorker struct {
+	database       storage.Database
+	balanceStorage BalanceUpdater
+	parser         *parser.Parser
+	handler        storage.BalanceStorageHandler
+	shards         int
+}
+
+// NewShardedBalanceWorker returns a new *ShardedBalanceWorker.
+// helper and handler must be the helper and handler the
+// *storage.BalanceStorage was initialized with (so balance
+// changes are computed with the same exemptions) and
+// database must be the storage.Database it stores balances in.
+func NewShardedBalanceWorker(
+	database storage.Database,
+	balanceStorage BalanceUpdater,
+	helper storage.BalanceStorageHelper,
+	handler storage.BalanceStorageHandler,
+	shards int,
+) *ShardedBalanceWorker {
+	return &ShardedBalanceWorker{
+		database:       database,
+		balanceStorage: balanceStorage,
+		parser: parser.New(
+			helper.Asserter(),
+			helper.ExemptFunc(),
+			helper.BalanceExemptions(),
+		),
+		handler: handler,
+		shards:  shards,
+	}
+}
+
+// shard returns the shard that owns the
+// account and currency of change.
+func (w *ShardedBalanceWorker) shard(change *parser.BalanceChange) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(types.Hash(&types.AccountCurrency{
+		Account:  change.Account,
+		Currency: change.Currency,
+	})))
+
+	return int(h.Sum32() % uint32(w.shards))
+}
+
+// partition returns changes partitioned into at most
+// w.shards shards (empty shards are omitted).
+func (w *ShardedBalanceWorker) partition(
+	changes []*parser.BalanceChange,
+) [][]*parser.BalanceChange {
+	shards := make([][]*parser.BalanceChange, w.shards)
+	for _, change := range changes {
+		i := w.shard(change)
+		shards[i] = append(shards[i], change)
+	}
+
+	partitioned := [][]*parser.BalanceChange{}
+	for _, shard := range shards {
+		if len(shard) > 0 {
+			partitioned = append(partitioned, shard)
+		}
+	}
+
+	return partitioned
+}
+
+// AddingBlock applies the balance changes in block
+// concurrently and returns a storage.CommitWorker that
+// notifies the handler of all changes.
+//
+// The storage.Database only allows one write transaction at a
+// time, so no balances are committed while transaction is open
+// and the read-only transaction of each shard sees the same
+// balances as transaction (balances are only written by the
+// balance worker).
+func (w *ShardedBalanceWorker) AddingBlock(
+	ctx context.Context,
+	block *types.Block,
+	transaction storage.DatabaseTransaction,
+) (storage.CommitWorker, error) {
+	changes, err := w.parser.BalanceChanges(ctx, block, false)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to calculate balance changes", err)
+	}
+
+	partitioned := w.partition(changes)
+	shardTxs := make([]*shardTransaction, len(partitioned))
+	for i := range partitioned {
+		shardTxs[i] = newShardTransaction(w.database.NewDatabaseTransaction(ctx, false))
+	}
+	defer func() {
+		for _, shardTx := range shardTxs {
+			shardTx.Discard(ctx)
+		}
+	}()
+
+	g, gctx := errgroup.WithContext(ctx)
+	for i, shard := range partitioned {
+		shardTx, shard := shardTxs[i], shard
+		g.Go(func() error {
+			for _, change := range shard {
+				if err := w.balanceStorage.UpdateBalance(
+					gctx,
+					shardTx,
+					change,
+					block.ParentBlockIdentifier,
+				); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+	}
+
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	if err := mergeShardTransactions(ctx, transaction, shardTxs); err != nil {
+		return nil, err
+	}
+
+	return func(ctx context.Context) error {
+		return w.handler.BlockAdded(ctx, block, changes)
+	}, nil
+}
+
+// RemovingBlock passes block to the *storage.BalanceStorage.
+func (w *ShardedBalanceWorker) RemovingBlock(
+	ctx context.Context,
+	block *types.Block,
+	transaction storage.DatabaseTransaction,
+) (storage.CommitWorker, error) {
+	return w.balanceStorage.RemovingBlock(ctx, block, transaction)
+}
+
+// shardWrite is a write buffered by a shardTransaction.
+type shardWrite struct {
+	value        []byte
+	reclaimValue bool
+	deleted      bool
+}
+
+// shardTransaction is the storage.DatabaseTransaction a shard
+// applies its balance changes with. Reads are made on a read-only
+// transaction (overlaid with the writes of the shard) and writes
+// are buffered until they are merged into the block's transaction
+// by mergeShardTransactions. It is only used by one shard, so it
+// is not safe for concurrent use.
+type shardTransaction struct {
+	storage.DatabaseTransaction
+
+	writes map[string]*shardWrite
+
+	// keys are the written keys in the
+	// order they were first written.
+	keys []string
+}
+
+func newShardTransaction(snapshot storage.DatabaseTransaction) *shardTransaction {
+	return &shardTransaction{
+		DatabaseTransaction: snapshot,
+		writes:              map[string]*shardWrite{},
+	}
+}
+
+// write buffers write for key.
+func (t *shardTransaction) write(key []byte, write *shardWrite) {
+	if _, ok := t.writes[string(key)]; !ok {
+		t.keys = append(t.keys, string(key))
+	}
+
+	t.writes[string(key)] = write
+}
+
+func (t *shardTransaction) Set(
+	ctx context.Context,
+	key []byte,
+	value []byte,
+	reclaimValue bool,
+) error {
+	t.write(key, &shardWrite{
+		value:        append([]byte{}, value...),
+		reclaimValue: reclaimValue,
+	})
+
+	return nil
+}
+
+func (t *shardTransaction) Delete(ctx context.Context, key []byte) error {
+	t.write(key, &shardWrite{deleted: true})
+
+	return nil
+}
+
+func (t *shardTransaction) Get(ctx context.Context, key []byte) (bool, []byte, error) {
+	if write, ok := t.writes[string(key)]; ok {
+		if write.deleted {
+			return false, nil, nil
+		}
+
+		return true, write.value, nil
+	}
+
+	return t.DatabaseTransaction.Get(ctx, key)
+}
+
+// pending returns the written (and not deleted) keys that
+// a scan of prefix starting at seekStart would visit, in
+// the order they would be visited.
+func (t *shardTransaction) pending(prefix []byte, seekStart []byte, reverse bool) []string {
+	pending := []string{}
+	for key, write := range t.writes {
+		if write.deleted || !bytes.HasPrefix([]byte(key), prefix) {
+			continue
+		}
+
+		cmp := bytes.Compare([]byte(key), seekStart)
+		if (!reverse && cmp < 0) || (reverse && cmp > 0) {
+			continue
+		}
+
+		pending = append(pending, key)
+	}
+
+	sort.Slice(pending, func(i, j int) bool {
+		if reverse {
+			return pending[i] > pending[j]
+		}
+
+		return pending[i] < pending[j]
+	})
+
+	return pending
+}
+
+// Scan merges the entries of the read-only transaction with
+// the writes of the shard (which replace entries with the same
+// key). The worker can stop the scan by returning an error.
+func (t *shardTransaction) Scan(
+	ctx context.Context,
+	prefix []byte,
+	seekStart []byte,
+	worker func([]byte, []byte) error,
+	logEntries bool,
+	reverse bool,
+) (int, error) {
+	pending := t.pending(prefix, seekStart, reverse)
+	count := 0
+	emitPending := func(until []byte) error {
+		for len(pending) > 0 {
+			cmp := bytes.Compare([]byte(pending[0]), until)
+			if until != nil && ((!reverse && cmp >= 0) || (reverse && cmp <= 0)) {
+				return nil
+			}
+
+			key := pending[0]
+			pending = pending[1:]
+			count++
+			if err := worker([]byte(key), t.writes[key].value); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	_, err := t.DatabaseTransaction.Scan(
+		ctx,
+		prefix,
+		seekStart,
+		func(k []byte, v []byte) error {
+			if err := emitPending(k); err != nil {
+				return err
+			}
+
+			// Written keys are visited from pending.
+			if _, ok := t.writes[string(k)]; ok {
+				return nil
+			}
+
+			count++
+			return worker(k, v)
+		},
+		logEntries,
+		reverse,
+	)
+	if err != nil {
+		return count, err
+	}
+
+	return count, emitPending(nil)
+}
+
+// mergeShardTransactions applies the buffered writes of
+// shardTxs to transaction. Each shard owns distinct accounts,
+// so ErrShardWriteConflict is returned if multiple shards
+// wrote the same key.
+func mergeShardTransactions(
+	ctx context.Context,
+	transaction storage.DatabaseTransaction,
+	shardTxs []*shardTransaction,
+) error {
+	merged := map[string]struct{}{}
+	for _, shardTx := range shardTxs {
+		for _, key := range shardTx.keys {
+			if _, ok := merged[key]; ok {
+				return fmt.Errorf("%w: %s", ErrShardWriteConflict, key)
+			}
+			merged[key] = struct{}{}
+
+			write := shardTx.writes[key]
+			if write.deleted {
+				if err := transaction.Delete(ctx, []byte(key)); err != nil {
+					return fmt.Errorf("%w: unable to delete %s", err, key)
+				}
+
+				continue
+			}
+
+			if err := transaction.Set(ctx, []byte(key), write.value, write.reclaimValue); err != nil {
+				return fmt.Errorf("%w: unable to set %s", err, key)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,255 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/asserter"
+	"github.com/coinbase/rosetta-sdk-go/fetcher"
+	"github.com/coinbase/rosetta-sdk-go/parser"
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/coinbase/rosetta-sdk-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+var errStopShardScan = errors.New("stop scan")
+
+// changeRecorder is a storage.BalanceStorageHandler
+// that records the balance changes of added blocks.
+type changeRecorder struct {
+	added [][]*parser.BalanceChange
+}
+
+func (r *changeRecorder) BlockAdded(
+	ctx context.Context,
+	block *types.Block,
+	changes []*parser.BalanceChange,
+) error {
+	r.added = append(r.added, changes)
+	return nil
+}
+
+func (r *changeRecorder) BlockRemoved(
+	ctx context.Context,
+	block *types.Block,
+	changes []*parser.BalanceChange,
+) error {
+	return nil
+}
+
+func TestShardedBalanceWorker(t *testing.T) {
+	ctx := context.Background()
+	network := &types.NetworkIdentifier{Blockchain: "blah", Network: "testnet"}
+	a, err := asserter.NewClientWithOptions(
+		network,
+		&types.BlockIdentifier{Hash: "genesis", Index: 0},
+		[]string{"TRANSFER"},
+		[]*types.OperationStatus{{Status: "SUCCESS", Successful: true}},
+		[]*types.Error{},
+		nil,
+	)
+	assert.NoError(t, err)
+
+	helper := NewBalanceStorageHelper(
+		network,
+		fetcher.New("http://localhost", fetcher.WithAsserter(a)),
+		false,
+		nil,
+		nil,
+		false,
+		nil,
+		false,
+	)
+
+	// Each account is credited multiple times and debited
+	// once, so the deltas of each account must be merged.
+	accounts := 50
+	ops := []*types.Operation{}
+	for i := 0; i < accounts; i++ {
+		address := fmt.Sprintf("addr%d", i)
+		for j := 0; j < 3; j++ {
+			ops = append(ops, feeTestOp("TRANSFER", "SUCCESS", address, fmt.Sprintf("%d", i+1)))
+		}
+		ops = append(ops, feeTestOp("TRANSFER", "SUCCESS", address, "-1"))
+	}
+
+	block := creationTestBlock(1, ops...)
+
+	dir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(dir)
+
+	localStore, err := storage.NewBadgerStorage(
+		ctx,
+		dir,
+		storage.WithIndexCacheSize(storage.TinyIndexCacheSize),
+	)
+	assert.NoError(t, err)
+	defer localStore.Close(ctx)
+
+	recorder := &changeRecorder{}
+	balanceStorage := storage.NewBalanceStorage(localStore)
+	balanceStorage.Initialize(helper, recorder)
+	w := NewShardedBalanceWorker(localStore, balanceStorage, helper, recorder, 4)
+	assert.Len(t, w.partition(make([]*parser.BalanceChange, 0)), 0)
+
+	dbTx := localStore.NewDatabaseTransaction(ctx, true)
+	commitWorker, err := w.AddingBlock(ctx, block, dbTx)
+	assert.NoError(t, err)
+	assert.NoError(t, dbTx.Commit(ctx))
+	assert.NoError(t, commitWorker(ctx))
+
+	assert.Len(t, recorder.added, 1)
+	assert.Len(t, recorder.added[0], accounts)
+	assert.True(t, len(w.partition(recorder.added[0])) > 1)
+
+	dbTx = localStore.NewDatabaseTransaction(ctx, false)
+	defer dbTx.Discard(ctx)
+	for i := 0; i < accounts; i++ {
+		expected := fmt.Sprintf("%d", 3*(i+1)-1)
+		amount, err := balanceStorage.GetBalanceTransactional(
+			ctx,
+			dbTx,
+			&types.AccountIdentifier{Address: fmt.Sprintf("addr%d", i)},
+			&types.Currency{Symbol: "BTC", Decimals: 8},
+			block.BlockIdentifier.Index,
+		)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, amount.Value)
+	}
+}
+
+func TestShardTransaction(t *testing.T) {
+	ctx := context.Background()
+	dir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(dir)
+
+	localStore, err := storage.NewBadgerStorage(
+		ctx,
+		dir,
+		storage.WithIndexCacheSize(storage.TinyIndexCacheSize),
+	)
+	assert.NoError(t, err)
+	defer localStore.Close(ctx)
+
+	dbTx := localStore.NewDatabaseTransaction(ctx, true)
+	for _, key := range []string{"bal/1", "bal/3", "bal/5"} {
+		assert.NoError(t, dbTx.Set(ctx, []byte(key), []byte("stored"), true))
+	}
+	assert.NoError(t, dbTx.Commit(ctx))
+
+	snapshot := localStore.NewDatabaseTransaction(ctx, false)
+	defer snapshot.Discard(ctx)
+
+	shardTx := newShardTransaction(snapshot)
+	assert.NoError(t, shardTx.Set(ctx, []byte("bal/2"), []byte("written"), true))
+	assert.NoError(t, shardTx.Set(ctx, []byte("bal/3"), []byte("written"), true))
+	assert.NoError(t, shardTx.Delete(ctx, []byte("bal/5")))
+	assert.NoError(t, shardTx.Set(ctx, []byte("bal/6"), []byte("written"), true))
+
+	exists, value, err := shardTx.Get(ctx, []byte("bal/3"))
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, []byte("written"), value)
+
+	exists, _, err = shardTx.Get(ctx, []byte("bal/5"))
+	assert.NoError(t, err)
+	assert.False(t, exists)
+
+	tests := map[string]struct {
+		seekStart string
+		reverse   bool
+		limit     int
+
+		expected []string
+	}{
+		"forward": {
+			seekStart: "bal/",
+			expected:  []string{"bal/1=stored", "bal/2=written", "bal/3=written", "bal/6=written"},
+		},
+		"forward from written key": {
+			seekStart: "bal/2",
+			expected:  []string{"bal/2=written", "bal/3=written", "bal/6=written"},
+		},
+		"reverse": {
+			seekStart: "bal/9",
+			reverse:   true,
+			expected:  []string{"bal/6=written", "bal/3=written", "bal/2=written", "bal/1=stored"},
+		},
+		"reverse stopped by worker": {
+			seekStart: "bal/4",
+			reverse:   true,
+			limit:     1,
+			expected:  []string{"bal/3=written"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			visited := []string{}
+			_, err := shardTx.Scan(
+				ctx,
+				[]byte("bal/"),
+				[]byte(test.seekStart),
+				func(k []byte, v []byte) error {
+					visited = append(visited, fmt.Sprintf("%s=%s", k, v))
+					if test.limit > 0 && len(visited) == test.limit {
+						return errStopShardScan
+					}
+
+					return nil
+				},
+				false,
+				test.reverse,
+			)
+			if test.limit > 0 {
+				assert.True(t, errors.Is(err, errStopShardScan))
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, test.expected, visited)
+		})
+	}
+
+	// Writes are merged into the block's transaction.
+	dbTx = localStore.NewDatabaseTransaction(ctx, true)
+	assert.NoError(t, mergeShardTransactions(ctx, dbTx, []*shardTransaction{shardTx}))
+	assert.NoError(t, dbTx.Commit(ctx))
+
+	readTx := localStore.NewDatabaseTransaction(ctx, false)
+	defer readTx.Discard(ctx)
+	exists, value, err = readTx.Get(ctx, []byte("bal/3"))
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, []byte("written"), value)
+
+	exists, _, err = readTx.Get(ctx, []byte("bal/5"))
+	assert.NoError(t, err)
+	assert.False(t, exists)
+
+	// Shards must not write the same key.
+	other := newShardTransaction(snapshot)
+	assert.NoError(t, other.Set(ctx, []byte("bal/2"), []byte("other"), true))
+	dbTx = localStore.NewDatabaseTransaction(ctx, true)
+	defer dbTx.Discard(ctx)
+	err = mergeShardTransactions(ctx, dbTx, []*shardTransaction{shardTx, other})
+	assert.True(t, errors.Is(err, ErrShardWriteConflict))
+}
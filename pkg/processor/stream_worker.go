@@ -0,0 +1,92 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/coinbase/rosetta-cli/pkg/results"
+	"github.com/coinbase/rosetta-cli/pkg/stream"
+
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+var _ storage.BlockWorker = (*StreamWorker)(nil)
+
+// StreamWorker implements the storage.BlockWorker interface
+// and publishes every added and removed block to a topic.
+//
+// Events are published by the storage.CommitWorker of each
+// block, so only committed blocks are published.
+type StreamWorker struct {
+	publisher *stream.Publisher
+	network   *types.NetworkIdentifier
+}
+
+// NewStreamWorker returns a new *StreamWorker.
+func NewStreamWorker(
+	publisher *stream.Publisher,
+	network *types.NetworkIdentifier,
+) *StreamWorker {
+	return &StreamWorker{
+		publisher: publisher,
+		network:   network,
+	}
+}
+
+func (w *StreamWorker) publish(ctx context.Context, eventType string, block *types.Block) error {
+	if err := w.publisher.Publish(ctx, &stream.Event{
+		Type:    eventType,
+		Network: w.network,
+		Block:   block,
+	}); err != nil {
+		return fmt.Errorf(
+			"%w: unable to publish %s for block %s:%d: %s",
+			results.ErrBlockStreamFailure,
+			eventType,
+			block.BlockIdentifier.Hash,
+			block.BlockIdentifier.Index,
+			err.Error(),
+		)
+	}
+
+	return nil
+}
+
+// AddingBlock returns a storage.CommitWorker
+// that publishes the added block.
+func (w *StreamWorker) AddingBlock(
+	ctx context.Context,
+	block *types.Block,
+	transaction storage.DatabaseTransaction,
+) (storage.CommitWorker, error) {
+	return func(ctx context.Context) error {
+		return w.publish(ctx, stream.BlockAdded, block)
+	}, nil
+}
+
+// RemovingBlock returns a storage.CommitWorker
+// that publishes the orphaned block.
+func (w *StreamWorker) RemovingBlock(
+	ctx context.Context,
+	block *types.Block,
+	transaction storage.DatabaseTransaction,
+) (storage.CommitWorker, error) {
+	return func(ctx context.Context) error {
+		return w.publish(ctx, stream.BlockRemoved, block)
+	}, nil
+}
@@ -0,0 +1,96 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+var _ storage.BlockWorker = (*SubAccountAggregationWorker)(nil)
+
+// SubAccountAggregationWorker implements the storage.BlockWorker
+// interface and wraps a storage.BlockWorker (usually the
+// *storage.BalanceStorage) that should track balances per
+// account instead of per (account, sub-account) pair. Before
+// each block is passed to the wrapped worker, the sub-account
+// (including its metadata) of every operation is removed.
+//
+// This is useful for blockchains that represent staked or locked
+// funds with sub-accounts but only return the total balance of
+// an account from /account/balance.
+type SubAccountAggregationWorker struct {
+	worker storage.BlockWorker
+}
+
+// NewSubAccountAggregationWorker returns a new
+// *SubAccountAggregationWorker.
+func NewSubAccountAggregationWorker(worker storage.BlockWorker) *SubAccountAggregationWorker {
+	return &SubAccountAggregationWorker{
+		worker: worker,
+	}
+}
+
+// AggregateSubAccounts returns a copy of block where the
+// SubAccountIdentifier of every operation account is removed.
+// block is not modified (so it can be processed by other
+// workers).
+func AggregateSubAccounts(block *types.Block) *types.Block {
+	aggregated := *block
+	aggregated.Transactions = make([]*types.Transaction, len(block.Transactions))
+	for i, tx := range block.Transactions {
+		aggregatedTx := *tx
+		aggregatedTx.Operations = make([]*types.Operation, len(tx.Operations))
+		for j, op := range tx.Operations {
+			if op.Account == nil || op.Account.SubAccount == nil {
+				aggregatedTx.Operations[j] = op
+				continue
+			}
+
+			aggregatedOp := *op
+			aggregatedOp.Account = &types.AccountIdentifier{
+				Address:  op.Account.Address,
+				Metadata: op.Account.Metadata,
+			}
+			aggregatedTx.Operations[j] = &aggregatedOp
+		}
+
+		aggregated.Transactions[i] = &aggregatedTx
+	}
+
+	return &aggregated
+}
+
+// AddingBlock passes block (without sub-accounts)
+// to the wrapped worker.
+func (w *SubAccountAggregationWorker) AddingBlock(
+	ctx context.Context,
+	block *types.Block,
+	transaction storage.DatabaseTransaction,
+) (storage.CommitWorker, error) {
+	return w.worker.AddingBlock(ctx, AggregateSubAccounts(block), transaction)
+}
+
+// RemovingBlock passes block (without sub-accounts)
+// to the wrapped worker.
+func (w *SubAccountAggregationWorker) RemovingBlock(
+	ctx context.Context,
+	block *types.Block,
+	transaction storage.DatabaseTransaction,
+) (storage.CommitWorker, error) {
+	return w.worker.RemovingBlock(ctx, AggregateSubAccounts(block), transaction)
+}
@@ -0,0 +1,63 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAggregateSubAccounts(t *testing.T) {
+	currency := &types.Currency{Symbol: "DOT", Decimals: 10}
+	stakedOp := &types.Operation{
+		OperationIdentifier: &types.OperationIdentifier{Index: 0},
+		Type:                "BOND",
+		Account: &types.AccountIdentifier{
+			Address: "addr1",
+			SubAccount: &types.SubAccountIdentifier{
+				Address:  "staked",
+				Metadata: map[string]interface{}{"era": 10},
+			},
+		},
+		Amount: &types.Amount{Value: "100", Currency: currency},
+	}
+	transferOp := &types.Operation{
+		OperationIdentifier: &types.OperationIdentifier{Index: 1},
+		Type:                "TRANSFER",
+		Account:             &types.AccountIdentifier{Address: "addr1"},
+		Amount:              &types.Amount{Value: "-100", Currency: currency},
+	}
+	block := &types.Block{
+		BlockIdentifier: &types.BlockIdentifier{Hash: "block 1", Index: 1},
+		Transactions: []*types.Transaction{
+			{
+				TransactionIdentifier: &types.TransactionIdentifier{Hash: "tx1"},
+				Operations:            []*types.Operation{stakedOp, transferOp},
+			},
+		},
+	}
+	original := types.Hash(block)
+
+	aggregated := AggregateSubAccounts(block)
+	assert.Equal(t, original, types.Hash(block))
+	assert.Equal(t, block.BlockIdentifier, aggregated.BlockIdentifier)
+
+	ops := aggregated.Transactions[0].Operations
+	assert.Equal(t, &types.AccountIdentifier{Address: "addr1"}, ops[0].Account)
+	assert.Equal(t, stakedOp.Amount, ops[0].Amount)
+	assert.Equal(t, transferOp, ops[1])
+}
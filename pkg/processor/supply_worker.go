@@ -0,0 +1,397 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+
+	"github.com/coinbase/rosetta-cli/configuration"
+	"github.com/coinbase/rosetta-cli/pkg/failures"
+	"github.com/coinbase/rosetta-cli/pkg/results"
+
+	"github.com/coinbase/rosetta-sdk-go/asserter"
+	"github.com/coinbase/rosetta-sdk-go/fetcher"
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	// supplyNamespace is prepended to the stored net
+	// issuance of each tracked currency.
+	supplyNamespace = "supply"
+)
+
+var _ storage.BlockWorker = (*SupplyWorker)(nil)
+
+// SupplyWorker implements the storage.BlockWorker interface
+// and tracks the total supply of each configured currency (the
+// sum of all successful operations in the currency). At every
+// checkpoint, the tracked supply is compared to the supply
+// expected by the issuance schedule of the currency (or the
+// supply reported by the node with /call).
+//
+// Inflation bugs (like an incorrect block reward) credit some
+// account with the amount issued, so the computed balance of
+// the account matches the balance reported by the node and the
+// bug is not caught by reconciliation.
+type SupplyWorker struct {
+	network        *types.NetworkIdentifier
+	fetcher        *fetcher.Fetcher
+	asserter       *asserter.Asserter
+	config         *configuration.SupplyTracking
+	failureStorage *failures.Storage
+}
+
+// NewSupplyWorker returns a new *SupplyWorker.
+func NewSupplyWorker(
+	network *types.NetworkIdentifier,
+	fetcher *fetcher.Fetcher,
+	asserter *asserter.Asserter,
+	config *configuration.SupplyTracking,
+	failureStorage *failures.Storage,
+) *SupplyWorker {
+	return &SupplyWorker{
+		network:        network,
+		fetcher:        fetcher,
+		asserter:       asserter,
+		config:         config,
+		failureStorage: failureStorage,
+	}
+}
+
+func getSupplyKey(currency *types.Currency) []byte {
+	return []byte(fmt.Sprintf("%s/%s", supplyNamespace, types.Hash(currency)))
+}
+
+// parseAmount parses amount (treating an empty amount as 0).
+func parseAmount(amount string) (*big.Int, error) {
+	if len(amount) == 0 {
+		return new(big.Int), nil
+	}
+
+	return types.BigInt(amount)
+}
+
+// ScheduledSupply returns the supply expected by schedule at
+// the block with index (the initial supply plus the reward of
+// each block up to and including index).
+func ScheduledSupply(
+	initialSupply string,
+	schedule []*configuration.IssuancePeriod,
+	index int64,
+) (*big.Int, error) {
+	supply, err := parseAmount(initialSupply)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to parse initial supply", err)
+	}
+
+	for i, period := range schedule {
+		if period.StartIndex > index {
+			break
+		}
+
+		end := index
+		if i+1 < len(schedule) && schedule[i+1].StartIndex-1 < end {
+			end = schedule[i+1].StartIndex - 1
+		}
+
+		reward, err := parseAmount(period.Reward)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to parse reward", err)
+		}
+
+		blocks := big.NewInt(end - period.StartIndex + 1)
+		supply.Add(supply, reward.Mul(reward, blocks))
+	}
+
+	return supply, nil
+}
+
+// issuance returns the net change in supply of each
+// tracked currency (by currency hash) in block.
+func (w *SupplyWorker) issuance(block *types.Block) (map[string]*big.Int, error) {
+	tracked := map[string]struct{}{}
+	for _, currency := range w.config.Currencies {
+		tracked[types.Hash(currency.Currency)] = struct{}{}
+	}
+
+	changes := map[string]*big.Int{}
+	for _, tx := range block.Transactions {
+		for _, op := range tx.Operations {
+			if op.Amount == nil {
+				continue
+			}
+
+			key := types.Hash(op.Amount.Currency)
+			if _, ok := tracked[key]; !ok {
+				continue
+			}
+
+			success, err := w.asserter.OperationSuccessful(op)
+			if err != nil {
+				return nil, fmt.Errorf("%w: unable to check operation success", err)
+			}
+
+			if !success {
+				continue
+			}
+
+			amount, err := types.BigInt(op.Amount.Value)
+			if err != nil {
+				return nil, fmt.Errorf("%w: unable to parse operation amount", err)
+			}
+
+			if _, ok := changes[key]; !ok {
+				changes[key] = new(big.Int)
+			}
+			changes[key].Add(changes[key], amount)
+		}
+	}
+
+	return changes, nil
+}
+
+// update applies the issuance in block to the stored net
+// issuance of each tracked currency (reverting it if remove
+// is true) and returns the tracked supply of each currency
+// (by currency hash).
+func (w *SupplyWorker) update(
+	ctx context.Context,
+	block *types.Block,
+	transaction storage.DatabaseTransaction,
+	remove bool,
+) (map[string]*big.Int, error) {
+	changes, err := w.issuance(block)
+	if err != nil {
+		return nil, err
+	}
+
+	supplies := map[string]*big.Int{}
+	for _, currency := range w.config.Currencies {
+		key := getSupplyKey(currency.Currency)
+		exists, val, err := transaction.Get(ctx, key)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to get supply record", err)
+		}
+
+		issued := new(big.Int)
+		if exists {
+			if _, ok := issued.SetString(string(val), 10); !ok {
+				return nil, fmt.Errorf("supply record of %s is corrupt", currency.Currency.Symbol)
+			}
+		}
+
+		if change, ok := changes[types.Hash(currency.Currency)]; ok && change.Sign() != 0 {
+			if remove {
+				issued.Sub(issued, change)
+			} else {
+				issued.Add(issued, change)
+			}
+
+			if err := transaction.Set(ctx, key, []byte(issued.String()), true); err != nil {
+				return nil, fmt.Errorf("%w: unable to store supply record", err)
+			}
+		}
+
+		supply, err := parseAmount(currency.InitialSupply)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to parse initial supply", err)
+		}
+
+		supplies[types.Hash(currency.Currency)] = supply.Add(supply, issued)
+	}
+
+	return supplies, nil
+}
+
+// reportedSupply returns the supply of currency at
+// block reported by the node with /call.
+func (w *SupplyWorker) reportedSupply(
+	ctx context.Context,
+	block *types.BlockIdentifier,
+	currency *configuration.SupplyCurrency,
+) (*big.Int, error) {
+	parameters := map[string]interface{}{}
+	for k, v := range currency.CallParameters {
+		parameters[k] = v
+	}
+	parameters["block_identifier"] = block
+	parameters["currency"] = currency.Currency
+
+	result, _, fetchErr := w.fetcher.Call(ctx, w.network, currency.CallMethod, parameters)
+	if fetchErr != nil {
+		return nil, fmt.Errorf("%w: unable to call %s", fetchErr.Err, currency.CallMethod)
+	}
+
+	encoded, err := json.Marshal(result)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to marshal call result", err)
+	}
+
+	value := gjson.GetBytes(encoded, currency.ResultPath)
+	if !value.Exists() {
+		return nil, fmt.Errorf(
+			"call result of %s has no supply at %s",
+			currency.CallMethod,
+			currency.ResultPath,
+		)
+	}
+
+	supply, ok := new(big.Int).SetString(value.String(), 10)
+	if !ok {
+		return nil, fmt.Errorf("supply %s at %s is not an integer", value.String(), currency.ResultPath)
+	}
+
+	return supply, nil
+}
+
+// expectedSupply returns the expected supply of
+// currency at block.
+func (w *SupplyWorker) expectedSupply(
+	ctx context.Context,
+	block *types.BlockIdentifier,
+	currency *configuration.SupplyCurrency,
+) (*big.Int, error) {
+	if len(currency.CallMethod) > 0 {
+		return w.reportedSupply(ctx, block, currency)
+	}
+
+	return ScheduledSupply(currency.InitialSupply, currency.Schedule, block.Index)
+}
+
+// violations returns the failures of all tracked
+// supplies that differ from their expected supply
+// at block (by more than their tolerance).
+func (w *SupplyWorker) violations(
+	ctx context.Context,
+	block *types.BlockIdentifier,
+	supplies map[string]*big.Int,
+) ([]*failures.Failure, error) {
+	violations := []*failures.Failure{}
+	for _, currency := range w.config.Currencies {
+		expected, err := w.expectedSupply(ctx, block, currency)
+		if err != nil {
+			return nil, err
+		}
+
+		tolerance, err := parseAmount(currency.Tolerance)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to parse tolerance", err)
+		}
+
+		actual := supplies[types.Hash(currency.Currency)]
+		difference := new(big.Int).Sub(actual, expected)
+		log.Printf(
+			"supply of %s at block %d: %s (expected %s)\n",
+			currency.Currency.Symbol,
+			block.Index,
+			actual.String(),
+			expected.String(),
+		)
+
+		if new(big.Int).Abs(difference).Cmp(tolerance) <= 0 {
+			continue
+		}
+
+		violations = append(violations, &failures.Failure{
+			Kind:     failures.SupplyMismatchFailure,
+			Block:    block,
+			Currency: currency.Currency,
+			Expected: expected.String(),
+			Actual:   actual.String(),
+			Message: fmt.Sprintf(
+				"supply of %s is %s but expected %s (difference %s)",
+				currency.Currency.Symbol,
+				actual.String(),
+				expected.String(),
+				difference.String(),
+			),
+		})
+	}
+
+	return violations, nil
+}
+
+// AddingBlock adds the issuance in block to the tracked
+// supply of each currency and, if block is a checkpoint,
+// returns an error listing every supply that differs from
+// its expected supply.
+func (w *SupplyWorker) AddingBlock(
+	ctx context.Context,
+	block *types.Block,
+	transaction storage.DatabaseTransaction,
+) (storage.CommitWorker, error) {
+	supplies, err := w.update(ctx, block, transaction, false)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to track supply", err)
+	}
+
+	if block.BlockIdentifier.Index%w.config.CheckpointInterval != 0 {
+		return nil, nil
+	}
+
+	violations, err := w.violations(ctx, block.BlockIdentifier, supplies)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to check supply", err)
+	}
+
+	if len(violations) == 0 {
+		return nil, nil
+	}
+
+	messages := make([]string, len(violations))
+	for i, violation := range violations {
+		log.Printf(
+			"supply mismatch in block %s:%d: %s\n",
+			block.BlockIdentifier.Hash,
+			block.BlockIdentifier.Index,
+			violation.Message,
+		)
+
+		// The block transaction is discarded when we return
+		// an error, so the failure must be deferred.
+		w.failureStorage.Defer(violation)
+		messages[i] = violation.Message
+	}
+
+	return nil, fmt.Errorf(
+		"%w: %d violations in block %s:%d [%s]",
+		results.ErrSupplyMismatch,
+		len(violations),
+		block.BlockIdentifier.Hash,
+		block.BlockIdentifier.Index,
+		strings.Join(messages, "; "),
+	)
+}
+
+// RemovingBlock removes the issuance in block
+// from the tracked supply of each currency.
+func (w *SupplyWorker) RemovingBlock(
+	ctx context.Context,
+	block *types.Block,
+	transaction storage.DatabaseTransaction,
+) (storage.CommitWorker, error) {
+	if _, err := w.update(ctx, block, transaction, true); err != nil {
+		return nil, fmt.Errorf("%w: unable to track supply", err)
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,175 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/coinbase/rosetta-cli/configuration"
+	"github.com/coinbase/rosetta-cli/pkg/failures"
+	"github.com/coinbase/rosetta-cli/pkg/results"
+
+	"github.com/coinbase/rosetta-sdk-go/asserter"
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/coinbase/rosetta-sdk-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+var supplyTestSchedule = []*configuration.IssuancePeriod{
+	{StartIndex: 0, Reward: "50"},
+	{StartIndex: 4, Reward: "25"},
+	{StartIndex: 8, Reward: "0"},
+}
+
+func TestScheduledSupply(t *testing.T) {
+	var tests = map[string]struct {
+		initialSupply string
+		index         int64
+
+		expected *big.Int
+	}{
+		"genesis": {
+			index:    0,
+			expected: big.NewInt(50),
+		},
+		"end of first period": {
+			index:    3,
+			expected: big.NewInt(200),
+		},
+		"second period": {
+			index:    5,
+			expected: big.NewInt(250),
+		},
+		"after last reward": {
+			index:    100,
+			expected: big.NewInt(300),
+		},
+		"initial supply": {
+			initialSupply: "1000",
+			index:         1,
+			expected:      big.NewInt(1100),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			supply, err := ScheduledSupply(test.initialSupply, supplyTestSchedule, test.index)
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, supply)
+		})
+	}
+}
+
+func TestSupplyWorker(t *testing.T) {
+	ctx := context.Background()
+	dir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(dir)
+
+	localStore, err := storage.NewBadgerStorage(
+		ctx,
+		dir,
+		storage.WithIndexCacheSize(storage.TinyIndexCacheSize),
+	)
+	assert.NoError(t, err)
+	defer localStore.Close(ctx)
+
+	a, err := asserter.NewClientWithOptions(
+		&types.NetworkIdentifier{Blockchain: "blah", Network: "testnet"},
+		&types.BlockIdentifier{Hash: "genesis", Index: 0},
+		[]string{"REWARD", "TRANSFER"},
+		[]*types.OperationStatus{
+			{Status: "SUCCESS", Successful: true},
+			{Status: "FAILURE", Successful: false},
+		},
+		[]*types.Error{},
+		nil,
+	)
+	assert.NoError(t, err)
+
+	w := NewSupplyWorker(
+		nil,
+		nil,
+		a,
+		&configuration.SupplyTracking{
+			CheckpointInterval: 2,
+			Currencies: []*configuration.SupplyCurrency{
+				{
+					Currency:  &types.Currency{Symbol: "BTC", Decimals: 8},
+					Schedule:  supplyTestSchedule,
+					Tolerance: "5",
+				},
+			},
+		},
+		failures.NewStorage(nil),
+	)
+
+	addBlock := func(block *types.Block) error {
+		dbTx := localStore.NewDatabaseTransaction(ctx, true)
+		defer dbTx.Discard(ctx)
+
+		if _, err := w.AddingBlock(ctx, block, dbTx); err != nil {
+			return err
+		}
+
+		return dbTx.Commit(ctx)
+	}
+
+	removeBlock := func(block *types.Block) {
+		dbTx := localStore.NewDatabaseTransaction(ctx, true)
+		defer dbTx.Discard(ctx)
+
+		_, err := w.RemovingBlock(ctx, block, dbTx)
+		assert.NoError(t, err)
+		assert.NoError(t, dbTx.Commit(ctx))
+	}
+
+	reward := func(index int64, value string) *types.Block {
+		return creationTestBlock(index, feeTestOp("REWARD", "SUCCESS", "miner", value))
+	}
+
+	assert.NoError(t, addBlock(reward(0, "50")))
+	assert.NoError(t, addBlock(creationTestBlock(
+		1,
+		feeTestOp("REWARD", "SUCCESS", "miner", "50"),
+		feeTestOp("TRANSFER", "SUCCESS", "miner", "-10"),
+		feeTestOp("TRANSFER", "SUCCESS", "addr 1", "10"),
+	)))
+	assert.NoError(t, addBlock(reward(2, "50")))
+	assert.NoError(t, addBlock(reward(3, "50")))
+
+	// Differences within the tolerance are allowed.
+	assert.NoError(t, addBlock(reward(4, "30")))
+	block5 := reward(5, "25")
+	assert.NoError(t, addBlock(block5))
+
+	// Unsuccessful operations do not change the supply.
+	err = addBlock(creationTestBlock(
+		6,
+		feeTestOp("REWARD", "SUCCESS", "miner", "31"),
+		feeTestOp("REWARD", "FAILURE", "miner", "100"),
+	))
+	assert.True(t, errors.Is(err, results.ErrSupplyMismatch))
+	assert.Contains(t, err.Error(), "supply of BTC is 286 but expected 275")
+
+	// Orphaned blocks are removed from the supply.
+	removeBlock(block5)
+	assert.NoError(t, addBlock(reward(5, "25")))
+	assert.NoError(t, addBlock(reward(6, "20")))
+}
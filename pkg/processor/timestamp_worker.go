@@ -0,0 +1,256 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/coinbase/rosetta-cli/configuration"
+	"github.com/coinbase/rosetta-cli/pkg/failures"
+	"github.com/coinbase/rosetta-cli/pkg/results"
+
+	"github.com/coinbase/rosetta-sdk-go/fetcher"
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+const (
+	// timestampNamespace is prepended to the stored
+	// timestamp of each synced block.
+	timestampNamespace = "timestamp"
+
+	// timestampRecordSize is the size of a stored timestamp.
+	timestampRecordSize = 8
+
+	// tipRefreshInterval is the minimum time between
+	// fetches of the tip of the node.
+	tipRefreshInterval = 10 * time.Second
+)
+
+var _ storage.BlockWorker = (*TimestampWorker)(nil)
+
+// TimestampWorker implements the storage.BlockWorker interface
+// and ensures block timestamps are non-decreasing (within a
+// tolerance) and fall within a plausible window of wall-clock
+// time.
+//
+// The timestamp of each synced block is stored by index (and
+// removed when the block is orphaned), so the timestamp of the
+// parent of a block is always available unless syncing started
+// at the block.
+type TimestampWorker struct {
+	network        *types.NetworkIdentifier
+	fetcher        *fetcher.Fetcher
+	config         *configuration.TimestampValidation
+	failureStorage *failures.Storage
+
+	now            func() time.Time
+	tip            int64
+	lastTipRefresh time.Time
+}
+
+// NewTimestampWorker returns a new *TimestampWorker.
+func NewTimestampWorker(
+	network *types.NetworkIdentifier,
+	fetcher *fetcher.Fetcher,
+	config *configuration.TimestampValidation,
+	failureStorage *failures.Storage,
+) *TimestampWorker {
+	return &TimestampWorker{
+		network:        network,
+		fetcher:        fetcher,
+		config:         config,
+		failureStorage: failureStorage,
+		now:            time.Now,
+	}
+}
+
+func getTimestampKey(index int64) []byte {
+	return []byte(fmt.Sprintf("%s/%d", timestampNamespace, index))
+}
+
+// parentTimestamp returns a boolean indicating if the timestamp
+// of the block at index was stored and the timestamp.
+func (w *TimestampWorker) parentTimestamp(
+	ctx context.Context,
+	transaction storage.DatabaseTransaction,
+	index int64,
+) (bool, int64, error) {
+	exists, val, err := transaction.Get(ctx, getTimestampKey(index))
+	if err != nil {
+		return false, -1, fmt.Errorf("%w: unable to get timestamp record", err)
+	}
+
+	if !exists {
+		return false, -1, nil
+	}
+
+	if len(val) != timestampRecordSize {
+		return false, -1, fmt.Errorf("timestamp record for block %d is corrupt", index)
+	}
+
+	return true, int64(binary.BigEndian.Uint64(val)), nil
+}
+
+// nearTip returns a boolean indicating if the block at index
+// is within the tip window of the tip of the node. The tip is
+// fetched at most once every tipRefreshInterval.
+func (w *TimestampWorker) nearTip(ctx context.Context, index int64) (bool, error) {
+	if w.now().Sub(w.lastTipRefresh) >= tipRefreshInterval {
+		networkStatus, fetchErr := w.fetcher.NetworkStatusRetry(ctx, w.network, nil)
+		if fetchErr != nil {
+			return false, fmt.Errorf("%w: unable to get network status", fetchErr.Err)
+		}
+
+		w.tip = networkStatus.CurrentBlockIdentifier.Index
+		w.lastTipRefresh = w.now()
+	}
+
+	return index >= w.tip-w.config.TipWindow, nil
+}
+
+// violations returns the failures of all
+// timestamp requirements violated by block.
+func (w *TimestampWorker) violations(
+	ctx context.Context,
+	block *types.Block,
+	transaction storage.DatabaseTransaction,
+) ([]*failures.Failure, error) {
+	violations := []*failures.Failure{}
+	violation := func(bound int64, message string) {
+		violations = append(violations, &failures.Failure{
+			Kind:     failures.TimestampFailure,
+			Block:    block.BlockIdentifier,
+			Expected: strconv.FormatInt(bound, 10),
+			Actual:   strconv.FormatInt(block.Timestamp, 10),
+			Message:  message,
+		})
+	}
+
+	index := block.BlockIdentifier.Index
+	exists, parent, err := w.parentTimestamp(ctx, transaction, index-1)
+	if err != nil {
+		return nil, err
+	}
+
+	if exists && block.Timestamp < parent-w.config.Tolerance {
+		violation(parent-w.config.Tolerance, fmt.Sprintf(
+			"timestamp %d is before timestamp %d of parent block",
+			block.Timestamp,
+			parent,
+		))
+	}
+
+	now := w.now().UnixNano() / int64(time.Millisecond)
+	maxTimestamp := now + w.config.MaxFutureSkew*int64(time.Second/time.Millisecond)
+	if block.Timestamp > maxTimestamp {
+		violation(maxTimestamp, fmt.Sprintf(
+			"timestamp %d is more than %d seconds after the current time %d",
+			block.Timestamp,
+			w.config.MaxFutureSkew,
+			now,
+		))
+	}
+
+	minTimestamp := now - w.config.MaxTipLag*int64(time.Second/time.Millisecond)
+	if w.config.MaxTipLag > 0 && block.Timestamp < minTimestamp {
+		atTip, err := w.nearTip(ctx, index)
+		if err != nil {
+			return nil, err
+		}
+
+		if atTip {
+			violation(minTimestamp, fmt.Sprintf(
+				"timestamp %d of block near tip is more than %d seconds before the current time %d",
+				block.Timestamp,
+				w.config.MaxTipLag,
+				now,
+			))
+		}
+	}
+
+	return violations, nil
+}
+
+// AddingBlock records the timestamp of block and returns an error
+// listing every timestamp requirement violated by block.
+func (w *TimestampWorker) AddingBlock(
+	ctx context.Context,
+	block *types.Block,
+	transaction storage.DatabaseTransaction,
+) (storage.CommitWorker, error) {
+	violations, err := w.violations(ctx, block, transaction)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to check block timestamp", err)
+	}
+
+	val := make([]byte, timestampRecordSize)
+	binary.BigEndian.PutUint64(val, uint64(block.Timestamp))
+	if err := transaction.Set(
+		ctx,
+		getTimestampKey(block.BlockIdentifier.Index),
+		val,
+		true,
+	); err != nil {
+		return nil, fmt.Errorf("%w: unable to store timestamp record", err)
+	}
+
+	if len(violations) == 0 {
+		return nil, nil
+	}
+
+	messages := make([]string, len(violations))
+	for i, violation := range violations {
+		log.Printf(
+			"invalid timestamp in block %s:%d: %s\n",
+			block.BlockIdentifier.Hash,
+			block.BlockIdentifier.Index,
+			violation.Message,
+		)
+
+		// The block transaction is discarded when we return
+		// an error, so the failure must be deferred.
+		w.failureStorage.Defer(violation)
+		messages[i] = violation.Message
+	}
+
+	return nil, fmt.Errorf(
+		"%w: %d violations in block %s:%d [%s]",
+		results.ErrInvalidTimestamp,
+		len(violations),
+		block.BlockIdentifier.Hash,
+		block.BlockIdentifier.Index,
+		strings.Join(messages, "; "),
+	)
+}
+
+// RemovingBlock removes the timestamp record of block.
+func (w *TimestampWorker) RemovingBlock(
+	ctx context.Context,
+	block *types.Block,
+	transaction storage.DatabaseTransaction,
+) (storage.CommitWorker, error) {
+	if err := transaction.Delete(ctx, getTimestampKey(block.BlockIdentifier.Index)); err != nil {
+		return nil, fmt.Errorf("%w: unable to remove timestamp record", err)
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,166 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/coinbase/rosetta-cli/configuration"
+	"github.com/coinbase/rosetta-cli/pkg/failures"
+	"github.com/coinbase/rosetta-cli/pkg/results"
+
+	"github.com/coinbase/rosetta-sdk-go/asserter"
+	"github.com/coinbase/rosetta-sdk-go/fetcher"
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/coinbase/rosetta-sdk-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimestampWorker(t *testing.T) {
+	ctx := context.Background()
+	dir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(dir)
+
+	localStore, err := storage.NewBadgerStorage(
+		ctx,
+		dir,
+		storage.WithIndexCacheSize(storage.TinyIndexCacheSize),
+	)
+	assert.NoError(t, err)
+	defer localStore.Close(ctx)
+
+	network := &types.NetworkIdentifier{Blockchain: "blah", Network: "testnet"}
+	now := time.Unix(1600000000, 0)
+	nowMillis := now.UnixNano() / int64(time.Millisecond)
+
+	tip := int64(100)
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			w.Header().Set("Content-Type", "application/json")
+			assert.NoError(t, json.NewEncoder(w).Encode(&types.NetworkStatusResponse{
+				CurrentBlockIdentifier: &types.BlockIdentifier{
+					Hash:  fmt.Sprintf("block %d", tip),
+					Index: tip,
+				},
+				CurrentBlockTimestamp:  nowMillis,
+				GenesisBlockIdentifier: &types.BlockIdentifier{Hash: "block 0", Index: 0},
+				Peers:                  []*types.Peer{},
+			}))
+		},
+	))
+	defer server.Close()
+
+	a, err := asserter.NewClientWithOptions(
+		network,
+		&types.BlockIdentifier{Hash: "block 0", Index: 0},
+		[]string{"TRANSFER"},
+		[]*types.OperationStatus{{Status: "SUCCESS", Successful: true}},
+		[]*types.Error{},
+		nil,
+	)
+	assert.NoError(t, err)
+
+	w := NewTimestampWorker(
+		network,
+		fetcher.New(server.URL, fetcher.WithAsserter(a), fetcher.WithMaxRetries(0)),
+		&configuration.TimestampValidation{
+			Tolerance:     1000,
+			MaxFutureSkew: 60,
+			MaxTipLag:     3600,
+			TipWindow:     10,
+		},
+		failures.NewStorage(nil),
+	)
+	w.now = func() time.Time { return now }
+
+	testBlock := func(index int64, timestamp int64) *types.Block {
+		return &types.Block{
+			BlockIdentifier: &types.BlockIdentifier{
+				Hash:  fmt.Sprintf("block %d", index),
+				Index: index,
+			},
+			ParentBlockIdentifier: &types.BlockIdentifier{
+				Hash:  fmt.Sprintf("block %d", index-1),
+				Index: index - 1,
+			},
+			Timestamp: timestamp,
+		}
+	}
+
+	addBlock := func(block *types.Block) error {
+		dbTx := localStore.NewDatabaseTransaction(ctx, true)
+		defer dbTx.Discard(ctx)
+
+		if _, err := w.AddingBlock(ctx, block, dbTx); err != nil {
+			return err
+		}
+
+		return dbTx.Commit(ctx)
+	}
+
+	removeBlock := func(block *types.Block) {
+		dbTx := localStore.NewDatabaseTransaction(ctx, true)
+		defer dbTx.Discard(ctx)
+
+		_, err := w.RemovingBlock(ctx, block, dbTx)
+		assert.NoError(t, err)
+		assert.NoError(t, dbTx.Commit(ctx))
+	}
+
+	// Blocks far behind tip may lag behind the current time.
+	old := nowMillis - 7200000
+	assert.NoError(t, addBlock(testBlock(50, old)))
+	assert.Equal(t, 1, requests)
+
+	// Timestamps may decrease by the tolerance.
+	assert.NoError(t, addBlock(testBlock(51, old-1000)))
+
+	err = addBlock(testBlock(52, old-2001))
+	assert.True(t, errors.Is(err, results.ErrInvalidTimestamp))
+	assert.Contains(t, err.Error(), "before timestamp")
+
+	// Timestamps may not be too far in the future.
+	assert.NoError(t, addBlock(testBlock(52, nowMillis+60000)))
+	err = addBlock(testBlock(53, nowMillis+60001))
+	assert.True(t, errors.Is(err, results.ErrInvalidTimestamp))
+	assert.Contains(t, err.Error(), "after the current time")
+
+	// Orphaned blocks do not constrain their replacements.
+	removeBlock(testBlock(52, nowMillis+60000))
+	assert.NoError(t, addBlock(testBlock(52, old)))
+
+	// The tip is cached between refreshes.
+	err = addBlock(testBlock(95, old))
+	assert.True(t, errors.Is(err, results.ErrInvalidTimestamp))
+	assert.Contains(t, err.Error(), "near tip")
+	assert.Equal(t, 1, requests)
+
+	// Once the cached tip is stale, it is refreshed.
+	tip = 200
+	now = now.Add(tipRefreshInterval)
+	assert.NoError(t, addBlock(testBlock(95, old)))
+	assert.Equal(t, 2, requests)
+}
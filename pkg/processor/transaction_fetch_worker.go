@@ -0,0 +1,169 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+
+	"github.com/coinbase/rosetta-cli/pkg/failures"
+
+	"github.com/coinbase/rosetta-sdk-go/fetcher"
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+var _ storage.BlockWorker = (*TransactionFetchWorker)(nil)
+
+// ErrTransactionFetchMismatch is returned when a transaction
+// fetched with /block/transaction is not equal to the same
+// transaction included in /block.
+var ErrTransactionFetchMismatch = errors.New(
+	"transaction fetched individually does not match transaction in block",
+)
+
+// TransactionFetchWorker implements the storage.BlockWorker
+// interface and fetches a random sample of the transactions in
+// each synced block individually (with /block/transaction) to
+// ensure they are equal to the transactions included in the
+// /block response. Many implementations populate these responses
+// with different code paths.
+type TransactionFetchWorker struct {
+	network        *types.NetworkIdentifier
+	fetcher        *fetcher.Fetcher
+	samples        int
+	failureStorage *failures.Storage
+}
+
+// NewTransactionFetchWorker returns a new *TransactionFetchWorker
+// that verifies up to samples transactions in each block.
+func NewTransactionFetchWorker(
+	network *types.NetworkIdentifier,
+	fetcher *fetcher.Fetcher,
+	samples uint64,
+	failureStorage *failures.Storage,
+) *TransactionFetchWorker {
+	return &TransactionFetchWorker{
+		network:        network,
+		fetcher:        fetcher,
+		samples:        int(samples),
+		failureStorage: failureStorage,
+	}
+}
+
+// sampleTransactions returns up to w.samples
+// randomly selected transactions in block.
+func (w *TransactionFetchWorker) sampleTransactions(block *types.Block) []*types.Transaction {
+	if len(block.Transactions) <= w.samples {
+		return block.Transactions
+	}
+
+	sampled := make([]*types.Transaction, w.samples)
+	for i, j := range rand.Perm(len(block.Transactions))[:w.samples] { // #nosec G404
+		sampled[i] = block.Transactions[j]
+	}
+
+	return sampled
+}
+
+// AddingBlock fetches a sample of the transactions in block
+// individually and returns an error if any of them are not
+// equal to the transaction in block.
+func (w *TransactionFetchWorker) AddingBlock(
+	ctx context.Context,
+	block *types.Block,
+	transaction storage.DatabaseTransaction,
+) (storage.CommitWorker, error) {
+	sampled := w.sampleTransactions(block)
+	if len(sampled) == 0 {
+		return nil, nil
+	}
+
+	identifiers := make([]*types.TransactionIdentifier, len(sampled))
+	for i, tx := range sampled {
+		identifiers[i] = tx.TransactionIdentifier
+	}
+
+	fetched, fetchErr := w.fetcher.UnsafeTransactions(
+		ctx,
+		w.network,
+		block.BlockIdentifier,
+		identifiers,
+	)
+	if fetchErr != nil {
+		return nil, fmt.Errorf(
+			"%w: unable to fetch transactions in block %s:%d",
+			fetchErr.Err,
+			block.BlockIdentifier.Hash,
+			block.BlockIdentifier.Index,
+		)
+	}
+
+	// Transactions may not be returned in
+	// the order they were requested.
+	fetchedTxs := map[string]*types.Transaction{}
+	for _, tx := range fetched {
+		if err := w.fetcher.Asserter.Transaction(tx); err != nil {
+			return nil, fmt.Errorf("%w: transaction fetched individually is invalid", err)
+		}
+
+		fetchedTxs[tx.TransactionIdentifier.Hash] = tx
+	}
+
+	mismatches := 0
+	for _, tx := range sampled {
+		fetchedTx := fetchedTxs[tx.TransactionIdentifier.Hash]
+		if types.Hash(fetchedTx) == types.Hash(tx) {
+			continue
+		}
+
+		mismatches++
+		log.Printf("transaction in block: %s\n", types.PrintStruct(tx))
+		log.Printf("transaction fetched individually: %s\n", types.PrintStruct(fetchedTx))
+		w.failureStorage.Defer(&failures.Failure{
+			Kind:        failures.TransactionFetchMismatchFailure,
+			Block:       block.BlockIdentifier,
+			Transaction: tx.TransactionIdentifier,
+			Expected:    types.Hash(tx),
+			Actual:      types.Hash(fetchedTx),
+			Message:     ErrTransactionFetchMismatch.Error(),
+		})
+	}
+
+	if mismatches == 0 {
+		return nil, nil
+	}
+
+	return nil, fmt.Errorf(
+		"%w: %d of %d sampled transactions in block %s:%d",
+		ErrTransactionFetchMismatch,
+		mismatches,
+		len(sampled),
+		block.BlockIdentifier.Hash,
+		block.BlockIdentifier.Index,
+	)
+}
+
+// RemovingBlock is a no-op.
+func (w *TransactionFetchWorker) RemovingBlock(
+	ctx context.Context,
+	block *types.Block,
+	transaction storage.DatabaseTransaction,
+) (storage.CommitWorker, error) {
+	return nil, nil
+}
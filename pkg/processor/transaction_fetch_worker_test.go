@@ -0,0 +1,149 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/coinbase/rosetta-cli/pkg/failures"
+
+	"github.com/coinbase/rosetta-sdk-go/asserter"
+	"github.com/coinbase/rosetta-sdk-go/fetcher"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func transferTransaction(hash string, value string) *types.Transaction {
+	return &types.Transaction{
+		TransactionIdentifier: &types.TransactionIdentifier{Hash: hash},
+		Operations: []*types.Operation{
+			{
+				OperationIdentifier: &types.OperationIdentifier{Index: 0},
+				Type:                "TRANSFER",
+				Status:              types.String("SUCCESS"),
+				Account:             &types.AccountIdentifier{Address: "addr1"},
+				Amount: &types.Amount{
+					Value:    value,
+					Currency: &types.Currency{Symbol: "BTC", Decimals: 8},
+				},
+			},
+		},
+	}
+}
+
+func TestTransactionFetchWorker(t *testing.T) {
+	network := &types.NetworkIdentifier{Blockchain: "blah", Network: "testnet"}
+	block := &types.Block{
+		BlockIdentifier:       &types.BlockIdentifier{Hash: "block 10", Index: 10},
+		ParentBlockIdentifier: &types.BlockIdentifier{Hash: "block 9", Index: 9},
+		Timestamp:             asserter.MinUnixEpoch + 1,
+		Transactions: []*types.Transaction{
+			transferTransaction("tx1", "100"),
+			transferTransaction("tx2", "200"),
+			transferTransaction("tx3", "300"),
+		},
+	}
+
+	var tests = map[string]struct {
+		samples uint64
+		served  map[string]*types.Transaction
+
+		requests int64
+		err      error
+	}{
+		"matching transactions": {
+			samples: 5,
+			served: map[string]*types.Transaction{
+				"tx1": transferTransaction("tx1", "100"),
+				"tx2": transferTransaction("tx2", "200"),
+				"tx3": transferTransaction("tx3", "300"),
+			},
+			requests: 3,
+		},
+		"sampled transactions": {
+			samples: 2,
+			served: map[string]*types.Transaction{
+				"tx1": transferTransaction("tx1", "100"),
+				"tx2": transferTransaction("tx2", "200"),
+				"tx3": transferTransaction("tx3", "300"),
+			},
+			requests: 2,
+		},
+		"divergent transaction": {
+			samples: 5,
+			served: map[string]*types.Transaction{
+				"tx1": transferTransaction("tx1", "100"),
+				"tx2": transferTransaction("tx2", "201"),
+				"tx3": transferTransaction("tx3", "300"),
+			},
+			requests: 3,
+			err:      ErrTransactionFetchMismatch,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			// Transactions are fetched concurrently.
+			var requests int64
+			server := httptest.NewServer(http.HandlerFunc(
+				func(w http.ResponseWriter, r *http.Request) {
+					var request types.BlockTransactionRequest
+					assert.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+					assert.Equal(t, "/block/transaction", r.URL.Path)
+					assert.Equal(t, block.BlockIdentifier, request.BlockIdentifier)
+
+					atomic.AddInt64(&requests, 1)
+					w.Header().Set("Content-Type", "application/json")
+					assert.NoError(t, json.NewEncoder(w).Encode(&types.BlockTransactionResponse{
+						Transaction: test.served[request.TransactionIdentifier.Hash],
+					}))
+				},
+			))
+			defer server.Close()
+
+			a, err := asserter.NewClientWithOptions(
+				network,
+				&types.BlockIdentifier{Hash: "block 0", Index: 0},
+				[]string{"TRANSFER"},
+				[]*types.OperationStatus{{Status: "SUCCESS", Successful: true}},
+				[]*types.Error{},
+				nil,
+			)
+			assert.NoError(t, err)
+
+			w := NewTransactionFetchWorker(
+				network,
+				fetcher.New(server.URL, fetcher.WithAsserter(a), fetcher.WithMaxRetries(0)),
+				test.samples,
+				failures.NewStorage(nil),
+			)
+
+			_, err = w.AddingBlock(context.Background(), block, nil)
+			if test.err != nil {
+				assert.True(t, errors.Is(err, test.err))
+			} else {
+				assert.NoError(t, err)
+			}
+
+			assert.Equal(t, test.requests, atomic.LoadInt64(&requests))
+		})
+	}
+}
@@ -0,0 +1,219 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provenance
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/coinbase/rosetta-cli/configuration"
+	"github.com/coinbase/rosetta-cli/pkg/upload"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/coinbase/rosetta-sdk-go/utils"
+	"github.com/olekukonko/tablewriter"
+)
+
+const (
+	// CLIVersion is the version of the rosetta-cli.
+	CLIVersion = "v0.6.0"
+
+	// RunFile is the name of the file containing the
+	// run that produced the files in a directory (like
+	// the files written by export:blocks).
+	RunFile = "run.json"
+
+	// runsDirectory is the directory (in the data
+	// directory) where all runs are stored.
+	runsDirectory = "runs"
+
+	// runFileExtension is the extension of each
+	// stored run.
+	runFileExtension = ".json"
+)
+
+// Run describes a single invocation of a command and is
+// stamped on all artifacts produced by it (results files,
+// log streams, and exports) so that every claim made from
+// those artifacts can be traced back to the exact CLI
+// version, configuration, and node that produced it.
+type Run struct {
+	ID         string                   `json:"run_id"`
+	Command    string                   `json:"command"`
+	CLIVersion string                   `json:"cli_version"`
+	ConfigHash string                   `json:"config_hash"`
+	Network    *types.NetworkIdentifier `json:"network_identifier,omitempty"`
+
+	// Version is the version returned by /network/options
+	// (only populated if the node was queried).
+	Version *types.Version `json:"version,omitempty"`
+
+	StartTime time.Time  `json:"start_time"`
+	EndTime   *time.Time `json:"end_time,omitempty"`
+	Error     string     `json:"error,omitempty"`
+}
+
+// New returns a new *Run of command started at start.
+// The run ID is the same as the ID used to upload the
+// artifacts of the run (see upload.RunID).
+func New(
+	command string,
+	config *configuration.Configuration,
+	version *types.Version,
+	start time.Time,
+) *Run {
+	return &Run{
+		ID:         upload.RunID(start),
+		Command:    command,
+		CLIVersion: CLIVersion,
+		ConfigHash: ConfigHash(config),
+		Network:    config.Network,
+		Version:    version,
+		StartTime:  start.UTC(),
+	}
+}
+
+// ConfigHash returns the hash of the JSON encoding of
+// config (after defaults and any profile are applied).
+func ConfigHash(config *configuration.Configuration) string {
+	return types.Hash(config)
+}
+
+// Finish records the time the run ended and
+// the error it exited with (if any).
+func (r *Run) Finish(end time.Time, err error) {
+	end = end.UTC()
+	r.EndTime = &end
+	if err != nil {
+		r.Error = err.Error()
+	}
+}
+
+// Header returns r as a single line of JSON that
+// is written at the start of each log stream.
+func (r *Run) Header() (string, error) {
+	encoded, err := json.Marshal(map[string]*Run{"run": r})
+	if err != nil {
+		return "", fmt.Errorf("%w: unable to encode run", err)
+	}
+
+	return string(encoded) + "\n", nil
+}
+
+// RunsPath returns the directory in dataDirectory
+// where runs are stored.
+func RunsPath(dataDirectory string) string {
+	return path.Join(dataDirectory, runsDirectory)
+}
+
+// Write writes r to filePath.
+func (r *Run) Write(filePath string) error {
+	if err := utils.SerializeAndWrite(filePath, r); err != nil {
+		return fmt.Errorf("%w: unable to write run %s", err, r.ID)
+	}
+
+	return nil
+}
+
+// Save stores r in the runs directory of dataDirectory
+// (overwriting any previously stored copy of r).
+func (r *Run) Save(dataDirectory string) error {
+	dir := RunsPath(dataDirectory)
+	if err := utils.EnsurePathExists(dir); err != nil {
+		return fmt.Errorf("%w: unable to create runs directory", err)
+	}
+
+	return r.Write(path.Join(dir, r.ID+runFileExtension))
+}
+
+// List returns all runs stored in dataDirectory
+// (sorted by start time).
+func List(dataDirectory string) ([]*Run, error) {
+	dir := RunsPath(dataDirectory)
+	files, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return []*Run{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to read runs directory", err)
+	}
+
+	runs := []*Run{}
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), runFileExtension) {
+			continue
+		}
+
+		var run Run
+		if err := utils.LoadAndParse(path.Join(dir, file.Name()), &run); err != nil {
+			return nil, fmt.Errorf("%w: unable to load run %s", err, file.Name())
+		}
+
+		runs = append(runs, &run)
+	}
+
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].StartTime.Before(runs[j].StartTime)
+	})
+
+	return runs, nil
+}
+
+// Print logs runs to the console as a table.
+func Print(runs []*Run) {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetRowLine(true)
+	table.SetRowSeparator("-")
+	table.SetHeader([]string{
+		"Run ID",
+		"Command",
+		"CLI Version",
+		"Node Version",
+		"Config Hash",
+		"Start",
+		"End",
+		"Error",
+	})
+	for _, run := range runs {
+		nodeVersion := ""
+		if run.Version != nil {
+			nodeVersion = run.Version.NodeVersion
+		}
+
+		end := "UNFINISHED"
+		if run.EndTime != nil {
+			end = run.EndTime.Format(time.RFC3339)
+		}
+
+		table.Append([]string{
+			run.ID,
+			run.Command,
+			run.CLIVersion,
+			nodeVersion,
+			run.ConfigHash,
+			run.StartTime.Format(time.RFC3339),
+			end,
+			run.Error,
+		})
+	}
+
+	table.Render()
+}
@@ -0,0 +1,77 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provenance
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/coinbase/rosetta-cli/configuration"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/coinbase/rosetta-sdk-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRuns(t *testing.T) {
+	dir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(dir)
+
+	runs, err := List(dir)
+	assert.NoError(t, err)
+	assert.Len(t, runs, 0)
+
+	config := configuration.DefaultConfiguration()
+	version := &types.Version{RosettaVersion: "1.4.10", NodeVersion: "0.21.0"}
+	start := time.Date(2020, 11, 1, 12, 0, 0, 0, time.UTC)
+
+	first := New("check:data", config, version, start)
+	assert.Equal(t, "20201101T120000Z", first.ID)
+	assert.Equal(t, CLIVersion, first.CLIVersion)
+	assert.Equal(t, ConfigHash(config), first.ConfigHash)
+	assert.Equal(t, config.Network, first.Network)
+	assert.NoError(t, first.Save(dir))
+
+	// Changing the configuration changes the config hash.
+	config.MaxSyncConcurrency++
+	second := New("check:data", config, version, start.Add(time.Hour))
+	assert.NotEqual(t, first.ConfigHash, second.ConfigHash)
+	second.Finish(start.Add(2*time.Hour), errors.New("reconciliation failure"))
+	assert.NoError(t, second.Save(dir))
+
+	first.Finish(start.Add(30*time.Minute), nil)
+	assert.NoError(t, first.Save(dir))
+
+	runs, err = List(dir)
+	assert.NoError(t, err)
+	assert.Len(t, runs, 2)
+	assert.Equal(t, first.ID, runs[0].ID)
+	assert.Equal(t, start.Add(30*time.Minute), *runs[0].EndTime)
+	assert.Empty(t, runs[0].Error)
+	assert.Equal(t, second.ID, runs[1].ID)
+	assert.Equal(t, "reconciliation failure", runs[1].Error)
+	assert.Equal(t, version, runs[1].Version)
+
+	header, err := first.Header()
+	assert.NoError(t, err)
+	assert.Equal(t, byte('\n'), header[len(header)-1])
+
+	var decoded map[string]*Run
+	assert.NoError(t, json.Unmarshal([]byte(header), &decoded))
+	assert.Equal(t, first.ConfigHash, decoded["run"].ConfigHash)
+}
@@ -0,0 +1,218 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package quorum
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+
+	"github.com/coinbase/rosetta-cli/pkg/failures"
+
+	"github.com/coinbase/rosetta-sdk-go/fetcher"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/fatih/color"
+)
+
+var (
+	// ErrQuorumNotReached is returned when a majority of
+	// endpoints do not return the same block.
+	ErrQuorumNotReached = errors.New("quorum not reached")
+)
+
+// BlockFetcher fetches a block from a single endpoint.
+type BlockFetcher interface {
+	Block(
+		ctx context.Context,
+		network *types.NetworkIdentifier,
+		block *types.PartialBlockIdentifier,
+	) (*types.Block, error)
+}
+
+// Endpoint is a Rosetta API implementation
+// blocks are fetched from.
+type Endpoint struct {
+	URL     string
+	Fetcher BlockFetcher
+}
+
+// NewEndpoint returns an *Endpoint that
+// fetches blocks with f (retrying errors).
+func NewEndpoint(url string, f *fetcher.Fetcher) *Endpoint {
+	return &Endpoint{URL: url, Fetcher: &retryFetcher{fetcher: f}}
+}
+
+// retryFetcher adapts *fetcher.Fetcher
+// to the BlockFetcher interface.
+type retryFetcher struct {
+	fetcher *fetcher.Fetcher
+}
+
+func (r *retryFetcher) Block(
+	ctx context.Context,
+	network *types.NetworkIdentifier,
+	block *types.PartialBlockIdentifier,
+) (*types.Block, error) {
+	b, fetchErr := r.fetcher.BlockRetry(ctx, network, block)
+	if fetchErr != nil {
+		return nil, fetchErr.Err
+	}
+
+	return b, nil
+}
+
+// Fetcher fetches each block from size endpoints (rotating
+// through all endpoints) and only returns a block when a
+// majority of these endpoints return it. Endpoints that
+// return a different block are recorded as failures.
+type Fetcher struct {
+	endpoints      []*Endpoint
+	size           int
+	failureStorage *failures.Storage
+
+	next uint64
+}
+
+// New returns a new *Fetcher.
+func New(
+	endpoints []*Endpoint,
+	size int,
+	failureStorage *failures.Storage,
+) *Fetcher {
+	return &Fetcher{
+		endpoints:      endpoints,
+		size:           size,
+		failureStorage: failureStorage,
+	}
+}
+
+// response is the response of a
+// single endpoint to a block request.
+type response struct {
+	endpoint *Endpoint
+	block    *types.Block
+	err      error
+}
+
+// selectEndpoints returns the next size endpoints
+// so that requests are spread across all endpoints.
+func (f *Fetcher) selectEndpoints() []*Endpoint {
+	start := int(atomic.AddUint64(&f.next, 1) - 1)
+	selected := make([]*Endpoint, f.size)
+	for i := range selected {
+		selected[i] = f.endpoints[(start+i)%len(f.endpoints)]
+	}
+
+	return selected
+}
+
+// Block fetches block from multiple endpoints and returns
+// the block returned by a majority of endpoints.
+func (f *Fetcher) Block(
+	ctx context.Context,
+	network *types.NetworkIdentifier,
+	block *types.PartialBlockIdentifier,
+) (*types.Block, error) {
+	selected := f.selectEndpoints()
+	responses := make([]*response, len(selected))
+
+	var wg sync.WaitGroup
+	for i, endpoint := range selected {
+		wg.Add(1)
+		go func(i int, endpoint *Endpoint) {
+			defer wg.Done()
+
+			b, err := endpoint.Fetcher.Block(ctx, network, block)
+			responses[i] = &response{endpoint: endpoint, block: b, err: err}
+		}(i, endpoint)
+	}
+	wg.Wait()
+
+	// Blocks are compared by the hash of their
+	// contents (a nil block is an omitted block).
+	votes := map[string]int{}
+	var majority *response
+	var majorityKey string
+	for _, r := range responses {
+		if r.err != nil {
+			log.Printf("%s: unable to fetch block from %s\n", r.err.Error(), r.endpoint.URL)
+			continue
+		}
+
+		key := types.Hash(r.block)
+		votes[key]++
+		if votes[key] > f.size/2 {
+			majority = r
+			majorityKey = key
+		}
+	}
+
+	if majority == nil {
+		return nil, fmt.Errorf(
+			"%w: %d endpoints returned %d different blocks for %s",
+			ErrQuorumNotReached,
+			f.size,
+			len(votes),
+			types.PrintStruct(block),
+		)
+	}
+
+	for _, r := range responses {
+		if r.err != nil || types.Hash(r.block) == majorityKey {
+			continue
+		}
+
+		f.recordDissent(ctx, majority, r)
+	}
+
+	return majority.block, nil
+}
+
+// recordDissent logs and records a failure for an
+// endpoint that returned a different block than
+// the majority of endpoints.
+func (f *Fetcher) recordDissent(ctx context.Context, majority *response, dissent *response) {
+	var blockIdentifier, dissentIdentifier *types.BlockIdentifier
+	if majority.block != nil {
+		blockIdentifier = majority.block.BlockIdentifier
+	}
+	if dissent.block != nil {
+		dissentIdentifier = dissent.block.BlockIdentifier
+	}
+
+	color.Yellow(
+		"%s returned %s instead of %s",
+		dissent.endpoint.URL,
+		types.PrintStruct(dissentIdentifier),
+		types.PrintStruct(blockIdentifier),
+	)
+
+	// Blocks are fetched outside of the block transaction,
+	// so the failure is recorded in its own transaction.
+	err := f.failureStorage.Record(ctx, &failures.Failure{
+		Kind:     failures.QuorumDissentFailure,
+		Block:    blockIdentifier,
+		Expected: types.Hash(majority.block),
+		Actual:   types.Hash(dissent.block),
+		Context:  map[string]string{"endpoint": dissent.endpoint.URL},
+		Message:  fmt.Sprintf("%s returned a block that differs from the majority", dissent.endpoint.URL),
+	})
+	if err != nil {
+		log.Printf("%s: unable to record quorum dissent\n", err.Error())
+	}
+}
@@ -0,0 +1,152 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package quorum
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/coinbase/rosetta-cli/pkg/failures"
+
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/coinbase/rosetta-sdk-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+// staticFetcher returns the same
+// block (or error) for all requests.
+type staticFetcher struct {
+	block *types.Block
+	err   error
+}
+
+func (f *staticFetcher) Block(
+	context.Context,
+	*types.NetworkIdentifier,
+	*types.PartialBlockIdentifier,
+) (*types.Block, error) {
+	return f.block, f.err
+}
+
+func testBlock(hash string) *types.Block {
+	return &types.Block{
+		BlockIdentifier:       &types.BlockIdentifier{Hash: hash, Index: 1},
+		ParentBlockIdentifier: &types.BlockIdentifier{Hash: "block 0", Index: 0},
+	}
+}
+
+func TestFetcher(t *testing.T) {
+	ctx := context.Background()
+	network := &types.NetworkIdentifier{Blockchain: "Bitcoin", Network: "Testnet"}
+	index := int64(1)
+	request := &types.PartialBlockIdentifier{Index: &index}
+
+	good := testBlock("block 1")
+	bad := testBlock("block 1a")
+
+	var tests = map[string]struct {
+		fetchers []*staticFetcher
+		size     int
+
+		block    *types.Block
+		err      error
+		dissents int
+	}{
+		"all agree": {
+			fetchers: []*staticFetcher{{block: good}, {block: good}, {block: good}},
+			size:     3,
+			block:    good,
+		},
+		"majority agrees": {
+			fetchers: []*staticFetcher{{block: good}, {block: bad}, {block: good}},
+			size:     3,
+			block:    good,
+			dissents: 1,
+		},
+		"majority agrees with error": {
+			fetchers: []*staticFetcher{{block: good}, {err: errors.New("timeout")}, {block: good}},
+			size:     3,
+			block:    good,
+		},
+		"no majority": {
+			fetchers: []*staticFetcher{{block: good}, {block: bad}, {err: errors.New("timeout")}},
+			size:     3,
+			err:      ErrQuorumNotReached,
+		},
+		"no majority of 2": {
+			fetchers: []*staticFetcher{{block: good}, {block: bad}},
+			size:     2,
+			err:      ErrQuorumNotReached,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			dir, err := utils.CreateTempDir()
+			assert.NoError(t, err)
+			defer utils.RemoveTempDir(dir)
+
+			database, err := storage.NewBadgerStorage(ctx, dir)
+			assert.NoError(t, err)
+			defer database.Close(ctx)
+
+			endpoints := make([]*Endpoint, len(test.fetchers))
+			for i, f := range test.fetchers {
+				endpoints[i] = &Endpoint{URL: string(rune('a' + i)), Fetcher: f}
+			}
+
+			failureStorage := failures.NewStorage(database)
+			f := New(endpoints, test.size, failureStorage)
+			block, err := f.Block(ctx, network, request)
+			if test.err != nil {
+				assert.True(t, errors.Is(err, test.err))
+				assert.Nil(t, block)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, test.block, block)
+			}
+
+			// Dissents are persisted without being flushed.
+			recorded, err := failureStorage.GetAll(ctx)
+			assert.NoError(t, err)
+			assert.Len(t, recorded, test.dissents)
+			for _, failure := range recorded {
+				assert.Equal(t, failures.QuorumDissentFailure, failure.Kind)
+				assert.Equal(t, "b", failure.Context["endpoint"])
+				assert.Equal(t, types.Hash(bad), failure.Actual)
+			}
+		})
+	}
+}
+
+func TestSelectEndpoints(t *testing.T) {
+	endpoints := []*Endpoint{{URL: "a"}, {URL: "b"}, {URL: "c"}}
+	f := New(endpoints, 2, nil)
+
+	urls := func(selected []*Endpoint) []string {
+		s := make([]string, len(selected))
+		for i, endpoint := range selected {
+			s[i] = endpoint.URL
+		}
+
+		return s
+	}
+
+	assert.Equal(t, []string{"a", "b"}, urls(f.selectEndpoints()))
+	assert.Equal(t, []string{"b", "c"}, urls(f.selectEndpoints()))
+	assert.Equal(t, []string{"c", "a"}, urls(f.selectEndpoints()))
+}
@@ -19,7 +19,9 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"sort"
 	"strconv"
+	"strings"
 
 	"github.com/coinbase/rosetta-cli/configuration"
 
@@ -75,9 +77,10 @@ func ComputeCheckConstructionResults(
 	err error,
 	counterStorage *storage.CounterStorage,
 	jobStorage *storage.JobStorage,
+	operationTypes []string,
 ) *CheckConstructionResults {
 	ctx := context.Background()
-	stats := ComputeCheckConstructionStats(ctx, cfg, counterStorage, jobStorage)
+	stats := ComputeCheckConstructionStats(ctx, cfg, counterStorage, jobStorage, operationTypes)
 	results := &CheckConstructionResults{
 		Stats: stats,
 	}
@@ -105,6 +108,16 @@ type CheckConstructionStats struct {
 	AddressesCreated      int64 `json:"addresses_created"`
 
 	WorkflowsCompleted map[string]int64 `json:"workflows_completed"`
+
+	// OperationTypes is the number of operations of each
+	// type supported by the network (in /network/options)
+	// in confirmed transactions.
+	OperationTypes map[string]int64 `json:"operation_types,omitempty"`
+
+	// UncoveredOperationTypes are the operation types
+	// supported by the network that were not in any
+	// confirmed transaction.
+	UncoveredOperationTypes []string `json:"uncovered_operation_types,omitempty"`
 }
 
 // PrintCounts logs counter-related stats to the console.
@@ -158,19 +171,55 @@ func (c *CheckConstructionStats) PrintWorkflows() {
 	table.Render()
 }
 
-// Print calls PrintCounts and PrintWorkflows.
+// PrintOperationTypes logs operation type coverage to the console.
+func (c *CheckConstructionStats) PrintOperationTypes() {
+	if len(c.OperationTypes) == 0 {
+		return
+	}
+
+	opTypes := make([]string, 0, len(c.OperationTypes))
+	for opType := range c.OperationTypes {
+		opTypes = append(opTypes, opType)
+	}
+	sort.Strings(opTypes)
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetRowLine(true)
+	table.SetRowSeparator("-")
+	table.SetHeader([]string{"check:construction Operation Types", "Count"})
+	for _, opType := range opTypes {
+		table.Append([]string{
+			opType,
+			strconv.FormatInt(c.OperationTypes[opType], 10),
+		})
+	}
+
+	table.Render()
+
+	if len(c.UncoveredOperationTypes) > 0 {
+		color.Yellow(
+			"Operation types never confirmed: %s",
+			strings.Join(c.UncoveredOperationTypes, ", "),
+		)
+	}
+}
+
+// Print calls PrintCounts, PrintWorkflows, and PrintOperationTypes.
 func (c *CheckConstructionStats) Print() {
 	c.PrintCounts()
 	c.PrintWorkflows()
+	c.PrintOperationTypes()
 }
 
 // ComputeCheckConstructionStats returns a populated
-// CheckConstructionStats.
+// CheckConstructionStats. Coverage is computed for
+// each of operationTypes (if any are provided).
 func ComputeCheckConstructionStats(
 	ctx context.Context,
 	config *configuration.Configuration,
 	counters *storage.CounterStorage,
 	jobs *storage.JobStorage,
+	operationTypes []string,
 ) *CheckConstructionStats {
 	if counters == nil || jobs == nil {
 		return nil
@@ -217,7 +266,7 @@ func ComputeCheckConstructionStats(
 		workflowsCompleted[workflow.Name] = int64(len(completed))
 	}
 
-	return &CheckConstructionStats{
+	stats := &CheckConstructionStats{
 		TransactionsCreated:   transactionsCreated.Int64(),
 		TransactionsConfirmed: transactionsConfirmed.Int64(),
 		StaleBroadcasts:       staleBroadcasts.Int64(),
@@ -225,6 +274,25 @@ func ComputeCheckConstructionStats(
 		AddressesCreated:      addressesCreated.Int64(),
 		WorkflowsCompleted:    workflowsCompleted,
 	}
+
+	for _, opType := range operationTypes {
+		count, err := counters.Get(ctx, ConstructionOperationCounter(opType))
+		if err != nil {
+			log.Printf("%s cannot get operation count for %s\n", err.Error(), opType)
+			return nil
+		}
+
+		if stats.OperationTypes == nil {
+			stats.OperationTypes = map[string]int64{}
+		}
+		stats.OperationTypes[opType] = count.Int64()
+
+		if count.Sign() == 0 {
+			stats.UncoveredOperationTypes = append(stats.UncoveredOperationTypes, opType)
+		}
+	}
+
+	return stats
 }
 
 // CheckConstructionProgress contains the number of
@@ -274,9 +342,10 @@ func ComputeCheckConstructionStatus(
 	counters *storage.CounterStorage,
 	broadcasts *storage.BroadcastStorage,
 	jobs *storage.JobStorage,
+	operationTypes []string,
 ) *CheckConstructionStatus {
 	return &CheckConstructionStatus{
-		Stats:    ComputeCheckConstructionStats(ctx, config, counters, jobs),
+		Stats:    ComputeCheckConstructionStats(ctx, config, counters, jobs, operationTypes),
 		Progress: ComputeCheckConstructionProgress(ctx, broadcasts, jobs),
 	}
 }
@@ -297,6 +366,7 @@ func ExitConstruction(
 	config *configuration.Configuration,
 	counterStorage *storage.CounterStorage,
 	jobStorage *storage.JobStorage,
+	operationTypes []string,
 	err error,
 ) error {
 	results := ComputeCheckConstructionResults(
@@ -304,6 +374,7 @@ func ExitConstruction(
 		err,
 		counterStorage,
 		jobStorage,
+		operationTypes,
 	)
 	if results != nil {
 		results.Print()
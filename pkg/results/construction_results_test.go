@@ -0,0 +1,83 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package results
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/coinbase/rosetta-cli/configuration"
+
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/coinbase/rosetta-sdk-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestComputeCheckConstructionStatsOperationTypes(t *testing.T) {
+	ctx := context.Background()
+	dir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(dir)
+
+	localStore, err := storage.NewBadgerStorage(ctx, dir)
+	assert.NoError(t, err)
+	defer localStore.Close(ctx)
+
+	counterStorage := storage.NewCounterStorage(localStore)
+	jobStorage := storage.NewJobStorage(localStore)
+	_, err = counterStorage.Update(ctx, ConstructionOperationCounter("TRANSFER"), big.NewInt(4))
+	assert.NoError(t, err)
+
+	cfg := &configuration.Configuration{
+		Construction: &configuration.ConstructionConfiguration{},
+	}
+
+	var tests = map[string]struct {
+		operationTypes []string
+
+		expectedOperationTypes map[string]int64
+		expectedUncovered      []string
+	}{
+		"no operation types": {},
+		"partial coverage": {
+			operationTypes: []string{"TRANSFER", "STAKE", "UNSTAKE"},
+			expectedOperationTypes: map[string]int64{
+				"TRANSFER": 4,
+				"STAKE":    0,
+				"UNSTAKE":  0,
+			},
+			expectedUncovered: []string{"STAKE", "UNSTAKE"},
+		},
+		"full coverage": {
+			operationTypes:         []string{"TRANSFER"},
+			expectedOperationTypes: map[string]int64{"TRANSFER": 4},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			stats := ComputeCheckConstructionStats(
+				ctx,
+				cfg,
+				counterStorage,
+				jobStorage,
+				test.operationTypes,
+			)
+			assert.Equal(t, test.expectedOperationTypes, stats.OperationTypes)
+			assert.Equal(t, test.expectedUncovered, stats.UncoveredOperationTypes)
+		})
+	}
+}
@@ -21,9 +21,14 @@ import (
 	"log"
 	"math/big"
 	"os"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/coinbase/rosetta-cli/configuration"
+	"github.com/coinbase/rosetta-cli/pkg/opstats"
+	"github.com/coinbase/rosetta-cli/pkg/provenance"
 
 	"github.com/coinbase/rosetta-sdk-go/asserter"
 	"github.com/coinbase/rosetta-sdk-go/fetcher"
@@ -53,10 +58,23 @@ type EndCondition struct {
 // on a check:data run, the outcome of certain tests,
 // and a collection of interesting stats.
 type CheckDataResults struct {
-	Error        string          `json:"error"`
-	EndCondition *EndCondition   `json:"end_condition"`
-	Tests        *CheckDataTests `json:"tests"`
-	Stats        *CheckDataStats `json:"stats"`
+	Error           string            `json:"error"`
+	EndCondition    *EndCondition     `json:"end_condition"`
+	Tests           *CheckDataTests   `json:"tests"`
+	Stats           *CheckDataStats   `json:"stats"`
+	DegradedWorkers []*DegradedWorker `json:"degraded_workers,omitempty"`
+
+	// ReconciliationFailures are all reconciliation failures
+	// collected towards the reconciliation failure budget.
+	ReconciliationFailures []*ReconciliationStatus `json:"reconciliation_failures,omitempty"`
+
+	// OperationStats are the statistics of each operation
+	// type (only populated if operation stats are enabled).
+	OperationStats []*opstats.OperationStats `json:"operation_stats,omitempty"`
+
+	// Run is the run that produced the results (only
+	// populated if the run was recorded).
+	Run *provenance.Run `json:"run,omitempty"`
 }
 
 // Print logs CheckDataResults to the console.
@@ -80,6 +98,80 @@ func (c *CheckDataResults) Print() {
 		c.Stats.Print()
 		fmt.Printf("\n")
 	}
+	if len(c.DegradedWorkers) > 0 {
+		printDegradedWorkers(c.DegradedWorkers)
+		fmt.Printf("\n")
+	}
+	if len(c.ReconciliationFailures) > 0 {
+		printReconciliationFailures(c.ReconciliationFailures)
+		fmt.Printf("\n")
+	}
+	if len(c.OperationStats) > 0 {
+		opstats.Print(c.OperationStats)
+		fmt.Printf("\n")
+	}
+}
+
+// DegradedWorker describes an optional block worker
+// that returned errors during a check:data run.
+type DegradedWorker struct {
+	Name      string `json:"name"`
+	Errors    uint64 `json:"errors"`
+	LastError string `json:"last_error"`
+
+	// DisabledAt is the block where the worker was
+	// disabled (nil if the worker is still enabled).
+	DisabledAt *types.BlockIdentifier `json:"disabled_at,omitempty"`
+}
+
+// printDegradedWorkers logs degraded workers to the console.
+func printDegradedWorkers(workers []*DegradedWorker) {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetRowLine(true)
+	table.SetRowSeparator("-")
+	table.SetHeader([]string{"Degraded Workers", "Status", "Errors", "Last Error"})
+	for _, worker := range workers {
+		status := "ENABLED"
+		if worker.DisabledAt != nil {
+			status = fmt.Sprintf("DISABLED AT %d", worker.DisabledAt.Index)
+		}
+
+		table.Append([]string{
+			worker.Name,
+			status,
+			strconv.FormatUint(worker.Errors, 10),
+			worker.LastError,
+		})
+	}
+
+	table.Render()
+}
+
+// printReconciliationFailures logs reconciliation failures to the console.
+func printReconciliationFailures(failures []*ReconciliationStatus) {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetRowLine(true)
+	table.SetRowSeparator("-")
+	table.SetHeader([]string{
+		"Reconciliation Failures",
+		"Account",
+		"Currency",
+		"Block",
+		"Computed",
+		"Live",
+	})
+	for _, failure := range failures {
+		table.Append([]string{
+			failure.Type,
+			types.AccountString(failure.Account),
+			failure.Currency.Symbol,
+			strconv.FormatInt(failure.Block.Index, 10),
+			failure.ComputedBalance,
+			failure.LiveBalance,
+		})
+	}
+
+	table.Render()
 }
 
 // Output writes *CheckDataResults to the provided
@@ -105,7 +197,19 @@ type CheckDataStats struct {
 	ExemptReconciliations   int64   `json:"exempt_reconciliations"`
 	FailedReconciliations   int64   `json:"failed_reconciliations"`
 	SkippedReconciliations  int64   `json:"skipped_reconciliations"`
+	DriftReconciliations    int64   `json:"drift_reconciliations"`
 	ReconciliationCoverage  float64 `json:"reconciliation_coverage"`
+	CheckpointBalances      int64   `json:"checkpoint_balances"`
+
+	// AsserterWarnings are the number of asserter violations
+	// of each class that were treated as warnings (only
+	// populated if any violations were found).
+	AsserterWarnings map[configuration.AsserterViolation]int64 `json:"asserter_warnings,omitempty"`
+
+	// ReconciliationDrift is the total drift (in atomic units)
+	// tolerated in each currency with a reconciliation tolerance,
+	// keyed by currency symbol.
+	ReconciliationDrift map[string]string `json:"reconciliation_drift,omitempty"`
 }
 
 // Print logs CheckDataStats to the console.
@@ -161,6 +265,13 @@ func (c *CheckDataStats) Print() {
 			strconv.FormatInt(c.SkippedReconciliations, 10),
 		},
 	)
+	table.Append(
+		[]string{
+			"Drift Reconciliations",
+			"# of reconciliation differences within tolerance",
+			strconv.FormatInt(c.DriftReconciliations, 10),
+		},
+	)
 	table.Append(
 		[]string{
 			"Reconciliation Coverage",
@@ -168,6 +279,42 @@ func (c *CheckDataStats) Print() {
 			fmt.Sprintf("%f%%", c.ReconciliationCoverage*utils.OneHundred),
 		},
 	)
+	table.Append(
+		[]string{
+			"Checkpoint Balances",
+			"# of balances fetched from /account/balance and trusted on first sight",
+			strconv.FormatInt(c.CheckpointBalances, 10),
+		},
+	)
+	for _, violation := range configuration.AsserterViolations {
+		count, ok := c.AsserterWarnings[violation]
+		if !ok {
+			continue
+		}
+
+		table.Append(
+			[]string{
+				fmt.Sprintf("Asserter Warnings (%s)", violation),
+				"# of asserter violations treated as warnings",
+				strconv.FormatInt(count, 10),
+			},
+		)
+	}
+
+	symbols := make([]string, 0, len(c.ReconciliationDrift))
+	for symbol := range c.ReconciliationDrift {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+	for _, symbol := range symbols {
+		table.Append(
+			[]string{
+				fmt.Sprintf("Reconciliation Drift (%s)", symbol),
+				"total difference tolerated in atomic units",
+				c.ReconciliationDrift[symbol],
+			},
+		)
+	}
 
 	table.Render()
 }
@@ -236,6 +383,18 @@ func ComputeCheckDataStats(
 		return nil
 	}
 
+	driftReconciliations, err := counters.Get(ctx, DriftReconciliationCounter)
+	if err != nil {
+		log.Printf("%s: cannot get drift reconciliations counter", err.Error())
+		return nil
+	}
+
+	checkpointBalances, err := counters.Get(ctx, CheckpointBalanceCounter)
+	if err != nil {
+		log.Printf("%s: cannot get checkpoint balances counter", err.Error())
+		return nil
+	}
+
 	stats := &CheckDataStats{
 		Blocks:                  blocks.Int64(),
 		Orphans:                 orphans.Int64(),
@@ -246,6 +405,25 @@ func ComputeCheckDataStats(
 		ExemptReconciliations:   exemptReconciliations.Int64(),
 		FailedReconciliations:   failedReconciliations.Int64(),
 		SkippedReconciliations:  skippedReconciliations.Int64(),
+		DriftReconciliations:    driftReconciliations.Int64(),
+		CheckpointBalances:      checkpointBalances.Int64(),
+	}
+
+	for _, violation := range configuration.AsserterViolations {
+		count, err := counters.Get(ctx, AsserterViolationCounter(violation))
+		if err != nil {
+			log.Printf("%s: cannot get %s asserter violations counter", err.Error(), violation)
+			return nil
+		}
+
+		if count.Sign() == 0 {
+			continue
+		}
+
+		if stats.AsserterWarnings == nil {
+			stats.AsserterWarnings = map[configuration.AsserterViolation]int64{}
+		}
+		stats.AsserterWarnings[violation] = count.Int64()
 	}
 
 	if balances != nil {
@@ -261,6 +439,31 @@ func ComputeCheckDataStats(
 	return stats
 }
 
+// ComputeReconciliationDrift returns the total drift tolerated
+// in each currency in tolerances (keyed by currency symbol).
+func ComputeReconciliationDrift(
+	ctx context.Context,
+	counters *storage.CounterStorage,
+	tolerances []*configuration.ReconciliationTolerance,
+) map[string]string {
+	if counters == nil || len(tolerances) == 0 {
+		return nil
+	}
+
+	drift := map[string]string{}
+	for _, tolerance := range tolerances {
+		total, err := counters.Get(ctx, ReconciliationDriftCounter(tolerance.Currency))
+		if err != nil {
+			log.Printf("%s: cannot get %s drift counter", err.Error(), tolerance.Currency.Symbol)
+			return nil
+		}
+
+		drift[tolerance.Currency.Symbol] = total.String()
+	}
+
+	return drift
+}
+
 // CheckDataProgress contains information
 // about check:data's syncing progress.
 type CheckDataProgress struct {
@@ -367,8 +570,61 @@ func ComputeCheckDataProgress(
 // CheckDataStatus contains both CheckDataStats
 // and CheckDataProgress.
 type CheckDataStatus struct {
-	Stats    *CheckDataStats    `json:"stats"`
-	Progress *CheckDataProgress `json:"progress"`
+	Stats              *CheckDataStats         `json:"stats"`
+	Progress           *CheckDataProgress      `json:"progress"`
+	Throughput         *CheckDataThroughput    `json:"throughput,omitempty"`
+	Reconciler         *ReconcilerStatus       `json:"reconciler,omitempty"`
+	HeadBlock          *types.BlockIdentifier  `json:"head_block,omitempty"`
+	TipDistance        *int64                  `json:"tip_distance,omitempty"`
+	LastReconciliation *ReconciliationStatus   `json:"last_reconciliation,omitempty"`
+	RecentFailures     []*ReconciliationStatus `json:"recent_failures,omitempty"`
+}
+
+// ReconcilerStatus describes the workers and the
+// active reconciliation backlog of the reconciler.
+type ReconcilerStatus struct {
+	ActiveConcurrency   int `json:"active_concurrency"`
+	InactiveConcurrency int `json:"inactive_concurrency"`
+
+	// QueueSize is the number of changes in the
+	// active reconciliation backlog.
+	QueueSize   int    `json:"queue_size"`
+	BacklogSize int    `json:"backlog_size"`
+	BacklogMode string `json:"backlog_mode"`
+
+	// BlockedSeconds is the total time syncing waited for
+	// room in the backlog (only in "block" mode).
+	BlockedSeconds float64 `json:"blocked_seconds"`
+}
+
+// ReconciliationStatus describes a single
+// reconciliation attempted by the reconciler.
+type ReconciliationStatus struct {
+	Type            string                   `json:"type"`
+	Result          string                   `json:"result"`
+	Account         *types.AccountIdentifier `json:"account"`
+	Currency        *types.Currency          `json:"currency"`
+	Block           *types.BlockIdentifier   `json:"block"`
+	Timestamp       int64                    `json:"timestamp"`
+	ComputedBalance string                   `json:"computed_balance,omitempty"`
+	LiveBalance     string                   `json:"live_balance,omitempty"`
+
+	// Cause is the reason a reconciliation
+	// was skipped (only populated for
+	// skipped reconciliations).
+	Cause string `json:"cause,omitempty"`
+}
+
+// ReconciliationTracker tracks the reconciliations
+// performed during a check:data run.
+type ReconciliationTracker interface {
+	// LastReconciliation returns the most recent
+	// reconciliation (or nil if none have been performed).
+	LastReconciliation() *ReconciliationStatus
+
+	// RecentFailures returns the most recent
+	// reconciliation failures (oldest first).
+	RecentFailures() []*ReconciliationStatus
 }
 
 // ComputeCheckDataStatus returns a populated
@@ -381,8 +637,9 @@ func ComputeCheckDataStatus(
 	fetcher *fetcher.Fetcher,
 	network *types.NetworkIdentifier,
 	reconciler *reconciler.Reconciler,
+	tracker ReconciliationTracker,
 ) *CheckDataStatus {
-	return &CheckDataStatus{
+	status := &CheckDataStatus{
 		Stats: ComputeCheckDataStats(
 			ctx,
 			counters,
@@ -396,7 +653,22 @@ func ComputeCheckDataStatus(
 			blocks,
 			reconciler,
 		),
+		LastReconciliation: tracker.LastReconciliation(),
+		RecentFailures:     tracker.RecentFailures(),
 	}
+
+	head, err := blocks.GetHeadBlockIdentifier(ctx)
+	if err != nil {
+		return status
+	}
+
+	status.HeadBlock = head
+	if status.Progress != nil {
+		tipDistance := status.Progress.Tip - head.Index
+		status.TipDistance = &tipDistance
+	}
+
+	return status
 }
 
 // FetchCheckDataStatus fetches *CheckDataStatus.
@@ -419,6 +691,12 @@ type CheckDataTests struct {
 	BlockSyncing      *bool `json:"block_syncing"`
 	BalanceTracking   *bool `json:"balance_tracking"`
 	Reconciliation    *bool `json:"reconciliation"`
+	AccountCreation   *bool `json:"account_creation,omitempty"`
+	Invariants        *bool `json:"invariants,omitempty"`
+	Fees              *bool `json:"fees,omitempty"`
+	DuplicateHashes   *bool `json:"duplicate_hashes,omitempty"`
+	Timestamps        *bool `json:"timestamps,omitempty"`
+	Supply            *bool `json:"supply,omitempty"`
 }
 
 // convertBool converts a *bool
@@ -476,6 +754,48 @@ func (c *CheckDataTests) Print() {
 			convertBool(c.Reconciliation),
 		},
 	)
+	table.Append(
+		[]string{
+			"Account Creation",
+			"No operations referenced accounts before they were created",
+			convertBool(c.AccountCreation),
+		},
+	)
+	table.Append(
+		[]string{
+			"Invariants",
+			"No blocks violated the configured invariants",
+			convertBool(c.Invariants),
+		},
+	)
+	table.Append(
+		[]string{
+			"Fees",
+			"Fee operations matched transaction fees",
+			convertBool(c.Fees),
+		},
+	)
+	table.Append(
+		[]string{
+			"Duplicate Hashes",
+			"No block or transaction hashes were reused",
+			convertBool(c.DuplicateHashes),
+		},
+	)
+	table.Append(
+		[]string{
+			"Timestamps",
+			"Block timestamps were non-decreasing and plausible",
+			convertBool(c.Timestamps),
+		},
+	)
+	table.Append(
+		[]string{
+			"Supply",
+			"Tracked supply matched the expected supply at checkpoints",
+			convertBool(c.Supply),
+		},
+	)
 
 	table.Render()
 }
@@ -509,6 +829,14 @@ func BlockSyncingTest(err error, blocksSynced bool) *bool {
 		syncPass = false
 	}
 
+	// Account creation, invariant, fee, hash, timestamp,
+	// and supply violations halt the syncer but are not
+	// syncing failures.
+	if accountNotCreated(err) || invariantViolated(err) || feeMismatch(err) ||
+		duplicateHash(err) || invalidTimestamp(err) || supplyMismatch(err) {
+		syncPass = true
+	}
+
 	if !blocksSynced && syncPass {
 		return nil
 	}
@@ -560,6 +888,130 @@ func ReconciliationTest(
 	return &tr
 }
 
+// accountNotCreated returns a boolean indicating if err
+// was caused by an account creation violation. The syncer
+// wraps block worker errors with %v, so we must check the
+// error string instead of using errors.Is.
+func accountNotCreated(err error) bool {
+	return err != nil && strings.Contains(err.Error(), ErrAccountNotCreated.Error())
+}
+
+// AccountCreationTest returns a boolean
+// indicating if no operations referenced
+// an account before it was created.
+func AccountCreationTest(cfg *configuration.Configuration, err error, blocksSynced bool) *bool {
+	if accountNotCreated(err) {
+		return &f
+	}
+
+	if cfg.Data.AccountCreation == nil || !blocksSynced {
+		return nil
+	}
+
+	return &tr
+}
+
+// invariantViolated returns a boolean indicating if err
+// was caused by an invariant violation (see accountNotCreated).
+func invariantViolated(err error) bool {
+	return err != nil && strings.Contains(err.Error(), ErrInvariantViolation.Error())
+}
+
+// InvariantsTest returns a boolean indicating
+// if no blocks violated the configured invariants.
+func InvariantsTest(cfg *configuration.Configuration, err error, blocksSynced bool) *bool {
+	if invariantViolated(err) {
+		return &f
+	}
+
+	if len(cfg.Data.Invariants) == 0 || !blocksSynced {
+		return nil
+	}
+
+	return &tr
+}
+
+// feeMismatch returns a boolean indicating if err
+// was caused by a fee mismatch (see accountNotCreated).
+func feeMismatch(err error) bool {
+	return err != nil && strings.Contains(err.Error(), ErrFeeMismatch.Error())
+}
+
+// FeesTest returns a boolean indicating if the fee
+// operations in all synced transactions were consistent.
+func FeesTest(cfg *configuration.Configuration, err error, blocksSynced bool) *bool {
+	if feeMismatch(err) {
+		return &f
+	}
+
+	if cfg.Data.FeeValidation == nil || !blocksSynced {
+		return nil
+	}
+
+	return &tr
+}
+
+// duplicateHash returns a boolean indicating if err
+// was caused by a duplicate hash (see accountNotCreated).
+func duplicateHash(err error) bool {
+	return err != nil && strings.Contains(err.Error(), ErrDuplicateHash.Error())
+}
+
+// DuplicateHashesTest returns a boolean indicating if no
+// block or transaction hashes were reused in synced blocks.
+func DuplicateHashesTest(cfg *configuration.Configuration, err error, blocksSynced bool) *bool {
+	if duplicateHash(err) {
+		return &f
+	}
+
+	if cfg.Data.DuplicateHashDetection == nil || !blocksSynced {
+		return nil
+	}
+
+	return &tr
+}
+
+// invalidTimestamp returns a boolean indicating if err was
+// caused by an invalid timestamp (see accountNotCreated).
+func invalidTimestamp(err error) bool {
+	return err != nil && strings.Contains(err.Error(), ErrInvalidTimestamp.Error())
+}
+
+// TimestampsTest returns a boolean indicating if the
+// timestamps of all synced blocks were valid.
+func TimestampsTest(cfg *configuration.Configuration, err error, blocksSynced bool) *bool {
+	if invalidTimestamp(err) {
+		return &f
+	}
+
+	if cfg.Data.TimestampValidation == nil || !blocksSynced {
+		return nil
+	}
+
+	return &tr
+}
+
+// supplyMismatch returns a boolean indicating if err was
+// caused by a supply mismatch (see accountNotCreated).
+func supplyMismatch(err error) bool {
+	return err != nil && strings.Contains(err.Error(), ErrSupplyMismatch.Error())
+}
+
+// SupplyTest returns a boolean indicating if the tracked
+// supply of each currency matched the expected supply at
+// every checkpoint.
+func SupplyTest(cfg *configuration.Configuration, err error, blocksSynced bool) *bool {
+	if supplyMismatch(err) {
+		return &f
+	}
+
+	if cfg.Data.SupplyTracking == nil || !blocksSynced {
+		return nil
+	}
+
+	return &tr
+}
+
 // ComputeCheckDataTests returns a populated CheckDataTests.
 func ComputeCheckDataTests( // nolint:gocognit
 	ctx context.Context,
@@ -624,6 +1076,12 @@ func ComputeCheckDataTests( // nolint:gocognit
 			reconciliationsPerformed,
 			reconciliationsFailed,
 		),
+		AccountCreation: AccountCreationTest(cfg, err, blocksSynced),
+		Invariants:      InvariantsTest(cfg, err, blocksSynced),
+		Fees:            FeesTest(cfg, err, blocksSynced),
+		DuplicateHashes: DuplicateHashesTest(cfg, err, blocksSynced),
+		Timestamps:      TimestampsTest(cfg, err, blocksSynced),
+		Supply:          SupplyTest(cfg, err, blocksSynced),
 	}
 }
 
@@ -639,6 +1097,14 @@ func ComputeCheckDataResults(
 	ctx := context.Background()
 	tests := ComputeCheckDataTests(ctx, cfg, err, counterStorage)
 	stats := ComputeCheckDataStats(ctx, counterStorage, balanceStorage)
+	if stats != nil {
+		stats.ReconciliationDrift = ComputeReconciliationDrift(
+			ctx,
+			counterStorage,
+			cfg.Data.ReconciliationTolerances,
+		)
+	}
+
 	results := &CheckDataResults{
 		Tests: tests,
 		Stats: stats,
@@ -654,7 +1120,13 @@ func ComputeCheckDataResults(
 			tests.ResponseAssertion &&
 			(tests.BlockSyncing == nil || *tests.BlockSyncing) &&
 			(tests.BalanceTracking == nil || *tests.BalanceTracking) &&
-			(tests.Reconciliation == nil || *tests.Reconciliation) {
+			(tests.Reconciliation == nil || *tests.Reconciliation) &&
+			(tests.AccountCreation == nil || *tests.AccountCreation) &&
+			(tests.Invariants == nil || *tests.Invariants) &&
+			(tests.Fees == nil || *tests.Fees) &&
+			(tests.DuplicateHashes == nil || *tests.DuplicateHashes) &&
+			(tests.Timestamps == nil || *tests.Timestamps) &&
+			(tests.Supply == nil || *tests.Supply) {
 			results.Tests = nil
 		}
 
@@ -673,8 +1145,21 @@ func ComputeCheckDataResults(
 	return results
 }
 
+// CheckDataExtras are the results of check:data that are
+// only known once the data tester is initialized.
+type CheckDataExtras struct {
+	DegradedWorkers        []*DegradedWorker
+	ReconciliationFailures []*ReconciliationStatus
+	OperationStats         []*opstats.OperationStats
+
+	// Run is finished (with the error check:data
+	// exits with) before the results are logged.
+	Run *provenance.Run
+}
+
 // ExitData exits check:data, logs the test results to the console,
-// and to a provided output path.
+// and to a provided output path. extras may be nil (if check:data
+// exits before the data tester is initialized).
 func ExitData(
 	config *configuration.Configuration,
 	counterStorage *storage.CounterStorage,
@@ -682,7 +1167,12 @@ func ExitData(
 	err error,
 	endCondition configuration.CheckDataEndCondition,
 	endConditionDetail string,
+	extras *CheckDataExtras,
 ) error {
+	if extras != nil && extras.Run != nil {
+		extras.Run.Finish(time.Now(), err)
+	}
+
 	results := ComputeCheckDataResults(
 		config,
 		err,
@@ -692,6 +1182,12 @@ func ExitData(
 		endConditionDetail,
 	)
 	if results != nil {
+		if extras != nil {
+			results.DegradedWorkers = extras.DegradedWorkers
+			results.ReconciliationFailures = extras.ReconciliationFailures
+			results.OperationStats = extras.OperationStats
+			results.Run = extras.Run
+		}
 		results.Print()
 		results.Output(config.Data.ResultsOutputFile)
 	}
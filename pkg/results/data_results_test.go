@@ -317,6 +317,120 @@ func TestComputeCheckDataResults(t *testing.T) {
 				},
 			},
 		},
+		"default configuration, counter storage with blocks, account creation errors": {
+			cfg:                   configuration.DefaultConfiguration(),
+			provideCounterStorage: true,
+			blockCount:            100,
+			err: []error{
+				fmt.Errorf("%w: %v", syncer.ErrBlockProcessFailed, ErrAccountNotCreated),
+			},
+			result: &CheckDataResults{
+				Tests: &CheckDataTests{
+					RequestResponse:   true,
+					ResponseAssertion: true,
+					BlockSyncing:      &tr,
+					AccountCreation:   &f,
+				},
+				Stats: &CheckDataStats{
+					Blocks: 100,
+				},
+			},
+		},
+		"default configuration, counter storage with blocks, invariant errors": {
+			cfg:                   configuration.DefaultConfiguration(),
+			provideCounterStorage: true,
+			blockCount:            100,
+			err: []error{
+				fmt.Errorf("%w: %v", syncer.ErrBlockProcessFailed, ErrInvariantViolation),
+			},
+			result: &CheckDataResults{
+				Tests: &CheckDataTests{
+					RequestResponse:   true,
+					ResponseAssertion: true,
+					BlockSyncing:      &tr,
+					Invariants:        &f,
+				},
+				Stats: &CheckDataStats{
+					Blocks: 100,
+				},
+			},
+		},
+		"default configuration, counter storage with blocks, fee errors": {
+			cfg:                   configuration.DefaultConfiguration(),
+			provideCounterStorage: true,
+			blockCount:            100,
+			err: []error{
+				fmt.Errorf("%w: %v", syncer.ErrBlockProcessFailed, ErrFeeMismatch),
+			},
+			result: &CheckDataResults{
+				Tests: &CheckDataTests{
+					RequestResponse:   true,
+					ResponseAssertion: true,
+					BlockSyncing:      &tr,
+					Fees:              &f,
+				},
+				Stats: &CheckDataStats{
+					Blocks: 100,
+				},
+			},
+		},
+		"default configuration, counter storage with blocks, duplicate hash errors": {
+			cfg:                   configuration.DefaultConfiguration(),
+			provideCounterStorage: true,
+			blockCount:            100,
+			err: []error{
+				fmt.Errorf("%w: %v", syncer.ErrBlockProcessFailed, ErrDuplicateHash),
+			},
+			result: &CheckDataResults{
+				Tests: &CheckDataTests{
+					RequestResponse:   true,
+					ResponseAssertion: true,
+					BlockSyncing:      &tr,
+					DuplicateHashes:   &f,
+				},
+				Stats: &CheckDataStats{
+					Blocks: 100,
+				},
+			},
+		},
+		"default configuration, counter storage with blocks, timestamp errors": {
+			cfg:                   configuration.DefaultConfiguration(),
+			provideCounterStorage: true,
+			blockCount:            100,
+			err: []error{
+				fmt.Errorf("%w: %v", syncer.ErrBlockProcessFailed, ErrInvalidTimestamp),
+			},
+			result: &CheckDataResults{
+				Tests: &CheckDataTests{
+					RequestResponse:   true,
+					ResponseAssertion: true,
+					BlockSyncing:      &tr,
+					Timestamps:        &f,
+				},
+				Stats: &CheckDataStats{
+					Blocks: 100,
+				},
+			},
+		},
+		"default configuration, counter storage with blocks, supply errors": {
+			cfg:                   configuration.DefaultConfiguration(),
+			provideCounterStorage: true,
+			blockCount:            100,
+			err: []error{
+				fmt.Errorf("%w: %v", syncer.ErrBlockProcessFailed, ErrSupplyMismatch),
+			},
+			result: &CheckDataResults{
+				Tests: &CheckDataTests{
+					RequestResponse:   true,
+					ResponseAssertion: true,
+					BlockSyncing:      &tr,
+					Supply:            &f,
+				},
+				Stats: &CheckDataStats{
+					Blocks: 100,
+				},
+			},
+		},
 		"default configuration, no storage, unknown errors": {
 			cfg:    configuration.DefaultConfiguration(),
 			err:    []error{errors.New("unsure how to handle this error")},
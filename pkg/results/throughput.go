@@ -0,0 +1,138 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package results
+
+import (
+	"sync"
+	"time"
+
+	"github.com/coinbase/rosetta-sdk-go/utils"
+)
+
+const (
+	// unknownTimeRemaining is the TimeRemaining of
+	// CheckDataThroughput when no blocks were synced
+	// during the window.
+	unknownTimeRemaining = "unknown"
+
+	// minThroughputSamples is the number of samples
+	// required to compute throughput.
+	minThroughputSamples = 2
+)
+
+// CheckDataThroughput contains the syncing throughput
+// of check:data over a recent window. Unlike
+// CheckDataProgress (which averages over the entire
+// run), this reflects the current speed of syncing.
+type CheckDataThroughput struct {
+	Window        string  `json:"window"`
+	BlockRate     float64 `json:"block_rate"`
+	OperationRate float64 `json:"operation_rate"`
+	Index         int64   `json:"index"`
+	Tip           int64   `json:"tip"`
+	TimeRemaining string  `json:"time_remaining"`
+}
+
+// throughputSample is a snapshot of the number
+// of blocks and operations synced at some time.
+type throughputSample struct {
+	time       time.Time
+	blocks     int64
+	operations int64
+}
+
+// ThroughputTracker records periodic samples of
+// CheckDataStats to compute throughput over a
+// sliding window.
+type ThroughputTracker struct {
+	window time.Duration
+
+	samples []*throughputSample
+	mutex   sync.Mutex
+}
+
+// NewThroughputTracker returns a new *ThroughputTracker
+// that computes throughput over window.
+func NewThroughputTracker(window time.Duration) *ThroughputTracker {
+	return &ThroughputTracker{
+		window: window,
+	}
+}
+
+// Record adds a sample of stats taken at now and
+// discards all samples older than the window (keeping
+// the newest of them so the full window is covered).
+func (t *ThroughputTracker) Record(now time.Time, stats *CheckDataStats) {
+	if stats == nil {
+		return
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.samples = append(t.samples, &throughputSample{
+		time:       now,
+		blocks:     stats.Blocks - stats.Orphans,
+		operations: stats.Operations,
+	})
+
+	start := 0
+	for start < len(t.samples)-1 && !t.samples[start+1].time.After(now.Add(-t.window)) {
+		start++
+	}
+	t.samples = t.samples[start:]
+}
+
+// Throughput returns the *CheckDataThroughput over the
+// recorded window given the index of the head block and
+// the tip. If fewer than 2 samples have been recorded,
+// nil is returned.
+func (t *ThroughputTracker) Throughput(index int64, tip int64) *CheckDataThroughput {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if len(t.samples) < minThroughputSamples {
+		return nil
+	}
+
+	first := t.samples[0]
+	last := t.samples[len(t.samples)-1]
+	elapsed := last.time.Sub(first.time)
+	if elapsed <= 0 {
+		return nil
+	}
+
+	blockRate := float64(last.blocks-first.blocks) / elapsed.Seconds()
+	operationRate := float64(last.operations-first.operations) / elapsed.Seconds()
+
+	// The time remaining is unknown if no
+	// blocks were synced during the window.
+	timeRemaining := unknownTimeRemaining
+	switch {
+	case index >= tip:
+		timeRemaining = time.Duration(0).String()
+	case blockRate > 0:
+		timeRemaining = utils.TimeToTip(blockRate, index, tip).String()
+	}
+
+	return &CheckDataThroughput{
+		Window:        elapsed.Round(time.Second).String(),
+		BlockRate:     blockRate,
+		OperationRate: operationRate,
+		Index:         index,
+		Tip:           tip,
+		TimeRemaining: timeRemaining,
+	}
+}
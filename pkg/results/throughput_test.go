@@ -0,0 +1,105 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package results
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestThroughputTracker(t *testing.T) {
+	start := time.Unix(1000, 0)
+	var tests = map[string]struct {
+		samples []*CheckDataStats
+		index   int64
+		tip     int64
+
+		throughput *CheckDataThroughput
+	}{
+		"no samples": {
+			index: 10,
+			tip:   100,
+		},
+		"single sample": {
+			samples: []*CheckDataStats{{Blocks: 10, Operations: 100}},
+			index:   10,
+			tip:     100,
+		},
+		"within window": {
+			samples: []*CheckDataStats{
+				{Blocks: 10, Operations: 100},
+				{Blocks: 110, Operations: 1100},
+			},
+			index: 110,
+			tip:   1110,
+			throughput: &CheckDataThroughput{
+				Window:        "10s",
+				BlockRate:     10,
+				OperationRate: 100,
+				Index:         110,
+				Tip:           1110,
+				TimeRemaining: "1m40s",
+			},
+		},
+		"samples outside window discarded": {
+			samples: []*CheckDataStats{
+				{Blocks: 0, Operations: 0},
+				{Blocks: 10, Operations: 10},
+				{Blocks: 20, Operations: 20},
+				{Blocks: 30, Operations: 30},
+				{Blocks: 40, Operations: 40},
+				{Blocks: 45, Orphans: 5, Operations: 60},
+				{Blocks: 50, Orphans: 5, Operations: 90},
+			},
+			index: 45,
+			tip:   45,
+			throughput: &CheckDataThroughput{
+				Window:        "30s",
+				BlockRate:     0.5,
+				OperationRate: 2,
+				Index:         45,
+				Tip:           45,
+				TimeRemaining: "0s",
+			},
+		},
+		"stalled": {
+			samples: []*CheckDataStats{
+				{Blocks: 10, Operations: 100},
+				{Blocks: 10, Operations: 100},
+			},
+			index: 10,
+			tip:   100,
+			throughput: &CheckDataThroughput{
+				Window:        "10s",
+				Index:         10,
+				Tip:           100,
+				TimeRemaining: unknownTimeRemaining,
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			tracker := NewThroughputTracker(30 * time.Second)
+			for i, sample := range test.samples {
+				tracker.Record(start.Add(time.Duration(i)*10*time.Second), sample)
+			}
+
+			assert.Equal(t, test.throughput, tracker.Throughput(test.index, test.tip))
+		})
+	}
+}
@@ -16,16 +16,139 @@ package results
 
 import (
 	"errors"
+
+	"github.com/coinbase/rosetta-cli/configuration"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
 )
 
 const (
 	// TimeElapsedCounter tracks the total time elapsed in seconds.
 	TimeElapsedCounter = "time_elapsed"
+
+	// asserterViolationCounterPrefix is the prefix of
+	// the counters that track asserter violations treated
+	// as warnings.
+	asserterViolationCounterPrefix = "asserter_violations_"
+
+	// constructionOperationCounterPrefix is the prefix of
+	// the counters that track the operations of each type
+	// confirmed by check:construction.
+	constructionOperationCounterPrefix = "construction_operations_"
+
+	// DriftReconciliationCounter tracks the number of
+	// reconciliations whose difference was within the
+	// configured tolerance of the currency.
+	DriftReconciliationCounter = "drift_reconciliations"
+
+	// CheckpointBalanceCounter tracks the number of balances
+	// fetched from /account/balance and trusted when an account
+	// was first seen (when checkpoint balances are enabled).
+	CheckpointBalanceCounter = "checkpoint_balances"
+
+	// reconciliationDriftCounterPrefix is the prefix of the
+	// counters that track the total drift (in atomic units)
+	// tolerated in each currency.
+	reconciliationDriftCounterPrefix = "reconciliation_drift_"
 )
 
+// AsserterViolationCounter returns the name of the counter
+// that tracks violations of class violation that were
+// treated as warnings.
+func AsserterViolationCounter(violation configuration.AsserterViolation) string {
+	return asserterViolationCounterPrefix + string(violation)
+}
+
+// ConstructionOperationCounter returns the name of the
+// counter that tracks the operations of type opType
+// in transactions confirmed by check:construction.
+func ConstructionOperationCounter(opType string) string {
+	return constructionOperationCounterPrefix + opType
+}
+
+// ReconciliationDriftCounter returns the name of the
+// counter that tracks the total drift tolerated
+// in currency.
+func ReconciliationDriftCounter(currency *types.Currency) string {
+	return reconciliationDriftCounterPrefix + types.Hash(currency)
+}
+
 var (
 	// ErrReconciliationFailure is returned if reconciliation fails.
 	// TODO: Move to reconciler package (had to remove from processor
 	// to prevent circular dependency)
 	ErrReconciliationFailure = errors.New("reconciliation failure")
+
+	// ErrAccountNotCreated is returned if an operation references
+	// an account before the account is created.
+	ErrAccountNotCreated = errors.New("account referenced before creation")
+
+	// ErrInvariantViolation is returned if a synced block
+	// violates a configured invariant.
+	ErrInvariantViolation = errors.New("invariant violated")
+
+	// ErrFeeMismatch is returned if the fee operations
+	// in a synced transaction are inconsistent.
+	ErrFeeMismatch = errors.New("fee mismatch")
+
+	// ErrDuplicateHash is returned if a synced block reuses
+	// a block hash or contains a transaction hash that was
+	// already seen in another block.
+	ErrDuplicateHash = errors.New("duplicate hash")
+
+	// ErrInvalidTimestamp is returned if a synced block has a
+	// timestamp before its parent or outside of a plausible
+	// window of wall-clock time.
+	ErrInvalidTimestamp = errors.New("invalid block timestamp")
+
+	// ErrSupplyMismatch is returned if the tracked supply of
+	// a currency differs from the expected supply at a
+	// checkpoint.
+	ErrSupplyMismatch = errors.New("supply mismatch")
+
+	// ErrPluginFailure is returned if a block worker
+	// plugin fails to process a synced block.
+	ErrPluginFailure = errors.New("plugin failure")
+
+	// ErrBlockStreamFailure is returned if a validated
+	// block cannot be published to the block stream.
+	ErrBlockStreamFailure = errors.New("block stream failure")
+
+	// ErrDeepReorg is returned if the syncer processes a reorg
+	// deeper than the configured max depth (and halting on deep
+	// reorgs is enabled).
+	ErrDeepReorg = errors.New("reorg exceeds max depth")
+
+	// ErrDiskSpaceExhausted is returned if a check is halted
+	// because the free space on the volume of the data directory
+	// dropped below the halt threshold.
+	ErrDiskSpaceExhausted = errors.New("disk space exhausted")
+
+	// ErrUnconfirmedBroadcasts is returned if transactions are
+	// still pending confirmation once all check:construction end
+	// conditions are met (and unconfirmed_broadcasts is "fail").
+	ErrUnconfirmedBroadcasts = errors.New("unconfirmed broadcasts")
+
+	// ErrFeeDeviation is returned if the fee charged for a
+	// confirmed check:construction transaction deviates from the
+	// suggested fee by more than the configured max deviation.
+	ErrFeeDeviation = errors.New("fee deviation exceeds max")
+
+	// ErrNonceReuse is returned if a check:construction transaction
+	// sent concurrently is built with the nonce of a pending
+	// transaction from the same account.
+	ErrNonceReuse = errors.New("nonce reused by pending transaction")
+
+	// ErrOutOfOrderConfirmation is returned if check:construction
+	// transactions sent concurrently from the same account confirm
+	// in a different order than they were broadcast.
+	ErrOutOfOrderConfirmation = errors.New("transactions confirmed out of order")
+
+	// ErrStorageCorruption is returned if data stored
+	// by check:data is inconsistent.
+	ErrStorageCorruption = errors.New("storage corruption")
+
+	// ErrCheckHalted is returned if a check is halted
+	// by a signal.
+	ErrCheckHalted = errors.New("check halted")
 )
@@ -0,0 +1,612 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package retry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"math"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coinbase/rosetta-cli/configuration"
+
+	"github.com/coinbase/rosetta-sdk-go/client"
+	"github.com/coinbase/rosetta-sdk-go/fetcher"
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+// ErrInvalidHTTPConfiguration is returned when the proxy
+// or TLS settings of the http configuration cannot be
+// applied to the transport of a fetcher.
+var ErrInvalidHTTPConfiguration = errors.New("invalid http configuration")
+
+// NewFetcher returns a *fetcher.Fetcher for serverAddress populated
+// with the timeout and retry settings in config. Any options are
+// applied after these settings.
+//
+// The fetcher's *Retry methods use a hardcoded exponential backoff,
+// so when config.RetryBackoff is populated, fetcher retries are
+// disabled and requests are instead retried by a *Transport.
+func NewFetcher(
+	config *configuration.Configuration,
+	serverAddress string,
+	options ...fetcher.Option,
+) (*fetcher.Fetcher, error) {
+	return NewWrappedFetcher(config, serverAddress, nil, options...)
+}
+
+// NewWrappedFetcher returns a *fetcher.Fetcher like NewFetcher
+// but wraps the transport of the fetcher with wrap (if not nil).
+// Requests retried by a *Transport are only seen once by the
+// wrapped transport.
+func NewWrappedFetcher(
+	config *configuration.Configuration,
+	serverAddress string,
+	wrap func(http.RoundTripper) http.RoundTripper,
+	options ...fetcher.Option,
+) (*fetcher.Fetcher, error) {
+	return NewInjectedFetcher(config, serverAddress, nil, wrap, options...)
+}
+
+// NewInjectedFetcher returns a *fetcher.Fetcher like NewWrappedFetcher
+// but also wraps the base transport of the fetcher with inject (if not
+// nil). Unlike wrap, inject sees each attempt of a request retried by
+// a *Transport, so it can be used to inject faults below all
+// retry logic.
+func NewInjectedFetcher(
+	config *configuration.Configuration,
+	serverAddress string,
+	inject func(http.RoundTripper) http.RoundTripper,
+	wrap func(http.RoundTripper) http.RoundTripper,
+	options ...fetcher.Option,
+) (*fetcher.Fetcher, error) {
+	apiClient := client.NewAPIClient(client.NewConfiguration(
+		serverAddress,
+		fetcher.DefaultUserAgent,
+		&http.Client{},
+	))
+
+	timeout := time.Duration(config.HTTPTimeout) * time.Second
+	maxRetries := config.MaxRetries
+	if config.RetryBackoff != nil {
+		// The http.Client timeout includes all time spent in the
+		// transport, so the *Transport enforces the timeout
+		// on each attempt instead.
+		timeout = 0
+		maxRetries = 0
+	}
+
+	// Endpoint timeouts are enforced on each
+	// attempt by a *TimeoutTransport.
+	attemptTimeout := time.Duration(config.HTTPTimeout) * time.Second
+	if config.EndpointTimeouts != nil {
+		timeout = 0
+		attemptTimeout = 0
+	}
+
+	f := fetcher.New(
+		serverAddress,
+		append([]fetcher.Option{
+			fetcher.WithClient(apiClient),
+			fetcher.WithTimeout(timeout),
+			fetcher.WithRetryElapsedTime(time.Duration(config.RetryElapsedTime) * time.Second),
+			fetcher.WithMaxRetries(maxRetries),
+		}, options...)...,
+	)
+
+	// fetcher.New overwrites the transport of the provided client,
+	// so we must wrap it after construction.
+	if config.HTTP != nil {
+		httpClient := apiClient.GetConfig().HTTPClient
+		transport, err := configureTransport(httpClient.Transport, config.HTTP)
+		if err != nil {
+			return nil, err
+		}
+		httpClient.Transport = transport
+	}
+
+	if inject != nil {
+		httpClient := apiClient.GetConfig().HTTPClient
+		httpClient.Transport = inject(httpClient.Transport)
+	}
+
+	if config.HTTP != nil {
+		httpClient := apiClient.GetConfig().HTTPClient
+		httpClient.Transport = NewHeaderTransport(
+			httpClient.Transport,
+			staticHeaders(config.HTTP),
+		)
+	}
+
+	if config.EndpointTimeouts != nil {
+		httpClient := apiClient.GetConfig().HTTPClient
+		httpClient.Transport = NewTimeoutTransport(
+			httpClient.Transport,
+			config.EndpointTimeouts,
+			time.Duration(config.HTTPTimeout)*time.Second,
+		)
+	}
+
+	// Rate limits are enforced on each attempt (and
+	// before the timeout of the attempt starts).
+	if config.RateLimits != nil {
+		httpClient := apiClient.GetConfig().HTTPClient
+		httpClient.Transport = NewRateLimitTransport(httpClient.Transport, config.RateLimits)
+	}
+
+	if config.RetryBackoff != nil {
+		httpClient := apiClient.GetConfig().HTTPClient
+		httpClient.Transport = NewTransport(
+			httpClient.Transport,
+			config.RetryBackoff,
+			config.MaxRetries,
+			time.Duration(config.RetryElapsedTime)*time.Second,
+			attemptTimeout,
+		)
+	}
+
+	if wrap != nil {
+		httpClient := apiClient.GetConfig().HTTPClient
+		httpClient.Transport = wrap(httpClient.Transport)
+	}
+
+	return f, nil
+}
+
+// configureTransport configures the proxy and TLS settings
+// of transport. These can only be set on an *http.Transport.
+func configureTransport(
+	transport http.RoundTripper,
+	config *configuration.HTTPConfiguration,
+) (http.RoundTripper, error) {
+	if len(config.ProxyURL) == 0 && config.TLS == nil {
+		return transport, nil
+	}
+
+	httpTransport, ok := transport.(*http.Transport)
+	if !ok {
+		return nil, fmt.Errorf(
+			"%w: unable to configure proxy and tls for %T",
+			ErrInvalidHTTPConfiguration,
+			transport,
+		)
+	}
+
+	httpTransport = httpTransport.Clone()
+
+	// The proxy URL and TLS configuration are validated
+	// when the configuration is loaded, but the TLS files
+	// may have changed since.
+	if len(config.ProxyURL) > 0 {
+		proxyURL, err := url.Parse(config.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"%w: unable to parse proxy url: %s",
+				ErrInvalidHTTPConfiguration,
+				err.Error(),
+			)
+		}
+
+		httpTransport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	if config.TLS != nil {
+		tlsConfig, err := configuration.LoadTLSConfig(config.TLS)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"%w: unable to load tls configuration: %s",
+				ErrInvalidHTTPConfiguration,
+				err.Error(),
+			)
+		}
+
+		httpTransport.TLSClientConfig = tlsConfig
+	}
+
+	return httpTransport, nil
+}
+
+// staticHeaders returns the static headers (including any
+// authentication headers) in config.
+func staticHeaders(config *configuration.HTTPConfiguration) map[string]string {
+	headers := map[string]string{}
+	for header, value := range config.Headers {
+		headers[header] = value
+	}
+
+	if len(config.BearerToken) > 0 {
+		headers["Authorization"] = "Bearer " + config.BearerToken
+	}
+
+	if config.APIKey != nil {
+		headers[config.APIKey.Header] = config.APIKey.Value
+	}
+
+	return headers
+}
+
+// HeaderTransport is an http.RoundTripper that adds
+// static headers to each request.
+type HeaderTransport struct {
+	next    http.RoundTripper
+	headers map[string]string
+}
+
+// NewHeaderTransport returns a new *HeaderTransport
+// that sends requests with headers using next.
+func NewHeaderTransport(next http.RoundTripper, headers map[string]string) *HeaderTransport {
+	return &HeaderTransport{
+		next:    next,
+		headers: headers,
+	}
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *HeaderTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// A http.RoundTripper should not modify
+	// the provided request.
+	req = req.Clone(req.Context())
+	for header, value := range t.headers {
+		if http.CanonicalHeaderKey(header) == "Host" {
+			req.Host = value
+			continue
+		}
+
+		req.Header.Set(header, value)
+	}
+
+	return t.next.RoundTrip(req)
+}
+
+// TimeoutTransport is an http.RoundTripper that enforces
+// a different timeout on requests to each endpoint.
+type TimeoutTransport struct {
+	next           http.RoundTripper
+	timeouts       *configuration.EndpointTimeouts
+	defaultTimeout time.Duration
+}
+
+// NewTimeoutTransport returns a new *TimeoutTransport that
+// sends requests using next. Requests to endpoints without
+// a timeout in timeouts use defaultTimeout (or do not time
+// out if defaultTimeout is 0).
+func NewTimeoutTransport(
+	next http.RoundTripper,
+	timeouts *configuration.EndpointTimeouts,
+	defaultTimeout time.Duration,
+) *TimeoutTransport {
+	return &TimeoutTransport{
+		next:           next,
+		timeouts:       timeouts,
+		defaultTimeout: defaultTimeout,
+	}
+}
+
+// Timeout returns the timeout of a request to path. The
+// online_url may include a path prefix, so endpoints
+// are matched at the end of path.
+func (t *TimeoutTransport) Timeout(path string) time.Duration {
+	var timeout uint64
+	switch {
+	case strings.HasSuffix(path, "/block"), strings.HasSuffix(path, "/block/transaction"):
+		timeout = t.timeouts.Block
+	case strings.HasSuffix(path, "/account/balance"), strings.HasSuffix(path, "/account/coins"):
+		timeout = t.timeouts.AccountBalance
+	case strings.HasSuffix(path, "/network/status"):
+		timeout = t.timeouts.NetworkStatus
+	case strings.Contains(path, "/construction/"):
+		timeout = t.timeouts.Construction
+	}
+
+	if timeout == 0 {
+		return t.defaultTimeout
+	}
+
+	return time.Duration(timeout) * time.Second
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *TimeoutTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return roundTripWithTimeout(t.next, req, t.Timeout(req.URL.Path))
+}
+
+// rateLimiter spaces requests evenly so that
+// no more than a fixed number are sent each second.
+type rateLimiter struct {
+	mutex    sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+// newRateLimiter returns a *rateLimiter that allows perSecond
+// requests each second (or nil if perSecond is 0).
+func newRateLimiter(perSecond float64) *rateLimiter {
+	if perSecond == 0 {
+		return nil
+	}
+
+	return &rateLimiter{interval: time.Duration(float64(time.Second) / perSecond)}
+}
+
+// Wait blocks until the next request may
+// be sent or ctx is canceled.
+func (l *rateLimiter) Wait(ctx context.Context) error {
+	l.mutex.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+
+	delay := l.next.Sub(now)
+	l.next = l.next.Add(l.interval)
+	l.mutex.Unlock()
+
+	if delay == 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// RateLimitTransport is an http.RoundTripper that limits
+// the rate of requests to some endpoints.
+type RateLimitTransport struct {
+	next           http.RoundTripper
+	block          *rateLimiter
+	accountBalance *rateLimiter
+}
+
+// NewRateLimitTransport returns a new *RateLimitTransport
+// that sends requests using next. Requests to endpoints
+// without a limit in limits are not limited.
+func NewRateLimitTransport(
+	next http.RoundTripper,
+	limits *configuration.RateLimits,
+) *RateLimitTransport {
+	return &RateLimitTransport{
+		next:           next,
+		block:          newRateLimiter(limits.Block),
+		accountBalance: newRateLimiter(limits.AccountBalance),
+	}
+}
+
+// limiter returns the *rateLimiter of requests to path (or nil
+// if they are not limited). Like in TimeoutTransport, endpoints
+// are matched at the end of path.
+func (t *RateLimitTransport) limiter(path string) *rateLimiter {
+	switch {
+	case strings.HasSuffix(path, "/block"), strings.HasSuffix(path, "/block/transaction"):
+		return t.block
+	case strings.HasSuffix(path, "/account/balance"), strings.HasSuffix(path, "/account/coins"):
+		return t.accountBalance
+	default:
+		return nil
+	}
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *RateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if limiter := t.limiter(req.URL.Path); limiter != nil {
+		if err := limiter.Wait(req.Context()); err != nil {
+			return nil, err
+		}
+	}
+
+	return t.next.RoundTrip(req)
+}
+
+// Transport is an http.RoundTripper that retries failed
+// requests with a configurable exponential backoff.
+type Transport struct {
+	next           http.RoundTripper
+	backoff        *configuration.RetryBackoff
+	maxRetries     uint64
+	maxElapsedTime time.Duration
+	timeout        time.Duration
+}
+
+// NewTransport returns a new *Transport that sends requests
+// using next. If maxElapsedTime is 0, retries are only
+// limited by maxRetries. If timeout is 0, attempts do
+// not time out.
+func NewTransport(
+	next http.RoundTripper,
+	backoff *configuration.RetryBackoff,
+	maxRetries uint64,
+	maxElapsedTime time.Duration,
+	timeout time.Duration,
+) *Transport {
+	return &Transport{
+		next:           next,
+		backoff:        backoff,
+		maxRetries:     maxRetries,
+		maxElapsedTime: maxElapsedTime,
+		timeout:        timeout,
+	}
+}
+
+// cancelOnClose cancels the context of an attempt
+// when the response body is closed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
+
+// roundTripWithTimeout sends req using next, canceling
+// it if no response is read within timeout (if not 0).
+func roundTripWithTimeout(
+	next http.RoundTripper,
+	req *http.Request,
+	timeout time.Duration,
+) (*http.Response, error) {
+	if timeout == 0 {
+		return next.RoundTrip(req)
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), timeout)
+	resp, err := next.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// attempt sends req using the next http.RoundTripper,
+// enforcing the per-attempt timeout.
+func (t *Transport) attempt(req *http.Request) (*http.Response, error) {
+	return roundTripWithTimeout(t.next, req, t.timeout)
+}
+
+// Backoff returns the delay before retry attempt
+// (starting at 0), including jitter.
+func (t *Transport) Backoff(attempt uint64) time.Duration {
+	base := float64(t.backoff.BaseBackoff) * math.Pow(2, float64(attempt))
+	delay := math.Min(base, float64(t.backoff.MaxBackoff))
+	delay *= 1 + t.backoff.Jitter*(2*rand.Float64()-1) // #nosec G404
+
+	return time.Duration(delay) * time.Millisecond
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	for attempt := uint64(0); ; attempt++ {
+		resp, err := t.attempt(req)
+		retry, retryAfter := shouldRetry(resp, err)
+		if !retry || attempt >= t.maxRetries || (req.Body != nil && req.GetBody == nil) {
+			return resp, err
+		}
+
+		delay := t.Backoff(attempt)
+		if retryAfter > delay {
+			delay = retryAfter
+		}
+
+		if t.maxElapsedTime > 0 && time.Since(start)+delay > t.maxElapsedTime {
+			return resp, err
+		}
+
+		var reason string
+		if err != nil {
+			reason = err.Error()
+		} else {
+			reason = resp.Status
+			_, _ = io.Copy(ioutil.Discard, resp.Body)
+			_ = resp.Body.Close()
+		}
+
+		log.Printf(
+			"%s: retrying %s after %fs (prior attempts: %d)\n",
+			reason,
+			req.URL.Path,
+			delay.Seconds(),
+			attempt+1,
+		)
+
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(delay):
+		}
+
+		retryReq := req.Clone(req.Context())
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			retryReq.Body = body
+		}
+		req = retryReq
+	}
+}
+
+// shouldRetry returns a boolean indicating if a request should be
+// retried and the delay requested by the server (if any).
+func shouldRetry(resp *http.Response, err error) (bool, time.Duration) {
+	if err != nil {
+		return true, 0
+	}
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return true, retryAfter(resp)
+	case http.StatusBadGateway, http.StatusGatewayTimeout:
+		return true, 0
+	case http.StatusInternalServerError:
+		return retriableError(resp), 0
+	default:
+		return false, 0
+	}
+}
+
+// retryAfter parses the Retry-After header (in seconds)
+// of a response.
+func retryAfter(resp *http.Response) time.Duration {
+	seconds, err := strconv.ParseUint(resp.Header.Get("Retry-After"), 10, 32)
+	if err != nil {
+		return 0
+	}
+
+	return time.Duration(seconds) * time.Second
+}
+
+// retriableError returns a boolean indicating if a 500 response
+// contains a *types.Error marked as retriable. The response
+// body is restored so it can be read by the caller.
+func retriableError(resp *http.Response) bool {
+	body, err := ioutil.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return false
+	}
+
+	var rosettaErr types.Error
+	if err := json.Unmarshal(body, &rosettaErr); err != nil {
+		return false
+	}
+
+	return rosettaErr.Retriable
+}
@@ -0,0 +1,521 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package retry
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/coinbase/rosetta-cli/configuration"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/coinbase/rosetta-sdk-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackoff(t *testing.T) {
+	transport := NewTransport(
+		http.DefaultTransport,
+		&configuration.RetryBackoff{BaseBackoff: 100, MaxBackoff: 1000, Jitter: 0.5},
+		10,
+		0,
+		0,
+	)
+
+	for attempt, base := range []time.Duration{100, 200, 400, 800, 1000, 1000} {
+		base *= time.Millisecond
+		for i := 0; i < 100; i++ {
+			delay := transport.Backoff(uint64(attempt))
+			assert.True(t, delay >= base/2, "attempt %d delay %s", attempt, delay)
+			assert.True(t, delay <= base*3/2, "attempt %d delay %s", attempt, delay)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	var tests = map[string]struct {
+		statuses   []int
+		body       string
+		maxRetries uint64
+
+		expectedStatus   int
+		expectedRequests int
+	}{
+		"success": {
+			statuses:         []int{http.StatusOK},
+			maxRetries:       5,
+			expectedStatus:   http.StatusOK,
+			expectedRequests: 1,
+		},
+		"retry unavailable": {
+			statuses:         []int{http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusOK},
+			maxRetries:       5,
+			expectedStatus:   http.StatusOK,
+			expectedRequests: 3,
+		},
+		"exhaust retries": {
+			statuses:         []int{http.StatusBadGateway},
+			maxRetries:       2,
+			expectedStatus:   http.StatusBadGateway,
+			expectedRequests: 3,
+		},
+		"retry retriable error": {
+			statuses:         []int{http.StatusInternalServerError, http.StatusOK},
+			body:             `{"code":1,"message":"node busy","retriable":true}`,
+			maxRetries:       5,
+			expectedStatus:   http.StatusOK,
+			expectedRequests: 2,
+		},
+		"non-retriable error": {
+			statuses:         []int{http.StatusInternalServerError},
+			body:             `{"code":2,"message":"invalid block","retriable":false}`,
+			maxRetries:       5,
+			expectedStatus:   http.StatusInternalServerError,
+			expectedRequests: 1,
+		},
+		"bad request": {
+			statuses:         []int{http.StatusBadRequest},
+			maxRetries:       5,
+			expectedStatus:   http.StatusBadRequest,
+			expectedRequests: 1,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			requests := 0
+			server := httptest.NewServer(http.HandlerFunc(
+				func(w http.ResponseWriter, r *http.Request) {
+					body, err := ioutil.ReadAll(r.Body)
+					assert.NoError(t, err)
+					assert.Equal(t, "request", string(body))
+
+					status := test.statuses[len(test.statuses)-1]
+					if requests < len(test.statuses) {
+						status = test.statuses[requests]
+					}
+					requests++
+
+					w.WriteHeader(status)
+					_, _ = w.Write([]byte(test.body))
+				},
+			))
+			defer server.Close()
+
+			client := &http.Client{
+				Transport: NewTransport(
+					http.DefaultTransport,
+					&configuration.RetryBackoff{BaseBackoff: 1, MaxBackoff: 5},
+					test.maxRetries,
+					time.Minute,
+					time.Second,
+				),
+			}
+
+			resp, err := client.Post(server.URL, "text/plain", bytes.NewBufferString("request"))
+			assert.NoError(t, err)
+			defer resp.Body.Close()
+
+			body, err := ioutil.ReadAll(resp.Body)
+			assert.NoError(t, err)
+			assert.Equal(t, test.body, string(body))
+			assert.Equal(t, test.expectedStatus, resp.StatusCode)
+			assert.Equal(t, test.expectedRequests, requests)
+		})
+	}
+}
+
+func TestTimeoutTransport(t *testing.T) {
+	transport := NewTimeoutTransport(
+		http.DefaultTransport,
+		&configuration.EndpointTimeouts{
+			Block:          1,
+			AccountBalance: 120,
+			Construction:   5,
+		},
+		50*time.Millisecond,
+	)
+
+	t.Run("timeouts", func(t *testing.T) {
+		var tests = map[string]struct {
+			path string
+
+			expectedTimeout time.Duration
+		}{
+			"block": {
+				path:            "/block",
+				expectedTimeout: time.Second,
+			},
+			"block transaction with prefix": {
+				path:            "/rosetta/block/transaction",
+				expectedTimeout: time.Second,
+			},
+			"account coins": {
+				path:            "/account/coins",
+				expectedTimeout: 120 * time.Second,
+			},
+			"construction": {
+				path:            "/construction/submit",
+				expectedTimeout: 5 * time.Second,
+			},
+			"network status (default)": {
+				path:            "/network/status",
+				expectedTimeout: 50 * time.Millisecond,
+			},
+			"events blocks (default)": {
+				path:            "/events/blocks",
+				expectedTimeout: 50 * time.Millisecond,
+			},
+		}
+
+		for name, test := range tests {
+			t.Run(name, func(t *testing.T) {
+				assert.Equal(t, test.expectedTimeout, transport.Timeout(test.path))
+			})
+		}
+	})
+
+	t.Run("round trip", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				time.Sleep(200 * time.Millisecond)
+				w.WriteHeader(http.StatusOK)
+			},
+		))
+		defer server.Close()
+
+		client := &http.Client{Transport: transport}
+		resp, err := client.Post(server.URL+"/block", "application/json", nil)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.NoError(t, resp.Body.Close())
+
+		_, err = client.Post(server.URL+"/network/status", "application/json", nil)
+		assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	})
+}
+
+func TestRateLimitTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		},
+	))
+	defer server.Close()
+
+	// Block requests are spaced 50ms apart and
+	// all other requests are not limited.
+	client := &http.Client{Transport: NewRateLimitTransport(
+		http.DefaultTransport,
+		&configuration.RateLimits{Block: 20},
+	)}
+	post := func(ctx context.Context, path string) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, server.URL+path, nil)
+		assert.NoError(t, err)
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+
+		return resp.Body.Close()
+	}
+
+	ctx := context.Background()
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		assert.NoError(t, post(ctx, "/rosetta/block"))
+	}
+	assert.True(t, time.Since(start) >= 150*time.Millisecond)
+
+	start = time.Now()
+	for i := 0; i < 4; i++ {
+		assert.NoError(t, post(ctx, "/account/balance"))
+		assert.NoError(t, post(ctx, "/network/status"))
+	}
+	assert.True(t, time.Since(start) < 150*time.Millisecond)
+
+	// Requests waiting to be sent can be canceled.
+	assert.NoError(t, post(ctx, "/block/transaction"))
+	cancelCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	assert.True(t, errors.Is(post(cancelCtx, "/block"), context.DeadlineExceeded))
+}
+
+func TestNewFetcherHTTPConfiguration(t *testing.T) {
+	var tests = map[string]struct {
+		http  *configuration.HTTPConfiguration
+		proxy bool
+
+		expectedHeaders map[string]string
+	}{
+		"headers": {
+			http: &configuration.HTTPConfiguration{
+				Headers: map[string]string{"X-Client": "rosetta-cli"},
+			},
+			expectedHeaders: map[string]string{"X-Client": "rosetta-cli"},
+		},
+		"bearer token": {
+			http: &configuration.HTTPConfiguration{BearerToken: "token"},
+			expectedHeaders: map[string]string{
+				"Authorization": "Bearer token",
+			},
+		},
+		"api key and proxy": {
+			http: &configuration.HTTPConfiguration{
+				APIKey: &configuration.APIKey{Header: "x-api-key", Value: "key"},
+			},
+			proxy:           true,
+			expectedHeaders: map[string]string{"X-Api-Key": "key"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			requests := 0
+			server := httptest.NewServer(http.HandlerFunc(
+				func(w http.ResponseWriter, r *http.Request) {
+					requests++
+					assert.Equal(t, "/network/list", r.URL.Path)
+					for header, value := range test.expectedHeaders {
+						assert.Equal(t, value, r.Header.Get(header))
+					}
+
+					w.Header().Set("Content-Type", "application/json")
+					_, _ = w.Write([]byte(types.PrintStruct(&types.NetworkListResponse{
+						NetworkIdentifiers: []*types.NetworkIdentifier{
+							{Blockchain: "Bitcoin", Network: "Testnet3"},
+						},
+					})))
+				},
+			))
+			defer server.Close()
+
+			config := configuration.DefaultConfiguration()
+			config.HTTP = test.http
+			serverAddress := server.URL
+			if test.proxy {
+				// Requests to an unresolvable host only
+				// succeed if sent to the proxy.
+				config.HTTP.ProxyURL = server.URL
+				serverAddress = "http://rosetta.invalid"
+			}
+
+			f, err := NewFetcher(config, serverAddress)
+			assert.NoError(t, err)
+			networks, fetchErr := f.NetworkList(context.Background(), nil)
+			assert.Nil(t, fetchErr)
+			assert.Len(t, networks.NetworkIdentifiers, 1)
+			assert.Equal(t, 1, requests)
+		})
+	}
+}
+
+// writeCertificate writes a self-signed certificate for
+// rosetta.internal (usable by clients and servers) and its
+// key to dir.
+func writeCertificate(t *testing.T, dir string) (tls.Certificate, string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "rosetta.internal"},
+		DNSNames:              []string{"rosetta.internal"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err)
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err)
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+	certFile := path.Join(dir, "cert.pem")
+	keyFile := path.Join(dir, "key.pem")
+	assert.NoError(t, ioutil.WriteFile(certFile, certPEM, 0600))
+	assert.NoError(t, ioutil.WriteFile(keyFile, keyPEM, 0600))
+
+	certificate, err := tls.X509KeyPair(certPEM, keyPEM)
+	assert.NoError(t, err)
+	certificate.Leaf, err = x509.ParseCertificate(der)
+	assert.NoError(t, err)
+
+	return certificate, certFile, keyFile
+}
+
+func TestNewFetcherTLS(t *testing.T) {
+	dir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(dir)
+
+	certificate, certFile, keyFile := writeCertificate(t, dir)
+	pool := x509.NewCertPool()
+	pool.AddCert(certificate.Leaf)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(types.PrintStruct(&types.NetworkListResponse{
+				NetworkIdentifiers: []*types.NetworkIdentifier{
+					{Blockchain: "Bitcoin", Network: "Testnet3"},
+				},
+			})))
+		},
+	))
+	server.TLS = &tls.Config{
+		Certificates: []tls.Certificate{certificate},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    pool,
+		MinVersion:   tls.VersionTLS12,
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	var tests = map[string]struct {
+		tls *configuration.TLSConfiguration
+
+		configErr error
+		err       bool
+	}{
+		"mutual tls": {
+			tls: &configuration.TLSConfiguration{
+				CertFile:   certFile,
+				KeyFile:    keyFile,
+				CAFile:     certFile,
+				ServerName: "rosetta.internal",
+			},
+		},
+		"missing client certificate": {
+			tls: &configuration.TLSConfiguration{
+				CAFile:     certFile,
+				ServerName: "rosetta.internal",
+			},
+			err: true,
+		},
+		"missing server name": {
+			tls: &configuration.TLSConfiguration{
+				CertFile: certFile,
+				KeyFile:  keyFile,
+				CAFile:   certFile,
+			},
+			err: true,
+		},
+		"missing ca": {
+			tls: &configuration.TLSConfiguration{
+				CertFile:   certFile,
+				KeyFile:    keyFile,
+				ServerName: "rosetta.internal",
+			},
+			err: true,
+		},
+		"missing certificate file": {
+			tls: &configuration.TLSConfiguration{
+				CertFile:   path.Join(dir, "missing.pem"),
+				KeyFile:    keyFile,
+				CAFile:     certFile,
+				ServerName: "rosetta.internal",
+			},
+			configErr: ErrInvalidHTTPConfiguration,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			config := configuration.DefaultConfiguration()
+			config.HTTP = &configuration.HTTPConfiguration{TLS: test.tls}
+
+			f, err := NewFetcher(config, server.URL)
+			if test.configErr != nil {
+				assert.True(t, errors.Is(err, test.configErr))
+				assert.Nil(t, f)
+				return
+			}
+			assert.NoError(t, err)
+
+			networks, fetchErr := f.NetworkList(context.Background(), nil)
+			if test.err {
+				assert.NotNil(t, fetchErr)
+				return
+			}
+
+			assert.Nil(t, fetchErr)
+			assert.Len(t, networks.NetworkIdentifiers, 1)
+		})
+	}
+}
+
+func TestConfigureTransport(t *testing.T) {
+	var tests = map[string]struct {
+		transport http.RoundTripper
+		config    *configuration.HTTPConfiguration
+
+		err error
+	}{
+		"no proxy or tls": {
+			transport: NewHeaderTransport(http.DefaultTransport, nil),
+			config:    &configuration.HTTPConfiguration{BearerToken: "token"},
+		},
+		"proxy": {
+			transport: http.DefaultTransport,
+			config:    &configuration.HTTPConfiguration{ProxyURL: "http://localhost:8080"},
+		},
+		"invalid proxy url": {
+			transport: http.DefaultTransport,
+			config:    &configuration.HTTPConfiguration{ProxyURL: "http://[::1"},
+			err:       ErrInvalidHTTPConfiguration,
+		},
+		"unsupported transport": {
+			transport: NewHeaderTransport(http.DefaultTransport, nil),
+			config:    &configuration.HTTPConfiguration{ProxyURL: "http://localhost:8080"},
+			err:       ErrInvalidHTTPConfiguration,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			transport, err := configureTransport(test.transport, test.config)
+			if test.err != nil {
+				assert.True(t, errors.Is(err, test.err))
+				assert.Nil(t, transport)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.NotNil(t, transport)
+		})
+	}
+}
@@ -0,0 +1,340 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package selftest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"time"
+
+	"github.com/coinbase/rosetta-cli/pkg/logger"
+
+	"github.com/coinbase/rosetta-sdk-go/keys"
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/olekukonko/tablewriter"
+)
+
+const (
+	// selfTestKeys is the number of keys written
+	// and scanned by the storage check.
+	selfTestKeys = 100
+
+	// payloadSize is the size of the
+	// payload signed by the signing checks.
+	payloadSize = 32
+)
+
+var (
+	// ErrMismatch is returned when data read back
+	// during a check differs from what was written.
+	ErrMismatch = errors.New("read data does not match written data")
+)
+
+// Result is the outcome of a single check.
+type Result struct {
+	Name     string        `json:"name"`
+	Duration time.Duration `json:"duration"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// Passed returns a boolean indicating if
+// the check succeeded.
+func (r *Result) Passed() bool {
+	return len(r.Error) == 0
+}
+
+// check is a single self-test. All files
+// must be written in dir.
+type check struct {
+	name string
+	run  func(ctx context.Context, dir string) error
+}
+
+// signingChecks are the curve and signature type
+// combinations supported by the construction tester.
+var signingChecks = []struct {
+	curve         types.CurveType
+	signatureType types.SignatureType
+}{
+	{curve: types.Secp256k1, signatureType: types.Ecdsa},
+	{curve: types.Secp256k1, signatureType: types.EcdsaRecovery},
+	{curve: types.Edwards25519, signatureType: types.Ed25519},
+}
+
+func checks() []*check {
+	c := []*check{
+		{name: "storage", run: checkStorage},
+		{name: "compression", run: checkCompression},
+		{name: "logger streams", run: checkLoggerStreams},
+	}
+
+	for _, s := range signingChecks {
+		curve, signatureType := s.curve, s.signatureType
+		c = append(c, &check{
+			name: fmt.Sprintf("signing (%s/%s)", curve, signatureType),
+			run: func(ctx context.Context, dir string) error {
+				return checkSigning(curve, signatureType)
+			},
+		})
+	}
+
+	return c
+}
+
+// Run executes all checks with synthetic data in a
+// temporary directory created in dir (so that the disk
+// the data directory is on is exercised) and returns
+// a *Result for each check.
+func Run(ctx context.Context, dir string) ([]*Result, error) {
+	testDir, err := ioutil.TempDir(dir, "selftest")
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to create self-test directory", err)
+	}
+	defer os.RemoveAll(testDir)
+
+	results := []*Result{}
+	for i, c := range checks() {
+		checkDir := path.Join(testDir, fmt.Sprintf("%d", i))
+		if err := os.MkdirAll(checkDir, os.ModePerm); err != nil {
+			return nil, fmt.Errorf("%w: unable to create directory for %s", err, c.name)
+		}
+
+		start := time.Now()
+		err := c.run(ctx, checkDir)
+		result := &Result{
+			Name:     c.name,
+			Duration: time.Since(start),
+		}
+		if err != nil {
+			result.Error = err.Error()
+		}
+
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// checkStorage writes keys to a new database, reads
+// them back individually, and then scans them.
+func checkStorage(ctx context.Context, dir string) error {
+	db, err := storage.NewBadgerStorage(ctx, dir)
+	if err != nil {
+		return fmt.Errorf("%w: unable to open database", err)
+	}
+	defer db.Close(ctx)
+
+	key := func(i int) []byte { return []byte(fmt.Sprintf("selftest/%03d", i)) }
+	value := func(i int) []byte { return []byte(fmt.Sprintf("value %d", i)) }
+
+	dbTx := db.NewDatabaseTransaction(ctx, true)
+	for i := 0; i < selfTestKeys; i++ {
+		if err := dbTx.Set(ctx, key(i), value(i), true); err != nil {
+			dbTx.Discard(ctx)
+			return fmt.Errorf("%w: unable to write key", err)
+		}
+	}
+	if err := dbTx.Commit(ctx); err != nil {
+		return fmt.Errorf("%w: unable to commit write", err)
+	}
+
+	dbTx = db.NewDatabaseTransaction(ctx, false)
+	defer dbTx.Discard(ctx)
+
+	for i := 0; i < selfTestKeys; i++ {
+		exists, val, err := dbTx.Get(ctx, key(i))
+		if err != nil {
+			return fmt.Errorf("%w: unable to read key", err)
+		}
+
+		if !exists || !bytes.Equal(val, value(i)) {
+			return fmt.Errorf("%w: key %s", ErrMismatch, key(i))
+		}
+	}
+
+	scanned, err := dbTx.Scan(
+		ctx,
+		[]byte("selftest/"),
+		[]byte("selftest/"),
+		func(k []byte, v []byte) error { return nil },
+		false,
+		false,
+	)
+	if err != nil {
+		return fmt.Errorf("%w: unable to scan keys", err)
+	}
+
+	if scanned != selfTestKeys {
+		return fmt.Errorf("%w: scanned %d of %d keys", ErrMismatch, scanned, selfTestKeys)
+	}
+
+	return nil
+}
+
+// syntheticBlock returns a block with a
+// transaction that transfers funds.
+func syntheticBlock() *types.Block {
+	currency := &types.Currency{Symbol: "TEST", Decimals: 8}
+	return &types.Block{
+		BlockIdentifier:       &types.BlockIdentifier{Hash: "block 1", Index: 1},
+		ParentBlockIdentifier: &types.BlockIdentifier{Hash: "block 0", Index: 0},
+		Timestamp:             1000,
+		Transactions: []*types.Transaction{
+			{
+				TransactionIdentifier: &types.TransactionIdentifier{Hash: "tx 1"},
+				Operations: []*types.Operation{
+					{
+						OperationIdentifier: &types.OperationIdentifier{Index: 0},
+						Type:                "Transfer",
+						Status:              types.String("Success"),
+						Account:             &types.AccountIdentifier{Address: "sender"},
+						Amount:              &types.Amount{Value: "-100", Currency: currency},
+					},
+					{
+						OperationIdentifier: &types.OperationIdentifier{Index: 1},
+						RelatedOperations:   []*types.OperationIdentifier{{Index: 0}},
+						Type:                "Transfer",
+						Status:              types.String("Success"),
+						Account:             &types.AccountIdentifier{Address: "recipient"},
+						Amount:              &types.Amount{Value: "100", Currency: currency},
+					},
+				},
+			},
+		},
+	}
+}
+
+// checkCompression encodes a block with the database
+// encoder (which compresses with zstd) and ensures
+// it decodes to the same block.
+func checkCompression(ctx context.Context, dir string) error {
+	db, err := storage.NewBadgerStorage(ctx, dir)
+	if err != nil {
+		return fmt.Errorf("%w: unable to open database", err)
+	}
+	defer db.Close(ctx)
+
+	block := syntheticBlock()
+	encoded, err := db.Encoder().Encode("", block)
+	if err != nil {
+		return fmt.Errorf("%w: unable to encode block", err)
+	}
+
+	var decoded types.Block
+	if err := db.Encoder().Decode("", encoded, &decoded, false); err != nil {
+		return fmt.Errorf("%w: unable to decode block", err)
+	}
+
+	if types.Hash(block) != types.Hash(&decoded) {
+		return fmt.Errorf("%w: decoded block", ErrMismatch)
+	}
+
+	return nil
+}
+
+// checkSigning generates a key on curve and ensures a
+// signature of signatureType can be created and verified.
+func checkSigning(curve types.CurveType, signatureType types.SignatureType) error {
+	keyPair, err := keys.GenerateKeypair(curve)
+	if err != nil {
+		return fmt.Errorf("%w: unable to generate key", err)
+	}
+
+	signer, err := keyPair.Signer()
+	if err != nil {
+		return fmt.Errorf("%w: unable to create signer", err)
+	}
+
+	signature, err := signer.Sign(&types.SigningPayload{
+		AccountIdentifier: &types.AccountIdentifier{Address: "selftest"},
+		Bytes:             bytes.Repeat([]byte{0xab}, payloadSize),
+		SignatureType:     signatureType,
+	}, signatureType)
+	if err != nil {
+		return fmt.Errorf("%w: unable to sign payload", err)
+	}
+
+	if err := signer.Verify(signature); err != nil {
+		return fmt.Errorf("%w: unable to verify signature", err)
+	}
+
+	return nil
+}
+
+// checkLoggerStreams writes a synthetic block (and its
+// transactions) to the logger streams and ensures the
+// stream files were written.
+func checkLoggerStreams(ctx context.Context, dir string) error {
+	l := logger.NewLogger(dir, true, true, false, false, false)
+
+	block := syntheticBlock()
+	if err := l.AddBlockStream(ctx, block); err != nil {
+		return fmt.Errorf("%w: unable to write block stream", err)
+	}
+
+	if err := l.RemoveBlockStream(ctx, block.BlockIdentifier); err != nil {
+		return fmt.Errorf("%w: unable to write block stream", err)
+	}
+
+	if err := l.Close(); err != nil {
+		return fmt.Errorf("%w: unable to flush logger streams", err)
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("%w: unable to read logger directory", err)
+	}
+
+	for _, file := range files {
+		if file.Size() == 0 {
+			return fmt.Errorf("%w: %s is empty", ErrMismatch, file.Name())
+		}
+	}
+
+	if len(files) == 0 {
+		return fmt.Errorf("%w: no stream files written", ErrMismatch)
+	}
+
+	return nil
+}
+
+// Print prints results as a table.
+func Print(results []*Result) {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetRowLine(true)
+	table.SetRowSeparator("-")
+	table.SetHeader([]string{"Check", "Status", "Duration", "Error"})
+	for _, result := range results {
+		status := "PASSED"
+		if !result.Passed() {
+			status = "FAILED"
+		}
+
+		table.Append([]string{
+			result.Name,
+			status,
+			result.Duration.String(),
+			result.Error,
+		})
+	}
+
+	table.Render()
+}
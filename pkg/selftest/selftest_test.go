@@ -0,0 +1,48 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package selftest
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRun(t *testing.T) {
+	dir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(dir)
+
+	results, err := Run(context.Background(), dir)
+	assert.NoError(t, err)
+	assert.Len(t, results, len(checks()))
+	for _, result := range results {
+		assert.True(t, result.Passed(), "%s: %s", result.Name, result.Error)
+	}
+
+	// The self-test directory is removed.
+	files, err := ioutil.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, files, 0)
+}
+
+func TestRunMissingDirectory(t *testing.T) {
+	results, err := Run(context.Background(), "/path/does/not/exist")
+	assert.Error(t, err)
+	assert.Nil(t, results)
+}
@@ -0,0 +1,317 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package serve
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+var (
+	// ErrInvalidRequest is returned when a request
+	// cannot be decoded.
+	ErrInvalidRequest = &types.Error{
+		Code:    1,
+		Message: "invalid request",
+	}
+
+	// ErrNetworkNotSupported is returned when a request
+	// is made for a network that is not served.
+	ErrNetworkNotSupported = &types.Error{
+		Code:    2,
+		Message: "network not supported",
+	}
+
+	// ErrBlockNotFound is returned when a block is not
+	// in storage (it may not be synced or may be pruned).
+	ErrBlockNotFound = &types.Error{
+		Code:    3,
+		Message: "block not found",
+	}
+
+	// ErrBalanceNotFound is returned when a balance is
+	// not in storage (it may not be synced or may be pruned).
+	ErrBalanceNotFound = &types.Error{
+		Code:    4,
+		Message: "balance not found",
+	}
+
+	// ErrInternal is returned when storage returns
+	// an unexpected error.
+	ErrInternal = &types.Error{
+		Code:    5,
+		Message: "internal error",
+	}
+
+	// Errors are all errors returned by the Server.
+	Errors = []*types.Error{
+		ErrInvalidRequest,
+		ErrNetworkNotSupported,
+		ErrBlockNotFound,
+		ErrBalanceNotFound,
+		ErrInternal,
+	}
+)
+
+// Server serves a subset of the Rosetta Data API
+// (/network/list, /network/status, /block, and
+// /account/balance) from blocks and balances stored
+// by check:data. No requests are made to a node.
+type Server struct {
+	network        *types.NetworkIdentifier
+	database       storage.Database
+	blockStorage   *storage.BlockStorage
+	balanceStorage *storage.BalanceStorage
+}
+
+// New returns a new *Server for network.
+func New(
+	network *types.NetworkIdentifier,
+	database storage.Database,
+	blockStorage *storage.BlockStorage,
+	balanceStorage *storage.BalanceStorage,
+) *Server {
+	return &Server{
+		network:        network,
+		database:       database,
+		blockStorage:   blockStorage,
+		balanceStorage: balanceStorage,
+	}
+}
+
+// Handler returns an http.Handler that
+// serves all supported endpoints.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/network/list", s.handle(s.networkList))
+	mux.HandleFunc("/network/status", s.handle(s.networkStatus))
+	mux.HandleFunc("/block", s.handle(s.block))
+	mux.HandleFunc("/account/balance", s.handle(s.accountBalance))
+
+	return mux
+}
+
+// endpoint decodes a request from body and returns
+// the response (or a *types.Error).
+type endpoint func(ctx context.Context, body *json.Decoder) (interface{}, *types.Error)
+
+// handle adapts an endpoint to an http.HandlerFunc.
+func (s *Server) handle(e endpoint) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		response, rosettaErr := e(r.Context(), json.NewDecoder(r.Body))
+		if rosettaErr != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			_ = json.NewEncoder(w).Encode(rosettaErr)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(response)
+	}
+}
+
+// wrapErr returns a copy of rosettaErr with
+// err included in the details.
+func wrapErr(rosettaErr *types.Error, err error) *types.Error {
+	wrapped := *rosettaErr
+	wrapped.Details = map[string]interface{}{"context": err.Error()}
+	return &wrapped
+}
+
+// checkNetwork returns an error if network
+// is not the network served by s.
+func (s *Server) checkNetwork(network *types.NetworkIdentifier) *types.Error {
+	if types.Hash(network) != types.Hash(s.network) {
+		return wrapErr(
+			ErrNetworkNotSupported,
+			fmt.Errorf("%s is not supported", types.PrintStruct(network)),
+		)
+	}
+
+	return nil
+}
+
+// blockErr returns ErrBlockNotFound if err indicates
+// the block is not in storage, otherwise ErrInternal.
+func blockErr(err error) *types.Error {
+	if errors.Is(err, storage.ErrBlockNotFound) || errors.Is(err, storage.ErrHeadBlockNotFound) {
+		return wrapErr(ErrBlockNotFound, err)
+	}
+
+	return wrapErr(ErrInternal, err)
+}
+
+func (s *Server) networkList(
+	ctx context.Context,
+	body *json.Decoder,
+) (interface{}, *types.Error) {
+	var request types.MetadataRequest
+	if err := body.Decode(&request); err != nil {
+		return nil, wrapErr(ErrInvalidRequest, err)
+	}
+
+	return &types.NetworkListResponse{
+		NetworkIdentifiers: []*types.NetworkIdentifier{s.network},
+	}, nil
+}
+
+// networkStatus returns the head block in storage as the
+// current block. The genesis block is the block at index 0
+// (or the oldest block in storage if it was pruned).
+func (s *Server) networkStatus(
+	ctx context.Context,
+	body *json.Decoder,
+) (interface{}, *types.Error) {
+	var request types.NetworkRequest
+	if err := body.Decode(&request); err != nil {
+		return nil, wrapErr(ErrInvalidRequest, err)
+	}
+
+	if err := s.checkNetwork(request.NetworkIdentifier); err != nil {
+		return nil, err
+	}
+
+	head, err := s.blockStorage.GetBlock(ctx, nil)
+	if err != nil {
+		return nil, blockErr(err)
+	}
+
+	genesisIndex := int64(0)
+	genesis, err := s.blockStorage.GetBlock(
+		ctx,
+		&types.PartialBlockIdentifier{Index: &genesisIndex},
+	)
+	if errors.Is(err, storage.ErrBlockNotFound) {
+		oldestIndex, oldestErr := s.blockStorage.GetOldestBlockIndex(ctx)
+		if oldestErr != nil {
+			return nil, wrapErr(ErrInternal, oldestErr)
+		}
+
+		genesis, err = s.blockStorage.GetBlock(
+			ctx,
+			&types.PartialBlockIdentifier{Index: &oldestIndex},
+		)
+	}
+	if err != nil {
+		return nil, blockErr(err)
+	}
+
+	return &types.NetworkStatusResponse{
+		CurrentBlockIdentifier: head.BlockIdentifier,
+		CurrentBlockTimestamp:  head.Timestamp,
+		GenesisBlockIdentifier: genesis.BlockIdentifier,
+		Peers:                  []*types.Peer{},
+	}, nil
+}
+
+func (s *Server) block(
+	ctx context.Context,
+	body *json.Decoder,
+) (interface{}, *types.Error) {
+	var request types.BlockRequest
+	if err := body.Decode(&request); err != nil {
+		return nil, wrapErr(ErrInvalidRequest, err)
+	}
+
+	if err := s.checkNetwork(request.NetworkIdentifier); err != nil {
+		return nil, err
+	}
+
+	block, err := s.blockStorage.GetBlock(ctx, request.BlockIdentifier)
+	if err != nil {
+		return nil, blockErr(err)
+	}
+
+	return &types.BlockResponse{Block: block}, nil
+}
+
+// accountBalance returns the computed balance of an account
+// at the requested block (or the head block). If no currencies
+// are requested, the balances of all currencies seen for the
+// account are returned.
+func (s *Server) accountBalance(
+	ctx context.Context,
+	body *json.Decoder,
+) (interface{}, *types.Error) {
+	var request types.AccountBalanceRequest
+	if err := body.Decode(&request); err != nil {
+		return nil, wrapErr(ErrInvalidRequest, err)
+	}
+
+	if err := s.checkNetwork(request.NetworkIdentifier); err != nil {
+		return nil, err
+	}
+
+	if request.AccountIdentifier == nil {
+		return nil, wrapErr(ErrInvalidRequest, errors.New("account identifier is missing"))
+	}
+
+	// A nil *types.PartialBlockIdentifier
+	// returns the head block.
+	block, err := s.blockStorage.GetBlock(ctx, request.BlockIdentifier)
+	if err != nil {
+		return nil, blockErr(err)
+	}
+
+	currencies := request.Currencies
+	if len(currencies) == 0 {
+		accountCurrencies, err := s.balanceStorage.GetAllAccountCurrency(ctx)
+		if err != nil {
+			return nil, wrapErr(ErrInternal, err)
+		}
+
+		accountKey := types.Hash(request.AccountIdentifier)
+		for _, accountCurrency := range accountCurrencies {
+			if types.Hash(accountCurrency.Account) == accountKey {
+				currencies = append(currencies, accountCurrency.Currency)
+			}
+		}
+	}
+
+	dbTx := s.database.NewDatabaseTransaction(ctx, false)
+	defer dbTx.Discard(ctx)
+
+	balances := make([]*types.Amount, len(currencies))
+	for i, currency := range currencies {
+		balances[i], err = s.balanceStorage.GetBalanceTransactional(
+			ctx,
+			dbTx,
+			request.AccountIdentifier,
+			currency,
+			block.BlockIdentifier.Index,
+		)
+		if err != nil {
+			return nil, wrapErr(ErrBalanceNotFound, err)
+		}
+	}
+
+	return &types.AccountBalanceResponse{
+		BlockIdentifier: block.BlockIdentifier,
+		Balances:        balances,
+	}, nil
+}
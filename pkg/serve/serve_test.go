@@ -0,0 +1,158 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package serve
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/coinbase/rosetta-sdk-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func post(
+	t *testing.T,
+	handler http.Handler,
+	path string,
+	request interface{},
+	response interface{},
+) int {
+	body, err := json.Marshal(request)
+	assert.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, path, bytes.NewReader(body)))
+	assert.NoError(t, json.NewDecoder(recorder.Body).Decode(response))
+
+	return recorder.Code
+}
+
+func TestServer(t *testing.T) {
+	ctx := context.Background()
+	dir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(dir)
+
+	database, err := storage.NewBadgerStorage(ctx, dir)
+	assert.NoError(t, err)
+	defer database.Close(ctx)
+
+	blockStorage := storage.NewBlockStorage(database)
+	blockStorage.Initialize([]storage.BlockWorker{})
+	balanceStorage := storage.NewBalanceStorage(database)
+
+	network := &types.NetworkIdentifier{Blockchain: "Bitcoin", Network: "Testnet"}
+	account := &types.AccountIdentifier{Address: "addr"}
+	btc := &types.Currency{Symbol: "BTC", Decimals: 8}
+	blocks := []*types.Block{
+		{
+			BlockIdentifier:       &types.BlockIdentifier{Hash: "block 0", Index: 0},
+			ParentBlockIdentifier: &types.BlockIdentifier{Hash: "block 0", Index: 0},
+			Timestamp:             1000,
+		},
+		{
+			BlockIdentifier:       &types.BlockIdentifier{Hash: "block 1", Index: 1},
+			ParentBlockIdentifier: &types.BlockIdentifier{Hash: "block 0", Index: 0},
+			Timestamp:             2000,
+		},
+	}
+	for _, block := range blocks {
+		assert.NoError(t, blockStorage.AddBlock(ctx, block))
+	}
+
+	dbTx := database.NewDatabaseTransaction(ctx, true)
+	assert.NoError(t, balanceStorage.SetBalance(
+		ctx,
+		dbTx,
+		account,
+		&types.Amount{Value: "100", Currency: btc},
+		blocks[0].BlockIdentifier,
+	))
+	assert.NoError(t, dbTx.Commit(ctx))
+
+	handler := New(network, database, blockStorage, balanceStorage).Handler()
+
+	t.Run("network list", func(t *testing.T) {
+		var response types.NetworkListResponse
+		code := post(t, handler, "/network/list", &types.MetadataRequest{}, &response)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, []*types.NetworkIdentifier{network}, response.NetworkIdentifiers)
+	})
+
+	t.Run("network status", func(t *testing.T) {
+		var response types.NetworkStatusResponse
+		code := post(t, handler, "/network/status", &types.NetworkRequest{
+			NetworkIdentifier: network,
+		}, &response)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, blocks[1].BlockIdentifier, response.CurrentBlockIdentifier)
+		assert.Equal(t, int64(2000), response.CurrentBlockTimestamp)
+		assert.Equal(t, blocks[0].BlockIdentifier, response.GenesisBlockIdentifier)
+	})
+
+	t.Run("unsupported network", func(t *testing.T) {
+		var response types.Error
+		code := post(t, handler, "/network/status", &types.NetworkRequest{
+			NetworkIdentifier: &types.NetworkIdentifier{Blockchain: "Bitcoin", Network: "Mainnet"},
+		}, &response)
+		assert.Equal(t, http.StatusInternalServerError, code)
+		assert.Equal(t, ErrNetworkNotSupported.Code, response.Code)
+	})
+
+	t.Run("block", func(t *testing.T) {
+		var response types.BlockResponse
+		index := int64(1)
+		code := post(t, handler, "/block", &types.BlockRequest{
+			NetworkIdentifier: network,
+			BlockIdentifier:   &types.PartialBlockIdentifier{Index: &index},
+		}, &response)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, blocks[1].BlockIdentifier, response.Block.BlockIdentifier)
+	})
+
+	t.Run("missing block", func(t *testing.T) {
+		var response types.Error
+		index := int64(10)
+		code := post(t, handler, "/block", &types.BlockRequest{
+			NetworkIdentifier: network,
+			BlockIdentifier:   &types.PartialBlockIdentifier{Index: &index},
+		}, &response)
+		assert.Equal(t, http.StatusInternalServerError, code)
+		assert.Equal(t, ErrBlockNotFound.Code, response.Code)
+	})
+
+	t.Run("account balance", func(t *testing.T) {
+		var response types.AccountBalanceResponse
+		code := post(t, handler, "/account/balance", &types.AccountBalanceRequest{
+			NetworkIdentifier: network,
+			AccountIdentifier: account,
+		}, &response)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, blocks[1].BlockIdentifier, response.BlockIdentifier)
+		assert.Equal(t, []*types.Amount{{Value: "100", Currency: btc}}, response.Balances)
+	})
+
+	t.Run("method not allowed", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/block", nil))
+		assert.Equal(t, http.StatusMethodNotAllowed, recorder.Code)
+	})
+}
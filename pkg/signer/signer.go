@@ -0,0 +1,247 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package signer delegates signing to an external signing backend
+// so that the private keys of prefunded accounts can live in a cloud
+// KMS (like AWS KMS or GCP KMS) or an HSM (over PKCS#11) instead of
+// the rosetta-cli database. A signing backend is any executable that
+// uses the same framing as block worker plugins (see pkg/plugin): it
+// reads requests (one JSON object per line) from stdin and writes
+// exactly one response (one JSON object per line) to stdout for each
+// request.
+//
+// A signing backend must support two types of Request:
+//
+//	public_key  respond with the public key of key_id
+//	sign        respond with the signature of payload by key_id
+//	            (hex encoded, of the payload's signature_type)
+//
+// If the signing backend responds with an error, the payload is not
+// signed (and the job that requested the signature fails).
+package signer
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/coinbase/rosetta-cli/configuration"
+	"github.com/coinbase/rosetta-cli/pkg/plugin"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+const (
+	// ProtocolVersion is the version of the signing protocol.
+	// It is included in every Request so signing backends can
+	// reject requests they do not understand.
+	ProtocolVersion = 1
+
+	// PublicKeyRequest is the Type of a Request
+	// for the public key of a key.
+	PublicKeyRequest = "public_key"
+
+	// SignRequest is the Type of a Request
+	// to sign a payload with a key.
+	SignRequest = "sign"
+
+	// backendName identifies the signing
+	// backend in logs.
+	backendName = "remote_signer"
+)
+
+var (
+	// ErrBackendError is returned when the signing backend
+	// responds to a request with an error.
+	ErrBackendError = errors.New("signing backend returned error")
+
+	// ErrUnknownAccount is returned when a payload must be
+	// signed by an account not managed by the signing backend.
+	ErrUnknownAccount = errors.New("account not managed by signing backend")
+
+	// ErrInvalidResponse is returned when the signing backend
+	// responds without the requested public key or signature.
+	ErrInvalidResponse = errors.New("invalid signing backend response")
+)
+
+// Request is sent to the signing backend.
+type Request struct {
+	Version int                   `json:"version"`
+	Type    string                `json:"type"`
+	KeyID   string                `json:"key_id"`
+	Payload *types.SigningPayload `json:"payload,omitempty"`
+}
+
+// Response is returned by the signing backend for each
+// Request. If Error is populated, the request failed.
+type Response struct {
+	Error        string           `json:"error,omitempty"`
+	PublicKey    *types.PublicKey `json:"public_key,omitempty"`
+	SignatureHex string           `json:"signature_hex,omitempty"`
+}
+
+// Remote signs payloads for the accounts
+// managed by a signing backend.
+type Remote struct {
+	backend *plugin.Plugin
+
+	// keyIDs are the key IDs of all managed
+	// accounts (keyed by types.Hash(account)).
+	keyIDs map[string]string
+
+	// publicKeys caches the public key of
+	// each key ID once it is fetched.
+	publicKeys map[string]*types.PublicKey
+	mutex      sync.Mutex
+}
+
+// Start starts the signing backend described by config.
+func Start(config *configuration.RemoteSigner) (*Remote, error) {
+	backend, err := plugin.Start(
+		backendName,
+		config.Path,
+		config.Args,
+		nil,
+		time.Duration(config.Timeout)*time.Second,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to start signing backend", err)
+	}
+
+	keyIDs := map[string]string{}
+	for _, account := range config.Accounts {
+		keyIDs[types.Hash(account.AccountIdentifier)] = account.KeyID
+	}
+
+	return &Remote{
+		backend:    backend,
+		keyIDs:     keyIDs,
+		publicKeys: map[string]*types.PublicKey{},
+	}, nil
+}
+
+// Manages returns a boolean indicating if the private
+// key of account is managed by the signing backend.
+func (r *Remote) Manages(account *types.AccountIdentifier) bool {
+	_, ok := r.keyIDs[types.Hash(account)]
+	return ok
+}
+
+// call sends request to the signing backend and returns
+// its response.
+func (r *Remote) call(ctx context.Context, request *Request) (*Response, error) {
+	request.Version = ProtocolVersion
+
+	var response Response
+	if err := r.backend.Call(ctx, request, &response); err != nil {
+		return nil, err
+	}
+
+	if len(response.Error) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrBackendError, response.Error)
+	}
+
+	return &response, nil
+}
+
+// keyID returns the key ID of account.
+func (r *Remote) keyID(account *types.AccountIdentifier) (string, error) {
+	keyID, ok := r.keyIDs[types.Hash(account)]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnknownAccount, types.AccountString(account))
+	}
+
+	return keyID, nil
+}
+
+// PublicKey returns the public key of account
+// (fetching it from the signing backend once).
+func (r *Remote) PublicKey(
+	ctx context.Context,
+	account *types.AccountIdentifier,
+) (*types.PublicKey, error) {
+	keyID, err := r.keyID(account)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mutex.Lock()
+	publicKey, ok := r.publicKeys[keyID]
+	r.mutex.Unlock()
+	if ok {
+		return publicKey, nil
+	}
+
+	response, err := r.call(ctx, &Request{Type: PublicKeyRequest, KeyID: keyID})
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to get public key %s", err, keyID)
+	}
+
+	if response.PublicKey == nil || len(response.PublicKey.Bytes) == 0 {
+		return nil, fmt.Errorf("%w: public key %s is missing", ErrInvalidResponse, keyID)
+	}
+
+	r.mutex.Lock()
+	r.publicKeys[keyID] = response.PublicKey
+	r.mutex.Unlock()
+
+	return response.PublicKey, nil
+}
+
+// Sign signs payload with the key of the
+// payload's account.
+func (r *Remote) Sign(
+	ctx context.Context,
+	payload *types.SigningPayload,
+) (*types.Signature, error) {
+	keyID, err := r.keyID(payload.AccountIdentifier)
+	if err != nil {
+		return nil, err
+	}
+
+	publicKey, err := r.PublicKey(ctx, payload.AccountIdentifier)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := r.call(ctx, &Request{Type: SignRequest, KeyID: keyID, Payload: payload})
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to sign with %s", err, keyID)
+	}
+
+	signature, err := hex.DecodeString(response.SignatureHex)
+	if err != nil || len(signature) == 0 {
+		return nil, fmt.Errorf(
+			"%w: signature %s from %s is not hex encoded",
+			ErrInvalidResponse,
+			response.SignatureHex,
+			keyID,
+		)
+	}
+
+	return &types.Signature{
+		SigningPayload: payload,
+		PublicKey:      publicKey,
+		SignatureType:  payload.SignatureType,
+		Bytes:          signature,
+	}, nil
+}
+
+// Close stops the signing backend.
+func (r *Remote) Close() error {
+	return r.backend.Close()
+}
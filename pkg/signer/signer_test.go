@@ -0,0 +1,120 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package signer
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/coinbase/rosetta-cli/configuration"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	account = &types.AccountIdentifier{Address: "addr1"}
+	payload = &types.SigningPayload{
+		AccountIdentifier: account,
+		Bytes:             []byte("payload"),
+		SignatureType:     types.Ecdsa,
+	}
+)
+
+// backendScript responds to public_key requests for
+// key-1 with a fixed public key and to sign requests
+// for key-1 with signature.
+func backendScript(signature string) string {
+	return `while read line; do
+  case "$line" in
+    *'"version":1,"type":"public_key","key_id":"key-1"'*)
+      echo '{"public_key":{"hex_bytes":"0102","curve_type":"secp256k1"}}' ;;
+    *'"version":1,"type":"sign","key_id":"key-1","payload":{'*'"signature_type":"ecdsa"'*)
+      echo '` + signature + `' ;;
+    *) echo '{"error":"unexpected request"}' ;;
+  esac
+done`
+}
+
+func TestRemote(t *testing.T) {
+	var tests = map[string]struct {
+		script string
+
+		signature *types.Signature
+		err       error
+	}{
+		"success": {
+			script: backendScript(`{"signature_hex":"abcd"}`),
+			signature: &types.Signature{
+				SigningPayload: payload,
+				PublicKey:      &types.PublicKey{Bytes: []byte{1, 2}, CurveType: types.Secp256k1},
+				SignatureType:  types.Ecdsa,
+				Bytes:          []byte{0xab, 0xcd},
+			},
+		},
+		"backend error": {
+			script: backendScript(`{"error":"key disabled"}`),
+			err:    ErrBackendError,
+		},
+		"invalid signature": {
+			script: backendScript(`{"signature_hex":"not hex"}`),
+			err:    ErrInvalidResponse,
+		},
+		"missing public key": {
+			script: `while read line; do echo '{}'; done`,
+			err:    ErrInvalidResponse,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			r, err := Start(&configuration.RemoteSigner{
+				Path: "sh",
+				Args: []string{"-c", test.script},
+				Accounts: []*configuration.RemoteAccount{
+					{KeyID: "key-1", AccountIdentifier: account},
+				},
+			})
+			assert.NoError(t, err)
+			defer r.Close()
+
+			assert.True(t, r.Manages(account))
+			assert.False(t, r.Manages(&types.AccountIdentifier{Address: "addr2"}))
+
+			signature, err := r.Sign(context.Background(), payload)
+			if test.err != nil {
+				assert.True(t, errors.Is(err, test.err))
+				assert.Nil(t, signature)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, test.signature, signature)
+		})
+	}
+
+	t.Run("unknown account", func(t *testing.T) {
+		r, err := Start(&configuration.RemoteSigner{
+			Path: "sh",
+			Args: []string{"-c", backendScript(`{"signature_hex":"abcd"}`)},
+		})
+		assert.NoError(t, err)
+		defer r.Close()
+
+		_, err = r.Sign(context.Background(), payload)
+		assert.True(t, errors.Is(err, ErrUnknownAccount))
+	})
+}
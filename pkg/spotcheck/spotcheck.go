@@ -0,0 +1,352 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spotcheck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/coinbase/rosetta-sdk-go/fetcher"
+	"github.com/coinbase/rosetta-sdk-go/parser"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/olekukonko/tablewriter"
+)
+
+var (
+	// ErrNoBlocks is returned when the chain has
+	// no blocks after the genesis block to sample.
+	ErrNoBlocks = errors.New("no blocks to sample")
+
+	// ErrParentMismatch is returned when walking back from
+	// the current block and the block returned for the parent
+	// of a block is not its parent.
+	ErrParentMismatch = errors.New("returned block is not the requested parent")
+)
+
+// Fetcher is the subset of *fetcher.Fetcher
+// used to spot-check an implementation.
+type Fetcher interface {
+	NetworkStatusRetry(
+		ctx context.Context,
+		network *types.NetworkIdentifier,
+		metadata map[string]interface{},
+	) (*types.NetworkStatusResponse, *fetcher.Error)
+
+	BlockRetry(
+		ctx context.Context,
+		network *types.NetworkIdentifier,
+		blockIdentifier *types.PartialBlockIdentifier,
+	) (*types.Block, *fetcher.Error)
+
+	AccountBalanceRetry(
+		ctx context.Context,
+		network *types.NetworkIdentifier,
+		account *types.AccountIdentifier,
+		block *types.PartialBlockIdentifier,
+		currencies []*types.Currency,
+	) (*types.BlockIdentifier, []*types.Amount, map[string]interface{}, *fetcher.Error)
+}
+
+// Mismatch is a balance change in a sampled block that
+// is not reflected in the historical balances returned
+// by the implementation. The balance at the parent block
+// plus the difference should equal the balance at the block.
+type Mismatch struct {
+	Block         *types.BlockIdentifier   `json:"block"`
+	Account       *types.AccountIdentifier `json:"account"`
+	Currency      *types.Currency          `json:"currency"`
+	ParentBalance string                   `json:"parent_balance"`
+	Difference    string                   `json:"difference"`
+	Balance       string                   `json:"balance"`
+}
+
+// Results summarizes a spot-check.
+type Results struct {
+	BlocksChecked   int         `json:"blocks_checked"`
+	AccountsChecked int         `json:"accounts_checked"`
+	Mismatches      []*Mismatch `json:"mismatches"`
+}
+
+// Checker fetches randomly sampled blocks and ensures the
+// historical balances of a sample of the accounts whose
+// balances change in each block are consistent with
+// its operations.
+type Checker struct {
+	network  *types.NetworkIdentifier
+	fetcher  Fetcher
+	parser   *parser.Parser
+	accounts int
+}
+
+// New returns a new *Checker that checks up to
+// accounts balance changes in each sampled block.
+func New(
+	network *types.NetworkIdentifier,
+	fetcher Fetcher,
+	parser *parser.Parser,
+	accounts int,
+) *Checker {
+	return &Checker{
+		network:  network,
+		fetcher:  fetcher,
+		parser:   parser,
+		accounts: accounts,
+	}
+}
+
+// sampleIndices returns up to samples distinct indices
+// in (genesis, tip] in ascending order.
+func sampleIndices(genesis int64, tip int64, samples int) []int64 {
+	available := tip - genesis
+	if int64(samples) >= available {
+		indices := make([]int64, available)
+		for i := range indices {
+			indices[i] = genesis + 1 + int64(i)
+		}
+
+		return indices
+	}
+
+	selected := map[int64]struct{}{}
+	indices := make([]int64, 0, samples)
+	for len(indices) < samples {
+		index := genesis + 1 + rand.Int63n(available) // #nosec G404
+		if _, ok := selected[index]; ok {
+			continue
+		}
+
+		selected[index] = struct{}{}
+		indices = append(indices, index)
+	}
+
+	sort.Slice(indices, func(i, j int) bool { return indices[i] < indices[j] })
+	return indices
+}
+
+// balance returns the balance of account in currency at block.
+func (c *Checker) balance(
+	ctx context.Context,
+	account *types.AccountIdentifier,
+	currency *types.Currency,
+	block *types.BlockIdentifier,
+) (string, error) {
+	fetchedBlock, amounts, _, fetchErr := c.fetcher.AccountBalanceRetry(
+		ctx,
+		c.network,
+		account,
+		&types.PartialBlockIdentifier{Index: &block.Index, Hash: &block.Hash},
+		nil,
+	)
+	if fetchErr != nil {
+		return "", fmt.Errorf(
+			"%w: unable to fetch balance of %s at %d",
+			fetchErr.Err,
+			types.AccountString(account),
+			block.Index,
+		)
+	}
+
+	if types.Hash(fetchedBlock) != types.Hash(block) {
+		return "", fmt.Errorf(
+			"balance of %s requested at %s but returned at %s",
+			types.AccountString(account),
+			types.PrintStruct(block),
+			types.PrintStruct(fetchedBlock),
+		)
+	}
+
+	for _, amount := range amounts {
+		if types.Hash(amount.Currency) == types.Hash(currency) {
+			return amount.Value, nil
+		}
+	}
+
+	// Currencies with no balance are
+	// often omitted from responses.
+	return "0", nil
+}
+
+// checkBlock fetches the block at index and checks the
+// historical balances of a sample of its balance changes.
+func (c *Checker) checkBlock(ctx context.Context, index int64, results *Results) error {
+	block, fetchErr := c.fetcher.BlockRetry(
+		ctx,
+		c.network,
+		&types.PartialBlockIdentifier{Index: &index},
+	)
+	if fetchErr != nil {
+		return fmt.Errorf("%w: unable to fetch block %d", fetchErr.Err, index)
+	}
+
+	return c.checkBalances(ctx, block, results)
+}
+
+// checkBalances checks the historical balances of a
+// sample of the balance changes in block.
+func (c *Checker) checkBalances(ctx context.Context, block *types.Block, results *Results) error {
+	index := block.BlockIdentifier.Index
+	changes, err := c.parser.BalanceChanges(ctx, block, false)
+	if err != nil {
+		return fmt.Errorf("%w: unable to calculate balance changes in block %d", err, index)
+	}
+
+	rand.Shuffle(len(changes), func(i, j int) { // #nosec G404
+		changes[i], changes[j] = changes[j], changes[i]
+	})
+	if len(changes) > c.accounts {
+		changes = changes[:c.accounts]
+	}
+
+	for _, change := range changes {
+		parentBalance, err := c.balance(ctx, change.Account, change.Currency, block.ParentBlockIdentifier)
+		if err != nil {
+			return err
+		}
+
+		balance, err := c.balance(ctx, change.Account, change.Currency, block.BlockIdentifier)
+		if err != nil {
+			return err
+		}
+
+		results.AccountsChecked++
+		expected, err := types.AddValues(parentBalance, change.Difference)
+		if err != nil {
+			return fmt.Errorf("%w: unable to add balance change", err)
+		}
+
+		if expected == balance {
+			continue
+		}
+
+		mismatch := &Mismatch{
+			Block:         block.BlockIdentifier,
+			Account:       change.Account,
+			Currency:      change.Currency,
+			ParentBalance: parentBalance,
+			Difference:    change.Difference,
+			Balance:       balance,
+		}
+		log.Printf(
+			"balance of %s at %d is %s%s (expected %s%s)\n",
+			types.AccountString(change.Account),
+			block.BlockIdentifier.Index,
+			balance,
+			change.Currency.Symbol,
+			expected,
+			change.Currency.Symbol,
+		)
+		results.Mismatches = append(results.Mismatches, mismatch)
+	}
+
+	results.BlocksChecked++
+	return nil
+}
+
+// Run samples up to samples blocks after the genesis block
+// (up to the current block) and checks each of them.
+func (c *Checker) Run(ctx context.Context, samples int) (*Results, error) {
+	status, fetchErr := c.fetcher.NetworkStatusRetry(ctx, c.network, nil)
+	if fetchErr != nil {
+		return nil, fmt.Errorf("%w: unable to fetch network status", fetchErr.Err)
+	}
+
+	genesis := status.GenesisBlockIdentifier.Index
+	tip := status.CurrentBlockIdentifier.Index
+	if tip <= genesis {
+		return nil, ErrNoBlocks
+	}
+
+	results := &Results{Mismatches: []*Mismatch{}}
+	for _, index := range sampleIndices(genesis, tip, samples) {
+		if err := c.checkBlock(ctx, index, results); err != nil {
+			return nil, err
+		}
+	}
+
+	return results, nil
+}
+
+// RunFromTip checks up to depth blocks, starting at the current
+// block and walking back through the parent of each block (until
+// the block after the genesis block). No state is maintained
+// across blocks except for the parent to fetch next. Each parent
+// is fetched by its hash, so the walk follows the chain returned
+// at the current block even if it is reorged during the walk.
+func (c *Checker) RunFromTip(ctx context.Context, depth int) (*Results, error) {
+	status, fetchErr := c.fetcher.NetworkStatusRetry(ctx, c.network, nil)
+	if fetchErr != nil {
+		return nil, fmt.Errorf("%w: unable to fetch network status", fetchErr.Err)
+	}
+
+	genesis := status.GenesisBlockIdentifier.Index
+	next := status.CurrentBlockIdentifier
+	if next.Index <= genesis {
+		return nil, ErrNoBlocks
+	}
+
+	results := &Results{Mismatches: []*Mismatch{}}
+	for i := 0; i < depth && next.Index > genesis; i++ {
+		block, fetchErr := c.fetcher.BlockRetry(
+			ctx,
+			c.network,
+			&types.PartialBlockIdentifier{Index: &next.Index, Hash: &next.Hash},
+		)
+		if fetchErr != nil {
+			return nil, fmt.Errorf("%w: unable to fetch block %d", fetchErr.Err, next.Index)
+		}
+
+		if types.Hash(block.BlockIdentifier) != types.Hash(next) {
+			return nil, fmt.Errorf(
+				"%w: requested %s but received %s",
+				ErrParentMismatch,
+				types.PrintStruct(next),
+				types.PrintStruct(block.BlockIdentifier),
+			)
+		}
+
+		if err := c.checkBalances(ctx, block, results); err != nil {
+			return nil, err
+		}
+
+		next = block.ParentBlockIdentifier
+	}
+
+	return results, nil
+}
+
+// Print logs results to the console.
+func (r *Results) Print() {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetRowLine(true)
+	table.SetRowSeparator("-")
+	table.SetHeader([]string{"Block", "Account", "Parent Balance", "Difference", "Balance"})
+	for _, mismatch := range r.Mismatches {
+		table.Append([]string{
+			strconv.FormatInt(mismatch.Block.Index, 10),
+			types.AccountString(mismatch.Account),
+			mismatch.ParentBalance + mismatch.Currency.Symbol,
+			mismatch.Difference + mismatch.Currency.Symbol,
+			mismatch.Balance + mismatch.Currency.Symbol,
+		})
+	}
+
+	table.Render()
+}
@@ -0,0 +1,245 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spotcheck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/asserter"
+	"github.com/coinbase/rosetta-sdk-go/fetcher"
+	"github.com/coinbase/rosetta-sdk-go/parser"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	network  = &types.NetworkIdentifier{Blockchain: "Bitcoin", Network: "Testnet3"}
+	currency = &types.Currency{Symbol: "BTC", Decimals: 8}
+)
+
+func blockIdentifier(index int64) *types.BlockIdentifier {
+	return &types.BlockIdentifier{Hash: fmt.Sprintf("block %d", index), Index: index}
+}
+
+// mockFetcher serves blocks where addr receives
+// 10 in each block and balances (keyed by block
+// index) from a map.
+type mockFetcher struct {
+	tip      int64
+	balances map[int64]string
+
+	// reorged is the index of a block that is returned
+	// with a different hash (if not 0).
+	reorged int64
+}
+
+func (f *mockFetcher) NetworkStatusRetry(
+	context.Context,
+	*types.NetworkIdentifier,
+	map[string]interface{},
+) (*types.NetworkStatusResponse, *fetcher.Error) {
+	return &types.NetworkStatusResponse{
+		GenesisBlockIdentifier: blockIdentifier(0),
+		CurrentBlockIdentifier: blockIdentifier(f.tip),
+	}, nil
+}
+
+func (f *mockFetcher) BlockRetry(
+	ctx context.Context,
+	network *types.NetworkIdentifier,
+	identifier *types.PartialBlockIdentifier,
+) (*types.Block, *fetcher.Error) {
+	index := *identifier.Index
+	block := blockIdentifier(index)
+	if index == f.reorged {
+		block.Hash = fmt.Sprintf("reorged block %d", index)
+	}
+
+	return &types.Block{
+		BlockIdentifier:       block,
+		ParentBlockIdentifier: blockIdentifier(index - 1),
+		Transactions: []*types.Transaction{
+			{
+				TransactionIdentifier: &types.TransactionIdentifier{Hash: "tx"},
+				Operations: []*types.Operation{
+					{
+						OperationIdentifier: &types.OperationIdentifier{Index: 0},
+						Type:                "TRANSFER",
+						Status:              types.String("SUCCESS"),
+						Account:             &types.AccountIdentifier{Address: "addr"},
+						Amount:              &types.Amount{Value: "10", Currency: currency},
+					},
+				},
+			},
+		},
+	}, nil
+}
+
+func (f *mockFetcher) AccountBalanceRetry(
+	ctx context.Context,
+	network *types.NetworkIdentifier,
+	account *types.AccountIdentifier,
+	block *types.PartialBlockIdentifier,
+	currencies []*types.Currency,
+) (*types.BlockIdentifier, []*types.Amount, map[string]interface{}, *fetcher.Error) {
+	balance, ok := f.balances[*block.Index]
+	if !ok {
+		return nil, nil, nil, &fetcher.Error{Err: errors.New("balance not found")}
+	}
+
+	return blockIdentifier(*block.Index), []*types.Amount{
+		{Value: balance, Currency: currency},
+	}, nil, nil
+}
+
+func TestChecker(t *testing.T) {
+	a, err := asserter.NewClientWithOptions(
+		network,
+		blockIdentifier(0),
+		[]string{"TRANSFER"},
+		[]*types.OperationStatus{{Status: "SUCCESS", Successful: true}},
+		[]*types.Error{},
+		nil,
+	)
+	assert.NoError(t, err)
+	p := parser.New(a, nil, nil)
+
+	var tests = map[string]struct {
+		fetcher *mockFetcher
+
+		results *Results
+		err     error
+	}{
+		"consistent": {
+			fetcher: &mockFetcher{
+				tip:      3,
+				balances: map[int64]string{0: "0", 1: "10", 2: "20", 3: "30"},
+			},
+			results: &Results{BlocksChecked: 3, AccountsChecked: 3, Mismatches: []*Mismatch{}},
+		},
+		"inconsistent": {
+			fetcher: &mockFetcher{
+				tip:      3,
+				balances: map[int64]string{0: "0", 1: "10", 2: "10", 3: "20"},
+			},
+			results: &Results{
+				BlocksChecked:   3,
+				AccountsChecked: 3,
+				Mismatches: []*Mismatch{
+					{
+						Block:         blockIdentifier(2),
+						Account:       &types.AccountIdentifier{Address: "addr"},
+						Currency:      currency,
+						ParentBalance: "10",
+						Difference:    "10",
+						Balance:       "10",
+					},
+				},
+			},
+		},
+		"no blocks": {
+			fetcher: &mockFetcher{},
+			err:     ErrNoBlocks,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			results, err := New(network, test.fetcher, p, 5).Run(context.Background(), 10)
+			if test.err != nil {
+				assert.True(t, errors.Is(err, test.err))
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, test.results, results)
+		})
+	}
+}
+
+func TestRunFromTip(t *testing.T) {
+	a, err := asserter.NewClientWithOptions(
+		network,
+		blockIdentifier(0),
+		[]string{"TRANSFER"},
+		[]*types.OperationStatus{{Status: "SUCCESS", Successful: true}},
+		[]*types.Error{},
+		nil,
+	)
+	assert.NoError(t, err)
+	p := parser.New(a, nil, nil)
+	balances := map[int64]string{0: "0", 1: "10", 2: "20", 3: "30", 4: "40"}
+
+	var tests = map[string]struct {
+		fetcher *mockFetcher
+		depth   int
+
+		results *Results
+		err     error
+	}{
+		"stops after genesis": {
+			fetcher: &mockFetcher{tip: 4, balances: balances},
+			depth:   10,
+			results: &Results{BlocksChecked: 4, AccountsChecked: 4, Mismatches: []*Mismatch{}},
+		},
+		"stops at depth": {
+			fetcher: &mockFetcher{tip: 4, balances: balances},
+			depth:   2,
+			results: &Results{BlocksChecked: 2, AccountsChecked: 2, Mismatches: []*Mismatch{}},
+		},
+		"parent mismatch": {
+			fetcher: &mockFetcher{tip: 4, balances: balances, reorged: 2},
+			depth:   10,
+			err:     ErrParentMismatch,
+		},
+		"no blocks": {
+			fetcher: &mockFetcher{},
+			depth:   10,
+			err:     ErrNoBlocks,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			results, err := New(network, test.fetcher, p, 5).RunFromTip(
+				context.Background(),
+				test.depth,
+			)
+			if test.err != nil {
+				assert.True(t, errors.Is(err, test.err))
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, test.results, results)
+		})
+	}
+}
+
+func TestSampleIndices(t *testing.T) {
+	assert.Equal(t, []int64{11, 12, 13}, sampleIndices(10, 13, 5))
+
+	indices := sampleIndices(0, 1000, 20)
+	assert.Len(t, indices, 20)
+	for i, index := range indices {
+		assert.True(t, index > 0 && index <= 1000)
+		if i > 0 {
+			assert.True(t, index > indices[i-1])
+		}
+	}
+}
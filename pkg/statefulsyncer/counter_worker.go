@@ -0,0 +1,86 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statefulsyncer
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+var _ storage.BlockWorker = (*counterWorker)(nil)
+
+// counterWorker implements the storage.BlockWorker interface
+// and updates block counters in the same database transaction
+// that stores (or removes) a block.
+type counterWorker struct {
+	counterStorage *storage.CounterStorage
+}
+
+// AddingBlock increments the block, transaction, and
+// operation counters.
+func (w *counterWorker) AddingBlock(
+	ctx context.Context,
+	block *types.Block,
+	transaction storage.DatabaseTransaction,
+) (storage.CommitWorker, error) {
+	opCount := int64(0)
+	for _, txn := range block.Transactions {
+		opCount += int64(len(txn.Operations))
+	}
+
+	counters := []struct {
+		name   string
+		amount int64
+	}{
+		{storage.BlockCounter, 1},
+		{storage.TransactionCounter, int64(len(block.Transactions))},
+		{storage.OperationCounter, opCount},
+	}
+
+	for _, counter := range counters {
+		if _, err := w.counterStorage.UpdateTransactional(
+			ctx,
+			transaction,
+			counter.name,
+			big.NewInt(counter.amount),
+		); err != nil {
+			return nil, fmt.Errorf("%w: unable to update %s counter", err, counter.name)
+		}
+	}
+
+	return nil, nil
+}
+
+// RemovingBlock increments the orphan counter.
+func (w *counterWorker) RemovingBlock(
+	ctx context.Context,
+	block *types.Block,
+	transaction storage.DatabaseTransaction,
+) (storage.CommitWorker, error) {
+	if _, err := w.counterStorage.UpdateTransactional(
+		ctx,
+		transaction,
+		storage.OrphanCounter,
+		big.NewInt(1),
+	); err != nil {
+		return nil, fmt.Errorf("%w: unable to update %s counter", err, storage.OrphanCounter)
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,280 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statefulsyncer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"time"
+
+	"github.com/coinbase/rosetta-sdk-go/fetcher"
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+const (
+	// EventsOffsetCounter is the counter that stores the
+	// sequence of the next block event to process.
+	EventsOffsetCounter = "events_offset"
+
+	// eventsLimit is the maximum number of block
+	// events requested at once.
+	eventsLimit = 100
+)
+
+// ErrBlockNotFound is returned when the node
+// does not return a block announced in an event.
+var ErrBlockNotFound = errors.New("block not found")
+
+// EventsFetcher is the subset of *fetcher.Fetcher
+// used to fetch block events.
+type EventsFetcher interface {
+	EventsBlocks(
+		ctx context.Context,
+		network *types.NetworkIdentifier,
+		offset *int64,
+		limit *int64,
+	) (int64, []*types.BlockEvent, *fetcher.Error)
+
+	EventsBlocksRetry(
+		ctx context.Context,
+		network *types.NetworkIdentifier,
+		offset *int64,
+		limit *int64,
+	) (int64, []*types.BlockEvent, *fetcher.Error)
+}
+
+// EventsSyncer syncs blocks with a *StatefulSyncer by
+// consuming the /events/blocks endpoint instead of
+// polling /network/status for the head block. Blocks
+// are added and removed in the order they are announced
+// by the node, so reorgs do not need to be discovered by
+// comparing parent hashes.
+//
+// The sequence of the next event to process is stored
+// in counter storage so that syncing resumes where it
+// stopped after a restart. Because events are only
+// applied if they are consistent with the blocks in
+// storage, replaying events is safe.
+type EventsSyncer struct {
+	syncer       *StatefulSyncer
+	events       EventsFetcher
+	pollInterval time.Duration
+
+	startIndex int64
+	endIndex   int64
+}
+
+// NewEventsSyncer returns a new *EventsSyncer that
+// fetches new events every pollInterval once all
+// available events are processed.
+func NewEventsSyncer(
+	syncer *StatefulSyncer,
+	events EventsFetcher,
+	pollInterval time.Duration,
+) *EventsSyncer {
+	return &EventsSyncer{
+		syncer:       syncer,
+		events:       events,
+		pollInterval: pollInterval,
+	}
+}
+
+// Sync syncs from startIndex to endIndex (with the same
+// semantics as *StatefulSyncer.Sync). If the node does
+// not support /events/blocks, Sync falls back to
+// *StatefulSyncer.Sync.
+func (e *EventsSyncer) Sync(ctx context.Context, startIndex int64, endIndex int64) error {
+	offset, err := e.syncer.counterStorage.Get(ctx, EventsOffsetCounter)
+	if err != nil {
+		return fmt.Errorf("%w: unable to get events offset", err)
+	}
+
+	limit := int64(eventsLimit)
+	nextOffset := offset.Int64()
+	_, events, fetchErr := e.events.EventsBlocks(ctx, e.syncer.network, &nextOffset, &limit)
+	if fetchErr != nil {
+		log.Printf(
+			"%s: /events/blocks is not supported, falling back to head polling\n",
+			fetchErr.Err.Error(),
+		)
+
+		return e.syncer.Sync(ctx, startIndex, endIndex)
+	}
+
+	startIndex, err = e.syncer.initialize(ctx, startIndex)
+	if err != nil {
+		return err
+	}
+
+	if startIndex == -1 {
+		networkStatus, err := e.syncer.NetworkStatus(ctx, e.syncer.network)
+		if err != nil {
+			return fmt.Errorf("%w: unable to get network status", err)
+		}
+
+		startIndex = networkStatus.GenesisBlockIdentifier.Index
+	}
+
+	e.startIndex = startIndex
+	e.endIndex = endIndex
+
+	for {
+		for _, event := range events {
+			if err := e.processEvent(ctx, event); err != nil {
+				return err
+			}
+
+			if _, err := e.syncer.counterStorage.Update(
+				ctx,
+				EventsOffsetCounter,
+				big.NewInt(event.Sequence+1-nextOffset),
+			); err != nil {
+				return fmt.Errorf("%w: unable to update events offset", err)
+			}
+			nextOffset = event.Sequence + 1
+		}
+
+		nextIndex, _, err := e.next(ctx)
+		if err != nil {
+			return err
+		}
+
+		if e.ended(nextIndex) {
+			return nil
+		}
+
+		if len(events) < eventsLimit {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(e.pollInterval):
+			}
+		}
+
+		if e.syncer.gate != nil {
+			if err := e.syncer.gate.Wait(ctx); err != nil {
+				return err
+			}
+		}
+
+		_, events, fetchErr = e.events.EventsBlocksRetry(
+			ctx,
+			e.syncer.network,
+			&nextOffset,
+			&limit,
+		)
+		if fetchErr != nil {
+			return fmt.Errorf("%w: unable to fetch block events", fetchErr.Err)
+		}
+	}
+}
+
+// next returns the index of the next block to
+// add and the head block (nil if no blocks are
+// stored).
+func (e *EventsSyncer) next(ctx context.Context) (int64, *types.BlockIdentifier, error) {
+	head, err := e.syncer.blockStorage.GetHeadBlockIdentifier(ctx)
+	if errors.Is(err, storage.ErrHeadBlockNotFound) {
+		return e.startIndex, nil, nil
+	}
+	if err != nil {
+		return -1, nil, fmt.Errorf("%w: unable to get head block", err)
+	}
+
+	return head.Index + 1, head, nil
+}
+
+// ended returns a boolean indicating if
+// nextIndex is after the end index.
+func (e *EventsSyncer) ended(nextIndex int64) bool {
+	return e.endIndex != -1 && nextIndex > e.endIndex
+}
+
+// processEvent applies event to storage. Added blocks
+// that are already stored and removed blocks that are
+// not the head block are ignored.
+func (e *EventsSyncer) processEvent(ctx context.Context, event *types.BlockEvent) error {
+	nextIndex, head, err := e.next(ctx)
+	if err != nil {
+		return err
+	}
+
+	switch event.Type {
+	case types.ADDED:
+		if event.BlockIdentifier.Index < nextIndex {
+			return nil
+		}
+
+		return e.syncTo(ctx, event.BlockIdentifier)
+	case types.REMOVED:
+		if head == nil || types.Hash(head) != types.Hash(event.BlockIdentifier) {
+			return nil
+		}
+
+		return e.syncer.BlockRemoved(ctx, head)
+	default:
+		return fmt.Errorf("unknown block event type %s", event.Type)
+	}
+}
+
+// syncTo adds blocks until target is the head block. Blocks
+// between the head block and target are fetched by index. If
+// the parent of a fetched block is not the head block, the
+// head block is removed (like the stateless syncer does when
+// it encounters a reorg).
+func (e *EventsSyncer) syncTo(ctx context.Context, target *types.BlockIdentifier) error {
+	for ctx.Err() == nil {
+		nextIndex, head, err := e.next(ctx)
+		if err != nil {
+			return err
+		}
+
+		if nextIndex > target.Index || e.ended(nextIndex) {
+			return nil
+		}
+
+		blockIdentifier := &types.PartialBlockIdentifier{Index: &nextIndex}
+		if nextIndex == target.Index {
+			blockIdentifier.Hash = &target.Hash
+		}
+
+		block, err := e.syncer.Block(ctx, e.syncer.network, blockIdentifier)
+		if err != nil {
+			return fmt.Errorf("%w: unable to fetch block %d", err, nextIndex)
+		}
+
+		if block == nil {
+			return fmt.Errorf("%w: %d", ErrBlockNotFound, nextIndex)
+		}
+
+		if head != nil && types.Hash(block.ParentBlockIdentifier) != types.Hash(head) {
+			if err := e.syncer.BlockRemoved(ctx, head); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if err := e.syncer.BlockAdded(ctx, block); err != nil {
+			return err
+		}
+	}
+
+	return ctx.Err()
+}
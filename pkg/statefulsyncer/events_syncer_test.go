@@ -0,0 +1,189 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statefulsyncer
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/coinbase/rosetta-sdk-go/fetcher"
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/coinbase/rosetta-sdk-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+var _ EventsFetcher = (*mockEventsFetcher)(nil)
+
+// mockEventsFetcher returns events in pages
+// of pageSize.
+type mockEventsFetcher struct {
+	events   []*types.BlockEvent
+	pageSize int64
+}
+
+func (f *mockEventsFetcher) EventsBlocks(
+	ctx context.Context,
+	network *types.NetworkIdentifier,
+	offset *int64,
+	limit *int64,
+) (int64, []*types.BlockEvent, *fetcher.Error) {
+	start := *offset
+	if start > int64(len(f.events)) {
+		start = int64(len(f.events))
+	}
+
+	end := start + f.pageSize
+	if end > int64(len(f.events)) {
+		end = int64(len(f.events))
+	}
+
+	return int64(len(f.events)) - 1, f.events[start:end], nil
+}
+
+func (f *mockEventsFetcher) EventsBlocksRetry(
+	ctx context.Context,
+	network *types.NetworkIdentifier,
+	offset *int64,
+	limit *int64,
+) (int64, []*types.BlockEvent, *fetcher.Error) {
+	return f.EventsBlocks(ctx, network, offset, limit)
+}
+
+var _ BlockFetcher = (*mockBlockFetcher)(nil)
+
+// mockBlockFetcher returns blocks by hash or,
+// if no hash is provided, the canonical block
+// at an index.
+type mockBlockFetcher struct {
+	blocks    map[string]*types.Block
+	canonical map[int64]*types.Block
+}
+
+func (f *mockBlockFetcher) Block(
+	ctx context.Context,
+	network *types.NetworkIdentifier,
+	block *types.PartialBlockIdentifier,
+) (*types.Block, error) {
+	if block.Hash != nil {
+		return f.blocks[*block.Hash], nil
+	}
+
+	return f.canonical[*block.Index], nil
+}
+
+func blockEvent(sequence int64, block *types.Block, eventType types.BlockEventType) *types.BlockEvent {
+	return &types.BlockEvent{
+		Sequence:        sequence,
+		BlockIdentifier: block.BlockIdentifier,
+		Type:            eventType,
+	}
+}
+
+func TestEventsSyncer(t *testing.T) {
+	ctx := context.Background()
+
+	newDir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(newDir)
+
+	database, err := storage.NewBadgerStorage(ctx, newDir)
+	assert.NoError(t, err)
+	defer database.Close(ctx)
+
+	// Block 3 is orphaned by block 3b.
+	reorgBlock := testBlock(3)
+	reorgBlock.BlockIdentifier = &types.BlockIdentifier{Hash: "block 3b", Index: 3}
+	tipBlock := testBlock(4)
+	tipBlock.ParentBlockIdentifier = reorgBlock.BlockIdentifier
+
+	blockFetcher := &mockBlockFetcher{
+		blocks:    map[string]*types.Block{},
+		canonical: map[int64]*types.Block{},
+	}
+	for _, block := range []*types.Block{
+		testBlock(0),
+		testBlock(1),
+		testBlock(2),
+		testBlock(3),
+		reorgBlock,
+		tipBlock,
+	} {
+		blockFetcher.blocks[block.BlockIdentifier.Hash] = block
+	}
+	for _, block := range []*types.Block{
+		testBlock(0),
+		testBlock(1),
+		testBlock(2),
+		reorgBlock,
+		tipBlock,
+	} {
+		blockFetcher.canonical[block.BlockIdentifier.Index] = block
+	}
+
+	// Block 2 is never announced, so it must
+	// be fetched by index.
+	eventsFetcher := &mockEventsFetcher{
+		events: []*types.BlockEvent{
+			blockEvent(0, testBlock(0), types.ADDED),
+			blockEvent(1, testBlock(1), types.ADDED),
+			blockEvent(2, testBlock(3), types.ADDED),
+			blockEvent(3, testBlock(3), types.REMOVED),
+			blockEvent(4, reorgBlock, types.ADDED),
+			blockEvent(5, testBlock(1), types.ADDED),
+			blockEvent(6, tipBlock, types.ADDED),
+		},
+		pageSize: 2,
+	}
+
+	blockStorage := storage.NewBlockStorage(database)
+	counterStorage := storage.NewCounterStorage(database)
+	s := New(
+		ctx,
+		&types.NetworkIdentifier{Blockchain: "blah", Network: "testnet"},
+		nil,
+		blockFetcher,
+		nil,
+		blockStorage,
+		counterStorage,
+		&mockLogger{},
+		func() {},
+		[]storage.BlockWorker{},
+		10,
+		1,
+		10,
+	)
+
+	e := NewEventsSyncer(s, eventsFetcher, time.Millisecond)
+	assert.NoError(t, e.Sync(ctx, 0, 4))
+
+	head, err := blockStorage.GetHeadBlockIdentifier(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, tipBlock.BlockIdentifier, head)
+
+	block, err := blockStorage.GetBlock(
+		ctx,
+		&types.PartialBlockIdentifier{Index: &reorgBlock.BlockIdentifier.Index},
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, reorgBlock.BlockIdentifier, block.BlockIdentifier)
+	assertCounters(ctx, t, counterStorage, 6, 1)
+
+	offset, err := counterStorage.Get(ctx, EventsOffsetCounter)
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewInt(7), offset)
+}
@@ -0,0 +1,149 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statefulsyncer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/fatih/color"
+)
+
+// ErrRestartsExhausted is returned when a sync returns a
+// retryable error after the max number of consecutive
+// restarts.
+var ErrRestartsExhausted = errors.New("sync restarts exhausted")
+
+// RestartPolicy determines when a sync that
+// returned an error should be restarted.
+type RestartPolicy struct {
+	// MaxRestarts is the maximum number of consecutive
+	// restarts without any new blocks being synced.
+	MaxRestarts int
+
+	// InitialBackoff is the time waited before the first
+	// consecutive restart. The time waited doubles with each
+	// consecutive restart (up to MaxBackoff).
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+
+	// Retryable returns a boolean indicating if an
+	// error returned by a sync is retryable.
+	Retryable func(error) bool
+}
+
+// Restarter restarts syncs that return retryable errors
+// (like when the node restarts) with exponential backoff so
+// that a long-running check does not exit on every transient
+// failure. The backoff and restart budget are reset whenever
+// a block is synced between restarts.
+type Restarter struct {
+	blockStorage *storage.BlockStorage
+	policy       *RestartPolicy
+
+	restarts int
+	backoff  time.Duration
+	lastHead int64
+}
+
+// NewRestarter returns a new *Restarter.
+func NewRestarter(blockStorage *storage.BlockStorage, policy *RestartPolicy) *Restarter {
+	return &Restarter{
+		blockStorage: blockStorage,
+		policy:       policy,
+		backoff:      policy.InitialBackoff,
+		lastHead:     -1,
+	}
+}
+
+// Retryable returns a boolean indicating if
+// err should cause the sync to restart.
+func (r *Restarter) Retryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, ErrHalted) {
+		return false
+	}
+
+	return r.policy.Retryable(err)
+}
+
+// headIndex returns the index of the head block
+// (or -1 if no blocks are stored).
+func (r *Restarter) headIndex(ctx context.Context) (int64, error) {
+	head, err := r.blockStorage.GetHeadBlockIdentifier(ctx)
+	if errors.Is(err, storage.ErrHeadBlockNotFound) {
+		return -1, nil
+	}
+	if err != nil {
+		return -1, fmt.Errorf("%w: unable to get head block", err)
+	}
+
+	return head.Index, nil
+}
+
+// Restart returns err if it is not retryable or the restart
+// budget is exhausted. Otherwise, it waits for the backoff and
+// returns nil (indicating the caller should restart the sync
+// from the head block).
+func (r *Restarter) Restart(ctx context.Context, err error) error {
+	if !r.Retryable(err) {
+		return err
+	}
+
+	head, headErr := r.headIndex(ctx)
+	if headErr != nil {
+		return headErr
+	}
+
+	if head > r.lastHead {
+		r.restarts = 0
+		r.backoff = r.policy.InitialBackoff
+	}
+	r.lastHead = head
+
+	if r.restarts >= r.policy.MaxRestarts {
+		return fmt.Errorf(
+			"%w: %d restarts without syncing a block: %v",
+			ErrRestartsExhausted,
+			r.restarts,
+			err,
+		)
+	}
+	r.restarts++
+
+	color.Yellow(
+		"%s: restarting sync from block %d in %s (restart %d of %d)",
+		err.Error(),
+		head+1,
+		r.backoff,
+		r.restarts,
+		r.policy.MaxRestarts,
+	)
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(r.backoff):
+	}
+
+	r.backoff *= 2
+	if r.backoff > r.policy.MaxBackoff {
+		r.backoff = r.policy.MaxBackoff
+	}
+
+	return nil
+}
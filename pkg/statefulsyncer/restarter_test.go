@@ -0,0 +1,91 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statefulsyncer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/coinbase/rosetta-sdk-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+var errRetryable = errors.New("503 service unavailable")
+
+func TestRestarter(t *testing.T) {
+	ctx := context.Background()
+
+	newDir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(newDir)
+
+	database, err := storage.NewBadgerStorage(ctx, newDir)
+	assert.NoError(t, err)
+	defer database.Close(ctx)
+
+	blockStorage := storage.NewBlockStorage(database)
+	blockStorage.Initialize(nil)
+
+	r := NewRestarter(blockStorage, &RestartPolicy{
+		MaxRestarts:    2,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     3 * time.Millisecond,
+		Retryable: func(err error) bool {
+			return errors.Is(err, errRetryable)
+		},
+	})
+
+	t.Run("not retryable", func(t *testing.T) {
+		assert.False(t, r.Retryable(nil))
+		assert.False(t, r.Retryable(errors.New("invalid block")))
+		assert.False(t, r.Retryable(fmt.Errorf("%w: %v", ErrHalted, errRetryable)))
+		assert.False(t, r.Retryable(fmt.Errorf("%w: %v", context.Canceled, errRetryable)))
+
+		err := errors.New("invalid block")
+		assert.Equal(t, err, r.Restart(ctx, err))
+	})
+
+	t.Run("restarts until budget exhausted", func(t *testing.T) {
+		assert.True(t, r.Retryable(errRetryable))
+		assert.NoError(t, r.Restart(ctx, errRetryable))
+		assert.Equal(t, 2*time.Millisecond, r.backoff)
+		assert.NoError(t, r.Restart(ctx, errRetryable))
+		assert.Equal(t, 3*time.Millisecond, r.backoff)
+
+		err := r.Restart(ctx, errRetryable)
+		assert.True(t, errors.Is(err, ErrRestartsExhausted))
+	})
+
+	t.Run("progress resets budget", func(t *testing.T) {
+		assert.NoError(t, blockStorage.AddBlock(ctx, testBlock(0)))
+
+		assert.NoError(t, r.Restart(ctx, errRetryable))
+		assert.Equal(t, 1, r.restarts)
+		assert.Equal(t, 2*time.Millisecond, r.backoff)
+	})
+
+	t.Run("context canceled", func(t *testing.T) {
+		r.policy.InitialBackoff = time.Minute
+		assert.NoError(t, blockStorage.AddBlock(ctx, testBlock(1)))
+
+		canceledCtx, cancel := context.WithCancel(ctx)
+		cancel()
+		assert.True(t, errors.Is(r.Restart(canceledCtx, errRetryable), context.Canceled))
+	})
+}
@@ -0,0 +1,444 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package statefulsyncer is a fork of the statefulsyncer package in
+// rosetta-sdk-go that can be halted between blocks and that commits
+// block counters in the same database transaction as the block.
+package statefulsyncer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/coinbase/rosetta-sdk-go/fetcher"
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/coinbase/rosetta-sdk-go/syncer"
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+var _ syncer.Handler = (*StatefulSyncer)(nil)
+var _ syncer.Helper = (*StatefulSyncer)(nil)
+
+const (
+	// pruneSleepTime is how long we sleep between
+	// pruning attempts.
+	pruneSleepTime = 10 * time.Second
+
+	// pruneBuffer is the cushion we apply to pastBlockLimit
+	// when pruning.
+	pruneBuffer = 2
+)
+
+// ErrHalted is returned when a block is added or
+// removed after the syncer is halted.
+var ErrHalted = errors.New("syncer halted")
+
+// StatefulSyncer is an abstraction layer over
+// the stateless syncer package. This layer
+// handles sync restarts and provides
+// fully populated blocks during reorgs (not
+// provided by stateless syncer).
+type StatefulSyncer struct {
+	network        *types.NetworkIdentifier
+	fetcher        *fetcher.Fetcher
+	blockFetcher   BlockFetcher
+	gate           Gate
+	cancel         context.CancelFunc
+	blockStorage   *storage.BlockStorage
+	counterStorage *storage.CounterStorage
+	logger         Logger
+	workers        []storage.BlockWorker
+	cacheSize      int
+	maxConcurrency int64
+	pastBlockLimit int
+
+	// blockMutex is held while a block is added
+	// or removed so that Halt can wait for the
+	// block to be fully processed.
+	blockMutex sync.Mutex
+	halted     bool
+
+	// pruneMutex serializes PruneOnce so that blocks are
+	// archived and pruned by one caller at a time (Prune
+	// and callers that prune immediately may overlap).
+	pruneMutex sync.Mutex
+}
+
+// Logger is used by the statefulsyncer to
+// log the addition and removal of blocks.
+type Logger interface {
+	AddBlockStream(context.Context, *types.Block) error
+	RemoveBlockStream(context.Context, *types.BlockIdentifier) error
+}
+
+// BlockFetcher is used by the statefulsyncer to fetch
+// blocks instead of the *fetcher.Fetcher (for example,
+// to fetch each block from multiple endpoints).
+type BlockFetcher interface {
+	Block(
+		ctx context.Context,
+		network *types.NetworkIdentifier,
+		block *types.PartialBlockIdentifier,
+	) (*types.Block, error)
+}
+
+// Gate is used by the statefulsyncer to wait before
+// each request (for example, while syncing is paused).
+// Release is called once each block fetch is complete.
+type Gate interface {
+	Acquire(ctx context.Context) error
+	Release()
+	Wait(ctx context.Context) error
+}
+
+// PruneHelper is used by the stateful syncer
+// to determine the safe pruneable index. This is
+// a helper instead of a static argument because the
+// pruneable index is often a function of the state
+// of some number of structs.
+type PruneHelper interface {
+	// PruneableIndex is the largest block
+	// index that is considered safe to prune.
+	PruneableIndex(ctx context.Context, headIndex int64) (int64, error)
+}
+
+// ArchiveHelper can be implemented by a PruneHelper
+// that must store blocks elsewhere (like in a block
+// archive) before they are pruned.
+type ArchiveHelper interface {
+	// ArchiveBlocks is called with the pruneable
+	// index before any blocks are pruned.
+	ArchiveBlocks(ctx context.Context, pruneableIndex int64) error
+}
+
+// New returns a new *StatefulSyncer. If blockFetcher
+// is nil, blocks are fetched with fetcher. If gate is
+// not nil, it is waited on before each request.
+func New(
+	ctx context.Context,
+	network *types.NetworkIdentifier,
+	fetcher *fetcher.Fetcher,
+	blockFetcher BlockFetcher,
+	gate Gate,
+	blockStorage *storage.BlockStorage,
+	counterStorage *storage.CounterStorage,
+	logger Logger,
+	cancel context.CancelFunc,
+	workers []storage.BlockWorker,
+	cacheSize int,
+	maxConcurrency int64,
+	pastBlockLimit int,
+) *StatefulSyncer {
+	// Counters are updated by the last worker so that
+	// they are only committed if all other workers succeed.
+	allWorkers := make([]storage.BlockWorker, len(workers), len(workers)+1)
+	copy(allWorkers, workers)
+	allWorkers = append(allWorkers, &counterWorker{counterStorage: counterStorage})
+
+	return &StatefulSyncer{
+		network:        network,
+		fetcher:        fetcher,
+		blockFetcher:   blockFetcher,
+		gate:           gate,
+		cancel:         cancel,
+		blockStorage:   blockStorage,
+		counterStorage: counterStorage,
+		workers:        allWorkers,
+		logger:         logger,
+		cacheSize:      cacheSize,
+		maxConcurrency: maxConcurrency,
+		pastBlockLimit: pastBlockLimit,
+	}
+}
+
+// initialize initializes blockStorage and returns the index
+// to start syncing from (-1 if it should be determined by the
+// syncer).
+func (s *StatefulSyncer) initialize(ctx context.Context, startIndex int64) (int64, error) {
+	s.blockStorage.Initialize(s.workers)
+
+	// Ensure storage is in correct state for starting at index
+	if startIndex != -1 { // attempt to remove blocks from storage (without handling)
+		if err := s.blockStorage.SetNewStartIndex(ctx, startIndex); err != nil {
+			return -1, fmt.Errorf("%w: unable to set new start index", err)
+		}
+	} else { // attempt to load last processed index
+		head, err := s.blockStorage.GetHeadBlockIdentifier(ctx)
+		if err == nil {
+			startIndex = head.Index + 1
+		}
+	}
+
+	return startIndex, nil
+}
+
+// Sync starts a new sync run after properly initializing blockStorage.
+func (s *StatefulSyncer) Sync(ctx context.Context, startIndex int64, endIndex int64) error {
+	startIndex, err := s.initialize(ctx, startIndex)
+	if err != nil {
+		return err
+	}
+
+	// Load in previous blocks into syncer cache to handle reorgs.
+	// If previously processed blocks exist in storage, they are fetched.
+	// Otherwise, none are provided to the cache (the syncer will not attempt
+	// a reorg if the cache is empty).
+	pastBlocks := s.blockStorage.CreateBlockCache(ctx, s.pastBlockLimit)
+
+	syncer := syncer.New(
+		s.network,
+		s,
+		s,
+		s.cancel,
+		syncer.WithPastBlocks(pastBlocks),
+		syncer.WithCacheSize(s.cacheSize),
+		syncer.WithMaxConcurrency(s.maxConcurrency),
+	)
+
+	return syncer.Sync(ctx, startIndex, endIndex)
+}
+
+// Halt prevents any blocks from being added or removed
+// after the block currently being processed (if any). Halt
+// waits up to timeout for the block to be fully processed
+// and returns a boolean indicating if it was processed
+// in time.
+//
+// Because block storage, all block workers, and counters
+// are updated in a single database transaction, restarting
+// a halted syncer resumes at exactly the block after the
+// last processed block.
+func (s *StatefulSyncer) Halt(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		s.blockMutex.Lock()
+		s.halted = true
+		s.blockMutex.Unlock()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// Prune will repeatedly attempt to prune BlockStorage until
+// the context is canceled or an error is encountered.
+//
+// PruneHelper is provided as an argument here instead of
+// in the initializer because the caller may wish to change
+// pruning strategies during syncing.
+func (s *StatefulSyncer) Prune(ctx context.Context, helper PruneHelper) error {
+	for ctx.Err() == nil {
+		if err := s.PruneOnce(ctx, helper); err != nil {
+			return err
+		}
+
+		time.Sleep(pruneSleepTime)
+	}
+
+	return ctx.Err()
+}
+
+// PruneOnce prunes all blocks in BlockStorage that are
+// safe to prune (according to PruneHelper). It is called
+// by Prune and can also be called to prune immediately
+// (for example, when disk space is low). Concurrent calls
+// are serialized.
+func (s *StatefulSyncer) PruneOnce(ctx context.Context, helper PruneHelper) error {
+	s.pruneMutex.Lock()
+	defer s.pruneMutex.Unlock()
+
+	// We don't use a transaction to fetch head block identifier
+	// because we might delete blocks after we get our transaction.
+	headBlock, err := s.blockStorage.GetHeadBlockIdentifier(ctx)
+	if headBlock == nil && errors.Is(err, storage.ErrHeadBlockNotFound) {
+		// this will occur when we are waiting for the first block to be synced
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	oldestIndex, err := s.blockStorage.GetOldestBlockIndex(ctx)
+	if oldestIndex == -1 && errors.Is(err, storage.ErrOldestIndexMissing) {
+		// this will occur when we have yet to store the oldest index
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	pruneableIndex, err := helper.PruneableIndex(ctx, headBlock.Index)
+	if err != nil {
+		return fmt.Errorf("%w: could not determine pruneable index", err)
+	}
+
+	if pruneableIndex < oldestIndex {
+		return nil
+	}
+
+	if archiver, ok := helper.(ArchiveHelper); ok {
+		if err := archiver.ArchiveBlocks(ctx, pruneableIndex); err != nil {
+			return fmt.Errorf("%w: unable to archive blocks", err)
+		}
+	}
+
+	firstPruned, lastPruned, err := s.blockStorage.Prune(
+		ctx,
+		pruneableIndex,
+		int64(s.pastBlockLimit)*pruneBuffer, // we should be very cautious about pruning
+	)
+	if err != nil {
+		return err
+	}
+
+	// firstPruned and lastPruned are -1 if there is nothing to prune
+	if firstPruned != -1 && lastPruned != -1 {
+		pruneMessage := fmt.Sprintf("pruned blocks %d-%d", firstPruned, lastPruned)
+		if firstPruned == lastPruned {
+			pruneMessage = fmt.Sprintf("pruned block %d", firstPruned)
+		}
+
+		log.Println(pruneMessage)
+	}
+
+	return nil
+}
+
+// BlockAdded is called by the syncer when a block is added.
+func (s *StatefulSyncer) BlockAdded(ctx context.Context, block *types.Block) error {
+	s.blockMutex.Lock()
+	defer s.blockMutex.Unlock()
+
+	if s.halted {
+		return fmt.Errorf(
+			"%w: unable to add block %s:%d",
+			ErrHalted,
+			block.BlockIdentifier.Hash,
+			block.BlockIdentifier.Index,
+		)
+	}
+
+	err := s.blockStorage.AddBlock(ctx, block)
+	if err != nil {
+		return fmt.Errorf(
+			"%w: unable to add block to storage %s:%d",
+			err,
+			block.BlockIdentifier.Hash,
+			block.BlockIdentifier.Index,
+		)
+	}
+
+	if err := s.logger.AddBlockStream(ctx, block); err != nil {
+		return fmt.Errorf(
+			"%w: unable to log block %s:%d",
+			err,
+			block.BlockIdentifier.Hash,
+			block.BlockIdentifier.Index,
+		)
+	}
+
+	return nil
+}
+
+// BlockRemoved is called by the syncer when a block is removed.
+func (s *StatefulSyncer) BlockRemoved(
+	ctx context.Context,
+	blockIdentifier *types.BlockIdentifier,
+) error {
+	s.blockMutex.Lock()
+	defer s.blockMutex.Unlock()
+
+	if s.halted {
+		return fmt.Errorf(
+			"%w: unable to remove block %s:%d",
+			ErrHalted,
+			blockIdentifier.Hash,
+			blockIdentifier.Index,
+		)
+	}
+
+	err := s.blockStorage.RemoveBlock(ctx, blockIdentifier)
+	if err != nil {
+		return fmt.Errorf(
+			"%w: unable to remove block from storage %s:%d",
+			err,
+			blockIdentifier.Hash,
+			blockIdentifier.Index,
+		)
+	}
+
+	if err := s.logger.RemoveBlockStream(ctx, blockIdentifier); err != nil {
+		return fmt.Errorf(
+			"%w: unable to log removed block %s:%d",
+			err,
+			blockIdentifier.Hash,
+			blockIdentifier.Index,
+		)
+	}
+
+	return nil
+}
+
+// NetworkStatus is called by the syncer to get the current
+// network status.
+func (s *StatefulSyncer) NetworkStatus(
+	ctx context.Context,
+	network *types.NetworkIdentifier,
+) (*types.NetworkStatusResponse, error) {
+	if s.gate != nil {
+		if err := s.gate.Wait(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	networkStatus, fetchErr := s.fetcher.NetworkStatusRetry(ctx, network, nil)
+	if fetchErr != nil {
+		return nil, fetchErr.Err
+	}
+
+	return networkStatus, nil
+}
+
+// Block is called by the syncer to fetch a block.
+func (s *StatefulSyncer) Block(
+	ctx context.Context,
+	network *types.NetworkIdentifier,
+	block *types.PartialBlockIdentifier,
+) (*types.Block, error) {
+	if s.gate != nil {
+		if err := s.gate.Acquire(ctx); err != nil {
+			return nil, err
+		}
+		defer s.gate.Release()
+	}
+
+	if s.blockFetcher != nil {
+		return s.blockFetcher.Block(ctx, network, block)
+	}
+
+	blockResponse, fetchErr := s.fetcher.BlockRetry(ctx, network, block)
+	if fetchErr != nil {
+		return nil, fetchErr.Err
+	}
+	return blockResponse, nil
+}
@@ -0,0 +1,251 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statefulsyncer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/coinbase/rosetta-sdk-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+var _ Logger = (*mockLogger)(nil)
+
+type mockLogger struct{}
+
+func (l *mockLogger) AddBlockStream(context.Context, *types.Block) error {
+	return nil
+}
+
+func (l *mockLogger) RemoveBlockStream(context.Context, *types.BlockIdentifier) error {
+	return nil
+}
+
+var _ storage.BlockWorker = (*failingWorker)(nil)
+
+// failingWorker returns an error when adding
+// a block with index fail.
+type failingWorker struct {
+	fail int64
+}
+
+func (w *failingWorker) AddingBlock(
+	ctx context.Context,
+	block *types.Block,
+	transaction storage.DatabaseTransaction,
+) (storage.CommitWorker, error) {
+	if block.BlockIdentifier.Index == w.fail {
+		return nil, errors.New("worker failed")
+	}
+
+	return nil, nil
+}
+
+func (w *failingWorker) RemovingBlock(
+	ctx context.Context,
+	block *types.Block,
+	transaction storage.DatabaseTransaction,
+) (storage.CommitWorker, error) {
+	return nil, nil
+}
+
+var (
+	_ PruneHelper   = (*archiveHelper)(nil)
+	_ ArchiveHelper = (*archiveHelper)(nil)
+)
+
+// archiveHelper records the number of calls
+// to ArchiveBlocks and the largest number of
+// calls in progress at the same time.
+type archiveHelper struct {
+	mutex     sync.Mutex
+	active    int
+	maxActive int
+	calls     int
+}
+
+func (h *archiveHelper) PruneableIndex(ctx context.Context, headIndex int64) (int64, error) {
+	return headIndex, nil
+}
+
+func (h *archiveHelper) ArchiveBlocks(ctx context.Context, pruneableIndex int64) error {
+	h.mutex.Lock()
+	h.active++
+	h.calls++
+	if h.active > h.maxActive {
+		h.maxActive = h.active
+	}
+	h.mutex.Unlock()
+
+	time.Sleep(10 * time.Millisecond)
+
+	h.mutex.Lock()
+	h.active--
+	h.mutex.Unlock()
+
+	return nil
+}
+
+func testBlock(index int64) *types.Block {
+	parentIndex := index - 1
+	if parentIndex < 0 {
+		parentIndex = 0
+	}
+
+	return &types.Block{
+		BlockIdentifier: &types.BlockIdentifier{
+			Hash:  fmt.Sprintf("block %d", index),
+			Index: index,
+		},
+		ParentBlockIdentifier: &types.BlockIdentifier{
+			Hash:  fmt.Sprintf("block %d", parentIndex),
+			Index: parentIndex,
+		},
+		Transactions: []*types.Transaction{
+			{
+				TransactionIdentifier: &types.TransactionIdentifier{
+					Hash: fmt.Sprintf("tx %d", index),
+				},
+				Operations: []*types.Operation{
+					{OperationIdentifier: &types.OperationIdentifier{Index: 0}},
+					{OperationIdentifier: &types.OperationIdentifier{Index: 1}},
+				},
+			},
+		},
+	}
+}
+
+func assertCounters(
+	ctx context.Context,
+	t *testing.T,
+	counterStorage *storage.CounterStorage,
+	blocks int64,
+	orphans int64,
+) {
+	for counter, expected := range map[string]int64{
+		storage.BlockCounter:       blocks,
+		storage.TransactionCounter: blocks,
+		storage.OperationCounter:   blocks * 2,
+		storage.OrphanCounter:      orphans,
+	} {
+		val, err := counterStorage.Get(ctx, counter)
+		assert.NoError(t, err)
+		assert.Equal(t, big.NewInt(expected), val, counter)
+	}
+}
+
+func TestStatefulSyncer(t *testing.T) {
+	ctx := context.Background()
+
+	newDir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(newDir)
+
+	database, err := storage.NewBadgerStorage(ctx, newDir)
+	assert.NoError(t, err)
+	defer database.Close(ctx)
+
+	blockStorage := storage.NewBlockStorage(database)
+	counterStorage := storage.NewCounterStorage(database)
+	s := New(
+		ctx,
+		&types.NetworkIdentifier{Blockchain: "blah", Network: "testnet"},
+		nil,
+		nil,
+		nil,
+		blockStorage,
+		counterStorage,
+		&mockLogger{},
+		func() {},
+		[]storage.BlockWorker{&failingWorker{fail: 3}},
+		10,
+		1,
+		10,
+	)
+	s.blockStorage.Initialize(s.workers)
+
+	t.Run("add blocks", func(t *testing.T) {
+		for i := int64(0); i < 3; i++ {
+			assert.NoError(t, s.BlockAdded(ctx, testBlock(i)))
+		}
+
+		assertCounters(ctx, t, counterStorage, 3, 0)
+	})
+
+	t.Run("worker failure does not update counters", func(t *testing.T) {
+		assert.Error(t, s.BlockAdded(ctx, testBlock(3)))
+
+		head, err := blockStorage.GetHeadBlockIdentifier(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), head.Index)
+		assertCounters(ctx, t, counterStorage, 3, 0)
+	})
+
+	t.Run("remove block", func(t *testing.T) {
+		assert.NoError(t, s.BlockRemoved(ctx, testBlock(2).BlockIdentifier))
+		assertCounters(ctx, t, counterStorage, 3, 1)
+	})
+
+	t.Run("halt", func(t *testing.T) {
+		assert.True(t, s.Halt(time.Second))
+
+		err := s.BlockAdded(ctx, testBlock(2))
+		assert.True(t, errors.Is(err, ErrHalted))
+		err = s.BlockRemoved(ctx, testBlock(1).BlockIdentifier)
+		assert.True(t, errors.Is(err, ErrHalted))
+
+		head, err := blockStorage.GetHeadBlockIdentifier(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), head.Index)
+		assertCounters(ctx, t, counterStorage, 3, 1)
+	})
+
+	t.Run("concurrent prunes are serialized", func(t *testing.T) {
+		helper := &archiveHelper{}
+
+		var wg sync.WaitGroup
+		errs := make(chan error, 4)
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				errs <- s.PruneOnce(ctx, helper)
+			}()
+		}
+		wg.Wait()
+		close(errs)
+
+		for err := range errs {
+			assert.NoError(t, err)
+		}
+		assert.Equal(t, 4, helper.calls)
+		assert.Equal(t, 1, helper.maxActive)
+	})
+
+	t.Run("halt waits for block being processed", func(t *testing.T) {
+		s.blockMutex.Lock()
+		assert.False(t, s.Halt(10*time.Millisecond))
+		s.blockMutex.Unlock()
+		assert.True(t, s.Halt(time.Second))
+	})
+}
@@ -0,0 +1,164 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stream
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/coinbase/rosetta-cli/configuration"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+const (
+	// BlockAdded is the type of the event published
+	// when a validated block is added.
+	BlockAdded = "block_added"
+
+	// BlockRemoved is the type of the event published
+	// when a block is orphaned.
+	BlockRemoved = "block_removed"
+
+	// kafkaContentType is the content type of records
+	// with JSON values sent to the Kafka REST Proxy.
+	kafkaContentType = "application/vnd.kafka.json.v2+json"
+
+	// maxErrorBodySize is the maximum number of bytes
+	// of an error response included in an error.
+	maxErrorBodySize = 512
+)
+
+// ErrPublishFailed is returned when the message
+// broker does not accept an event.
+var ErrPublishFailed = errors.New("unable to publish event")
+
+// Event is published to the message broker for
+// each added and removed block.
+type Event struct {
+	Type    string                   `json:"type"`
+	Network *types.NetworkIdentifier `json:"network_identifier"`
+	Block   *types.Block             `json:"block"`
+}
+
+// Publisher publishes events to a topic.
+type Publisher struct {
+	kind   configuration.BlockStreamKind
+	url    string
+	topic  string
+	client *http.Client
+}
+
+// New returns a new *Publisher for the
+// message broker described by config.
+func New(config *configuration.BlockStream) *Publisher {
+	return &Publisher{
+		kind:  config.Kind,
+		url:   strings.TrimSuffix(config.URL, "/"),
+		topic: config.Topic,
+		client: &http.Client{
+			Timeout: time.Duration(config.Timeout) * time.Second,
+		},
+	}
+}
+
+// kafkaRecords are the records sent
+// to the Kafka REST Proxy.
+type kafkaRecords struct {
+	Records []*kafkaRecord `json:"records"`
+}
+
+type kafkaRecord struct {
+	Key   string `json:"key"`
+	Value *Event `json:"value"`
+}
+
+// request returns the URL, content type, and
+// body used to publish event.
+func (p *Publisher) request(event *Event) (string, string, []byte, error) {
+	if p.kind == configuration.NSQBlockStream {
+		body, err := json.Marshal(event)
+		if err != nil {
+			return "", "", nil, err
+		}
+
+		return fmt.Sprintf("%s/pub?topic=%s", p.url, url.QueryEscape(p.topic)),
+			"application/json",
+			body,
+			nil
+	}
+
+	// All events of a network are published with the same
+	// key so that they are stored (and consumed) in order.
+	body, err := json.Marshal(&kafkaRecords{
+		Records: []*kafkaRecord{
+			{
+				Key:   fmt.Sprintf("%s/%s", event.Network.Blockchain, event.Network.Network),
+				Value: event,
+			},
+		},
+	})
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	return fmt.Sprintf("%s/topics/%s", p.url, url.PathEscape(p.topic)),
+		kafkaContentType,
+		body,
+		nil
+}
+
+// Publish publishes event to the topic and returns
+// once the message broker has accepted it.
+func (p *Publisher) Publish(ctx context.Context, event *Event) error {
+	endpoint, contentType, body, err := p.request(event)
+	if err != nil {
+		return fmt.Errorf("%w: unable to encode event", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("%w: unable to create request", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrPublishFailed, err.Error())
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+		return fmt.Errorf(
+			"%w: %s returned %d: %s",
+			ErrPublishFailed,
+			p.kind,
+			resp.StatusCode,
+			strings.TrimSpace(string(message)),
+		)
+	}
+
+	return nil
+}
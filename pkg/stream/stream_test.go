@@ -0,0 +1,120 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/coinbase/rosetta-cli/configuration"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+var testEvent = &Event{
+	Type: BlockAdded,
+	Network: &types.NetworkIdentifier{
+		Blockchain: "Bitcoin",
+		Network:    "Testnet3",
+	},
+	Block: &types.Block{
+		BlockIdentifier:       &types.BlockIdentifier{Hash: "block 1", Index: 1},
+		ParentBlockIdentifier: &types.BlockIdentifier{Hash: "block 0", Index: 0},
+	},
+}
+
+func TestPublish(t *testing.T) {
+	var tests = map[string]struct {
+		kind   configuration.BlockStreamKind
+		status int
+
+		path        string
+		query       string
+		contentType string
+		body        interface{}
+		err         bool
+	}{
+		"kafka": {
+			kind:        configuration.KafkaBlockStream,
+			status:      http.StatusOK,
+			path:        "/topics/blocks",
+			contentType: kafkaContentType,
+			body: &kafkaRecords{
+				Records: []*kafkaRecord{{Key: "Bitcoin/Testnet3", Value: testEvent}},
+			},
+		},
+		"nsq": {
+			kind:        configuration.NSQBlockStream,
+			status:      http.StatusOK,
+			path:        "/pub",
+			query:       "topic=blocks",
+			contentType: "application/json",
+			body:        testEvent,
+		},
+		"rejected": {
+			kind:        configuration.NSQBlockStream,
+			status:      http.StatusBadRequest,
+			path:        "/pub",
+			query:       "topic=blocks",
+			contentType: "application/json",
+			body:        testEvent,
+			err:         true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, http.MethodPost, r.Method)
+				assert.Equal(t, test.path, r.URL.Path)
+				assert.Equal(t, test.query, r.URL.RawQuery)
+				assert.Equal(t, test.contentType, r.Header.Get("Content-Type"))
+
+				body, err := ioutil.ReadAll(r.Body)
+				assert.NoError(t, err)
+
+				expected, err := json.Marshal(test.body)
+				assert.NoError(t, err)
+				assert.JSONEq(t, string(expected), string(body))
+
+				w.WriteHeader(test.status)
+				_, _ = w.Write([]byte("E_BAD_TOPIC"))
+			}))
+			defer ts.Close()
+
+			p := New(&configuration.BlockStream{
+				Kind:    test.kind,
+				URL:     ts.URL + "/",
+				Topic:   "blocks",
+				Timeout: 1,
+			})
+
+			err := p.Publish(context.Background(), testEvent)
+			if test.err {
+				assert.True(t, errors.Is(err, ErrPublishFailed))
+				assert.Contains(t, err.Error(), "E_BAD_TOPIC")
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,180 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tester
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/coinbase/rosetta-cli/pkg/processor"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/fatih/color"
+)
+
+// accountFile is a file of accounts that
+// is reloaded whenever it changes.
+type accountFile struct {
+	path     string
+	modTime  time.Time
+	size     int64
+	accounts []*types.AccountCurrency
+}
+
+func newAccountFile(path string, accounts []*types.AccountCurrency) *accountFile {
+	f := &accountFile{path: path, accounts: accounts}
+	if len(path) == 0 {
+		return f
+	}
+
+	if info, err := os.Stat(path); err == nil {
+		f.modTime = info.ModTime()
+		f.size = info.Size()
+	}
+
+	return f
+}
+
+// reload loads the file if it changed since it was last
+// loaded and returns a boolean indicating if it was reloaded.
+// The file is only marked as loaded if it can be parsed (so a
+// partially written file is loaded again on the next call).
+func (f *accountFile) reload() (bool, error) {
+	if len(f.path) == 0 {
+		return false, nil
+	}
+
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return false, fmt.Errorf("%w: unable to stat %s", err, f.path)
+	}
+
+	if info.ModTime().Equal(f.modTime) && info.Size() == f.size {
+		return false, nil
+	}
+
+	accounts, err := loadAccounts(f.path)
+	if err != nil {
+		return false, err
+	}
+
+	f.accounts = accounts
+	f.modTime = info.ModTime()
+	f.size = info.Size()
+
+	return true, nil
+}
+
+// diffAccounts returns the number of accounts in
+// current that are not in previous (added) and the
+// number of accounts in previous that are not in
+// current (removed).
+func diffAccounts(previous []*types.AccountCurrency, current []*types.AccountCurrency) (int, int) {
+	previousSet := map[string]struct{}{}
+	for _, account := range previous {
+		previousSet[types.Hash(account)] = struct{}{}
+	}
+
+	added := 0
+	currentSet := map[string]struct{}{}
+	for _, account := range current {
+		key := types.Hash(account)
+		currentSet[key] = struct{}{}
+		if _, ok := previousSet[key]; !ok {
+			added++
+		}
+	}
+
+	removed := 0
+	for key := range previousSet {
+		if _, ok := currentSet[key]; !ok {
+			removed++
+		}
+	}
+
+	return added, removed
+}
+
+// accountFileReloader applies changes to the exempt
+// and interesting account files to a running check:data.
+type accountFileReloader struct {
+	exempt      *accountFile
+	interesting *accountFile
+
+	currencyFilter *processor.CurrencyFilter
+	helper         *processor.BalanceStorageHelper
+	handler        *processor.BalanceStorageHandler
+}
+
+func newAccountFileReloader(
+	exemptPath string,
+	interestingPath string,
+	exemptAccounts []*types.AccountCurrency,
+	interestingAccounts []*types.AccountCurrency,
+	currencyFilter *processor.CurrencyFilter,
+	helper *processor.BalanceStorageHelper,
+	handler *processor.BalanceStorageHandler,
+) *accountFileReloader {
+	return &accountFileReloader{
+		exempt:         newAccountFile(exemptPath, exemptAccounts),
+		interesting:    newAccountFile(interestingPath, interestingAccounts),
+		currencyFilter: currencyFilter,
+		helper:         helper,
+		handler:        handler,
+	}
+}
+
+// Reload reloads any changed account files and applies
+// them to balance tracking and reconciliation. If a file
+// cannot be loaded, the accounts last loaded remain in use.
+func (r *accountFileReloader) Reload() {
+	previous := r.exempt.accounts
+	reloaded, err := r.exempt.reload()
+	if err != nil {
+		color.Yellow("%s: unable to reload exempt accounts", err.Error())
+	}
+
+	if reloaded {
+		r.helper.SetExemptAccounts(r.exempt.accounts)
+
+		added, removed := diffAccounts(previous, r.exempt.accounts)
+		color.Cyan(
+			"reloaded exempt accounts from %s: %d added, %d removed",
+			r.exempt.path,
+			added,
+			removed,
+		)
+	}
+
+	previous = r.interesting.accounts
+	reloaded, err = r.interesting.reload()
+	if err != nil {
+		color.Yellow("%s: unable to reload interesting accounts", err.Error())
+	}
+
+	if reloaded {
+		r.interesting.accounts = r.currencyFilter.FilterAccounts(r.interesting.accounts)
+		r.handler.SetInterestingAccounts(r.interesting.accounts)
+
+		added, removed := diffAccounts(previous, r.interesting.accounts)
+		color.Cyan(
+			"reloaded interesting accounts from %s: %d added, %d removed",
+			r.interesting.path,
+			added,
+			removed,
+		)
+	}
+}
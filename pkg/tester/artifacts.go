@@ -0,0 +1,173 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tester
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"time"
+
+	"github.com/coinbase/rosetta-cli/pkg/results"
+	"github.com/coinbase/rosetta-cli/pkg/upload"
+
+	"github.com/coinbase/rosetta-sdk-go/utils"
+	"github.com/fatih/color"
+)
+
+const (
+	// resultsArtifact is the name of the uploaded results.
+	resultsArtifact = "results.json"
+
+	// failuresArtifact is the name of the
+	// uploaded recorded failures.
+	failuresArtifact = "failures.json"
+
+	// snapshotArtifact is the name of the uploaded
+	// snapshot of the data directory.
+	snapshotArtifact = "data.tar.gz"
+
+	// artifactDirPrefix is the prefix of the temporary
+	// directory artifacts are written to before upload.
+	artifactDirPrefix = "rosetta-cli-artifacts"
+)
+
+// prepareArtifacts writes the results and all recorded failures
+// of the run to a temporary directory (which must be removed by
+// uploadArtifacts). When final is true, the results saved to
+// ResultsOutputFile (if populated) are uploaded instead of the
+// results computed from the current counters.
+func (t *DataTester) prepareArtifacts(
+	ctx context.Context,
+	final bool,
+) (string, []*upload.Artifact, error) {
+	dir, err := ioutil.TempDir("", artifactDirPrefix)
+	if err != nil {
+		return "", nil, fmt.Errorf("%w: unable to create artifact directory", err)
+	}
+
+	recorded, err := t.failureStorage.GetAll(ctx)
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return "", nil, fmt.Errorf("%w: unable to get failures", err)
+	}
+
+	failuresPath := path.Join(dir, failuresArtifact)
+	if err := utils.SerializeAndWrite(failuresPath, recorded); err != nil {
+		_ = os.RemoveAll(dir)
+		return "", nil, fmt.Errorf("%w: unable to write failures", err)
+	}
+
+	resultsPath := t.config.Data.ResultsOutputFile
+	if !final || len(resultsPath) == 0 {
+		resultsPath = path.Join(dir, resultsArtifact)
+		results.ComputeCheckDataResults(
+			t.config,
+			nil,
+			t.counterStorage,
+			t.balanceStorage,
+			t.endCondition,
+			t.endConditionDetail,
+		).Output(resultsPath)
+	}
+
+	return dir, []*upload.Artifact{
+		{Name: resultsArtifact, Path: resultsPath},
+		{Name: failuresArtifact, Path: failuresPath},
+	}, nil
+}
+
+// uploadArtifacts uploads artifacts, deletes expired runs, and
+// removes the temporary directory dir. If snapshot is true, a
+// snapshot of the data directory (if not disabled) is added to
+// artifacts. The data directory is only consistent once the
+// database is closed, so snapshot must only be true then.
+func (t *DataTester) uploadArtifacts(
+	ctx context.Context,
+	dir string,
+	artifacts []*upload.Artifact,
+	snapshot bool,
+) error {
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	if snapshot && !t.config.Data.ArtifactUpload.SnapshotDisabled {
+		snapshotPath := path.Join(dir, snapshotArtifact)
+		f, err := os.Create(snapshotPath) // #nosec G304
+		if err != nil {
+			return fmt.Errorf("%w: unable to create snapshot", err)
+		}
+
+		snapshotErr := upload.Snapshot(t.dataPath, f)
+		if err := f.Close(); err != nil && snapshotErr == nil {
+			snapshotErr = err
+		}
+		if snapshotErr != nil {
+			return fmt.Errorf("%w: unable to snapshot data directory", snapshotErr)
+		}
+
+		artifacts = append(artifacts, &upload.Artifact{Name: snapshotArtifact, Path: snapshotPath})
+	}
+
+	if err := t.uploader.Upload(ctx, artifacts); err != nil {
+		return err
+	}
+
+	expired, err := t.uploader.Prune(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, run := range expired {
+		color.Cyan("deleted expired artifacts of run %s", run)
+	}
+
+	return nil
+}
+
+// StartArtifactUploader uploads the artifacts of the run
+// every Interval seconds (if configured). The data directory
+// is not snapshotted while the database is open. Upload
+// errors are logged but do not stop check:data.
+func (t *DataTester) StartArtifactUploader(ctx context.Context) error {
+	if t.uploader == nil || t.config.Data.ArtifactUpload.Interval == 0 {
+		return nil
+	}
+
+	tc := time.NewTicker(time.Duration(t.config.Data.ArtifactUpload.Interval) * time.Second)
+	defer tc.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-tc.C:
+		}
+
+		dir, artifacts, err := t.prepareArtifacts(ctx, false)
+		if err == nil {
+			err = t.uploadArtifacts(ctx, dir, artifacts, false)
+		}
+		if err != nil {
+			color.Yellow("%s: unable to upload artifacts", err.Error())
+			continue
+		}
+
+		color.Cyan("uploaded artifacts to %s", t.uploader.RunURL())
+	}
+}
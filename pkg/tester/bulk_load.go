@@ -0,0 +1,126 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tester
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/coinbase/rosetta-cli/configuration"
+
+	"github.com/coinbase/rosetta-sdk-go/fetcher"
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/fatih/color"
+)
+
+// bulkLoadConfiguration returns a copy of config used to bulk
+// load blocks: reconciliation is disabled, blocks are synced
+// (by polling) up to the bulk load index, and no artifacts
+// are uploaded.
+func bulkLoadConfiguration(config *configuration.Configuration) *configuration.Configuration {
+	data := *config.Data
+	data.ReconciliationDisabled = true
+	data.EndConditions = &configuration.DataEndConditions{Index: config.Data.BulkLoadIndex}
+	data.SyncMode = configuration.PollingSyncMode
+	data.RuntimeControls = false
+	data.ArtifactUpload = nil
+
+	bulkConfig := *config
+	bulkConfig.Data = &data
+	return &bulkConfig
+}
+
+// BulkLoad syncs all blocks up to the bulk load index of config
+// (if populated) with a *bulkload.Database, before the database
+// is opened by InitializeData. It returns a boolean indicating if
+// any blocks were synced. Blocks are not synced if the database
+// already contains the block at the bulk load index (unless a
+// start index is populated).
+//
+// If blocks were synced, check:data must resume from the last
+// synced block (instead of the start index, if any).
+func BulkLoad(
+	ctx context.Context,
+	config *configuration.Configuration,
+	network *types.NetworkIdentifier,
+	fetcher *fetcher.Fetcher,
+	cancel context.CancelFunc,
+	genesisBlock *types.BlockIdentifier,
+	signalReceived *bool,
+) (bool, error) {
+	if config.Data.BulkLoadIndex == nil {
+		return false, nil
+	}
+
+	index := *config.Data.BulkLoadIndex
+	t, err := initializeData(
+		ctx,
+		bulkLoadConfiguration(config),
+		network,
+		fetcher,
+		cancel,
+		genesisBlock,
+		nil,
+		signalReceived,
+		true,
+	)
+	if err != nil {
+		return false, fmt.Errorf("%w: unable to initialize bulk load", err)
+	}
+
+	if config.Data.StartIndex == nil {
+		loaded, err := t.bulkLoaded(ctx, index)
+		if err != nil || loaded {
+			if closeErr := t.CloseDatabase(ctx); closeErr != nil {
+				log.Printf("%s: unable to close bulk load\n", closeErr.Error())
+			}
+
+			return false, err
+		}
+	}
+
+	color.Cyan("bulk loading blocks up to %d", index)
+	syncErr := t.StartSyncing(ctx)
+
+	// The database is closed even if syncing failed so
+	// that all processed blocks are written.
+	if err := t.CloseDatabase(ctx); err != nil {
+		return false, fmt.Errorf("%w: unable to close bulk load", err)
+	}
+
+	if syncErr != nil {
+		return false, fmt.Errorf("%w: unable to bulk load blocks", syncErr)
+	}
+
+	color.Cyan("bulk loaded blocks up to %d", index)
+	return true, nil
+}
+
+// bulkLoaded returns a boolean indicating if the
+// block at index has already been synced.
+func (t *DataTester) bulkLoaded(ctx context.Context, index int64) (bool, error) {
+	head, err := t.blockStorage.GetHeadBlockIdentifier(ctx)
+	if errors.Is(err, storage.ErrHeadBlockNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("%w: unable to get head block", err)
+	}
+
+	return head.Index >= index, nil
+}
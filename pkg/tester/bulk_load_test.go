@@ -0,0 +1,116 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tester
+
+import (
+	"context"
+	"testing"
+
+	"github.com/coinbase/rosetta-cli/configuration"
+	"github.com/coinbase/rosetta-cli/pkg/bulkload"
+
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/coinbase/rosetta-sdk-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBulkLoadConfiguration(t *testing.T) {
+	startIndex := int64(10)
+	bulkLoadIndex := int64(100)
+	config := &configuration.Configuration{
+		Data: &configuration.DataConfiguration{
+			StartIndex:      &startIndex,
+			BulkLoadIndex:   &bulkLoadIndex,
+			SyncMode:        configuration.EventsSyncMode,
+			RuntimeControls: true,
+			ArtifactUpload:  &configuration.ArtifactUpload{},
+			EndConditions: &configuration.DataEndConditions{
+				Tip: types.Bool(true),
+			},
+		},
+	}
+
+	bulkConfig := bulkLoadConfiguration(config)
+	assert.Equal(t, &startIndex, bulkConfig.Data.StartIndex)
+	assert.True(t, bulkConfig.Data.ReconciliationDisabled)
+	assert.Equal(t, &configuration.DataEndConditions{Index: &bulkLoadIndex}, bulkConfig.Data.EndConditions)
+	assert.Equal(t, configuration.PollingSyncMode, bulkConfig.Data.SyncMode)
+	assert.False(t, bulkConfig.Data.RuntimeControls)
+	assert.Nil(t, bulkConfig.Data.ArtifactUpload)
+
+	// config is not modified.
+	assert.False(t, config.Data.ReconciliationDisabled)
+	assert.Equal(t, configuration.EventsSyncMode, config.Data.SyncMode)
+	assert.NotNil(t, config.Data.ArtifactUpload)
+	assert.Nil(t, config.Data.EndConditions.Index)
+}
+
+func TestOpenDatabase(t *testing.T) {
+	ctx := context.Background()
+	dir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(dir)
+
+	config := &configuration.Configuration{Data: &configuration.DataConfiguration{}}
+	database, err := openDatabase(ctx, config, dir, true)
+	assert.NoError(t, err)
+	_, ok := database.(*bulkload.Database)
+	assert.True(t, ok)
+
+	addBlocks(ctx, t, database)
+	assert.NoError(t, database.Close(ctx))
+
+	// Bulk loaded blocks can be read once
+	// the bulk load is closed.
+	database, err = openDatabase(ctx, config, dir, false)
+	assert.NoError(t, err)
+	defer database.Close(ctx)
+
+	tester := &DataTester{blockStorage: storage.NewBlockStorage(database)}
+	head, err := tester.blockStorage.GetHeadBlockIdentifier(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, diskSpaceBlock(blocksAdded-1).BlockIdentifier, head)
+
+	for _, index := range []int64{0, blocksAdded - 1} {
+		block, err := tester.blockStorage.GetBlock(
+			ctx,
+			types.ConstructPartialBlockIdentifier(diskSpaceBlock(index).BlockIdentifier),
+		)
+		assert.NoError(t, err)
+		assert.Equal(t, diskSpaceBlock(index), block)
+	}
+
+	var tests = map[string]struct {
+		index    int64
+		expected bool
+	}{
+		"loaded": {
+			index:    blocksAdded - 1,
+			expected: true,
+		},
+		"not loaded": {
+			index: blocksAdded,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			loaded, err := tester.bulkLoaded(ctx, test.index)
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, loaded)
+		})
+	}
+}
@@ -21,19 +21,25 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"path"
 	"time"
 
 	"github.com/coinbase/rosetta-cli/configuration"
+	"github.com/coinbase/rosetta-cli/pkg/curves"
+	"github.com/coinbase/rosetta-cli/pkg/encryption"
+	"github.com/coinbase/rosetta-cli/pkg/fixture"
 	"github.com/coinbase/rosetta-cli/pkg/logger"
 	"github.com/coinbase/rosetta-cli/pkg/processor"
 	"github.com/coinbase/rosetta-cli/pkg/results"
+	"github.com/coinbase/rosetta-cli/pkg/retry"
+	"github.com/coinbase/rosetta-cli/pkg/signer"
+	"github.com/coinbase/rosetta-cli/pkg/statefulsyncer"
 
 	"github.com/coinbase/rosetta-sdk-go/constructor/coordinator"
 	"github.com/coinbase/rosetta-sdk-go/fetcher"
+	"github.com/coinbase/rosetta-sdk-go/keys"
 	"github.com/coinbase/rosetta-sdk-go/parser"
-	"github.com/coinbase/rosetta-sdk-go/statefulsyncer"
 	"github.com/coinbase/rosetta-sdk-go/storage"
-	"github.com/coinbase/rosetta-sdk-go/syncer"
 	"github.com/coinbase/rosetta-sdk-go/types"
 	"github.com/coinbase/rosetta-sdk-go/utils"
 	"github.com/fatih/color"
@@ -66,45 +72,85 @@ type ConstructionTester struct {
 	coordinator      *coordinator.Coordinator
 	cancel           context.CancelFunc
 	signalReceived   *bool
+	nodeMonitor      *processor.NodeMonitor
+	recorder         *fixture.Recorder
+	remoteSigner     *signer.Remote
+	curvePlugins     *curves.Registry
+	feeEstimator     *processor.FeeEstimator
+	sequenceTracker  *processor.SequenceTracker
+
+	// operationTypes are the operation types supported by
+	// the network (used to report construction coverage).
+	operationTypes []string
 
 	reachedEndConditions bool
 }
 
+// ConstructionPath returns the path of the check:construction
+// database for network in dataDirectory.
+func ConstructionPath(dataDirectory string, network *types.NetworkIdentifier) string {
+	return path.Join(dataDirectory, constructionCmdName, types.Hash(network))
+}
+
 // InitializeConstruction initiates the construction API tester.
 func InitializeConstruction(
 	ctx context.Context,
 	config *configuration.Configuration,
 	network *types.NetworkIdentifier,
 	onlineFetcher *fetcher.Fetcher,
+	recorder *fixture.Recorder,
 	cancel context.CancelFunc,
 	signalReceived *bool,
 ) (*ConstructionTester, error) {
 	dataPath, err := utils.CreateCommandPath(config.DataDirectory, constructionCmdName, network)
 	if err != nil {
-		log.Fatalf("%s: cannot create command path", err.Error())
+		return nil, fmt.Errorf("%w: cannot create command path", err)
 	}
 
 	opts := []storage.BadgerOption{}
 	if config.CompressionDisabled {
 		opts = append(opts, storage.WithoutCompression())
 	}
-	if config.MemoryLimitDisabled {
-		opts = append(opts, storage.WithCustomSettings(storage.PerformanceBadgerOptions(dataPath)))
+
+	key, err := encryption.LoadKey(config.Encryption)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to load encryption key", err)
 	}
+	opts = append(opts, storage.WithCustomSettings(
+		encryption.BadgerOptions(dataPath, config.MemoryLimitDisabled, key),
+	))
 
 	localStore, err := storage.NewBadgerStorage(ctx, dataPath, opts...)
 	if err != nil {
-		log.Fatalf("%s: unable to initialize database", err.Error())
+		return nil, fmt.Errorf("%w: unable to initialize database", err)
+	}
+
+	// If initialization fails, everything opened so far
+	// is closed so that the data directory can be reused.
+	closers := []func() error{
+		func() error { return localStore.Close(ctx) },
 	}
+	initialized := false
+	defer func() {
+		if initialized {
+			return
+		}
+
+		for i := len(closers) - 1; i >= 0; i-- {
+			if err := closers[i](); err != nil {
+				log.Printf("%s: unable to close after initialization failure\n", err.Error())
+			}
+		}
+	}()
 
 	networkOptions, fetchErr := onlineFetcher.NetworkOptionsRetry(ctx, network, nil)
-	if err != nil {
-		log.Fatalf("%s: unable to get network options", fetchErr.Err.Error())
+	if fetchErr != nil {
+		return nil, fmt.Errorf("%w: unable to get network options", fetchErr.Err)
 	}
 
 	if len(networkOptions.Allow.BalanceExemptions) > 0 &&
 		config.Construction.InitialBalanceFetchDisabled {
-		log.Fatal("found balance exemptions but initial balance fetch disabled")
+		return nil, errors.New("found balance exemptions but initial balance fetch disabled")
 	}
 
 	counterStorage := storage.NewCounterStorage(localStore)
@@ -114,10 +160,13 @@ func InitializeConstruction(
 		false,
 		false,
 		false,
+		false,
 	)
+	closers = append(closers, logger.Close)
 
 	blockStorage := storage.NewBlockStorage(localStore)
 	keyStorage := storage.NewKeyStorage(localStore)
+	jobStorage := storage.NewJobStorage(localStore)
 	coinStorageHelper := processor.NewCoinStorageHelper(blockStorage)
 	coinStorage := storage.NewCoinStorage(localStore, coinStorageHelper, onlineFetcher.Asserter)
 	balanceStorage := storage.NewBalanceStorage(localStore)
@@ -127,6 +176,7 @@ func InitializeConstruction(
 		onlineFetcher,
 		false,
 		nil,
+		nil,
 		true,
 		networkOptions.Allow.BalanceExemptions,
 		config.Construction.InitialBalanceFetchDisabled,
@@ -141,10 +191,20 @@ func InitializeConstruction(
 
 	balanceStorage.Initialize(balanceStorageHelper, balanceStorageHandler)
 
+	// When rebroadcasts are performed by the RebroadcastWorker,
+	// BroadcastStorage only broadcasts each transaction once and
+	// fails the broadcast when the transaction is lost.
+	staleDepth := config.Construction.StaleDepth
+	broadcastLimit := config.Construction.BroadcastLimit
+	if backoff := config.Construction.RebroadcastBackoff; backoff != nil {
+		staleDepth = backoff.LostDepth
+		broadcastLimit = 1
+	}
+
 	broadcastStorage := storage.NewBroadcastStorage(
 		localStore,
-		config.Construction.StaleDepth,
-		config.Construction.BroadcastLimit,
+		staleDepth,
+		broadcastLimit,
 		config.TipDelay,
 		config.Construction.BroadcastBehindTip,
 		config.Construction.BlockBroadcastLimit,
@@ -152,23 +212,101 @@ func InitializeConstruction(
 
 	parser := parser.New(onlineFetcher.Asserter, nil, networkOptions.Allow.BalanceExemptions)
 	broadcastHelper := processor.NewBroadcastStorageHelper(
+		network,
 		blockStorage,
 		onlineFetcher,
+		config.Construction.VerifyCanonicalConfirmations,
 	)
-	offlineFetcher := fetcher.New(
+	var wrap func(http.RoundTripper) http.RoundTripper
+	if recorder != nil {
+		wrap = recorder.Wrap
+	}
+	offlineFetcher, err := retry.NewWrappedFetcher(
+		config,
 		config.Construction.OfflineURL,
+		wrap,
 		fetcher.WithMaxConnections(config.Construction.MaxOfflineConnections),
 		fetcher.WithAsserter(onlineFetcher.Asserter),
-		fetcher.WithTimeout(time.Duration(config.HTTPTimeout)*time.Second),
-		fetcher.WithMaxRetries(config.MaxRetries),
 	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to create offline fetcher", err)
+	}
 
-	// Import prefunded account and save to database
-	err = keyStorage.ImportAccounts(ctx, config.Construction.PrefundedAccounts)
+	resuming, err := isResuming(ctx, blockStorage)
 	if err != nil {
 		return nil, err
 	}
 
+	newPrefundedAccounts, err := prefundedAccountsToFetch(
+		ctx,
+		keyStorage,
+		config.Construction.PrefundedAccounts,
+		resuming,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var curvePlugins *curves.Registry
+	if len(config.Construction.CurvePlugins) > 0 {
+		if len(config.Construction.Seed) > 0 {
+			return nil, errors.New("seeded keys cannot be derived on plugin curves")
+		}
+
+		curvePlugins, err = curves.Start(config.Construction.CurvePlugins)
+		if err != nil {
+			return nil, err
+		}
+		closers = append(closers, curvePlugins.Close)
+
+		if err := curvePlugins.RewriteWorkflows(config.Construction.Workflows); err != nil {
+			return nil, fmt.Errorf("%w: unable to rewrite generate_key actions", err)
+		}
+	}
+
+	// Import prefunded account and save to database (keys on
+	// plugin curves are imported by their curve plugin)
+	nativePrefundedAccounts := []*storage.PrefundedAccount{}
+	for _, prefundedAcc := range config.Construction.PrefundedAccounts {
+		if curvePlugins == nil || !curvePlugins.Supports(prefundedAcc.CurveType) {
+			nativePrefundedAccounts = append(nativePrefundedAccounts, prefundedAcc)
+			continue
+		}
+
+		if err := importPluginAccount(ctx, keyStorage, curvePlugins, prefundedAcc); err != nil {
+			return nil, err
+		}
+	}
+
+	err = keyStorage.ImportAccounts(ctx, nativePrefundedAccounts)
+	if err != nil {
+		return nil, err
+	}
+
+	var remoteSigner *signer.Remote
+	var coordinatorSigner processor.RemoteSigner
+	if config.Construction.RemoteSigner != nil {
+		remoteSigner, err = signer.Start(config.Construction.RemoteSigner)
+		if err != nil {
+			return nil, err
+		}
+		closers = append(closers, remoteSigner.Close)
+		coordinatorSigner = remoteSigner
+
+		remoteAccounts, err := importRemoteAccounts(
+			ctx,
+			keyStorage,
+			remoteSigner,
+			config.Construction.RemoteSigner.Accounts,
+			resuming,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		newPrefundedAccounts = append(newPrefundedAccounts, remoteAccounts...)
+	}
+
 	// Load all accounts for network
 	accounts, err := keyStorage.GetAllAccounts(ctx)
 	if err != nil {
@@ -182,9 +320,16 @@ func InitializeConstruction(
 
 	log.Printf("construction tester initialized with %d accounts\n", len(accounts))
 
-	// Load prefunded accounts
+	if resuming {
+		if err := logResumedState(ctx, broadcastStorage, jobStorage); err != nil {
+			return nil, err
+		}
+	}
+
+	// Load prefunded accounts (balances of accounts imported
+	// in a previous run are tracked by syncing)
 	var accountBalanceRequests []*utils.AccountBalanceRequest
-	for _, prefundedAcc := range config.Construction.PrefundedAccounts {
+	for _, prefundedAcc := range newPrefundedAccounts {
 		accountBalance := &utils.AccountBalanceRequest{
 			Account:  prefundedAcc.AccountIdentifier,
 			Network:  network,
@@ -209,7 +354,11 @@ func InitializeConstruction(
 		return nil, fmt.Errorf("%w: unable to set coin balances", err)
 	}
 
-	jobStorage := storage.NewJobStorage(localStore)
+	minConfirmationDepth := config.Construction.ConfirmationDepth
+	if config.Finality != nil && config.Finality.MaxDepth() > minConfirmationDepth {
+		minConfirmationDepth = config.Finality.MaxDepth()
+	}
+
 	coordinatorHelper := processor.NewCoordinatorHelper(
 		offlineFetcher,
 		onlineFetcher,
@@ -221,9 +370,26 @@ func InitializeConstruction(
 		broadcastStorage,
 		balanceStorageHelper,
 		counterStorage,
+		minConfirmationDepth,
+		jobStorage,
+		config.Construction.WorkflowConfirmationDepths,
 		config.Construction.Quiet,
+		coordinatorSigner,
 	)
 
+	if len(config.Construction.Seed) > 0 {
+		seededKeys, err := processor.NewSeededKeys(ctx, localStore, config.Construction.Seed)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to load seeded keys", err)
+		}
+
+		coordinatorHelper.SeedKeys(seededKeys)
+	}
+
+	if curvePlugins != nil {
+		coordinatorHelper.UseCurvePlugins(curvePlugins)
+	}
+
 	coordinatorHandler := processor.NewCoordinatorHandler(
 		counterStorage,
 	)
@@ -235,7 +401,12 @@ func InitializeConstruction(
 		config.Construction.Workflows,
 	)
 	if err != nil {
-		log.Fatalf("%s: unable to create coordinator", err.Error())
+		return nil, fmt.Errorf("%w: unable to create coordinator", err)
+	}
+
+	intentMatchers, err := processor.GetIntentMatchers(config.Construction.IntentMatchers)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to load intent matchers", err)
 	}
 
 	broadcastHandler := processor.NewBroadcastStorageHandler(
@@ -243,24 +414,59 @@ func InitializeConstruction(
 		counterStorage,
 		coordinator,
 		parser,
+		intentMatchers,
 	)
 
+	var feeEstimator *processor.FeeEstimator
+	if estimation := config.Construction.FeeEstimation; estimation != nil {
+		feeEstimator = processor.NewFeeEstimator(parser.Asserter, estimation.MaxDeviation)
+		coordinatorHelper.EstimateFees(feeEstimator)
+		broadcastHandler.EstimateFees(feeEstimator)
+	}
+
+	var sequenceTracker *processor.SequenceTracker
+	if sends := config.Construction.ConcurrentSends; sends != nil {
+		sequenceTracker = processor.NewSequenceTracker(sends)
+		coordinatorHelper.TrackSequences(sequenceTracker)
+		broadcastHandler.TrackSequences(sequenceTracker)
+	}
+
 	broadcastStorage.Initialize(broadcastHelper, broadcastHandler)
 
+	workers := []storage.BlockWorker{balanceStorage, coinStorage, broadcastStorage}
+	if backoff := config.Construction.RebroadcastBackoff; backoff != nil {
+		workers = append(
+			workers,
+			processor.NewRebroadcastWorker(backoff, broadcastStorage, broadcastHelper),
+		)
+	}
+
 	syncer := statefulsyncer.New(
 		ctx,
 		network,
 		onlineFetcher,
+		nil,
+		nil,
 		blockStorage,
 		counterStorage,
 		logger,
 		cancel,
-		[]storage.BlockWorker{balanceStorage, coinStorage, broadcastStorage},
-		syncer.DefaultCacheSize,
+		workers,
+		config.SyncCacheSize,
 		config.MaxSyncConcurrency,
 		config.MaxReorgDepth,
 	)
 
+	var nodeMonitor *processor.NodeMonitor
+	if config.NodeRestartPatience > 0 {
+		nodeMonitor = processor.NewNodeMonitor(
+			network,
+			onlineFetcher,
+			time.Duration(config.NodeRestartPatience)*time.Second,
+		)
+	}
+
+	initialized = true
 	return &ConstructionTester{
 		network:          network,
 		database:         localStore,
@@ -275,14 +481,188 @@ func InitializeConstruction(
 		onlineFetcher:    onlineFetcher,
 		cancel:           cancel,
 		signalReceived:   signalReceived,
+		nodeMonitor:      nodeMonitor,
+		recorder:         recorder,
+		remoteSigner:     remoteSigner,
+		curvePlugins:     curvePlugins,
+		feeEstimator:     feeEstimator,
+		sequenceTracker:  sequenceTracker,
+		operationTypes:   networkOptions.Allow.OperationTypes,
 	}, nil
 }
 
-// CloseDatabase closes the database used by ConstructionTester.
-func (t *ConstructionTester) CloseDatabase(ctx context.Context) {
+// importRemoteAccounts stores the public keys of all accounts
+// managed by remoteSigner that are not already stored (their
+// private keys are never stored) and returns the accounts
+// whose balances must be imported (like newly prefunded
+// accounts).
+func importRemoteAccounts(
+	ctx context.Context,
+	keyStorage *storage.KeyStorage,
+	remoteSigner *signer.Remote,
+	accounts []*configuration.RemoteAccount,
+	resuming bool,
+) ([]*storage.PrefundedAccount, error) {
+	newAccounts := []*storage.PrefundedAccount{}
+	for _, account := range accounts {
+		prefundedAcc := &storage.PrefundedAccount{
+			AccountIdentifier: account.AccountIdentifier,
+			Currency:          account.Currency,
+		}
+
+		_, err := keyStorage.Get(ctx, account.AccountIdentifier)
+		switch {
+		case err == nil:
+			if !resuming {
+				newAccounts = append(newAccounts, prefundedAcc)
+			}
+
+			continue
+		case !errors.Is(err, storage.ErrAddrNotFound):
+			return nil, fmt.Errorf("%w: unable to lookup remote account", err)
+		}
+
+		publicKey, err := remoteSigner.PublicKey(ctx, account.AccountIdentifier)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := keyStorage.Store(
+			ctx,
+			account.AccountIdentifier,
+			&keys.KeyPair{PublicKey: publicKey},
+		); err != nil {
+			return nil, fmt.Errorf("%w: unable to store remote account", err)
+		}
+
+		newAccounts = append(newAccounts, prefundedAcc)
+	}
+
+	return newAccounts, nil
+}
+
+// isResuming returns a boolean indicating if blocks have
+// already been synced. If so, we are resuming a previous
+// run and should use the state in storage instead of
+// recomputing it.
+func isResuming(ctx context.Context, blockStorage *storage.BlockStorage) (bool, error) {
+	_, err := blockStorage.GetHeadBlockIdentifier(ctx)
+	switch {
+	case errors.Is(err, storage.ErrHeadBlockNotFound):
+		return false, nil
+	case err != nil:
+		return false, fmt.Errorf("%w: unable to get head block identifier", err)
+	default:
+		return true, nil
+	}
+}
+
+// prefundedAccountsToFetch returns the prefunded accounts whose
+// balances must be fetched. When resuming, the balances of accounts
+// imported in a previous run are tracked by syncing, so only
+// accounts that have not been seen before are returned.
+func prefundedAccountsToFetch(
+	ctx context.Context,
+	keyStorage *storage.KeyStorage,
+	prefundedAccounts []*storage.PrefundedAccount,
+	resuming bool,
+) ([]*storage.PrefundedAccount, error) {
+	newPrefundedAccounts := []*storage.PrefundedAccount{}
+	for _, prefundedAcc := range prefundedAccounts {
+		_, err := keyStorage.Get(ctx, prefundedAcc.AccountIdentifier)
+		switch {
+		case errors.Is(err, storage.ErrAddrNotFound):
+			newPrefundedAccounts = append(newPrefundedAccounts, prefundedAcc)
+		case err != nil:
+			return nil, fmt.Errorf("%w: unable to lookup prefunded account", err)
+		case !resuming:
+			newPrefundedAccounts = append(newPrefundedAccounts, prefundedAcc)
+		}
+	}
+
+	return newPrefundedAccounts, nil
+}
+
+// importPluginAccount stores the key pair of a prefunded
+// account on a plugin curve (if it is not already stored).
+func importPluginAccount(
+	ctx context.Context,
+	keyStorage *storage.KeyStorage,
+	curvePlugins *curves.Registry,
+	account *storage.PrefundedAccount,
+) error {
+	_, err := keyStorage.Get(ctx, account.AccountIdentifier)
+	switch {
+	case err == nil:
+		return nil
+	case !errors.Is(err, storage.ErrAddrNotFound):
+		return fmt.Errorf("%w: unable to lookup prefunded account", err)
+	}
+
+	keyPair, err := curvePlugins.ImportPrivateKey(ctx, account.PrivateKeyHex, account.CurveType)
+	if err != nil {
+		return err
+	}
+
+	if err := keyStorage.Store(ctx, account.AccountIdentifier, keyPair); err != nil {
+		return fmt.Errorf("%w: unable to store prefunded account", err)
+	}
+
+	return nil
+}
+
+// logResumedState prints the in-flight state loaded from
+// storage when resuming a previous check:construction run.
+func logResumedState(
+	ctx context.Context,
+	broadcastStorage *storage.BroadcastStorage,
+	jobStorage *storage.JobStorage,
+) error {
+	broadcasts, err := broadcastStorage.GetAllBroadcasts(ctx)
+	if err != nil {
+		return fmt.Errorf("%w: unable to load pending broadcasts", err)
+	}
+
+	processing, err := jobStorage.AllProcessing(ctx)
+	if err != nil {
+		return fmt.Errorf("%w: unable to load processing jobs", err)
+	}
+
+	color.Cyan(
+		"resuming check:construction with %d pending broadcasts and %d processing jobs",
+		len(broadcasts),
+		len(processing),
+	)
+
+	return nil
+}
+
+// CloseDatabase flushes all logger streams and
+// closes the database used by ConstructionTester.
+// An error is only returned if the database cannot
+// be closed.
+func (t *ConstructionTester) CloseDatabase(ctx context.Context) error {
+	if err := t.logger.Close(); err != nil {
+		log.Printf("%s: error flushing logger streams\n", err.Error())
+	}
+
+	if t.remoteSigner != nil {
+		if err := t.remoteSigner.Close(); err != nil {
+			log.Printf("%s: error closing signing backend\n", err.Error())
+		}
+	}
+
+	if t.curvePlugins != nil {
+		if err := t.curvePlugins.Close(); err != nil {
+			log.Printf("%s: error closing curve plugins\n", err.Error())
+		}
+	}
+
 	if err := t.database.Close(ctx); err != nil {
-		log.Fatalf("%s: error closing database", err.Error())
+		return fmt.Errorf("%w: error closing database", err)
 	}
+
+	return nil
 }
 
 // StartPeriodicLogger prints out periodic
@@ -304,6 +684,7 @@ func (t *ConstructionTester) StartPeriodicLogger(
 				t.counterStorage,
 				t.broadcastStorage,
 				t.jobStorage,
+				t.operationTypes,
 			)
 			t.logger.LogConstructionStatus(ctx, status)
 		}
@@ -353,7 +734,9 @@ func (t *ConstructionTester) waitForTip(ctx context.Context) (int64, error) {
 
 // StartSyncer uses the tester's stateful syncer
 // to compute balance changes and track transactions
-// for confirmation on-chain.
+// for confirmation on-chain. If node restart patience
+// is configured, syncing is resumed from the last saved
+// block once an unavailable node returns.
 func (t *ConstructionTester) StartSyncer(
 	ctx context.Context,
 	cancel context.CancelFunc,
@@ -371,7 +754,18 @@ func (t *ConstructionTester) StartSyncer(
 		return fmt.Errorf("%w: unable to get last block synced", err)
 	}
 
-	return t.syncer.Sync(ctx, startIndex, -1)
+	for {
+		err := t.syncer.Sync(ctx, startIndex, -1)
+		if t.nodeMonitor == nil || !processor.NodeUnavailable(err) {
+			return err
+		}
+
+		if err := t.nodeMonitor.Wait(ctx, err); err != nil {
+			return err
+		}
+
+		startIndex = -1
+	}
 }
 
 // StartConstructor uses the tester's constructor
@@ -403,6 +797,7 @@ func (t *ConstructionTester) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		t.counterStorage,
 		t.broadcastStorage,
 		t.jobStorage,
+		t.operationTypes,
 	)
 
 	if err := json.NewEncoder(w).Encode(status); err != nil {
@@ -410,6 +805,16 @@ func (t *ConstructionTester) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// Ready returns an error if check:construction has
+// not synced any blocks.
+func (t *ConstructionTester) Ready(ctx context.Context) error {
+	if _, err := t.blockStorage.GetHeadBlockIdentifier(ctx); err != nil {
+		return fmt.Errorf("%w: no blocks synced", err)
+	}
+
+	return nil
+}
+
 // PerformBroadcasts attempts to rebroadcast all pending transactions
 // if the RebroadcastAll configuration is set to true.
 func (t *ConstructionTester) PerformBroadcasts(ctx context.Context) error {
@@ -467,10 +872,15 @@ func (t *ConstructionTester) WatchEndConditions(
 	}
 }
 
-func (t *ConstructionTester) returnFunds(
+// ReturnFunds executes the return_funds workflow (while syncing
+// to confirm its broadcasts) until it can no longer be executed.
+// This sweeps funds from all stored accounts back to the address
+// specified in the workflow. If no return_funds workflow is
+// defined, this returns immediately.
+func (t *ConstructionTester) ReturnFunds(
 	ctx context.Context,
 	sigListeners *[]context.CancelFunc,
-) {
+) error {
 	// To cancel all execution, need to call multiple cancel functions.
 	ctx, cancel := context.WithCancel(ctx)
 	*sigListeners = append(*sigListeners, cancel)
@@ -500,12 +910,92 @@ func (t *ConstructionTester) returnFunds(
 	err := g.Wait()
 	if *t.signalReceived {
 		color.Red("Fund return halted")
+		return results.ErrCheckHalted
+	}
+
+	if returnFundsSuccess {
+		return nil
+	}
+
+	if err == nil {
+		err = errors.New("syncing stopped before all funds were returned")
+	}
+
+	return fmt.Errorf("%w: unable to return funds", err)
+}
+
+// Halt stops syncing after the block currently being
+// processed is committed. It is called when a signal is
+// received (before any contexts are canceled) so that
+// restarting the check resumes at the next block.
+func (t *ConstructionTester) Halt() {
+	haltSyncer(t.syncer)
+}
+
+// checkUnconfirmedBroadcasts logs all broadcasts that are still
+// pending confirmation and returns an error if there are any and
+// UnconfirmedBroadcasts is FailUnconfirmedBroadcasts.
+func (t *ConstructionTester) checkUnconfirmedBroadcasts(ctx context.Context) error {
+	broadcasts, err := t.broadcastStorage.GetAllBroadcasts(ctx)
+	if err != nil {
+		return fmt.Errorf("%w: unable to get pending broadcasts", err)
+	}
+
+	if len(broadcasts) == 0 {
+		return nil
+	}
+
+	for _, broadcast := range broadcasts {
+		color.Yellow(
+			"transaction %s (%s) is unconfirmed",
+			broadcast.TransactionIdentifier.Hash,
+			broadcast.Identifier,
+		)
+	}
+
+	if t.config.Construction.UnconfirmedBroadcasts == configuration.FailUnconfirmedBroadcasts {
+		return fmt.Errorf(
+			"%w: %d transactions",
+			results.ErrUnconfirmedBroadcasts,
+			len(broadcasts),
+		)
+	}
+
+	return nil
+}
+
+// reportFees prints the fee estimation report and saves
+// it to the configured report file (if any).
+func (t *ConstructionTester) reportFees() {
+	if t.feeEstimator == nil {
+		return
+	}
+
+	report := t.feeEstimator.Report()
+	report.Print()
+
+	reportFile := t.config.Construction.FeeEstimation.ReportFile
+	if len(reportFile) == 0 {
 		return
 	}
 
-	if !returnFundsSuccess {
-		log.Printf("unable to return funds %v\n", err)
+	if err := report.Save(reportFile); err != nil {
+		log.Printf("%s\n", err.Error())
+		return
 	}
+
+	color.Green("Fee estimation report saved to %s", reportFile)
+}
+
+// reportSequences prints the report of transactions
+// sent concurrently from the same account (if any
+// workflows send concurrently).
+func (t *ConstructionTester) reportSequences() {
+	if t.sequenceTracker == nil {
+		return
+	}
+
+	t.sequenceTracker.Report().Print()
 }
 
 // HandleErr is called when `check:construction` returns an error.
@@ -513,26 +1003,70 @@ func (t *ConstructionTester) HandleErr(
 	err error,
 	sigListeners *[]context.CancelFunc,
 ) error {
+	// Fees and concurrent sends are reported once
+	// ReturnFunds has completed (if it is run).
+	defer t.reportFees()
+	defer t.reportSequences()
+
 	if *t.signalReceived {
 		return results.ExitConstruction(
 			t.config,
 			t.counterStorage,
 			t.jobStorage,
-			errors.New("check halted"),
+			t.operationTypes,
+			results.ErrCheckHalted,
 		)
 	}
 
 	if !t.reachedEndConditions {
-		return results.ExitConstruction(t.config, t.counterStorage, t.jobStorage, err)
+		return results.ExitConstruction(
+			t.config,
+			t.counterStorage,
+			t.jobStorage,
+			t.operationTypes,
+			err,
+		)
 	}
 
 	// We optimistically run the ReturnFunds function on the coordinator
 	// and only log if it fails. If there is no ReturnFunds workflow defined,
 	// this will just return nil.
-	t.returnFunds(
+	if err := t.ReturnFunds(
 		context.Background(),
 		sigListeners,
-	)
+	); err != nil && !errors.Is(err, results.ErrCheckHalted) {
+		log.Println(err.Error())
+	}
+
+	if t.recorder != nil {
+		if err := t.recorder.Save(t.config.Construction.FixtureOutputFile); err != nil {
+			return results.ExitConstruction(
+				t.config,
+				t.counterStorage,
+				t.jobStorage,
+				t.operationTypes,
+				err,
+			)
+		}
 
-	return results.ExitConstruction(t.config, t.counterStorage, t.jobStorage, nil)
+		color.Green("Construction fixture saved to %s", t.config.Construction.FixtureOutputFile)
+	}
+
+	if err := t.checkUnconfirmedBroadcasts(context.Background()); err != nil {
+		return results.ExitConstruction(
+			t.config,
+			t.counterStorage,
+			t.jobStorage,
+			t.operationTypes,
+			err,
+		)
+	}
+
+	return results.ExitConstruction(
+		t.config,
+		t.counterStorage,
+		t.jobStorage,
+		t.operationTypes,
+		nil,
+	)
 }
@@ -0,0 +1,151 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tester
+
+import (
+	"context"
+	"encoding/hex"
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/keys"
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/coinbase/rosetta-sdk-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func prefundedAccount(t *testing.T, address string) *storage.PrefundedAccount {
+	keyPair, err := keys.GenerateKeypair(types.Secp256k1)
+	assert.NoError(t, err)
+
+	return &storage.PrefundedAccount{
+		PrivateKeyHex:     hex.EncodeToString(keyPair.PrivateKey),
+		AccountIdentifier: &types.AccountIdentifier{Address: address},
+		CurveType:         types.Secp256k1,
+		Currency:          &types.Currency{Symbol: "BTC", Decimals: 8},
+	}
+}
+
+func TestResumePrefundedAccounts(t *testing.T) {
+	ctx := context.Background()
+
+	dir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(dir)
+
+	first := prefundedAccount(t, "addr 1")
+	second := prefundedAccount(t, "addr 2")
+	added := prefundedAccount(t, "addr 3")
+
+	// run opens the construction storage in dir, returns the
+	// prefunded accounts whose balances would be fetched, imports
+	// prefundedAccounts, and returns all stored accounts.
+	run := func(
+		prefundedAccounts []*storage.PrefundedAccount,
+		syncBlock *types.Block,
+	) (bool, []*storage.PrefundedAccount, []*types.AccountIdentifier) {
+		database, err := storage.NewBadgerStorage(ctx, dir)
+		assert.NoError(t, err)
+		defer database.Close(ctx)
+
+		blockStorage := storage.NewBlockStorage(database)
+		keyStorage := storage.NewKeyStorage(database)
+		jobStorage := storage.NewJobStorage(database)
+		broadcastStorage := storage.NewBroadcastStorage(database, 0, 0, 0, false, 0)
+
+		resuming, err := isResuming(ctx, blockStorage)
+		assert.NoError(t, err)
+		if resuming {
+			assert.NoError(t, logResumedState(ctx, broadcastStorage, jobStorage))
+		}
+
+		toFetch, err := prefundedAccountsToFetch(ctx, keyStorage, prefundedAccounts, resuming)
+		assert.NoError(t, err)
+		assert.NoError(t, keyStorage.ImportAccounts(ctx, prefundedAccounts))
+
+		accounts, err := keyStorage.GetAllAccounts(ctx)
+		assert.NoError(t, err)
+
+		if syncBlock != nil {
+			assert.NoError(t, blockStorage.AddBlock(ctx, syncBlock))
+		}
+
+		return resuming, toFetch, accounts
+	}
+
+	genesis := &types.Block{
+		BlockIdentifier:       &types.BlockIdentifier{Hash: "block 0", Index: 0},
+		ParentBlockIdentifier: &types.BlockIdentifier{Hash: "block 0", Index: 0},
+	}
+
+	resuming, toFetch, accounts := run([]*storage.PrefundedAccount{first, second}, genesis)
+	assert.False(t, resuming)
+	assert.Equal(t, []*storage.PrefundedAccount{first, second}, toFetch)
+	assert.ElementsMatch(t, []*types.AccountIdentifier{
+		first.AccountIdentifier,
+		second.AccountIdentifier,
+	}, accounts)
+
+	var tests = map[string]struct {
+		prefundedAccounts []*storage.PrefundedAccount
+
+		expectedToFetch  []*storage.PrefundedAccount
+		expectedAccounts []*types.AccountIdentifier
+	}{
+		"same prefunded accounts": {
+			prefundedAccounts: []*storage.PrefundedAccount{first, second},
+			expectedToFetch:   []*storage.PrefundedAccount{},
+			expectedAccounts: []*types.AccountIdentifier{
+				first.AccountIdentifier,
+				second.AccountIdentifier,
+			},
+		},
+		"added prefunded account": {
+			prefundedAccounts: []*storage.PrefundedAccount{first, second, added},
+			expectedToFetch:   []*storage.PrefundedAccount{added},
+			expectedAccounts: []*types.AccountIdentifier{
+				first.AccountIdentifier,
+				second.AccountIdentifier,
+				added.AccountIdentifier,
+			},
+		},
+		"removed prefunded account": {
+			prefundedAccounts: []*storage.PrefundedAccount{first},
+			expectedToFetch:   []*storage.PrefundedAccount{},
+			expectedAccounts: []*types.AccountIdentifier{
+				first.AccountIdentifier,
+				second.AccountIdentifier,
+				added.AccountIdentifier,
+			},
+		},
+	}
+
+	// Tests share the stored state of the previous run
+	// (like check:construction restarted several times),
+	// so they are run in order.
+	for _, name := range []string{
+		"same prefunded accounts",
+		"added prefunded account",
+		"removed prefunded account",
+	} {
+		test := tests[name]
+		t.Run(name, func(t *testing.T) {
+			resuming, toFetch, accounts := run(test.prefundedAccounts, nil)
+			assert.True(t, resuming)
+			assert.Equal(t, test.expectedToFetch, toFetch)
+			assert.ElementsMatch(t, test.expectedAccounts, accounts)
+		})
+	}
+}
@@ -22,22 +22,43 @@ import (
 	"log"
 	"math/big"
 	"net/http"
+	"path"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/coinbase/rosetta-cli/configuration"
+	"github.com/coinbase/rosetta-cli/pkg/archive"
+	"github.com/coinbase/rosetta-cli/pkg/bootstrap"
+	"github.com/coinbase/rosetta-cli/pkg/bulkload"
+	"github.com/coinbase/rosetta-cli/pkg/compact"
+	"github.com/coinbase/rosetta-cli/pkg/control"
+	"github.com/coinbase/rosetta-cli/pkg/diskspace"
+	"github.com/coinbase/rosetta-cli/pkg/encryption"
+	"github.com/coinbase/rosetta-cli/pkg/failures"
+	"github.com/coinbase/rosetta-cli/pkg/invariant"
 	"github.com/coinbase/rosetta-cli/pkg/logger"
+	"github.com/coinbase/rosetta-cli/pkg/metrics"
+	"github.com/coinbase/rosetta-cli/pkg/opstats"
+	"github.com/coinbase/rosetta-cli/pkg/plugin"
 	"github.com/coinbase/rosetta-cli/pkg/processor"
+	"github.com/coinbase/rosetta-cli/pkg/provenance"
+	"github.com/coinbase/rosetta-cli/pkg/quorum"
 	"github.com/coinbase/rosetta-cli/pkg/results"
+	"github.com/coinbase/rosetta-cli/pkg/retry"
+	"github.com/coinbase/rosetta-cli/pkg/statefulsyncer"
+	"github.com/coinbase/rosetta-cli/pkg/stream"
+	"github.com/coinbase/rosetta-cli/pkg/timeseries"
+	"github.com/coinbase/rosetta-cli/pkg/upload"
 
 	"github.com/coinbase/rosetta-sdk-go/fetcher"
 	"github.com/coinbase/rosetta-sdk-go/parser"
 	"github.com/coinbase/rosetta-sdk-go/reconciler"
-	"github.com/coinbase/rosetta-sdk-go/statefulsyncer"
 	"github.com/coinbase/rosetta-sdk-go/storage"
-	"github.com/coinbase/rosetta-sdk-go/syncer"
 	"github.com/coinbase/rosetta-sdk-go/types"
 	"github.com/coinbase/rosetta-sdk-go/utils"
 	"github.com/fatih/color"
+	"github.com/tidwall/gjson"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -60,13 +81,35 @@ const (
 	// to the terminal.
 	PeriodicLoggingFrequency = periodicLoggingSeconds * time.Second
 
+	// ThroughputWindow is the window over which the
+	// current syncing throughput is measured.
+	ThroughputWindow = 5 * time.Minute
+
+	// EventsPollInterval is how long we wait to fetch
+	// new block events once all block events have been
+	// processed (when the events sync mode is used).
+	EventsPollInterval = 1 * time.Second
+
 	// EndAtTipCheckInterval is the frequency that EndAtTip condition
 	// is evaludated
 	EndAtTipCheckInterval = 10 * time.Second
+
+	// reconciliationDumpDirectory is the directory (in the
+	// data directory) where the context of each reconciliation
+	// failure is written.
+	reconciliationDumpDirectory = "reconciliation_failures"
+
+	// blockArchiveDirectory is the directory (in the
+	// data directory) where pruned blocks are archived.
+	blockArchiveDirectory = "block_archive"
 )
 
-var _ http.Handler = (*DataTester)(nil)
+var (
+	_ http.Handler                 = (*DataTester)(nil)
+	_ statefulsyncer.ArchiveHelper = (*DataTester)(nil)
+)
 var _ statefulsyncer.PruneHelper = (*DataTester)(nil)
+var _ ControlTester = (*DataTester)(nil)
 
 // DataTester coordinates the `check:data` test.
 type DataTester struct {
@@ -74,21 +117,50 @@ type DataTester struct {
 	database                 storage.Database
 	config                   *configuration.Configuration
 	syncer                   *statefulsyncer.StatefulSyncer
+	eventsSyncer             *statefulsyncer.EventsSyncer
 	reconciler               *reconciler.Reconciler
 	logger                   *logger.Logger
 	balanceStorage           *storage.BalanceStorage
 	blockStorage             *storage.BlockStorage
 	counterStorage           *storage.CounterStorage
+	failureStorage           *failures.Storage
+	sampleStorage            *timeseries.Storage
 	reconcilerHandler        *processor.ReconcilerHandler
+	balanceStorageHandler    *processor.BalanceStorageHandler
 	fetcher                  *fetcher.Fetcher
 	signalReceived           *bool
 	genesisBlock             *types.BlockIdentifier
 	cancel                   context.CancelFunc
 	historicalBalanceEnabled bool
 	parser                   *parser.Parser
+	asserterRefresher        *processor.AsserterRefreshWorker
+	asserterModeWorker       *processor.AsserterModeWorker
+	optionalWorkers          []*processor.OptionalWorker
+	nodeMonitor              *processor.NodeMonitor
+	syncRestarter            *statefulsyncer.Restarter
+	diskSpaceWatchdog        *diskspace.Watchdog
+	accountFiles             *accountFileReloader
+	operationStats           *opstats.Storage
+	throughputTracker        *results.ThroughputTracker
+	plugins                  []*plugin.Plugin
+	metricsClient            *metrics.Client
+	controller               *control.Controller
+	uploader                 *upload.Uploader
+	blockArchive             *archive.Archive
+	run                      *provenance.Run
+	dataPath                 string
 
 	endCondition       configuration.CheckDataEndCondition
 	endConditionDetail string
+
+	// diskSpaceErr is populated when the disk space
+	// watchdog halts syncing (which causes the syncer
+	// to return statefulsyncer.ErrHalted).
+	diskSpaceErr error
+
+	// haltAborted is set when the operator aborts a
+	// check halted by HaltOnError.
+	haltAborted bool
 }
 
 func shouldReconcile(config *configuration.Configuration) bool {
@@ -103,6 +175,25 @@ func shouldReconcile(config *configuration.Configuration) bool {
 	return true
 }
 
+// isOptionalWorker returns a boolean indicating if
+// the worker with name is configured to be optional.
+func isOptionalWorker(
+	config *configuration.Configuration,
+	name configuration.OptionalWorker,
+) bool {
+	if config.Data.OptionalWorkers == nil {
+		return false
+	}
+
+	for _, worker := range config.Data.OptionalWorkers.Workers {
+		if worker == name {
+			return true
+		}
+	}
+
+	return false
+}
+
 // loadAccounts is a utility function to parse the []*types.AccountCurrency
 // in a file.
 func loadAccounts(filePath string) ([]*types.AccountCurrency, error) {
@@ -125,11 +216,154 @@ func loadAccounts(filePath string) ([]*types.AccountCurrency, error) {
 	return accounts, nil
 }
 
-// CloseDatabase closes the database used by DataTester.
-func (t *DataTester) CloseDatabase(ctx context.Context) {
+// newQuorumFetcher returns a *quorum.Fetcher that fetches
+// blocks from online_url and all configured quorum URLs.
+func newQuorumFetcher(
+	config *configuration.Configuration,
+	onlineFetcher *fetcher.Fetcher,
+	failureStorage *failures.Storage,
+) (*quorum.Fetcher, error) {
+	endpoints := []*quorum.Endpoint{quorum.NewEndpoint(config.OnlineURL, onlineFetcher)}
+	for _, url := range config.Data.Quorum.URLs {
+		urlFetcher, err := retry.NewFetcher(
+			config,
+			url,
+			fetcher.WithMaxConnections(config.MaxOnlineConnections),
+			fetcher.WithAsserter(onlineFetcher.Asserter),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to create fetcher for %s", err, url)
+		}
+
+		endpoints = append(endpoints, quorum.NewEndpoint(url, urlFetcher))
+	}
+
+	return quorum.New(endpoints, config.Data.Quorum.Size, failureStorage), nil
+}
+
+// DataPath returns the path of the check:data
+// database for network in dataDirectory.
+func DataPath(dataDirectory string, network *types.NetworkIdentifier) string {
+	return path.Join(dataDirectory, dataCmdName, types.Hash(network))
+}
+
+// BlockArchivePath returns the path of the check:data
+// block archive for network in dataDirectory.
+func BlockArchivePath(dataDirectory string, network *types.NetworkIdentifier) string {
+	return path.Join(DataPath(dataDirectory, network), blockArchiveDirectory)
+}
+
+// CloseDatabase flushes all logger streams and
+// closes the database used by DataTester. If artifact
+// upload is configured, the artifacts of the run are
+// uploaded once the database is closed (so the snapshot
+// of the data directory is consistent). An error is only
+// returned if the database cannot be closed.
+func (t *DataTester) CloseDatabase(ctx context.Context) error {
+	// The run is saved again so that the stored copy
+	// includes when (and why) the run ended.
+	if len(t.config.DataDirectory) > 0 {
+		if err := t.run.Save(t.config.DataDirectory); err != nil {
+			log.Printf("%s: unable to save run\n", err.Error())
+		}
+	}
+
+	var artifactDir string
+	var artifacts []*upload.Artifact
+	if t.uploader != nil {
+		var err error
+		artifactDir, artifacts, err = t.prepareArtifacts(ctx, true)
+		if err != nil {
+			log.Printf("%s: unable to prepare artifacts\n", err.Error())
+		}
+	}
+
+	if err := t.logger.Close(); err != nil {
+		log.Printf("%s: error flushing logger streams\n", err.Error())
+	}
+
+	for _, p := range t.plugins {
+		if err := p.Close(); err != nil {
+			log.Printf("%s: error closing plugin\n", err.Error())
+		}
+	}
+
+	if t.metricsClient != nil {
+		if err := t.metricsClient.Close(); err != nil {
+			log.Printf("%s: error closing statsd client\n", err.Error())
+		}
+	}
+
+	if t.blockArchive != nil {
+		if err := t.blockArchive.Close(); err != nil {
+			log.Printf("%s: error closing block archive\n", err.Error())
+		}
+	}
+
 	if err := t.database.Close(ctx); err != nil {
-		log.Fatalf("%s: error closing database", err.Error())
+		return fmt.Errorf("%w: error closing database", err)
+	}
+
+	if len(artifactDir) == 0 {
+		return nil
+	}
+
+	// ctx may already be canceled when check:data
+	// exits, so uploads are not made with ctx.
+	if err := t.uploadArtifacts(context.Background(), artifactDir, artifacts, true); err != nil {
+		log.Printf("%s: unable to upload artifacts\n", err.Error())
+		return nil
+	}
+
+	color.Cyan("uploaded artifacts to %s", t.uploader.RunURL())
+	return nil
+}
+
+// orderSeenAccounts returns seenAccounts in the order they should
+// be queued for inactive reconciliation, using their computed
+// balances at the last synced block. If no block has been
+// synced, seenAccounts is returned unchanged.
+func orderSeenAccounts(
+	ctx context.Context,
+	order configuration.InactiveReconciliationOrder,
+	localStore storage.Database,
+	blockStorage *storage.BlockStorage,
+	balanceStorage *storage.BalanceStorage,
+	seenAccounts []*types.AccountCurrency,
+) ([]*types.AccountCurrency, error) {
+	if order != configuration.BalanceInactiveReconciliationOrder {
+		return seenAccounts, nil
+	}
+
+	head, err := blockStorage.GetHeadBlockIdentifier(ctx)
+	if errors.Is(err, storage.ErrHeadBlockNotFound) {
+		return seenAccounts, nil
 	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to get head block", err)
+	}
+
+	dbTx := localStore.NewDatabaseTransaction(ctx, false)
+	defer dbTx.Discard(ctx)
+
+	return processor.OrderInactiveAccounts(
+		seenAccounts,
+		order,
+		func(account *types.AccountCurrency) (string, error) {
+			amount, err := balanceStorage.GetBalanceTransactional(
+				ctx,
+				dbTx,
+				account.Account,
+				account.Currency,
+				head.Index,
+			)
+			if err != nil {
+				return "", err
+			}
+
+			return amount.Value, nil
+		},
+	)
 }
 
 // InitializeData returns a new *DataTester.
@@ -142,38 +376,169 @@ func InitializeData(
 	genesisBlock *types.BlockIdentifier,
 	interestingAccount *types.AccountCurrency,
 	signalReceived *bool,
-) *DataTester {
-	dataPath, err := utils.CreateCommandPath(config.DataDirectory, dataCmdName, network)
+) (*DataTester, error) {
+	return initializeData(
+		ctx,
+		config,
+		network,
+		fetcher,
+		cancel,
+		genesisBlock,
+		interestingAccount,
+		signalReceived,
+		false,
+	)
+}
+
+// openDatabase opens the check:data database in dataPath. If
+// bulkLoad is true, it is opened with a *bulkload.Database.
+// Otherwise, it is opened with storage.BadgerStorage (and
+// bulkload.ErrInterrupted is returned if a bulk load of it
+// was interrupted).
+func openDatabase(
+	ctx context.Context,
+	config *configuration.Configuration,
+	dataPath string,
+	bulkLoad bool,
+) (storage.Database, error) {
+	key, err := encryption.LoadKey(config.Encryption)
 	if err != nil {
-		log.Fatalf("%s: cannot create command path", err.Error())
+		return nil, fmt.Errorf("%w: unable to load encryption key", err)
+	}
+	badgerOptions := encryption.BadgerOptions(dataPath, config.MemoryLimitDisabled, key)
+
+	if bulkLoad {
+		return bulkload.Open(
+			ctx,
+			badgerOptions,
+			!config.CompressionDisabled,
+			bulkload.DefaultFlushSize,
+		)
 	}
 
 	opts := []storage.BadgerOption{}
 	if config.CompressionDisabled {
 		opts = append(opts, storage.WithoutCompression())
 	}
-	if config.MemoryLimitDisabled {
-		opts = append(opts, storage.WithCustomSettings(storage.PerformanceBadgerOptions(dataPath)))
-	}
+	opts = append(opts, storage.WithCustomSettings(badgerOptions))
 
 	localStore, err := storage.NewBadgerStorage(ctx, dataPath, opts...)
 	if err != nil {
-		log.Fatalf("%s: unable to initialize database", err.Error())
+		return nil, err
+	}
+
+	interrupted, err := bulkload.Interrupted(ctx, localStore)
+	if err == nil && interrupted {
+		err = bulkload.ErrInterrupted
+	}
+
+	if err != nil {
+		if closeErr := localStore.Close(ctx); closeErr != nil {
+			log.Printf("%s: unable to close database\n", closeErr.Error())
+		}
+
+		return nil, err
+	}
+
+	return localStore, nil
+}
+
+// initializeData returns a new *DataTester. If bulkLoad
+// is true, its database is a *bulkload.Database.
+func initializeData(
+	ctx context.Context,
+	config *configuration.Configuration,
+	network *types.NetworkIdentifier,
+	fetcher *fetcher.Fetcher,
+	cancel context.CancelFunc,
+	genesisBlock *types.BlockIdentifier,
+	interestingAccount *types.AccountCurrency,
+	signalReceived *bool,
+	bulkLoad bool,
+) (*DataTester, error) {
+	dataPath, err := utils.CreateCommandPath(config.DataDirectory, dataCmdName, network)
+	if err != nil {
+		return nil, fmt.Errorf("%w: cannot create command path", err)
+	}
+
+	localStore, err := openDatabase(ctx, config, dataPath, bulkLoad)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to initialize database", err)
+	}
+
+	// If initialization fails, everything opened so far
+	// is closed so that the data directory can be reused.
+	closers := []func() error{
+		func() error { return localStore.Close(ctx) },
 	}
+	initialized := false
+	defer func() {
+		if initialized {
+			return
+		}
+
+		for i := len(closers) - 1; i >= 0; i-- {
+			if err := closers[i](); err != nil {
+				log.Printf("%s: unable to close after initialization failure\n", err.Error())
+			}
+		}
+	}()
 
 	exemptAccounts, err := loadAccounts(config.Data.ExemptAccounts)
 	if err != nil {
-		log.Fatalf("%s: unable to load exempt accounts", err.Error())
+		return nil, fmt.Errorf("%w: unable to load exempt accounts", err)
 	}
 
 	interestingAccounts, err := loadAccounts(config.Data.InterestingAccounts)
 	if err != nil {
-		log.Fatalf("%s: unable to load interesting accounts", err.Error())
+		return nil, fmt.Errorf("%w: unable to load interesting accounts", err)
+	}
+
+	currencyFilter := processor.NewCurrencyFilter(
+		config.Data.TrackedCurrencies,
+		config.Data.IgnoredCurrencies,
+	)
+	interestingAccounts = currencyFilter.FilterAccounts(interestingAccounts)
+
+	var subscribedAccounts []*types.AccountCurrency
+	if len(config.Data.SubscribedAccounts) > 0 {
+		subscribedAccounts, err = loadAccounts(config.Data.SubscribedAccounts)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to load subscribed accounts", err)
+		}
 	}
 
 	counterStorage := storage.NewCounterStorage(localStore)
 	blockStorage := storage.NewBlockStorage(localStore)
 	balanceStorage := storage.NewBalanceStorage(localStore)
+	failureStorage := failures.NewStorage(localStore)
+
+	var accountCreationWorker *processor.AccountCreationWorker
+	if config.Data.AccountCreation != nil {
+		accountCreationWorker = processor.NewAccountCreationWorker(
+			fetcher.Asserter,
+			config.Data.AccountCreation.OperationTypes,
+			failureStorage,
+		)
+	}
+
+	var balanceCache *processor.BalanceCache
+	if config.Data.BalanceCacheSize > 0 {
+		balanceCache = processor.NewBalanceCache(
+			localStore,
+			balanceStorage,
+			config.Data.BalanceCacheSize,
+		)
+	}
+
+	// Balances are set and reconciled through the balance
+	// cache (if any) so that it can invalidate them.
+	var balanceSetter bootstrap.BalanceSetter = balanceStorage
+	var balanceReconciler processor.BalanceReconciler = balanceStorage
+	if balanceCache != nil {
+		balanceSetter = balanceCache
+		balanceReconciler = balanceCache
+	}
 
 	// Bootstrap balances, if provided. We need to do before initializing
 	// the reconciler otherwise we won't reconcile bootstrapped accounts
@@ -182,16 +547,42 @@ func InitializeData(
 		_, err := blockStorage.GetHeadBlockIdentifier(ctx)
 		switch {
 		case err == storage.ErrHeadBlockNotFound:
-			err = balanceStorage.BootstrapBalances(
+			_, err = bootstrap.ImportBalances(
 				ctx,
+				localStore,
+				balanceSetter,
 				config.Data.BootstrapBalances,
 				genesisBlock,
 			)
 			if err != nil {
-				log.Fatalf("%s: unable to bootstrap balances", err.Error())
+				return nil, fmt.Errorf("%w: unable to bootstrap balances", err)
+			}
+
+			if config.Data.ValidateBootstrapBalances {
+				err = bootstrap.ValidateBalances(
+					ctx,
+					network,
+					fetcher,
+					config.Data.BootstrapBalances,
+					genesisBlock,
+				)
+				if err != nil {
+					return nil, fmt.Errorf("%w: unable to validate bootstrap balances", err)
+				}
+			}
+
+			if accountCreationWorker != nil {
+				err = accountCreationWorker.BootstrapAccounts(
+					ctx,
+					localStore,
+					config.Data.BootstrapBalances,
+				)
+				if err != nil {
+					return nil, fmt.Errorf("%w: unable to mark bootstrapped accounts as created", err)
+				}
 			}
 		case err != nil:
-			log.Fatalf("%s: unable to get head block identifier", err.Error())
+			return nil, fmt.Errorf("%w: unable to get head block identifier", err)
 		default:
 			log.Println("Skipping balance bootstrapping because already started syncing")
 		}
@@ -203,7 +594,33 @@ func InitializeData(
 		config.Data.LogTransactions,
 		config.Data.LogBalanceChanges,
 		config.Data.LogReconciliations,
+		config.Data.ReconciliationStream,
 	)
+	closers = append(closers, logger.Close)
+
+	var nodeMonitor *processor.NodeMonitor
+	if config.NodeRestartPatience > 0 {
+		nodeMonitor = processor.NewNodeMonitor(
+			network,
+			fetcher,
+			time.Duration(config.NodeRestartPatience)*time.Second,
+		)
+	}
+
+	// The controller is only created when runtime
+	// controls are enabled so that requests are not
+	// gated otherwise.
+	var controller *control.Controller
+	if config.Data.RuntimeControls {
+		controller = control.New()
+	}
+
+	// The asserter is only refreshed (in place) when asserter
+	// refresh or an asserter mode is configured.
+	var asserterGuard *processor.AsserterGuard
+	if config.Data.AsserterRefresh != nil || len(config.Data.AsserterMode) > 0 {
+		asserterGuard = processor.NewAsserterGuard()
+	}
 
 	reconcilerHelper := processor.NewReconcilerHelper(
 		config,
@@ -212,28 +629,54 @@ func InitializeData(
 		localStore,
 		blockStorage,
 		balanceStorage,
-	)
-
-	reconcilerHandler := processor.NewReconcilerHandler(
-		logger,
-		counterStorage,
-		balanceStorage,
-		!config.Data.IgnoreReconciliationError,
+		nodeMonitor,
+		controller,
+		balanceCache,
+		asserterGuard,
 	)
 
 	// Get all previously seen accounts
 	seenAccounts, err := balanceStorage.GetAllAccountCurrency(ctx)
 	if err != nil {
-		log.Fatalf("%s: unable to get previously seen accounts", err.Error())
+		return nil, fmt.Errorf("%w: unable to get previously seen accounts", err)
 	}
 
-	networkOptions, fetchErr := fetcher.NetworkOptionsRetry(ctx, network, nil)
+	// Accounts seen before the currency filter was
+	// changed should not be reconciled.
+	seenAccounts = currencyFilter.FilterAccounts(seenAccounts)
+	seenAccounts, err = orderSeenAccounts(
+		ctx,
+		config.Data.InactiveReconciliationOrder,
+		localStore,
+		blockStorage,
+		balanceStorage,
+		seenAccounts,
+	)
 	if err != nil {
-		log.Fatalf("%s: unable to get network options", fetchErr.Err.Error())
+		return nil, fmt.Errorf("%w: unable to order previously seen accounts", err)
+	}
+
+	networkOptions, fetchErr := fetcher.NetworkOptionsRetry(ctx, network, nil)
+	if fetchErr != nil {
+		return nil, fmt.Errorf("%w: unable to get network options", fetchErr.Err)
+	}
+
+	// The run is stamped on all log streams and the results
+	// file and is stored in the data directory (so that it
+	// can be listed with view:runs).
+	run := provenance.New("check:data", config, networkOptions.Version, time.Now())
+	if err := logger.StampRun(run); err != nil {
+		return nil, fmt.Errorf("%w: unable to stamp run on logs", err)
+	}
+
+	if len(config.DataDirectory) > 0 {
+		if err := run.Save(config.DataDirectory); err != nil {
+			return nil, fmt.Errorf("%w: unable to save run", err)
+		}
 	}
 
 	if len(networkOptions.Allow.BalanceExemptions) > 0 && config.Data.InitialBalanceFetchDisabled {
-		log.Fatal("found balance exemptions but initial balance fetch disabled")
+		return nil, errors.New("found balance exemptions but initial balance fetch disabled")
 	}
 
 	parser := parser.New(
@@ -250,16 +693,55 @@ func InitializeData(
 		historicalBalanceEnabled = networkOptions.Allow.HistoricalBalanceLookup
 	}
 
+	if config.Data.CheckpointBalances && !historicalBalanceEnabled {
+		return nil, errors.New("checkpoint balances require historical balance lookup")
+	}
+
+	var interpolator *processor.BalanceInterpolator
+	if config.Data.BalanceInterpolationSamples > 0 {
+		if !historicalBalanceEnabled {
+			return nil, errors.New("balance interpolation requires historical balance lookup")
+		}
+
+		interpolator = processor.NewBalanceInterpolator(
+			reconcilerHelper,
+			config.Data.BalanceInterpolationSamples,
+		)
+	}
+
+	var dumper *processor.ReconciliationDumper
+	if config.Data.ReconciliationDumpBlocks > 0 {
+		dumper = processor.NewReconciliationDumper(
+			network,
+			fetcher,
+			asserterGuard.Successful(fetcher.Asserter),
+			localStore,
+			blockStorage,
+			balanceStorage,
+			path.Join(dataPath, reconciliationDumpDirectory),
+			config.Data.ReconciliationDumpBlocks,
+		)
+	}
+
+	reconcilerHandler := processor.NewReconcilerHandler(
+		logger,
+		counterStorage,
+		balanceReconciler,
+		failureStorage,
+		interpolator,
+		dumper,
+		config.Data.ReconciliationTolerances,
+		!config.Data.IgnoreReconciliationError,
+		config.Data.ReconciliationFailureBudget,
+	)
+
 	rOpts := []reconciler.Option{
 		reconciler.WithActiveConcurrency(int(config.Data.ActiveReconciliationConcurrency)),
 		reconciler.WithInactiveConcurrency(int(config.Data.InactiveReconciliationConcurrency)),
-		reconciler.WithInterestingAccounts(interestingAccounts),
 		reconciler.WithSeenAccounts(seenAccounts),
 		reconciler.WithInactiveFrequency(int64(config.Data.InactiveReconciliationFrequency)),
 		reconciler.WithBalancePruning(),
-	}
-	if config.Data.ReconcilerActiveBacklog != nil {
-		rOpts = append(rOpts, reconciler.WithBacklogSize(*config.Data.ReconcilerActiveBacklog))
+		reconciler.WithBacklogSize(reconcilerBacklogSize(config)),
 	}
 	if historicalBalanceEnabled {
 		rOpts = append(rOpts, reconciler.WithLookupBalanceByBlock())
@@ -276,27 +758,221 @@ func InitializeData(
 	)
 
 	blockWorkers := []storage.BlockWorker{}
+
+	// The asserter refresher runs first so that no
+	// other worker processes a block that must be
+	// re-fetched after a refresh.
+	var asserterRefresher *processor.AsserterRefreshWorker
+	if config.Data.AsserterRefresh != nil {
+		asserterRefresher = processor.NewAsserterRefreshWorker(
+			network,
+			fetcher,
+			asserterGuard,
+			config.Data.AsserterRefresh,
+		)
+
+		blockWorkers = append(blockWorkers, asserterRefresher)
+	}
+
+	var asserterModeWorker *processor.AsserterModeWorker
+	if len(config.Data.AsserterMode) > 0 {
+		asserterModeWorker = processor.NewAsserterModeWorker(
+			network,
+			fetcher,
+			asserterGuard,
+			counterStorage,
+			config.Data.AsserterMode,
+			config.Data.AsserterWarnings,
+		)
+
+		blockWorkers = append(blockWorkers, asserterModeWorker)
+	}
+
+	optionalWorkers := []*processor.OptionalWorker{}
+	addWorker := func(name configuration.OptionalWorker, worker storage.BlockWorker) {
+		if isOptionalWorker(config, name) {
+			optionalWorker := processor.NewOptionalWorker(
+				string(name),
+				worker,
+				config.Data.OptionalWorkers.MaxConsecutiveErrors,
+			)
+			optionalWorkers = append(optionalWorkers, optionalWorker)
+			worker = optionalWorker
+		}
+
+		blockWorkers = append(blockWorkers, worker)
+	}
+
+	if accountCreationWorker != nil {
+		addWorker(configuration.AccountCreationWorker, accountCreationWorker)
+	}
+
+	if config.Data.FeeValidation != nil {
+		addWorker(configuration.FeeWorker, processor.NewFeeWorker(
+			fetcher.Asserter,
+			config.Data.FeeValidation.OperationTypes,
+			config.Data.FeeValidation.MetadataPath,
+			failureStorage,
+		))
+	}
+
+	if config.Data.DuplicateHashDetection != nil {
+		addWorker(configuration.DuplicateHashWorker, processor.NewDuplicateHashWorker(
+			config.Data.DuplicateHashDetection.ExemptTransactions,
+			failureStorage,
+		))
+	}
+
+	if config.Data.TimestampValidation != nil {
+		addWorker(configuration.TimestampWorker, processor.NewTimestampWorker(
+			network,
+			fetcher,
+			config.Data.TimestampValidation,
+			failureStorage,
+		))
+	}
+
+	if config.Data.SupplyTracking != nil {
+		addWorker(configuration.SupplyWorker, processor.NewSupplyWorker(
+			network,
+			fetcher,
+			fetcher.Asserter,
+			config.Data.SupplyTracking,
+			failureStorage,
+		))
+	}
+
+	if config.Data.BlockHashVerificationFrequency > 0 {
+		addWorker(configuration.BlockHashVerificationWorker, processor.NewBlockFetchWorker(
+			network,
+			fetcher,
+			config.Data.BlockHashVerificationFrequency,
+			failureStorage,
+		))
+	}
+
+	if config.Data.TransactionFetchVerificationSamples > 0 {
+		addWorker(
+			configuration.TransactionFetchVerificationWorker,
+			processor.NewTransactionFetchWorker(
+				network,
+				fetcher,
+				config.Data.TransactionFetchVerificationSamples,
+				failureStorage,
+			),
+		)
+	}
+
+	if config.Data.ReorgAlerts != nil {
+		blockWorkers = append(
+			blockWorkers,
+			processor.NewReorgWorker(config.Data.ReorgAlerts, failureStorage),
+		)
+	}
+
+	if len(config.Data.Invariants) > 0 {
+		invariants := make([]*invariant.Invariant, len(config.Data.Invariants))
+		for i, cfg := range config.Data.Invariants {
+			invariants[i], err = invariant.New(cfg.Name, cfg.Scope, cfg.Condition, cfg.Assertion)
+			if err != nil {
+				return nil, fmt.Errorf("%w: unable to compile invariant %s", err, cfg.Name)
+			}
+		}
+
+		blockWorkers = append(blockWorkers, processor.NewInvariantWorker(invariants, failureStorage))
+	}
+
+	var accountFiles *accountFileReloader
+	var balanceStorageHandler *processor.BalanceStorageHandler
 	if !config.Data.BalanceTrackingDisabled {
 		balanceStorageHelper := processor.NewBalanceStorageHelper(
 			network,
 			fetcher,
 			historicalBalanceEnabled,
 			exemptAccounts,
+			currencyFilter,
 			false,
 			networkOptions.Allow.BalanceExemptions,
 			config.Data.InitialBalanceFetchDisabled,
 		)
 
-		balanceStorageHandler := processor.NewBalanceStorageHandler(
+		// Only track the balances of subscribed
+		// accounts, if any are provided.
+		if subscribedAccounts != nil {
+			balanceStorageHelper.SubscribeAccounts(subscribedAccounts)
+		}
+
+		balanceStorageHandler = processor.NewBalanceStorageHandler(
 			logger,
 			r,
 			shouldReconcile(config),
 			interestingAccount,
 		)
+		balanceStorageHandler.SetInterestingAccounts(interestingAccounts)
+		if config.Data.ReconcilerBacklogMode == configuration.BlockBacklogMode {
+			balanceStorageHandler.EnableBacklogBlocking(reconcilerBacklogSize(config))
+		}
+		if balanceCache != nil {
+			balanceStorageHandler.EnableBalanceCache(balanceCache)
+		}
+		if config.Data.LogBalanceChanges {
+			balanceStorageHandler.EnableBalanceOperations(
+				fetcher.Asserter.OperationSuccessful,
+				localStore,
+				balanceStorage,
+			)
+		}
 
 		balanceStorage.Initialize(balanceStorageHelper, balanceStorageHandler)
 
-		blockWorkers = append(blockWorkers, balanceStorage)
+		if config.Data.AccountFileReloadInterval > 0 {
+			accountFiles = newAccountFileReloader(
+				config.Data.ExemptAccounts,
+				config.Data.InterestingAccounts,
+				exemptAccounts,
+				interestingAccounts,
+				currencyFilter,
+				balanceStorageHelper,
+				balanceStorageHandler,
+			)
+		}
+
+		// The balance cache (if any) applies balance changes
+		// with the *processor.ShardedBalanceWorker (in a single
+		// shard if balance writes are not sharded).
+		var balanceWorker storage.BlockWorker = balanceStorage
+		var balanceUpdater processor.BalanceUpdater = balanceStorage
+		if balanceCache != nil {
+			balanceUpdater = balanceCache.Updater(balanceStorage, balanceStorageHelper)
+		}
+		if config.Data.BalanceWriteShards > 1 || balanceCache != nil {
+			shards := config.Data.BalanceWriteShards
+			if shards < 1 {
+				shards = 1
+			}
+
+			balanceWorker = processor.NewShardedBalanceWorker(
+				localStore,
+				balanceUpdater,
+				balanceStorageHelper,
+				balanceStorageHandler,
+				shards,
+			)
+		}
+
+		if config.Data.SubAccountMode == configuration.AggregateSubAccounts {
+			balanceWorker = processor.NewSubAccountAggregationWorker(balanceWorker)
+		}
+
+		blockWorkers = append(blockWorkers, balanceWorker)
+
+		// Balances trusted on first sight are persisted after
+		// balance storage has processed each block.
+		if config.Data.CheckpointBalances {
+			checkpointWorker := processor.NewCheckpointBalanceWorker(localStore, counterStorage)
+			balanceStorageHelper.RecordCheckpoints(checkpointWorker)
+			blockWorkers = append(blockWorkers, checkpointWorker)
+		}
 	}
 
 	if !config.Data.CoinTrackingDisabled {
@@ -306,70 +982,402 @@ func InitializeData(
 		blockWorkers = append(blockWorkers, coinStorage)
 	}
 
+	var operationStats *opstats.Storage
+	if config.Data.OperationStatsEnabled {
+		operationStats = opstats.NewStorage(localStore, fetcher.Asserter)
+		blockWorkers = append(blockWorkers, operationStats)
+	}
+
+	// Plugins run after all other workers so that
+	// they are only sent blocks that pass all checks.
+	plugins := make([]*plugin.Plugin, len(config.Data.Plugins))
+	for i, cfg := range config.Data.Plugins {
+		plugins[i], err = plugin.Start(
+			cfg.Name,
+			cfg.Path,
+			cfg.Args,
+			network,
+			time.Duration(cfg.Timeout)*time.Second,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to start plugin %s", err, cfg.Name)
+		}
+		closers = append(closers, plugins[i].Close)
+
+		blockWorkers = append(blockWorkers, processor.NewPluginWorker(plugins[i], failureStorage))
+	}
+
+	// Blocks are streamed after plugins so that only
+	// blocks that pass all checks are published.
+	if cfg := config.Data.BlockStream; cfg != nil {
+		addWorker(
+			configuration.BlockStreamWorker,
+			processor.NewStreamWorker(stream.New(cfg), network),
+		)
+	}
+
+	var metricsClient *metrics.Client
+	if cfg := config.Data.Statsd; cfg != nil {
+		metricsClient, err = metrics.New(cfg.Host, cfg.Port, cfg.Prefix, cfg.Tags)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to initialize statsd client", err)
+		}
+		closers = append(closers, metricsClient.Close)
+
+		blockWorkers = append(blockWorkers, processor.NewMetricsWorker(metricsClient))
+	}
+
+	// When a quorum is configured, each block is fetched
+	// from multiple endpoints instead of just online_url.
+	var blockFetcher statefulsyncer.BlockFetcher
+	if config.Data.Quorum != nil {
+		blockFetcher, err = newQuorumFetcher(config, fetcher, failureStorage)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// In permissive mode, blocks that fail assertion
+	// because of warnings are accepted.
+	if config.Data.AsserterMode == configuration.PermissiveAsserterMode {
+		blockFetcher = asserterModeWorker
+	}
+
+	var gate statefulsyncer.Gate
+	if controller != nil {
+		gate = controller
+	}
+
 	syncer := statefulsyncer.New(
 		ctx,
 		network,
 		fetcher,
+		blockFetcher,
+		gate,
 		blockStorage,
 		counterStorage,
 		logger,
 		cancel,
 		blockWorkers,
-		syncer.DefaultCacheSize,
+		config.SyncCacheSize,
 		config.MaxSyncConcurrency,
 		config.MaxReorgDepth,
 	)
 
+	var diskSpaceWatchdog *diskspace.Watchdog
+	if config.Data.DiskSpaceWatchdog != nil {
+		diskSpaceWatchdog = diskspace.NewWatchdog(dataPath, config.Data.DiskSpaceWatchdog)
+	}
+
+	var blockArchive *archive.Archive
+	if config.Data.BlockArchive != nil {
+		blockArchive, err = archive.Open(
+			path.Join(dataPath, blockArchiveDirectory),
+			config.Data.BlockArchive.SegmentSize,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to open block archive", err)
+		}
+		closers = append(closers, blockArchive.Close)
+	}
+
+	var uploader *upload.Uploader
+	if config.Data.ArtifactUpload != nil {
+		uploader, err = upload.New(config.Data.ArtifactUpload, run.ID)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to initialize artifact upload", err)
+		}
+	}
+
+	var syncRestarter *statefulsyncer.Restarter
+	if config.Data.SyncRestarts != nil {
+		syncRestarter = statefulsyncer.NewRestarter(
+			blockStorage,
+			syncRestartPolicy(config.Data.SyncRestarts),
+		)
+	}
+
+	var eventsSyncer *statefulsyncer.EventsSyncer
+	if config.Data.SyncMode == configuration.EventsSyncMode {
+		eventsSyncer = statefulsyncer.NewEventsSyncer(syncer, fetcher, EventsPollInterval)
+	}
+
+	initialized = true
 	return &DataTester{
 		network:                  network,
 		database:                 localStore,
 		config:                   config,
 		syncer:                   syncer,
+		eventsSyncer:             eventsSyncer,
 		cancel:                   cancel,
 		reconciler:               r,
+		asserterRefresher:        asserterRefresher,
+		asserterModeWorker:       asserterModeWorker,
+		optionalWorkers:          optionalWorkers,
+		nodeMonitor:              nodeMonitor,
+		syncRestarter:            syncRestarter,
+		diskSpaceWatchdog:        diskSpaceWatchdog,
+		accountFiles:             accountFiles,
+		operationStats:           operationStats,
 		logger:                   logger,
 		balanceStorage:           balanceStorage,
 		blockStorage:             blockStorage,
 		counterStorage:           counterStorage,
+		failureStorage:           failureStorage,
+		sampleStorage:            timeseries.NewStorage(localStore),
 		reconcilerHandler:        reconcilerHandler,
+		balanceStorageHandler:    balanceStorageHandler,
 		fetcher:                  fetcher,
 		signalReceived:           signalReceived,
 		genesisBlock:             genesisBlock,
 		historicalBalanceEnabled: historicalBalanceEnabled,
 		parser:                   parser,
+		throughputTracker:        results.NewThroughputTracker(ThroughputWindow),
+		plugins:                  plugins,
+		metricsClient:            metricsClient,
+		controller:               controller,
+		uploader:                 uploader,
+		blockArchive:             blockArchive,
+		run:                      run,
+		dataPath:                 dataPath,
+	}, nil
+}
+
+// StartSyncing syncs from startIndex to endIndex.
+// If startIndex is -1, it will start from the last
+// saved block. If endIndex is -1, it will sync
+// continuously (or until an error).
+//
+// If asserter refresh is configured, syncing is
+// resumed from the last saved block after each
+// refresh. Likewise, if node restart patience is
+// configured, syncing is resumed from the last saved
+// block once an unavailable node returns.
+func (t *DataTester) StartSyncing(
+	ctx context.Context,
+) error {
+	startIndex := int64(-1)
+	if t.config.Data.StartIndex != nil {
+		startIndex = *t.config.Data.StartIndex
+	}
+
+	endIndex := int64(-1)
+	if t.config.Data.EndConditions != nil && t.config.Data.EndConditions.Index != nil {
+		endIndex = *t.config.Data.EndConditions.Index
+	}
+
+	for {
+		err := t.sync(ctx, startIndex, endIndex)
+		switch {
+		case t.asserterRefresher != nil && t.asserterRefresher.ShouldRefresh(err):
+			if err := t.asserterRefresher.Refresh(ctx, err); err != nil {
+				return err
+			}
+		case t.asserterModeWorker != nil && t.asserterModeWorker.ShouldRefresh(err):
+			if err := t.asserterModeWorker.Refresh(ctx); err != nil {
+				return err
+			}
+		case t.nodeMonitor != nil && processor.NodeUnavailable(err):
+			if err := t.nodeMonitor.Wait(ctx, err); err != nil {
+				return err
+			}
+		case t.syncRestarter != nil && t.syncRestarter.Retryable(err):
+			if err := t.syncRestarter.Restart(ctx, err); err != nil {
+				return err
+			}
+		default:
+			return err
+		}
+
+		startIndex = -1
+	}
+}
+
+// syncRestartPolicy returns the *statefulsyncer.RestartPolicy
+// described by config. Errors returned when the node is
+// unavailable are always retryable.
+func syncRestartPolicy(config *configuration.SyncRestarts) *statefulsyncer.RestartPolicy {
+	return &statefulsyncer.RestartPolicy{
+		MaxRestarts:    config.MaxRestarts,
+		InitialBackoff: time.Duration(config.InitialBackoff) * time.Second,
+		MaxBackoff:     time.Duration(config.MaxBackoff) * time.Second,
+		Retryable: func(err error) bool {
+			if processor.NodeUnavailable(err) {
+				return true
+			}
+
+			for _, retryable := range config.RetryableErrors {
+				if strings.Contains(err.Error(), retryable) {
+					return true
+				}
+			}
+
+			return false
+		},
+	}
+}
+
+// sync syncs from startIndex to endIndex using
+// the configured sync mode.
+func (t *DataTester) sync(ctx context.Context, startIndex int64, endIndex int64) error {
+	if t.eventsSyncer != nil {
+		return t.eventsSyncer.Sync(ctx, startIndex, endIndex)
+	}
+
+	return t.syncer.Sync(ctx, startIndex, endIndex)
+}
+
+// StartPruning attempts to prune block storage
+// every 10 seconds.
+func (t *DataTester) StartPruning(
+	ctx context.Context,
+) error {
+	if t.config.Data.PruningDisabled {
+		return nil
+	}
+
+	return t.syncer.Prune(ctx, t)
+}
+
+// StartDiskSpaceWatchdog checks the free space on the volume
+// of the data directory every CheckInterval seconds (if
+// configured). While free space is low, blocks that are safe
+// to prune are pruned. When free space is exhausted, syncing
+// is halted after the block being processed is committed
+// (so restarting resumes at the next block) and an error
+// is returned before badger fails to write.
+func (t *DataTester) StartDiskSpaceWatchdog(ctx context.Context) error {
+	if t.diskSpaceWatchdog == nil {
+		return nil
+	}
+
+	tc := time.NewTicker(
+		time.Duration(t.config.Data.DiskSpaceWatchdog.CheckInterval) * time.Second,
+	)
+	defer tc.Stop()
+
+	low := false
+	for {
+		var err error
+		low, err = t.checkDiskSpace(ctx, low)
+		if err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-tc.C:
+		}
+	}
+}
+
+// checkDiskSpace checks the free space in the data directory
+// once. low is true if free space was below the low threshold
+// at the previous check (so that the alert is only raised once
+// each time free space drops) and the returned bool is true if it
+// is below the low threshold now. An error is only returned when
+// free space is exhausted (after halting the syncer).
+func (t *DataTester) checkDiskSpace(ctx context.Context, low bool) (bool, error) {
+	free, level, err := t.diskSpaceWatchdog.Check()
+	if err != nil {
+		color.Yellow("%s: disk space watchdog disabled", err.Error())
+		return false, nil
+	}
+
+	if t.metricsClient != nil {
+		t.metricsClient.Gauge("disk.free_bytes", float64(free))
+	}
+
+	switch level {
+	case diskspace.Exhausted:
+		t.diskSpaceErr = fmt.Errorf(
+			"%w: %s free in data directory",
+			results.ErrDiskSpaceExhausted,
+			diskspace.FormatMB(free),
+		)
+		color.Red("%s: halting check:data", t.diskSpaceErr.Error())
+		t.Halt()
+
+		return false, t.diskSpaceErr
+	case diskspace.Low:
+		if !low {
+			t.alertDiskSpace(ctx, free)
+		}
+
+		if _, err := t.reclaimDiskSpace(ctx); err != nil {
+			log.Printf("%s: unable to reclaim disk space\n", err.Error())
+		}
+
+		return true, nil
+	default:
+		if low {
+			color.Green("%s free in data directory", diskspace.FormatMB(free))
+		}
+
+		return false, nil
 	}
 }
 
-// StartSyncing syncs from startIndex to endIndex.
-// If startIndex is -1, it will start from the last
-// saved block. If endIndex is -1, it will sync
-// continuously (or until an error).
-func (t *DataTester) StartSyncing(
-	ctx context.Context,
-) error {
-	startIndex := int64(-1)
-	if t.config.Data.StartIndex != nil {
-		startIndex = *t.config.Data.StartIndex
+// reclaimDiskSpace prunes all blocks that are safe to prune
+// and then compacts the database. Pruning only deletes keys,
+// so their space is not returned to the volume until the LSM
+// tree is compacted and the value log is garbage collected.
+func (t *DataTester) reclaimDiskSpace(ctx context.Context) (*compact.Results, error) {
+	if err := t.syncer.PruneOnce(ctx, t); err != nil {
+		return nil, fmt.Errorf("%w: unable to prune blocks", err)
 	}
 
-	endIndex := int64(-1)
-	if t.config.Data.EndConditions != nil && t.config.Data.EndConditions.Index != nil {
-		endIndex = *t.config.Data.EndConditions.Index
+	compactResults, err := compact.Online(ctx, t.database, compact.DefaultDiscardRatio)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to compact database", err)
 	}
 
-	return t.syncer.Sync(ctx, startIndex, endIndex)
+	return compactResults, nil
 }
 
-// StartPruning attempts to prune block storage
-// every 10 seconds.
-func (t *DataTester) StartPruning(
-	ctx context.Context,
-) error {
-	if t.config.Data.PruningDisabled {
+// StartAccountFileReloader checks the exempt and interesting
+// account files for changes every AccountFileReloadInterval
+// seconds (if configured) and applies changed files without
+// restarting the run.
+func (t *DataTester) StartAccountFileReloader(ctx context.Context) error {
+	if t.accountFiles == nil {
 		return nil
 	}
 
-	return t.syncer.Prune(ctx, t)
+	tc := time.NewTicker(
+		time.Duration(t.config.Data.AccountFileReloadInterval) * time.Second,
+	)
+	defer tc.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-tc.C:
+			t.accountFiles.Reload()
+		}
+	}
+}
+
+// alertDiskSpace logs and records a failures.DiskSpaceFailure
+// when free space drops below the low threshold.
+func (t *DataTester) alertDiskSpace(ctx context.Context, free uint64) {
+	message := fmt.Sprintf(
+		"%s free in data directory is below the low threshold of %d MB",
+		diskspace.FormatMB(free),
+		t.config.Data.DiskSpaceWatchdog.LowThreshold,
+	)
+	color.Red("%s: pruning blocks", message)
+
+	err := t.failureStorage.Record(ctx, &failures.Failure{
+		Kind:    failures.DiskSpaceFailure,
+		Actual:  strconv.FormatUint(free, 10),
+		Message: message,
+	})
+	if err != nil {
+		color.Red("%s: unable to record failures", err.Error())
+	}
 }
 
 // PruneableIndex is the index that is
@@ -385,6 +1393,49 @@ func (t *DataTester) PruneableIndex(
 	return headIndex - int64(t.config.MaxReorgDepth), nil
 }
 
+// ArchiveBlocks appends all blocks in storage with an index
+// less than or equal to pruneableIndex that have not yet been
+// archived to the block archive (if configured). It is called
+// by the syncer before blocks are pruned.
+func (t *DataTester) ArchiveBlocks(
+	ctx context.Context,
+	pruneableIndex int64,
+) error {
+	if t.blockArchive == nil {
+		return nil
+	}
+
+	startIndex, err := t.blockStorage.GetOldestBlockIndex(ctx)
+	if err != nil {
+		return fmt.Errorf("%w: unable to get oldest block index", err)
+	}
+
+	if lastIndex := t.blockArchive.LastIndex(); lastIndex >= startIndex {
+		startIndex = lastIndex + 1
+	}
+
+	for index := startIndex; index <= pruneableIndex; index++ {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		i := index
+		block, err := t.blockStorage.GetBlock(ctx, &types.PartialBlockIdentifier{Index: &i})
+		if errors.Is(err, storage.ErrBlockNotFound) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("%w: unable to get block %d", err, index)
+		}
+
+		if err := t.blockArchive.Append(block); err != nil {
+			return fmt.Errorf("%w: unable to archive block %d", err, index)
+		}
+	}
+
+	return t.blockArchive.Sync()
+}
+
 // StartReconciler starts the reconciler if
 // reconciliation is enabled.
 func (t *DataTester) StartReconciler(
@@ -426,12 +1477,101 @@ func (t *DataTester) StartPeriodicLogger(
 				t.fetcher,
 				t.config.Network,
 				t.reconciler,
+				t.reconcilerHandler,
 			)
+			t.throughputTracker.Record(time.Now(), status.Stats)
+			t.addThroughput(status)
+			t.addReconcilerStatus(status)
 			t.logger.LogDataStatus(ctx, status)
+			if t.metricsClient != nil {
+				t.metricsClient.ReportStatus(status)
+			}
+		}
+	}
+}
+
+// StartCounterSampler persists a sample of the check:data
+// counters every CounterSampleInterval seconds (if
+// configured). Samples that cannot be persisted are
+// skipped because they are not needed to complete
+// check:data.
+func (t *DataTester) StartCounterSampler(ctx context.Context) error {
+	if t.config.Data.CounterSampleInterval == 0 {
+		return nil
+	}
+
+	tc := time.NewTicker(time.Duration(t.config.Data.CounterSampleInterval) * time.Second)
+	defer tc.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-tc.C:
+			sample, err := timeseries.Take(ctx, t.counterStorage)
+			if err == nil {
+				err = t.sampleStorage.Record(ctx, sample)
+			}
+
+			if err != nil {
+				log.Printf("%s: unable to sample counters\n", err.Error())
+			}
 		}
 	}
 }
 
+// addThroughput populates the Throughput of status
+// using the samples recorded by StartPeriodicLogger.
+// If the tip is unknown (i.e. check:data is synced),
+// the head block is used as the tip.
+func (t *DataTester) addThroughput(status *results.CheckDataStatus) {
+	if status.HeadBlock == nil {
+		return
+	}
+
+	tip := status.HeadBlock.Index
+	if status.Progress != nil {
+		tip = status.Progress.Tip
+	}
+
+	status.Throughput = t.throughputTracker.Throughput(status.HeadBlock.Index, tip)
+}
+
+// reconcilerBacklogSize returns the size of the
+// active reconciliation backlog.
+func reconcilerBacklogSize(config *configuration.Configuration) int {
+	if config.Data.ReconcilerActiveBacklog != nil {
+		return *config.Data.ReconcilerActiveBacklog
+	}
+
+	return configuration.DefaultReconcilerActiveBacklog
+}
+
+// addReconcilerStatus populates the Reconciler of status
+// (if reconciliation is enabled).
+func (t *DataTester) addReconcilerStatus(status *results.CheckDataStatus) {
+	if !shouldReconcile(t.config) {
+		return
+	}
+
+	backlogMode := t.config.Data.ReconcilerBacklogMode
+	if len(backlogMode) == 0 {
+		backlogMode = configuration.DropBacklogMode
+	}
+
+	status.Reconciler = &results.ReconcilerStatus{
+		ActiveConcurrency:   t.reconciler.ActiveConcurrency,
+		InactiveConcurrency: t.reconciler.InactiveConcurrency,
+		QueueSize:           t.reconciler.QueueSize(),
+		BacklogSize:         reconcilerBacklogSize(t.config),
+		BacklogMode:         string(backlogMode),
+	}
+
+	if t.balanceStorageHandler != nil {
+		status.Reconciler.BlockedSeconds = t.balanceStorageHandler.BlockedDuration().Seconds()
+	}
+}
+
 // ServeHTTP serves a CheckDataStatus response on all paths.
 func (t *DataTester) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
@@ -445,13 +1585,73 @@ func (t *DataTester) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		t.fetcher,
 		t.network,
 		t.reconciler,
+		t.reconcilerHandler,
 	)
+	t.addThroughput(status)
+	t.addReconcilerStatus(status)
 
 	if err := json.NewEncoder(w).Encode(status); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
 }
 
+// Ready returns an error if check:data has not
+// synced any blocks.
+func (t *DataTester) Ready(ctx context.Context) error {
+	if _, err := t.blockStorage.GetHeadBlockIdentifier(ctx); err != nil {
+		return fmt.Errorf("%w: no blocks synced", err)
+	}
+
+	return nil
+}
+
+// queueReconciliations queues all tracked accounts for
+// reconciliation at the head block and returns the
+// number of accounts queued.
+func (t *DataTester) queueReconciliations(ctx context.Context) (int, error) {
+	head, err := t.blockStorage.GetHeadBlockIdentifier(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("%w: unable to get head block", err)
+	}
+
+	accounts, err := t.balanceStorage.GetAllAccountCurrency(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("%w: unable to get tracked accounts", err)
+	}
+
+	changes := make([]*parser.BalanceChange, len(accounts))
+	for i, account := range accounts {
+		changes[i] = &parser.BalanceChange{
+			Account:    account.Account,
+			Currency:   account.Currency,
+			Block:      head,
+			Difference: "0",
+		}
+	}
+
+	if err := t.reconciler.QueueChanges(ctx, head, changes); err != nil {
+		return 0, fmt.Errorf("%w: unable to queue reconciliations", err)
+	}
+
+	color.Yellow("queued %d accounts for reconciliation at block %d", len(changes), head.Index)
+	return len(changes), nil
+}
+
+// Controls returns the runtime controls of check:data
+// (or nil if runtime controls are not enabled).
+func (t *DataTester) Controls() http.Handler {
+	if t.controller == nil {
+		return nil
+	}
+
+	var reconcile control.ReconcileFunc
+	if shouldReconcile(t.config) {
+		reconcile = t.queueReconciliations
+	}
+
+	return control.Handler(t.controller, reconcile)
+}
+
 // EndAtTipLoop runs a loop that evaluates end condition EndAtTip
 func (t *DataTester) EndAtTipLoop(
 	ctx context.Context,
@@ -628,6 +1828,120 @@ func (t *DataTester) EndDurationLoop(
 	}
 }
 
+// EndConditionState is the document end condition
+// expressions are evaluated against.
+type EndConditionState struct {
+	// Counters are the values of the counters
+	// sampled by timeseries.Take (like blocks).
+	Counters map[string]int64 `json:"counters"`
+
+	// SyncedIndex is the index of the last synced
+	// block (-1 if no blocks are synced).
+	SyncedIndex int64 `json:"synced_index"`
+
+	// TipIndex is the index of the current block
+	// returned by /network/status.
+	TipIndex    int64 `json:"tip_index"`
+	TipDistance int64 `json:"tip_distance"`
+
+	// AtTip is true if the last synced block is
+	// within tip_delay of the current time.
+	AtTip bool `json:"at_tip"`
+
+	ReconciliationQueueSize int `json:"reconciliation_queue_size"`
+
+	// ElapsedSeconds is the number of seconds
+	// since check:data was started.
+	ElapsedSeconds int64 `json:"elapsed_seconds"`
+}
+
+// endConditionState returns the current *EndConditionState.
+func (t *DataTester) endConditionState(
+	ctx context.Context,
+	start time.Time,
+) (*EndConditionState, error) {
+	sample, err := timeseries.Take(ctx, t.counterStorage)
+	if err != nil {
+		return nil, err
+	}
+
+	state := &EndConditionState{
+		Counters:                sample.Counters,
+		SyncedIndex:             -1,
+		ReconciliationQueueSize: t.reconciler.QueueSize(),
+		ElapsedSeconds:          int64(time.Since(start).Seconds()),
+	}
+
+	headBlock, err := t.blockStorage.GetBlock(ctx, nil)
+	switch {
+	case err == nil:
+		state.SyncedIndex = headBlock.BlockIdentifier.Index
+		state.AtTip = utils.AtTip(t.config.TipDelay, headBlock.Timestamp)
+	case !errors.Is(err, storage.ErrHeadBlockNotFound):
+		return nil, fmt.Errorf("%w: unable to get head block", err)
+	}
+
+	status, fetchErr := t.fetcher.NetworkStatusRetry(ctx, t.network, nil)
+	if fetchErr != nil {
+		return nil, fmt.Errorf("%w: unable to get network status", fetchErr.Err)
+	}
+
+	state.TipIndex = status.CurrentBlockIdentifier.Index
+	state.TipDistance = state.TipIndex - state.SyncedIndex
+
+	return state, nil
+}
+
+// EndExpressionLoop runs a loop that evaluates end
+// condition Expression.
+func (t *DataTester) EndExpressionLoop(
+	ctx context.Context,
+	expression *invariant.Expression,
+) {
+	start := time.Now()
+	tc := time.NewTicker(EndAtTipCheckInterval)
+	defer tc.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-tc.C:
+			state, err := t.endConditionState(ctx, start)
+			if err != nil {
+				log.Printf(
+					"%s: unable to evaluate end condition expression",
+					err.Error(),
+				)
+				continue
+			}
+
+			encoded, err := json.Marshal(state)
+			if err != nil {
+				log.Printf("%s: unable to encode end condition state", err.Error())
+				continue
+			}
+
+			met, err := expression.Evaluate(gjson.ParseBytes(encoded))
+			if err != nil {
+				log.Printf("%s: unable to evaluate end condition expression", err.Error())
+				continue
+			}
+
+			if met {
+				t.endCondition = configuration.ExpressionEndCondition
+				t.endConditionDetail = fmt.Sprintf(
+					"Expression: %s",
+					expression.String(),
+				)
+				t.cancel()
+				return
+			}
+		}
+	}
+}
+
 // WatchEndConditions starts go routines to watch the end conditions
 func (t *DataTester) WatchEndConditions(
 	ctx context.Context,
@@ -651,6 +1965,16 @@ func (t *DataTester) WatchEndConditions(
 		go t.EndReconciliationCoverage(ctx, endConds.ReconciliationCoverage)
 	}
 
+	if len(endConds.Expression) > 0 {
+		expression, err := invariant.Compile(endConds.Expression)
+		if err != nil {
+			return fmt.Errorf("%w: unable to compile end condition expression", err)
+		}
+
+		// runs a go routine that ends once the expression is true
+		go t.EndExpressionLoop(ctx, expression)
+	}
+
 	return nil
 }
 
@@ -770,6 +2094,88 @@ func (t *DataTester) DrainReconcilerQueue(
 	return err
 }
 
+// degradedWorkers returns the status of all optional
+// workers that have returned errors.
+func (t *DataTester) degradedWorkers() []*results.DegradedWorker {
+	degraded := []*results.DegradedWorker{}
+	for _, worker := range t.optionalWorkers {
+		if status := worker.Status(); status != nil {
+			degraded = append(degraded, status)
+		}
+	}
+
+	return degraded
+}
+
+// operationStatistics returns the statistics of each
+// operation type (if operation stats are enabled).
+func (t *DataTester) operationStatistics(ctx context.Context) []*opstats.OperationStats {
+	if t.operationStats == nil {
+		return nil
+	}
+
+	all, err := t.operationStats.GetAll(ctx)
+	if err != nil {
+		color.Red("%s: unable to get operation stats", err.Error())
+		return nil
+	}
+
+	return all
+}
+
+// exitData exits check:data with err (or endCondition) and
+// the results only known by the DataTester.
+func (t *DataTester) exitData(
+	ctx context.Context,
+	err error,
+	endCondition configuration.CheckDataEndCondition,
+	endConditionDetail string,
+) error {
+	return results.ExitData(
+		t.config,
+		t.counterStorage,
+		t.balanceStorage,
+		err,
+		endCondition,
+		endConditionDetail,
+		&results.CheckDataExtras{
+			DegradedWorkers:        t.degradedWorkers(),
+			ReconciliationFailures: t.reconcilerHandler.BudgetFailures(),
+			OperationStats:         t.operationStatistics(ctx),
+			Run:                    t.run,
+		},
+	)
+}
+
+// recordFailures persists any failures deferred by block
+// workers and, if check:data exited with an error, a
+// failures.CheckFailure describing it.
+func (t *DataTester) recordFailures(ctx context.Context, err error) {
+	if err == nil || errors.Is(err, context.Canceled) {
+		if flushErr := t.failureStorage.Flush(ctx); flushErr != nil {
+			color.Red("%s: unable to record failures", flushErr.Error())
+		}
+
+		return
+	}
+
+	recordErr := t.failureStorage.Record(ctx, &failures.Failure{
+		Kind:    failures.CheckFailure,
+		Message: err.Error(),
+	})
+	if recordErr != nil {
+		color.Red("%s: unable to record failures", recordErr.Error())
+	}
+}
+
+// Halt stops syncing after the block currently being
+// processed is committed. It is called when a signal is
+// received (before any contexts are canceled) so that
+// restarting the check resumes at the next block.
+func (t *DataTester) Halt() {
+	haltSyncer(t.syncer)
+}
+
 // HandleErr is called when `check:data` returns an error.
 // If historical balance lookups are enabled, HandleErr will attempt to
 // automatically find any missing balance-changing operations.
@@ -778,17 +2184,20 @@ func (t *DataTester) HandleErr(err error, sigListeners *[]context.CancelFunc) er
 	// will no longer be usable when after termination.
 	ctx := context.Background()
 
+	// The syncer returns statefulsyncer.ErrHalted when
+	// halted by the disk space watchdog, which may be
+	// returned before the watchdog error.
+	if t.diskSpaceErr != nil {
+		err = t.diskSpaceErr
+	}
+
 	if *t.signalReceived {
-		return results.ExitData(
-			t.config,
-			t.counterStorage,
-			t.balanceStorage,
-			errors.New("check halted"),
-			"",
-			"",
-		)
+		t.recordFailures(ctx, nil)
+		return t.exitData(ctx, results.ErrCheckHalted, "", "")
 	}
 
+	t.recordFailures(ctx, err)
+
 	if (err == nil || errors.Is(err, context.Canceled)) &&
 		len(t.endCondition) == 0 && t.config.Data.EndConditions != nil &&
 		t.config.Data.EndConditions.Index != nil { // occurs at syncer end
@@ -812,64 +2221,34 @@ func (t *DataTester) HandleErr(err error, sigListeners *[]context.CancelFunc) er
 			} else {
 				drainErr := t.DrainReconcilerQueue(ctx, sigListeners)
 				if drainErr != nil {
-					return results.ExitData(
-						t.config,
-						t.counterStorage,
-						t.balanceStorage,
-						drainErr,
-						"",
-						"",
-					)
+					return t.exitData(ctx, drainErr, "", "")
 				}
 			}
 		}
 
-		return results.ExitData(
-			t.config,
-			t.counterStorage,
-			t.balanceStorage,
-			nil,
-			t.endCondition,
-			t.endConditionDetail,
-		)
+		return t.exitData(ctx, nil, t.endCondition, t.endConditionDetail)
 	}
 
 	fmt.Printf("\n")
 	if t.reconcilerHandler.InactiveFailure == nil {
-		return results.ExitData(
-			t.config,
-			t.counterStorage,
-			t.balanceStorage,
-			err,
-			"",
-			"",
-		)
+		return t.exitData(ctx, err, "", "")
+	}
+
+	if t.haltAborted {
+		color.Yellow("Skipping search for inactive reconciliation discrepency (aborted)")
+		return t.exitData(ctx, err, "", "")
 	}
 
 	if !t.historicalBalanceEnabled {
 		color.Yellow(
 			"Can't find the block missing operations automatically, please enable historical balance lookup",
 		)
-		return results.ExitData(
-			t.config,
-			t.counterStorage,
-			t.balanceStorage,
-			err,
-			"",
-			"",
-		)
+		return t.exitData(ctx, err, "", "")
 	}
 
 	if t.config.Data.InactiveDiscrepencySearchDisabled {
 		color.Yellow("Search for inactive reconciliation discrepency is disabled")
-		return results.ExitData(
-			t.config,
-			t.counterStorage,
-			t.balanceStorage,
-			err,
-			"",
-			"",
-		)
+		return t.exitData(ctx, err, "", "")
 	}
 
 	return t.FindMissingOps(ctx, err, sigListeners)
@@ -893,14 +2272,7 @@ func (t *DataTester) FindMissingOps(
 	)
 	if err != nil {
 		color.Yellow("%s: could not find block with missing ops", err.Error())
-		return results.ExitData(
-			t.config,
-			t.counterStorage,
-			t.balanceStorage,
-			originalErr,
-			"",
-			"",
-		)
+		return t.exitData(ctx, originalErr, "", "")
 	}
 
 	color.Yellow(
@@ -910,14 +2282,7 @@ func (t *DataTester) FindMissingOps(
 		badBlock.Hash,
 	)
 
-	return results.ExitData(
-		t.config,
-		t.counterStorage,
-		t.balanceStorage,
-		originalErr,
-		"",
-		"",
-	)
+	return t.exitData(ctx, originalErr, "", "")
 }
 
 func (t *DataTester) recursiveOpSearch(
@@ -953,6 +2318,7 @@ func (t *DataTester) recursiveOpSearch(
 		false,
 		false,
 		false,
+		false,
 	)
 
 	reconcilerHelper := processor.NewReconcilerHelper(
@@ -962,13 +2328,22 @@ func (t *DataTester) recursiveOpSearch(
 		localStore,
 		blockStorage,
 		balanceStorage,
+		nil,
+		nil,
+		nil,
+		nil,
 	)
 
 	reconcilerHandler := processor.NewReconcilerHandler(
 		logger,
 		counterStorage,
 		balanceStorage,
+		nil,
+		nil,
+		nil,
+		nil,
 		true, // halt on reconciliation error
+		0,
 	)
 
 	r := reconciler.New(
@@ -993,6 +2368,7 @@ func (t *DataTester) recursiveOpSearch(
 		t.fetcher,
 		t.historicalBalanceEnabled,
 		nil,
+		nil,
 		false,
 		t.parser.BalanceExemptions,
 		false, // we will need to perform an initial balance fetch when finding issues
@@ -1011,12 +2387,14 @@ func (t *DataTester) recursiveOpSearch(
 		ctx,
 		t.network,
 		t.fetcher,
+		nil,
+		nil,
 		blockStorage,
 		counterStorage,
 		logger,
 		cancel,
 		[]storage.BlockWorker{balanceStorage},
-		syncer.DefaultCacheSize,
+		t.config.SyncCacheSize,
 		t.config.MaxSyncConcurrency,
 		t.config.MaxReorgDepth,
 	)
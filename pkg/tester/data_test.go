@@ -0,0 +1,267 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tester
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/coinbase/rosetta-cli/configuration"
+	"github.com/coinbase/rosetta-cli/pkg/diskspace"
+	"github.com/coinbase/rosetta-cli/pkg/failures"
+	"github.com/coinbase/rosetta-cli/pkg/results"
+	"github.com/coinbase/rosetta-cli/pkg/statefulsyncer"
+
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/coinbase/rosetta-sdk-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	bytesPerMB = 1024 * 1024
+
+	// blocksAdded is the number of blocks added
+	// before checking disk space.
+	blocksAdded = 20
+
+	// maxReorgDepth is the configured max_reorg_depth
+	// (so blocks up to blocksAdded-1-maxReorgDepth
+	// can be pruned).
+	maxReorgDepth = 5
+)
+
+// emptyLogger is a statefulsyncer.Logger
+// that does nothing.
+type emptyLogger struct{}
+
+func (l *emptyLogger) AddBlockStream(context.Context, *types.Block) error {
+	return nil
+}
+
+func (l *emptyLogger) RemoveBlockStream(context.Context, *types.BlockIdentifier) error {
+	return nil
+}
+
+func diskSpaceBlock(index int64) *types.Block {
+	parentIndex := index - 1
+	if parentIndex < 0 {
+		parentIndex = 0
+	}
+
+	return &types.Block{
+		BlockIdentifier: &types.BlockIdentifier{
+			Hash:  fmt.Sprintf("block %d", index),
+			Index: index,
+		},
+		ParentBlockIdentifier: &types.BlockIdentifier{
+			Hash:  fmt.Sprintf("block %d", parentIndex),
+			Index: parentIndex,
+		},
+	}
+}
+
+// addBlocks adds blocksAdded blocks to database and returns
+// its *storage.BlockStorage and a *statefulsyncer.StatefulSyncer
+// that prunes it.
+func addBlocks(
+	ctx context.Context,
+	t *testing.T,
+	database storage.Database,
+) (*storage.BlockStorage, *statefulsyncer.StatefulSyncer) {
+	blockStorage := storage.NewBlockStorage(database)
+	syncer := statefulsyncer.New(
+		ctx,
+		&types.NetworkIdentifier{Blockchain: "blah", Network: "testnet"},
+		nil,
+		nil,
+		nil,
+		blockStorage,
+		storage.NewCounterStorage(database),
+		&emptyLogger{},
+		func() {},
+		nil,
+		10,
+		1,
+		1,
+	)
+
+	for i := int64(0); i < blocksAdded; i++ {
+		assert.NoError(t, blockStorage.AddBlock(ctx, diskSpaceBlock(i)))
+	}
+
+	return blockStorage, syncer
+}
+
+func TestCheckDiskSpace(t *testing.T) {
+	var tests = map[string]struct {
+		// thresholds returns the low and halt threshold
+		// (in MB) given the free space (in MB).
+		thresholds func(free uint64) (uint64, uint64)
+		low        bool
+
+		expectedLow      bool
+		expectedErr      error
+		expectedPruned   bool
+		expectedFailures int
+		expectedHalted   bool
+	}{
+		"ok": {
+			thresholds: func(free uint64) (uint64, uint64) { return 0, 0 },
+		},
+		"recovered": {
+			thresholds: func(free uint64) (uint64, uint64) { return 0, 0 },
+			low:        true,
+		},
+		"low": {
+			thresholds: func(free uint64) (uint64, uint64) {
+				return free + 1024, 0
+			},
+			expectedLow:      true,
+			expectedPruned:   true,
+			expectedFailures: 1,
+		},
+		"still low": {
+			thresholds: func(free uint64) (uint64, uint64) {
+				return free + 1024, 0
+			},
+			low:            true,
+			expectedLow:    true,
+			expectedPruned: true,
+		},
+		"exhausted": {
+			thresholds: func(free uint64) (uint64, uint64) {
+				return free + 2048, free + 1024
+			},
+			expectedErr:    results.ErrDiskSpaceExhausted,
+			expectedHalted: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			dir, err := utils.CreateTempDir()
+			assert.NoError(t, err)
+			defer utils.RemoveTempDir(dir)
+
+			database, err := storage.NewBadgerStorage(ctx, dir)
+			assert.NoError(t, err)
+			defer database.Close(ctx)
+
+			blockStorage, syncer := addBlocks(ctx, t, database)
+			failureStorage := failures.NewStorage(database)
+
+			free, err := diskspace.Free(dir)
+			assert.NoError(t, err)
+			low, halt := test.thresholds(free / bytesPerMB)
+			config := &configuration.Configuration{
+				MaxReorgDepth: maxReorgDepth,
+				Data: &configuration.DataConfiguration{
+					DiskSpaceWatchdog: &configuration.DiskSpaceWatchdog{
+						LowThreshold:  low,
+						HaltThreshold: halt,
+					},
+				},
+			}
+
+			tester := &DataTester{
+				config:            config,
+				database:          database,
+				blockStorage:      blockStorage,
+				failureStorage:    failureStorage,
+				syncer:            syncer,
+				diskSpaceWatchdog: diskspace.NewWatchdog(dir, config.Data.DiskSpaceWatchdog),
+			}
+
+			isLow, err := tester.checkDiskSpace(ctx, test.low)
+			assert.Equal(t, test.expectedLow, isLow)
+			if test.expectedErr != nil {
+				assert.True(t, errors.Is(err, test.expectedErr))
+				assert.True(t, errors.Is(tester.diskSpaceErr, test.expectedErr))
+			} else {
+				assert.NoError(t, err)
+				assert.Nil(t, tester.diskSpaceErr)
+			}
+
+			// Only blocks more than max_reorg_depth
+			// behind the head block are pruned.
+			pruneableIndex := int64(blocksAdded - 1 - maxReorgDepth)
+			for i := int64(0); i < blocksAdded; i++ {
+				index := i
+				_, err := blockStorage.GetBlock(
+					ctx,
+					&types.PartialBlockIdentifier{Index: &index},
+				)
+				if test.expectedPruned && i <= pruneableIndex {
+					assert.True(t, errors.Is(err, storage.ErrCannotAccessPrunedData))
+				} else {
+					assert.NoError(t, err)
+				}
+			}
+
+			stored, err := failureStorage.GetAll(ctx)
+			assert.NoError(t, err)
+			assert.Len(t, stored, test.expectedFailures)
+			for _, failure := range stored {
+				assert.Equal(t, failures.DiskSpaceFailure, failure.Kind)
+			}
+
+			// A halted syncer does not add blocks.
+			err = syncer.BlockAdded(ctx, diskSpaceBlock(blocksAdded))
+			assert.Equal(t, test.expectedHalted, errors.Is(err, statefulsyncer.ErrHalted))
+		})
+	}
+}
+
+func TestReclaimDiskSpace(t *testing.T) {
+	ctx := context.Background()
+
+	dir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(dir)
+
+	database, err := storage.NewBadgerStorage(ctx, dir)
+	assert.NoError(t, err)
+	defer database.Close(ctx)
+
+	blockStorage, syncer := addBlocks(ctx, t, database)
+
+	tester := &DataTester{
+		config:       &configuration.Configuration{MaxReorgDepth: maxReorgDepth},
+		database:     database,
+		blockStorage: blockStorage,
+		syncer:       syncer,
+	}
+
+	// The database is compacted after
+	// blocks are pruned.
+	compactResults, err := tester.reclaimDiskSpace(ctx)
+	assert.NoError(t, err)
+	assert.NotNil(t, compactResults)
+
+	oldestIndex, err := blockStorage.GetOldestBlockIndex(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(blocksAdded-maxReorgDepth), oldestIndex)
+
+	// Compaction fails if the database
+	// is not a badger database.
+	tester.database = nil
+	_, err = tester.reclaimDiskSpace(ctx)
+	assert.Error(t, err)
+}
@@ -16,20 +16,41 @@ package tester
 
 import (
 	"context"
-	"fmt"
+	"errors"
 	"log"
 	"net/http"
 	"time"
 
+	"github.com/coinbase/rosetta-cli/pkg/control"
+	"github.com/coinbase/rosetta-cli/pkg/dashboard"
 	"github.com/coinbase/rosetta-cli/pkg/logger"
+	"github.com/coinbase/rosetta-cli/pkg/statefulsyncer"
+
+	"github.com/fatih/color"
 )
 
 const (
 	// MemoryLoggingFrequency is the frequency that memory
 	// usage stats are logged to the terminal.
 	MemoryLoggingFrequency = 10 * time.Second
+
+	// HaltTimeout is the maximum time to wait for
+	// the block being processed to be committed
+	// when a check is halted.
+	HaltTimeout = 30 * time.Second
 )
 
+// haltSyncer halts syncer so that no blocks are added
+// or removed after the block currently being processed.
+func haltSyncer(syncer *statefulsyncer.StatefulSyncer) {
+	if !syncer.Halt(HaltTimeout) {
+		color.Red(
+			"block being processed was not committed after %s, it will be re-synced on restart",
+			HaltTimeout,
+		)
+	}
+}
+
 // LogMemoryLoop runs a loop that logs memory usage.
 func LogMemoryLoop(
 	ctx context.Context,
@@ -48,22 +69,79 @@ func LogMemoryLoop(
 	}
 }
 
-// StartServer stats a server at a port with a particular handler.
+// StatusTester is implemented by any tester
+// that serves a status endpoint.
+type StatusTester interface {
+	http.Handler
+
+	// Ready returns an error if the tester
+	// is not yet ready to serve traffic.
+	Ready(ctx context.Context) error
+}
+
+// ControlTester is implemented by any tester
+// that can be controlled while it is running.
+type ControlTester interface {
+	// Controls returns the handler that serves the
+	// runtime controls of the tester (or nil if
+	// runtime controls are not enabled).
+	Controls() http.Handler
+}
+
+// statusHandler serves /healthz, /readyz, /status, and
+// a read-only dashboard backed by /status. If the tester
+// has runtime controls enabled, they are served under
+// control.Path. All other paths serve the tester status for
+// compatibility with clients that query the server root.
+func statusHandler(tester StatusTester) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("ok"))
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		if err := tester.Ready(r.Context()); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("ok"))
+	})
+	mux.Handle("/status", tester)
+	mux.Handle(dashboard.Path, dashboard.Handler())
+	if controlTester, ok := tester.(ControlTester); ok {
+		if controls := controlTester.Controls(); controls != nil {
+			mux.Handle(control.Path, controls)
+			mux.Handle(control.Path+"/", controls)
+		}
+	}
+	mux.Handle("/", tester)
+
+	return mux
+}
+
+// StartServer stats a server at an address with a particular tester.
 // This is often used to support a status endpoint for a particular test.
+// The server runs until ctx is canceled. If the server fails (for
+// example, because addr is in use), the error is logged but the
+// test continues.
 func StartServer(
 	ctx context.Context,
 	name string,
-	handler http.Handler,
-	port uint,
+	tester StatusTester,
+	addr string,
 ) error {
 	server := &http.Server{
-		Addr:    fmt.Sprintf(":%d", port),
-		Handler: handler,
+		Addr:    addr,
+		Handler: statusHandler(tester),
 	}
 
 	go func() {
-		log.Printf("%s server running on port %d\n", name, port)
-		_ = server.ListenAndServe()
+		log.Printf("%s server running on %s\n", name, addr)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("%s: %s server failed\n", err.Error(), name)
+		}
 	}()
 
 	go func() {
@@ -0,0 +1,173 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tester
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/coinbase/rosetta-cli/pkg/control"
+	"github.com/coinbase/rosetta-cli/pkg/dashboard"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var _ StatusTester = (*statusTester)(nil)
+
+// statusTester serves "status" and is
+// ready once readyErr is nil.
+type statusTester struct {
+	readyErr error
+	controls http.Handler
+}
+
+func (s *statusTester) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	_, _ = w.Write([]byte("status"))
+}
+
+func (s *statusTester) Ready(ctx context.Context) error {
+	return s.readyErr
+}
+
+func (s *statusTester) Controls() http.Handler {
+	return s.controls
+}
+
+func TestStatusHandler(t *testing.T) {
+	controls := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("controls"))
+	})
+
+	var tests = map[string]struct {
+		tester *statusTester
+		path   string
+
+		expectedStatus int
+		expectedBody   string
+	}{
+		"healthz": {
+			tester:         &statusTester{readyErr: errors.New("syncing")},
+			path:           "/healthz",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "ok",
+		},
+		"readyz ready": {
+			tester:         &statusTester{},
+			path:           "/readyz",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "ok",
+		},
+		"readyz not ready": {
+			tester:         &statusTester{readyErr: errors.New("syncing")},
+			path:           "/readyz",
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedBody:   "syncing\n",
+		},
+		"status": {
+			tester:         &statusTester{},
+			path:           "/status",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "status",
+		},
+		"root": {
+			tester:         &statusTester{},
+			path:           "/",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "status",
+		},
+		"controls": {
+			tester:         &statusTester{controls: controls},
+			path:           control.Path + "/pause",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "controls",
+		},
+		"controls disabled": {
+			tester:         &statusTester{},
+			path:           control.Path,
+			expectedStatus: http.StatusOK,
+			expectedBody:   "status",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			server := httptest.NewServer(statusHandler(test.tester))
+			defer server.Close()
+
+			resp, err := http.Get(server.URL + test.path)
+			assert.NoError(t, err)
+			defer resp.Body.Close()
+
+			body, err := ioutil.ReadAll(resp.Body)
+			assert.NoError(t, err)
+			assert.Equal(t, test.expectedStatus, resp.StatusCode)
+			assert.Equal(t, test.expectedBody, string(body))
+		})
+	}
+
+	t.Run("dashboard", func(t *testing.T) {
+		server := httptest.NewServer(statusHandler(&statusTester{}))
+		defer server.Close()
+
+		resp, err := http.Get(server.URL + dashboard.Path)
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+
+		body, err := ioutil.ReadAll(resp.Body)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.NotEqual(t, "status", string(body))
+	})
+}
+
+func TestStartServer(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	addr := listener.Addr().String()
+	assert.NoError(t, listener.Close())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	assert.NoError(t, StartServer(ctx, "test", &statusTester{}, addr))
+
+	var resp *http.Response
+	for i := 0; i < 50; i++ {
+		resp, err = http.Get("http://" + addr + "/healthz")
+		if err == nil {
+			break
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.NoError(t, resp.Body.Close())
+
+	cancel()
+	for i := 0; i < 50; i++ {
+		_, err = http.Get("http://" + addr + "/healthz")
+		if err != nil {
+			break
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Error(t, err)
+}
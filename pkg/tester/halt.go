@@ -0,0 +1,151 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tester
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/coinbase/rosetta-cli/pkg/inspect"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/fatih/color"
+)
+
+const (
+	// haltPrompt is printed before reading each
+	// query while halted on an error.
+	haltPrompt = "halted> "
+
+	// continueQuery resumes the usual failure handling
+	// of a check halted on an error.
+	continueQuery = "continue"
+
+	// abortQuery exits a check halted on an
+	// error without any further failure handling.
+	abortQuery = "abort"
+)
+
+// HaltOnError pauses check:data after it failed with err so that
+// its storage can be inspected (using the queries of the inspect
+// command read from r) while the status server is still running.
+// It returns once the operator runs continue (or exit) or abort,
+// or once ctx is canceled. If the operator aborts, HandleErr exits
+// without searching for the block missing operations.
+//
+// HaltOnError returns immediately if the check did
+// not fail (or was halted by a signal).
+func (t *DataTester) HaltOnError(
+	ctx context.Context,
+	err error,
+	r io.Reader,
+	w io.Writer,
+) {
+	if t.diskSpaceErr != nil {
+		err = t.diskSpaceErr
+	}
+
+	if *t.signalReceived || err == nil || errors.Is(err, context.Canceled) ||
+		len(t.endCondition) != 0 {
+		return
+	}
+
+	t.printFailureContext(ctx, err, w)
+
+	// Reading from r cannot be interrupted, so queries
+	// are read in a separate goroutine.
+	queries := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			queries <- scanner.Text()
+		}
+
+		close(queries)
+	}()
+
+	explorer := inspect.New(t.database, t.dataPath)
+	for {
+		fmt.Fprint(w, haltPrompt)
+
+		var query string
+		var ok bool
+		select {
+		case <-ctx.Done():
+			return
+		case query, ok = <-queries:
+		}
+
+		if !ok {
+			// Without any input, the check stays halted
+			// until it is interrupted by a signal.
+			color.Yellow("\nno more queries can be read: send SIGINT to abort")
+			<-ctx.Done()
+			return
+		}
+
+		switch strings.TrimSpace(query) {
+		case continueQuery:
+			return
+		case abortQuery:
+			t.haltAborted = true
+			return
+		}
+
+		err := explorer.Execute(ctx, query, w)
+		if errors.Is(err, inspect.ErrExit) {
+			return
+		}
+
+		if err != nil {
+			fmt.Fprintf(w, "error: %s\n", err.Error())
+		}
+	}
+}
+
+// printFailureContext prints err, the last synced block,
+// and the account that failed inactive reconciliation (if any).
+func (t *DataTester) printFailureContext(ctx context.Context, err error, w io.Writer) {
+	color.Red("check:data halted on error: %s", err.Error())
+
+	head, headErr := t.blockStorage.GetHeadBlockIdentifier(ctx)
+	if headErr != nil {
+		fmt.Fprintf(w, "last synced block: unknown (%s)\n", headErr.Error())
+	} else {
+		fmt.Fprintf(w, "last synced block: %d:%s\n", head.Index, head.Hash)
+	}
+
+	if failure := t.reconcilerHandler.InactiveFailure; failure != nil {
+		fmt.Fprintf(
+			w,
+			"inactive reconciliation failure: %s %s at block %d:%s\n",
+			types.AccountString(failure.Account),
+			types.PrintStruct(failure.Currency),
+			t.reconcilerHandler.InactiveFailureBlock.Index,
+			t.reconcilerHandler.InactiveFailureBlock.Hash,
+		)
+	}
+
+	fmt.Fprintf(
+		w,
+		"Run help to see all queries, %s to resume failure handling, or %s to exit.\n",
+		continueQuery,
+		abortQuery,
+	)
+}
@@ -0,0 +1,290 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package timeseries persists periodic samples of the counters
+// tracked by check:data (like blocks synced and reconciliations
+// performed) and exports them in formats suitable for plotting.
+// A single value at the end of a run hides throughput regressions
+// that occur during long syncs.
+package timeseries
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/coinbase/rosetta-cli/pkg/results"
+
+	"github.com/coinbase/rosetta-sdk-go/storage"
+)
+
+const (
+	// sampleNamespace is prepended to any stored sample.
+	sampleNamespace = "counter_sample"
+
+	// CSVFormat exports one row per sample
+	// to a CSV file.
+	CSVFormat = "csv"
+
+	// JSONFormat exports all samples as
+	// a JSON array.
+	JSONFormat = "json"
+
+	// rateSuffix is appended to the name of a counter
+	// to form the name of its per-second rate.
+	rateSuffix = "_per_second"
+
+	// millisecondsPerSecond converts the difference
+	// between timestamps to seconds.
+	millisecondsPerSecond = 1000
+)
+
+var (
+	// ErrUnsupportedFormat is returned when an
+	// export format is not supported.
+	ErrUnsupportedFormat = errors.New("unsupported export format")
+
+	// Counters are the counters included in each Sample
+	// (in the order they are exported).
+	Counters = []string{
+		storage.BlockCounter,
+		storage.OrphanCounter,
+		storage.TransactionCounter,
+		storage.OperationCounter,
+		storage.ActiveReconciliationCounter,
+		storage.InactiveReconciliationCounter,
+		storage.ExemptReconciliationCounter,
+		storage.SkippedReconciliationsCounter,
+		storage.FailedReconciliationCounter,
+		results.DriftReconciliationCounter,
+	}
+)
+
+// Sample is the value of each counter in Counters
+// at a point in time.
+type Sample struct {
+	// Timestamp is the time the sample was
+	// taken (in milliseconds since the epoch).
+	Timestamp int64            `json:"timestamp"`
+	Counters  map[string]int64 `json:"counters"`
+}
+
+// Take returns a Sample of the counters in counterStorage.
+func Take(ctx context.Context, counterStorage *storage.CounterStorage) (*Sample, error) {
+	sample := &Sample{
+		Timestamp: time.Now().UnixNano() / int64(time.Millisecond),
+		Counters:  map[string]int64{},
+	}
+	for _, counter := range Counters {
+		value, err := counterStorage.Get(ctx, counter)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to get counter %s", err, counter)
+		}
+
+		sample.Counters[counter] = value.Int64()
+	}
+
+	return sample, nil
+}
+
+// Storage persists samples in a storage.Database.
+type Storage struct {
+	db storage.Database
+
+	mutex   sync.Mutex
+	lastKey int64
+}
+
+// NewStorage returns a new *Storage.
+func NewStorage(db storage.Database) *Storage {
+	return &Storage{db: db}
+}
+
+func getSampleKey(key int64) []byte {
+	return []byte(fmt.Sprintf("%s/%020d", sampleNamespace, key))
+}
+
+func getSamplePrefix() []byte {
+	return []byte(fmt.Sprintf("%s/", sampleNamespace))
+}
+
+// Record persists sample.
+func (s *Storage) Record(ctx context.Context, sample *Sample) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	encoded, err := s.db.Encoder().Encode(sampleNamespace, sample)
+	if err != nil {
+		return fmt.Errorf("%w: unable to encode sample", err)
+	}
+
+	// Keys are ordered by the time samples are taken
+	// (and are unique, even if they are taken in the
+	// same millisecond).
+	key := sample.Timestamp
+	if key <= s.lastKey {
+		key = s.lastKey + 1
+	}
+	s.lastKey = key
+
+	dbTx := s.db.NewDatabaseTransaction(ctx, true)
+	defer dbTx.Discard(ctx)
+
+	if err := dbTx.Set(ctx, getSampleKey(key), encoded, true); err != nil {
+		return fmt.Errorf("%w: unable to store sample", err)
+	}
+
+	if err := dbTx.Commit(ctx); err != nil {
+		return fmt.Errorf("%w: unable to commit sample", err)
+	}
+
+	return nil
+}
+
+// GetAll returns all persisted samples
+// in the order they were taken.
+func (s *Storage) GetAll(ctx context.Context) ([]*Sample, error) {
+	dbTx := s.db.NewDatabaseTransaction(ctx, false)
+	defer dbTx.Discard(ctx)
+
+	samples := []*Sample{}
+	_, err := dbTx.Scan(
+		ctx,
+		getSamplePrefix(),
+		getSamplePrefix(),
+		func(k []byte, v []byte) error {
+			var sample Sample
+			if err := s.db.Encoder().Decode(sampleNamespace, v, &sample, false); err != nil {
+				return fmt.Errorf("%w: unable to decode sample", err)
+			}
+
+			samples = append(samples, &sample)
+			return nil
+		},
+		false,
+		false,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to scan samples", err)
+	}
+
+	return samples, nil
+}
+
+// Point is an exported Sample. Rates are the per-second
+// change of each counter since the previous sample (and
+// are not populated for the first sample).
+type Point struct {
+	Timestamp int64              `json:"timestamp"`
+	Elapsed   float64            `json:"elapsed_seconds"`
+	Counters  map[string]int64   `json:"counters"`
+	Rates     map[string]float64 `json:"rates,omitempty"`
+}
+
+// Points converts samples into Points.
+func Points(samples []*Sample) []*Point {
+	points := make([]*Point, len(samples))
+	for i, sample := range samples {
+		point := &Point{
+			Timestamp: sample.Timestamp,
+			Counters:  sample.Counters,
+		}
+		points[i] = point
+
+		if i == 0 {
+			continue
+		}
+
+		first := samples[0]
+		point.Elapsed = float64(sample.Timestamp-first.Timestamp) / millisecondsPerSecond
+
+		previous := samples[i-1]
+		seconds := float64(sample.Timestamp-previous.Timestamp) / millisecondsPerSecond
+		if seconds <= 0 {
+			continue
+		}
+
+		point.Rates = map[string]float64{}
+		for _, counter := range Counters {
+			delta := sample.Counters[counter] - previous.Counters[counter]
+			point.Rates[counter+rateSuffix] = float64(delta) / seconds
+		}
+	}
+
+	return points
+}
+
+// Write writes points to w in format.
+func Write(w io.Writer, points []*Point, format string) error {
+	switch format {
+	case JSONFormat:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(points); err != nil {
+			return fmt.Errorf("%w: unable to encode samples", err)
+		}
+
+		return nil
+	case CSVFormat:
+		return writeCSV(w, points)
+	default:
+		return fmt.Errorf("%w: %s", ErrUnsupportedFormat, format)
+	}
+}
+
+// writeCSV writes points to w with one row per point. Each
+// counter has a column for its value and its rate.
+func writeCSV(w io.Writer, points []*Point) error {
+	writer := csv.NewWriter(w)
+	columns := []string{"timestamp", "elapsed_seconds"}
+	for _, counter := range Counters {
+		columns = append(columns, counter, counter+rateSuffix)
+	}
+
+	if err := writer.Write(columns); err != nil {
+		return fmt.Errorf("%w: unable to write header", err)
+	}
+
+	for _, point := range points {
+		row := []string{
+			strconv.FormatInt(point.Timestamp, 10),
+			strconv.FormatFloat(point.Elapsed, 'f', -1, 64),
+		}
+		for _, counter := range Counters {
+			rate := ""
+			if value, ok := point.Rates[counter+rateSuffix]; ok {
+				rate = strconv.FormatFloat(value, 'f', -1, 64)
+			}
+
+			row = append(row, strconv.FormatInt(point.Counters[counter], 10), rate)
+		}
+
+		if err := writer.Write(row); err != nil {
+			return fmt.Errorf("%w: unable to write row", err)
+		}
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("%w: unable to flush rows", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,142 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package timeseries
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/coinbase/rosetta-sdk-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStorage(t *testing.T) {
+	ctx := context.Background()
+	dir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(dir)
+
+	database, err := storage.NewBadgerStorage(
+		ctx,
+		dir,
+		storage.WithIndexCacheSize(storage.TinyIndexCacheSize),
+	)
+	assert.NoError(t, err)
+	defer database.Close(ctx)
+
+	counterStorage := storage.NewCounterStorage(database)
+	s := NewStorage(database)
+
+	samples, err := s.GetAll(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, samples, 0)
+
+	for _, blocks := range []int64{10, 5} {
+		_, err := counterStorage.Update(ctx, storage.BlockCounter, big.NewInt(blocks))
+		assert.NoError(t, err)
+
+		sample, err := Take(ctx, counterStorage)
+		assert.NoError(t, err)
+		assert.Len(t, sample.Counters, len(Counters))
+
+		// Samples taken in the same millisecond
+		// must not overwrite each other.
+		sample.Timestamp = 1000
+		assert.NoError(t, s.Record(ctx, sample))
+	}
+
+	samples, err = s.GetAll(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, samples, 2)
+	assert.Equal(t, int64(10), samples[0].Counters[storage.BlockCounter])
+	assert.Equal(t, int64(15), samples[1].Counters[storage.BlockCounter])
+}
+
+func TestPoints(t *testing.T) {
+	samples := []*Sample{
+		{Timestamp: 1000, Counters: map[string]int64{storage.BlockCounter: 10}},
+		{Timestamp: 3000, Counters: map[string]int64{storage.BlockCounter: 30}},
+		{Timestamp: 3000, Counters: map[string]int64{storage.BlockCounter: 30}},
+		{Timestamp: 7000, Counters: map[string]int64{storage.BlockCounter: 34}},
+	}
+
+	points := Points(samples)
+	assert.Len(t, points, len(samples))
+	assert.Nil(t, points[0].Rates)
+	assert.Nil(t, points[2].Rates)
+
+	assert.Equal(t, float64(2), points[1].Elapsed)
+	assert.Equal(t, float64(10), points[1].Rates["blocks_per_second"])
+	assert.Equal(t, float64(0), points[1].Rates["orphans_per_second"])
+
+	assert.Equal(t, float64(6), points[3].Elapsed)
+	assert.Equal(t, float64(1), points[3].Rates["blocks_per_second"])
+}
+
+func TestWrite(t *testing.T) {
+	points := Points([]*Sample{
+		{Timestamp: 1000, Counters: map[string]int64{storage.BlockCounter: 10}},
+		{Timestamp: 3000, Counters: map[string]int64{storage.BlockCounter: 15}},
+	})
+
+	var tests = map[string]struct {
+		format string
+
+		prefix string
+		err    error
+	}{
+		"csv": {
+			format: CSVFormat,
+			prefix: "timestamp,elapsed_seconds,blocks,blocks_per_second,orphans",
+		},
+		"json": {
+			format: JSONFormat,
+			prefix: "[\n  {\n    \"timestamp\": 1000",
+		},
+		"unsupported": {
+			format: "parquet",
+			err:    ErrUnsupportedFormat,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := Write(&buf, points, test.format)
+			if test.err != nil {
+				assert.True(t, errors.Is(err, test.err))
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.True(t, strings.HasPrefix(buf.String(), test.prefix))
+		})
+	}
+
+	t.Run("csv rows", func(t *testing.T) {
+		var buf bytes.Buffer
+		assert.NoError(t, Write(&buf, points, CSVFormat))
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		assert.Len(t, lines, 3)
+		assert.True(t, strings.HasPrefix(lines[1], "1000,0,10,,0,"))
+		assert.True(t, strings.HasPrefix(lines[2], "3000,2,15,2.5,0,0,"))
+	})
+}
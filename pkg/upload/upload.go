@@ -0,0 +1,314 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package upload copies the artifacts of a run (like the results
+// file and a snapshot of the data directory) to S3 or GCS so they
+// outlive an ephemeral CI runner. Objects are copied with the aws
+// (for s3://) or gsutil (for gs://) command-line tools, which
+// handle authentication (instance roles, service accounts, etc.).
+package upload
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/coinbase/rosetta-cli/configuration"
+)
+
+const (
+	// RunIDFormat is the format of the UTC start time that
+	// names the directory of each run (so runs sort by the
+	// time they started).
+	RunIDFormat = "20060102T150405Z"
+
+	s3Scheme = "s3://"
+	gsScheme = "gs://"
+
+	// s3PrefixMarker precedes each common prefix
+	// (directory) listed by aws s3 ls.
+	s3PrefixMarker = "PRE"
+)
+
+var (
+	// ErrUnsupportedDestination is returned when the
+	// destination is not an s3:// or gs:// URL.
+	ErrUnsupportedDestination = errors.New("unsupported upload destination")
+)
+
+// Artifact is a local file that is uploaded
+// to the directory of a run as Name.
+type Artifact struct {
+	Name string
+	Path string
+}
+
+// Uploader uploads the artifacts of a single
+// run to a configured destination.
+type Uploader struct {
+	config      *configuration.ArtifactUpload
+	destination string
+	runID       string
+
+	// run executes a command and returns its output. It
+	// is only overridden in tests.
+	run func(ctx context.Context, name string, args ...string) ([]byte, error)
+}
+
+// RunID returns the ID of a run started at t.
+func RunID(t time.Time) string {
+	return t.UTC().Format(RunIDFormat)
+}
+
+// New returns a new *Uploader for the run runID.
+func New(config *configuration.ArtifactUpload, runID string) (*Uploader, error) {
+	if !strings.HasPrefix(config.Destination, s3Scheme) &&
+		!strings.HasPrefix(config.Destination, gsScheme) {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedDestination, config.Destination)
+	}
+
+	return &Uploader{
+		config:      config,
+		destination: strings.TrimSuffix(config.Destination, "/"),
+		runID:       runID,
+		run:         runCommand,
+	}, nil
+}
+
+// runCommand executes a command and returns its combined output.
+func runCommand(ctx context.Context, name string, args ...string) ([]byte, error) {
+	output, err := exec.CommandContext(ctx, name, args...).CombinedOutput() // #nosec G204
+	if err != nil {
+		return nil, fmt.Errorf(
+			"%w: %s %s: %s",
+			err,
+			name,
+			strings.Join(args, " "),
+			strings.TrimSpace(string(output)),
+		)
+	}
+
+	return output, nil
+}
+
+// s3 returns a boolean indicating if
+// the destination is an S3 bucket.
+func (u *Uploader) s3() bool {
+	return strings.HasPrefix(u.destination, s3Scheme)
+}
+
+// command executes a command with
+// the configured timeout.
+func (u *Uploader) command(ctx context.Context, name string, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(u.config.Timeout)*time.Second)
+	defer cancel()
+
+	return u.run(ctx, name, args...)
+}
+
+// RunURL returns the URL of the directory
+// the artifacts of the run are uploaded to.
+func (u *Uploader) RunURL() string {
+	return u.destination + "/" + u.runID
+}
+
+// copy uploads the file at localPath to remoteURL.
+func (u *Uploader) copy(ctx context.Context, localPath string, remoteURL string) error {
+	var err error
+	if u.s3() {
+		_, err = u.command(ctx, "aws", "s3", "cp", "--only-show-errors", localPath, remoteURL)
+	} else {
+		_, err = u.command(ctx, "gsutil", "-q", "cp", localPath, remoteURL)
+	}
+
+	return err
+}
+
+// Upload copies each artifact to the directory of the run
+// (overwriting the artifacts of any previous upload of the
+// run). Artifacts whose files do not exist are skipped.
+func (u *Uploader) Upload(ctx context.Context, artifacts []*Artifact) error {
+	for _, artifact := range artifacts {
+		if _, err := os.Stat(artifact.Path); os.IsNotExist(err) {
+			continue
+		}
+
+		if err := u.copy(ctx, artifact.Path, u.RunURL()+"/"+artifact.Name); err != nil {
+			return fmt.Errorf("%w: unable to upload %s", err, artifact.Name)
+		}
+	}
+
+	return nil
+}
+
+// runs returns the IDs of all runs uploaded to the
+// destination (oldest first). Directories that are
+// not named by a run ID are ignored.
+func (u *Uploader) runs(ctx context.Context) ([]string, error) {
+	var output []byte
+	var err error
+	if u.s3() {
+		output, err = u.command(ctx, "aws", "s3", "ls", u.destination+"/")
+	} else {
+		output, err = u.command(ctx, "gsutil", "ls", u.destination+"/")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to list runs", err)
+	}
+
+	runs := []string{}
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		// aws s3 ls prints "PRE <run>/" for each directory
+		// and gsutil ls prints the URL of each directory.
+		entry := fields[len(fields)-1]
+		if u.s3() && fields[0] != s3PrefixMarker {
+			continue
+		}
+
+		run := path.Base(strings.TrimSuffix(entry, "/"))
+		if _, err := time.Parse(RunIDFormat, run); err != nil {
+			continue
+		}
+
+		runs = append(runs, run)
+	}
+
+	sort.Strings(runs)
+	return runs, nil
+}
+
+// Prune deletes the oldest runs uploaded to the destination
+// so that at most Retention runs are kept (if Retention is
+// populated). It returns the IDs of all deleted runs.
+func (u *Uploader) Prune(ctx context.Context) ([]string, error) {
+	if u.config.Retention == 0 {
+		return nil, nil
+	}
+
+	runs, err := u.runs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if uint64(len(runs)) <= u.config.Retention {
+		return nil, nil
+	}
+
+	expired := runs[:uint64(len(runs))-u.config.Retention]
+	for _, run := range expired {
+		runURL := u.destination + "/" + run + "/"
+		if u.s3() {
+			_, err = u.command(ctx, "aws", "s3", "rm", "--recursive", "--only-show-errors", runURL)
+		} else {
+			_, err = u.command(ctx, "gsutil", "-q", "-m", "rm", "-r", runURL)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to delete run %s", err, run)
+		}
+	}
+
+	return expired, nil
+}
+
+// Snapshot writes a gzip-compressed tar archive of all files in
+// dir to w. Files that are removed while the archive is written
+// (like compacted badger tables) are skipped, and files that grow
+// are truncated to their size when they were opened.
+func Snapshot(dir string, w io.Writer) error {
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+
+	walkErr := filepath.Walk(dir, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+
+			return err
+		}
+
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		return addFile(tw, dir, filePath)
+	})
+	if walkErr != nil {
+		return fmt.Errorf("%w: unable to archive %s", walkErr, dir)
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("%w: unable to close archive", err)
+	}
+
+	if err := gw.Close(); err != nil {
+		return fmt.Errorf("%w: unable to close compressor", err)
+	}
+
+	return nil
+}
+
+// addFile writes the file at filePath to tw
+// (named relative to dir).
+func addFile(tw *tar.Writer, dir string, filePath string) error {
+	f, err := os.Open(filePath) // #nosec G304
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%w: unable to open %s", err, filePath)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("%w: unable to stat %s", err, filePath)
+	}
+
+	name, err := filepath.Rel(dir, filePath)
+	if err != nil {
+		return fmt.Errorf("%w: unable to name %s", err, filePath)
+	}
+
+	header, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return fmt.Errorf("%w: unable to create header for %s", err, filePath)
+	}
+	header.Name = filepath.ToSlash(name)
+
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("%w: unable to write header for %s", err, filePath)
+	}
+
+	if _, err := io.CopyN(tw, f, info.Size()); err != nil {
+		return fmt.Errorf("%w: unable to archive %s", err, filePath)
+	}
+
+	return nil
+}
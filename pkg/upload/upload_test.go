@@ -0,0 +1,194 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package upload
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coinbase/rosetta-cli/configuration"
+
+	"github.com/coinbase/rosetta-sdk-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeRunner records all commands and responds
+// to list commands with listing.
+type fakeRunner struct {
+	listing  string
+	commands []string
+}
+
+func (f *fakeRunner) run(ctx context.Context, name string, args ...string) ([]byte, error) {
+	command := strings.Join(append([]string{name}, args...), " ")
+	f.commands = append(f.commands, command)
+	if strings.Contains(command, " ls ") {
+		return []byte(f.listing), nil
+	}
+
+	return nil, nil
+}
+
+func TestNew(t *testing.T) {
+	_, err := New(&configuration.ArtifactUpload{Destination: "https://bucket"}, "run")
+	assert.True(t, errors.Is(err, ErrUnsupportedDestination))
+
+	u, err := New(&configuration.ArtifactUpload{Destination: "s3://bucket/prefix/"}, "run")
+	assert.NoError(t, err)
+	assert.Equal(t, "s3://bucket/prefix/run", u.RunURL())
+	assert.Equal(t, "20201101T120000Z", RunID(time.Date(2020, 11, 1, 12, 0, 0, 0, time.UTC)))
+}
+
+func TestUploadAndPrune(t *testing.T) {
+	dir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(dir)
+
+	results := path.Join(dir, "results.json")
+	assert.NoError(t, ioutil.WriteFile(results, []byte("{}"), os.FileMode(0600)))
+	artifacts := []*Artifact{
+		{Name: "results.json", Path: results},
+		{Name: "failures.json", Path: path.Join(dir, "missing.json")},
+	}
+
+	var tests = map[string]struct {
+		destination string
+		retention   uint64
+		listing     string
+
+		expectedDeleted  []string
+		expectedCommands []string
+	}{
+		"s3 without retention": {
+			destination: "s3://bucket/prefix",
+			expectedCommands: []string{
+				"aws s3 cp --only-show-errors " + results +
+					" s3://bucket/prefix/20201103T000000Z/results.json",
+			},
+		},
+		"s3 with retention": {
+			destination: "s3://bucket/prefix",
+			retention:   2,
+			listing: strings.Join([]string{
+				"                           PRE 20201103T000000Z/",
+				"                           PRE 20201101T000000Z/",
+				"                           PRE other/",
+				"2020-11-01 00:00:00        100 20201102T000000Z",
+				"                           PRE 20201102T000000Z/",
+			}, "\n"),
+			expectedDeleted: []string{"20201101T000000Z"},
+			expectedCommands: []string{
+				"aws s3 cp --only-show-errors " + results +
+					" s3://bucket/prefix/20201103T000000Z/results.json",
+				"aws s3 ls s3://bucket/prefix/",
+				"aws s3 rm --recursive --only-show-errors s3://bucket/prefix/20201101T000000Z/",
+			},
+		},
+		"gs with retention": {
+			destination: "gs://bucket",
+			retention:   1,
+			listing: strings.Join([]string{
+				"gs://bucket/20201102T000000Z/",
+				"gs://bucket/20201101T000000Z/",
+				"gs://bucket/20201103T000000Z/",
+			}, "\n"),
+			expectedDeleted: []string{"20201101T000000Z", "20201102T000000Z"},
+			expectedCommands: []string{
+				"gsutil -q cp " + results + " gs://bucket/20201103T000000Z/results.json",
+				"gsutil ls gs://bucket/",
+				"gsutil -q -m rm -r gs://bucket/20201101T000000Z/",
+				"gsutil -q -m rm -r gs://bucket/20201102T000000Z/",
+			},
+		},
+		"gs within retention": {
+			destination: "gs://bucket",
+			retention:   5,
+			listing:     "gs://bucket/20201103T000000Z/\n",
+			expectedCommands: []string{
+				"gsutil -q cp " + results + " gs://bucket/20201103T000000Z/results.json",
+				"gsutil ls gs://bucket/",
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			u, err := New(&configuration.ArtifactUpload{
+				Destination: test.destination,
+				Retention:   test.retention,
+				Timeout:     configuration.DefaultArtifactUploadTimeout,
+			}, "20201103T000000Z")
+			assert.NoError(t, err)
+
+			runner := &fakeRunner{listing: test.listing}
+			u.run = runner.run
+
+			assert.NoError(t, u.Upload(context.Background(), artifacts))
+			deleted, err := u.Prune(context.Background())
+			assert.NoError(t, err)
+			assert.Equal(t, test.expectedDeleted, deleted)
+			assert.Equal(t, test.expectedCommands, runner.commands)
+		})
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	dir, err := utils.CreateTempDir()
+	assert.NoError(t, err)
+	defer utils.RemoveTempDir(dir)
+
+	files := map[string]string{
+		"000001.vlog":       "values",
+		"MANIFEST":          "manifest",
+		"dumps/failure.csv": "account,balance",
+	}
+	assert.NoError(t, os.MkdirAll(path.Join(dir, "dumps"), os.FileMode(0700)))
+	for name, contents := range files {
+		err := ioutil.WriteFile(path.Join(dir, name), []byte(contents), os.FileMode(0600))
+		assert.NoError(t, err)
+	}
+
+	var buf bytes.Buffer
+	assert.NoError(t, Snapshot(dir, &buf))
+
+	gr, err := gzip.NewReader(&buf)
+	assert.NoError(t, err)
+	tr := tar.NewReader(gr)
+
+	archived := map[string]string{}
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(t, err)
+
+		contents, err := ioutil.ReadAll(tr)
+		assert.NoError(t, err)
+		archived[header.Name] = string(contents)
+	}
+
+	assert.Equal(t, files, archived)
+}
@@ -0,0 +1,418 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package verify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"math/rand"
+	"os"
+	"strconv"
+
+	"github.com/coinbase/rosetta-sdk-go/parser"
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/olekukonko/tablewriter"
+)
+
+// logFrequency is the number of verified
+// blocks between progress logs.
+const logFrequency = 10000
+
+// Check is a type of integrity check.
+type Check string
+
+const (
+	// LinkageCheck confirms that every block between the
+	// oldest block and the head block is stored and that
+	// the parent of each block is the previous block.
+	LinkageCheck Check = "linkage"
+
+	// BalanceCheck confirms that the difference between
+	// the stored balances of an account before and after
+	// a block is the balance change in the block.
+	BalanceCheck Check = "balance"
+
+	// CounterCheck confirms that the block, transaction, and
+	// operation counters are consistent with stored blocks.
+	CounterCheck Check = "counter"
+)
+
+// Corruption is an inconsistency found in storage.
+type Corruption struct {
+	Check       Check  `json:"check"`
+	Index       int64  `json:"index"`
+	Description string `json:"description"`
+}
+
+// Results summarizes a verification.
+type Results struct {
+	StartIndex int64 `json:"start_index"`
+	EndIndex   int64 `json:"end_index"`
+	Blocks     int64 `json:"blocks"`
+
+	// SampledBlocks is the number of blocks where
+	// balance changes were re-derived.
+	SampledBlocks int64 `json:"sampled_blocks"`
+
+	// BalanceChanges is the number of balance
+	// changes compared with stored balances.
+	BalanceChanges int64 `json:"balance_changes"`
+
+	// SkippedBalanceChanges is the number of balance changes
+	// that could not be compared (for example, because the
+	// account is exempt, balances are not tracked, or
+	// historical balances were pruned).
+	SkippedBalanceChanges int64 `json:"skipped_balance_changes"`
+
+	Corruptions []*Corruption `json:"corruptions"`
+}
+
+// Verifier checks the integrity of data stored by check:data.
+// No data is written to storage.
+type Verifier struct {
+	database       storage.Database
+	blockStorage   *storage.BlockStorage
+	balanceStorage *storage.BalanceStorage
+	counterStorage *storage.CounterStorage
+	parser         *parser.Parser
+
+	// samples is the number of blocks where
+	// balance changes are re-derived.
+	samples int64
+}
+
+// New returns a new *Verifier for database. The parser is used to
+// re-derive the balance changes in samples randomly selected blocks.
+func New(
+	database storage.Database,
+	parser *parser.Parser,
+	samples int64,
+) *Verifier {
+	return &Verifier{
+		database:       database,
+		blockStorage:   storage.NewBlockStorage(database),
+		balanceStorage: storage.NewBalanceStorage(database),
+		counterStorage: storage.NewCounterStorage(database),
+		parser:         parser,
+		samples:        samples,
+	}
+}
+
+// sample returns the indexes in (startIndex, endIndex]
+// where balance changes are re-derived. The oldest block
+// is never sampled because balances before it are not
+// stored.
+func (v *Verifier) sample(startIndex int64, endIndex int64) map[int64]struct{} {
+	sampled := map[int64]struct{}{}
+	candidates := endIndex - startIndex
+	if v.samples >= candidates {
+		for index := startIndex + 1; index <= endIndex; index++ {
+			sampled[index] = struct{}{}
+		}
+
+		return sampled
+	}
+
+	for int64(len(sampled)) < v.samples {
+		sampled[startIndex+1+rand.Int63n(candidates)] = struct{}{} // #nosec G404
+	}
+
+	return sampled
+}
+
+// bounds returns the index of the oldest
+// and head blocks in storage.
+func (v *Verifier) bounds(ctx context.Context) (int64, int64, error) {
+	head, err := v.blockStorage.GetHeadBlockIdentifier(ctx)
+	if err != nil {
+		return -1, -1, fmt.Errorf("%w: unable to get head block", err)
+	}
+
+	oldestIndex, err := v.blockStorage.GetOldestBlockIndex(ctx)
+	switch {
+	case err == nil:
+	case errors.Is(err, storage.ErrOldestIndexMissing):
+		oldestIndex = 0
+	default:
+		return -1, -1, fmt.Errorf("%w: unable to get oldest block index", err)
+	}
+
+	return oldestIndex, head.Index, nil
+}
+
+// verifyBalances re-derives the balance changes in block and
+// compares them with the difference between the stored balances
+// of each account before and after block.
+func (v *Verifier) verifyBalances(
+	ctx context.Context,
+	block *types.Block,
+	results *Results,
+) error {
+	changes, err := v.parser.BalanceChanges(ctx, block, false)
+	if err != nil {
+		return fmt.Errorf(
+			"%w: unable to calculate balance changes in block %d",
+			err,
+			block.BlockIdentifier.Index,
+		)
+	}
+
+	dbTx := v.database.NewDatabaseTransaction(ctx, false)
+	defer dbTx.Discard(ctx)
+
+	index := block.BlockIdentifier.Index
+	for _, change := range changes {
+		if len(v.parser.FindExemptions(change.Account, change.Currency)) > 0 {
+			results.SkippedBalanceChanges++
+			continue
+		}
+
+		// Balances may be missing because the account is
+		// not tracked or was pruned, neither of which
+		// indicates corruption.
+		before, err := v.balanceStorage.GetBalanceTransactional(
+			ctx,
+			dbTx,
+			change.Account,
+			change.Currency,
+			index-1,
+		)
+		if err != nil {
+			results.SkippedBalanceChanges++
+			continue
+		}
+
+		after, err := v.balanceStorage.GetBalanceTransactional(
+			ctx,
+			dbTx,
+			change.Account,
+			change.Currency,
+			index,
+		)
+		if err != nil {
+			results.SkippedBalanceChanges++
+			continue
+		}
+
+		beforeValue, ok := new(big.Int).SetString(before.Value, 10)
+		if !ok {
+			return fmt.Errorf("unable to parse balance %s", before.Value)
+		}
+
+		afterValue, ok := new(big.Int).SetString(after.Value, 10)
+		if !ok {
+			return fmt.Errorf("unable to parse balance %s", after.Value)
+		}
+
+		difference, ok := new(big.Int).SetString(change.Difference, 10)
+		if !ok {
+			return fmt.Errorf("unable to parse balance change %s", change.Difference)
+		}
+
+		stored := new(big.Int).Sub(afterValue, beforeValue)
+		if stored.Cmp(difference) == 0 {
+			results.BalanceChanges++
+			continue
+		}
+
+		// When check:data does not start at genesis, the balance of
+		// an account before the block it is first seen in is stored
+		// as 0 (even if its initial balance was fetched from the node).
+		if beforeValue.Sign() == 0 {
+			results.SkippedBalanceChanges++
+			continue
+		}
+
+		results.BalanceChanges++
+		results.Corruptions = append(results.Corruptions, &Corruption{
+			Check: BalanceCheck,
+			Index: index,
+			Description: fmt.Sprintf(
+				"stored balance of %s in %s changed by %s but block changes it by %s",
+				types.AccountString(change.Account),
+				change.Currency.Symbol,
+				stored.String(),
+				difference.String(),
+			),
+		})
+	}
+
+	return nil
+}
+
+// verifyCounters compares the block, transaction, and operation
+// counters with the blocks in storage. Counters include blocks
+// that were pruned (and transactions and operations in orphaned
+// blocks), so they must be at least the stored totals.
+func (v *Verifier) verifyCounters(
+	ctx context.Context,
+	blocks int64,
+	transactions int64,
+	operations int64,
+	results *Results,
+) error {
+	counts := map[string]int64{}
+	for _, counter := range []string{
+		storage.BlockCounter,
+		storage.OrphanCounter,
+		storage.TransactionCounter,
+		storage.OperationCounter,
+	} {
+		value, err := v.counterStorage.Get(ctx, counter)
+		if err != nil {
+			return fmt.Errorf("%w: unable to get %s counter", err, counter)
+		}
+
+		counts[counter] = value.Int64()
+	}
+
+	for _, check := range []struct {
+		description string
+		counted     int64
+		stored      int64
+	}{
+		{
+			"canonical blocks (blocks - orphans)",
+			counts[storage.BlockCounter] - counts[storage.OrphanCounter],
+			blocks,
+		},
+		{storage.TransactionCounter, counts[storage.TransactionCounter], transactions},
+		{storage.OperationCounter, counts[storage.OperationCounter], operations},
+	} {
+		if check.counted >= check.stored {
+			continue
+		}
+
+		results.Corruptions = append(results.Corruptions, &Corruption{
+			Check: CounterCheck,
+			Index: results.EndIndex,
+			Description: fmt.Sprintf(
+				"%s counter is %d but %d are stored",
+				check.description,
+				check.counted,
+				check.stored,
+			),
+		})
+	}
+
+	return nil
+}
+
+// Run walks all blocks in storage (from the oldest block to
+// the head block) and returns all corruption found.
+func (v *Verifier) Run(ctx context.Context) (*Results, error) {
+	startIndex, endIndex, err := v.bounds(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	results := &Results{
+		StartIndex:  startIndex,
+		EndIndex:    endIndex,
+		Corruptions: []*Corruption{},
+	}
+	sampled := v.sample(startIndex, endIndex)
+
+	var parent *types.BlockIdentifier
+	var transactions, operations int64
+	for index := startIndex; index <= endIndex; index++ {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		i := index
+		block, err := v.blockStorage.GetBlock(ctx, &types.PartialBlockIdentifier{Index: &i})
+		if errors.Is(err, storage.ErrBlockNotFound) {
+			results.Corruptions = append(results.Corruptions, &Corruption{
+				Check:       LinkageCheck,
+				Index:       index,
+				Description: "block is missing",
+			})
+			parent = nil
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to get block %d", err, index)
+		}
+
+		switch {
+		case block.BlockIdentifier.Index != index:
+			results.Corruptions = append(results.Corruptions, &Corruption{
+				Check: LinkageCheck,
+				Index: index,
+				Description: fmt.Sprintf(
+					"block stored at index %d has index %d",
+					index,
+					block.BlockIdentifier.Index,
+				),
+			})
+		case parent != nil && types.Hash(block.ParentBlockIdentifier) != types.Hash(parent):
+			results.Corruptions = append(results.Corruptions, &Corruption{
+				Check: LinkageCheck,
+				Index: index,
+				Description: fmt.Sprintf(
+					"parent block %s does not match previous block %s",
+					types.PrintStruct(block.ParentBlockIdentifier),
+					types.PrintStruct(parent),
+				),
+			})
+		}
+
+		parent = block.BlockIdentifier
+		results.Blocks++
+		transactions += int64(len(block.Transactions))
+		for _, tx := range block.Transactions {
+			operations += int64(len(tx.Operations))
+		}
+
+		if _, ok := sampled[index]; ok {
+			results.SampledBlocks++
+			if err := v.verifyBalances(ctx, block, results); err != nil {
+				return nil, err
+			}
+		}
+
+		if results.Blocks%logFrequency == 0 {
+			log.Printf("verified %d blocks (current index: %d)\n", results.Blocks, index)
+		}
+	}
+
+	if err := v.verifyCounters(ctx, results.Blocks, transactions, operations, results); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// Print logs all corruption to the console.
+func Print(corruptions []*Corruption) {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetRowLine(true)
+	table.SetRowSeparator("-")
+	table.SetHeader([]string{"Check", "Block", "Description"})
+	for _, corruption := range corruptions {
+		table.Append([]string{
+			string(corruption.Check),
+			strconv.FormatInt(corruption.Index, 10),
+			corruption.Description,
+		})
+	}
+
+	table.Render()
+}
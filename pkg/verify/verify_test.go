@@ -0,0 +1,178 @@
+// Copyright 2020 Coinbase, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package verify
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"path"
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/asserter"
+	"github.com/coinbase/rosetta-sdk-go/parser"
+	"github.com/coinbase/rosetta-sdk-go/storage"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/coinbase/rosetta-sdk-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+var network = &types.NetworkIdentifier{Blockchain: "Bitcoin", Network: "Testnet3"}
+
+func blockIdentifier(index int64) *types.BlockIdentifier {
+	return &types.BlockIdentifier{Hash: fmt.Sprintf("block %d", index), Index: index}
+}
+
+func block(index int64, parent *types.BlockIdentifier) *types.Block {
+	return &types.Block{
+		BlockIdentifier:       blockIdentifier(index),
+		ParentBlockIdentifier: parent,
+		Transactions: []*types.Transaction{
+			{
+				TransactionIdentifier: &types.TransactionIdentifier{
+					Hash: fmt.Sprintf("tx %d", index),
+				},
+				Operations: []*types.Operation{
+					{
+						OperationIdentifier: &types.OperationIdentifier{Index: 0},
+						Type:                "TRANSFER",
+						Status:              types.String("SUCCESS"),
+						Account:             &types.AccountIdentifier{Address: "addr"},
+						Amount: &types.Amount{
+							Value:    "10",
+							Currency: &types.Currency{Symbol: "BTC", Decimals: 8},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestVerifier(t *testing.T) {
+	a, err := asserter.NewClientWithOptions(
+		network,
+		blockIdentifier(0),
+		[]string{"TRANSFER"},
+		[]*types.OperationStatus{{Status: "SUCCESS", Successful: true}},
+		[]*types.Error{},
+		nil,
+	)
+	assert.NoError(t, err)
+	p := parser.New(a, nil, nil)
+
+	var tests = map[string]struct {
+		badParent bool
+		counters  map[string]int64
+
+		corruptions []*Corruption
+	}{
+		"valid": {
+			counters: map[string]int64{
+				storage.BlockCounter:       5,
+				storage.OrphanCounter:      1,
+				storage.TransactionCounter: 5,
+				storage.OperationCounter:   5,
+			},
+			corruptions: []*Corruption{},
+		},
+		"broken linkage": {
+			badParent: true,
+			counters: map[string]int64{
+				storage.BlockCounter:       4,
+				storage.TransactionCounter: 4,
+				storage.OperationCounter:   4,
+			},
+			corruptions: []*Corruption{
+				{
+					Check: LinkageCheck,
+					Index: 2,
+					Description: fmt.Sprintf(
+						"parent block %s does not match previous block %s",
+						types.PrintStruct(&types.BlockIdentifier{Hash: "other", Index: 1}),
+						types.PrintStruct(blockIdentifier(1)),
+					),
+				},
+			},
+		},
+		"missing counts": {
+			counters: map[string]int64{
+				storage.BlockCounter:       4,
+				storage.OrphanCounter:      1,
+				storage.TransactionCounter: 4,
+				storage.OperationCounter:   2,
+			},
+			corruptions: []*Corruption{
+				{
+					Check:       CounterCheck,
+					Index:       3,
+					Description: "canonical blocks (blocks - orphans) counter is 3 but 4 are stored",
+				},
+				{
+					Check:       CounterCheck,
+					Index:       3,
+					Description: fmt.Sprintf("%s counter is 2 but 4 are stored", storage.OperationCounter),
+				},
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			dir, err := utils.CreateTempDir()
+			assert.NoError(t, err)
+			defer utils.RemoveTempDir(dir)
+
+			database, err := storage.NewBadgerStorage(ctx, path.Join(dir, "db"))
+			assert.NoError(t, err)
+			defer database.Close(ctx)
+
+			blockStorage := storage.NewBlockStorage(database)
+			blockStorage.Initialize([]storage.BlockWorker{})
+			for i := int64(0); i < 4; i++ {
+				parent := blockIdentifier(i - 1)
+				if i == 0 {
+					parent = blockIdentifier(0)
+				}
+
+				if test.badParent && i == 2 {
+					parent = &types.BlockIdentifier{Hash: "other", Index: 1}
+				}
+
+				assert.NoError(t, blockStorage.AddBlock(ctx, block(i, parent)))
+			}
+
+			counterStorage := storage.NewCounterStorage(database)
+			for counter, value := range test.counters {
+				_, err := counterStorage.Update(ctx, counter, big.NewInt(value))
+				assert.NoError(t, err)
+			}
+
+			results, err := New(database, p, 10).Run(ctx)
+			assert.NoError(t, err)
+			assert.Equal(t, int64(0), results.StartIndex)
+			assert.Equal(t, int64(3), results.EndIndex)
+			assert.Equal(t, int64(4), results.Blocks)
+			assert.Equal(t, int64(3), results.SampledBlocks)
+
+			// Balances are not tracked, so no
+			// balance changes can be compared.
+			assert.Equal(t, int64(0), results.BalanceChanges)
+			assert.Equal(t, int64(3), results.SkippedBalanceChanges)
+			assert.Equal(t, test.corruptions, results.Corruptions)
+		})
+	}
+}